@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"openai-router-go/internal/config"
+)
+
+// ctlAPIKeyEnv 未指定 --key 时读取的环境变量，都没有时使用配置文件中的本地 API Key
+const ctlAPIKeyEnv = "ANYPROXY_API_KEY"
+
+const ctlUsage = `usage: ctl [--url URL] [--key KEY] [--json] <command> [args]
+
+commands:
+  routes                          list routes
+  routes add --model M --api-url U [--api-key K] [--name N] [--group G] [--format openai|claude|gemini]
+  routes test <id>                send a test request through a route
+  routes enable|disable <id>      enable or disable a route
+  logs [-n N] [--model M] [--failed] [-f]   print recent request logs, -f keeps following new logs
+  stats [--days N]                print usage statistics
+
+The admin API is reached at --url (default: the local API server from config.json)
+with --key, $ANYPROXY_API_KEY or the local API key from config.json.
+`
+
+// localAPIBase 本机 API 服务器地址和客户端；监听所有地址时使用 127.0.0.1，本机地址不校验证书（通常为自签名）
+func localAPIBase(cfg *config.Config, timeout time.Duration) (string, *http.Client) {
+	host := cfg.Host
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	scheme := "http"
+	if cfg.TLSEnabled {
+		scheme = "https"
+	}
+	base := fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(cfg.Port)))
+	return base, &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: isLoopbackHost(host)}},
+	}
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ctlClient 调用 /api/admin 管理接口
+type ctlClient struct {
+	base   string
+	key    string
+	http   *http.Client
+	asJSON bool
+}
+
+// do 发送请求并解码 JSON 响应到 out（out 为 nil 时丢弃），错误响应返回其中的错误信息
+func (c *ctlClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.base+"/api/admin"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.key != "" {
+		req.Header.Set("Authorization", "Bearer "+c.key)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("admin API is not reachable at %s: %v", c.base, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("HTTP %d: %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// printJSON --json 模式下输出结果
+func printJSON(v interface{}) {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(data))
+}
+
+// runCtlCommand 命令行管理工具，通过管理接口操作正在运行的代理（无需图形界面）
+func runCtlCommand(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, ctlUsage) }
+	base, httpClient := localAPIBase(cfg, 2*time.Minute)
+	apiURL := fs.String("url", base, "API server URL")
+	key := fs.String("key", "", "local API key")
+	asJSON := fs.Bool("json", false, "print raw JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	client := &ctlClient{base: strings.TrimRight(*apiURL, "/"), key: *key, http: httpClient, asJSON: *asJSON}
+	if client.key == "" {
+		client.key = os.Getenv(ctlAPIKeyEnv)
+	}
+	if client.key == "" {
+		client.key = cfg.AuthKey()
+	}
+
+	var err error
+	rest := fs.Args()[1:]
+	switch fs.Arg(0) {
+	case "routes":
+		err = ctlRoutes(client, rest)
+	case "logs":
+		err = ctlLogs(client, rest)
+	case "stats":
+		err = ctlStats(client, rest)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown ctl command: %s\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		if err == errCtlUsage {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+var errCtlUsage = fmt.Errorf("invalid arguments, run \"ctl\" without arguments for usage")
+
+// ctlRoute 路由列表中的一项
+type ctlRoute struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Model   string `json:"model"`
+	APIUrl  string `json:"api_url"`
+	Group   string `json:"group"`
+	Format  string `json:"format"`
+	Enabled bool   `json:"enabled"`
+}
+
+func ctlRoutes(c *ctlClient, args []string) error {
+	if len(args) == 0 {
+		var routes []ctlRoute
+		if err := c.do(http.MethodGet, "/routes", nil, &routes); err != nil {
+			return err
+		}
+		if c.asJSON {
+			printJSON(routes)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tMODEL\tFORMAT\tGROUP\tENABLED\tAPI URL")
+		for _, r := range routes {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%v\t%s\n", r.ID, r.Name, r.Model, r.Format, r.Group, r.Enabled, r.APIUrl)
+		}
+		return w.Flush()
+	}
+
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("routes add", flag.ContinueOnError)
+		name := fs.String("name", "", "route name (default: model)")
+		model := fs.String("model", "", "model name clients request")
+		apiURL := fs.String("api-url", "", "upstream API base URL")
+		apiKey := fs.String("api-key", "", "upstream API key")
+		group := fs.String("group", "", "route group")
+		format := fs.String("format", "openai", "upstream API format: openai, claude or gemini")
+		if err := fs.Parse(args[1:]); err != nil {
+			return errCtlUsage
+		}
+		if *model == "" || *apiURL == "" {
+			return fmt.Errorf("--model and --api-url are required")
+		}
+		body := map[string]interface{}{"model": *model, "api_url": *apiURL, "api_key": *apiKey, "group": *group, "format": *format}
+		if *name != "" {
+			body["name"] = *name
+		}
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		if err := c.do(http.MethodPost, "/routes", body, &created); err != nil {
+			return err
+		}
+		if c.asJSON {
+			printJSON(created)
+		} else {
+			fmt.Printf("route %d created\n", created.ID)
+		}
+		return nil
+	case "test":
+		id, err := ctlRouteID(args)
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Success    bool   `json:"success"`
+			StatusCode int    `json:"status_code"`
+			LatencyMs  int64  `json:"latency_ms"`
+			TargetURL  string `json:"target_url"`
+			Failure    string `json:"failure"`
+			Message    string `json:"message"`
+			Reply      string `json:"reply"`
+		}
+		if err := c.do(http.MethodPost, "/routes/"+id+"/test", nil, &result); err != nil {
+			return err
+		}
+		if c.asJSON {
+			printJSON(result)
+		} else if result.Success {
+			fmt.Printf("OK  HTTP %d  %d ms  %s\n%s\n", result.StatusCode, result.LatencyMs, result.TargetURL, result.Reply)
+		} else {
+			fmt.Printf("FAILED  HTTP %d  %d ms  %s\n%s: %s\n", result.StatusCode, result.LatencyMs, result.TargetURL, result.Failure, result.Message)
+		}
+		if !result.Success {
+			return fmt.Errorf("route test failed")
+		}
+		return nil
+	case "enable", "disable":
+		id, err := ctlRouteID(args)
+		if err != nil {
+			return err
+		}
+		if err := c.do(http.MethodPut, "/routes/"+id, map[string]interface{}{"enabled": args[0] == "enable"}, nil); err != nil {
+			return err
+		}
+		fmt.Printf("route %s %sd\n", id, args[0])
+		return nil
+	}
+	return errCtlUsage
+}
+
+// ctlRouteID 读取 routes <command> <id> 中的路由 ID
+func ctlRouteID(args []string) (string, error) {
+	if len(args) < 2 {
+		return "", errCtlUsage
+	}
+	if _, err := strconv.ParseInt(args[1], 10, 64); err != nil {
+		return "", fmt.Errorf("invalid route id: %s", args[1])
+	}
+	return args[1], nil
+}
+
+// ctlLog 请求日志中的一项
+type ctlLog struct {
+	ID           int64  `json:"id"`
+	Model        string `json:"model"`
+	ProviderName string `json:"provider_name"`
+	TotalTokens  int    `json:"total_tokens"`
+	Success      bool   `json:"success"`
+	ErrorMessage string `json:"error_message"`
+	Style        string `json:"style"`
+	ProxyTimeMs  int64  `json:"proxy_time_ms"`
+	IsStream     bool   `json:"is_stream"`
+	RequestID    string `json:"request_id"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// ctlLogsPollInterval -f 模式下查询新日志的间隔
+const ctlLogsPollInterval = 2 * time.Second
+
+func ctlLogs(c *ctlClient, args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	n := fs.Int("n", 20, "number of logs to print (max 100)")
+	model := fs.String("model", "", "only logs for this model")
+	failed := fs.Bool("failed", false, "only failed requests")
+	follow := fs.Bool("f", false, "keep printing new logs")
+	if err := fs.Parse(args); err != nil {
+		return errCtlUsage
+	}
+
+	query := url.Values{}
+	query.Set("page", "1")
+	query.Set("page_size", strconv.Itoa(*n))
+	if *model != "" {
+		query.Set("model", *model)
+	}
+	if *failed {
+		query.Set("success", "false")
+	}
+
+	var lastID int64
+	for {
+		var result struct {
+			Data []ctlLog `json:"data"`
+		}
+		if err := c.do(http.MethodGet, "/logs?"+query.Encode(), nil, &result); err != nil {
+			return err
+		}
+		// 接口按时间倒序返回，按 ID 正序输出尚未输出的日志
+		sort.Slice(result.Data, func(i, j int) bool { return result.Data[i].ID < result.Data[j].ID })
+		for _, l := range result.Data {
+			if l.ID <= lastID {
+				continue
+			}
+			lastID = l.ID
+			if c.asJSON {
+				data, _ := json.Marshal(l)
+				fmt.Println(string(data))
+				continue
+			}
+			printCtlLog(l)
+		}
+		if !*follow {
+			return nil
+		}
+		time.Sleep(ctlLogsPollInterval)
+	}
+}
+
+func printCtlLog(l ctlLog) {
+	status := "OK  "
+	if !l.Success {
+		status = "FAIL"
+	}
+	target := l.Model
+	if l.ProviderName != "" {
+		target += " -> " + l.ProviderName
+	}
+	if l.IsStream {
+		target += " (stream)"
+	}
+	fmt.Printf("%s  %s  %-7s %s  %d tok  %d ms  %s\n",
+		l.CreatedAt, status, l.Style, target, l.TotalTokens, l.ProxyTimeMs, l.RequestID)
+	if !l.Success && l.ErrorMessage != "" {
+		fmt.Printf("    %s\n", l.ErrorMessage)
+	}
+}
+
+func ctlStats(c *ctlClient, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	days := fs.Int("days", 7, "number of days of daily statistics")
+	if err := fs.Parse(args); err != nil {
+		return errCtlUsage
+	}
+
+	var stats struct {
+		RouteCount    int     `json:"route_count"`
+		ModelCount    int     `json:"model_count"`
+		TotalRequests int64   `json:"total_requests"`
+		TotalTokens   int64   `json:"total_tokens"`
+		TodayRequests int64   `json:"today_requests"`
+		TodayTokens   int64   `json:"today_tokens"`
+		SuccessRate   float64 `json:"success_rate"`
+	}
+	if err := c.do(http.MethodGet, "/stats", nil, &stats); err != nil {
+		return err
+	}
+	var daily []struct {
+		Date        string `json:"date"`
+		Requests    int    `json:"requests"`
+		TotalTokens int    `json:"total_tokens"`
+	}
+	if err := c.do(http.MethodGet, "/stats/daily?days="+strconv.Itoa(*days), nil, &daily); err != nil {
+		return err
+	}
+	var models []map[string]interface{}
+	if err := c.do(http.MethodGet, "/stats/models?limit=10", nil, &models); err != nil {
+		return err
+	}
+	if c.asJSON {
+		printJSON(map[string]interface{}{"stats": stats, "daily": daily, "top_models": models})
+		return nil
+	}
+
+	fmt.Printf("Routes: %d  Models: %d\n", stats.RouteCount, stats.ModelCount)
+	fmt.Printf("Total:  %d requests, %d tokens, %.1f%% success\n", stats.TotalRequests, stats.TotalTokens, stats.SuccessRate)
+	fmt.Printf("Today:  %d requests, %d tokens\n\n", stats.TodayRequests, stats.TodayTokens)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tREQUESTS\tTOKENS")
+	for _, d := range daily {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", d.Date, d.Requests, d.TotalTokens)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "MODEL\tREQUESTS\tTOKENS")
+	for _, m := range models {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", m["model"], m["requests"], m["total_tokens"])
+	}
+	return w.Flush()
+}
@@ -5,15 +5,39 @@
  * by mapping them to Wails v3 Call API.
  */
 
-import { Call } from '@wailsio/runtime'
+import { Call, Events } from '@wailsio/runtime'
 
 // Wails v3 service name format: module/package.struct
 // Format: openai-router-go/services.AppService
 const SERVICE = 'openai-router-go/services.AppService'
 
+// 通过 API 服务器的 /admin 打开时（网页管理界面）使用 HTTP 调用，否则使用 Wails 绑定
+const WEB_ADMIN = window.location.pathname.startsWith('/admin')
+
+const callWebAdmin = async (method, args) => {
+  const resp = await fetch(`/admin/rpc/${method}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    credentials: 'same-origin',
+    body: JSON.stringify(args),
+  })
+  if (resp.status === 401) {
+    window.location.href = '/admin/login'
+    throw new Error('not logged in')
+  }
+  const data = await resp.json()
+  if (!resp.ok) {
+    throw data.error || `HTTP ${resp.status}`
+  }
+  return data.result
+}
+
 // Helper function to call service methods
 // Wails v3 Call.ByName expects method name and arguments as separate parameters
 const callService = async (method, ...args) => {
+  if (WEB_ADMIN) {
+    return callWebAdmin(method, args)
+  }
   const fullMethod = `${SERVICE}.${method}`
   console.log(`Calling: ${fullMethod}`, args)
   try {
@@ -32,11 +56,62 @@ const createWailsShim = () => {
   const App = {
     // Route management
     GetRoutes: () => callService('GetRoutes'),
+    GetRoutesWithKeys: () => callService('GetRoutesWithKeys'),
+    RevealRouteKey: (id) => callService('RevealRouteKey', id),
+    GetRouteKeyStats: (id) => callService('GetRouteKeyStats', id),
+    GetKeyPoolStatus: () => callService('GetKeyPoolStatus'),
     AddRoute: (name, model, apiUrl, apiKey, group, format) => 
       callService('AddRoute', name, model, apiUrl, apiKey, group, format),
     UpdateRoute: (id, name, model, apiUrl, apiKey, group, format) => 
       callService('UpdateRoute', id, name, model, apiUrl, apiKey, group, format),
+    SetRouteExtras: (id, extraHeaders, extraQuery) =>
+      callService('SetRouteExtras', id, extraHeaders, extraQuery),
     DeleteRoute: (id) => callService('DeleteRoute', id),
+    ExportRoutes: (opts) => callService('ExportRoutes', opts),
+    ImportRoutes: (data, passphrase) => callService('ImportRoutes', data, passphrase),
+    ImportExternalConfig: (data, apiKey) => callService('ImportExternalConfig', data, apiKey),
+    GetWebAdminEnabled: () => callService('GetWebAdminEnabled'),
+    SetWebAdminEnabled: (enabled) => callService('SetWebAdminEnabled', enabled),
+    GetMCPEnabled: () => callService('GetMCPEnabled'),
+    SetMCPEnabled: (enabled) => callService('SetMCPEnabled', enabled),
+    GetRouteGroups: () => callService('GetRouteGroups'),
+    SetGroupPriority: (name, priority) => callService('SetGroupPriority', name, priority),
+    SetRoutePriority: (id, priority) => callService('SetRoutePriority', id, priority),
+    SetRouteMaxTokens: (id, maxTokens) => callService('SetRouteMaxTokens', id, maxTokens),
+    SetRouteQuota: (id, dailyTokens, dailyRequests) => callService('SetRouteQuota', id, dailyTokens, dailyRequests),
+    SetRouteUpstreamModel: (id, upstreamModel) => callService('SetRouteUpstreamModel', id, upstreamModel),
+    SetRouteProviderPrefs: (id, prefs) => callService('SetRouteProviderPrefs', id, prefs),
+    TestRoute: (id) => callService('TestRoute', id),
+    GetPromptTemplates: () => callService('GetPromptTemplates'),
+    SavePromptTemplate: (template) => callService('SavePromptTemplate', template),
+    DeletePromptTemplate: (id) => callService('DeletePromptTemplate', id),
+    GetModelMetadata: () => callService('GetModelMetadata'),
+    SaveModelMetadata: (metadata) => callService('SaveModelMetadata', metadata),
+    DeleteModelMetadata: (id) => callService('DeleteModelMetadata', id),
+    GetModelSyncSources: () => callService('GetModelSyncSources'),
+    SaveModelSyncSource: (source) => callService('SaveModelSyncSource', source),
+    DeleteModelSyncSource: (id) => callService('DeleteModelSyncSource', id),
+    SyncModelSourceNow: (id) => callService('SyncModelSourceNow', id),
+    GetModelSyncChanges: (sourceID) => callService('GetModelSyncChanges', sourceID),
+    ApplyModelSyncChange: (id) => callService('ApplyModelSyncChange', id),
+    DismissModelSyncChange: (id) => callService('DismissModelSyncChange', id),
+    GetMirrorRules: () => callService('GetMirrorRules'),
+    SaveMirrorRule: (rule) => callService('SaveMirrorRule', rule),
+    DeleteMirrorRule: (id) => callService('DeleteMirrorRule', id),
+    GetMirrorSummary: () => callService('GetMirrorSummary'),
+    GetMirrorResults: (model, limit) => callService('GetMirrorResults', model, limit),
+    ClearMirrorResults: () => callService('ClearMirrorResults'),
+    StartPlayground: (req) => callService('StartPlayground', req),
+    GetPlaygroundSession: (id) => callService('GetPlaygroundSession', id),
+    CancelPlayground: (id) => callService('CancelPlayground', id),
+    GetActiveRequests: () => callService('GetActiveRequests'),
+    CancelActiveRequest: (requestId) => callService('CancelActiveRequest', requestId),
+    CompareConversation: (req) => callService('CompareConversation', req),
+    AggregateConversation: (req) => callService('AggregateConversation', req),
+    GetExperiments: () => callService('GetExperiments'),
+    SaveExperiment: (experiment) => callService('SaveExperiment', experiment),
+    DeleteExperiment: (id) => callService('DeleteExperiment', id),
+    GetExperimentReport: (id) => callService('GetExperimentReport', id),
     ToggleRoute: (id, enabled) => callService('ToggleRoute', id, enabled),
     
     // Statistics
@@ -45,6 +120,9 @@ const createWailsShim = () => {
     GetHourlyStats: () => callService('GetHourlyStats'),
     GetSecondlyStats: (minutes) => callService('GetSecondlyStats', minutes),
     GetModelRanking: (limit) => callService('GetModelRanking', limit),
+    GetStatsBreakdown: (dimension, days) => callService('GetStatsBreakdown', dimension, days),
+    GetLatencyStats: (hours) => callService('GetLatencyStats', hours),
+    GetErrorStats: (hours) => callService('GetErrorStats', hours),
     ClearStats: () => callService('ClearStats'),
     
     // Configuration
@@ -53,19 +131,39 @@ const createWailsShim = () => {
       callService('UpdateConfig', redirectEnabled, redirectKeyword, redirectTargetModel, redirectTargetRouteId),
     UpdatePort: (port) => callService('UpdatePort', port),
     UpdateLocalApiKey: (newApiKey) => callService('UpdateLocalApiKey', newApiKey),
+    GetNetworkSettings: () => callService('GetNetworkSettings'),
+    SetNetworkSettings: (listenHosts, allowedCidrs) => callService('SetNetworkSettings', listenHosts || [], allowedCidrs || []),
+    GetTLSSettings: () => callService('GetTLSSettings'),
+    SetTLSSettings: (enabled, certPath, keyPath) => callService('SetTLSSettings', enabled, certPath || '', keyPath || ''),
     RestartApp: () => callService('RestartApp'),
+    GetUpdateSettings: () => callService('GetUpdateSettings'),
+    SetUpdateSettings: (enabled, channel) => callService('SetUpdateSettings', enabled, channel),
+    CheckForUpdate: () => callService('CheckForUpdate'),
+    GetUpdateStatus: () => callService('GetUpdateStatus'),
+    InstallUpdate: () => callService('InstallUpdate'),
     
     // App settings
     GetAppSettings: () => callService('GetAppSettings'),
     SetMinimizeToTray: (enabled) => callService('SetMinimizeToTray', enabled),
     SetAutoStart: (enabled) => callService('SetAutoStart', enabled),
+    GetServiceStatus: () => callService('GetServiceStatus'),
+    InstallService: () => callService('InstallService'),
+    UninstallService: () => callService('UninstallService'),
+    StartService: () => callService('StartService'),
+    StopService: () => callService('StopService'),
     SetEnableFileLog: (enabled) => callService('SetEnableFileLog', enabled),
     SetFallbackEnabled: (enabled) => callService('SetFallbackEnabled', enabled),
+    GetQuickStatus: () => callService('GetQuickStatus'),
+    GetProxyPaused: () => callService('GetProxyPaused'),
+    SetProxyPaused: (paused) => callService('SetProxyPaused', paused),
+    SetRedirectTarget: (routeId) => callService('SetRedirectTarget', routeId),
     GetProxyEnabled: () => callService('GetProxyEnabled'),
     SetProxyEnabled: (enabled) => callService('SetProxyEnabled', enabled),
     
     // Remote models
+    GetProviderTemplates: () => callService('GetProviderTemplates'),
     FetchRemoteModels: (apiUrl, apiKey) => callService('FetchRemoteModels', apiUrl, apiKey),
+    RefreshRemoteModels: (apiUrl, apiKey) => callService('RefreshRemoteModels', apiUrl, apiKey),
     
     // Import
     ImportRouteFromFormat: (name, model, apiUrl, apiKey, group, targetFormat) => 
@@ -74,10 +172,16 @@ const createWailsShim = () => {
     // Database management
     CompressDatabase: () => callService('CompressDatabase'),
     GetUsageSummary: () => callService('GetUsageSummary'),
+    GetLogBufferStatus: () => callService('GetLogBufferStatus'),
+    GetSchemaDriftWarnings: () => callService('GetSchemaDriftWarnings'),
+    ClearSchemaDriftWarnings: (resetBaseline) => callService('ClearSchemaDriftWarnings', resetBaseline),
 
     // Request logs (with time range support)
-    GetRequestLogs: (page, pageSize, model, style, success, startTime, endTime) =>
-      callService('GetRequestLogs', page, pageSize, model, style, success, startTime || '', endTime || ''),
+    GetRequestLogs: (page, pageSize, model, style, success, startTime, endTime, requestId, search) =>
+      callService('GetRequestLogs', page, pageSize, model, style, success, startTime || '', endTime || '', requestId || '', search || ''),
+    GetRequestLogExportColumns: () => callService('GetRequestLogExportColumns'),
+    ExportRequestLogs: (dateFrom, dateTo, filters, format, columns) =>
+      callService('ExportRequestLogs', dateFrom || '', dateTo || '', filters || {}, format || 'csv', columns || []),
 
     // Health monitoring
     GetHealthStatus: () => callService('GetHealthStatus'),
@@ -87,15 +191,60 @@ const createWailsShim = () => {
     SetTracesEnabled: (enabled) => callService('SetTracesEnabled', enabled),
     GetTracesRetentionDays: () => callService('GetTracesRetentionDays'),
     SetTracesRetentionDays: (days) => callService('SetTracesRetentionDays', days),
+    GetTraceStreamMaxKB: () => callService('GetTraceStreamMaxKB'),
+    SetTraceStreamMaxKB: (kb) => callService('SetTraceStreamMaxKB', kb),
     GetTracesSessionTimeout: () => callService('GetTracesSessionTimeout'),
     SetTracesSessionTimeout: (minutes) => callService('SetTracesSessionTimeout', minutes),
     GetTraceSessions: (page, pageSize) => callService('GetTraceSessions', page, pageSize),
     GetTracesBySession: (sessionID) => callService('GetTracesBySession', sessionID),
-    GetAllTraces: (page, pageSize, success, startTime, endTime) => 
-      callService('GetAllTraces', page, pageSize, success || '', startTime || '', endTime || ''),
+    GetTraceConversion: (id) => callService('GetTraceConversion', id),
+    GetAllTraces: (page, pageSize, success, startTime, endTime, requestId, search) => 
+      callService('GetAllTraces', page, pageSize, success || '', startTime || '', endTime || '', requestId || '', search || ''),
+    ExportTraces: (opts) => callService('ExportTraces', opts),
     ClearOldTraces: (beforeDays) => callService('ClearOldTraces', beforeDays),
     ClearAllTraces: () => callService('ClearAllTraces'),
     GetTracesCount: () => callService('GetTracesCount'),
+    GetLogSettings: () => callService('GetLogSettings'),
+    SetLogSettings: (level, format, maxSizeMB, maxBackups, maxMessageBytes) =>
+      callService('SetLogSettings', level, format, maxSizeMB, maxBackups, maxMessageBytes),
+    GetBodyLogSettings: () => callService('GetBodyLogSettings'),
+    SetBodyLogSettings: (mode, samplePercent) => callService('SetBodyLogSettings', mode, samplePercent),
+    GetRequestLimits: () => callService('GetRequestLimits'),
+    SetRequestLimits: (maxBodyMB, maxMessages) => callService('SetRequestLimits', maxBodyMB, maxMessages),
+    GetModerationSettings: () => callService('GetModerationSettings'),
+    SetModerationSettings: (enabled, rules) => callService('SetModerationSettings', enabled, rules),
+    GetModelCompatSettings: () => callService('GetModelCompatSettings'),
+    SetModelCompatSettings: (enabled, rules) => callService('SetModelCompatSettings', enabled, rules),
+    GetClaudeMaxTokens: () => callService('GetClaudeMaxTokens'),
+    SetClaudeMaxTokens: (defaults) => callService('SetClaudeMaxTokens', defaults),
+    GetAdapterPlugins: () => callService('GetAdapterPlugins'),
+    SetAdapterPlugins: (plugins) => callService('SetAdapterPlugins', plugins),
+    GetRoutingRuleSettings: () => callService('GetRoutingRuleSettings'),
+    SetRoutingRuleSettings: (enabled, rules) => callService('SetRoutingRuleSettings', enabled, rules),
+    GetContextOverflowSettings: () => callService('GetContextOverflowSettings'),
+    SetContextOverflowSettings: (enabled, strategy, summaryModel) => callService('SetContextOverflowSettings', enabled, strategy, summaryModel),
+    GetQuotaSettings: () => callService('GetQuotaSettings'),
+    SetQuotaSettings: (timezone) => callService('SetQuotaSettings', timezone),
+    GetClientSnippets: (model) => callService('GetClientSnippets', model || ''),
+    GetMaintenanceSettings: () => callService('GetMaintenanceSettings'),
+    SetMaintenanceSettings: (enabled, intervalHours, logRetentionDays, vacuumIntervalHours) =>
+      callService('SetMaintenanceSettings', enabled, intervalHours, logRetentionDays, vacuumIntervalHours),
+    RunMaintenanceNow: () => callService('RunMaintenanceNow'),
+    GetHealthProbeSettings: () => callService('GetHealthProbeSettings'),
+    SetHealthProbeSettings: (enabled, intervalSeconds, mode, healthAware) =>
+      callService('SetHealthProbeSettings', enabled, intervalSeconds, mode, healthAware),
+    RunHealthProbeNow: () => callService('RunHealthProbeNow'),
+    GetNotificationSettings: () => callService('GetNotificationSettings'),
+    SetNotificationSettings: (settings) => callService('SetNotificationSettings', settings),
+    TestNotification: () => callService('TestNotification'),
+    GetReportSettings: () => callService('GetReportSettings'),
+    SetReportSettings: (settings) => callService('SetReportSettings', settings),
+    PreviewUsageReport: () => callService('PreviewUsageReport'),
+    SendUsageReportNow: () => callService('SendUsageReportNow'),
+    FetchOpenRouterPrices: () => callService('FetchOpenRouterPrices'),
+    GetTraceRedactionEnabled: () => callService('GetTraceRedactionEnabled'),
+    SetTraceRedactionEnabled: (enabled, adminKey) => callService('SetTraceRedactionEnabled', enabled, adminKey || ''),
+    RevealTrace: (id, adminKey) => callService('RevealTrace', id, adminKey),
   }
 
   // Create the window.go.main.App structure
@@ -111,4 +260,35 @@ const createWailsShim = () => {
 // Initialize the shim
 createWailsShim()
 
+// 后端事件通知（路由故障、预算提醒）显示为系统通知，未授权时显示为应用内消息
+const showNotification = (note) => {
+  if (!note) return
+  const fallback = () => {
+    const type = note.level === 'error' ? 'error' : note.level === 'warning' ? 'warning' : 'info'
+    window.$message?.[type](`${note.title}: ${note.message}`, { duration: 8000 })
+  }
+  if (!('Notification' in window)) {
+    fallback()
+    return
+  }
+  const show = () => new Notification(note.title, { body: note.message, tag: note.event })
+  if (Notification.permission === 'granted') {
+    show()
+  } else if (Notification.permission !== 'denied') {
+    Notification.requestPermission().then(p => (p === 'granted' ? show() : fallback()))
+  } else {
+    fallback()
+  }
+}
+
+if (!WEB_ADMIN) {
+  Events.On('anyproxy:notification', (event) => showNotification(event.data))
+  // 托盘快捷操作修改了配置，通知页面重新加载
+  Events.On('anyproxy:config-changed', () => window.dispatchEvent(new Event('anyproxy:config-changed')))
+  // 后台检查发现新版本
+  Events.On('anyproxy:update-available', (event) => window.dispatchEvent(new CustomEvent('anyproxy:update-available', { detail: event.data })))
+  // 实时请求流（每批为 LiveRequestEvent 数组）
+  Events.On('anyproxy:requests', (event) => window.dispatchEvent(new CustomEvent('anyproxy:requests', { detail: event.data })))
+}
+
 export default createWailsShim
@@ -3,6 +3,8 @@ import vue from '@vitejs/plugin-vue'
 
 export default defineConfig({
   plugins: [vue()],
+  // 相对路径：同一份构建产物同时用于桌面窗口（/）和网页管理界面（/admin/）
+  base: './',
   publicDir: 'public',
   build: {
     outDir: 'dist',
@@ -1,5 +1,7 @@
 package adapters
 
+import "sync"
+
 // Adapter 接口定义
 type Adapter interface {
 	AdaptRequest(request map[string]interface{}, targetModel string) (map[string]interface{}, error)
@@ -9,22 +11,50 @@ type Adapter interface {
 	AdaptStreamEnd() []map[string]interface{}
 }
 
-// 适配器注册表
-var adapterRegistry = make(map[string]Adapter)
+// 适配器注册表（插件在运行时注册和移除，读写需要加锁）
+var (
+	adapterRegistry   = make(map[string]Adapter)
+	adapterRegistryMu sync.RWMutex
+)
 
 // RegisterAdapter 注册适配器
 func RegisterAdapter(name string, adapter Adapter) {
+	adapterRegistryMu.Lock()
 	adapterRegistry[name] = adapter
+	adapterRegistryMu.Unlock()
+}
+
+// unregisterAdapter 移除适配器
+func unregisterAdapter(name string) {
+	adapterRegistryMu.Lock()
+	delete(adapterRegistry, name)
+	adapterRegistryMu.Unlock()
 }
 
 // GetAdapter 获取适配器
 func GetAdapter(name string) Adapter {
+	adapterRegistryMu.RLock()
+	defer adapterRegistryMu.RUnlock()
 	if adapter, ok := adapterRegistry[name]; ok {
 		return adapter
 	}
 	return nil
 }
 
+// streamStateful 转换流式响应时保存状态的适配器（如 tool_calls 的 index），每个流需要独立的实例
+type streamStateful interface {
+	newStream() Adapter
+}
+
+// GetStreamAdapter 获取用于转换一个流式响应的适配器，保存流式状态的适配器返回新实例，避免并发的流互相影响
+func GetStreamAdapter(name string) Adapter {
+	adapter := GetAdapter(name)
+	if stateful, ok := adapter.(streamStateful); ok {
+		return stateful.newStream()
+	}
+	return adapter
+}
+
 func init() {
 	// 注册所有适配器
 	RegisterAdapter("anthropic", &AnthropicAdapter{})
@@ -18,11 +18,9 @@ func (a *AnthropicAdapter) AdaptRequest(request map[string]interface{}, targetMo
 		adapted["model"] = "claude-3-sonnet-20240229"
 	}
 
-	// 设置最大tokens
+	// 设置最大tokens，未指定时由代理按模型填充默认值
 	if maxTokens, ok := request["max_tokens"]; ok {
 		adapted["max_tokens"] = maxTokens
-	} else {
-		adapted["max_tokens"] = 4096
 	}
 
 	// 转换消息格式
@@ -57,11 +55,13 @@ func (a *AnthropicAdapter) AdaptRequest(request map[string]interface{}, targetMo
 
 	// 其他参数
 	if temp, ok := request["temperature"]; ok {
-		adapted["temperature"] = temp
+		adapted["temperature"] = claudeTemperature(temp)
 	}
 	if topP, ok := request["top_p"]; ok {
 		adapted["top_p"] = topP
 	}
+	copyParam(adapted, "top_k", request, "top_k")
+	setStopSequences(adapted, "stop_sequences", request["stop"], 0)
 	if stream, ok := request["stream"]; ok {
 		adapted["stream"] = stream
 	}
@@ -28,6 +28,8 @@ func (a *ClaudeToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 
 	// 转换 messages 为 contents
 	contents := make([]interface{}, 0)
+	// tool_use id -> 函数名，用于为 tool_result 找到对应的 functionResponse 名称
+	toolNames := make(map[string]string)
 	if messages, ok := reqData["messages"].([]interface{}); ok {
 		for _, msg := range messages {
 			if msgMap, ok := msg.(map[string]interface{}); ok {
@@ -61,9 +63,15 @@ func (a *ClaudeToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 									parts = append(parts, map[string]interface{}{"text": text})
 								}
 
+							case "thinking", "redacted_thinking":
+								// Gemini 不使用历史中的思考内容，跳过
+
 							case "tool_use":
 								name, _ := blockMap["name"].(string)
 								input := blockMap["input"]
+								if id, _ := blockMap["id"].(string); id != "" {
+									toolNames[id] = name
+								}
 
 								parts = append(parts, map[string]interface{}{
 									"functionCall": map[string]interface{}{
@@ -84,8 +92,11 @@ func (a *ClaudeToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 									resultStr = string(resultJSON)
 								}
 
-								// 提取函数名(从 tool_use_id)
-								functionName := extractFunctionNameFromID(toolUseID)
+								// 优先使用历史中 tool_use 的函数名，找不到时从 tool_use_id 提取
+								functionName, ok := toolNames[toolUseID]
+								if !ok {
+									functionName = extractFunctionNameFromID(toolUseID)
+								}
 
 								parts = append(parts, map[string]interface{}{
 									"functionResponse": map[string]interface{}{
@@ -127,8 +138,12 @@ func (a *ClaudeToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 		generationConfig["topP"] = topP
 	}
 
-	if stopSequences, ok := reqData["stop_sequences"]; ok {
-		generationConfig["stopSequences"] = stopSequences
+	setStopSequences(generationConfig, "stopSequences", reqData["stop_sequences"], geminiMaxStopSequences)
+	copyParam(generationConfig, "topK", reqData, "top_k")
+
+	// thinking.budget_tokens → thinkingConfig.thinkingBudget
+	if budget := claudeThinkingBudget(reqData); budget > 0 {
+		generationConfig["thinkingConfig"] = geminiThinkingConfig(budget)
 	}
 
 	if len(generationConfig) > 0 {
@@ -219,12 +234,21 @@ func (a *ClaudeToGeminiAdapter) AdaptResponse(respData map[string]interface{}) (
 				if parts, ok := content["parts"].([]interface{}); ok {
 					for _, part := range parts {
 						if partMap, ok := part.(map[string]interface{}); ok {
-							// 文本内容
+							// 文本内容，thought 部分转换为 thinking 块
 							if text, ok := partMap["text"].(string); ok {
-								contentBlocks = append(contentBlocks, map[string]interface{}{
-									"type": "text",
-									"text": text,
-								})
+								if thought, _ := partMap["thought"].(bool); thought {
+									signature, _ := partMap["thoughtSignature"].(string)
+									contentBlocks = append(contentBlocks, map[string]interface{}{
+										"type":      "thinking",
+										"thinking":  text,
+										"signature": signature,
+									})
+								} else {
+									contentBlocks = append(contentBlocks, map[string]interface{}{
+										"type": "text",
+										"text": text,
+									})
+								}
 							}
 
 							// 函数调用
@@ -234,7 +258,7 @@ func (a *ClaudeToGeminiAdapter) AdaptResponse(respData map[string]interface{}) (
 
 								contentBlocks = append(contentBlocks, map[string]interface{}{
 									"type":  "tool_use",
-									"id":    fmt.Sprintf("toolu_%s_%d", name, time.Now().UnixNano()),
+									"id":    fmt.Sprintf("toolu_%s_%d_%d", name, time.Now().UnixNano(), len(contentBlocks)),
 									"name":  name,
 									"input": args,
 								})
@@ -302,6 +326,10 @@ func (a *ClaudeToGeminiAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 				if parts, ok := content["parts"].([]interface{}); ok {
 					for _, part := range parts {
 						if partMap, ok := part.(map[string]interface{}); ok {
+							// 流式输出只有一个 text 块，thought 部分不混入正文
+							if thought, _ := partMap["thought"].(bool); thought {
+								continue
+							}
 							if text, ok := partMap["text"].(string); ok {
 								textContent += text
 							}
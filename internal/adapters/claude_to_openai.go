@@ -9,15 +9,22 @@ import (
 
 // ClaudeToOpenAIAdapter 将 Claude (Anthropic) 格式转换为 OpenAI 格式
 type ClaudeToOpenAIAdapter struct {
-	// 用于在流式响应中跟踪当前的 tool_use ID 和名称
-	currentToolCallID   string // 当前正在处理的 tool_use ID
-	currentToolCallName string // 当前正在处理的 tool_use name
+	// 流式响应中 Claude content block 的 index 到 OpenAI tool_calls index 的映射
+	// 并行工具调用时每个 tool_use 块对应一个独立的 tool_call
+	toolCallIndexes map[int]int
+	// message_start 中的输入 token，结束时与 message_delta 中的输出 token 一起作为 usage 发送
+	inputTokens int
 }
 
 func init() {
 	RegisterAdapter("claude-to-openai", &ClaudeToOpenAIAdapter{})
 }
 
+// newStream 每个流式响应使用独立的实例
+func (a *ClaudeToOpenAIAdapter) newStream() Adapter {
+	return &ClaudeToOpenAIAdapter{}
+}
+
 // AdaptRequest 将 Claude 请求转换为 OpenAI 请求
 func (a *ClaudeToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, model string) (map[string]interface{}, error) {
 	// Claude 请求格式示例:
@@ -137,6 +144,11 @@ func (a *ClaudeToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 		openaiReq["tool_choice"] = convertClaudeToolChoiceToOpenAI(toolChoice)
 	}
 
+	// tool_choice.disable_parallel_tool_use → parallel_tool_calls=false
+	if claudeDisablesParallelToolUse(reqData) && openaiReq["tools"] != nil {
+		openaiReq["parallel_tool_calls"] = false
+	}
+
 	// 转换其他参数
 	if maxTokens, ok := reqData["max_tokens"]; ok {
 		openaiReq["max_tokens"] = maxTokens
@@ -161,9 +173,12 @@ func (a *ClaudeToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 		}
 	}
 
-	// 处理 stop sequences
-	if stopSequences, ok := reqData["stop_sequences"]; ok {
-		openaiReq["stop"] = stopSequences
+	// 处理 stop sequences（OpenAI 最多 4 个）；top_k OpenAI 不支持，不转发
+	setStopSequences(openaiReq, "stop", reqData["stop_sequences"], openAIMaxStopSequences)
+
+	// thinking.budget_tokens → reasoning_effort
+	if effort := BudgetToReasoningEffort(claudeThinkingBudget(reqData)); effort != "" {
+		openaiReq["reasoning_effort"] = effort
 	}
 
 	// 注意：不要转发以下 Claude 特有的字段，因为 OpenAI API 不支持：
@@ -247,13 +262,14 @@ func convertClaudeUserMessage(contentArr []interface{}) []interface{} {
 	return result
 }
 
-// convertClaudeAssistantMessage 转换包含 tool_use 的助手消息
+// convertClaudeAssistantMessage 转换包含 tool_use、thinking 的助手消息
 func convertClaudeAssistantMessage(contentArr []interface{}) map[string]interface{} {
 	assistantMsg := map[string]interface{}{
 		"role": "assistant",
 	}
 
 	var textParts []string
+	var thinkingParts []string
 	var toolCalls []interface{}
 
 	for _, block := range contentArr {
@@ -292,6 +308,15 @@ func convertClaudeAssistantMessage(contentArr []interface{}) map[string]interfac
 			if text, ok := blockMap["text"].(string); ok && text != "" {
 				textParts = append(textParts, text)
 			}
+
+		case "thinking":
+			// thinking → reasoning_content，签名留给之后转回 Claude 时使用
+			if thinking, ok := blockMap["thinking"].(string); ok && thinking != "" {
+				thinkingParts = append(thinkingParts, thinking)
+			}
+			if signature, ok := blockMap["signature"].(string); ok && len(signature) >= MinSignatureLength {
+				StoreThoughtSignature(signature)
+			}
 		}
 	}
 
@@ -299,6 +324,10 @@ func convertClaudeAssistantMessage(contentArr []interface{}) map[string]interfac
 		assistantMsg["content"] = strings.Join(textParts, "\n")
 	}
 
+	if len(thinkingParts) > 0 {
+		assistantMsg["reasoning_content"] = strings.Join(thinkingParts, "\n")
+	}
+
 	if len(toolCalls) > 0 {
 		assistantMsg["tool_calls"] = toolCalls
 	}
@@ -416,6 +445,16 @@ func convertClaudeToolChoiceToOpenAI(toolChoice interface{}) interface{} {
 	return "auto"
 }
 
+// claudeDisablesParallelToolUse 判断 Claude 请求是否通过 tool_choice 禁用了并行工具调用
+func claudeDisablesParallelToolUse(reqData map[string]interface{}) bool {
+	toolChoice, ok := reqData["tool_choice"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	disable, _ := toolChoice["disable_parallel_tool_use"].(bool)
+	return disable
+}
+
 // sanitizeClaudeJSONSchema 清理 JSON Schema，移除不支持的字段
 func sanitizeClaudeJSONSchema(schema interface{}) interface{} {
 	if schema == nil {
@@ -516,6 +555,106 @@ func (a *ClaudeToOpenAIAdapter) AdaptResponse(respData map[string]interface{}) (
 	return respData, nil
 }
 
+// ClaudeResponseToOpenAI 将 Claude 非流式响应转换为 OpenAI 响应
+// thinking 块转换为 message.reasoning_content，tool_use 块转换为 tool_calls
+func ClaudeResponseToOpenAI(claudeResp map[string]interface{}) map[string]interface{} {
+	openaiResp := make(map[string]interface{})
+
+	if id, ok := claudeResp["id"].(string); ok {
+		openaiResp["id"] = id
+	} else {
+		openaiResp["id"] = fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	}
+	openaiResp["object"] = "chat.completion"
+	openaiResp["created"] = time.Now().Unix()
+	if model, ok := claudeResp["model"].(string); ok {
+		openaiResp["model"] = model
+	}
+
+	var content, reasoning string
+	var toolCalls []interface{}
+	if contentArray, ok := claudeResp["content"].([]interface{}); ok {
+		for _, item := range contentArray {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "text":
+				text, _ := block["text"].(string)
+				content += text
+			case "thinking":
+				thinking, _ := block["thinking"].(string)
+				reasoning += thinking
+				if signature, ok := block["signature"].(string); ok && len(signature) >= MinSignatureLength {
+					StoreThoughtSignature(signature)
+				}
+			case "tool_use":
+				id, _ := block["id"].(string)
+				name, _ := block["name"].(string)
+				arguments := "{}"
+				if input := block["input"]; input != nil {
+					if inputBytes, err := json.Marshal(input); err == nil {
+						arguments = string(inputBytes)
+					}
+				}
+				toolCalls = append(toolCalls, map[string]interface{}{
+					"id":   id,
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      name,
+						"arguments": arguments,
+					},
+				})
+			}
+		}
+	}
+
+	finishReason := "stop"
+	if stopReason, ok := claudeResp["stop_reason"].(string); ok {
+		switch stopReason {
+		case "end_turn", "stop_sequence":
+			finishReason = "stop"
+		case "max_tokens":
+			finishReason = "length"
+		case "tool_use":
+			finishReason = "tool_calls"
+		default:
+			finishReason = stopReason
+		}
+	}
+
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": content,
+	}
+	if reasoning != "" {
+		message["reasoning_content"] = reasoning
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+	openaiResp["choices"] = []interface{}{
+		map[string]interface{}{
+			"index":         0,
+			"message":       message,
+			"finish_reason": finishReason,
+		},
+	}
+
+	if usage, ok := claudeResp["usage"].(map[string]interface{}); ok {
+		promptTokens := numberToInt(usage["input_tokens"])
+		completionTokens := numberToInt(usage["output_tokens"])
+		openaiResp["usage"] = map[string]interface{}{
+			"prompt_tokens":     promptTokens,
+			"completion_tokens": completionTokens,
+			"total_tokens":      promptTokens + completionTokens,
+		}
+	}
+
+	return openaiResp
+}
+
 // AdaptStreamChunk 转换流式响应块 - Claude SSE → OpenAI SSE
 // 支持：text_delta, thinking_delta, input_json_delta (tool_use)
 func (a *ClaudeToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (map[string]interface{}, error) {
@@ -523,8 +662,27 @@ func (a *ClaudeToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 
 	switch chunkType {
 	case "message_start":
-		// 跳过 message_start 事件，OpenAI 不需要
-		return nil, nil
+		// 新消息重新分配 tool_calls index，发送 OpenAI 流的首个 role 分片
+		a.toolCallIndexes = make(map[int]int)
+		a.inputTokens = 0
+		if message, ok := chunk["message"].(map[string]interface{}); ok {
+			if usage, ok := message["usage"].(map[string]interface{}); ok {
+				a.inputTokens = numberToInt(usage["input_tokens"])
+			}
+		}
+		return map[string]interface{}{
+			"id":      "chatcmpl-" + fmt.Sprintf("%d", time.Now().UnixNano()),
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   "claude",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"index":         0,
+					"delta":         map[string]interface{}{"role": "assistant", "content": ""},
+					"finish_reason": nil,
+				},
+			},
+		}, nil
 
 	case "content_block_start":
 		// 检查 content_block 类型
@@ -533,17 +691,18 @@ func (a *ClaudeToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 
 			switch blockType {
 			case "tool_use":
-				// 保存 tool_use 信息到适配器状态，用于后续的 input_json_delta 事件
-				// 注意：Claude 流式响应中，id 和 name 在 content_block_start 事件中提供
-				// 但 input_json_delta 事件不包含这些信息，需要从适配器状态中获取
-				if id, ok := contentBlock["id"].(string); ok {
-					a.currentToolCallID = id
-				}
-				if name, ok := contentBlock["name"].(string); ok {
-					a.currentToolCallName = name
-				}
-				// 对于 tool_use 类型，返回 nil 以等待 input_json_delta 事件
-				return nil, nil
+				// Claude 的 id 和 name 只在 content_block_start 中出现，此时发送 tool_call 的首个分片
+				// 后续 input_json_delta 只携带 arguments（OpenAI 客户端会拼接 name，不能重复发送）
+				id, _ := contentBlock["id"].(string)
+				name, _ := contentBlock["name"].(string)
+				return a.toolCallChunk(a.toolCallIndex(chunk), map[string]interface{}{
+					"id":   id,
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      name,
+						"arguments": "",
+					},
+				}), nil
 			case "thinking":
 				// thinking 不需要特殊处理
 			case "text":
@@ -596,6 +755,12 @@ func (a *ClaudeToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 					}, nil
 				}
 
+			case "signature_delta":
+				// thinking 块的签名，保存后在下一轮请求中还原 thinking 块
+				if signature, ok := delta["signature"].(string); ok && len(signature) >= MinSignatureLength {
+					StoreThoughtSignature(signature)
+				}
+
 			case "input_json_delta":
 				// Tool Use 参数 → 转换为 OpenAI 的 tool_calls
 				// 注意：OpenAI 流式发送 tool_calls 是分片的
@@ -624,7 +789,7 @@ func (a *ClaudeToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 				openaiStopReason = "tool_calls"
 			}
 
-			return map[string]interface{}{
+			finalChunk := map[string]interface{}{
 				"id":      "chatcmpl-" + fmt.Sprintf("%d", time.Now().UnixNano()),
 				"object":  "chat.completion.chunk",
 				"created": time.Now().Unix(),
@@ -636,7 +801,21 @@ func (a *ClaudeToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 						"finish_reason": openaiStopReason,
 					},
 				},
-			}, nil
+			}
+			// 最后一个分片附带 usage（OpenAI 客户端请求 include_usage 时需要）
+			if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+				promptTokens := a.inputTokens
+				if promptTokens == 0 {
+					promptTokens = numberToInt(usage["input_tokens"])
+				}
+				completionTokens := numberToInt(usage["output_tokens"])
+				finalChunk["usage"] = map[string]interface{}{
+					"prompt_tokens":     promptTokens,
+					"completion_tokens": completionTokens,
+					"total_tokens":      promptTokens + completionTokens,
+				}
+			}
+			return finalChunk, nil
 		}
 		return nil, nil
 
@@ -664,36 +843,31 @@ func (a *ClaudeToOpenAIAdapter) AdaptStreamEnd() []map[string]interface{} {
 
 // adaptToolUseDelta 处理 Claude tool_use 的 input_json_delta 并转换为 OpenAI 格式
 func (a *ClaudeToOpenAIAdapter) adaptToolUseDelta(delta map[string]interface{}, chunk map[string]interface{}) map[string]interface{} {
-	// 从 content_block 中提取 tool_use 信息
-	index, _ := chunk["index"].(float64)
-
-	// 从适配器状态中获取当前 tool_use 的 ID 和 name
-	toolID := a.currentToolCallID
-	toolName := a.currentToolCallName
-
-	// 提取 partial_json
 	partialJSON, _ := delta["partial_json"].(string)
+	return a.toolCallChunk(a.toolCallIndex(chunk), map[string]interface{}{
+		"function": map[string]interface{}{
+			"arguments": partialJSON,
+		},
+	})
+}
 
-	// 构建 OpenAI 格式的 tool_calls delta
-	toolCallDelta := map[string]interface{}{
-		"index": int(index),
-	}
-
-	// 如果有 toolID，添加 id 字段
-	if toolID != "" {
-		toolCallDelta["id"] = toolID
-	}
-
-	toolCallDelta["type"] = "function"
-	toolCallDelta["function"] = map[string]interface{}{
-		"arguments": partialJSON,
+// toolCallIndex 返回 Claude content block 对应的 OpenAI tool_calls index，首次出现时按顺序分配
+func (a *ClaudeToOpenAIAdapter) toolCallIndex(chunk map[string]interface{}) int {
+	if a.toolCallIndexes == nil {
+		a.toolCallIndexes = make(map[int]int)
 	}
-
-	// 如果有 toolName，添加 name 到 function 中
-	if toolName != "" {
-		toolCallDelta["function"].(map[string]interface{})["name"] = toolName
+	blockIndex := numberToInt(chunk["index"])
+	if index, ok := a.toolCallIndexes[blockIndex]; ok {
+		return index
 	}
+	index := len(a.toolCallIndexes)
+	a.toolCallIndexes[blockIndex] = index
+	return index
+}
 
+// toolCallChunk 构建只包含一个 tool_call 分片的 OpenAI 流式块
+func (a *ClaudeToOpenAIAdapter) toolCallChunk(index int, toolCallDelta map[string]interface{}) map[string]interface{} {
+	toolCallDelta["index"] = index
 	return map[string]interface{}{
 		"id":      "chatcmpl-" + fmt.Sprintf("%d", time.Now().UnixNano()),
 		"object":  "chat.completion.chunk",
@@ -701,7 +875,7 @@ func (a *ClaudeToOpenAIAdapter) adaptToolUseDelta(delta map[string]interface{},
 		"model":   "claude",
 		"choices": []interface{}{
 			map[string]interface{}{
-				"index":         int(index),
+				"index":         0,
 				"delta":         map[string]interface{}{"tool_calls": []interface{}{toolCallDelta}},
 				"finish_reason": nil,
 			},
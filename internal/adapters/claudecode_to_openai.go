@@ -63,6 +63,9 @@ func (a *ClaudeCodeToOpenAIAdapter) AdaptRequest(reqData map[string]interface{},
 	if toolChoice := reqData["tool_choice"]; toolChoice != nil {
 		openaiReq["tool_choice"] = a.convertToolChoice(toolChoice)
 	}
+	if claudeDisablesParallelToolUse(reqData) && openaiReq["tools"] != nil {
+		openaiReq["parallel_tool_calls"] = false
+	}
 
 	// 5. 转换其他参数
 	if maxTokens, ok := reqData["max_tokens"]; ok {
@@ -91,6 +94,11 @@ func (a *ClaudeCodeToOpenAIAdapter) AdaptRequest(reqData map[string]interface{},
 		openaiReq["stop"] = stopSequences
 	}
 
+	// 6. thinking.budget_tokens → reasoning_effort
+	if effort := BudgetToReasoningEffort(claudeThinkingBudget(reqData)); effort != "" {
+		openaiReq["reasoning_effort"] = effort
+	}
+
 	return openaiReq, nil
 }
 
@@ -151,18 +159,18 @@ func (a *ClaudeCodeToOpenAIAdapter) convertMessage(msgMap map[string]interface{}
 				// 处理包含 tool_result 的用户消息
 				result = append(result, a.convertToolResultMessage(c)...)
 			} else {
-				// 普通用户消息，提取文本
-				textContent := a.extractTextFromBlocks(c)
-				if textContent != "" {
+				// 普通用户消息，提取文本和图片
+				if parts := a.userContentParts(c); len(parts) > 0 {
 					result = append(result, map[string]interface{}{
 						"role":    role,
-						"content": textContent,
+						"content": openAIUserContent(parts),
 					})
 				}
 			}
 		} else if role == "assistant" {
-			// 助手消息 - 可能包含 text 和 tool_use
+			// 助手消息 - 可能包含 thinking、text 和 tool_use
 			textContent := ""
+			reasoningContent := ""
 			var toolCalls []interface{}
 
 			for _, block := range c {
@@ -174,6 +182,10 @@ func (a *ClaudeCodeToOpenAIAdapter) convertMessage(msgMap map[string]interface{}
 						if text, ok := blockMap["text"].(string); ok {
 							textContent += text
 						}
+					case "thinking":
+						if thinking, ok := blockMap["thinking"].(string); ok {
+							reasoningContent += thinking
+						}
 					case "tool_use":
 						// 转换 tool_use 为 OpenAI 的 tool_calls
 						toolCall := a.convertToolUse(blockMap)
@@ -190,6 +202,9 @@ func (a *ClaudeCodeToOpenAIAdapter) convertMessage(msgMap map[string]interface{}
 			if textContent != "" {
 				assistantMsg["content"] = textContent
 			}
+			if reasoningContent != "" {
+				assistantMsg["reasoning_content"] = reasoningContent
+			}
 			if len(toolCalls) > 0 {
 				assistantMsg["tool_calls"] = toolCalls
 			}
@@ -207,19 +222,23 @@ func (a *ClaudeCodeToOpenAIAdapter) convertMessage(msgMap map[string]interface{}
 	return result
 }
 
-// extractTextFromBlocks 从内容块数组中提取文本
-func (a *ClaudeCodeToOpenAIAdapter) extractTextFromBlocks(blocks []interface{}) string {
-	var textParts []string
+// userContentParts 将用户消息的内容块按顺序转换为 OpenAI 的文本和图片内容块
+func (a *ClaudeCodeToOpenAIAdapter) userContentParts(blocks []interface{}) []interface{} {
+	var parts []interface{}
 	for _, block := range blocks {
-		if blockMap, ok := block.(map[string]interface{}); ok {
-			if blockType, _ := blockMap["type"].(string); blockType == "text" {
-				if text, ok := blockMap["text"].(string); ok {
-					textParts = append(textParts, text)
-				}
+		blockMap, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if image := openAIImagePart(blockMap); image != nil {
+			parts = append(parts, image)
+		} else if blockType, _ := blockMap["type"].(string); blockType == "text" {
+			if text, ok := blockMap["text"].(string); ok && text != "" {
+				parts = append(parts, map[string]interface{}{"type": "text", "text": text})
 			}
 		}
 	}
-	return strings.Join(textParts, "\n")
+	return parts
 }
 
 // convertToolUse 转换 tool_use 为 OpenAI 的 tool_call
@@ -247,8 +266,10 @@ func (a *ClaudeCodeToOpenAIAdapter) convertToolUse(toolUse map[string]interface{
 }
 
 // convertToolResultMessage 转换包含 tool_result 的消息
+// OpenAI 的 tool 消息不支持图片，tool_result 中的截图和消息中的图片放到最后的用户消息中
 func (a *ClaudeCodeToOpenAIAdapter) convertToolResultMessage(blocks []interface{}) []interface{} {
 	result := make([]interface{}, 0)
+	var images []interface{}
 
 	for _, block := range blocks {
 		if blockMap, ok := block.(map[string]interface{}); ok {
@@ -265,6 +286,7 @@ func (a *ClaudeCodeToOpenAIAdapter) convertToolResultMessage(blocks []interface{
 					"tool_call_id": toolUseID,
 					"content":      content,
 				})
+				images = append(images, toolResultImages(blockMap["content"])...)
 
 			case "text":
 				// 普通文本，作为用户消息
@@ -274,10 +296,22 @@ func (a *ClaudeCodeToOpenAIAdapter) convertToolResultMessage(blocks []interface{
 						"content": text,
 					})
 				}
+
+			case "image":
+				if image := openAIImagePart(blockMap); image != nil {
+					images = append(images, image)
+				}
 			}
 		}
 	}
 
+	if len(images) > 0 {
+		result = append(result, map[string]interface{}{
+			"role":    "user",
+			"content": images,
+		})
+	}
+
 	return result
 }
 
@@ -298,6 +332,9 @@ func extractToolResultContent(content interface{}) string {
 					if text, ok := itemMap["text"].(string); ok {
 						parts = append(parts, text)
 					}
+				} else if isImageBlock(itemMap) {
+					// 图片单独放到之后的用户消息中
+					parts = append(parts, "[image]")
 				} else {
 					// 其他类型，序列化为 JSON
 					if jsonBytes, err := json.Marshal(itemMap); err == nil {
@@ -397,6 +434,15 @@ func (a *ClaudeCodeToOpenAIAdapter) AdaptResponse(respData map[string]interface{
 	if choices, ok := respData["choices"].([]interface{}); ok && len(choices) > 0 {
 		if choice, ok := choices[0].(map[string]interface{}); ok {
 			if message, ok := choice["message"].(map[string]interface{}); ok {
+				// 推理内容 → thinking 块（必须在最前面）
+				if reasoning, ok := message["reasoning_content"].(string); ok && reasoning != "" {
+					content = append(content, map[string]interface{}{
+						"type":      "thinking",
+						"thinking":  reasoning,
+						"signature": "",
+					})
+				}
+
 				// 文本内容
 				if msgContent, ok := message["content"].(string); ok && msgContent != "" {
 					content = append(content, map[string]interface{}{
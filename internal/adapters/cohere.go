@@ -0,0 +1,323 @@
+package adapters
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OpenAIToCohereAdapter 将 OpenAI 格式转换为 Cohere Chat API v2 (/v2/chat) 格式
+type OpenAIToCohereAdapter struct{}
+
+// CohereToOpenAIAdapter 将 Cohere v2 流式事件转换为 OpenAI 格式
+type CohereToOpenAIAdapter struct{}
+
+func init() {
+	RegisterAdapter("openai-to-cohere", &OpenAIToCohereAdapter{})
+	RegisterAdapter("cohere-to-openai", &CohereToOpenAIAdapter{})
+}
+
+// cohereParamKeys OpenAI 参数 -> Cohere 参数（其余 OpenAI 专有参数 Cohere 会报错，不透传）
+var cohereParamKeys = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "p",
+	"max_tokens":        "max_tokens",
+	"seed":              "seed",
+	"frequency_penalty": "frequency_penalty",
+	"presence_penalty":  "presence_penalty",
+}
+
+// AdaptRequest 将 OpenAI 请求转换为 Cohere 请求
+func (a *OpenAIToCohereAdapter) AdaptRequest(reqData map[string]interface{}, model string) (map[string]interface{}, error) {
+	cohereReq := map[string]interface{}{
+		"model": model,
+	}
+	if stream, ok := reqData["stream"].(bool); ok && stream {
+		cohereReq["stream"] = true
+	}
+
+	messages := make([]interface{}, 0)
+	if msgs, ok := reqData["messages"].([]interface{}); ok {
+		for _, msg := range msgs {
+			msgMap, ok := msg.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			role, _ := msgMap["role"].(string)
+			if role == "developer" {
+				role = "system"
+			}
+			cohereMsg := map[string]interface{}{"role": role}
+
+			// Cohere v2 的消息结构与 OpenAI 基本一致，content 只支持文本（字符串或 text 块数组）
+			if text := cohereTextContent(msgMap["content"]); text != "" || role != "assistant" {
+				cohereMsg["content"] = text
+			}
+			if toolCalls, ok := msgMap["tool_calls"].([]interface{}); ok && len(toolCalls) > 0 {
+				cohereMsg["tool_calls"] = toolCalls
+			}
+			if role == "tool" {
+				cohereMsg["tool_call_id"] = msgMap["tool_call_id"]
+			}
+			messages = append(messages, cohereMsg)
+		}
+	}
+	cohereReq["messages"] = messages
+
+	// 工具定义格式相同
+	if tools, ok := reqData["tools"].([]interface{}); ok && len(tools) > 0 {
+		cohereReq["tools"] = tools
+	}
+	// tool_choice 只支持 REQUIRED / NONE
+	switch reqData["tool_choice"] {
+	case "required":
+		cohereReq["tool_choice"] = "REQUIRED"
+	case "none":
+		cohereReq["tool_choice"] = "NONE"
+	}
+
+	for openaiKey, cohereKey := range cohereParamKeys {
+		if v, ok := reqData[openaiKey]; ok && v != nil {
+			cohereReq[cohereKey] = v
+		}
+	}
+	if v, ok := reqData["max_completion_tokens"]; ok && v != nil {
+		cohereReq["max_tokens"] = v
+	}
+	switch stop := reqData["stop"].(type) {
+	case string:
+		cohereReq["stop_sequences"] = []interface{}{stop}
+	case []interface{}:
+		cohereReq["stop_sequences"] = stop
+	}
+
+	if rf, ok := reqData["response_format"].(map[string]interface{}); ok {
+		switch rf["type"] {
+		case "json_object":
+			cohereReq["response_format"] = map[string]interface{}{"type": "json_object"}
+		case "json_schema":
+			format := map[string]interface{}{"type": "json_object"}
+			if js, ok := rf["json_schema"].(map[string]interface{}); ok && js["schema"] != nil {
+				format["json_schema"] = js["schema"]
+			}
+			cohereReq["response_format"] = format
+		}
+	}
+
+	return cohereReq, nil
+}
+
+// cohereTextContent 提取文本内容（Cohere 不支持图片等多模态块）
+func cohereTextContent(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var texts []string
+		for _, part := range c {
+			if partMap, ok := part.(map[string]interface{}); ok && partMap["type"] == "text" {
+				if text, ok := partMap["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// AdaptResponse 将 Cohere 响应转换为 OpenAI 响应
+func (a *OpenAIToCohereAdapter) AdaptResponse(respData map[string]interface{}) (map[string]interface{}, error) {
+	// Cohere 错误响应: {"message": "..."}（没有 id 字段）
+	if _, ok := respData["id"]; !ok {
+		if errMsg, ok := respData["message"].(string); ok {
+			return map[string]interface{}{
+				"error": map[string]interface{}{"message": errMsg, "type": "cohere_error"},
+			}, nil
+		}
+	}
+
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": "",
+	}
+	if msg, ok := respData["message"].(map[string]interface{}); ok {
+		var texts []string
+		if content, ok := msg["content"].([]interface{}); ok {
+			for _, block := range content {
+				if blockMap, ok := block.(map[string]interface{}); ok {
+					if text, ok := blockMap["text"].(string); ok {
+						texts = append(texts, text)
+					}
+				}
+			}
+		}
+		message["content"] = strings.Join(texts, "")
+		if toolCalls, ok := msg["tool_calls"].([]interface{}); ok && len(toolCalls) > 0 {
+			message["tool_calls"] = toolCalls
+		}
+	}
+
+	id, _ := respData["id"].(string)
+	if id == "" {
+		id = fmt.Sprintf("chatcmpl-cohere-%d", time.Now().UnixNano())
+	}
+	return map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   "cohere",
+		"choices": []interface{}{
+			map[string]interface{}{
+				"index":         0,
+				"message":       message,
+				"finish_reason": cohereFinishReason(respData["finish_reason"]),
+			},
+		},
+		"usage": CohereUsage(respData["usage"]),
+	}, nil
+}
+
+// AdaptStreamChunk OpenAIToCohereAdapter 不处理流式响应（由 cohere-to-openai 处理）
+func (a *OpenAIToCohereAdapter) AdaptStreamChunk(chunk map[string]interface{}) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+// AdaptStreamStart 流式响应开始
+func (a *OpenAIToCohereAdapter) AdaptStreamStart(model string) []map[string]interface{} {
+	return nil
+}
+
+// AdaptStreamEnd 流式响应结束
+func (a *OpenAIToCohereAdapter) AdaptStreamEnd() []map[string]interface{} {
+	return nil
+}
+
+// AdaptRequest 不支持将 Cohere 请求作为输入
+func (a *CohereToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, model string) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("cohere request format is not supported as input")
+}
+
+// AdaptResponse 响应保持 OpenAI 格式
+func (a *CohereToOpenAIAdapter) AdaptResponse(respData map[string]interface{}) (map[string]interface{}, error) {
+	return respData, nil
+}
+
+// AdaptStreamChunk 转换流式响应块 - Cohere v2 SSE → OpenAI SSE
+// 事件类型: message-start, content-delta, tool-plan-delta, tool-call-start, tool-call-delta, message-end 等
+func (a *CohereToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (map[string]interface{}, error) {
+	eventType, _ := chunk["type"].(string)
+	eventDelta, _ := chunk["delta"].(map[string]interface{})
+	deltaMsg, _ := eventDelta["message"].(map[string]interface{})
+	index := 0
+	if v, ok := chunk["index"].(float64); ok {
+		index = int(v)
+	}
+
+	delta := map[string]interface{}{}
+	var finishReason interface{}
+	var usage interface{}
+
+	switch eventType {
+	case "message-start":
+		delta["role"] = "assistant"
+	case "content-delta":
+		content, _ := deltaMsg["content"].(map[string]interface{})
+		text, _ := content["text"].(string)
+		if text == "" {
+			return nil, nil
+		}
+		delta["content"] = text
+	case "tool-call-start":
+		toolCall, _ := deltaMsg["tool_calls"].(map[string]interface{})
+		function, _ := toolCall["function"].(map[string]interface{})
+		delta["tool_calls"] = []interface{}{
+			map[string]interface{}{
+				"index": index,
+				"id":    toolCall["id"],
+				"type":  "function",
+				"function": map[string]interface{}{
+					"name":      function["name"],
+					"arguments": "",
+				},
+			},
+		}
+	case "tool-call-delta":
+		toolCall, _ := deltaMsg["tool_calls"].(map[string]interface{})
+		function, _ := toolCall["function"].(map[string]interface{})
+		arguments, _ := function["arguments"].(string)
+		if arguments == "" {
+			return nil, nil
+		}
+		delta["tool_calls"] = []interface{}{
+			map[string]interface{}{
+				"index":    index,
+				"function": map[string]interface{}{"arguments": arguments},
+			},
+		}
+	case "message-end":
+		finishReason = cohereFinishReason(eventDelta["finish_reason"])
+		usage = CohereUsage(eventDelta["usage"])
+	default:
+		// tool-plan-delta、content-start/end、tool-call-end、citation 等事件没有对应的 OpenAI 内容
+		return nil, nil
+	}
+
+	openaiChunk := map[string]interface{}{
+		"id":      fmt.Sprintf("chatcmpl-cohere-%d", time.Now().UnixNano()),
+		"object":  "chat.completion.chunk",
+		"created": time.Now().Unix(),
+		"model":   "cohere",
+		"choices": []interface{}{
+			map[string]interface{}{
+				"index":         0,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			},
+		},
+	}
+	if usage != nil {
+		openaiChunk["usage"] = usage
+	}
+	return openaiChunk, nil
+}
+
+// AdaptStreamStart 流式响应开始
+func (a *CohereToOpenAIAdapter) AdaptStreamStart(model string) []map[string]interface{} {
+	return nil
+}
+
+// AdaptStreamEnd 流式响应结束
+func (a *CohereToOpenAIAdapter) AdaptStreamEnd() []map[string]interface{} {
+	return nil
+}
+
+// cohereFinishReason 转换 Cohere finish_reason
+func cohereFinishReason(reason interface{}) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return "length"
+	case "TOOL_CALL":
+		return "tool_calls"
+	case "ERROR":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// CohereUsage 将 Cohere usage（tokens 或 billed_units）转换为 OpenAI usage
+func CohereUsage(raw interface{}) map[string]interface{} {
+	usage, _ := raw.(map[string]interface{})
+	counts, ok := usage["tokens"].(map[string]interface{})
+	if !ok {
+		counts, _ = usage["billed_units"].(map[string]interface{})
+	}
+	promptTokens, _ := counts["input_tokens"].(float64)
+	completionTokens, _ := counts["output_tokens"].(float64)
+	return map[string]interface{}{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": completionTokens,
+		"total_tokens":      promptTokens + completionTokens,
+	}
+}
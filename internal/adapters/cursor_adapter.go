@@ -257,9 +257,10 @@ func (a *CursorAdapter) convertMessages(messages []interface{}) []interface{} {
 }
 
 // convertUserMessage 转换包含 tool_result 的用户消息
+// 图片（包括 tool_result 中的截图）按顺序放到 tool 消息之后的用户消息中
 func (a *CursorAdapter) convertUserMessage(contentArr []interface{}) []interface{} {
 	result := make([]interface{}, 0)
-	var textParts []string
+	var parts []interface{}
 
 	for _, block := range contentArr {
 		blockMap, ok := block.(map[string]interface{})
@@ -280,26 +281,27 @@ func (a *CursorAdapter) convertUserMessage(contentArr []interface{}) []interface
 				"tool_call_id": toolUseID,
 				"content":      content,
 			})
+			parts = append(parts, toolResultImages(blockMap["content"])...)
 			log.Debugf("[Cursor] Converted tool_result: %s", toolUseID)
 
-		case "text":
-			if text, ok := blockMap["text"].(string); ok && text != "" {
-				textParts = append(textParts, text)
+		case "image", "image_url":
+			if image := openAIImagePart(blockMap); image != nil {
+				parts = append(parts, image)
 			}
 
 		default:
-			// 其他类型尝试提取文本
+			// text 和其他类型尝试提取文本
 			if text, ok := blockMap["text"].(string); ok && text != "" {
-				textParts = append(textParts, text)
+				parts = append(parts, map[string]interface{}{"type": "text", "text": text})
 			}
 		}
 	}
 
-	// 如果有文本内容，添加为用户消息
-	if len(textParts) > 0 {
+	// 如果有文本或图片，添加为用户消息
+	if len(parts) > 0 {
 		result = append(result, map[string]interface{}{
 			"role":    "user",
-			"content": strings.Join(textParts, "\n"),
+			"content": openAIUserContent(parts),
 		})
 	}
 
@@ -525,6 +527,9 @@ func extractToolResultContentCursor(content interface{}) string {
 					if text, ok := itemMap["text"].(string); ok {
 						parts = append(parts, text)
 					}
+				} else if isImageBlock(itemMap) {
+					// 图片单独放到之后的用户消息中
+					parts = append(parts, "[image]")
 				} else {
 					// 其他类型序列化为 JSON
 					if jsonBytes, err := json.Marshal(itemMap); err == nil {
@@ -1,28 +1,179 @@
 package adapters
 
+import (
+	"strings"
+)
+
+// DeepSeekAdapter DeepSeek 的 OpenAI 兼容接口：请求去掉不支持的参数并映射推理设置，
+// 响应中的推理内容统一为 reasoning_content
 type DeepSeekAdapter struct{}
 
-func (a *DeepSeekAdapter) AdaptRequest(request map[string]interface{}, targetModel string) (map[string]interface{}, error) {
-	// DeepSeek 使用 OpenAI 兼容格式，基本不需要转换
-	adapted := make(map[string]interface{})
+func init() {
+	RegisterAdapter("openai-to-deepseek", &DeepSeekAdapter{})
+	RegisterAdapter("deepseek-to-openai", &DeepSeekAdapter{})
+}
 
+// deepSeekUnsupportedParams DeepSeek 不支持的 OpenAI 参数（部分会返回 400）
+var deepSeekUnsupportedParams = []string{
+	"logit_bias", "n", "service_tier", "store", "metadata", "modalities", "audio",
+	"parallel_tool_calls", "prediction", "web_search_options",
+}
+
+// deepSeekSamplingParams 推理模式下不生效的采样参数
+var deepSeekSamplingParams = []string{
+	"temperature", "top_p", "presence_penalty", "frequency_penalty",
+}
+
+// isDeepSeekReasoningModel deepseek-reasoner、DeepSeek-R1 及其蒸馏模型
+func isDeepSeekReasoningModel(model string) bool {
+	lower := strings.ToLower(model)
+	if i := strings.LastIndex(lower, "/"); i >= 0 {
+		lower = lower[i+1:]
+	}
+	return strings.Contains(lower, "reasoner") || strings.HasPrefix(lower, "r1") ||
+		strings.Contains(lower, "-r1") || strings.Contains(lower, "_r1")
+}
+
+// AdaptRequest 将 OpenAI 请求转换为 DeepSeek 可接受的请求
+func (a *DeepSeekAdapter) AdaptRequest(request map[string]interface{}, targetModel string) (map[string]interface{}, error) {
+	adapted := make(map[string]interface{}, len(request))
 	for k, v := range request {
 		adapted[k] = v
 	}
-
 	if targetModel != "" {
 		adapted["model"] = targetModel
 	}
-
+	if stream, ok := adapted["stream"].(bool); ok && stream {
+		adapted["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	FixDeepSeekRequest(adapted, targetModel)
 	return adapted, nil
 }
 
+// FixDeepSeekRequest 修正发往 DeepSeek 的 OpenAI 格式请求（其他格式转换后的请求也适用）：
+// 去掉不支持的参数，max_completion_tokens -> max_tokens，reasoning_effort/reasoning -> thinking，
+// 去掉历史轮次 assistant 消息中的 reasoning_content（DeepSeek 不接受）
+func FixDeepSeekRequest(reqData map[string]interface{}, model string) {
+	if m, ok := reqData["model"].(string); ok && m != "" {
+		model = m
+	}
+	for _, key := range deepSeekUnsupportedParams {
+		delete(reqData, key)
+	}
+	if v, ok := reqData["max_completion_tokens"]; ok {
+		if _, exists := reqData["max_tokens"]; !exists {
+			reqData["max_tokens"] = v
+		}
+		delete(reqData, "max_completion_tokens")
+	}
+
+	reasoning := isDeepSeekReasoningModel(model)
+	if !reasoning {
+		// deepseek-chat 等混合推理模型通过 thinking 开关推理模式，调用方已指定时保留
+		if _, exists := reqData["thinking"]; !exists {
+			if enabled, ok := deepSeekThinking(reqData); ok {
+				thinkingType := "disabled"
+				if enabled {
+					thinkingType = "enabled"
+				}
+				reqData["thinking"] = map[string]interface{}{"type": thinkingType}
+			}
+		}
+		if thinking, ok := reqData["thinking"].(map[string]interface{}); ok && thinking["type"] == "enabled" {
+			reasoning = true
+		}
+	}
+	delete(reqData, "reasoning_effort")
+	delete(reqData, "reasoning")
+
+	if reasoning {
+		for _, key := range deepSeekSamplingParams {
+			delete(reqData, key)
+		}
+		// logprobs 在推理模式下会返回 400
+		delete(reqData, "logprobs")
+		delete(reqData, "top_logprobs")
+	}
+
+	stripDeepSeekHistoryReasoning(reqData)
+}
+
+// deepSeekThinking 从 reasoning_effort 或 reasoning（OpenRouter 风格）推断是否开启推理，未指定时 ok 为 false
+func deepSeekThinking(reqData map[string]interface{}) (enabled bool, ok bool) {
+	effort, _ := reqData["reasoning_effort"].(string)
+	if r, isMap := reqData["reasoning"].(map[string]interface{}); isMap {
+		if e, _ := r["effort"].(string); e != "" {
+			effort = e
+		} else if enabled, isBool := r["enabled"].(bool); isBool {
+			return enabled, true
+		} else if tokens, isNum := r["max_tokens"].(float64); isNum {
+			return tokens > 0, true
+		}
+	}
+	switch strings.ToLower(effort) {
+	case "":
+		return false, false
+	case "none", "minimal":
+		return false, true
+	}
+	return true, true
+}
+
+// stripDeepSeekHistoryReasoning 去掉最后一条 user 消息之前 assistant 消息的 reasoning_content；
+// 之后的消息属于当前轮次的工具调用，推理内容需要原样传回
+func stripDeepSeekHistoryReasoning(reqData map[string]interface{}) {
+	messages, _ := reqData["messages"].([]interface{})
+	lastUser := -1
+	for i, msg := range messages {
+		if msgMap, ok := msg.(map[string]interface{}); ok && msgMap["role"] == "user" {
+			lastUser = i
+		}
+	}
+	for i := 0; i < lastUser; i++ {
+		if msgMap, ok := messages[i].(map[string]interface{}); ok && msgMap["role"] == "assistant" {
+			delete(msgMap, "reasoning_content")
+			delete(msgMap, "reasoning")
+		}
+	}
+}
+
+// AdaptResponse 推理内容统一放入 message.reasoning_content：
+// 兼容使用 reasoning 字段的部署，以及把推理过程以 <think> 标签写在 content 开头的 R1 部署
 func (a *DeepSeekAdapter) AdaptResponse(response map[string]interface{}) (map[string]interface{}, error) {
-	// DeepSeek 响应格式与 OpenAI 兼容，直接返回
+	choices, _ := response["choices"].([]interface{})
+	for _, choice := range choices {
+		choiceMap, ok := choice.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		message, ok := choiceMap["message"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		normalizeDeepSeekReasoning(message)
+		if current, _ := message["reasoning_content"].(string); current != "" {
+			continue
+		}
+		if content, ok := message["content"].(string); ok {
+			if reasoning, rest, found := splitThinkTag(content); found {
+				message["reasoning_content"] = reasoning
+				message["content"] = rest
+			}
+		}
+	}
 	return response, nil
 }
 
+// AdaptStreamChunk delta.reasoning 统一为 delta.reasoning_content，其余内容原样返回
 func (a *DeepSeekAdapter) AdaptStreamChunk(chunk map[string]interface{}) (map[string]interface{}, error) {
+	choices, _ := chunk["choices"].([]interface{})
+	for _, choice := range choices {
+		if choiceMap, ok := choice.(map[string]interface{}); ok {
+			if delta, ok := choiceMap["delta"].(map[string]interface{}); ok {
+				normalizeDeepSeekReasoning(delta)
+			}
+		}
+	}
 	return chunk, nil
 }
 
@@ -35,3 +186,30 @@ func (a *DeepSeekAdapter) AdaptStreamEnd() []map[string]interface{} {
 	// DeepSeek 适配器不需要转换结束事件
 	return nil
 }
+
+// normalizeDeepSeekReasoning 将 reasoning 字段（vLLM、SGLang 等部署）改名为 reasoning_content
+func normalizeDeepSeekReasoning(message map[string]interface{}) {
+	reasoning, ok := message["reasoning"].(string)
+	if !ok {
+		return
+	}
+	if current, _ := message["reasoning_content"].(string); current == "" && reasoning != "" {
+		message["reasoning_content"] = reasoning
+	}
+	delete(message, "reasoning")
+}
+
+// splitThinkTag 拆分 content 开头的 <think>...</think>，没有完整标签时 found 为 false
+func splitThinkTag(content string) (reasoning, rest string, found bool) {
+	trimmed := strings.TrimLeft(content, " \t\r\n")
+	if !strings.HasPrefix(trimmed, "<think>") {
+		return "", content, false
+	}
+	end := strings.Index(trimmed, "</think>")
+	if end < 0 {
+		return "", content, false
+	}
+	reasoning = strings.TrimSpace(trimmed[len("<think>"):end])
+	rest = strings.TrimLeft(trimmed[end+len("</think>"):], " \t\r\n")
+	return reasoning, rest, true
+}
@@ -14,9 +14,12 @@ func (a *GeminiAdapter) AdaptRequest(request map[string]interface{}, targetModel
 		adapted["model"] = getOrDefault(request, "model", "gemini-pro")
 	}
 
-	// Gemini 使用 contents 而不是 messages
+	// Gemini 使用 contents 而不是 messages，system 消息转换为 systemInstruction
 	if messages, ok := request["messages"].([]interface{}); ok {
 		adapted["contents"] = a.convertMessages(messages)
+		if systemInstruction := geminiSystemInstruction(messages); systemInstruction != nil {
+			adapted["systemInstruction"] = systemInstruction
+		}
 	} else {
 		// 如果没有 messages，但其他适配器需要这个字段，提供一个默认值
 		adapted["contents"] = []map[string]interface{}{
@@ -36,12 +39,22 @@ func (a *GeminiAdapter) AdaptRequest(request map[string]interface{}, targetModel
 	if maxTokens, ok := request["max_tokens"]; ok {
 		generationConfig["maxOutputTokens"] = maxTokens
 	}
+	setStopSequences(generationConfig, "stopSequences", request["stop"], geminiMaxStopSequences)
+	copyParam(generationConfig, "frequencyPenalty", request, "frequency_penalty")
+	copyParam(generationConfig, "presencePenalty", request, "presence_penalty")
+	copyParam(generationConfig, "seed", request, "seed")
+	copyParam(generationConfig, "topK", request, "top_k")
+	setGeminiOutputConfig(generationConfig, request)
 
 	// 只有当有配置时才添加 generationConfig
 	if len(generationConfig) > 0 {
 		adapted["generationConfig"] = generationConfig
 	}
 
+	if safetySettings := geminiSafetySettings(request); len(safetySettings) > 0 {
+		adapted["safetySettings"] = safetySettings
+	}
+
 	// Gemini 的流式参数在 URL 中处理，这里不需要设置 stream
 	// 因为调用时会使用 buildAdapterStreamURL 构建正确的 URL
 
@@ -49,6 +62,11 @@ func (a *GeminiAdapter) AdaptRequest(request map[string]interface{}, targetModel
 }
 
 func (a *GeminiAdapter) AdaptResponse(response map[string]interface{}) (map[string]interface{}, error) {
+	// 被安全过滤拦截且没有输出内容
+	if blocked := GeminiBlocked(response); blocked != nil {
+		return nil, blocked
+	}
+
 	// 将 Gemini 响应转换为 OpenAI 格式
 	adapted := map[string]interface{}{
 		"id":      "chatcmpl-gemini",
@@ -217,6 +235,9 @@ func (a *GeminiAdapter) convertMessages(messages []interface{}) []map[string]int
 		if msgMap, ok := msg.(map[string]interface{}); ok {
 			role := msgMap["role"].(string)
 			content := msgMap["content"]
+			if role == "system" || role == "developer" {
+				continue
+			}
 
 			// Gemini 使用 "user" 和 "model" 作为角色
 			geminiRole := "user"
@@ -243,20 +264,7 @@ func (a *GeminiAdapter) convertFinishReason(finishReason string) string {
 	}
 
 	// 将 Gemini 的停止原因转换为 OpenAI 格式
-	switch finishReason {
-	case "STOP":
-		return "stop"
-	case "MAX_TOKENS":
-		return "length"
-	case "SAFETY":
-		return "content_filter"
-	case "RECITATION":
-		return "content_filter"
-	case "OTHER":
-		return "stop"
-	default:
-		return "stop"
-	}
+	return geminiFinishReason(finishReason)
 }
 
 func (a *GeminiAdapter) AdaptStreamStart(model string) []map[string]interface{} {
@@ -0,0 +1,250 @@
+package adapters
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Gemini 安全设置和拦截：OpenAI 请求中的 safety_settings（Gemini 格式的扩展参数）转发给 Gemini；
+// Gemini 因安全过滤拦截提示词（promptFeedback.blockReason）或回复（finishReason 为 SAFETY 等）且没有输出内容时，
+// 转换为 OpenAI 格式的 content_filter 错误，而不是返回空回复
+
+// geminiFilteredFinishReasons 表示回复被过滤的 finishReason
+var geminiFilteredFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// geminiFinishReason 将 Gemini 的 finishReason 转换为 OpenAI 的 finish_reason
+func geminiFinishReason(finishReason string) string {
+	switch {
+	case finishReason == "MAX_TOKENS":
+		return "length"
+	case geminiFilteredFinishReasons[finishReason]:
+		return "content_filter"
+	}
+	return "stop"
+}
+
+// GeminiBlockedError Gemini 拦截了提示词或回复
+type GeminiBlockedError struct {
+	Reason       string   // blockReason 或 finishReason
+	Prompt       bool     // 拦截的是提示词
+	Categories   []string // 触发拦截的安全类别
+	Message      string   // blockReasonMessage
+	PromptTokens int
+}
+
+func (e *GeminiBlockedError) Error() string {
+	target := "response"
+	if e.Prompt {
+		target = "prompt"
+	}
+	msg := fmt.Sprintf("%s blocked by Gemini safety filters (%s", target, e.Reason)
+	if len(e.Categories) > 0 {
+		msg += ": " + strings.Join(e.Categories, ", ")
+	}
+	msg += ")"
+	if e.Message != "" {
+		msg += " - " + e.Message
+	}
+	return msg
+}
+
+// GeminiBlocked Gemini 响应（或流式响应块）被安全过滤拦截且没有输出内容时返回拦截原因，否则返回 nil
+func GeminiBlocked(resp map[string]interface{}) *GeminiBlockedError {
+	var blocked *GeminiBlockedError
+	if feedback, ok := resp["promptFeedback"].(map[string]interface{}); ok {
+		if reason, _ := feedback["blockReason"].(string); reason != "" {
+			blocked = &GeminiBlockedError{Reason: reason, Prompt: true, Categories: geminiBlockedCategories(feedback)}
+			blocked.Message, _ = feedback["blockReasonMessage"].(string)
+		}
+	}
+	if blocked == nil {
+		candidates, _ := resp["candidates"].([]interface{})
+		if len(candidates) == 0 {
+			return nil
+		}
+		for _, c := range candidates {
+			candidate, _ := c.(map[string]interface{})
+			reason, _ := candidate["finishReason"].(string)
+			if !geminiFilteredFinishReasons[reason] || geminiHasOutput(candidate) {
+				return nil
+			}
+			if blocked == nil {
+				blocked = &GeminiBlockedError{Reason: reason, Categories: geminiBlockedCategories(candidate)}
+				blocked.Message, _ = candidate["finishMessage"].(string)
+			}
+		}
+	}
+	if usage, ok := resp["usageMetadata"].(map[string]interface{}); ok {
+		if v, ok := usage["promptTokenCount"].(float64); ok {
+			blocked.PromptTokens = int(v)
+		}
+	}
+	return blocked
+}
+
+// geminiBlockedCategories safetyRatings 中 blocked 为 true 的安全类别
+func geminiBlockedCategories(m map[string]interface{}) []string {
+	var categories []string
+	ratings, _ := m["safetyRatings"].([]interface{})
+	for _, r := range ratings {
+		rating, _ := r.(map[string]interface{})
+		if blocked, _ := rating["blocked"].(bool); blocked {
+			if category, _ := rating["category"].(string); category != "" {
+				categories = append(categories, category)
+			}
+		}
+	}
+	return categories
+}
+
+// geminiHasOutput candidate 包含文本、函数调用或其他内容
+func geminiHasOutput(candidate map[string]interface{}) bool {
+	content, _ := candidate["content"].(map[string]interface{})
+	parts, _ := content["parts"].([]interface{})
+	for _, p := range parts {
+		part, _ := p.(map[string]interface{})
+		if text, ok := part["text"].(string); ok && text == "" {
+			continue
+		}
+		if len(part) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// geminiSafetySettings 转换请求中的 safety_settings / safetySettings
+// 支持 Gemini 格式的数组 [{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_NONE"}]
+// 和简写 {"harassment": "BLOCK_NONE"}，类别可省略 HARM_CATEGORY_ 前缀
+func geminiSafetySettings(reqData map[string]interface{}) []interface{} {
+	raw, ok := reqData["safety_settings"]
+	if !ok {
+		raw = reqData["safetySettings"]
+	}
+	var settings []interface{}
+	switch v := raw.(type) {
+	case []interface{}:
+		for _, item := range v {
+			setting, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			category, _ := setting["category"].(string)
+			threshold, _ := setting["threshold"].(string)
+			if category == "" || threshold == "" {
+				continue
+			}
+			converted := map[string]interface{}{
+				"category":  geminiHarmCategory(category),
+				"threshold": strings.ToUpper(threshold),
+			}
+			if method, _ := setting["method"].(string); method != "" {
+				converted["method"] = strings.ToUpper(method)
+			}
+			settings = append(settings, converted)
+		}
+	case map[string]interface{}:
+		for category, t := range v {
+			if threshold, _ := t.(string); threshold != "" {
+				settings = append(settings, map[string]interface{}{
+					"category":  geminiHarmCategory(category),
+					"threshold": strings.ToUpper(threshold),
+				})
+			}
+		}
+	}
+	return settings
+}
+
+// geminiHarmCategory 补全安全类别名称：harassment → HARM_CATEGORY_HARASSMENT
+func geminiHarmCategory(category string) string {
+	category = strings.ToUpper(strings.TrimSpace(category))
+	if !strings.HasPrefix(category, "HARM_CATEGORY_") {
+		category = "HARM_CATEGORY_" + category
+	}
+	return category
+}
+
+// geminiSystemInstruction 将所有 system/developer 消息合并为 Gemini 的 systemInstruction，没有时返回 nil
+func geminiSystemInstruction(messages []interface{}) map[string]interface{} {
+	var parts []interface{}
+	for _, msg := range messages {
+		msgMap, _ := msg.(map[string]interface{})
+		if role, _ := msgMap["role"].(string); role != "system" && role != "developer" {
+			continue
+		}
+		switch content := msgMap["content"].(type) {
+		case string:
+			if content != "" {
+				parts = append(parts, map[string]interface{}{"text": content})
+			}
+		case []interface{}:
+			for _, item := range content {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					if text, _ := itemMap["text"].(string); text != "" {
+						parts = append(parts, map[string]interface{}{"text": text})
+					}
+				}
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"parts": parts}
+}
+
+// setGeminiOutputConfig 将 response_format、n、logprobs 转换为 generationConfig 字段
+func setGeminiOutputConfig(generationConfig, reqData map[string]interface{}) {
+	if format, ok := reqData["response_format"].(map[string]interface{}); ok {
+		switch format["type"] {
+		case "json_object":
+			generationConfig["responseMimeType"] = "application/json"
+		case "json_schema":
+			generationConfig["responseMimeType"] = "application/json"
+			if jsonSchema, ok := format["json_schema"].(map[string]interface{}); ok && jsonSchema["schema"] != nil {
+				generationConfig["responseSchema"] = cleanGeminiSchema(jsonSchema["schema"])
+			}
+		}
+	}
+	if n, ok := reqData["n"].(float64); ok && n > 1 {
+		generationConfig["candidateCount"] = n
+	}
+	if logprobs, _ := reqData["logprobs"].(bool); logprobs {
+		generationConfig["responseLogprobs"] = true
+		copyParam(generationConfig, "logprobs", reqData, "top_logprobs")
+	}
+}
+
+// setOpenAIOutputConfig 将 generationConfig 中的 responseMimeType/responseSchema、candidateCount、logprobs 转换为 OpenAI 参数
+func setOpenAIOutputConfig(openaiReq, generationConfig map[string]interface{}) {
+	if mimeType, _ := generationConfig["responseMimeType"].(string); mimeType == "application/json" {
+		schema := generationConfig["responseJsonSchema"]
+		if schema == nil {
+			schema = generationConfig["responseSchema"]
+		}
+		if schema != nil {
+			openaiReq["response_format"] = map[string]interface{}{
+				"type": "json_schema",
+				"json_schema": map[string]interface{}{
+					"name":   "response",
+					"schema": schema,
+				},
+			}
+		} else {
+			openaiReq["response_format"] = map[string]interface{}{"type": "json_object"}
+		}
+	}
+	copyParam(openaiReq, "n", generationConfig, "candidateCount")
+	if logprobs, _ := generationConfig["responseLogprobs"].(bool); logprobs {
+		openaiReq["logprobs"] = true
+		copyParam(openaiReq, "top_logprobs", generationConfig, "logprobs")
+	}
+}
@@ -42,6 +42,9 @@ func (a *GeminiToClaudeAdapter) AdaptRequest(reqData map[string]interface{}, mod
 
 	// 转换 contents 为 messages
 	claudeMessages := make([]interface{}, 0)
+	// Gemini 的 functionCall 通常没有 id，按函数名排队为 functionResponse 分配对应的 tool_use_id
+	pendingToolIDs := make(map[string][]string)
+	toolSeq := 0
 	if contents, ok := reqData["contents"].([]interface{}); ok {
 		for _, content := range contents {
 			if contentMap, ok := content.(map[string]interface{}); ok {
@@ -60,6 +63,11 @@ func (a *GeminiToClaudeAdapter) AdaptRequest(reqData map[string]interface{}, mod
 
 				for _, part := range parts {
 					if partMap, ok := part.(map[string]interface{}); ok {
+						// 思考内容没有 Claude 签名，不能作为 thinking 块回传，跳过
+						if thought, _ := partMap["thought"].(bool); thought {
+							continue
+						}
+
 						// 处理文本内容
 						if text, ok := partMap["text"].(string); ok {
 							textContent += text
@@ -84,9 +92,16 @@ func (a *GeminiToClaudeAdapter) AdaptRequest(reqData map[string]interface{}, mod
 							var input map[string]interface{}
 							json.Unmarshal(argsJSON, &input)
 
+							id, _ := functionCall["id"].(string)
+							if id == "" {
+								id = fmt.Sprintf("toolu_%s_%d", name, toolSeq)
+								toolSeq++
+							}
+							pendingToolIDs[name] = append(pendingToolIDs[name], id)
+
 							contentBlocks = append(contentBlocks, map[string]interface{}{
 								"type":  "tool_use",
-								"id":    fmt.Sprintf("toolu_%s", name),
+								"id":    id,
 								"name":  name,
 								"input": input,
 							})
@@ -107,9 +122,17 @@ func (a *GeminiToClaudeAdapter) AdaptRequest(reqData map[string]interface{}, mod
 								}
 							}
 
+							toolUseID, _ := functionResponse["id"].(string)
+							if queue := pendingToolIDs[name]; toolUseID == "" && len(queue) > 0 {
+								toolUseID = queue[0]
+								pendingToolIDs[name] = queue[1:]
+							} else if toolUseID == "" {
+								toolUseID = fmt.Sprintf("toolu_%s", name)
+							}
+
 							contentBlocks = append(contentBlocks, map[string]interface{}{
 								"type":        "tool_result",
-								"tool_use_id": fmt.Sprintf("toolu_%s", name),
+								"tool_use_id": toolUseID,
 								"content":     responseStr,
 							})
 						}
@@ -149,25 +172,25 @@ func (a *GeminiToClaudeAdapter) AdaptRequest(reqData map[string]interface{}, mod
 		claudeReq["system"] = systemContent
 	}
 
-	// 转换 generationConfig
+	// 转换 generationConfig；未设置 maxOutputTokens 时由代理按模型填充 max_tokens
 	if generationConfig, ok := reqData["generationConfig"].(map[string]interface{}); ok {
 		if maxOutputTokens, ok := generationConfig["maxOutputTokens"]; ok {
 			claudeReq["max_tokens"] = maxOutputTokens
 		}
 		if temperature, ok := generationConfig["temperature"]; ok {
-			claudeReq["temperature"] = temperature
+			claudeReq["temperature"] = claudeTemperature(temperature)
 		}
 		if topP, ok := generationConfig["topP"]; ok {
 			claudeReq["top_p"] = topP
 		}
-		if stopSequences, ok := generationConfig["stopSequences"]; ok {
-			claudeReq["stop_sequences"] = stopSequences
-		}
+		copyParam(claudeReq, "top_k", generationConfig, "topK")
+		setStopSequences(claudeReq, "stop_sequences", generationConfig["stopSequences"], 0)
+		// frequencyPenalty、presencePenalty、seed Claude 不支持，不转发
 	}
 
-	// 如果没有设置 max_tokens,使用默认值
-	if _, ok := claudeReq["max_tokens"]; !ok {
-		claudeReq["max_tokens"] = 4096
+	// thinkingConfig.thinkingBudget → thinking.budget_tokens
+	if generationConfig, ok := reqData["generationConfig"].(map[string]interface{}); ok {
+		setClaudeThinking(claudeReq, geminiThinkingBudget(generationConfig))
 	}
 
 	// 转换 tools
@@ -210,7 +233,7 @@ func (a *GeminiToClaudeAdapter) AdaptResponse(respData map[string]interface{}) (
 	geminiResp := make(map[string]interface{})
 
 	// 提取内容
-	var textContent string
+	var textContent, thinkingContent string
 	var functionCalls []interface{}
 	stopReason := "STOP"
 
@@ -224,6 +247,10 @@ func (a *GeminiToClaudeAdapter) AdaptResponse(respData map[string]interface{}) (
 					if text, ok := blockMap["text"].(string); ok {
 						textContent += text
 					}
+				case "thinking":
+					if thinking, ok := blockMap["thinking"].(string); ok {
+						thinkingContent += thinking
+					}
 				case "tool_use":
 					name, _ := blockMap["name"].(string)
 					input := blockMap["input"]
@@ -251,8 +278,14 @@ func (a *GeminiToClaudeAdapter) AdaptResponse(respData map[string]interface{}) (
 		}
 	}
 
-	// 构建 parts
+	// 构建 parts，思考内容作为 thought 部分放在最前面
 	parts := make([]interface{}, 0)
+	if thinkingContent != "" {
+		parts = append(parts, map[string]interface{}{
+			"text":    thinkingContent,
+			"thought": true,
+		})
+	}
 	if textContent != "" {
 		parts = append(parts, map[string]interface{}{
 			"text": textContent,
@@ -310,27 +343,29 @@ func (a *GeminiToClaudeAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 
 	switch chunkType {
 	case "content_block_delta":
-		// 提取文本内容
-		var textContent string
+		// 提取文本内容，thinking_delta 转换为 thought 部分
+		part := map[string]interface{}{}
 		if delta, ok := chunk["delta"].(map[string]interface{}); ok {
-			if deltaType, ok := delta["type"].(string); ok && deltaType == "text_delta" {
-				if text, ok := delta["text"].(string); ok {
-					textContent = text
+			switch delta["type"] {
+			case "text_delta":
+				if text, ok := delta["text"].(string); ok && text != "" {
+					part["text"] = text
+				}
+			case "thinking_delta":
+				if thinking, ok := delta["thinking"].(string); ok && thinking != "" {
+					part["text"] = thinking
+					part["thought"] = true
 				}
 			}
 		}
 
-		if textContent != "" {
+		if _, ok := part["text"]; ok {
 			return map[string]interface{}{
 				"candidates": []interface{}{
 					map[string]interface{}{
 						"content": map[string]interface{}{
-							"role": "model",
-							"parts": []interface{}{
-								map[string]interface{}{
-									"text": textContent,
-								},
-							},
+							"role":  "model",
+							"parts": []interface{}{part},
 						},
 					},
 				},
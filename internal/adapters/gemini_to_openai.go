@@ -7,12 +7,20 @@ import (
 )
 
 // GeminiToOpenAIAdapter 将 Gemini 格式转换为 OpenAI 格式
-type GeminiToOpenAIAdapter struct{}
+type GeminiToOpenAIAdapter struct {
+	// 流式响应中已发送的 tool_calls 数量，用于为跨块到达的 functionCall 分配 index
+	toolCallCount int
+}
 
 func init() {
 	RegisterAdapter("gemini-to-openai", &GeminiToOpenAIAdapter{})
 }
 
+// newStream 每个流式响应使用独立的实例
+func (a *GeminiToOpenAIAdapter) newStream() Adapter {
+	return &GeminiToOpenAIAdapter{}
+}
+
 // AdaptRequest 将 Gemini 请求转换为 OpenAI 请求
 func (a *GeminiToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, model string) (map[string]interface{}, error) {
 	openaiReq := make(map[string]interface{})
@@ -23,10 +31,17 @@ func (a *GeminiToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 	// 转换消息
 	messages := make([]interface{}, 0)
 
-	// 处理 systemInstruction
-	if systemInstruction, ok := reqData["systemInstruction"].(map[string]interface{}); ok {
-		if parts, ok := systemInstruction["parts"].([]interface{}); ok {
-			var systemText string
+	// 处理 systemInstruction（REST 接口也接受 system_instruction）
+	systemInstruction, ok := reqData["systemInstruction"]
+	if !ok {
+		systemInstruction = reqData["system_instruction"]
+	}
+	var systemText string
+	switch si := systemInstruction.(type) {
+	case string:
+		systemText = si
+	case map[string]interface{}:
+		if parts, ok := si["parts"].([]interface{}); ok {
 			for _, part := range parts {
 				if partMap, ok := part.(map[string]interface{}); ok {
 					if text, ok := partMap["text"].(string); ok {
@@ -34,14 +49,18 @@ func (a *GeminiToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 					}
 				}
 			}
-			if systemText != "" {
-				messages = append(messages, map[string]interface{}{
-					"role":    "system",
-					"content": systemText,
-				})
-			}
 		}
 	}
+	if systemText != "" {
+		messages = append(messages, map[string]interface{}{
+			"role":    "system",
+			"content": systemText,
+		})
+	}
+
+	// Gemini 的 functionCall/functionResponse 通常没有 id，按函数名顺序配对生成 tool_call_id
+	pendingCallIDs := make(map[string][]string)
+	callSeq := 0
 
 	// 转换 contents
 	if contents, ok := reqData["contents"].([]interface{}); ok {
@@ -57,15 +76,19 @@ func (a *GeminiToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 				}
 
 				// 检查是否包含 functionCall 或 functionResponse
-				var textContent string
+				var textContent, reasoningContent string
 				var toolCalls []interface{}
-				var functionResponse *map[string]interface{}
+				var toolMessages []interface{}
 
 				for _, part := range parts {
 					if partMap, ok := part.(map[string]interface{}); ok {
-						// 文本内容
+						// 文本内容，thought 部分转换为 reasoning_content
 						if text, ok := partMap["text"].(string); ok {
-							textContent += text
+							if thought, _ := partMap["thought"].(bool); thought {
+								reasoningContent += text
+							} else {
+								textContent += text
+							}
 						}
 
 						// 函数调用
@@ -78,8 +101,15 @@ func (a *GeminiToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 								arguments = string(argsBytes)
 							}
 
+							id, _ := fc["id"].(string)
+							if id == "" {
+								id = fmt.Sprintf("call_%s_%d", name, callSeq)
+								callSeq++
+							}
+							pendingCallIDs[name] = append(pendingCallIDs[name], id)
+
 							toolCalls = append(toolCalls, map[string]interface{}{
-								"id":   fmt.Sprintf("call_%d_%s", time.Now().UnixNano(), name),
+								"id":   id,
 								"type": "function",
 								"function": map[string]interface{}{
 									"name":      name,
@@ -88,34 +118,50 @@ func (a *GeminiToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 							})
 						}
 
-						// 函数响应
+						// 函数响应 - 每个 functionResponse 转换为一条 tool 消息（并行调用时有多个）
 						if fr, ok := partMap["functionResponse"].(map[string]interface{}); ok {
-							functionResponse = &fr
-						}
-					}
-				}
+							name, _ := fr["name"].(string)
+							response := fr["response"]
+
+							var contentStr string
+							if respMap, ok := response.(map[string]interface{}); ok {
+								if result, ok := respMap["result"].(string); ok {
+									contentStr = result
+								} else {
+									if respBytes, err := json.Marshal(respMap); err == nil {
+										contentStr = string(respBytes)
+									}
+								}
+							}
 
-				// 处理函数响应 - 转换为 tool 消息
-				if functionResponse != nil {
-					name, _ := (*functionResponse)["name"].(string)
-					response := (*functionResponse)["response"]
-
-					var contentStr string
-					if respMap, ok := response.(map[string]interface{}); ok {
-						if result, ok := respMap["result"].(string); ok {
-							contentStr = result
-						} else {
-							if respBytes, err := json.Marshal(respMap); err == nil {
-								contentStr = string(respBytes)
+							id, _ := fr["id"].(string)
+							if id == "" {
+								if ids := pendingCallIDs[name]; len(ids) > 0 {
+									id = ids[0]
+									pendingCallIDs[name] = ids[1:]
+								} else {
+									id = fmt.Sprintf("call_%s", name)
+								}
 							}
+
+							toolMessages = append(toolMessages, map[string]interface{}{
+								"role":         "tool",
+								"tool_call_id": id,
+								"content":      contentStr,
+							})
 						}
 					}
+				}
 
-					messages = append(messages, map[string]interface{}{
-						"role":         "tool",
-						"tool_call_id": fmt.Sprintf("call_%s", name),
-						"content":      contentStr,
-					})
+				// 处理函数响应 - 转换为 tool 消息，同一 content 中的文本作为之后的用户消息
+				if len(toolMessages) > 0 {
+					messages = append(messages, toolMessages...)
+					if textContent != "" {
+						messages = append(messages, map[string]interface{}{
+							"role":    "user",
+							"content": textContent,
+						})
+					}
 					continue
 				}
 
@@ -130,6 +176,9 @@ func (a *GeminiToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 				} else {
 					msg["content"] = textContent
 				}
+				if reasoningContent != "" && openaiRole == "assistant" {
+					msg["reasoning_content"] = reasoningContent
+				}
 
 				messages = append(messages, msg)
 			}
@@ -179,11 +228,21 @@ func (a *GeminiToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 		if topP, ok := generationConfig["topP"]; ok {
 			openaiReq["top_p"] = topP
 		}
-		if stopSequences, ok := generationConfig["stopSequences"]; ok {
-			openaiReq["stop"] = stopSequences
+		setStopSequences(openaiReq, "stop", generationConfig["stopSequences"], openAIMaxStopSequences)
+		copyParam(openaiReq, "frequency_penalty", generationConfig, "frequencyPenalty")
+		copyParam(openaiReq, "presence_penalty", generationConfig, "presencePenalty")
+		copyParam(openaiReq, "seed", generationConfig, "seed")
+		// topK OpenAI 不支持，不转发
+		// responseMimeType/responseSchema → response_format，candidateCount → n，responseLogprobs → logprobs
+		setOpenAIOutputConfig(openaiReq, generationConfig)
+		// thinkingConfig → reasoning_effort
+		if effort := BudgetToReasoningEffort(geminiThinkingBudget(generationConfig)); effort != "" {
+			openaiReq["reasoning_effort"] = effort
 		}
 	}
 
+	// safetySettings 在 OpenAI 中没有对应参数，不转发
+
 	// 处理 stream
 	if stream, ok := reqData["stream"]; ok {
 		openaiReq["stream"] = stream
@@ -201,17 +260,26 @@ func (a *GeminiToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, mod
 func (a *GeminiToOpenAIAdapter) AdaptResponse(respData map[string]interface{}) (map[string]interface{}, error) {
 	geminiResp := make(map[string]interface{})
 
-	// 转换 choices 为 candidates
+	// 转换 choices 为 candidates，每个 choice 对应一个 candidate
 	candidates := make([]interface{}, 0)
 
-	if choices, ok := respData["choices"].([]interface{}); ok && len(choices) > 0 {
-		if choice, ok := choices[0].(map[string]interface{}); ok {
+	choices, _ := respData["choices"].([]interface{})
+	for ci, c := range choices {
+		if choice, ok := c.(map[string]interface{}); ok {
 			candidate := make(map[string]interface{})
 
 			// 提取内容
 			parts := make([]interface{}, 0)
 
 			if message, ok := choice["message"].(map[string]interface{}); ok {
+				// 推理内容 → thought 部分
+				if reasoning, ok := message["reasoning_content"].(string); ok && reasoning != "" {
+					parts = append(parts, map[string]interface{}{
+						"text":    reasoning,
+						"thought": true,
+					})
+				}
+
 				// 文本内容
 				if content, ok := message["content"].(string); ok && content != "" {
 					parts = append(parts, map[string]interface{}{
@@ -265,7 +333,10 @@ func (a *GeminiToOpenAIAdapter) AdaptResponse(respData map[string]interface{}) (
 				}
 			}
 
-			candidate["index"] = 0
+			candidate["index"] = ci
+			if index, ok := choice["index"].(float64); ok {
+				candidate["index"] = int(index)
+			}
 			candidates = append(candidates, candidate)
 		}
 	}
@@ -299,7 +370,8 @@ func (a *GeminiToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 	if candidates, ok := chunk["candidates"].([]interface{}); ok && len(candidates) > 0 {
 		if candidate, ok := candidates[0].(map[string]interface{}); ok {
 			// 提取文本内容
-			var textContent string
+			var textContent, reasoningContent string
+			var toolCalls []interface{}
 			var finishReason interface{} = nil
 
 			if content, ok := candidate["content"].(map[string]interface{}); ok {
@@ -307,7 +379,15 @@ func (a *GeminiToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 					for _, part := range parts {
 						if partMap, ok := part.(map[string]interface{}); ok {
 							if text, ok := partMap["text"].(string); ok {
-								textContent += text
+								if thought, _ := partMap["thought"].(bool); thought {
+									reasoningContent += text
+								} else {
+									textContent += text
+								}
+							}
+							// Gemini 的 functionCall 一次给出完整参数，每个转换为一个完整的 tool_call 分片
+							if fc, ok := partMap["functionCall"].(map[string]interface{}); ok {
+								toolCalls = append(toolCalls, a.streamToolCall(fc))
 							}
 						}
 					}
@@ -316,16 +396,11 @@ func (a *GeminiToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 
 			// 检查 finishReason
 			if fr, ok := candidate["finishReason"].(string); ok && fr != "" {
-				switch fr {
-				case "STOP":
-					finishReason = "stop"
-				case "MAX_TOKENS":
-					finishReason = "length"
-				case "SAFETY", "RECITATION":
-					finishReason = "content_filter"
-				default:
-					finishReason = "stop"
+				finishReason = geminiFinishReason(fr)
+				if a.toolCallCount > 0 && fr == "STOP" {
+					finishReason = "tool_calls"
 				}
+				a.toolCallCount = 0
 			}
 
 			// 构建 OpenAI 格式的流式响应
@@ -343,12 +418,29 @@ func (a *GeminiToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 				},
 			}
 
-			// 只有当有文本内容时才添加到 delta
+			// 只有当有文本或推理内容时才添加到 delta
+			delta := openaiChunk["choices"].([]interface{})[0].(map[string]interface{})["delta"].(map[string]interface{})
 			if textContent != "" {
-				choices := openaiChunk["choices"].([]interface{})
-				choice := choices[0].(map[string]interface{})
-				choice["delta"] = map[string]interface{}{
-					"content": textContent,
+				delta["content"] = textContent
+			}
+			if reasoningContent != "" {
+				delta["reasoning_content"] = reasoningContent
+			}
+			if len(toolCalls) > 0 {
+				delta["tool_calls"] = toolCalls
+				if finishReason == "stop" {
+					finishReason = "tool_calls"
+					openaiChunk["choices"].([]interface{})[0].(map[string]interface{})["finish_reason"] = finishReason
+				}
+			}
+			// 最后一个分片附带 usage（每个分片都有 usageMetadata，结束时的为最终值）
+			if metadata, ok := chunk["usageMetadata"].(map[string]interface{}); ok && finishReason != nil {
+				promptTokens := numberToInt(metadata["promptTokenCount"])
+				completionTokens := numberToInt(metadata["candidatesTokenCount"])
+				openaiChunk["usage"] = map[string]interface{}{
+					"prompt_tokens":     promptTokens,
+					"completion_tokens": completionTokens,
+					"total_tokens":      promptTokens + completionTokens,
 				}
 			}
 
@@ -359,6 +451,33 @@ func (a *GeminiToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 	return nil, nil
 }
 
+// streamToolCall 将一个 functionCall 转换为流式 tool_call 分片，index 在同一响应中递增
+func (a *GeminiToOpenAIAdapter) streamToolCall(fc map[string]interface{}) map[string]interface{} {
+	name, _ := fc["name"].(string)
+	arguments := "{}"
+	if args := fc["args"]; args != nil {
+		if argsBytes, err := json.Marshal(args); err == nil {
+			arguments = string(argsBytes)
+		}
+	}
+	id, _ := fc["id"].(string)
+	if id == "" {
+		id = fmt.Sprintf("call_%d_%d_%s", time.Now().UnixNano(), a.toolCallCount, name)
+	}
+
+	toolCall := map[string]interface{}{
+		"index": a.toolCallCount,
+		"id":    id,
+		"type":  "function",
+		"function": map[string]interface{}{
+			"name":      name,
+			"arguments": arguments,
+		},
+	}
+	a.toolCallCount++
+	return toolCall
+}
+
 // AdaptStreamStart 流式响应开始
 func (a *GeminiToOpenAIAdapter) AdaptStreamStart(model string) []map[string]interface{} {
 	return nil
@@ -0,0 +1,125 @@
+package adapters
+
+import (
+	"strings"
+)
+
+// 图片内容转换：Cursor 和 Claude Code 在 content 数组中发送图片（截图），Claude 格式为
+// {"type": "image", "source": {"type": "base64", "media_type": ..., "data": ...}}（或 URL 来源），
+// OpenAI 格式为 {"type": "image_url", "image_url": {"url": "data:...;base64,..."}}，转换消息时保留图片
+
+// openAIImagePart 将 Claude 的 image 块或 OpenAI 的 image_url 块转换为 OpenAI 的 image_url 内容块，不是图片时返回 nil
+func openAIImagePart(block map[string]interface{}) map[string]interface{} {
+	switch block["type"] {
+	case "image_url":
+		imageURL := map[string]interface{}{}
+		switch v := block["image_url"].(type) {
+		case map[string]interface{}:
+			imageURL = v
+		case string:
+			// Cursor 有时直接使用字符串
+			imageURL["url"] = v
+		}
+		if url, _ := imageURL["url"].(string); url == "" {
+			return nil
+		}
+		return map[string]interface{}{"type": "image_url", "image_url": imageURL}
+	case "image":
+		source, _ := block["source"].(map[string]interface{})
+		var url string
+		switch source["type"] {
+		case "base64":
+			mediaType, _ := source["media_type"].(string)
+			data, _ := source["data"].(string)
+			if data == "" {
+				return nil
+			}
+			if mediaType == "" {
+				mediaType = "image/png"
+			}
+			url = "data:" + mediaType + ";base64," + data
+		case "url":
+			url, _ = source["url"].(string)
+		}
+		if url == "" {
+			return nil
+		}
+		return map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]interface{}{"url": url},
+		}
+	}
+	return nil
+}
+
+// claudeImageBlock 将 OpenAI 的 image_url 块转换为 Claude 的 image 块（data URL 使用 base64 来源，其他使用 URL 来源），
+// Claude 的 image 块原样返回，不是图片时返回 nil
+func claudeImageBlock(block map[string]interface{}) map[string]interface{} {
+	if block["type"] == "image" {
+		return block
+	}
+	part := openAIImagePart(block)
+	if part == nil {
+		return nil
+	}
+	url, _ := part["image_url"].(map[string]interface{})["url"].(string)
+	source := map[string]interface{}{"type": "url", "url": url}
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		if mediaType, data, ok := strings.Cut(rest, ";base64,"); ok {
+			source = map[string]interface{}{"type": "base64", "media_type": mediaType, "data": data}
+		}
+	}
+	return map[string]interface{}{"type": "image", "source": source}
+}
+
+// claudeUserContent 将 OpenAI 格式的 user 消息内容转换为 Claude 格式：image_url 块改为 image 块，其他内容不变
+func claudeUserContent(content interface{}) interface{} {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return content
+	}
+	blocks := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		if partMap, ok := part.(map[string]interface{}); ok && partMap["type"] == "image_url" {
+			if image := claudeImageBlock(partMap); image != nil {
+				blocks = append(blocks, image)
+			}
+			continue
+		}
+		blocks = append(blocks, part)
+	}
+	return blocks
+}
+
+// openAIUserContent 按顺序组合 OpenAI 格式的 user 消息内容：只有文本时合并为字符串，包含图片时为内容块数组
+func openAIUserContent(parts []interface{}) interface{} {
+	var texts []string
+	for _, part := range parts {
+		partMap, _ := part.(map[string]interface{})
+		if partMap["type"] != "text" {
+			return parts
+		}
+		text, _ := partMap["text"].(string)
+		texts = append(texts, text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// toolResultImages 提取 tool_result 内容中的图片（OpenAI 的 tool 消息不支持图片，需要放到之后的 user 消息中）
+func toolResultImages(content interface{}) []interface{} {
+	items, _ := content.([]interface{})
+	var images []interface{}
+	for _, item := range items {
+		if itemMap, ok := item.(map[string]interface{}); ok {
+			if image := openAIImagePart(itemMap); image != nil {
+				images = append(images, image)
+			}
+		}
+	}
+	return images
+}
+
+// isImageBlock 内容块是图片（Claude 的 image 或 OpenAI 的 image_url）
+func isImageBlock(block map[string]interface{}) bool {
+	return block["type"] == "image" || block["type"] == "image_url"
+}
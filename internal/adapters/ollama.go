@@ -0,0 +1,385 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OpenAIToOllamaAdapter 将 OpenAI 格式转换为 Ollama 原生 /api/chat 格式
+type OpenAIToOllamaAdapter struct{}
+
+// OllamaToOpenAIAdapter 将 Ollama 原生流式响应（NDJSON）转换为 OpenAI 格式
+type OllamaToOpenAIAdapter struct{}
+
+func init() {
+	RegisterAdapter("openai-to-ollama", &OpenAIToOllamaAdapter{})
+	RegisterAdapter("ollama-to-openai", &OllamaToOpenAIAdapter{})
+}
+
+// ollamaOptionKeys OpenAI 采样参数 -> Ollama options 字段
+var ollamaOptionKeys = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "top_p",
+	"max_tokens":        "num_predict",
+	"seed":              "seed",
+	"frequency_penalty": "frequency_penalty",
+	"presence_penalty":  "presence_penalty",
+}
+
+// AdaptRequest 将 OpenAI 请求转换为 Ollama 请求
+func (a *OpenAIToOllamaAdapter) AdaptRequest(reqData map[string]interface{}, model string) (map[string]interface{}, error) {
+	ollamaReq := map[string]interface{}{
+		"model": model,
+	}
+
+	// Ollama 默认流式输出，非流式请求必须显式关闭
+	stream, _ := reqData["stream"].(bool)
+	ollamaReq["stream"] = stream
+
+	// tool_call_id -> 函数名，用于 tool 消息的 tool_name
+	toolNames := make(map[string]string)
+	messages := make([]interface{}, 0)
+
+	if msgs, ok := reqData["messages"].([]interface{}); ok {
+		for _, msg := range msgs {
+			msgMap, ok := msg.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			role, _ := msgMap["role"].(string)
+			if role == "developer" {
+				role = "system"
+			}
+			ollamaMsg := map[string]interface{}{"role": role}
+
+			text, images := convertOllamaContent(msgMap["content"])
+			ollamaMsg["content"] = text
+			if len(images) > 0 {
+				ollamaMsg["images"] = images
+			}
+
+			// assistant 的 tool_calls：arguments 由 JSON 字符串转为对象
+			if toolCalls, ok := msgMap["tool_calls"].([]interface{}); ok && len(toolCalls) > 0 {
+				calls := make([]interface{}, 0, len(toolCalls))
+				for _, tc := range toolCalls {
+					tcMap, ok := tc.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					function, ok := tcMap["function"].(map[string]interface{})
+					if !ok {
+						continue
+					}
+					name, _ := function["name"].(string)
+					if id, ok := tcMap["id"].(string); ok {
+						toolNames[id] = name
+					}
+					args := map[string]interface{}{}
+					if arguments, ok := function["arguments"].(string); ok && arguments != "" {
+						json.Unmarshal([]byte(arguments), &args)
+					}
+					calls = append(calls, map[string]interface{}{
+						"function": map[string]interface{}{
+							"name":      name,
+							"arguments": args,
+						},
+					})
+				}
+				ollamaMsg["tool_calls"] = calls
+			}
+
+			if role == "tool" {
+				if id, ok := msgMap["tool_call_id"].(string); ok && toolNames[id] != "" {
+					ollamaMsg["tool_name"] = toolNames[id]
+				}
+			}
+
+			messages = append(messages, ollamaMsg)
+		}
+	}
+	ollamaReq["messages"] = messages
+
+	// 工具定义与 OpenAI 格式相同，直接透传
+	if tools, ok := reqData["tools"].([]interface{}); ok && len(tools) > 0 {
+		ollamaReq["tools"] = tools
+	}
+
+	// 采样参数放入 options
+	options := make(map[string]interface{})
+	for openaiKey, ollamaKey := range ollamaOptionKeys {
+		if v, ok := reqData[openaiKey]; ok && v != nil {
+			options[ollamaKey] = v
+		}
+	}
+	if v, ok := reqData["max_completion_tokens"]; ok && v != nil {
+		options["num_predict"] = v
+	}
+	switch stop := reqData["stop"].(type) {
+	case string:
+		options["stop"] = []interface{}{stop}
+	case []interface{}:
+		options["stop"] = stop
+	}
+	if len(options) > 0 {
+		ollamaReq["options"] = options
+	}
+
+	// response_format: json_object -> "json"，json_schema -> schema
+	if rf, ok := reqData["response_format"].(map[string]interface{}); ok {
+		switch rf["type"] {
+		case "json_object":
+			ollamaReq["format"] = "json"
+		case "json_schema":
+			if js, ok := rf["json_schema"].(map[string]interface{}); ok && js["schema"] != nil {
+				ollamaReq["format"] = js["schema"]
+			}
+		}
+	}
+
+	if keepAlive, ok := reqData["keep_alive"]; ok {
+		ollamaReq["keep_alive"] = keepAlive
+	}
+
+	return ollamaReq, nil
+}
+
+// convertOllamaContent 将 OpenAI content（字符串或多模态数组）拆分为文本和 base64 图片
+// Ollama 只接受 base64 图片，远程 URL 图片会被忽略
+func convertOllamaContent(content interface{}) (string, []interface{}) {
+	switch c := content.(type) {
+	case string:
+		return c, nil
+	case []interface{}:
+		var texts []string
+		var images []interface{}
+		for _, part := range c {
+			partMap, ok := part.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch partMap["type"] {
+			case "text":
+				if text, ok := partMap["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			case "image_url":
+				var url string
+				if imageURL, ok := partMap["image_url"].(map[string]interface{}); ok {
+					url, _ = imageURL["url"].(string)
+				} else {
+					url, _ = partMap["image_url"].(string)
+				}
+				if strings.HasPrefix(url, "data:") {
+					if idx := strings.Index(url, ","); idx >= 0 {
+						images = append(images, url[idx+1:])
+					}
+				}
+			}
+		}
+		return strings.Join(texts, "\n"), images
+	}
+	return "", nil
+}
+
+// AdaptResponse 将 Ollama 响应转换为 OpenAI 响应
+func (a *OpenAIToOllamaAdapter) AdaptResponse(respData map[string]interface{}) (map[string]interface{}, error) {
+	// Ollama 错误响应: {"error": "..."}
+	if errMsg, ok := respData["error"].(string); ok {
+		return map[string]interface{}{
+			"error": map[string]interface{}{"message": errMsg, "type": "ollama_error"},
+		}, nil
+	}
+
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": "",
+	}
+	finishReason := ollamaFinishReason(respData["done_reason"])
+
+	if msg, ok := respData["message"].(map[string]interface{}); ok {
+		if content, ok := msg["content"].(string); ok {
+			message["content"] = content
+		}
+		if thinking, ok := msg["thinking"].(string); ok && thinking != "" {
+			message["reasoning_content"] = thinking
+		}
+		if toolCalls := convertOllamaToolCalls(msg["tool_calls"], false); len(toolCalls) > 0 {
+			message["tool_calls"] = toolCalls
+			finishReason = "tool_calls"
+		}
+	}
+
+	model, _ := respData["model"].(string)
+	return map[string]interface{}{
+		"id":      fmt.Sprintf("chatcmpl-ollama-%d", time.Now().UnixNano()),
+		"object":  "chat.completion",
+		"created": ollamaCreated(respData),
+		"model":   model,
+		"choices": []interface{}{
+			map[string]interface{}{
+				"index":         0,
+				"message":       message,
+				"finish_reason": finishReason,
+			},
+		},
+		"usage": OllamaUsage(respData),
+	}, nil
+}
+
+// AdaptStreamChunk OpenAIToOllamaAdapter 不处理流式响应（由 ollama-to-openai 处理）
+func (a *OpenAIToOllamaAdapter) AdaptStreamChunk(chunk map[string]interface{}) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+// AdaptStreamStart 流式响应开始
+func (a *OpenAIToOllamaAdapter) AdaptStreamStart(model string) []map[string]interface{} {
+	return nil
+}
+
+// AdaptStreamEnd 流式响应结束
+func (a *OpenAIToOllamaAdapter) AdaptStreamEnd() []map[string]interface{} {
+	return nil
+}
+
+// AdaptRequest 不支持将 Ollama 原生请求作为输入
+func (a *OllamaToOpenAIAdapter) AdaptRequest(reqData map[string]interface{}, model string) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("ollama native request format is not supported as input")
+}
+
+// AdaptResponse 响应保持 OpenAI 格式
+func (a *OllamaToOpenAIAdapter) AdaptResponse(respData map[string]interface{}) (map[string]interface{}, error) {
+	return respData, nil
+}
+
+// AdaptStreamChunk 转换流式响应块 - Ollama NDJSON → OpenAI SSE
+func (a *OllamaToOpenAIAdapter) AdaptStreamChunk(chunk map[string]interface{}) (map[string]interface{}, error) {
+	// Ollama 流式响应格式: {"model": "...", "message": {"role": "assistant", "content": "..."}, "done": false}
+	if errMsg, ok := chunk["error"].(string); ok {
+		return map[string]interface{}{
+			"error": map[string]interface{}{"message": errMsg, "type": "ollama_error"},
+		}, nil
+	}
+
+	delta := map[string]interface{}{}
+	if msg, ok := chunk["message"].(map[string]interface{}); ok {
+		if role, ok := msg["role"].(string); ok && role != "" {
+			delta["role"] = role
+		}
+		if content, ok := msg["content"].(string); ok && content != "" {
+			delta["content"] = content
+		}
+		if thinking, ok := msg["thinking"].(string); ok && thinking != "" {
+			delta["reasoning_content"] = thinking
+		}
+		if toolCalls := convertOllamaToolCalls(msg["tool_calls"], true); len(toolCalls) > 0 {
+			delta["tool_calls"] = toolCalls
+		}
+	}
+
+	var finishReason interface{}
+	done, _ := chunk["done"].(bool)
+	if done {
+		finishReason = ollamaFinishReason(chunk["done_reason"])
+	}
+
+	model, _ := chunk["model"].(string)
+	openaiChunk := map[string]interface{}{
+		"id":      fmt.Sprintf("chatcmpl-ollama-%d", time.Now().UnixNano()),
+		"object":  "chat.completion.chunk",
+		"created": ollamaCreated(chunk),
+		"model":   model,
+		"choices": []interface{}{
+			map[string]interface{}{
+				"index":         0,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			},
+		},
+	}
+	// 最后一块携带 token 统计
+	if done {
+		openaiChunk["usage"] = OllamaUsage(chunk)
+	}
+	return openaiChunk, nil
+}
+
+// AdaptStreamStart 流式响应开始
+func (a *OllamaToOpenAIAdapter) AdaptStreamStart(model string) []map[string]interface{} {
+	return nil
+}
+
+// AdaptStreamEnd 流式响应结束
+func (a *OllamaToOpenAIAdapter) AdaptStreamEnd() []map[string]interface{} {
+	return nil
+}
+
+// convertOllamaToolCalls 将 Ollama tool_calls（arguments 为对象）转换为 OpenAI 格式（arguments 为 JSON 字符串）
+// 流式响应中需要带 index
+func convertOllamaToolCalls(raw interface{}, withIndex bool) []interface{} {
+	toolCalls, ok := raw.([]interface{})
+	if !ok {
+		return nil
+	}
+	result := make([]interface{}, 0, len(toolCalls))
+	for i, tc := range toolCalls {
+		tcMap, ok := tc.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		function, ok := tcMap["function"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := function["name"].(string)
+		arguments := "{}"
+		if args, ok := function["arguments"]; ok && args != nil {
+			if argsBytes, err := json.Marshal(args); err == nil {
+				arguments = string(argsBytes)
+			}
+		}
+		call := map[string]interface{}{
+			"id":   fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), i),
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":      name,
+				"arguments": arguments,
+			},
+		}
+		if withIndex {
+			call["index"] = i
+		}
+		result = append(result, call)
+	}
+	return result
+}
+
+// ollamaFinishReason 转换 done_reason
+func ollamaFinishReason(doneReason interface{}) string {
+	if reason, _ := doneReason.(string); reason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+// ollamaCreated 解析 created_at，失败时使用当前时间
+func ollamaCreated(data map[string]interface{}) int64 {
+	if createdAt, ok := data["created_at"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
+			return t.Unix()
+		}
+	}
+	return time.Now().Unix()
+}
+
+// OllamaUsage 从 Ollama 响应的 prompt_eval_count / eval_count 构造 OpenAI usage
+func OllamaUsage(data map[string]interface{}) map[string]interface{} {
+	promptTokens, _ := data["prompt_eval_count"].(float64)
+	completionTokens, _ := data["eval_count"].(float64)
+	return map[string]interface{}{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": completionTokens,
+		"total_tokens":      promptTokens + completionTokens,
+	}
+}
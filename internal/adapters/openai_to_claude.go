@@ -67,15 +67,20 @@ func (a *OpenAIToClaudeAdapter) AdaptRequest(request map[string]interface{}, tar
 						contentStr = cs
 					}
 
+					toolResult := map[string]interface{}{
+						"type":        "tool_result",
+						"tool_use_id": toolCallID,
+						"content":     contentStr,
+					}
+					// 并行工具调用的多个结果必须放在同一条 user 消息中
+					if last := len(claudeMessages) - 1; last >= 0 && isClaudeToolResultMessage(claudeMessages[last]) {
+						lastMsg := claudeMessages[last].(map[string]interface{})
+						lastMsg["content"] = append(lastMsg["content"].([]interface{}), toolResult)
+						continue
+					}
 					claudeMessages = append(claudeMessages, map[string]interface{}{
-						"role": "user",
-						"content": []interface{}{
-							map[string]interface{}{
-								"type":        "tool_result",
-								"tool_use_id": toolCallID,
-								"content":     contentStr,
-							},
-						},
+						"role":    "user",
+						"content": []interface{}{toolResult},
 					})
 					continue
 				}
@@ -163,11 +168,11 @@ func (a *OpenAIToClaudeAdapter) AdaptRequest(request map[string]interface{}, tar
 					continue
 				}
 
-				// 处理 user 消息
+				// 处理 user 消息（image_url 转换为 Claude 的 image 块）
 				if role == "user" {
 					claudeMessages = append(claudeMessages, map[string]interface{}{
 						"role":    "user",
-						"content": content,
+						"content": claudeUserContent(content),
 					})
 				}
 			}
@@ -207,31 +212,43 @@ func (a *OpenAIToClaudeAdapter) AdaptRequest(request map[string]interface{}, tar
 		claudeReq["tool_choice"] = a.convertToolChoice(toolChoice)
 	}
 
-	// 转换其他参数
+	// parallel_tool_calls=false → tool_choice.disable_parallel_tool_use
+	if parallel, ok := request["parallel_tool_calls"].(bool); ok && !parallel && claudeReq["tools"] != nil {
+		toolChoice, ok := claudeReq["tool_choice"].(map[string]interface{})
+		if !ok {
+			toolChoice = map[string]interface{}{"type": "auto"}
+		}
+		toolChoice["disable_parallel_tool_use"] = true
+		claudeReq["tool_choice"] = toolChoice
+	}
+
+	// 转换其他参数；未指定 max_tokens 时由代理按模型填充默认值（Claude 需要 max_tokens）
 	if maxTokens, ok := request["max_tokens"]; ok {
 		claudeReq["max_tokens"] = maxTokens
 	} else if maxCompletionTokens, ok := request["max_completion_tokens"]; ok {
 		claudeReq["max_tokens"] = maxCompletionTokens
-	} else {
-		// Claude 需要 max_tokens
-		claudeReq["max_tokens"] = 4096
 	}
 
 	if temperature, ok := request["temperature"]; ok {
-		claudeReq["temperature"] = temperature
+		claudeReq["temperature"] = claudeTemperature(temperature)
 	}
 
 	if topP, ok := request["top_p"]; ok {
 		claudeReq["top_p"] = topP
 	}
 
+	// top_k 不是 OpenAI 标准参数，部分客户端会发送；frequency_penalty、presence_penalty、seed Claude 不支持
+	copyParam(claudeReq, "top_k", request, "top_k")
+
 	if stream, ok := request["stream"]; ok {
 		claudeReq["stream"] = stream
 	}
 
-	if stop, ok := request["stop"]; ok {
-		claudeReq["stop_sequences"] = stop
-	}
+	// stop 可以是字符串，stop_sequences 必须是数组
+	setStopSequences(claudeReq, "stop_sequences", request["stop"], 0)
+
+	// reasoning_effort → thinking.budget_tokens
+	setClaudeThinking(claudeReq, openAIThinkingBudget(request))
 
 	return claudeReq, nil
 }
@@ -263,6 +280,24 @@ func (a *OpenAIToClaudeAdapter) convertToolChoice(toolChoice interface{}) interf
 	return map[string]interface{}{"type": "auto"}
 }
 
+// isClaudeToolResultMessage 判断消息是否是只包含 tool_result 的 user 消息
+func isClaudeToolResultMessage(msg interface{}) bool {
+	msgMap, ok := msg.(map[string]interface{})
+	if !ok || msgMap["role"] != "user" {
+		return false
+	}
+	blocks, ok := msgMap["content"].([]interface{})
+	if !ok || len(blocks) == 0 {
+		return false
+	}
+	for _, block := range blocks {
+		if blockMap, ok := block.(map[string]interface{}); !ok || blockMap["type"] != "tool_result" {
+			return false
+		}
+	}
+	return true
+}
+
 // extractSystemFromMessages 从消息中提取 system 内容（用于兼容）
 func extractSystemFromMessages(messages []interface{}) string {
 	var systemParts []string
@@ -278,43 +313,9 @@ func extractSystemFromMessages(messages []interface{}) string {
 	return strings.Join(systemParts, "\n\n")
 }
 
+// AdaptResponse 将 Claude 响应转换为 OpenAI 响应（thinking → reasoning_content，tool_use → tool_calls）
 func (a *OpenAIToClaudeAdapter) AdaptResponse(response map[string]interface{}) (map[string]interface{}, error) {
-	// 将 OpenAI 响应转换为 Claude 格式
-	adapted := make(map[string]interface{})
-
-	// 基本字段
-	adapted["id"] = "msg_default"
-	adapted["type"] = "message"
-	adapted["role"] = "assistant"
-	adapted["model"] = "claude-3-sonnet-20240229"
-
-	// 提取内容
-	var contentText string
-	if choices, ok := response["choices"].([]interface{}); ok && len(choices) > 0 {
-		if choice, ok := choices[0].(map[string]interface{}); ok {
-			if message, ok := choice["message"].(map[string]interface{}); ok {
-				contentText = getStringValueOCClaude(message, "content", "")
-			}
-		}
-	}
-
-	// Claude 格式的 content
-	adapted["content"] = []map[string]interface{}{
-		{
-			"type": "text",
-			"text": contentText,
-		},
-	}
-
-	// 转换使用量
-	if usage, ok := response["usage"].(map[string]interface{}); ok {
-		adapted["usage"] = map[string]interface{}{
-			"input_tokens":  getIntValueOC(usage, "prompt_tokens", 0),
-			"output_tokens": getIntValueOC(usage, "completion_tokens", 0),
-		}
-	}
-
-	return adapted, nil
+	return ClaudeResponseToOpenAI(response), nil
 }
 
 func (a *OpenAIToClaudeAdapter) AdaptStreamChunk(chunk map[string]interface{}) (map[string]interface{}, error) {
@@ -370,9 +371,8 @@ func (a *OpenAIToClaudeAdapter) AdaptStreamChunk(chunk map[string]interface{}) (
 		// 检查是否结束
 		if finishReason, hasFinish := choice["finish_reason"].(string); hasFinish && finishReason != "" {
 			fmt.Printf("[ADAPTER DEBUG] Found finish_reason: %s\n", finishReason)
-			// 生成 message_stop 事件
-			adapted["type"] = "message_stop"
-			return adapted, nil
+			// 结束事件（content_block_stop、message_delta、message_stop）由 AdaptStreamEnd 按顺序发送
+			return nil, nil
 		}
 
 		fmt.Printf("[ADAPTER DEBUG] No content or finish reason found\n")
@@ -445,6 +445,11 @@ func (a *OpenAIToClaudeAdapter) AdaptStreamEnd() []map[string]interface{} {
 	}
 	events = append(events, messageDelta)
 
+	// message_stop 事件
+	events = append(events, map[string]interface{}{
+		"type": "message_stop",
+	})
+
 	return events
 }
 
@@ -467,12 +472,3 @@ func getStringValueOCClaude(m map[string]interface{}, key string, defaultValue s
 	}
 	return defaultValue
 }
-
-func getIntValueOC(m map[string]interface{}, key string, defaultValue int) int {
-	if val, ok := m[key]; ok {
-		if f, ok := val.(float64); ok {
-			return int(f)
-		}
-	}
-	return defaultValue
-}
@@ -19,7 +19,8 @@ func (a *OpenAIToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 
 	// 转换消息为 Gemini contents
 	contents := make([]interface{}, 0)
-	var systemInstruction interface{}
+	toolNames := make(map[string]string) // tool_call_id → 函数名，functionResponse 需要函数名
+	lastToolResponse := -1               // 上一条 tool 消息生成的 content 下标，连续的 tool 结果合并到一起
 
 	if messages, ok := reqData["messages"].([]interface{}); ok {
 		for _, msg := range messages {
@@ -27,15 +28,8 @@ func (a *OpenAIToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 				role, _ := msgMap["role"].(string)
 				content := msgMap["content"]
 
-				// 处理 system 消息 - Gemini 使用 systemInstruction
-				if role == "system" {
-					if contentStr, ok := content.(string); ok {
-						systemInstruction = map[string]interface{}{
-							"parts": []interface{}{
-								map[string]interface{}{"text": contentStr},
-							},
-						}
-					}
+				// system/developer 消息合并到 systemInstruction
+				if role == "system" || role == "developer" {
 					continue
 				}
 
@@ -53,22 +47,31 @@ func (a *OpenAIToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 						contentStr = cs
 					}
 
-					// 从 tool_call_id 提取函数名
-					functionName := extractFunctionName(toolCallID)
-
-					contents = append(contents, map[string]interface{}{
-						"role": "user",
-						"parts": []interface{}{
-							map[string]interface{}{
-								"functionResponse": map[string]interface{}{
-									"name": functionName,
-									"response": map[string]interface{}{
-										"result": contentStr,
-									},
-								},
+					// 优先使用对应 tool_call 的函数名，找不到时从 tool_call_id 提取
+					functionName, ok := toolNames[toolCallID]
+					if !ok {
+						functionName = extractFunctionName(toolCallID)
+					}
+					part := map[string]interface{}{
+						"functionResponse": map[string]interface{}{
+							"name": functionName,
+							"response": map[string]interface{}{
+								"result": contentStr,
 							},
 						},
+					}
+
+					// 并行工具调用的多个结果需要放在同一个 content 中，与 functionCall 数量对应
+					if lastToolResponse >= 0 && lastToolResponse == len(contents)-1 {
+						last := contents[lastToolResponse].(map[string]interface{})
+						last["parts"] = append(last["parts"].([]interface{}), part)
+						continue
+					}
+					contents = append(contents, map[string]interface{}{
+						"role":  "user",
+						"parts": []interface{}{part},
 					})
+					lastToolResponse = len(contents) - 1
 					continue
 				}
 
@@ -89,6 +92,9 @@ func (a *OpenAIToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 								if function, ok := tcMap["function"].(map[string]interface{}); ok {
 									name, _ := function["name"].(string)
 									arguments, _ := function["arguments"].(string)
+									if id, ok := tcMap["id"].(string); ok {
+										toolNames[id] = name
+									}
 
 									var args map[string]interface{}
 									if err := json.Unmarshal([]byte(arguments), &args); err != nil {
@@ -131,8 +137,15 @@ func (a *OpenAIToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 	geminiReq["contents"] = contents
 
 	// 设置 systemInstruction
-	if systemInstruction != nil {
-		geminiReq["systemInstruction"] = systemInstruction
+	if messages, ok := reqData["messages"].([]interface{}); ok {
+		if systemInstruction := geminiSystemInstruction(messages); systemInstruction != nil {
+			geminiReq["systemInstruction"] = systemInstruction
+		}
+	}
+
+	// 安全设置（扩展参数）
+	if safetySettings := geminiSafetySettings(reqData); len(safetySettings) > 0 {
+		geminiReq["safetySettings"] = safetySettings
 	}
 
 	// 转换 tools
@@ -180,8 +193,19 @@ func (a *OpenAIToGeminiAdapter) AdaptRequest(reqData map[string]interface{}, mod
 		generationConfig["topP"] = topP
 	}
 
-	if stop, ok := reqData["stop"]; ok {
-		generationConfig["stopSequences"] = stop
+	setStopSequences(generationConfig, "stopSequences", reqData["stop"], geminiMaxStopSequences)
+	copyParam(generationConfig, "frequencyPenalty", reqData, "frequency_penalty")
+	copyParam(generationConfig, "presencePenalty", reqData, "presence_penalty")
+	copyParam(generationConfig, "seed", reqData, "seed")
+	// top_k 不是 OpenAI 标准参数，部分客户端会发送
+	copyParam(generationConfig, "topK", reqData, "top_k")
+
+	// response_format、n、logprobs
+	setGeminiOutputConfig(generationConfig, reqData)
+
+	// reasoning_effort → thinkingConfig
+	if budget := openAIThinkingBudget(reqData); budget > 0 {
+		generationConfig["thinkingConfig"] = geminiThinkingConfig(budget)
 	}
 
 	if len(generationConfig) > 0 {
@@ -270,7 +294,12 @@ func extractFunctionName(toolID string) string {
 }
 
 // AdaptResponse 将 Gemini 响应转换为 OpenAI 响应
+// 每个 candidate 对应一个 choice，同一 candidate 中的多个 functionCall 对应多个 tool_calls
+// 被安全过滤拦截且没有输出内容时返回 *GeminiBlockedError
 func (a *OpenAIToGeminiAdapter) AdaptResponse(respData map[string]interface{}) (map[string]interface{}, error) {
+	if blocked := GeminiBlocked(respData); blocked != nil {
+		return nil, blocked
+	}
 	openaiResp := make(map[string]interface{})
 
 	// 基本字段
@@ -279,79 +308,27 @@ func (a *OpenAIToGeminiAdapter) AdaptResponse(respData map[string]interface{}) (
 	openaiResp["created"] = time.Now().Unix()
 	openaiResp["model"] = "gemini-pro"
 
-	// 转换 candidates
-	var textContent string
-	var toolCalls []interface{}
-	finishReason := "stop"
-
-	if candidates, ok := respData["candidates"].([]interface{}); ok && len(candidates) > 0 {
-		if candidate, ok := candidates[0].(map[string]interface{}); ok {
-			// 提取内容
-			if content, ok := candidate["content"].(map[string]interface{}); ok {
-				if parts, ok := content["parts"].([]interface{}); ok {
-					for _, part := range parts {
-						if partMap, ok := part.(map[string]interface{}); ok {
-							// 文本内容
-							if text, ok := partMap["text"].(string); ok {
-								textContent += text
-							}
-							// 函数调用
-							if functionCall, ok := partMap["functionCall"].(map[string]interface{}); ok {
-								name, _ := functionCall["name"].(string)
-								args := functionCall["args"]
-
-								var arguments string
-								if argsBytes, err := json.Marshal(args); err == nil {
-									arguments = string(argsBytes)
-								}
-
-								toolCalls = append(toolCalls, map[string]interface{}{
-									"id":   fmt.Sprintf("call_%d_%s", time.Now().UnixNano(), name),
-									"type": "function",
-									"function": map[string]interface{}{
-										"name":      name,
-										"arguments": arguments,
-									},
-								})
-							}
-						}
-					}
-				}
-			}
-
-			// 转换 finishReason
-			if fr, ok := candidate["finishReason"].(string); ok {
-				switch fr {
-				case "STOP":
-					finishReason = "stop"
-				case "MAX_TOKENS":
-					finishReason = "length"
-				case "SAFETY", "RECITATION":
-					finishReason = "content_filter"
-				default:
-					finishReason = "stop"
-				}
-			}
+	choices := make([]interface{}, 0)
+	candidates, _ := respData["candidates"].([]interface{})
+	for ci, c := range candidates {
+		candidate, ok := c.(map[string]interface{})
+		if !ok {
+			continue
 		}
+		choiceIndex := ci
+		if v, ok := candidate["index"].(float64); ok {
+			choiceIndex = int(v)
+		}
+		choices = append(choices, a.convertCandidate(candidate, choiceIndex))
 	}
-
-	// 构建 message
-	message := map[string]interface{}{
-		"role":    "assistant",
-		"content": textContent,
-	}
-	if len(toolCalls) > 0 {
-		message["tool_calls"] = toolCalls
-		finishReason = "tool_calls"
-	}
-
-	openaiResp["choices"] = []interface{}{
-		map[string]interface{}{
+	if len(choices) == 0 {
+		choices = append(choices, map[string]interface{}{
 			"index":         0,
-			"message":       message,
-			"finish_reason": finishReason,
-		},
+			"message":       map[string]interface{}{"role": "assistant", "content": ""},
+			"finish_reason": "stop",
+		})
 	}
+	openaiResp["choices"] = choices
 
 	// 转换 usage
 	if usageMetadata, ok := respData["usageMetadata"].(map[string]interface{}); ok {
@@ -373,6 +350,79 @@ func (a *OpenAIToGeminiAdapter) AdaptResponse(respData map[string]interface{}) (
 	return openaiResp, nil
 }
 
+// convertCandidate 将一个 Gemini candidate 转换为 OpenAI choice
+func (a *OpenAIToGeminiAdapter) convertCandidate(candidate map[string]interface{}, choiceIndex int) map[string]interface{} {
+	var textContent, reasoningContent string
+	var toolCalls []interface{}
+	finishReason := "stop"
+
+	if content, ok := candidate["content"].(map[string]interface{}); ok {
+		parts, _ := content["parts"].([]interface{})
+		for _, part := range parts {
+			partMap, ok := part.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			// 文本内容，thought 为 true 的是思考过程
+			if text, ok := partMap["text"].(string); ok {
+				if thought, _ := partMap["thought"].(bool); thought {
+					reasoningContent += text
+				} else {
+					textContent += text
+				}
+			}
+			// 函数调用，并行调用时每个 functionCall 都是一个 tool_call
+			if functionCall, ok := partMap["functionCall"].(map[string]interface{}); ok {
+				name, _ := functionCall["name"].(string)
+				id, _ := functionCall["id"].(string)
+				if id == "" {
+					id = fmt.Sprintf("call_%d_%d_%s", time.Now().UnixNano(), len(toolCalls), name)
+				}
+
+				arguments := "{}"
+				if args := functionCall["args"]; args != nil {
+					if argsBytes, err := json.Marshal(args); err == nil {
+						arguments = string(argsBytes)
+					}
+				}
+
+				toolCalls = append(toolCalls, map[string]interface{}{
+					"id":   id,
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      name,
+						"arguments": arguments,
+					},
+				})
+			}
+		}
+	}
+
+	// 转换 finishReason
+	if fr, ok := candidate["finishReason"].(string); ok {
+		finishReason = geminiFinishReason(fr)
+	}
+
+	// 构建 message
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": textContent,
+	}
+	if reasoningContent != "" {
+		message["reasoning_content"] = reasoningContent
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+		finishReason = "tool_calls"
+	}
+
+	return map[string]interface{}{
+		"index":         choiceIndex,
+		"message":       message,
+		"finish_reason": finishReason,
+	}
+}
+
 // AdaptStreamChunk 转换流式响应块
 func (a *OpenAIToGeminiAdapter) AdaptStreamChunk(chunk map[string]interface{}) (map[string]interface{}, error) {
 	return nil, nil
@@ -0,0 +1,313 @@
+package adapters
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 适配器插件：用户配置的外部程序（任意语言）通过标准输入输出的 JSON 行协议转换请求和响应，以自定义格式名注册，
+// 路由格式设为该名称时 OpenAI 格式的请求经插件转换后发往上游，不需要修改代码即可支持小众供应商。
+//
+// 每次调用向插件的标准输入写入一行 {"id": 1, "method": "...", "params": {...}}，插件向标准输出写入一行
+// {"id": 1, "result": ...} 或 {"id": 1, "error": "..."}，日志写到标准错误。调用按顺序逐个发送，标准输入关闭时插件应退出。
+//
+//	adapt_request      {"request": OpenAI 请求, "model": 模型名}        -> 上游请求体
+//	adapt_response     {"response": 上游响应}                           -> OpenAI 响应
+//	stream_start       {"stream": 流编号, "model": 模型名}              -> 流开始前发送的 OpenAI 分片数组（可为 null）
+//	adapt_stream_chunk {"stream": 流编号, "chunk": 上游 SSE/JSON 行}    -> OpenAI 分片，null 表示不发送
+//	stream_end         {"stream": 流编号}                               -> 流结束前发送的 OpenAI 分片数组（可为 null）
+//
+// 插件可按流编号保存每个流的状态（如 tool_calls 的 index），stream_end 后释放
+
+// PluginConfig 插件配置
+type PluginConfig struct {
+	Name    string // 格式名称（小写），注册为 openai-to-<name> 和 <name>-to-openai 适配器
+	Command string
+	Args    []string
+	Timeout time.Duration // 单次调用的超时，0 表示 30 秒
+}
+
+const (
+	pluginDefaultTimeout = 30 * time.Second // 未配置超时时单次调用的超时
+	pluginMaxLineBytes   = 64 * 1024 * 1024 // 插件输出的一行最大字节数
+)
+
+// 已加载的插件（格式名称 -> 插件进程）
+var (
+	plugins   = make(map[string]*pluginProcess)
+	pluginsMu sync.RWMutex
+)
+
+// pluginStreamID 流编号
+var pluginStreamID atomic.Int64
+
+// LoadPlugins 替换已加载的插件：停止原有插件进程并移除其适配器，注册新的插件（进程在第一次调用时启动）
+func LoadPlugins(configs []PluginConfig) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	for name, p := range plugins {
+		unregisterAdapter("openai-to-" + name)
+		unregisterAdapter(name + "-to-openai")
+		// 正在进行的调用结束后才能停止，不阻塞新插件的注册
+		go p.close()
+	}
+	plugins = make(map[string]*pluginProcess)
+	for _, cfg := range configs {
+		name := strings.ToLower(strings.TrimSpace(cfg.Name))
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = pluginDefaultTimeout
+		}
+		p := &pluginProcess{name: name, command: cfg.Command, args: cfg.Args, timeout: timeout}
+		plugins[name] = p
+		adapter := &PluginAdapter{proc: p}
+		RegisterAdapter("openai-to-"+name, adapter)
+		RegisterAdapter(name+"-to-openai", adapter)
+		log.Infof("[Plugin] Registered adapter plugin %s: %s %s", name, cfg.Command, strings.Join(cfg.Args, " "))
+	}
+}
+
+// IsPlugin 格式名称是否为已加载的插件
+func IsPlugin(format string) bool {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	_, ok := plugins[strings.ToLower(strings.TrimSpace(format))]
+	return ok
+}
+
+// PluginAdapter 通过插件进程转换 OpenAI 请求和上游响应
+type PluginAdapter struct {
+	proc   *pluginProcess
+	stream int64 // 流编号，注册的共享实例为 0
+}
+
+// newStream 每个流使用独立的流编号，插件据此区分并发的流
+func (a *PluginAdapter) newStream() Adapter {
+	return &PluginAdapter{proc: a.proc, stream: pluginStreamID.Add(1)}
+}
+
+// AdaptRequest 将 OpenAI 请求转换为上游请求
+func (a *PluginAdapter) AdaptRequest(request map[string]interface{}, targetModel string) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if err := a.proc.call("adapt_request", map[string]interface{}{"request": request, "model": targetModel}, &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, fmt.Errorf("plugin %s returned an empty request", a.proc.name)
+	}
+	return result, nil
+}
+
+// AdaptResponse 将上游响应转换为 OpenAI 响应
+func (a *PluginAdapter) AdaptResponse(response map[string]interface{}) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if err := a.proc.call("adapt_response", map[string]interface{}{"response": response}, &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, fmt.Errorf("plugin %s returned an empty response", a.proc.name)
+	}
+	return result, nil
+}
+
+// AdaptStreamChunk 将上游流式事件转换为 OpenAI 分片，返回 nil 表示不发送
+func (a *PluginAdapter) AdaptStreamChunk(chunk map[string]interface{}) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := a.proc.call("adapt_stream_chunk", map[string]interface{}{"stream": a.stream, "chunk": chunk}, &result)
+	return result, err
+}
+
+// AdaptStreamStart 流开始前发送的分片
+func (a *PluginAdapter) AdaptStreamStart(model string) []map[string]interface{} {
+	var result []map[string]interface{}
+	if err := a.proc.call("stream_start", map[string]interface{}{"stream": a.stream, "model": model}, &result); err != nil {
+		log.Warnf("[Plugin %s] stream_start failed: %v", a.proc.name, err)
+		return nil
+	}
+	return result
+}
+
+// AdaptStreamEnd 流结束前发送的分片
+func (a *PluginAdapter) AdaptStreamEnd() []map[string]interface{} {
+	var result []map[string]interface{}
+	if err := a.proc.call("stream_end", map[string]interface{}{"stream": a.stream}, &result); err != nil {
+		log.Warnf("[Plugin %s] stream_end failed: %v", a.proc.name, err)
+		return nil
+	}
+	return result
+}
+
+// pluginRequest 发给插件的一行
+type pluginRequest struct {
+	ID     int64                  `json:"id"`
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// pluginResponse 插件返回的一行
+type pluginResponse struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+// pluginProcess 插件进程，第一次调用时启动，退出、超时或通信失败后在下一次调用时重新启动
+type pluginProcess struct {
+	name    string
+	command string
+	args    []string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte   // 标准输出的每一行，进程退出后关闭
+	quit   chan struct{} // 结束进程时关闭，读取协程不再发送
+	nextID int64
+	closed bool
+}
+
+// start 启动插件进程
+func (p *pluginProcess) start() error {
+	cmd := exec.Command(p.command, p.args...)
+	hidePluginWindow(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.name, err)
+	}
+	log.Infof("[Plugin %s] Started (pid %d)", p.name, cmd.Process.Pid)
+
+	var stderrDone sync.WaitGroup
+	stderrDone.Add(1)
+	go func() {
+		defer stderrDone.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Infof("[Plugin %s] %s", p.name, scanner.Text())
+		}
+	}()
+
+	lines := make(chan []byte, 16)
+	quit := make(chan struct{})
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), pluginMaxLineBytes)
+		for scanner.Scan() {
+			select {
+			case lines <- append([]byte(nil), scanner.Bytes()...):
+			case <-quit:
+				// 进程已结束，丢弃剩余输出
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Warnf("[Plugin %s] Failed to read output: %v", p.name, err)
+		}
+		// Wait 会关闭管道，需要在读取结束之后调用
+		stderrDone.Wait()
+		if err := cmd.Wait(); err != nil {
+			log.Warnf("[Plugin %s] Exited: %v", p.name, err)
+		} else {
+			log.Infof("[Plugin %s] Exited", p.name)
+		}
+	}()
+
+	p.cmd, p.stdin, p.lines, p.quit = cmd, stdin, lines, quit
+	return nil
+}
+
+// stop 结束插件进程（调用方持有 mu）
+func (p *pluginProcess) stop() {
+	if p.cmd == nil {
+		return
+	}
+	close(p.quit)
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	p.cmd, p.stdin, p.lines, p.quit = nil, nil, nil, nil
+}
+
+// close 结束插件进程，之后的调用返回错误（插件被移除或替换）
+func (p *pluginProcess) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.stop()
+	p.mu.Unlock()
+}
+
+// call 调用插件方法，result 为 nil 时忽略返回值
+func (p *pluginProcess) call(method string, params map[string]interface{}, result interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("plugin %s has been unloaded", p.name)
+	}
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return err
+		}
+	}
+
+	p.nextID++
+	id := p.nextID
+	line, err := json.Marshal(pluginRequest{ID: id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		p.stop()
+		return fmt.Errorf("failed to write to plugin %s: %w", p.name, err)
+	}
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case data, ok := <-p.lines:
+			if !ok {
+				p.stop()
+				return fmt.Errorf("plugin %s exited while handling %s", p.name, method)
+			}
+			var resp pluginResponse
+			if err := json.Unmarshal(data, &resp); err != nil || resp.ID != id {
+				// 超时的调用之后才返回的结果或插件误写到标准输出的日志
+				log.Warnf("[Plugin %s] Ignoring unexpected output: %.200s", p.name, data)
+				continue
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("plugin %s: %s", p.name, resp.Error)
+			}
+			if result == nil || len(resp.Result) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("plugin %s returned an invalid %s result: %w", p.name, method, err)
+			}
+			return nil
+		case <-timer.C:
+			// 插件可能卡住，结束进程，下一次调用时重新启动
+			p.stop()
+			return fmt.Errorf("plugin %s did not answer %s within %v", p.name, method, p.timeout)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package adapters
+
+import "os/exec"
+
+// hidePluginWindow 只有 Windows 需要隐藏控制台窗口
+func hidePluginWindow(cmd *exec.Cmd) {}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package adapters
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// createNoWindow CREATE_NO_WINDOW，GUI 程序启动控制台程序时不弹出控制台窗口
+const createNoWindow = 0x08000000
+
+// hidePluginWindow 插件进程不显示控制台窗口
+func hidePluginWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: createNoWindow}
+}
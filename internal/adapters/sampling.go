@@ -0,0 +1,63 @@
+package adapters
+
+// 生成参数在三种格式之间的对应关系：
+//   stop（字符串或数组） <-> stop_sequences <-> generationConfig.stopSequences
+//   frequency_penalty / presence_penalty <-> frequencyPenalty / presencePenalty（Claude 不支持，丢弃）
+//   top_k（Claude，部分 OpenAI 兼容接口） <-> topK（OpenAI 官方接口不支持，丢弃）
+//   seed <-> seed（Claude 不支持，丢弃）
+
+const (
+	openAIMaxStopSequences = 4 // OpenAI 最多接受 4 个 stop
+	geminiMaxStopSequences = 5 // Gemini 最多接受 5 个 stopSequences
+	claudeMaxTemperature   = 1.0
+)
+
+// stopSequenceList 将 stop 参数（字符串或字符串数组）转换为数组，去掉空字符串，limit > 0 时只保留前 limit 个；
+// 没有有效值时返回 nil
+func stopSequenceList(v interface{}, limit int) []interface{} {
+	var list []interface{}
+	switch stop := v.(type) {
+	case string:
+		if stop != "" {
+			list = append(list, stop)
+		}
+	case []interface{}:
+		for _, item := range stop {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+	case []string:
+		for _, s := range stop {
+			if s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// setStopSequences 将 stop 参数转换为数组写入 target[key]，没有有效值时不写入
+func setStopSequences(target map[string]interface{}, key string, v interface{}, limit int) {
+	if list := stopSequenceList(v, limit); len(list) > 0 {
+		target[key] = list
+	}
+}
+
+// copyParam source 中存在 from 参数时复制为 target[to]
+func copyParam(target map[string]interface{}, to string, source map[string]interface{}, from string) {
+	if v, ok := source[from]; ok && v != nil {
+		target[to] = v
+	}
+}
+
+// claudeTemperature Claude 的 temperature 范围为 0~1（OpenAI/Gemini 为 0~2），超出时截断为 1
+func claudeTemperature(v interface{}) interface{} {
+	if t, ok := v.(float64); ok && t > claudeMaxTemperature {
+		return claudeMaxTemperature
+	}
+	return v
+}
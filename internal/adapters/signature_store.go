@@ -121,7 +121,7 @@ func StoreSignatureForSession(sessionID string, signature string) {
 			ExpiresAt: time.Now().Add(globalSessionStore.ttl),
 			CreatedAt: time.Now(),
 		}
-		log.Debugf("[SigStore] Stored signature for session %s (len=%d)", sessionID[:8], len(signature))
+		log.Debugf("[SigStore] Stored signature for session %s (len=%d)", sessionID[:min(8, len(sessionID))], len(signature))
 	}
 }
 
@@ -157,7 +157,7 @@ func ClearSignatureForSession(sessionID string) {
 	defer globalSessionStore.mu.Unlock()
 
 	delete(globalSessionStore.store, sessionID)
-	log.Debugf("[SigStore] Cleared signature for session %s", sessionID[:8])
+	log.Debugf("[SigStore] Cleared signature for session %s", sessionID[:min(8, len(sessionID))])
 }
 
 // cleanup 清理过期的签名条目
@@ -0,0 +1,137 @@
+package adapters
+
+import (
+	"strings"
+)
+
+// reasoning_effort 与 thinking 预算（budget_tokens / thinkingBudget）的对应关系
+const (
+	ThinkingBudgetLow    = 1024 // Claude 允许的最小预算
+	ThinkingBudgetMedium = 8192
+	ThinkingBudgetHigh   = 24576
+)
+
+// ReasoningEffortToBudget 将 OpenAI 的 reasoning_effort 转换为 thinking 预算，返回 0 表示不启用
+func ReasoningEffortToBudget(effort string) int {
+	switch strings.ToLower(strings.TrimSpace(effort)) {
+	case "minimal", "low":
+		return ThinkingBudgetLow
+	case "medium":
+		return ThinkingBudgetMedium
+	case "high", "xhigh":
+		return ThinkingBudgetHigh
+	}
+	return 0
+}
+
+// BudgetToReasoningEffort 将 thinking 预算转换为最接近的 reasoning_effort，预算为 0 时返回空
+func BudgetToReasoningEffort(budget int) string {
+	switch {
+	case budget <= 0:
+		return ""
+	case budget < (ThinkingBudgetLow+ThinkingBudgetMedium)/2:
+		return "low"
+	case budget < (ThinkingBudgetMedium+ThinkingBudgetHigh)/2:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// openAIThinkingBudget 读取 OpenAI 请求的 reasoning_effort（兼容 Responses 风格的 reasoning.effort）
+func openAIThinkingBudget(request map[string]interface{}) int {
+	if effort, ok := request["reasoning_effort"].(string); ok {
+		return ReasoningEffortToBudget(effort)
+	}
+	if reasoning, ok := request["reasoning"].(map[string]interface{}); ok {
+		if effort, ok := reasoning["effort"].(string); ok {
+			return ReasoningEffortToBudget(effort)
+		}
+	}
+	return 0
+}
+
+// claudeThinkingBudget 读取 Claude 请求的 thinking 配置，未启用时返回 0
+func claudeThinkingBudget(request map[string]interface{}) int {
+	thinking, ok := request["thinking"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	switch thinking["type"] {
+	case "enabled":
+		if budget := numberToInt(thinking["budget_tokens"]); budget > 0 {
+			return budget
+		}
+		return ThinkingBudgetMedium
+	case "adaptive":
+		return ThinkingBudgetMedium
+	}
+	return 0
+}
+
+// geminiThinkingBudget 读取 generationConfig.thinkingConfig，-1（动态预算）按 medium 处理，返回 0 表示不启用
+func geminiThinkingBudget(generationConfig map[string]interface{}) int {
+	thinkingConfig, ok := generationConfig["thinkingConfig"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	if v, ok := thinkingConfig["thinkingBudget"]; ok {
+		budget := numberToInt(v)
+		if budget < 0 {
+			return ThinkingBudgetMedium
+		}
+		return budget
+	}
+	// Gemini 3 使用 thinkingLevel（low/high）
+	if level, ok := thinkingConfig["thinkingLevel"].(string); ok {
+		return ReasoningEffortToBudget(level)
+	}
+	if includeThoughts, _ := thinkingConfig["includeThoughts"].(bool); includeThoughts {
+		return ThinkingBudgetMedium
+	}
+	return 0
+}
+
+// setClaudeThinking 为 Claude 请求启用 extended thinking
+// Claude 要求 max_tokens 大于 budget_tokens，且启用 thinking 时不支持修改 temperature、top_k
+func setClaudeThinking(claudeReq map[string]interface{}, budget int) {
+	if budget <= 0 {
+		return
+	}
+	if budget < ThinkingBudgetLow {
+		budget = ThinkingBudgetLow
+	}
+	claudeReq["thinking"] = map[string]interface{}{
+		"type":          "enabled",
+		"budget_tokens": budget,
+	}
+	if numberToInt(claudeReq["max_tokens"]) <= budget {
+		claudeReq["max_tokens"] = budget + 4096
+	}
+	delete(claudeReq, "temperature")
+	delete(claudeReq, "top_k")
+	if topP, ok := claudeReq["top_p"].(float64); ok && topP < 0.95 {
+		delete(claudeReq, "top_p")
+	}
+}
+
+// geminiThinkingConfig 构建 Gemini 的 thinkingConfig，includeThoughts 让响应返回思考内容
+func geminiThinkingConfig(budget int) map[string]interface{} {
+	return map[string]interface{}{
+		"thinkingBudget":  budget,
+		"includeThoughts": true,
+	}
+}
+
+// numberToInt 将 JSON 数字（float64）或 int 转换为 int，其他类型返回 0
+func numberToInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case int64:
+		return int(n)
+	}
+	return 0
+}
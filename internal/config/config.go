@@ -2,8 +2,16 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -12,6 +20,8 @@ type Config struct {
 	Host                  string `json:"host"`
 	Port                  int    `json:"port"`
 	DatabasePath          string `json:"database_path"`
+	DatabaseDriver        string `json:"database_driver"` // 路由和请求日志数据库: sqlite, postgres, mysql
+	DatabaseDSN           string `json:"database_dsn"`    // postgres/mysql 连接串，sqlite 使用 database_path
 	LocalAPIKey           string `json:"local_api_key"`
 	FallbackEnabled       bool   `json:"fallback_enabled"`
 	ProxyEnabled          bool   `json:"proxy_enabled"`           // 是否使用系统代理
@@ -23,20 +33,264 @@ type Config struct {
 	MinimizeToTray        bool   `json:"minimize_to_tray"`
 	AutoStart             bool   `json:"auto_start"`
 	EnableFileLog         bool   `json:"enable_file_log"`
+	LogLevel              string `json:"log_level"`               // 日志级别: debug, info, warn, error
+	LogFormat             string `json:"log_format"`              // 日志格式: text, json
+	LogMaxSizeMB          int    `json:"log_max_size_mb"`         // 日志文件滚动大小(MB)
+	LogMaxBackups         int    `json:"log_max_backups"`         // 保留的滚动日志文件数
+	LogMaxMessageBytes    int    `json:"log_max_message_bytes"`   // 单条日志最大长度，超出部分（通常是请求/响应体）被截断，0 表示不限制
+	BodyLogMode           string `json:"body_log_mode"`           // 代理请求日志: off, headers(只记录请求头), sampled(按比例记录请求/响应体), full
+	BodyLogSamplePercent  int    `json:"body_log_sample_percent"` // sampled 模式下记录请求/响应体的比例(0-100)
 	TracesEnabled         bool   `json:"traces_enabled"`          // 是否启用对话追踪
 	TracesRetentionDays   int    `json:"traces_retention_days"`   // 对话保留天数
 	TracesSessionTimeout  int    `json:"traces_session_timeout"` // 会话超时时间(分钟)
+	TraceRedactionEnabled bool     `json:"trace_redaction_enabled"`  // 查看 Traces 时自动脱敏 API Key 等密钥
+	TraceRedactionPatterns []string `json:"trace_redaction_patterns"` // 额外的脱敏正则
+	TraceStreamMaxKB       int      `json:"trace_stream_max_kb"`      // 流式响应写入 Trace 的最大长度(KB)，0 表示不记录内容
+	MaintenanceEnabled       bool `json:"maintenance_enabled"`        // 后台定期清理过期 Traces、压缩请求日志
+	MaintenanceIntervalHours int  `json:"maintenance_interval_hours"` // 清理间隔(小时)
+	LogRetentionDays         int  `json:"log_retention_days"`         // 原始请求日志保留天数，之前的日志聚合为小时统计
+	VacuumIntervalHours      int  `json:"vacuum_interval_hours"`      // VACUUM 间隔(小时)，0 表示不自动执行
 	Language              string `json:"language"`
+	GenProfiles           map[string]map[string]interface{} `json:"gen_profiles"` // 生成参数预设，客户端通过 X-Gen-Profile 选择
+	RetryMaxAttempts      int     `json:"retry_max_attempts"`  // 同一路由最大尝试次数（含首次），1 表示不重试
+	RetryBaseDelayMs      int     `json:"retry_base_delay_ms"` // 指数退避基础延迟(毫秒)
+	RetryMaxDelayMs       int     `json:"retry_max_delay_ms"`  // 单次重试最大等待(毫秒)
+	RetryJitter           float64 `json:"retry_jitter"`        // 退避随机抖动比例 0~1
+	ProviderRPMLimits     map[string]int `json:"provider_rpm_limits"` // 供应商账号级 RPM 限制，按 API 主机配置，"*" 为默认值
+	AdaptiveTimeoutEnabled bool   `json:"adaptive_timeout_enabled"` // 非流式请求按历史耗时自适应超时
+	AdaptiveTimeoutFactor  float64 `json:"adaptive_timeout_factor"` // 超时 = p99 耗时 × 系数
+	AdaptiveTimeoutMinMs   int    `json:"adaptive_timeout_min_ms"`  // 自适应超时下限(毫秒)
+	AdaptiveTimeoutMaxMs   int    `json:"adaptive_timeout_max_ms"`  // 自适应超时上限(毫秒)
+	TLSEnabled            bool   `json:"tls_enabled"` // API 服务器使用 HTTPS 监听
+	CertPath              string `json:"cert_path"`   // 证书路径，为空时自动生成自签名证书
+	KeyPath               string `json:"key_path"`    // 私钥路径
+	ListenHosts           []string `json:"listen_hosts"`  // 额外监听的地址（与 Host 使用同一端口）
+	AllowedCIDRs          []string `json:"allowed_cidrs"` // 允许访问的客户端网段，为空不限制（本机回环地址始终允许）
+	ModelsCacheTTLMinutes int    `json:"models_cache_ttl_minutes"` // 上游模型列表缓存有效期(分钟)
+	ModelsCacheWarmup     bool   `json:"models_cache_warmup"`      // 启动时后台预热所有路由的模型列表缓存
+	EncryptAPIKeys        bool   `json:"encrypt_api_keys"`         // 加密存储上游 API Key
+	SecretKeyPath         string `json:"secret_key_path"`          // 数据密钥文件路径，为空时与数据库同目录
+	SchemaDriftEnabled    bool    `json:"schema_drift_enabled"`     // 采样检测上游响应结构变化
+	SchemaDriftSampleRate float64 `json:"schema_drift_sample_rate"` // 基线建立后的采样比例 0~1
+	HealthProbeEnabled         bool   `json:"health_probe_enabled"`          // 定期主动探测所有启用的路由
+	HealthProbeIntervalSeconds int    `json:"health_probe_interval_seconds"` // 探测间隔(秒)
+	HealthProbeMode            string `json:"health_probe_mode"`             // 探测方式: models(GET /models), completion(max_tokens=1 的对话请求)
+	HealthAwareRouting         bool   `json:"health_aware_routing"`          // Fallback 时将连续探测失败的路由排到最后
+	KeyCooldownSeconds         int    `json:"key_cooldown_seconds"`          // 多 Key 路由中 Key 返回 429 后的冷却时间(秒)，上游 Retry-After 更长时以其为准
+	KeyAuthCooldownMinutes     int    `json:"key_auth_cooldown_minutes"`     // Key 返回 401/403 后的冷却时间(分钟)
+	QuotaTimezone              string `json:"quota_timezone"`                // 路由每日配额的重置时区（IANA 名称，如 America/Los_Angeles），为空时使用本地时区
+	ModerationEnabled     bool             `json:"moderation_enabled"` // 请求发往上游前按规则检查内容（脱敏/拦截/记录）
+	ModerationRules       []ModerationRule `json:"moderation_rules"`   // 内容审查规则，按顺序匹配
+	BatchConcurrency      int              `json:"batch_concurrency"`  // 批处理任务同时执行的请求数
+	AnthropicVersion      string           `json:"anthropic_version"`  // 客户端未指定时发往 Claude 上游的 anthropic-version
+	ModelCompatEnabled    bool              `json:"model_compat_enabled"` // 按模型兼容规则修正发往 OpenAI 兼容接口的请求参数
+	ModelCompatRules      []ModelCompatRule `json:"model_compat_rules"`   // 模型兼容规则，使用第一条匹配的规则
+	ClaudeMaxTokens       map[string]int    `json:"claude_max_tokens"`    // 发往 Claude 的请求未指定 max_tokens 时填充的默认值（按模型名，支持 * 通配），超过模型输出上限时使用上限
+	AdapterPlugins        []AdapterPlugin   `json:"adapter_plugins"`      // 外部程序实现的适配器插件，路由格式设为插件名称时使用
+	RoutingRulesEnabled   bool              `json:"routing_rules_enabled"` // 按请求特征（估算输入 token、图片、工具数）选择路由
+	RoutingRules          []RoutingRule     `json:"routing_rules"`         // 按请求特征路由的规则，使用第一条匹配的规则
+	ContextOverflowEnabled  bool   `json:"context_overflow_enabled"`  // 估算输入超过路由模型的上下文长度（模型元数据）时改用上下文更大的路由
+	ContextOverflowStrategy string `json:"context_overflow_strategy"` // 没有路由容得下时: none(原样发送), drop_oldest(删除最早的消息), summarize(总结最早的消息)
+	ContextSummaryModel     string `json:"context_summary_model"`     // summarize 策略使用的模型，为空时使用请求的模型
+	RouteOverrideEnabled  bool             `json:"route_override_enabled"` // 允许持有本地 API Key 的客户端通过 X-AnyProxy-Route-ID / X-AnyProxy-Provider 指定路由
+	VirtualKeys           []VirtualKey     `json:"virtual_keys"`           // 本地 API Key 之外的客户端 Key，可限制可见模型
+	WebAdminEnabled       bool             `json:"web_admin_enabled"`      // 在 API 服务器的 /admin 提供网页管理界面（使用本地 API Key 登录）
+	MCPEnabled            bool             `json:"mcp_enabled"`            // 在 /mcp 提供 MCP 服务器（管理工具，使用本地 API Key 认证）
+	NotificationsEnabled  bool             `json:"notifications_enabled"`   // 路由故障、预算等事件发送通知
+	NotifyDesktop         bool             `json:"notify_desktop"`          // 发送系统桌面通知
+	NotifyEvents          map[string]bool  `json:"notify_events"`           // 各事件的通知开关，未列出的事件默认开启
+	NotifyWebhooks        []NotifyWebhook  `json:"notify_webhooks"`         // 通知 Webhook（Slack/Discord/通用 JSON）
+	NotifyCooldownMinutes int              `json:"notify_cooldown_minutes"` // 同一事件（同一路由/模型）重复通知的最小间隔(分钟)
+	DailyTokenBudget      int64            `json:"daily_token_budget"`      // 每日 token 预算，用量达到 90% 和 100% 时通知，0 表示不提醒
+	ReportEnabled         bool                  `json:"report_enabled"`          // 定期发送用量报告
+	ReportSchedule        string                `json:"report_schedule"`         // daily(前一天), weekly(前 7 天)
+	ReportHour            int                   `json:"report_hour"`             // 发送时间(0-23 点，本地时间)
+	ReportWeekday         int                   `json:"report_weekday"`          // weekly 时在星期几发送，0 表示周日
+	ReportTemplate        string                `json:"report_template"`         // 报告模板(Go text/template)，为空使用默认模板
+	ReportWebhooks        bool                  `json:"report_webhooks"`         // 发送到通知 Webhook（订阅了 usage_report 事件或全部事件的）
+	ReportEmail           ReportEmail           `json:"report_email"`            // 通过邮件发送
+	ReportLastSentAt      string                `json:"report_last_sent_at"`     // 上次发送的报告对应的计划时间，避免重启后重复发送
+	ModelPrices           map[string]ModelPrice `json:"model_prices"`            // 模型价格（按模型名，支持 * 通配），用于估算报告中的费用
+	UpstreamMaxIdleConnsPerHost    int  `json:"upstream_max_idle_conns_per_host"`   // 每个上游地址保留的空闲连接数
+	UpstreamMaxConnsPerHost        int  `json:"upstream_max_conns_per_host"`        // 每个上游地址的最大连接数，0 表示不限制
+	UpstreamIdleConnTimeoutSeconds int  `json:"upstream_idle_conn_timeout_seconds"` // 空闲连接关闭时间(秒)
+	UpstreamDialTimeoutSeconds     int  `json:"upstream_dial_timeout_seconds"`      // 建立连接超时(秒)
+	UpstreamForceHTTP2             bool `json:"upstream_force_http2"`               // HTTPS 上游优先使用 HTTP/2（多个请求复用一个连接）
+	UpstreamDNSCacheSeconds        int  `json:"upstream_dns_cache_seconds"`         // 上游域名解析结果缓存时间(秒)，0 表示不缓存
+	StreamHeartbeatSeconds         int  `json:"stream_heartbeat_seconds"`           // 流式响应上游无数据时向客户端发送心跳的间隔(秒)，0 表示不发送
+	StreamPassthrough              bool `json:"stream_passthrough"`                 // 同格式路由的流式响应零拷贝透传，不逐行解析事件（记录 Trace 内容或响应体日志的请求除外）
+	MaxRequestBodyMB               int  `json:"max_request_body_mb"`                // 请求体最大大小(MB)，超出返回 413，0 表示不限制
+	MaxRequestMessages             int  `json:"max_request_messages"`               // 单个请求最多包含的消息数，0 表示不限制
+	UpdateCheckEnabled             bool   `json:"update_check_enabled"`             // 启动后和每天检查 GitHub 上的新版本
+	UpdateChannel                  string `json:"update_channel"`                   // 更新通道: stable(正式版), beta(包含预发布版本)
 	configPath            string
+	authKey               atomic.Pointer[string] // 运行时生效的本地 API Key（原子读写，支持热更新）
 }
 
-func LoadConfig() *Config {
-	configPath := "config.json"
+// ModerationRule 内容审查规则
+type ModerationRule struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`    // keyword(关键词，逗号或换行分隔，不区分大小写), regex, pii(内置规则: email, credit_card, api_key, phone)
+	Pattern string `json:"pattern"` // 关键词列表、正则表达式或内置 PII 规则名
+	Action  string `json:"action"`  // mask(替换为 [REDACTED]), block(拒绝请求), log(只记录)
+	Enabled bool   `json:"enabled"`
+}
+
+// ModelCompatRule 模型参数兼容规则：o1/o3 等推理模型不接受 max_tokens、temperature 和 system 消息
+type ModelCompatRule struct {
+	Name       string            `json:"name"`
+	Pattern    string            `json:"pattern"`     // 模型名，逗号分隔多个，支持 * 通配，不区分大小写；带供应商前缀（如 openai/o3）时匹配前缀之后的部分
+	Rename     map[string]string `json:"rename"`      // 参数改名（如 max_tokens -> max_completion_tokens），目标参数已存在时只删除原参数
+	Remove     []string          `json:"remove"`      // 删除的参数
+	SystemRole string            `json:"system_role"` // system 消息改为: developer, user(合并到第一条 user 消息开头)，为空不修改
+	Enabled    bool              `json:"enabled"`
+}
+
+// RoutingRule 按请求特征路由的规则：请求的模型匹配且满足所有设置的条件时，改用目标模型的路由或优先使用目标分组的路由
+type RoutingRule struct {
+	Name            string `json:"name"`
+	Model           string `json:"model"`             // 客户端请求的模型名，逗号分隔多个，支持 * 通配，不区分大小写
+	MinPromptTokens int    `json:"min_prompt_tokens"` // 估算输入 token 不少于该值，0 表示不检查
+	HasImages       bool   `json:"has_images"`        // 请求包含图片
+	MinTools        int    `json:"min_tools"`         // 工具（函数）定义数不少于该值，0 表示不检查
+	TargetModel     string `json:"target_model"`      // 改用该模型的路由，为空时使用请求的模型
+	TargetGroup     string `json:"target_group"`      // 优先使用该分组的路由，Fallback 时其它路由排在后面
+	Enabled         bool   `json:"enabled"`
+}
+
+// AdapterPlugin 适配器插件：外部程序通过标准输入输出的 JSON 行协议转换 OpenAI 请求和上游响应（协议见 adapters/plugin.go）
+type AdapterPlugin struct {
+	Name           string   `json:"name"`            // 格式名称，路由的格式设为该名称时使用插件
+	Command        string   `json:"command"`         // 插件程序路径
+	Args           []string `json:"args"`            // 启动参数
+	Path           string   `json:"path"`            // 追加到路由 API 地址后的请求路径，支持 {model}，为空时使用 OpenAI 的 /v1/chat/completions
+	StreamPath     string   `json:"stream_path"`     // 流式请求的路径，为空时与 path 相同
+	TimeoutSeconds int      `json:"timeout_seconds"` // 单次转换调用的超时(秒)，0 表示 30 秒
+	Enabled        bool     `json:"enabled"`
+}
+
+// reasoningUnsupportedParams OpenAI 推理模型不接受的采样参数
+var reasoningUnsupportedParams = []string{"temperature", "top_p", "presence_penalty", "frequency_penalty", "logprobs", "top_logprobs", "logit_bias"}
+
+// DefaultModelCompatRules 内置的模型兼容规则
+func DefaultModelCompatRules() []ModelCompatRule {
+	maxCompletionTokens := map[string]string{"max_tokens": "max_completion_tokens"}
+	return []ModelCompatRule{
+		{Name: "o1-mini / o1-preview", Pattern: "o1-mini*, o1-preview*", Rename: maxCompletionTokens, Remove: reasoningUnsupportedParams, SystemRole: "user", Enabled: true},
+		{Name: "OpenAI o-series", Pattern: "o1*, o3*, o4*", Rename: maxCompletionTokens, Remove: reasoningUnsupportedParams, SystemRole: "developer", Enabled: true},
+		{Name: "GPT-5 Chat", Pattern: "gpt-5-chat*", Rename: maxCompletionTokens, Enabled: true},
+		{Name: "GPT-5", Pattern: "gpt-5*", Rename: maxCompletionTokens, Remove: reasoningUnsupportedParams, Enabled: true},
+	}
+}
+
+// DefaultGenProfiles 内置的生成参数预设（只设置 temperature，Claude 不允许同时设置 temperature 和 top_p）
+func DefaultGenProfiles() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"precise":  {"temperature": 0.2},
+		"creative": {"temperature": 1.0},
+	}
+}
+
+// setDefaultGenProfiles config.json 中没有 gen_profiles 字段时使用内置预设，有该字段时以文件为准（可以删除内置预设）
+func (c *Config) setDefaultGenProfiles(data []byte) {
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields) // 无法解析时使用内置预设
+	if _, ok := fields["gen_profiles"]; !ok {
+		c.GenProfiles = DefaultGenProfiles()
+	}
+}
+
+// NotifyWebhook 通知 Webhook
+type NotifyWebhook struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"` // slack, discord, generic(POST 通知的 JSON)
+	URL     string   `json:"url"`
+	Events  []string `json:"events"` // 只发送这些事件，为空表示全部
+	Enabled bool     `json:"enabled"`
+}
+
+// ReportEmail 用量报告的邮件发送设置
+type ReportEmail struct {
+	Enabled  bool     `json:"enabled"`
+	SMTPHost string   `json:"smtp_host"`
+	SMTPPort int      `json:"smtp_port"` // 465 使用 TLS 连接，其它端口在服务器支持时使用 STARTTLS
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// ModelPrice 模型价格（每百万 token）
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
 
-	cfg := &Config{
+// PriceFor 按模型名查找价格，先精确匹配再按通配符匹配（多个通配符匹配时取排序最后的，即更具体的前缀优先）
+func (c *Config) PriceFor(model string) (ModelPrice, bool) {
+	if price, ok := c.ModelPrices[model]; ok {
+		return price, true
+	}
+	patterns := make([]string, 0, len(c.ModelPrices))
+	for pattern := range c.ModelPrices {
+		patterns = append(patterns, pattern)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(patterns)))
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, model); ok {
+			return c.ModelPrices[pattern], true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// ClaudeMaxTokensFor 按模型名查找 Claude 请求的默认 max_tokens，匹配方式与 PriceFor 相同，未配置时返回 0
+func (c *Config) ClaudeMaxTokensFor(model string) int {
+	if n, ok := c.ClaudeMaxTokens[model]; ok {
+		return n
+	}
+	patterns := make([]string, 0, len(c.ClaudeMaxTokens))
+	for pattern := range c.ClaudeMaxTokens {
+		patterns = append(patterns, pattern)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(patterns)))
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, model); ok {
+			return c.ClaudeMaxTokens[pattern]
+		}
+	}
+	return 0
+}
+
+// VirtualKey 分发给不同客户端的 API Key，与本地 API Key 一样可以调用所有接口
+type VirtualKey struct {
+	Name          string   `json:"name"`
+	Key           string   `json:"key"`
+	VisibleModels []string `json:"visible_models"` // 模型列表中可见的模型，支持 * 通配，为空表示全部可见
+	MaxTokens     int      `json:"max_tokens"`     // 该 Key 请求的 max_tokens 上限，0 表示不限制
+	Enabled       bool     `json:"enabled"`
+}
+
+// ModelVisible 模型是否对该 Key 可见
+func (k *VirtualKey) ModelVisible(model string) bool {
+	if len(k.VisibleModels) == 0 {
+		return true
+	}
+	for _, pattern := range k.VisibleModels {
+		if ok, _ := path.Match(pattern, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultConfig 返回默认配置
+func defaultConfig(configPath string) *Config {
+	return &Config{
 		Host:                  "localhost",
 		Port:                  5642,
 		DatabasePath:          "routes.db",
+		DatabaseDriver:        "sqlite",
 		LocalAPIKey:           "sk-local-default-key",
 		FallbackEnabled:       true,
 		ProxyEnabled:          true,  // 默认启用系统代理
@@ -48,12 +302,78 @@ func LoadConfig() *Config {
 		MinimizeToTray:        true,
 		AutoStart:             false,
 		EnableFileLog:         false,
+		LogLevel:              "info",
+		LogFormat:             "text",
+		LogMaxSizeMB:          20,
+		LogMaxBackups:         7,
+		LogMaxMessageBytes:    8192,
+		BodyLogMode:           "headers",
+		BodyLogSamplePercent:  10,
 		TracesEnabled:         false, // 默认关闭，因为会占用存储
 		TracesRetentionDays:   7,     // 默认保疙7天
 		TracesSessionTimeout:  30,    // 默认30分钟超时
+		TraceRedactionEnabled: true,
+		TraceStreamMaxKB:      64,
+		NotifyDesktop:            true,
+		NotifyCooldownMinutes:    10,
+		ReportSchedule:           "daily",
+		ReportHour:               9,
+		ReportWeekday:            1,
+		ReportWebhooks:           true,
+		ReportEmail:              ReportEmail{SMTPPort: 587},
+		UpstreamMaxIdleConnsPerHost:    32,
+		UpstreamIdleConnTimeoutSeconds: 90,
+		UpstreamDialTimeoutSeconds:     30,
+		UpstreamForceHTTP2:             true,
+		UpstreamDNSCacheSeconds:        60,
+		StreamHeartbeatSeconds:         15,
+		MaxRequestBodyMB:               32,
+		UpdateCheckEnabled:             true,
+		UpdateChannel:                  "stable",
+		MaintenanceEnabled:       true,
+		MaintenanceIntervalHours: 6,
+		LogRetentionDays:         7,
+		VacuumIntervalHours:      168, // 每周一次
 		Language:              "en-US",
-		configPath:            configPath,
+		RetryMaxAttempts: 2,    // 默认对瞬时错误重试一次
+		RetryBaseDelayMs: 500,
+		RetryMaxDelayMs:  10000,
+		RetryJitter:      0.2,
+		AdaptiveTimeoutEnabled: false,
+		AdaptiveTimeoutFactor:  3,
+		AdaptiveTimeoutMinMs:   30000,  // 最少 30 秒
+		AdaptiveTimeoutMaxMs:   600000, // 最多 10 分钟
+		ModelsCacheTTLMinutes:  60,
+		ModelsCacheWarmup:      true,
+		EncryptAPIKeys:         true,
+		SchemaDriftEnabled:     true,
+		SchemaDriftSampleRate:  0.2,
+		HealthProbeEnabled:         false,
+		HealthProbeIntervalSeconds: 300,
+		HealthProbeMode:            "models",
+		HealthAwareRouting:         true,
+		KeyCooldownSeconds:         60,
+		KeyAuthCooldownMinutes:     30,
+		ModerationEnabled:          false,
+		ModerationRules: []ModerationRule{
+			{Name: "Email", Type: "pii", Pattern: "email", Action: "mask", Enabled: true},
+			{Name: "Credit card", Type: "pii", Pattern: "credit_card", Action: "mask", Enabled: true},
+			{Name: "API key", Type: "pii", Pattern: "api_key", Action: "mask", Enabled: true},
+		},
+		BatchConcurrency:   4,
+		AnthropicVersion:   "2023-06-01",
+		ModelCompatEnabled: true,
+		ModelCompatRules:   DefaultModelCompatRules(),
+		ContextOverflowEnabled:  true,
+		ContextOverflowStrategy: "none",
+		configPath:         configPath,
 	}
+}
+
+func LoadConfig() *Config {
+	configPath := "config.json"
+
+	cfg := defaultConfig(configPath)
 
 	// 尝试从文件加载配置
 	if data, err := os.ReadFile(configPath); err == nil {
@@ -62,15 +382,148 @@ func LoadConfig() *Config {
 		} else {
 			log.Info("Configuration loaded from config.json")
 		}
+		cfg.setDefaultGenProfiles(data)
 	} else {
 		log.Info("Config file not found, using default configuration")
+		cfg.setDefaultGenProfiles(nil)
 		// 保存默认配置
 		cfg.Save()
 	}
 
+	cfg.SetAuthKey(cfg.LocalAPIKey)
 	return cfg
 }
 
+// Reload 重新从 config.json 读取配置并覆盖当前实例的所有导出字段
+func (c *Config) Reload() error {
+	data, err := os.ReadFile(c.configPath)
+	if err != nil {
+		return err
+	}
+
+	next := defaultConfig(c.configPath)
+	if err := json.Unmarshal(data, next); err != nil {
+		return err
+	}
+	next.setDefaultGenProfiles(data)
+
+	dst := reflect.ValueOf(c).Elem()
+	src := reflect.ValueOf(next).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		if dst.Type().Field(i).IsExported() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+
+	c.SetAuthKey(c.LocalAPIKey)
+	log.Info("Configuration reloaded from config.json")
+	return nil
+}
+
+// WritePatch 将部分字段（字段名与 config.json 相同）合并到当前配置后写入 config.json
+// 不修改运行中的配置，由调用方通过 Reload 应用；包含未知字段、类型错误或 validate 返回错误时不写入
+func (c *Config) WritePatch(patch []byte, validate func(next *Config) error) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return fmt.Errorf("invalid config patch: %v", err)
+	}
+	known := make(map[string]bool)
+	t := reflect.TypeOf(c).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.IsExported() {
+			known[strings.Split(f.Tag.Get("json"), ",")[0]] = true
+		}
+	}
+	for name := range fields {
+		if !known[name] {
+			return fmt.Errorf("unknown config field: %s", name)
+		}
+	}
+
+	current, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	next := defaultConfig(c.configPath)
+	if err := json.Unmarshal(current, next); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patch, next); err != nil {
+		return fmt.Errorf("invalid config patch: %v", err)
+	}
+	if validate != nil {
+		if err := validate(next); err != nil {
+			return err
+		}
+	}
+	return next.Save()
+}
+
+// BaseURL 本地 API 服务器的访问地址（根据 TLS 设置选择协议）
+func (c *Config) BaseURL() string {
+	scheme := "http"
+	if c.TLSEnabled {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, c.Host, c.Port)
+}
+
+// ListenAddrs API 服务器需要监听的所有地址（Host 在前，已去重）
+func (c *Config) ListenAddrs() []string {
+	seen := make(map[string]bool)
+	var addrs []string
+	for _, host := range append([]string{c.Host}, c.ListenHosts...) {
+		host = strings.TrimSpace(host)
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(c.Port)))
+	}
+	return addrs
+}
+
+// DatabaseSource 主数据库的驱动和连接串（SQLite 为文件路径）
+func (c *Config) DatabaseSource() (driver, dsn string) {
+	driver = strings.ToLower(strings.TrimSpace(c.DatabaseDriver))
+	if driver == "" || driver == "sqlite" || driver == "sqlite3" {
+		return "sqlite", c.DatabasePath
+	}
+	return driver, c.DatabaseDSN
+}
+
+// AuthKey 获取当前生效的本地 API Key
+func (c *Config) AuthKey() string {
+	if key := c.authKey.Load(); key != nil {
+		return *key
+	}
+	return c.LocalAPIKey
+}
+
+// FindVirtualKey 查找启用的虚拟 Key，不存在时返回 nil
+func (c *Config) FindVirtualKey(key string) *VirtualKey {
+	if key == "" {
+		return nil
+	}
+	for i := range c.VirtualKeys {
+		if c.VirtualKeys[i].Enabled && c.VirtualKeys[i].Key == key {
+			return &c.VirtualKeys[i]
+		}
+	}
+	return nil
+}
+
+// IsClientKey 是否为本地 API Key 或启用的虚拟 Key（这些 Key 不应透传给上游）
+func (c *Config) IsClientKey(key string) bool {
+	return key != "" && (key == c.AuthKey() || c.FindVirtualKey(key) != nil)
+}
+
+// SetAuthKey 原子更新本地 API Key，正在处理的请求不受影响
+func (c *Config) SetAuthKey(key string) {
+	c.LocalAPIKey = key
+	c.authKey.Store(&key)
+}
+
 func (c *Config) Save() error {
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
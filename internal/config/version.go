@@ -0,0 +1,5 @@
+package config
+
+// Version 当前程序版本，用于检查更新。发布构建时可通过
+// -ldflags "-X openai-router-go/internal/config.Version=v2.0.8" 覆盖
+var Version = "2.0.7"
@@ -2,12 +2,20 @@ package database
 
 import (
 	"database/sql"
+	"embed"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	_ "modernc.org/sqlite"
 )
 
+//go:embed schema/*.sql
+var serverSchemas embed.FS
+
 // ModelRoute 模型路由表结构
 type ModelRoute struct {
 	ID        int64     `json:"id"`
@@ -20,27 +28,41 @@ type ModelRoute struct {
 	Enabled   bool      `json:"enabled"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	ExtraHeaders  map[string]string `json:"extra_headers"`  // 发往上游的自定义请求头
+	ExtraQuery    map[string]string `json:"extra_query"`    // 附加到上游 URL 的查询参数
+	Priority      int               `json:"priority"`       // 分组内的优先级，数字越小越先尝试
+	MaxTokens     int               `json:"max_tokens"`     // 请求的 max_tokens 上限，0 表示不限制
+	UpstreamModel string            `json:"upstream_model"` // 发往上游的模型名，为空时使用客户端请求的模型名（Model 为客户端请求的模型名）
+	ProviderPrefs string            `json:"provider_prefs"` // OpenRouter 路由附加到请求的字段（JSON 对象：provider、transforms）
+
+	DailyTokenLimit   int64 `json:"daily_token_limit"`   // 每日 token 上限，达到后当天选择路由时跳过，0 表示不限制
+	DailyRequestLimit int   `json:"daily_request_limit"` // 每日请求数上限，0 表示不限制
 }
 
 // RequestLog 请求日志表结构
 type RequestLog struct {
-	ID             int64     `json:"id"`
-	Model          string    `json:"model"`           // 请求的模型名
-	ProviderModel  string    `json:"provider_model"` // 实际使用的提供商模型
-	ProviderName   string    `json:"provider_name"`  // 提供商/路由名称
-	RouteID        int64     `json:"route_id"`
-	RequestTokens  int       `json:"request_tokens"`
-	ResponseTokens int       `json:"response_tokens"`
-	TotalTokens    int       `json:"total_tokens"`
-	Success        bool      `json:"success"`
-	ErrorMessage   string    `json:"error_message"`
-	Style          string    `json:"style"`           // 请求类型: openai, claude, gemini
-	UserAgent      string    `json:"user_agent"`      // 用户代理
-	RemoteIP       string    `json:"remote_ip"`       // 客户端IP
-	ProxyTimeMs    int64     `json:"proxy_time_ms"`   // 代理总耗时(毫秒)
-	FirstChunkMs   int64     `json:"first_chunk_ms"` // 首字节时间(毫秒)
-	IsStream       bool      `json:"is_stream"`       // 是否流式请求
-	CreatedAt      time.Time `json:"created_at"`
+	ID              int64     `json:"id"`
+	Model           string    `json:"model"`          // 请求的模型名
+	ProviderModel   string    `json:"provider_model"` // 实际使用的提供商模型
+	ProviderName    string    `json:"provider_name"`  // 提供商/路由名称
+	RouteID         int64     `json:"route_id"`
+	RequestTokens   int       `json:"request_tokens"`
+	ResponseTokens  int       `json:"response_tokens"`
+	TotalTokens     int       `json:"total_tokens"`
+	Success         bool      `json:"success"`
+	ErrorMessage    string    `json:"error_message"`
+	Style           string    `json:"style"`            // 请求类型: openai, claude, gemini
+	UserAgent       string    `json:"user_agent"`       // 用户代理
+	RemoteIP        string    `json:"remote_ip"`        // 客户端IP
+	ProxyTimeMs     int64     `json:"proxy_time_ms"`    // 代理总耗时(毫秒)
+	FirstChunkMs    int64     `json:"first_chunk_ms"`   // 首字节时间(毫秒)
+	IsStream        bool      `json:"is_stream"`        // 是否流式请求
+	RequestID       string    `json:"request_id"`       // 请求唯一ID (X-Request-ID)
+	TokensEstimated bool      `json:"tokens_estimated"` // token 数为本地估算值
+	Cost            float64   `json:"cost"`             // 上游返回的实际费用（美元，如 OpenRouter），0 表示未返回
+	ErrorType       string    `json:"error_type"`       // 失败请求的错误类型（network、timeout、auth、rate_limit 等）
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 // HourlyStats 每小时统计表结构（压缩后的数据）
@@ -74,7 +96,7 @@ type UsageSummary struct {
 // ConversationTrace 对话追踪表结构
 type ConversationTrace struct {
 	ID              int64     `json:"id"`
-	SessionID       string    `json:"session_id"`       // 会话ID (IP + 时间窗口生成)
+	SessionID       string    `json:"session_id"`       // 会话ID (客户端会话/对话指纹/IP + 时间窗口)
 	RemoteIP        string    `json:"remote_ip"`        // 客户端IP
 	Model           string    `json:"model"`            // 请求模型
 	ProviderModel   string    `json:"provider_model"`   // 实际模型
@@ -89,32 +111,115 @@ type ConversationTrace struct {
 	Style           string    `json:"style"`            // openai/claude/gemini
 	IsStream        bool      `json:"is_stream"`
 	ProxyTimeMs     int64     `json:"proxy_time_ms"`
+	RequestID       string    `json:"request_id"`       // 请求唯一ID (X-Request-ID)
+	Moderation      string    `json:"moderation"`       // 命中的内容审查规则 (JSON)
+	Fingerprint     string    `json:"fingerprint"`      // 请求消息链的指纹，用于关联多轮对话
+	ParentID        int64     `json:"parent_id"`        // 上一轮对话的 Trace ID，0 表示对话的第一轮
+	ClientKey       string    `json:"client_key"`       // 客户端提供的会话标识 (X-Session-Id/user)
+	UpstreamRequest string    `json:"upstream_request"` // 格式转换后实际发往上游的请求体（只在查看转换对比时读取）
+	RawResponse     string    `json:"raw_response"`     // 上游返回的原始响应（转换前）
 	CreatedAt       time.Time `json:"created_at"`
 }
 
-func InitDB(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", dbPath)
+// Open 打开 SQLite 数据库，设置 busy_timeout 使短暂锁定（如备份）时等待而不是立即失败
+// 使用 WAL 日志模式，写入请求日志时不阻塞统计查询等读操作
+func Open(dbPath string) (*DB, error) {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", dbPath+sep+"_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, err
+	}
+	return &DB{DB: db, Dialect: DialectSQLite}, nil
+}
+
+// OpenDriver 按驱动打开主数据库，sqlite 时 dsn 为文件路径
+func OpenDriver(driver, dsn string) (*DB, error) {
+	dialect, err := ParseDialect(driver)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == DialectSQLite {
+		return Open(dsn)
+	}
+	if dsn == "" {
+		return nil, fmt.Errorf("database_dsn is required for driver %s", dialect)
+	}
+
+	if dialect == DialectMySQL {
+		dsn, err = mysqlDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open(string(dialect), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %v", dialect, err)
+	}
+	return &DB{DB: db, Dialect: dialect}, nil
+}
+
+// mysqlDSN 补充必需的连接参数：解析时间字段、按本地时区读写、允许多语句（建表脚本）
+func mysqlDSN(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.ParseTime = true
+	cfg.MultiStatements = true
+	if _, ok := cfg.Params["loc"]; !ok && cfg.Loc == time.UTC {
+		cfg.Loc = time.Local
+	}
+	return cfg.FormatDSN(), nil
+}
+
+// InitDB 初始化 SQLite 主数据库
+func InitDB(dbPath string) (*DB, error) {
+	return InitDBWithDriver("sqlite", dbPath)
+}
+
+// InitDBWithDriver 初始化主数据库（路由、请求日志、统计），支持 sqlite/postgres/mysql
+func InitDBWithDriver(driver, dsn string) (*DB, error) {
+	db, err := OpenDriver(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	// 创建表
-	if err := createTables(db); err != nil {
+	if db.Dialect == DialectSQLite {
+		if err := createTables(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		// 执行数据库迁移
+		if err := migrateDB(db); err != nil {
+			log.Warnf("Database migration warning: %v", err)
+		}
+	} else if err := createServerTables(db); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	// 执行数据库迁移
-	if err := migrateDB(db); err != nil {
-		log.Warnf("Database migration warning: %v", err)
+	// 执行版本化迁移（migrations/<dialect> 目录）
+	if err := Migrate(db, MigrationsFor(db.Dialect)); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	log.Info("Database initialized successfully")
+	log.Infof("Database initialized successfully (%s)", db.Dialect)
 	return db, nil
 }
 
-func InitTraceDB(dbPath string) (*sql.DB, error) {
-	traceDB, err := sql.Open("sqlite", dbPath)
+// InitTraceDB 初始化对话追踪数据库，始终使用本地 SQLite
+func InitTraceDB(dbPath string) (*DB, error) {
+	traceDB, err := Open(dbPath)
 	if err != nil {
 		return nil, err
 	}
@@ -124,11 +229,24 @@ func InitTraceDB(dbPath string) (*sql.DB, error) {
 		return nil, err
 	}
 
+	migrateTraceDB(traceDB)
+
 	log.Info("Trace database initialized successfully")
 	return traceDB, nil
 }
 
-func createTables(db *sql.DB) error {
+// createServerTables 创建 PostgreSQL/MySQL 的基础表结构
+func createServerTables(db *DB) error {
+	schema, err := serverSchemas.ReadFile("schema/" + string(db.Dialect) + ".sql")
+	if err != nil {
+		return err
+	}
+	// 建表脚本按方言单独编写，直接执行不做改写
+	_, err = db.DB.Exec(string(schema))
+	return err
+}
+
+func createTables(db *DB) error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS model_routes (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -217,7 +335,7 @@ func createTables(db *sql.DB) error {
 	return err
 }
 
-func createTraceTables(db *sql.DB) error {
+func createTraceTables(db *DB) error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS conversation_traces (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -250,7 +368,8 @@ func createTraceTables(db *sql.DB) error {
 }
 
 // migrateDB 执行数据库迁移，确保表结构是最新的
-func migrateDB(db *sql.DB) error {
+// 旧版迁移方式，新的表结构变更请添加到 migrations/app 目录
+func migrateDB(db *DB) error {
 	// 添加 format 列（如果不存在）
 	db.Exec(`ALTER TABLE model_routes ADD COLUMN format TEXT DEFAULT 'openai'`)
 
@@ -274,7 +393,71 @@ func migrateDB(db *sql.DB) error {
 	db.Exec(`ALTER TABLE request_logs ADD COLUMN proxy_time_ms INTEGER DEFAULT 0`)
 	db.Exec(`ALTER TABLE request_logs ADD COLUMN first_chunk_ms INTEGER DEFAULT 0`)
 	db.Exec(`ALTER TABLE request_logs ADD COLUMN is_stream INTEGER DEFAULT 0`)
+	db.Exec(`ALTER TABLE request_logs ADD COLUMN request_id TEXT`)
+	db.Exec(`CREATE INDEX IF NOT EXISTS idx_request_logs_request_id ON request_logs(request_id)`)
 
 	log.Info("Database migration completed")
 	return nil
 }
+
+// migrateTraceDB 执行 traces 数据库迁移
+func migrateTraceDB(db *DB) {
+	db.Exec(`ALTER TABLE conversation_traces ADD COLUMN request_id TEXT`)
+	db.Exec(`CREATE INDEX IF NOT EXISTS idx_traces_request_id ON conversation_traces(request_id)`)
+	db.Exec(`ALTER TABLE conversation_traces ADD COLUMN moderation TEXT`)
+	db.Exec(`ALTER TABLE conversation_traces ADD COLUMN fingerprint TEXT`)
+	db.Exec(`ALTER TABLE conversation_traces ADD COLUMN parent_id INTEGER DEFAULT 0`)
+	db.Exec(`ALTER TABLE conversation_traces ADD COLUMN client_key TEXT`)
+	db.Exec(`CREATE INDEX IF NOT EXISTS idx_traces_fingerprint ON conversation_traces(fingerprint)`)
+	db.Exec(`CREATE INDEX IF NOT EXISTS idx_traces_client_key ON conversation_traces(client_key)`)
+	db.Exec(`ALTER TABLE conversation_traces ADD COLUMN upstream_request TEXT`)
+	db.Exec(`ALTER TABLE conversation_traces ADD COLUMN raw_response TEXT`)
+
+	// 全文搜索索引，首次创建时为已有记录建立索引（在事务中执行，失败时下次启动重试）
+	var ftsTables int
+	db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='conversation_traces_fts'`).Scan(&ftsTables)
+	if ftsTables == 0 {
+		if err := createTraceSearchIndex(db); err != nil {
+			log.Warnf("Failed to create trace search index: %v", err)
+		}
+	}
+}
+
+func createTraceSearchIndex(db *DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(traceSearchSchema); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// traceSearchSchema 对话追踪的全文索引：FTS5 trigram 分词（按子串匹配，支持中文），由触发器与 conversation_traces 同步
+const traceSearchSchema = `
+	CREATE VIRTUAL TABLE conversation_traces_fts USING fts5(
+		model, provider_name, remote_ip, request_content, response_content, error_message,
+		content='conversation_traces', content_rowid='id', tokenize='trigram'
+	);
+
+	CREATE TRIGGER conversation_traces_fts_insert AFTER INSERT ON conversation_traces BEGIN
+		INSERT INTO conversation_traces_fts(rowid, model, provider_name, remote_ip, request_content, response_content, error_message)
+		VALUES (new.id, new.model, new.provider_name, new.remote_ip, new.request_content, new.response_content, new.error_message);
+	END;
+
+	CREATE TRIGGER conversation_traces_fts_delete AFTER DELETE ON conversation_traces BEGIN
+		INSERT INTO conversation_traces_fts(conversation_traces_fts, rowid, model, provider_name, remote_ip, request_content, response_content, error_message)
+		VALUES ('delete', old.id, old.model, old.provider_name, old.remote_ip, old.request_content, old.response_content, old.error_message);
+	END;
+
+	CREATE TRIGGER conversation_traces_fts_update AFTER UPDATE OF model, provider_name, remote_ip, request_content, response_content, error_message ON conversation_traces BEGIN
+		INSERT INTO conversation_traces_fts(conversation_traces_fts, rowid, model, provider_name, remote_ip, request_content, response_content, error_message)
+		VALUES ('delete', old.id, old.model, old.provider_name, old.remote_ip, old.request_content, old.response_content, old.error_message);
+		INSERT INTO conversation_traces_fts(rowid, model, provider_name, remote_ip, request_content, response_content, error_message)
+		VALUES (new.id, new.model, new.provider_name, new.remote_ip, new.request_content, new.response_content, new.error_message);
+	END;
+
+	INSERT INTO conversation_traces_fts(conversation_traces_fts) VALUES ('rebuild');
+`
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect 数据库方言
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+)
+
+// ParseDialect 解析配置中的 database_driver，空值表示 SQLite
+func ParseDialect(driver string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "", "sqlite", "sqlite3":
+		return DialectSQLite, nil
+	case "postgres", "postgresql", "pg":
+		return DialectPostgres, nil
+	case "mysql", "mariadb":
+		return DialectMySQL, nil
+	}
+	return "", fmt.Errorf("unsupported database driver: %s", driver)
+}
+
+// DB 数据库连接
+// 业务代码统一使用 SQLite 风格的 SQL，执行前按方言改写，使同一套查询可运行于 SQLite/PostgreSQL/MySQL
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// Tx 事务，同样按方言改写 SQL
+type Tx struct {
+	*sql.Tx
+	dialect Dialect
+}
+
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.Dialect.Rebind(query), db.Dialect.args(args)...)
+}
+
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.Dialect.Rebind(query), db.Dialect.args(args)...)
+}
+
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.Dialect.Rebind(query), db.Dialect.args(args)...)
+}
+
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: db.Dialect}, nil
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.Rebind(query), tx.dialect.args(args)...)
+}
+
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.Rebind(query), tx.dialect.args(args)...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.Rebind(query), tx.dialect.args(args)...)
+}
+
+// args 服务端数据库中布尔字段使用整数列存储（与 SQLite 一致）
+func (d Dialect) args(args []interface{}) []interface{} {
+	if d == DialectSQLite {
+		return args
+	}
+	converted := args
+	for i, arg := range args {
+		b, ok := arg.(bool)
+		if !ok {
+			continue
+		}
+		if &converted[0] == &args[0] {
+			converted = append([]interface{}(nil), args...)
+		}
+		if b {
+			converted[i] = 1
+		} else {
+			converted[i] = 0
+		}
+	}
+	return converted
+}
+
+var (
+	reDateNow       = regexp.MustCompile(`date\('now',\s*'localtime'\)`)
+	reDateNowShift  = regexp.MustCompile(`date\('now',\s*'localtime',\s*(\?|'[^']*')\)`)
+	reDatetimeNow   = regexp.MustCompile(`datetime\('now',\s*'localtime'\)`)
+	reStrftime      = regexp.MustCompile(`strftime\('([^']*)',\s*(\w+)\)`)
+	reCreatedSubstr = regexp.MustCompile(`substr\(created_at,`)
+	reCastInteger   = regexp.MustCompile(`(?i)\bAS INTEGER\)`)
+	reRandom        = regexp.MustCompile(`(?i)\bRANDOM\(\)`)
+	reLike          = regexp.MustCompile(`\bLIKE\b`)
+	reOnConflict    = regexp.MustCompile(`(?i)ON CONFLICT\s*\([^)]*\)\s*DO UPDATE SET`)
+	reExcluded      = regexp.MustCompile(`(?i)\bexcluded\.(\w+)`)
+)
+
+// Rebind 将 SQLite 风格的 SQL 改写为当前方言
+// 支持业务代码中用到的写法：? 占位符、date/datetime('now','localtime')、strftime、RANDOM()、ON CONFLICT 等
+func (d Dialect) Rebind(query string) string {
+	switch d {
+	case DialectPostgres:
+		query = reDateNowShift.ReplaceAllString(query, `to_char(CURRENT_DATE + CAST($1 AS INTERVAL), 'YYYY-MM-DD')`)
+		query = reDateNow.ReplaceAllString(query, `to_char(CURRENT_DATE, 'YYYY-MM-DD')`)
+		query = reDatetimeNow.ReplaceAllString(query, `to_char(LOCALTIMESTAMP, 'YYYY-MM-DD HH24:MI:SS')`)
+		query = reStrftime.ReplaceAllStringFunc(query, func(m string) string {
+			sub := reStrftime.FindStringSubmatch(m)
+			return postgresStrftime(sub[1], sub[2])
+		})
+		// created_at 在 PostgreSQL 中为 TIMESTAMP，按字符串截取前先转换
+		query = reCreatedSubstr.ReplaceAllString(query, `substr(CAST(created_at AS TEXT),`)
+		// SQLite 的 LIKE 对 ASCII 不区分大小写
+		query = reLike.ReplaceAllString(query, "ILIKE")
+		return numberPlaceholders(query)
+	case DialectMySQL:
+		query = reDateNowShift.ReplaceAllString(query, `DATE_FORMAT(DATE_ADD(CURDATE(), INTERVAL CAST(SUBSTRING_INDEX($1, ' ', 1) AS SIGNED) DAY), '%Y-%m-%d')`)
+		query = reDateNow.ReplaceAllString(query, `DATE_FORMAT(CURDATE(), '%Y-%m-%d')`)
+		query = reDatetimeNow.ReplaceAllString(query, `DATE_FORMAT(NOW(), '%Y-%m-%d %H:%i:%s')`)
+		query = reStrftime.ReplaceAllStringFunc(query, func(m string) string {
+			sub := reStrftime.FindStringSubmatch(m)
+			return mysqlStrftime(sub[1], sub[2])
+		})
+		query = reCastInteger.ReplaceAllString(query, "AS SIGNED)")
+		query = reRandom.ReplaceAllString(query, "RAND()")
+		query = reOnConflict.ReplaceAllString(query, "ON DUPLICATE KEY UPDATE")
+		query = reExcluded.ReplaceAllString(query, "VALUES($1)")
+		return strings.ReplaceAll(query, `"group"`, "`group`")
+	}
+	return query
+}
+
+// postgresStrftime 支持 %Y 与 %Y-W%W（周一为一周第一天，与 SQLite 的周编号一致）
+func postgresStrftime(format, column string) string {
+	date := "CAST(" + column + " AS DATE)"
+	switch format {
+	case "%Y":
+		return "to_char(" + date + ", 'YYYY')"
+	case "%Y-W%W":
+		return "to_char(" + date + ", 'YYYY') || '-W' || lpad(CAST((CAST(EXTRACT(DOY FROM " + date + ") AS INTEGER) + 7 - CAST(EXTRACT(ISODOW FROM " + date + ") AS INTEGER)) / 7 AS TEXT), 2, '0')"
+	}
+	return "to_char(" + date + ", 'YYYY-MM-DD')"
+}
+
+// mysqlStrftime 支持 %Y 与 %Y-W%W，WEEK 模式 5 与 SQLite 的 %W 一致
+func mysqlStrftime(format, column string) string {
+	switch format {
+	case "%Y":
+		return "DATE_FORMAT(" + column + ", '%Y')"
+	case "%Y-W%W":
+		return "CONCAT(DATE_FORMAT(" + column + ", '%Y'), '-W', LPAD(WEEK(" + column + ", 5), 2, '0'))"
+	}
+	return "DATE_FORMAT(" + column + ", '%Y-%m-%d')"
+}
+
+// numberPlaceholders 将 ? 占位符改写为 PostgreSQL 的 $1, $2...，跳过字符串和引号标识符
+func numberPlaceholders(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,244 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//go:embed migrations/app/*.sql migrations/postgres/*.sql migrations/mysql/*.sql
+var appMigrationFiles embed.FS
+
+// AppMigrations 主数据库的版本化迁移（SQLite）
+var AppMigrations = mustSub(appMigrationFiles, "migrations/app")
+
+// MigrationsFor 按方言选择迁移目录，服务端数据库的版本号与 SQLite 保持一致
+func MigrationsFor(d Dialect) fs.FS {
+	switch d {
+	case DialectPostgres:
+		return mustSub(appMigrationFiles, "migrations/postgres")
+	case DialectMySQL:
+		return mustSub(appMigrationFiles, "migrations/mysql")
+	}
+	return AppMigrations
+}
+
+// Migration 单个版本化迁移，文件命名为 NNNN_name.up.sql / NNNN_name.down.sql
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// AppliedMigration 已执行的迁移记录
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// LoadMigrations 读取目录中的迁移文件并按版本排序
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || path.Ext(fileName) != ".sql" {
+			continue
+		}
+
+		var direction string
+		base := strings.TrimSuffix(fileName, ".sql")
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			direction, base = "up", strings.TrimSuffix(base, ".up")
+		case strings.HasSuffix(base, ".down"):
+			direction, base = "down", strings.TrimSuffix(base, ".down")
+		default:
+			return nil, fmt.Errorf("migration %s must end with .up.sql or .down.sql", fileName)
+		}
+
+		versionStr, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s has invalid version prefix", fileName)
+		}
+
+		content, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %04d_%s is missing the up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func ensureSchemaVersionTable(db *DB) error {
+	appliedAtType := "DATETIME"
+	if db.Dialect == DialectPostgres {
+		appliedAtType = "TIMESTAMPTZ"
+	}
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at ` + appliedAtType + ` DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+// AppliedMigrations 获取已执行的迁移
+func AppliedMigrations(db *DB) ([]AppliedMigration, error) {
+	if err := ensureSchemaVersionTable(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT version, name, applied_at FROM schema_version ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, rows.Err()
+}
+
+// SchemaVersion 当前数据库结构版本，0 表示尚未执行任何版本化迁移
+func SchemaVersion(db *DB) (int, error) {
+	if err := ensureSchemaVersionTable(db); err != nil {
+		return 0, err
+	}
+	var version int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	return version, err
+}
+
+// Migrate 执行所有未执行的迁移，每个迁移在独立事务中执行
+func Migrate(db *DB, fsys fs.FS) error {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		start := time.Now()
+		if err := runMigration(db, m.Up, func(tx *Tx) error {
+			_, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`, m.Version, m.Name, time.Now())
+			return err
+		}); err != nil {
+			return fmt.Errorf("migration %04d_%s failed: %v", m.Version, m.Name, err)
+		}
+		log.Infof("Applied migration %04d_%s (%v)", m.Version, m.Name, time.Since(start))
+	}
+	return nil
+}
+
+// MigrateDown 回滚版本号大于 target 的迁移（按版本倒序执行 down 脚本）
+func MigrateDown(db *DB, fsys fs.FS, target int) error {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+	byVersion := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	for i := len(applied) - 1; i >= 0; i-- {
+		version := applied[i].Version
+		if version <= target {
+			break
+		}
+		m, ok := byVersion[version]
+		if !ok || strings.TrimSpace(m.Down) == "" {
+			return fmt.Errorf("migration %04d_%s has no down script", version, applied[i].Name)
+		}
+		if err := runMigration(db, m.Down, func(tx *Tx) error {
+			_, err := tx.Exec(`DELETE FROM schema_version WHERE version = ?`, version)
+			return err
+		}); err != nil {
+			return fmt.Errorf("rollback of migration %04d_%s failed: %v", version, m.Name, err)
+		}
+		log.Infof("Rolled back migration %04d_%s", version, m.Name)
+	}
+	return nil
+}
+
+func runMigration(db *DB, script string, record func(tx *Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	// 迁移脚本按方言分目录编写，直接执行不做改写
+	if _, err := tx.Tx.Exec(script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,7 @@
+-- 上游模型列表缓存（按 API 地址 + Key 缓存 /models 响应）
+CREATE TABLE IF NOT EXISTS remote_models_cache (
+	cache_key TEXT PRIMARY KEY,
+	api_url TEXT NOT NULL,
+	models TEXT NOT NULL,
+	fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
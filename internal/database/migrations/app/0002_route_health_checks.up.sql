@@ -0,0 +1,14 @@
+-- 主动健康探测结果（定期向每个启用的路由发送探测请求）
+CREATE TABLE IF NOT EXISTS route_health_checks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	route_id INTEGER NOT NULL,
+	mode TEXT NOT NULL,
+	success INTEGER DEFAULT 0,
+	status_code INTEGER DEFAULT 0,
+	latency_ms INTEGER DEFAULT 0,
+	error_message TEXT,
+	checked_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_route_health_checks_route_id ON route_health_checks(route_id, id);
+CREATE INDEX IF NOT EXISTS idx_route_health_checks_checked_at ON route_health_checks(checked_at);
@@ -0,0 +1,2 @@
+ALTER TABLE model_routes DROP COLUMN extra_headers;
+ALTER TABLE model_routes DROP COLUMN extra_query;
@@ -0,0 +1,3 @@
+-- 路由自定义请求头和查询参数（JSON 对象）
+ALTER TABLE model_routes ADD COLUMN extra_headers TEXT;
+ALTER TABLE model_routes ADD COLUMN extra_query TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_request_logs_key_hash;
+ALTER TABLE request_logs DROP COLUMN key_hash;
@@ -0,0 +1,4 @@
+-- 请求日志记录使用的上游 Key 指纹（用于多 Key 路由的按 Key 统计）
+ALTER TABLE request_logs ADD COLUMN key_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_request_logs_key_hash ON request_logs(key_hash, created_at);
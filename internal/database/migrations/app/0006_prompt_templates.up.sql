@@ -0,0 +1,13 @@
+-- 系统提示词注入模板（按路由或分组，在格式转换前应用到请求）
+CREATE TABLE IF NOT EXISTS prompt_templates (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	scope TEXT NOT NULL,
+	target TEXT NOT NULL,
+	mode TEXT NOT NULL DEFAULT 'prepend',
+	content TEXT,
+	enabled INTEGER DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_templates_scope_target ON prompt_templates(scope, target);
@@ -0,0 +1,31 @@
+-- 流量镜像：按模型将请求副本异步发送到影子路由，对比延迟、token 和响应差异
+CREATE TABLE IF NOT EXISTS mirror_rules (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	model TEXT NOT NULL,
+	shadow_route_id INTEGER NOT NULL,
+	sample_percent INTEGER DEFAULT 100,
+	enabled INTEGER DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mirror_rules_model ON mirror_rules(model);
+
+CREATE TABLE IF NOT EXISTS mirror_results (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	request_id TEXT,
+	model TEXT NOT NULL,
+	shadow_route_id INTEGER NOT NULL,
+	primary_status INTEGER DEFAULT 0,
+	primary_latency_ms INTEGER DEFAULT 0,
+	primary_tokens INTEGER DEFAULT 0,
+	shadow_status INTEGER DEFAULT 0,
+	shadow_latency_ms INTEGER DEFAULT 0,
+	shadow_tokens INTEGER DEFAULT 0,
+	similarity REAL DEFAULT 0,
+	error_message TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mirror_results_model ON mirror_results(model, id);
+CREATE INDEX IF NOT EXISTS idx_mirror_results_created_at ON mirror_results(created_at);
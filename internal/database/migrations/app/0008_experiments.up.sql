@@ -0,0 +1,14 @@
+-- A/B 实验：时间窗口内按权重将模型的请求分配到不同路由（arms 为 JSON 数组）
+CREATE TABLE IF NOT EXISTS experiments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	model TEXT NOT NULL,
+	arms TEXT NOT NULL,
+	start_at DATETIME NOT NULL,
+	end_at DATETIME NOT NULL,
+	enabled INTEGER DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiments_model ON experiments(model, start_at);
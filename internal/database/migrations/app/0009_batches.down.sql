@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS batch_results;
+DROP TABLE IF EXISTS batches;
+DROP TABLE IF EXISTS batch_files;
@@ -0,0 +1,46 @@
+-- 批处理（兼容 OpenAI Batch API）：上传的 JSONL 文件、批处理任务和逐行执行结果，时间为 Unix 秒
+CREATE TABLE IF NOT EXISTS batch_files (
+	id TEXT PRIMARY KEY,
+	purpose TEXT NOT NULL,
+	filename TEXT NOT NULL,
+	bytes INTEGER DEFAULT 0,
+	content TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS batches (
+	id TEXT PRIMARY KEY,
+	endpoint TEXT NOT NULL,
+	input_file_id TEXT NOT NULL,
+	completion_window TEXT NOT NULL,
+	status TEXT NOT NULL,
+	output_file_id TEXT,
+	error_file_id TEXT,
+	errors TEXT,
+	metadata TEXT,
+	total INTEGER DEFAULT 0,
+	completed INTEGER DEFAULT 0,
+	failed INTEGER DEFAULT 0,
+	created_at INTEGER NOT NULL,
+	in_progress_at INTEGER DEFAULT 0,
+	finalizing_at INTEGER DEFAULT 0,
+	completed_at INTEGER DEFAULT 0,
+	failed_at INTEGER DEFAULT 0,
+	expired_at INTEGER DEFAULT 0,
+	cancelling_at INTEGER DEFAULT 0,
+	cancelled_at INTEGER DEFAULT 0,
+	expires_at INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_batches_status ON batches(status);
+
+CREATE TABLE IF NOT EXISTS batch_results (
+	batch_id TEXT NOT NULL,
+	line_index INTEGER NOT NULL,
+	custom_id TEXT NOT NULL,
+	request_id TEXT,
+	status_code INTEGER DEFAULT 0,
+	response TEXT,
+	error_message TEXT,
+	PRIMARY KEY (batch_id, line_index)
+);
@@ -0,0 +1 @@
+ALTER TABLE model_routes DROP COLUMN max_tokens;
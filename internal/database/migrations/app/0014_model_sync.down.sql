@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS model_sync_changes;
+DROP TABLE IF EXISTS model_sync_sources;
@@ -0,0 +1,25 @@
+-- 模型列表自动同步：按计划获取路由所用供应商凭据的模型列表，与目标分组中的路由对比后生成待审批的变更，时间为 Unix 秒
+CREATE TABLE IF NOT EXISTS model_sync_sources (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	route_id INTEGER NOT NULL,
+	target_group TEXT,
+	format TEXT,
+	interval_minutes INTEGER DEFAULT 1440,
+	enabled INTEGER DEFAULT 1,
+	last_sync_at INTEGER DEFAULT 0,
+	last_error TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS model_sync_changes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	source_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	model TEXT NOT NULL,
+	route_id INTEGER DEFAULT 0,
+	status TEXT NOT NULL,
+	detected_at INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_model_sync_changes_source ON model_sync_changes(source_id, status);
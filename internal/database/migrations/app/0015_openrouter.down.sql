@@ -0,0 +1,5 @@
+ALTER TABLE hourly_stats DROP COLUMN reported_response_tokens;
+ALTER TABLE hourly_stats DROP COLUMN reported_request_tokens;
+ALTER TABLE hourly_stats DROP COLUMN reported_cost;
+ALTER TABLE request_logs DROP COLUMN cost;
+ALTER TABLE model_routes DROP COLUMN provider_prefs;
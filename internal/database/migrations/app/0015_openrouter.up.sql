@@ -0,0 +1,7 @@
+-- OpenRouter 集成：路由的供应商偏好（JSON 对象，包含 provider、transforms），上游返回的实际费用（美元）
+-- hourly_stats 中 reported_*_tokens 为有实际费用的请求的 token 数，其余 token 按配置的价格估算
+ALTER TABLE model_routes ADD COLUMN provider_prefs TEXT;
+ALTER TABLE request_logs ADD COLUMN cost REAL DEFAULT 0;
+ALTER TABLE hourly_stats ADD COLUMN reported_cost REAL DEFAULT 0;
+ALTER TABLE hourly_stats ADD COLUMN reported_request_tokens INTEGER DEFAULT 0;
+ALTER TABLE hourly_stats ADD COLUMN reported_response_tokens INTEGER DEFAULT 0;
@@ -0,0 +1,3 @@
+-- 路由每日配额：当天（按配置的时区）token 或请求数达到上限后选择路由时跳过，0 表示不限制
+ALTER TABLE model_routes ADD COLUMN daily_token_limit INTEGER DEFAULT 0;
+ALTER TABLE model_routes ADD COLUMN daily_request_limit INTEGER DEFAULT 0;
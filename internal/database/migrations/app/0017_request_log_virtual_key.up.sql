@@ -0,0 +1,2 @@
+-- 请求使用的虚拟 Key 名称，用于按客户端统计用量；本地 API Key 的请求为空
+ALTER TABLE request_logs ADD COLUMN virtual_key TEXT;
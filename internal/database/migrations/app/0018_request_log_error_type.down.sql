@@ -0,0 +1 @@
+ALTER TABLE request_logs DROP COLUMN error_type;
@@ -0,0 +1,2 @@
+-- 失败请求的错误类型（network、timeout、auth、rate_limit、upstream_5xx 等），用于按类型统计错误率；成功的请求为空
+ALTER TABLE request_logs ADD COLUMN error_type TEXT;
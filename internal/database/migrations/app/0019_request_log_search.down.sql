@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS request_logs_fts_insert;
+DROP TRIGGER IF EXISTS request_logs_fts_delete;
+DROP TRIGGER IF EXISTS request_logs_fts_update;
+DROP TABLE IF EXISTS request_logs_fts;
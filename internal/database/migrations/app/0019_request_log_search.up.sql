@@ -0,0 +1,17 @@
+-- 请求日志错误信息全文索引：FTS5 trigram 分词（按子串匹配，支持中文），由触发器与 request_logs 同步
+CREATE VIRTUAL TABLE IF NOT EXISTS request_logs_fts USING fts5(error_message, content='request_logs', content_rowid='id', tokenize='trigram');
+
+CREATE TRIGGER IF NOT EXISTS request_logs_fts_insert AFTER INSERT ON request_logs BEGIN
+	INSERT INTO request_logs_fts(rowid, error_message) VALUES (new.id, new.error_message);
+END;
+
+CREATE TRIGGER IF NOT EXISTS request_logs_fts_delete AFTER DELETE ON request_logs BEGIN
+	INSERT INTO request_logs_fts(request_logs_fts, rowid, error_message) VALUES ('delete', old.id, old.error_message);
+END;
+
+CREATE TRIGGER IF NOT EXISTS request_logs_fts_update AFTER UPDATE OF error_message ON request_logs BEGIN
+	INSERT INTO request_logs_fts(request_logs_fts, rowid, error_message) VALUES ('delete', old.id, old.error_message);
+	INSERT INTO request_logs_fts(rowid, error_message) VALUES (new.id, new.error_message);
+END;
+
+INSERT INTO request_logs_fts(request_logs_fts) VALUES ('rebuild');
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS route_health_checks;
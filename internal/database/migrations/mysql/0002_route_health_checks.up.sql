@@ -0,0 +1,13 @@
+-- 主动健康探测结果（定期向每个启用的路由发送探测请求）
+CREATE TABLE IF NOT EXISTS route_health_checks (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	route_id BIGINT NOT NULL,
+	mode VARCHAR(32) NOT NULL,
+	success INT DEFAULT 0,
+	status_code INT DEFAULT 0,
+	latency_ms BIGINT DEFAULT 0,
+	error_message TEXT,
+	checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_route_health_checks_route_id (route_id, id),
+	INDEX idx_route_health_checks_checked_at (checked_at)
+) DEFAULT CHARSET = utf8mb4;
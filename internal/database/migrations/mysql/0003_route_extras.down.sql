@@ -0,0 +1 @@
+ALTER TABLE model_routes DROP COLUMN extra_headers, DROP COLUMN extra_query;
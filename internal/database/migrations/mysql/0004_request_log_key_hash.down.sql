@@ -0,0 +1 @@
+ALTER TABLE request_logs DROP INDEX idx_request_logs_key_hash, DROP COLUMN key_hash;
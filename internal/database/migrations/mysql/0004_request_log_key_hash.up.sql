@@ -0,0 +1,2 @@
+-- 请求日志记录使用的上游 Key 指纹（用于多 Key 路由的按 Key 统计）
+ALTER TABLE request_logs ADD COLUMN key_hash VARCHAR(32), ADD INDEX idx_request_logs_key_hash (key_hash, created_at);
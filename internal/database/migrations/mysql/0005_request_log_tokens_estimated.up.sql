@@ -0,0 +1,2 @@
+-- 标记 token 数为本地估算值（上游未返回 usage）
+ALTER TABLE request_logs ADD COLUMN tokens_estimated INT DEFAULT 0;
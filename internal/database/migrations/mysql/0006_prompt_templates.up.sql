@@ -0,0 +1,12 @@
+-- 系统提示词注入模板（按路由或分组，在格式转换前应用到请求）
+CREATE TABLE IF NOT EXISTS prompt_templates (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	scope VARCHAR(16) NOT NULL,
+	target VARCHAR(255) NOT NULL,
+	mode VARCHAR(16) NOT NULL DEFAULT 'prepend',
+	content TEXT,
+	enabled INT DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE INDEX idx_prompt_templates_scope_target (scope, target)
+) DEFAULT CHARSET = utf8mb4;
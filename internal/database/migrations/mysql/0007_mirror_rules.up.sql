@@ -0,0 +1,29 @@
+-- 流量镜像：按模型将请求副本异步发送到影子路由，对比延迟、token 和响应差异
+CREATE TABLE IF NOT EXISTS mirror_rules (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	model VARCHAR(255) NOT NULL,
+	shadow_route_id BIGINT NOT NULL,
+	sample_percent INT DEFAULT 100,
+	enabled INT DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE INDEX idx_mirror_rules_model (model)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS mirror_results (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	request_id VARCHAR(128),
+	model VARCHAR(255) NOT NULL,
+	shadow_route_id BIGINT NOT NULL,
+	primary_status INT DEFAULT 0,
+	primary_latency_ms BIGINT DEFAULT 0,
+	primary_tokens INT DEFAULT 0,
+	shadow_status INT DEFAULT 0,
+	shadow_latency_ms BIGINT DEFAULT 0,
+	shadow_tokens INT DEFAULT 0,
+	similarity DOUBLE DEFAULT 0,
+	error_message TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_mirror_results_model (model, id),
+	INDEX idx_mirror_results_created_at (created_at)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,13 @@
+-- A/B 实验：时间窗口内按权重将模型的请求分配到不同路由（arms 为 JSON 数组）
+CREATE TABLE IF NOT EXISTS experiments (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	model VARCHAR(255) NOT NULL,
+	arms TEXT NOT NULL,
+	start_at DATETIME NOT NULL,
+	end_at DATETIME NOT NULL,
+	enabled INT DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_experiments_model (model, start_at)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,45 @@
+-- 批处理（兼容 OpenAI Batch API）：上传的 JSONL 文件、批处理任务和逐行执行结果，时间为 Unix 秒
+CREATE TABLE IF NOT EXISTS batch_files (
+	id VARCHAR(64) PRIMARY KEY,
+	purpose VARCHAR(64) NOT NULL,
+	filename TEXT NOT NULL,
+	bytes BIGINT DEFAULT 0,
+	content LONGTEXT NOT NULL,
+	created_at BIGINT NOT NULL
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS batches (
+	id VARCHAR(64) PRIMARY KEY,
+	endpoint TEXT NOT NULL,
+	input_file_id TEXT NOT NULL,
+	completion_window TEXT NOT NULL,
+	status VARCHAR(32) NOT NULL,
+	output_file_id TEXT,
+	error_file_id TEXT,
+	errors TEXT,
+	metadata TEXT,
+	total BIGINT DEFAULT 0,
+	completed BIGINT DEFAULT 0,
+	failed BIGINT DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	in_progress_at BIGINT DEFAULT 0,
+	finalizing_at BIGINT DEFAULT 0,
+	completed_at BIGINT DEFAULT 0,
+	failed_at BIGINT DEFAULT 0,
+	expired_at BIGINT DEFAULT 0,
+	cancelling_at BIGINT DEFAULT 0,
+	cancelled_at BIGINT DEFAULT 0,
+	expires_at BIGINT DEFAULT 0,
+	INDEX idx_batches_status (status)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS batch_results (
+	batch_id VARCHAR(64) NOT NULL,
+	line_index BIGINT NOT NULL,
+	custom_id TEXT NOT NULL,
+	request_id TEXT,
+	status_code INT DEFAULT 0,
+	response MEDIUMTEXT,
+	error_message TEXT,
+	PRIMARY KEY (batch_id, line_index)
+) DEFAULT CHARSET = utf8mb4;
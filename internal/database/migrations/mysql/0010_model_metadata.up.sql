@@ -0,0 +1,14 @@
+-- 模型元数据（显示名、上下文长度、最大输出、能力），在模型列表接口中返回
+CREATE TABLE IF NOT EXISTS model_metadata (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	model VARCHAR(255) NOT NULL,
+	display_name VARCHAR(255),
+	description TEXT,
+	context_window INT DEFAULT 0,
+	max_output_tokens INT DEFAULT 0,
+	capabilities VARCHAR(255),
+	hidden INT DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE INDEX idx_model_metadata_model (model)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,8 @@
+-- 路由优先级：Fallback 先按分组优先级（主用 → 备用 → 应急），再按分组内路由优先级排序，数字越小越先尝试
+ALTER TABLE model_routes ADD COLUMN priority INT DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS route_groups (
+	name VARCHAR(255) PRIMARY KEY,
+	priority INT DEFAULT 0,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) DEFAULT CHARSET = utf8mb4;
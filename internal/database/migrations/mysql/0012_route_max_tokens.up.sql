@@ -0,0 +1,2 @@
+-- 路由 max_tokens 上限：发往该路由的请求最多生成这么多 token，0 表示不限制
+ALTER TABLE model_routes ADD COLUMN max_tokens INT DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE model_routes DROP COLUMN upstream_model;
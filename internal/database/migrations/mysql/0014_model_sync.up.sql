@@ -0,0 +1,24 @@
+-- 模型列表自动同步：按计划获取路由所用供应商凭据的模型列表，与目标分组中的路由对比后生成待审批的变更，时间为 Unix 秒
+CREATE TABLE IF NOT EXISTS model_sync_sources (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	route_id BIGINT NOT NULL,
+	target_group VARCHAR(255),
+	format VARCHAR(32),
+	interval_minutes INT DEFAULT 1440,
+	enabled INT DEFAULT 1,
+	last_sync_at BIGINT DEFAULT 0,
+	last_error TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS model_sync_changes (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	source_id BIGINT NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	model VARCHAR(255) NOT NULL,
+	route_id BIGINT DEFAULT 0,
+	status VARCHAR(16) NOT NULL,
+	detected_at BIGINT DEFAULT 0,
+	INDEX idx_model_sync_changes_source (source_id, status)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE hourly_stats DROP COLUMN reported_cost, DROP COLUMN reported_request_tokens, DROP COLUMN reported_response_tokens;
+ALTER TABLE request_logs DROP COLUMN cost;
+ALTER TABLE model_routes DROP COLUMN provider_prefs;
@@ -0,0 +1 @@
+ALTER TABLE model_routes DROP COLUMN daily_request_limit, DROP COLUMN daily_token_limit;
@@ -0,0 +1 @@
+ALTER TABLE request_logs DROP INDEX ft_request_logs_error_message;
@@ -0,0 +1,2 @@
+-- 请求日志错误信息全文索引，搜索时使用 MATCH ... AGAINST
+ALTER TABLE request_logs ADD FULLTEXT INDEX ft_request_logs_error_message (error_message);
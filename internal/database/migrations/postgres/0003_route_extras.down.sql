@@ -0,0 +1 @@
+ALTER TABLE model_routes DROP COLUMN IF EXISTS extra_headers, DROP COLUMN IF EXISTS extra_query;
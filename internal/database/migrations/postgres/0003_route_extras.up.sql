@@ -0,0 +1,2 @@
+-- 路由自定义请求头和查询参数（JSON 对象）
+ALTER TABLE model_routes ADD COLUMN IF NOT EXISTS extra_headers TEXT, ADD COLUMN IF NOT EXISTS extra_query TEXT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS mirror_results;
+DROP TABLE IF EXISTS mirror_rules;
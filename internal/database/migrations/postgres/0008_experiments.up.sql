@@ -0,0 +1,14 @@
+-- A/B 实验：时间窗口内按权重将模型的请求分配到不同路由（arms 为 JSON 数组）
+CREATE TABLE IF NOT EXISTS experiments (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	model TEXT NOT NULL,
+	arms TEXT NOT NULL,
+	start_at TIMESTAMPTZ NOT NULL,
+	end_at TIMESTAMPTZ NOT NULL,
+	enabled INTEGER DEFAULT 1,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiments_model ON experiments(model, start_at);
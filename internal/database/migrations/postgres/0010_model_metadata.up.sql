@@ -0,0 +1,15 @@
+-- 模型元数据（显示名、上下文长度、最大输出、能力），在模型列表接口中返回
+CREATE TABLE IF NOT EXISTS model_metadata (
+	id BIGSERIAL PRIMARY KEY,
+	model TEXT NOT NULL,
+	display_name TEXT,
+	description TEXT,
+	context_window INTEGER DEFAULT 0,
+	max_output_tokens INTEGER DEFAULT 0,
+	capabilities TEXT,
+	hidden INTEGER DEFAULT 0,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_model_metadata_model ON model_metadata(model);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS route_groups;
+ALTER TABLE model_routes DROP COLUMN IF EXISTS priority;
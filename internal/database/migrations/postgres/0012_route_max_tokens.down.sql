@@ -0,0 +1 @@
+ALTER TABLE model_routes DROP COLUMN IF EXISTS max_tokens;
@@ -0,0 +1 @@
+ALTER TABLE model_routes DROP COLUMN IF EXISTS upstream_model;
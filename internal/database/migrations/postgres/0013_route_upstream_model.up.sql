@@ -0,0 +1,2 @@
+-- 路由的上游模型名：发往上游时替换请求中的模型名，为空时使用客户端请求的模型名
+ALTER TABLE model_routes ADD COLUMN IF NOT EXISTS upstream_model TEXT;
@@ -0,0 +1,25 @@
+-- 模型列表自动同步：按计划获取路由所用供应商凭据的模型列表，与目标分组中的路由对比后生成待审批的变更，时间为 Unix 秒
+CREATE TABLE IF NOT EXISTS model_sync_sources (
+	id BIGSERIAL PRIMARY KEY,
+	route_id BIGINT NOT NULL,
+	target_group TEXT,
+	format TEXT,
+	interval_minutes INTEGER DEFAULT 1440,
+	enabled INTEGER DEFAULT 1,
+	last_sync_at BIGINT DEFAULT 0,
+	last_error TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS model_sync_changes (
+	id BIGSERIAL PRIMARY KEY,
+	source_id BIGINT NOT NULL,
+	action TEXT NOT NULL,
+	model TEXT NOT NULL,
+	route_id BIGINT DEFAULT 0,
+	status TEXT NOT NULL,
+	detected_at BIGINT DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_model_sync_changes_source ON model_sync_changes(source_id, status);
@@ -0,0 +1,5 @@
+ALTER TABLE hourly_stats DROP COLUMN IF EXISTS reported_response_tokens;
+ALTER TABLE hourly_stats DROP COLUMN IF EXISTS reported_request_tokens;
+ALTER TABLE hourly_stats DROP COLUMN IF EXISTS reported_cost;
+ALTER TABLE request_logs DROP COLUMN IF EXISTS cost;
+ALTER TABLE model_routes DROP COLUMN IF EXISTS provider_prefs;
@@ -0,0 +1,2 @@
+ALTER TABLE model_routes DROP COLUMN IF EXISTS daily_request_limit;
+ALTER TABLE model_routes DROP COLUMN IF EXISTS daily_token_limit;
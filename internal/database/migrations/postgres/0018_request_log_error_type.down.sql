@@ -0,0 +1 @@
+ALTER TABLE request_logs DROP COLUMN IF EXISTS error_type;
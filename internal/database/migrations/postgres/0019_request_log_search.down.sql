@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_request_logs_error_fts;
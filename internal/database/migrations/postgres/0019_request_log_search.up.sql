@@ -0,0 +1,2 @@
+-- 请求日志错误信息全文索引，搜索时使用 to_tsvector('simple', ...) @@ plainto_tsquery
+CREATE INDEX IF NOT EXISTS idx_request_logs_error_fts ON request_logs USING GIN (to_tsvector('simple', COALESCE(error_message, '')));
@@ -0,0 +1,78 @@
+-- MySQL 基础表结构，与 SQLite 的 createTables + migrateDB 结果一致
+-- 需要建索引的文本字段使用 VARCHAR；布尔字段沿用整数存储
+CREATE TABLE IF NOT EXISTS model_routes (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	model VARCHAR(255) NOT NULL,
+	api_url TEXT NOT NULL,
+	api_key TEXT,
+	`group` VARCHAR(255),
+	format VARCHAR(32) DEFAULT 'openai',
+	enabled INT DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_model_routes_model (model),
+	INDEX idx_model_routes_enabled (enabled),
+	INDEX idx_model_routes_group (`group`)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS request_logs (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	model VARCHAR(255) NOT NULL,
+	provider_model VARCHAR(255),
+	provider_name VARCHAR(255),
+	route_id BIGINT,
+	request_tokens INT DEFAULT 0,
+	response_tokens INT DEFAULT 0,
+	total_tokens INT DEFAULT 0,
+	success INT DEFAULT 1,
+	error_message TEXT,
+	style VARCHAR(32),
+	user_agent VARCHAR(512),
+	remote_ip VARCHAR(64),
+	proxy_time_ms BIGINT DEFAULT 0,
+	first_chunk_ms BIGINT DEFAULT 0,
+	is_stream INT DEFAULT 0,
+	request_id VARCHAR(128),
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_request_logs_model (model),
+	INDEX idx_request_logs_route_id (route_id),
+	INDEX idx_request_logs_created_at (created_at),
+	INDEX idx_request_logs_success (success),
+	INDEX idx_request_logs_provider_name (provider_name),
+	INDEX idx_request_logs_style (style),
+	INDEX idx_request_logs_user_agent (user_agent),
+	INDEX idx_request_logs_request_id (request_id)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS hourly_stats (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	date VARCHAR(10) NOT NULL,
+	hour INT NOT NULL,
+	model VARCHAR(255) NOT NULL,
+	request_count BIGINT DEFAULT 0,
+	request_tokens BIGINT DEFAULT 0,
+	response_tokens BIGINT DEFAULT 0,
+	total_tokens BIGINT DEFAULT 0,
+	success_count BIGINT DEFAULT 0,
+	fail_count BIGINT DEFAULT 0,
+	UNIQUE KEY uk_hourly_stats (date, hour, model),
+	INDEX idx_hourly_stats_date (date),
+	INDEX idx_hourly_stats_model (model)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS usage_summary (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	period_type VARCHAR(16) NOT NULL,
+	period_key VARCHAR(32) NOT NULL,
+	request_count BIGINT DEFAULT 0,
+	request_tokens BIGINT DEFAULT 0,
+	response_tokens BIGINT DEFAULT 0,
+	total_tokens BIGINT DEFAULT 0,
+	success_count BIGINT DEFAULT 0,
+	fail_count BIGINT DEFAULT 0,
+	updated_at VARCHAR(19),
+	UNIQUE KEY uk_usage_summary (period_type, period_key),
+	INDEX idx_usage_summary_type (period_type),
+	INDEX idx_usage_summary_key (period_key)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,82 @@
+-- PostgreSQL 基础表结构，与 SQLite 的 createTables + migrateDB 结果一致
+-- 布尔字段沿用整数存储；request_logs.created_at 存储本地时间，不带时区
+CREATE TABLE IF NOT EXISTS model_routes (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	model TEXT NOT NULL,
+	api_url TEXT NOT NULL,
+	api_key TEXT,
+	"group" TEXT,
+	format TEXT DEFAULT 'openai',
+	enabled INTEGER DEFAULT 1,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_model_routes_model ON model_routes(model);
+CREATE INDEX IF NOT EXISTS idx_model_routes_enabled ON model_routes(enabled);
+CREATE INDEX IF NOT EXISTS idx_model_routes_group ON model_routes("group");
+
+CREATE TABLE IF NOT EXISTS request_logs (
+	id BIGSERIAL PRIMARY KEY,
+	model TEXT NOT NULL,
+	provider_model TEXT,
+	provider_name TEXT,
+	route_id BIGINT,
+	request_tokens INTEGER DEFAULT 0,
+	response_tokens INTEGER DEFAULT 0,
+	total_tokens INTEGER DEFAULT 0,
+	success INTEGER DEFAULT 1,
+	error_message TEXT,
+	style TEXT,
+	user_agent TEXT,
+	remote_ip TEXT,
+	proxy_time_ms BIGINT DEFAULT 0,
+	first_chunk_ms BIGINT DEFAULT 0,
+	is_stream INTEGER DEFAULT 0,
+	request_id TEXT,
+	created_at TIMESTAMP DEFAULT LOCALTIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_logs_model ON request_logs(model);
+CREATE INDEX IF NOT EXISTS idx_request_logs_route_id ON request_logs(route_id);
+CREATE INDEX IF NOT EXISTS idx_request_logs_created_at ON request_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_request_logs_success ON request_logs(success);
+CREATE INDEX IF NOT EXISTS idx_request_logs_provider_name ON request_logs(provider_name);
+CREATE INDEX IF NOT EXISTS idx_request_logs_style ON request_logs(style);
+CREATE INDEX IF NOT EXISTS idx_request_logs_user_agent ON request_logs(user_agent);
+CREATE INDEX IF NOT EXISTS idx_request_logs_request_id ON request_logs(request_id);
+
+CREATE TABLE IF NOT EXISTS hourly_stats (
+	id BIGSERIAL PRIMARY KEY,
+	date TEXT NOT NULL,
+	hour INTEGER NOT NULL,
+	model TEXT NOT NULL,
+	request_count BIGINT DEFAULT 0,
+	request_tokens BIGINT DEFAULT 0,
+	response_tokens BIGINT DEFAULT 0,
+	total_tokens BIGINT DEFAULT 0,
+	success_count BIGINT DEFAULT 0,
+	fail_count BIGINT DEFAULT 0,
+	UNIQUE(date, hour, model)
+);
+
+CREATE INDEX IF NOT EXISTS idx_hourly_stats_date ON hourly_stats(date);
+CREATE INDEX IF NOT EXISTS idx_hourly_stats_model ON hourly_stats(model);
+
+CREATE TABLE IF NOT EXISTS usage_summary (
+	id BIGSERIAL PRIMARY KEY,
+	period_type TEXT NOT NULL,
+	period_key TEXT NOT NULL,
+	request_count BIGINT DEFAULT 0,
+	request_tokens BIGINT DEFAULT 0,
+	response_tokens BIGINT DEFAULT 0,
+	total_tokens BIGINT DEFAULT 0,
+	success_count BIGINT DEFAULT 0,
+	fail_count BIGINT DEFAULT 0,
+	updated_at TEXT,
+	UNIQUE(period_type, period_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_summary_type ON usage_summary(period_type);
+CREATE INDEX IF NOT EXISTS idx_usage_summary_key ON usage_summary(period_key);
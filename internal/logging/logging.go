@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ParseLevel 解析日志级别，无法识别时返回 info
+func ParseLevel(level string) log.Level {
+	lvl, err := log.ParseLevel(strings.TrimSpace(level))
+	if err != nil {
+		return log.InfoLevel
+	}
+	return lvl
+}
+
+// NewFormatter 创建日志格式化器，format 为 json 时输出结构化日志
+// maxMessageBytes > 0 时截断超长的消息和字段（通常是完整的请求/响应体）
+func NewFormatter(format string, maxMessageBytes int) log.Formatter {
+	var inner log.Formatter
+	if strings.EqualFold(strings.TrimSpace(format), "json") {
+		inner = &log.JSONFormatter{}
+	} else {
+		inner = &log.TextFormatter{FullTimestamp: true}
+	}
+	if maxMessageBytes <= 0 {
+		return inner
+	}
+	return &truncatingFormatter{inner: inner, max: maxMessageBytes}
+}
+
+// Apply 应用日志级别和格式
+func Apply(level, format string, maxMessageBytes int) {
+	log.SetLevel(ParseLevel(level))
+	log.SetFormatter(NewFormatter(format, maxMessageBytes))
+}
+
+// truncatingFormatter 截断超过阈值的消息和字符串字段，避免大段请求/响应体写入日志
+type truncatingFormatter struct {
+	inner log.Formatter
+	max   int
+}
+
+func (f *truncatingFormatter) Format(entry *log.Entry) ([]byte, error) {
+	truncated := false
+	message := entry.Message
+	if len(message) > f.max {
+		message = truncate(message, f.max)
+		truncated = true
+	}
+	var data log.Fields
+	for k, v := range entry.Data {
+		s, ok := v.(string)
+		if !ok || len(s) <= f.max {
+			continue
+		}
+		if data == nil {
+			data = make(log.Fields, len(entry.Data))
+			for k2, v2 := range entry.Data {
+				data[k2] = v2
+			}
+		}
+		data[k] = truncate(s, f.max)
+		truncated = true
+	}
+	if !truncated {
+		return f.inner.Format(entry)
+	}
+
+	// 复制 entry，不修改调用方和其他 hook 看到的内容
+	clone := *entry
+	clone.Message = message
+	if data != nil {
+		clone.Data = data
+	}
+	return f.inner.Format(&clone)
+}
+
+func truncate(s string, max int) string {
+	cut := max
+	// 不截断在 UTF-8 字符中间
+	for cut > 0 && cut < len(s) && s[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return fmt.Sprintf("%s...[truncated %d bytes]", s[:cut], len(s)-cut)
+}
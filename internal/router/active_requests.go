@@ -0,0 +1,16 @@
+package router
+
+import (
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// activeRequests 在活动请求监视器中登记进行中的代理请求，请求处理结束时移除
+func activeRequests(proxyService *service.ProxyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := proxyService.TrackRequest(c.GetString("request_id"), c.Request.Method, c.Request.URL.Path, c.ClientIP(), c.Writer.Size)
+		defer done()
+		c.Next()
+	}
+}
@@ -0,0 +1,154 @@
+package router
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strings"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExportPassphraseHeader 加密导出/导入 API Key 使用的密码（不放在查询参数中，避免写入访问日志）
+const ExportPassphraseHeader = "X-Export-Passphrase"
+
+// adminError 管理接口的错误响应
+func adminError(c *gin.Context, statusCode int, errType string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": gin.H{
+			"message":    message,
+			"type":       errType,
+			"request_id": c.GetString("request_id"),
+		},
+	})
+	c.Abort()
+}
+
+// adminAuth 管理接口只允许本地 API Key 访问（Authorization: Bearer 或 x-api-key）
+// 未配置本地 API Key 或使用虚拟 Key 时拒绝
+func adminAuth(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		localAPIKey := cfg.AuthKey()
+		if localAPIKey == "" {
+			adminError(c, http.StatusForbidden, "permission_error", "Admin API requires a local API key")
+			return
+		}
+		apiKey := c.GetHeader("x-api-key")
+		if auth := c.GetHeader("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			apiKey = auth[7:]
+		}
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(localAPIKey)) == 1 {
+			c.Next()
+			return
+		}
+		if vk := cfg.FindVirtualKey(apiKey); vk != nil {
+			adminError(c, http.StatusForbidden, "permission_error", "Admin API is not allowed for virtual key "+vk.Name)
+			return
+		}
+		log.Warnf("Invalid admin API key from %s, path: %s", c.ClientIP(), c.Request.URL.Path)
+		adminError(c, http.StatusUnauthorized, "invalid_api_key", "Invalid API key. Please check your API key and try again.")
+	}
+}
+
+// registerAdminRoutes 注册 /api/admin 管理接口（不经过代理请求的中间件）
+func registerAdminRoutes(admin *gin.RouterGroup, cfg *config.Config, routeService *service.RouteService, proxyService *service.ProxyService) {
+	// 导出路由配置包：?format=json|yaml&keys=plain|exclude|encrypted
+	admin.GET("/routes/export", func(c *gin.Context) {
+		opts := service.RouteExportOptions{
+			Format:     c.DefaultQuery("format", "json"),
+			KeyMode:    c.DefaultQuery("keys", service.BundleKeysPlain),
+			Passphrase: c.GetHeader(ExportPassphraseHeader),
+		}
+		data, err := routeService.ExportRoutes(cfg, opts)
+		if err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		contentType, ext := "application/json", "json"
+		if f := strings.ToLower(opts.Format); f == "yaml" || f == "yml" {
+			contentType, ext = "application/yaml", "yaml"
+		}
+		c.Header("Content-Disposition", "attachment; filename=anyproxy-routes."+ext)
+		c.Data(http.StatusOK, contentType, data)
+	})
+
+	// 导出请求日志：?format=csv|json&start=&end=&columns=id,model,...&model=&provider=&style=&success=&q=，逐行写入响应
+	admin.GET("/logs/export", func(c *gin.Context) {
+		opts := service.RequestLogExportOptions{
+			DateFrom: c.Query("start"),
+			DateTo:   c.Query("end"),
+			Format:   c.Query("format"),
+			Filters: map[string]string{
+				"model":         c.Query("model"),
+				"provider_name": c.Query("provider"),
+				"style":         c.Query("style"),
+				"success":       c.Query("success"),
+				"search":        c.Query("q"),
+			},
+		}
+		if columns := c.Query("columns"); columns != "" {
+			opts.Columns = strings.Split(columns, ",")
+		}
+		if err := opts.Normalize(); err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		contentType := "text/csv"
+		if opts.Format == service.LogExportJSON {
+			contentType = "application/json"
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+opts.Filename()+`"`)
+		c.Header("Content-Type", contentType+"; charset=utf-8")
+		c.Status(http.StatusOK)
+		// 响应头已发送，出错时只能记录日志
+		if _, err := routeService.ExportRequestLogs(c.Writer, opts, cfg); err != nil {
+			log.Errorf("Failed to export request logs: %v", err)
+		}
+	})
+
+	// 导入路由配置包（请求体为 JSON 或 YAML）
+	admin.POST("/routes/import", func(c *gin.Context) {
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", "Failed to read request body")
+			return
+		}
+		result, err := routeService.ImportRoutes(cfg, data, c.GetHeader(ExportPassphraseHeader))
+		if err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
+	// 路由预演：请求体与代理接口相同，返回格式检测、路由选择、适配器和上游请求，不调用上游
+	// ?endpoint=openai|cursor|claude|claudecode|gemini，省略时按请求体自动检测；支持 X-AnyProxy-Route-ID / X-AnyProxy-Provider
+	admin.POST("/explain", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", "Failed to read request body")
+			return
+		}
+		headers := make(map[string]string)
+		for key, values := range c.Request.Header {
+			if len(values) > 0 {
+				headers[key] = values[0]
+			}
+		}
+		// 管理接口的认证头是本地 API Key，不能当作客户端凭据透传给上游
+		delete(headers, "Authorization")
+		delete(headers, "X-Api-Key")
+		headers["X-Real-IP"] = c.ClientIP()
+
+		result, statusCode, err := proxyService.ExplainRequest(c.Query("endpoint"), body, headers)
+		if err != nil {
+			adminError(c, statusCode, "invalid_request_error", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+}
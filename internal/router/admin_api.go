@@ -0,0 +1,392 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"openai-router-go/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 管理 REST 接口：与桌面端/网页管理界面调用相同的 AppService 方法，供脚本和 CI 管理代理
+// 认证与 /api/admin 其它接口相同，只允许本地 API Key
+
+// adminRoute 新增/修改路由的请求体，修改时未提供的字段保持不变
+type adminRoute struct {
+	Name          *string           `json:"name"`
+	Model         *string           `json:"model"`
+	APIUrl        *string           `json:"api_url"`
+	APIKey        *string           `json:"api_key"`
+	Group         *string           `json:"group"`
+	Format        *string           `json:"format"`
+	Enabled       *bool             `json:"enabled"`
+	Priority      *int              `json:"priority"`
+	MaxTokens     *int              `json:"max_tokens"`
+	UpstreamModel *string           `json:"upstream_model"`
+	ProviderPrefs *string           `json:"provider_prefs"`
+	ExtraHeaders  map[string]string `json:"extra_headers"`
+	ExtraQuery    map[string]string `json:"extra_query"`
+
+	DailyTokenLimit   *int64 `json:"daily_token_limit"`
+	DailyRequestLimit *int   `json:"daily_request_limit"`
+}
+
+// adminRouteInfo 路由列表中的一项（只取需要的字段）
+type adminRouteInfo struct {
+	ID           int64             `json:"id"`
+	Name         string            `json:"name"`
+	Model        string            `json:"model"`
+	APIUrl       string            `json:"api_url"`
+	APIKey       string            `json:"api_key"`
+	Group        string            `json:"group"`
+	Format       string            `json:"format"`
+	Enabled      bool              `json:"enabled"`
+	ExtraHeaders map[string]string `json:"extra_headers"`
+	ExtraQuery   map[string]string `json:"extra_query"`
+
+	DailyTokenLimit   int64 `json:"daily_token_limit"`
+	DailyRequestLimit int   `json:"daily_request_limit"`
+}
+
+func stringOr(v *string, def string) string {
+	if v != nil {
+		return *v
+	}
+	return def
+}
+
+// adminCall 调用 AppService 方法，返回 JSON 结果；出错时写入错误响应并返回 false
+func adminCall(c *gin.Context, invoke AdminInvoker, method string, args ...interface{}) (interface{}, bool) {
+	raw := make([]json.RawMessage, len(args))
+	for i, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return nil, false
+		}
+		raw[i] = data
+	}
+	result, err := invoke(method, raw)
+	if err != nil {
+		adminError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return nil, false
+	}
+	return result, true
+}
+
+// adminRespond 调用 AppService 方法并写出结果，没有返回值的方法返回 {"success": true}
+func adminRespond(c *gin.Context, invoke AdminInvoker, method string, args ...interface{}) {
+	result, ok := adminCall(c, invoke, method, args...)
+	if !ok {
+		return
+	}
+	if result == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// adminRoutes 读取路由列表，reveal 为 true 时包含完整 API Key
+func adminRoutes(c *gin.Context, invoke AdminInvoker, reveal bool) ([]adminRouteInfo, bool) {
+	method := "GetRoutes"
+	if reveal {
+		method = "GetRoutesWithKeys"
+	}
+	result, ok := adminCall(c, invoke, method)
+	if !ok {
+		return nil, false
+	}
+	data, _ := json.Marshal(result)
+	var routes []adminRouteInfo
+	if err := json.Unmarshal(data, &routes); err != nil {
+		adminError(c, http.StatusInternalServerError, "api_error", err.Error())
+		return nil, false
+	}
+	return routes, true
+}
+
+// adminRouteID 解析路径中的路由 ID 并确认路由存在
+func adminRouteID(c *gin.Context, invoke AdminInvoker, reveal bool) (*adminRouteInfo, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		adminError(c, http.StatusBadRequest, "invalid_request_error", "invalid route id: "+c.Param("id"))
+		return nil, false
+	}
+	routes, ok := adminRoutes(c, invoke, reveal)
+	if !ok {
+		return nil, false
+	}
+	for i := range routes {
+		if routes[i].ID == id {
+			return &routes[i], true
+		}
+	}
+	adminError(c, http.StatusNotFound, "not_found_error", "route not found: "+c.Param("id"))
+	return nil, false
+}
+
+// applyRouteOptions 写入路由的启用状态、优先级、max_tokens 上限、上游模型名、OpenRouter 供应商偏好、每日配额和自定义请求头/查询参数
+func applyRouteOptions(c *gin.Context, invoke AdminInvoker, id int64, req adminRoute, current adminRouteInfo) bool {
+	if req.Enabled != nil && *req.Enabled != current.Enabled {
+		if _, ok := adminCall(c, invoke, "ToggleRoute", id, *req.Enabled); !ok {
+			return false
+		}
+	}
+	if req.Priority != nil {
+		if _, ok := adminCall(c, invoke, "SetRoutePriority", id, *req.Priority); !ok {
+			return false
+		}
+	}
+	if req.MaxTokens != nil {
+		if _, ok := adminCall(c, invoke, "SetRouteMaxTokens", id, *req.MaxTokens); !ok {
+			return false
+		}
+	}
+	if req.UpstreamModel != nil {
+		if _, ok := adminCall(c, invoke, "SetRouteUpstreamModel", id, *req.UpstreamModel); !ok {
+			return false
+		}
+	}
+	if req.ProviderPrefs != nil {
+		if _, ok := adminCall(c, invoke, "SetRouteProviderPrefs", id, *req.ProviderPrefs); !ok {
+			return false
+		}
+	}
+	if req.DailyTokenLimit != nil || req.DailyRequestLimit != nil {
+		tokens, requests := current.DailyTokenLimit, current.DailyRequestLimit
+		if req.DailyTokenLimit != nil {
+			tokens = *req.DailyTokenLimit
+		}
+		if req.DailyRequestLimit != nil {
+			requests = *req.DailyRequestLimit
+		}
+		if _, ok := adminCall(c, invoke, "SetRouteQuota", id, tokens, requests); !ok {
+			return false
+		}
+	}
+	if req.ExtraHeaders != nil || req.ExtraQuery != nil {
+		headers, query := current.ExtraHeaders, current.ExtraQuery
+		if req.ExtraHeaders != nil {
+			headers = req.ExtraHeaders
+		}
+		if req.ExtraQuery != nil {
+			query = req.ExtraQuery
+		}
+		if _, ok := adminCall(c, invoke, "SetRouteExtras", id, headers, query); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// RegisterAdminAPI 注册 /api/admin 下的路由、配置、统计、日志、Traces、健康状态和运行指标接口
+func RegisterAdminAPI(r *gin.Engine, cfg *config.Config, invoke AdminInvoker) {
+	admin := r.Group("/api/admin", adminAuth(cfg))
+
+	// 路由：?reveal=true 返回完整 API Key
+	admin.GET("/routes", func(c *gin.Context) {
+		if routes, ok := adminRoutes(c, invoke, c.Query("reveal") == "true"); ok {
+			c.JSON(http.StatusOK, routes)
+		}
+	})
+	admin.GET("/routes/:id", func(c *gin.Context) {
+		if route, ok := adminRouteID(c, invoke, c.Query("reveal") == "true"); ok {
+			c.JSON(http.StatusOK, route)
+		}
+	})
+	admin.POST("/routes", func(c *gin.Context) {
+		var req adminRoute
+		if err := c.ShouldBindJSON(&req); err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		if stringOr(req.Model, "") == "" || stringOr(req.APIUrl, "") == "" {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", "model and api_url are required")
+			return
+		}
+		name, model, apiURL := stringOr(req.Name, *req.Model), *req.Model, *req.APIUrl
+		if _, ok := adminCall(c, invoke, "AddRoute", name, model, apiURL,
+			stringOr(req.APIKey, ""), stringOr(req.Group, ""), stringOr(req.Format, "openai")); !ok {
+			return
+		}
+		// 新路由为名称、模型、地址相同的路由中 ID 最大的一条
+		routes, ok := adminRoutes(c, invoke, false)
+		if !ok {
+			return
+		}
+		var created *adminRouteInfo
+		for i := range routes {
+			r := &routes[i]
+			if r.Name == name && r.Model == model && r.APIUrl == apiURL && (created == nil || r.ID > created.ID) {
+				created = r
+			}
+		}
+		if created == nil {
+			adminError(c, http.StatusInternalServerError, "api_error", "route created but not found")
+			return
+		}
+		if !applyRouteOptions(c, invoke, created.ID, req, *created) {
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": created.ID})
+	})
+	admin.PUT("/routes/:id", func(c *gin.Context) {
+		var req adminRoute
+		if err := c.ShouldBindJSON(&req); err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		current, ok := adminRouteID(c, invoke, true)
+		if !ok {
+			return
+		}
+		if _, ok := adminCall(c, invoke, "UpdateRoute", current.ID,
+			stringOr(req.Name, current.Name), stringOr(req.Model, current.Model), stringOr(req.APIUrl, current.APIUrl),
+			stringOr(req.APIKey, current.APIKey), stringOr(req.Group, current.Group), stringOr(req.Format, current.Format)); !ok {
+			return
+		}
+		if !applyRouteOptions(c, invoke, current.ID, req, *current) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+	admin.POST("/routes/:id/test", func(c *gin.Context) {
+		if route, ok := adminRouteID(c, invoke, false); ok {
+			adminRespond(c, invoke, "TestRoute", route.ID)
+		}
+	})
+	admin.DELETE("/routes/:id", func(c *gin.Context) {
+		if route, ok := adminRouteID(c, invoke, false); ok {
+			adminRespond(c, invoke, "DeleteRoute", route.ID)
+		}
+	})
+
+	// 配置：GET 返回常用设置；PATCH 的请求体为 config.json 中的部分字段，写入后立即应用
+	admin.GET("/config", func(c *gin.Context) {
+		adminRespond(c, invoke, "GetConfig")
+	})
+	admin.PATCH("/config", func(c *gin.Context) {
+		var fields map[string]interface{}
+		if err := c.ShouldBindJSON(&fields); err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		adminRespond(c, invoke, "PatchConfig", fields)
+	})
+
+	// 统计
+	admin.GET("/stats", func(c *gin.Context) {
+		adminRespond(c, invoke, "GetStats")
+	})
+	admin.GET("/stats/daily", func(c *gin.Context) {
+		days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+		adminRespond(c, invoke, "GetDailyStats", days)
+	})
+	admin.GET("/stats/hourly", func(c *gin.Context) {
+		adminRespond(c, invoke, "GetHourlyStats")
+	})
+	admin.GET("/stats/models", func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		adminRespond(c, invoke, "GetModelRanking", limit)
+	})
+	admin.GET("/stats/breakdown", func(c *gin.Context) {
+		days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+		adminRespond(c, invoke, "GetStatsBreakdown", c.DefaultQuery("dimension", "provider"), days)
+	})
+	admin.GET("/stats/latency", func(c *gin.Context) {
+		hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+		adminRespond(c, invoke, "GetLatencyStats", hours)
+	})
+	admin.GET("/stats/errors", func(c *gin.Context) {
+		hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+		adminRespond(c, invoke, "GetErrorStats", hours)
+	})
+	admin.GET("/stats/usage", func(c *gin.Context) {
+		adminRespond(c, invoke, "GetUsageSummary")
+	})
+
+	// 进行中的请求
+	admin.GET("/requests", func(c *gin.Context) {
+		adminRespond(c, invoke, "GetActiveRequests")
+	})
+	admin.POST("/requests/:id/cancel", func(c *gin.Context) {
+		adminRespond(c, invoke, "CancelActiveRequest", c.Param("id"))
+	})
+
+	// 请求日志：?page=&page_size=&model=&style=&success=&start=&end=&request_id=&q=
+	admin.GET("/logs", func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+		adminRespond(c, invoke, "GetRequestLogs", page, pageSize, c.Query("model"), c.Query("style"),
+			c.Query("success"), c.Query("start"), c.Query("end"), c.Query("request_id"), c.Query("q"))
+	})
+
+	// Traces
+	admin.GET("/traces", func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+		adminRespond(c, invoke, "GetAllTraces", page, pageSize, c.Query("success"), c.Query("start"), c.Query("end"), c.Query("request_id"), c.Query("q"))
+	})
+	admin.GET("/traces/sessions", func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+		adminRespond(c, invoke, "GetTraceSessions", page, pageSize)
+	})
+	admin.GET("/traces/sessions/:session", func(c *gin.Context) {
+		adminRespond(c, invoke, "GetTracesBySession", c.Param("session"))
+	})
+	admin.GET("/traces/:id/conversion", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			adminError(c, http.StatusBadRequest, "invalid_request_error", "invalid trace id: "+c.Param("id"))
+			return
+		}
+		adminRespond(c, invoke, "GetTraceConversion", id)
+	})
+	// 导出：?format=finetune|jsonl|json|csv&session=&start=&end=&success_only=true，直接返回文件内容
+	admin.GET("/traces/export", func(c *gin.Context) {
+		opts := map[string]interface{}{
+			"session_id":   c.Query("session"),
+			"start_time":   c.Query("start"),
+			"end_time":     c.Query("end"),
+			"format":       c.Query("format"),
+			"success_only": c.Query("success_only") == "true",
+		}
+		result, ok := adminCall(c, invoke, "ExportTraces", opts)
+		if !ok {
+			return
+		}
+		data, _ := json.Marshal(result)
+		var export struct {
+			Format   string `json:"format"`
+			Content  string `json:"content"`
+			Filename string `json:"filename"`
+		}
+		json.Unmarshal(data, &export)
+		contentType := "application/x-ndjson"
+		switch export.Format {
+		case "json":
+			contentType = "application/json"
+		case "csv":
+			contentType = "text/csv"
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+export.Filename+`"`)
+		c.Data(http.StatusOK, contentType+"; charset=utf-8", []byte(export.Content))
+	})
+
+	// 健康状态
+	admin.GET("/health", func(c *gin.Context) {
+		adminRespond(c, invoke, "GetHealthStatus")
+	})
+	admin.POST("/health/probe", func(c *gin.Context) {
+		adminRespond(c, invoke, "RunHealthProbeNow")
+	})
+
+	// 运行指标
+	admin.GET("/metrics", func(c *gin.Context) {
+		adminRespond(c, invoke, "GetMetrics")
+	})
+}
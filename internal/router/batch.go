@@ -0,0 +1,154 @@
+package router
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// batchError 返回 OpenAI 格式的错误，资源不存在时为 404
+func batchError(c *gin.Context, statusCode int, err error) {
+	errType := "invalid_request_error"
+	if errors.Is(err, service.ErrBatchNotFound) {
+		statusCode = http.StatusNotFound
+	} else if statusCode >= http.StatusInternalServerError {
+		errType = "internal_error"
+	}
+	c.JSON(statusCode, gin.H{
+		"error": gin.H{
+			"message":    err.Error(),
+			"type":       errType,
+			"request_id": c.GetString("request_id"),
+		},
+	})
+}
+
+// registerBatchRoutes 注册 OpenAI 兼容的 Files 和 Batch 接口
+// 批处理任务在本地后台执行，逐条请求走正常的路由和格式转换，上游无需支持 Batch API
+func registerBatchRoutes(v1 *gin.RouterGroup, routeService *service.RouteService, proxyService *service.ProxyService) {
+	// 上传文件（multipart: file, purpose）
+	v1.POST("/files", func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.BatchFileMaxBytes+1024*1024)
+		purpose := c.PostForm("purpose")
+		if purpose == "" {
+			batchError(c, http.StatusBadRequest, errors.New("'purpose' is required"))
+			return
+		}
+		header, err := c.FormFile("file")
+		if err != nil {
+			batchError(c, http.StatusBadRequest, errors.New("'file' is required"))
+			return
+		}
+		if header.Size > service.BatchFileMaxBytes {
+			batchError(c, http.StatusRequestEntityTooLarge, errors.New("file exceeds the maximum size of 100 MB"))
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			batchError(c, http.StatusBadRequest, err)
+			return
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			batchError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		f, err := routeService.CreateBatchFile(header.Filename, purpose, content)
+		if err != nil {
+			batchError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, f)
+	})
+
+	v1.GET("/files", func(c *gin.Context) {
+		files, err := routeService.GetBatchFiles(c.Query("purpose"))
+		if err != nil {
+			batchError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"object": "list", "data": files})
+	})
+
+	v1.GET("/files/:id", func(c *gin.Context) {
+		f, err := routeService.GetBatchFile(c.Param("id"))
+		if err != nil {
+			batchError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, f)
+	})
+
+	v1.GET("/files/:id/content", func(c *gin.Context) {
+		content, err := routeService.GetBatchFileContent(c.Param("id"))
+		if err != nil {
+			batchError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.Data(http.StatusOK, "application/jsonl", content)
+	})
+
+	v1.DELETE("/files/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		if err := routeService.DeleteBatchFile(id); err != nil {
+			batchError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id, "object": "file", "deleted": true})
+	})
+
+	// 创建批处理任务
+	v1.POST("/batches", func(c *gin.Context) {
+		var req service.BatchCreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			batchError(c, http.StatusBadRequest, err)
+			return
+		}
+		batch, err := proxyService.CreateBatch(req)
+		if err != nil {
+			batchError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, batch)
+	})
+
+	v1.GET("/batches", func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		batches, hasMore, err := routeService.GetBatches(limit, c.Query("after"))
+		if err != nil {
+			batchError(c, http.StatusInternalServerError, err)
+			return
+		}
+		resp := gin.H{"object": "list", "data": batches, "has_more": hasMore}
+		if len(batches) > 0 {
+			resp["first_id"] = batches[0].ID
+			resp["last_id"] = batches[len(batches)-1].ID
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	v1.GET("/batches/:id", func(c *gin.Context) {
+		batch, err := routeService.GetBatch(c.Param("id"))
+		if err != nil {
+			batchError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, batch)
+	})
+
+	v1.POST("/batches/:id/cancel", func(c *gin.Context) {
+		batch, err := proxyService.CancelBatch(c.Param("id"))
+		if err != nil {
+			batchError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, batch)
+	})
+}
@@ -0,0 +1,124 @@
+package router
+
+import (
+	"io"
+	"net/http"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// geminiModelInfo Gemini 格式的模型信息，未配置元数据时使用默认的 token 上限
+func geminiModelInfo(m service.ModelMetadata) gin.H {
+	description := m.Description
+	if description == "" {
+		description = "Model " + m.Model
+	}
+	inputTokenLimit, outputTokenLimit := m.ContextWindow, m.MaxOutputTokens
+	if inputTokenLimit == 0 {
+		inputTokenLimit = 1048576
+	}
+	if outputTokenLimit == 0 {
+		outputTokenLimit = 8192
+	}
+	return gin.H{
+		"name":                       "models/" + m.Model,
+		"version":                    "001",
+		"displayName":                m.Name(),
+		"description":                description,
+		"inputTokenLimit":            inputTokenLimit,
+		"outputTokenLimit":           outputTokenLimit,
+		"supportedGenerationMethods": []string{"generateContent", "streamGenerateContent", "countTokens", "embedContent", "batchEmbedContents"},
+	}
+}
+
+// geminiGetModel 获取单个模型信息（GET models/{model}），param 为路径参数名
+func geminiGetModel(cfg *config.Config, routeService *service.RouteService, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		model, _ := splitGeminiModelAction(c.Param(param))
+
+		models, err := listModels(c, cfg, routeService)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"message":    err.Error(),
+					"type":       "internal_error",
+					"request_id": c.GetString("request_id"),
+				},
+			})
+			return
+		}
+
+		for _, m := range models {
+			if m.Model == model {
+				c.JSON(http.StatusOK, geminiModelInfo(m))
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":       http.StatusNotFound,
+				"message":    "models/" + model + " is not found",
+				"status":     "NOT_FOUND",
+				"request_id": c.GetString("request_id"),
+			},
+		})
+	}
+}
+
+// isGeminiGenerateAction 生成类操作（包括没有操作后缀的旧路径）
+func isGeminiGenerateAction(action string) bool {
+	return action == "" || action == "generateContent" || action == geminiStreamAction
+}
+
+// handleGeminiAction 处理生成以外的官方操作（countTokens、embedContent、batchEmbedContents）
+// 返回 false 表示不是这些操作，由调用方继续处理
+func handleGeminiAction(c *gin.Context, proxyService *service.ProxyService, model, action string) bool {
+	switch action {
+	case "countTokens", "embedContent", "batchEmbedContents":
+	default:
+		return false
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message":    "Failed to read request body",
+				"type":       "invalid_request_error",
+				"request_id": c.GetString("request_id"),
+			},
+		})
+		return true
+	}
+
+	headers := make(map[string]string)
+	for key, values := range c.Request.Header {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+	headers["X-Real-IP"] = c.ClientIP()
+
+	var respBody []byte
+	var statusCode int
+	if action == "countTokens" {
+		respBody, statusCode, err = proxyService.GeminiCountTokens(model, body, headers)
+	} else {
+		respBody, statusCode, err = proxyService.GeminiEmbedContent(model, action, body, headers)
+	}
+	if err != nil {
+		c.JSON(statusCode, gin.H{
+			"error": gin.H{
+				"message":    err.Error(),
+				"type":       "proxy_error",
+				"request_id": c.GetString("request_id"),
+			},
+		})
+		return true
+	}
+	c.Data(statusCode, "application/json", respBody)
+	return true
+}
@@ -0,0 +1,131 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"openai-router-go/internal/service"
+	"openai-router-go/internal/sse"
+
+	"github.com/gin-gonic/gin"
+)
+
+// geminiStreamAction Gemini 官方路径中的流式生成操作
+const geminiStreamAction = "streamGenerateContent"
+
+// splitGeminiModelAction 拆分 {model}:{action}，没有操作时 action 为空
+func splitGeminiModelAction(param string) (model, action string) {
+	if idx := strings.LastIndex(param, ":"); idx >= 0 {
+		return param[:idx], param[idx+1:]
+	}
+	return param, ""
+}
+
+// geminiJSONArray 判断流式响应是否使用 JSON 数组格式
+// alt=sse 时使用 SSE；未指定 alt 时官方 :streamGenerateContent 路径默认返回 JSON 数组，其他路径保持 SSE
+func geminiJSONArray(c *gin.Context, officialPath bool) bool {
+	switch c.Query("alt") {
+	case "sse":
+		return false
+	case "":
+		return officialPath
+	default:
+		return true
+	}
+}
+
+// geminiArrayResponse 包装响应，将服务层输出的 SSE 转换为 JSON 数组
+type geminiArrayResponse struct {
+	gin.ResponseWriter
+	array *sse.JSONArrayWriter
+}
+
+func (w *geminiArrayResponse) Write(data []byte) (int, error) {
+	return w.array.Write(data)
+}
+
+func (w *geminiArrayResponse) WriteString(s string) (int, error) {
+	return w.array.Write([]byte(s))
+}
+
+// Flush JSONArrayWriter 每写完一个元素会自行 flush
+func (w *geminiArrayResponse) Flush() {}
+
+// unwrapGeminiElement 去掉 {"code":200,"data":{...}} 包装，JSON 数组模式按官方格式输出
+func unwrapGeminiElement(data []byte) []byte {
+	var wrapped struct {
+		Code int             `json:"code"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err == nil && wrapped.Code != 0 && len(wrapped.Data) > 0 {
+		return wrapped.Data
+	}
+	return data
+}
+
+// geminiUnwrapSSE 逐行去掉 SSE 事件中的 {"code":200,"data":...} 包装
+type geminiUnwrapSSE struct {
+	gin.ResponseWriter
+	buf []byte
+}
+
+func (w *geminiUnwrapSSE) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := w.buf[:idx+1]
+		if payload, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+			line = append(append([]byte("data: "), unwrapGeminiElement(bytes.TrimSpace(payload))...), '\n')
+		}
+		if _, err := w.ResponseWriter.Write(line); err != nil {
+			return len(data), err
+		}
+		w.buf = w.buf[idx+1:]
+	}
+	return len(data), nil
+}
+
+func (w *geminiUnwrapSSE) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// streamGeminiResponse 处理 Gemini 流式请求，jsonArray 为 true 时以 JSON 数组返回，否则为 SSE
+func streamGeminiResponse(c *gin.Context, proxyService *service.ProxyService, body []byte, headers map[string]string, jsonArray bool) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		service.RequestLogger(c.GetString("request_id")).Errorf("Streaming not supported")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message":    "Streaming not supported",
+				"type":       "internal_error",
+				"request_id": c.GetString("request_id"),
+			},
+		})
+		return
+	}
+
+	if jsonArray {
+		c.Header("Content-Type", "application/json")
+		array := sse.NewJSONArrayWriter(c.Writer, flusher)
+		array.Element = unwrapGeminiElement
+		wrapped := &geminiArrayResponse{ResponseWriter: c.Writer, array: array}
+		c.Writer, flusher = wrapped, wrapped
+		defer array.Close()
+	} else {
+		c.Header("Content-Type", "text/event-stream")
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// 使用 Gemini 专用流式处理，响应会转换为 Gemini 格式
+	if err := proxyService.ProxyGeminiStreamRequest(body, headers, c.Writer, flusher); err != nil {
+		service.RequestLogger(c.GetString("request_id")).Errorf("Gemini stream proxy error: %v", err)
+		sendStreamError(c, flusher, err, "openai")
+	}
+}
@@ -0,0 +1,107 @@
+package router
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"openai-router-go/internal/config"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// ipAllowlist 客户端 IP 白名单，按 allowed_cidrs 配置解析并缓存
+type ipAllowlist struct {
+	mu   sync.Mutex
+	key  string
+	nets []*net.IPNet
+}
+
+// parseCIDRs 解析网段列表，单个 IP 视为 /32 或 /128
+func parseCIDRs(entries []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				log.Warnf("Invalid allowed_cidrs entry ignored: %s", entry)
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Warnf("Invalid allowed_cidrs entry ignored: %s", entry)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// networks 返回当前配置的网段（配置热更新后重新解析）
+func (a *ipAllowlist) networks(entries []string) []*net.IPNet {
+	key := strings.Join(entries, ",")
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if key != a.key || a.nets == nil {
+		a.nets = parseCIDRs(entries)
+		a.key = key
+	}
+	return a.nets
+}
+
+// allowed 判断客户端 IP 是否允许访问，本机回环地址始终允许
+func (a *ipAllowlist) allowed(entries []string, remoteIP string) bool {
+	if len(entries) == 0 {
+		return true
+	}
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, ipNet := range a.networks(entries) {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipFilter IP 白名单中间件
+// 使用 TCP 连接的真实来源地址，不信任 X-Forwarded-For 等可伪造的请求头
+func ipFilter(cfg *config.Config) gin.HandlerFunc {
+	allowlist := &ipAllowlist{}
+	return func(c *gin.Context) {
+		remoteIP := c.RemoteIP()
+		if !allowlist.allowed(cfg.AllowedCIDRs, remoteIP) {
+			log.Warnf("[%s] Rejected request from %s: not in allowed_cidrs (path: %s)",
+				c.GetString("request_id"), remoteIP, c.Request.URL.Path)
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": gin.H{
+					"message":    "client address is not allowed",
+					"type":       "forbidden",
+					"request_id": c.GetString("request_id"),
+				},
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,525 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"openai-router-go/internal/config"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// MCP 服务器：以 Model Context Protocol 提供查询模型、用量统计、添加/测试路由等管理工具，
+// 供 Claude Desktop 等 MCP 客户端通过对话操作代理。工具调用与 /api/admin 相同，转发到 AppService
+//
+//	POST /mcp                 Streamable HTTP：请求体为 JSON-RPC 消息（或批量），直接返回 JSON 响应
+//	GET  /mcp/sse             SSE 传输：先发送 endpoint 事件，响应以 message 事件返回
+//	POST /mcp/message?session_id=  SSE 传输的客户端消息
+//
+// stdio 客户端使用命令行 `mcp` 子命令转发到 /mcp。需要开启 mcp_enabled，认证与管理接口相同（本地 API Key）
+
+const (
+	mcpServerName         = "anyproxyai"
+	mcpSSEKeepAlive       = 30 * time.Second
+	mcpSSEQueueSize       = 16
+	mcpDefaultLogPageSize = 20
+)
+
+// mcpProtocolVersions 支持的协议版本，第一个为默认版本
+var mcpProtocolVersions = []string{"2025-03-26", "2024-11-05", "2025-06-18"}
+
+// JSON-RPC 错误码
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+)
+
+type jsonrpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+}
+
+// mcpTool 一个 MCP 工具：call 的参数为客户端传入的 arguments
+type mcpTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	call        func(invoke AdminInvoker, args map[string]interface{}) (interface{}, error)
+}
+
+// mcpSchema 生成工具参数的 JSON Schema，properties 为 参数名 -> {type, description}
+func mcpSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func mcpProp(typ, description string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "description": description}
+}
+
+// mcpInvoke 调用 AppService 方法
+func mcpInvoke(invoke AdminInvoker, method string, args ...interface{}) (interface{}, error) {
+	raw := make([]json.RawMessage, len(args))
+	for i, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			return nil, err
+		}
+		raw[i] = data
+	}
+	return invoke(method, raw)
+}
+
+// mcpRoutes 读取路由列表（不含完整 API Key）
+func mcpRoutes(invoke AdminInvoker) ([]adminRouteInfo, error) {
+	result, err := mcpInvoke(invoke, "GetRoutes")
+	if err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(result)
+	var routes []adminRouteInfo
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+func argString(args map[string]interface{}, key, def string) string {
+	if v, ok := args[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func argInt(args map[string]interface{}, key string, def int) int {
+	if v, ok := args[key].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+// argRouteID 读取必填的 route_id 参数
+func argRouteID(args map[string]interface{}) (int64, error) {
+	v, ok := args["route_id"].(float64)
+	if !ok || v <= 0 {
+		return 0, fmt.Errorf("route_id is required")
+	}
+	return int64(v), nil
+}
+
+// mcpTools MCP 服务器提供的工具
+var mcpTools = []mcpTool{
+	{
+		Name:        "list_models",
+		Description: "List the model names clients can request, with the routes (upstream providers) serving each model.",
+		InputSchema: mcpSchema(map[string]interface{}{}),
+		call: func(invoke AdminInvoker, args map[string]interface{}) (interface{}, error) {
+			routes, err := mcpRoutes(invoke)
+			if err != nil {
+				return nil, err
+			}
+			type modelRoute struct {
+				ID      int64  `json:"id"`
+				Name    string `json:"name"`
+				Group   string `json:"group"`
+				Format  string `json:"format"`
+				Enabled bool   `json:"enabled"`
+			}
+			models := make(map[string][]modelRoute)
+			for _, r := range routes {
+				models[r.Model] = append(models[r.Model], modelRoute{r.ID, r.Name, r.Group, r.Format, r.Enabled})
+			}
+			names := make([]string, 0, len(models))
+			for name := range models {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			list := make([]map[string]interface{}, 0, len(names))
+			for _, name := range names {
+				list = append(list, map[string]interface{}{"model": name, "routes": models[name]})
+			}
+			return list, nil
+		},
+	},
+	{
+		Name:        "list_routes",
+		Description: "List all configured routes (id, name, model, upstream URL, group, format, enabled). API keys are masked.",
+		InputSchema: mcpSchema(map[string]interface{}{}),
+		call: func(invoke AdminInvoker, args map[string]interface{}) (interface{}, error) {
+			return mcpRoutes(invoke)
+		},
+	},
+	{
+		Name:        "get_usage_stats",
+		Description: "Get request and token usage statistics: totals, today's usage, per-day usage for the last N days and the most used models.",
+		InputSchema: mcpSchema(map[string]interface{}{
+			"days": mcpProp("integer", "Number of days of daily statistics (default 7)"),
+		}),
+		call: func(invoke AdminInvoker, args map[string]interface{}) (interface{}, error) {
+			stats, err := mcpInvoke(invoke, "GetStats")
+			if err != nil {
+				return nil, err
+			}
+			usage, err := mcpInvoke(invoke, "GetUsageSummary")
+			if err != nil {
+				return nil, err
+			}
+			daily, err := mcpInvoke(invoke, "GetDailyStats", argInt(args, "days", 7))
+			if err != nil {
+				return nil, err
+			}
+			ranking, err := mcpInvoke(invoke, "GetModelRanking", 10)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"stats": stats, "usage": usage, "daily": daily, "top_models": ranking}, nil
+		},
+	},
+	{
+		Name:        "get_request_logs",
+		Description: "Get recent request logs, newest first. Optionally filter by model or success, or search error messages.",
+		InputSchema: mcpSchema(map[string]interface{}{
+			"model":     mcpProp("string", "Only logs for this model"),
+			"success":   mcpProp("string", "\"true\" for successful requests, \"false\" for failed requests"),
+			"search":    mcpProp("string", "Full-text search in error messages; space-separated words must all match"),
+			"page_size": mcpProp("integer", "Number of logs to return (default 20)"),
+		}),
+		call: func(invoke AdminInvoker, args map[string]interface{}) (interface{}, error) {
+			return mcpInvoke(invoke, "GetRequestLogs", 1, argInt(args, "page_size", mcpDefaultLogPageSize),
+				argString(args, "model", ""), "", argString(args, "success", ""), "", "", "", argString(args, "search", ""))
+		},
+	},
+	{
+		Name:        "add_route",
+		Description: "Add a route that serves a model from an upstream API. Returns the new route id.",
+		InputSchema: mcpSchema(map[string]interface{}{
+			"name":    mcpProp("string", "Route name (default: the model name)"),
+			"model":   mcpProp("string", "Model name clients request"),
+			"api_url": mcpProp("string", "Upstream API base URL, e.g. https://api.openai.com"),
+			"api_key": mcpProp("string", "Upstream API key"),
+			"group":   mcpProp("string", "Route group"),
+			"format":  mcpProp("string", "Upstream API format: openai, claude or gemini (default openai)"),
+		}, "model", "api_url"),
+		call: func(invoke AdminInvoker, args map[string]interface{}) (interface{}, error) {
+			model, apiURL := argString(args, "model", ""), argString(args, "api_url", "")
+			if model == "" || apiURL == "" {
+				return nil, fmt.Errorf("model and api_url are required")
+			}
+			name := argString(args, "name", model)
+			if _, err := mcpInvoke(invoke, "AddRoute", name, model, apiURL,
+				argString(args, "api_key", ""), argString(args, "group", ""), argString(args, "format", "openai")); err != nil {
+				return nil, err
+			}
+			// 新路由为名称、模型、地址相同的路由中 ID 最大的一条
+			routes, err := mcpRoutes(invoke)
+			if err != nil {
+				return nil, err
+			}
+			var id int64
+			for _, r := range routes {
+				if r.Name == name && r.Model == model && r.APIUrl == apiURL && r.ID > id {
+					id = r.ID
+				}
+			}
+			return map[string]interface{}{"id": id}, nil
+		},
+	},
+	{
+		Name:        "test_route",
+		Description: "Send a test request through a route and report whether the upstream answers, with latency and error details.",
+		InputSchema: mcpSchema(map[string]interface{}{
+			"route_id": mcpProp("integer", "Route id (see list_routes)"),
+		}, "route_id"),
+		call: func(invoke AdminInvoker, args map[string]interface{}) (interface{}, error) {
+			id, err := argRouteID(args)
+			if err != nil {
+				return nil, err
+			}
+			return mcpInvoke(invoke, "TestRoute", id)
+		},
+	},
+	{
+		Name:        "toggle_route",
+		Description: "Enable or disable a route.",
+		InputSchema: mcpSchema(map[string]interface{}{
+			"route_id": mcpProp("integer", "Route id (see list_routes)"),
+			"enabled":  mcpProp("boolean", "true to enable, false to disable"),
+		}, "route_id", "enabled"),
+		call: func(invoke AdminInvoker, args map[string]interface{}) (interface{}, error) {
+			id, err := argRouteID(args)
+			if err != nil {
+				return nil, err
+			}
+			enabled, ok := args["enabled"].(bool)
+			if !ok {
+				return nil, fmt.Errorf("enabled is required")
+			}
+			if _, err := mcpInvoke(invoke, "ToggleRoute", id, enabled); err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"success": true}, nil
+		},
+	},
+	{
+		Name:        "get_health_status",
+		Description: "Get the health of each route group from recent requests and health probes.",
+		InputSchema: mcpSchema(map[string]interface{}{}),
+		call: func(invoke AdminInvoker, args map[string]interface{}) (interface{}, error) {
+			return mcpInvoke(invoke, "GetHealthStatus")
+		},
+	},
+}
+
+// mcpServer 处理 JSON-RPC 消息并保存 SSE 会话
+type mcpServer struct {
+	invoke   AdminInvoker
+	mu       sync.Mutex
+	sessions map[string]chan []byte // SSE 会话 ID -> 待发送的响应
+}
+
+// handle 处理一条消息，通知（没有 id）返回 nil
+func (s *mcpServer) handle(msg jsonrpcMessage) *jsonrpcResponse {
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		return &jsonrpcResponse{JSONRPC: "2.0", ID: mcpID(msg.ID), Error: &jsonrpcError{Code: jsonrpcInvalidRequest, Message: "invalid request"}}
+	}
+	if len(msg.ID) == 0 {
+		return nil
+	}
+	resp := &jsonrpcResponse{JSONRPC: "2.0", ID: msg.ID}
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		version := mcpProtocolVersions[0]
+		for _, v := range mcpProtocolVersions {
+			if v == params.ProtocolVersion {
+				version = v
+			}
+		}
+		resp.Result = map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": mcpServerName, "version": config.Version},
+		}
+	case "ping":
+		resp.Result = map[string]interface{}{}
+	case "tools/list":
+		resp.Result = map[string]interface{}{"tools": mcpTools}
+	case "tools/call":
+		var params struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			resp.Error = &jsonrpcError{Code: jsonrpcInvalidParams, Message: err.Error()}
+			break
+		}
+		resp.Result, resp.Error = s.callTool(params.Name, params.Arguments)
+	default:
+		resp.Error = &jsonrpcError{Code: jsonrpcMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	return resp
+}
+
+// callTool 执行工具，工具执行出错时以 isError 结果返回（由模型处理），未知工具返回协议错误
+func (s *mcpServer) callTool(name string, args map[string]interface{}) (interface{}, *jsonrpcError) {
+	for _, tool := range mcpTools {
+		if tool.Name != name {
+			continue
+		}
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		log.Infof("[MCP] Tool call: %s", name)
+		result, err := tool.call(s.invoke, args)
+		if err != nil {
+			return mcpToolResult(err.Error(), true), nil
+		}
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return mcpToolResult(err.Error(), true), nil
+		}
+		return mcpToolResult(string(data), false), nil
+	}
+	return nil, &jsonrpcError{Code: jsonrpcInvalidParams, Message: "unknown tool: " + name}
+}
+
+func mcpToolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]interface{}{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// mcpID 无法解析 id 的请求按 JSON-RPC 规范返回 null
+func mcpID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// handleBody 处理请求体（单条消息或批量），没有需要返回的响应时返回 nil
+func (s *mcpServer) handleBody(body []byte) interface{} {
+	var batch []jsonrpcMessage
+	if err := json.Unmarshal(body, &batch); err == nil {
+		var responses []*jsonrpcResponse
+		for _, msg := range batch {
+			if resp := s.handle(msg); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return responses
+	}
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return &jsonrpcResponse{JSONRPC: "2.0", ID: mcpID(nil), Error: &jsonrpcError{Code: jsonrpcParseError, Message: "parse error: " + err.Error()}}
+	}
+	if resp := s.handle(msg); resp != nil {
+		return resp
+	}
+	return nil
+}
+
+func (s *mcpServer) openSession() (string, chan []byte, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	id := hex.EncodeToString(buf)
+	ch := make(chan []byte, mcpSSEQueueSize)
+	s.mu.Lock()
+	s.sessions[id] = ch
+	s.mu.Unlock()
+	return id, ch, nil
+}
+
+func (s *mcpServer) closeSession(id string) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+}
+
+func (s *mcpServer) session(id string) chan []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+// RegisterMCPServer 在 /mcp 注册 MCP 服务器（Streamable HTTP 和 SSE 传输）
+// 需要开启 mcp_enabled 并配置本地 API Key，未开启时返回 404
+func RegisterMCPServer(r *gin.Engine, cfg *config.Config, invoke AdminInvoker) {
+	server := &mcpServer{invoke: invoke, sessions: make(map[string]chan []byte)}
+	mcp := r.Group("/mcp", func(c *gin.Context) {
+		if !cfg.MCPEnabled {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.Next()
+	}, adminAuth(cfg))
+
+	mcp.POST("", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, jsonrpcResponse{JSONRPC: "2.0", ID: mcpID(nil), Error: &jsonrpcError{Code: jsonrpcParseError, Message: err.Error()}})
+			return
+		}
+		result := server.handleBody(body)
+		if result == nil {
+			c.Status(http.StatusAccepted)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+	// 服务器不主动发送消息，不提供 GET 流
+	mcp.GET("", func(c *gin.Context) {
+		c.Status(http.StatusMethodNotAllowed)
+	})
+
+	mcp.GET("/sse", func(c *gin.Context) {
+		id, ch, err := server.openSession()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer server.closeSession(id)
+		log.Infof("[MCP] SSE session %s opened from %s", id, c.ClientIP())
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		fmt.Fprintf(c.Writer, "event: endpoint\ndata: /mcp/message?session_id=%s\n\n", id)
+		c.Writer.Flush()
+
+		ticker := time.NewTicker(mcpSSEKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				log.Infof("[MCP] SSE session %s closed", id)
+				return
+			case data := <-ch:
+				fmt.Fprintf(c.Writer, "event: message\ndata: %s\n\n", data)
+				c.Writer.Flush()
+			case <-ticker.C:
+				io.WriteString(c.Writer, ": ping\n\n")
+				c.Writer.Flush()
+			}
+		}
+	})
+	mcp.POST("/message", func(c *gin.Context) {
+		ch := server.session(c.Query("session_id"))
+		if ch == nil {
+			c.String(http.StatusNotFound, "unknown session")
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if result := server.handleBody(body); result != nil {
+			data, _ := json.Marshal(result)
+			select {
+			case ch <- data:
+			case <-time.After(mcpSSEKeepAlive):
+				c.String(http.StatusServiceUnavailable, "session is not reading")
+				return
+			}
+		}
+		c.Status(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mirrorWriter 将写给客户端的响应同时交给 MirrorRun
+type mirrorWriter struct {
+	gin.ResponseWriter
+	run *service.MirrorRun
+}
+
+func (w *mirrorWriter) Write(data []byte) (int, error) {
+	w.run.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *mirrorWriter) WriteString(s string) (int, error) {
+	w.run.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// mirrorTarget 判断请求是否为可镜像的对话接口，返回请求格式和模型名（Gemini 官方路径的模型在 URL 中）
+func mirrorTarget(c *gin.Context, body []byte) (format, model string) {
+	switch c.FullPath() {
+	case "/api/v1/chat/completions", "/api/cursor/v1/chat/completions":
+		format = "openai"
+	case "/api/anthropic/v1/messages", "/api/claudecode/v1/messages":
+		format = "claude"
+	case "/api/gemini/completions":
+		format = "gemini"
+	case "/api/gemini/models/:model", "/api/gemini/:model",
+		"/api/gemini/v1beta/models/:model", "/api/gemini/v1/models/:model":
+		var action string
+		model, action = splitGeminiModelAction(c.Param("model"))
+		if !isGeminiGenerateAction(action) {
+			return "", ""
+		}
+		format = "gemini"
+	case "/api/v1/gemini/models/:modelAction":
+		var action string
+		model, action = splitGeminiModelAction(c.Param("modelAction"))
+		if !isGeminiGenerateAction(action) {
+			return "", ""
+		}
+		format = "gemini"
+	default:
+		return "", ""
+	}
+	if model == "" {
+		var reqData struct {
+			Model string `json:"model"`
+		}
+		json.Unmarshal(body, &reqData)
+		model = reqData.Model
+	}
+	return format, model
+}
+
+// mirror 流量镜像中间件：模型配置了镜像规则时将请求副本异步发送到影子路由，响应不返回给客户端
+func mirror(proxyService *service.ProxyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/") {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		format, model := mirrorTarget(c, body)
+		if format == "" {
+			c.Next()
+			return
+		}
+		run := proxyService.StartMirror(c.GetString("request_id"), format, model, body)
+		if run == nil {
+			c.Next()
+			return
+		}
+		c.Writer = &mirrorWriter{ResponseWriter: c.Writer, run: run}
+		c.Next()
+		proxyService.FinishMirror(run, c.Writer.Status())
+	}
+}
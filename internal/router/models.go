@@ -0,0 +1,71 @@
+package router
+
+import (
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestVirtualKey 请求使用的虚拟 Key，使用本地 API Key 时返回 nil
+func requestVirtualKey(c *gin.Context) *config.VirtualKey {
+	if v, ok := c.Get("virtual_key"); ok {
+		if vk, ok := v.(config.VirtualKey); ok {
+			return &vk
+		}
+	}
+	return nil
+}
+
+// listModels 模型列表接口共用：开启重定向时包含重定向关键字，按虚拟 Key 过滤可见模型并附带元数据
+func listModels(c *gin.Context, cfg *config.Config, routeService *service.RouteService) ([]service.ModelMetadata, error) {
+	keyword := ""
+	if cfg.RedirectEnabled && cfg.RedirectKeyword != "" {
+		keyword = cfg.RedirectKeyword
+	}
+	var visible func(model string) bool
+	if vk := requestVirtualKey(c); vk != nil {
+		visible = vk.ModelVisible
+	}
+	return routeService.ListModels(keyword, visible)
+}
+
+// addModelMetadata 附加已配置的元数据，未配置的字段不输出
+func addModelMetadata(info gin.H, m service.ModelMetadata) gin.H {
+	if m.DisplayName != "" {
+		info["display_name"] = m.DisplayName
+	}
+	if m.Description != "" {
+		info["description"] = m.Description
+	}
+	if m.ContextWindow > 0 {
+		info["context_window"] = m.ContextWindow
+	}
+	if m.MaxOutputTokens > 0 {
+		info["max_output_tokens"] = m.MaxOutputTokens
+	}
+	if len(m.Capabilities) > 0 {
+		info["capabilities"] = m.Capabilities
+	}
+	return info
+}
+
+// openAIModelInfo OpenAI 格式的模型信息
+func openAIModelInfo(m service.ModelMetadata) gin.H {
+	return addModelMetadata(gin.H{
+		"id":       m.Model,
+		"object":   "model",
+		"created":  1677610602,
+		"owned_by": "openai-router",
+	}, m)
+}
+
+// anthropicModelInfo Anthropic 格式的模型信息
+func anthropicModelInfo(m service.ModelMetadata) gin.H {
+	return addModelMetadata(gin.H{
+		"id":           m.Model,
+		"type":         "model",
+		"display_name": m.Name(),
+		"created_at":   "2024-01-01T00:00:00Z",
+	}, m)
+}
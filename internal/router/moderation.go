@@ -0,0 +1,61 @@
+package router
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestStyle 根据请求路径判断客户端使用的 API 格式
+func requestStyle(path string) string {
+	if strings.Contains(path, "/gemini") {
+		return "gemini"
+	}
+	if strings.HasPrefix(path, "/api/anthropic") || strings.HasPrefix(path, "/api/claudecode") {
+		return "claude"
+	}
+	return "openai"
+}
+
+// moderation 内容审查中间件：请求发往上游前按规则脱敏或拒绝（multipart 上传不处理）
+func moderation(cfg *config.Config, proxyService *service.ProxyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.ModerationEnabled || c.Request.Method != http.MethodPost ||
+			strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/") {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		requestID := c.GetString("request_id")
+		result := proxyService.ModerateRequest(requestID, body)
+		defer proxyService.FinishModeration(requestID)
+
+		if result.Blocked != nil {
+			message := proxyService.RecordModerationBlock(requestID, c.ClientIP(), requestStyle(c.Request.URL.Path), result)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"message":    message,
+					"type":       "moderation_blocked",
+					"request_id": requestID,
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(result.Body))
+		c.Request.ContentLength = int64(len(result.Body))
+		c.Next()
+	}
+}
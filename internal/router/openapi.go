@@ -0,0 +1,295 @@
+package router
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"openai-router-go/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPI 3 文档：根据已注册的路由生成，接口增减无需手动维护；常用接口附带说明和请求/响应结构
+
+// OpenAPIPath OpenAPI 文档地址（不需要认证）
+const OpenAPIPath = "/api/openapi.json"
+
+// openAPIOperation 接口说明
+type openAPIOperation struct {
+	summary  string
+	request  string // 请求体结构名（components/schemas），为空表示没有请求体或不描述
+	response string // 响应结构名
+}
+
+// openAPIOperations 常用接口的说明，键为 "方法 路径"
+var openAPIOperations = map[string]openAPIOperation{
+	"POST /api/v1/chat/completions":          {"Create a chat completion (OpenAI format, routed by model)", "ChatCompletionRequest", "ChatCompletionResponse"},
+	"POST /api/v1/completions":               {"Create a text completion (OpenAI format)", "GenericRequest", "GenericResponse"},
+	"POST /api/v1/embeddings":                {"Create embeddings (OpenAI format)", "GenericRequest", "GenericResponse"},
+	"POST /api/v1/images/generations":        {"Generate images (OpenAI format)", "GenericRequest", "GenericResponse"},
+	"POST /api/v1/audio/speech":              {"Text to speech (OpenAI format)", "GenericRequest", ""},
+	"POST /api/v1/audio/transcriptions":      {"Transcribe audio (multipart/form-data)", "", "GenericResponse"},
+	"POST /api/v1/audio/translations":        {"Translate audio (multipart/form-data)", "", "GenericResponse"},
+	"GET /api/v1/models":                     {"List models (OpenAI format)", "", "OpenAIModelList"},
+	"GET /api/models":                        {"List models (OpenAI format)", "", "OpenAIModelList"},
+	"GET /api/v1/realtime":                   {"Realtime API WebSocket (OpenAI format)", "", ""},
+	"POST /api/anthropic/v1/messages":        {"Create a message (Anthropic format)", "AnthropicMessagesRequest", "AnthropicMessagesResponse"},
+	"GET /api/anthropic/v1/models":           {"List models (Anthropic format)", "", "AnthropicModelList"},
+	"POST /api/claudecode/v1/messages":       {"Create a message for Claude Code (Anthropic format)", "AnthropicMessagesRequest", "AnthropicMessagesResponse"},
+	"GET /api/claudecode/v1/models":          {"List models for Claude Code (Anthropic format)", "", "AnthropicModelList"},
+	"POST /api/cursor/v1/chat/completions":   {"Create a chat completion for Cursor (OpenAI format)", "ChatCompletionRequest", "ChatCompletionResponse"},
+	"GET /api/cursor/v1/models":              {"List models for Cursor (OpenAI format)", "", "OpenAIModelList"},
+	"POST /api/gemini/v1beta/models/{model}": {"Gemini model action, e.g. gemini-2.5-pro:generateContent or :streamGenerateContent", "GeminiGenerateContentRequest", "GenericResponse"},
+	"GET /api/gemini/v1beta/models":          {"List models (Gemini format)", "", "GeminiModelList"},
+	"GET /api/admin/routes":                  {"List routes (?reveal=true includes full API keys)", "", ""},
+	"POST /api/admin/routes":                 {"Create a route", "AdminRoute", ""},
+	"GET /api/admin/routes/{id}":             {"Get a route", "", "AdminRoute"},
+	"PUT /api/admin/routes/{id}":             {"Update a route (omitted fields are kept)", "AdminRoute", ""},
+	"DELETE /api/admin/routes/{id}":          {"Delete a route", "", ""},
+	"POST /api/admin/routes/{id}/test":       {"Send a minimal chat completion through the route and return diagnostics", "", "GenericResponse"},
+	"GET /api/admin/routes/export":           {"Export routes as a JSON/YAML bundle (?format=json|yaml&keys=plain|exclude|encrypted)", "", ""},
+	"POST /api/admin/routes/import":          {"Import a JSON/YAML route bundle", "GenericRequest", ""},
+	"GET /api/admin/config":                  {"Get common settings", "", "GenericResponse"},
+	"PATCH /api/admin/config":                {"Update config.json fields and apply them immediately", "GenericRequest", ""},
+	"GET /api/admin/stats":                   {"Overall request statistics", "", "GenericResponse"},
+	"GET /api/admin/stats/breakdown":         {"Token, request and cost breakdown (?dimension=model|route|group|provider|style|virtual_key&days=30, days=0 for all retained logs)", "", ""},
+	"GET /api/admin/stats/latency":           {"P50/P95/P99 of total time and first chunk time for successful requests, per model, per route and over time (?hours=24)", "", ""},
+	"GET /api/admin/requests":                {"In-flight proxy requests (start time, model, route, client IP, bytes sent), oldest first", "", ""},
+	"POST /api/admin/requests/{id}/cancel":   {"Cancel an in-flight proxy request by request ID (aborts the upstream request)", "", ""},
+	"GET /api/admin/stats/errors":            {"Failed requests by error type (network, timeout, auth, rate_limit, upstream_5xx, upstream_4xx, conversion, client_abort, blocked, internal, other), by route and over time, plus the most recent failures (?hours=24)", "", ""},
+	"GET /api/admin/logs":                    {"Request logs (?page=&page_size=&model=&style=&success=&start=&end=&request_id=&q=, q searches error messages full-text)", "", "GenericResponse"},
+	"GET /api/admin/traces":                  {"Conversation traces (?page=&page_size=&success=&start=&end=&request_id=&q=, q searches request/response content, error messages, model, provider and client IP full-text)", "", "GenericResponse"},
+	"GET /api/admin/logs/export":             {"Export request logs as CSV/JSON (?format=csv|json&start=&end=&columns=&model=&provider=&style=&success=&q=, start/end accept dates)", "", ""},
+	"GET /api/admin/traces/export":           {"Export traces (?format=finetune|jsonl|json|csv&session=&start=&end=&success_only=true)", "", ""},
+	"GET /api/admin/traces/{id}/conversion":  {"Trace payloads before and after adapter conversion (inbound, upstream request, raw upstream response, returned response)", "", "GenericResponse"},
+	"GET /api/admin/health":                  {"Route health by group", "", ""},
+	"GET /api/admin/metrics":                 {"Runtime metrics (upstream connection pool)", "", "GenericResponse"},
+	"POST /api/admin/explain":                {"Dry-run routing for a proxy request body without calling any provider: format detection, route order, adapter, target URL and upstream payload (?endpoint=openai|cursor|claude|claudecode|gemini)", "GenericRequest", "GenericResponse"},
+	"GET /health":                            {"Liveness check", "", "GenericResponse"},
+	"GET /api/openapi.json":                  {"This OpenAPI document", "", "GenericResponse"},
+}
+
+// openAPITag 按路径前缀分组
+func openAPITag(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/admin"):
+		return "Admin"
+	case strings.HasPrefix(path, "/api/anthropic"), strings.HasPrefix(path, "/api/claudecode"):
+		return "Anthropic"
+	case strings.HasPrefix(path, "/api/gemini"), strings.HasPrefix(path, "/api/v1/gemini"):
+		return "Gemini"
+	case strings.HasPrefix(path, "/api/cursor"):
+		return "Cursor"
+	case strings.HasPrefix(path, "/api/v1/"), path == "/api/models":
+		return "OpenAI"
+	case path == "/health", path == OpenAPIPath:
+		return "System"
+	}
+	return "Utilities"
+}
+
+// openAPISecurity 各分组接受的认证方式，System 分组不需要认证
+func openAPISecurity(tag string) []gin.H {
+	switch tag {
+	case "System":
+		return []gin.H{}
+	case "Anthropic", "Admin":
+		return []gin.H{{"bearerAuth": []string{}}, {"apiKeyHeader": []string{}}}
+	case "Gemini":
+		return []gin.H{{"googApiKeyHeader": []string{}}, {"googApiKeyQuery": []string{}}, {"bearerAuth": []string{}}}
+	}
+	return []gin.H{{"bearerAuth": []string{}}}
+}
+
+// openAPIPath gin 路径转换为 OpenAPI 路径（:id、*path -> {id}、{path}），同时返回路径参数名
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func schemaRef(name string) gin.H {
+	return gin.H{"$ref": "#/components/schemas/" + name}
+}
+
+// openAPIComponents 认证方式和常用结构
+func openAPIComponents() gin.H {
+	object := func(description string, properties gin.H, required ...string) gin.H {
+		schema := gin.H{"type": "object", "description": description, "properties": properties, "additionalProperties": true}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	str := gin.H{"type": "string"}
+	modelList := func(item gin.H) gin.H {
+		return object("", gin.H{"data": gin.H{"type": "array", "items": item}})
+	}
+	modelInfo := object("Model entry, with optional metadata", gin.H{
+		"id":                str,
+		"display_name":      str,
+		"context_window":    gin.H{"type": "integer"},
+		"max_output_tokens": gin.H{"type": "integer"},
+		"capabilities":      gin.H{"type": "array", "items": str},
+	})
+
+	return gin.H{
+		"securitySchemes": gin.H{
+			"bearerAuth":       gin.H{"type": "http", "scheme": "bearer", "description": "Local API key or virtual key"},
+			"apiKeyHeader":     gin.H{"type": "apiKey", "in": "header", "name": "x-api-key"},
+			"googApiKeyHeader": gin.H{"type": "apiKey", "in": "header", "name": "x-goog-api-key"},
+			"googApiKeyQuery":  gin.H{"type": "apiKey", "in": "query", "name": "key"},
+		},
+		"schemas": gin.H{
+			"Error": object("Error response", gin.H{
+				"error": object("", gin.H{"message": str, "type": str, "request_id": str}),
+			}),
+			"GenericRequest":  object("JSON request body", gin.H{}),
+			"GenericResponse": object("JSON response body", gin.H{}),
+			"ChatCompletionRequest": object("OpenAI chat completion request", gin.H{
+				"model":       str,
+				"messages":    gin.H{"type": "array", "items": object("", gin.H{"role": str, "content": gin.H{}})},
+				"stream":      gin.H{"type": "boolean"},
+				"temperature": gin.H{"type": "number"},
+				"max_tokens":  gin.H{"type": "integer"},
+				"tools":       gin.H{"type": "array", "items": gin.H{"type": "object"}},
+			}, "model", "messages"),
+			"ChatCompletionResponse": object("OpenAI chat completion (or SSE stream when stream=true)", gin.H{
+				"id":      str,
+				"model":   str,
+				"choices": gin.H{"type": "array", "items": gin.H{"type": "object"}},
+				"usage":   gin.H{"type": "object"},
+			}),
+			"AnthropicMessagesRequest": object("Anthropic messages request", gin.H{
+				"model":      str,
+				"messages":   gin.H{"type": "array", "items": object("", gin.H{"role": str, "content": gin.H{}})},
+				"system":     gin.H{},
+				"max_tokens": gin.H{"type": "integer"},
+				"stream":     gin.H{"type": "boolean"},
+			}, "model", "messages"),
+			"AnthropicMessagesResponse": object("Anthropic message (or SSE stream when stream=true)", gin.H{
+				"id":          str,
+				"model":       str,
+				"content":     gin.H{"type": "array", "items": gin.H{"type": "object"}},
+				"stop_reason": str,
+				"usage":       gin.H{"type": "object"},
+			}),
+			"GeminiGenerateContentRequest": object("Gemini generateContent request", gin.H{
+				"contents":         gin.H{"type": "array", "items": gin.H{"type": "object"}},
+				"generationConfig": gin.H{"type": "object"},
+			}, "contents"),
+			"OpenAIModelList":    modelList(modelInfo),
+			"AnthropicModelList": modelList(modelInfo),
+			"GeminiModelList":    object("", gin.H{"models": gin.H{"type": "array", "items": gin.H{"type": "object"}}}),
+			"AdminRoute": object("Route", gin.H{
+				"name":                str,
+				"model":               str,
+				"api_url":             str,
+				"api_key":             str,
+				"group":               str,
+				"format":              gin.H{"type": "string", "enum": []string{"openai", "claude", "gemini", "ollama", "mistral", "xai", "cohere", "deepseek"}},
+				"enabled":             gin.H{"type": "boolean"},
+				"priority":            gin.H{"type": "integer"},
+				"max_tokens":          gin.H{"type": "integer", "description": "max_tokens cap for requests sent to this route, 0 for no limit"},
+				"upstream_model":      gin.H{"type": "string", "description": "Model name sent upstream; clients keep requesting the route model. Empty to forward the requested name"},
+				"provider_prefs":      gin.H{"type": "string", "description": `OpenRouter routes only: JSON object merged into chat requests, e.g. {"provider":{"order":["anthropic"]},"transforms":["middle-out"]}`},
+				"extra_headers":       gin.H{"type": "object", "additionalProperties": str},
+				"extra_query":         gin.H{"type": "object", "additionalProperties": str},
+				"daily_token_limit":   gin.H{"type": "integer", "description": "Daily token cap; once reached the route is skipped until the quota resets (quota timezone midnight). 0 for no limit"},
+				"daily_request_limit": gin.H{"type": "integer", "description": "Daily request cap, 0 for no limit"},
+			}),
+		},
+	}
+}
+
+// buildOpenAPI 根据已注册的路由生成 OpenAPI 文档（网页管理界面 /admin 使用 Cookie 会话，不包含在内）
+func buildOpenAPI(routes gin.RoutesInfo, cfg *config.Config) gin.H {
+	paths := gin.H{}
+	tags := make(map[string]bool)
+	for _, route := range routes {
+		if route.Path == "/admin" || strings.HasPrefix(route.Path, "/admin/") {
+			continue
+		}
+		path, params := openAPIPath(route.Path)
+		tag := openAPITag(route.Path)
+		tags[tag] = true
+
+		op := openAPIOperations[route.Method+" "+path]
+		summary := op.summary
+		if summary == "" {
+			summary = route.Method + " " + path
+		}
+		operation := gin.H{
+			"tags":     []string{tag},
+			"summary":  summary,
+			"security": openAPISecurity(tag),
+		}
+		if len(params) > 0 {
+			parameters := make([]gin.H, 0, len(params))
+			for _, name := range params {
+				parameters = append(parameters, gin.H{"name": name, "in": "path", "required": true, "schema": gin.H{"type": "string"}})
+			}
+			operation["parameters"] = parameters
+		}
+		if op.request != "" {
+			operation["requestBody"] = gin.H{
+				"required": true,
+				"content":  gin.H{"application/json": gin.H{"schema": schemaRef(op.request)}},
+			}
+		}
+		success := gin.H{"description": "Success"}
+		if op.response != "" {
+			success["content"] = gin.H{"application/json": gin.H{"schema": schemaRef(op.response)}}
+		}
+		errorResponse := gin.H{"description": "Error", "content": gin.H{"application/json": gin.H{"schema": schemaRef("Error")}}}
+		operation["responses"] = gin.H{"200": success, "default": errorResponse}
+
+		item, _ := paths[path].(gin.H)
+		if item == nil {
+			item = gin.H{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	tagNames := make([]string, 0, len(tags))
+	for tag := range tags {
+		tagNames = append(tagNames, tag)
+	}
+	sort.Strings(tagNames)
+	tagList := make([]gin.H, 0, len(tagNames))
+	for _, tag := range tagNames {
+		tagList = append(tagList, gin.H{"name": tag})
+	}
+
+	description := "Multi-format AI API proxy. Requests are routed to upstream providers by model name."
+	if cfg.AuthKey() == "" {
+		description += " No local API key is configured, so proxy endpoints currently accept requests without authentication."
+	}
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":       "AnyProxyAi API",
+			"version":     "1.0.0",
+			"description": description,
+		},
+		"servers":    []gin.H{{"url": cfg.BaseURL()}},
+		"tags":       tagList,
+		"paths":      paths,
+		"components": openAPIComponents(),
+	}
+}
+
+// registerOpenAPI 注册 OpenAPI 文档接口，每次请求时根据当前路由生成（包含之后注册的管理接口）
+func registerOpenAPI(r *gin.Engine, cfg *config.Config) {
+	r.GET(OpenAPIPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, buildOpenAPI(r.Routes(), cfg))
+	})
+}
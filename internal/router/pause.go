@@ -0,0 +1,28 @@
+package router
+
+import (
+	"net/http"
+
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// proxyPause 代理暂停时拒绝所有代理请求（503），客户端可稍后重试
+func proxyPause(proxyService *service.ProxyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !proxyService.IsPaused() {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"message":    "The proxy is paused, resume it from the tray menu or the settings page",
+				"type":       "proxy_paused",
+				"request_id": c.GetString("request_id"),
+			},
+		})
+		c.Abort()
+	}
+}
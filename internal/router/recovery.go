@@ -0,0 +1,66 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// panicRecovery 代理请求中的 panic：记录堆栈和失败的请求日志，SSE 响应以错误事件结束，
+// 其他情况返回 500。流式转换中的 panic 已在 ProxyService 中转换为错误，这里处理其余位置的 panic
+func panicRecovery(routeService *service.RouteService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		defer func() {
+			requestID := c.GetString("request_id")
+			err := service.RecoverPanic(requestID, recover())
+			if err == nil {
+				return
+			}
+
+			style := "openai"
+			path := c.Request.URL.Path
+			if strings.Contains(path, "/anthropic") || strings.Contains(path, "/claudecode") {
+				style = "claude"
+			} else if strings.Contains(path, "/gemini") {
+				style = "gemini"
+			}
+			model := strings.SplitN(c.Param("model"), ":", 2)[0]
+			if model == "" {
+				model = "unknown"
+			}
+			streaming := strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream")
+			routeService.LogRequestFull(service.RequestLogParams{
+				RequestID:    requestID,
+				Model:        model,
+				Success:      false,
+				ErrorMessage: err.Error(),
+				Style:        style,
+				UserAgent:    c.Request.UserAgent(),
+				RemoteIP:     c.ClientIP(),
+				ProxyTimeMs:  time.Since(start).Milliseconds(),
+				IsStream:     streaming,
+			})
+
+			switch {
+			case streaming:
+				flusher, _ := c.Writer.(http.Flusher)
+				sendStreamError(c, flusher, err, style)
+			case !c.Writer.Written():
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": gin.H{
+						"message":    err.Error(),
+						"type":       "internal_error",
+						"request_id": requestID,
+					},
+				})
+			}
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
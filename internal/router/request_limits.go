@@ -0,0 +1,95 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// requestLimits 请求护栏中间件：请求体超过 max_request_body_mb 返回 413，消息数超过 max_request_messages 返回 400，
+// 虚拟 Key 设置了 max_tokens 上限时将请求的 max_tokens 限制在上限以内（路由的上限在发往上游时应用）
+func requestLimits(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+
+		maxBytes := int64(cfg.MaxRequestBodyMB) << 20
+		if maxBytes > 0 && c.Request.ContentLength > maxBytes {
+			rejectRequest(c, http.StatusRequestEntityTooLarge, "request_too_large",
+				fmt.Sprintf("Request body exceeds the %d MB limit (max_request_body_mb)", cfg.MaxRequestBodyMB))
+			return
+		}
+		// multipart 上传（音频、文件）不读入内存，只限制读取的大小
+		if strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/") {
+			if maxBytes > 0 {
+				c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+			}
+			c.Next()
+			return
+		}
+
+		var vkMaxTokens int
+		if vk := requestVirtualKey(c); vk != nil {
+			vkMaxTokens = vk.MaxTokens
+		}
+		if maxBytes <= 0 && cfg.MaxRequestMessages <= 0 && vkMaxTokens <= 0 {
+			c.Next()
+			return
+		}
+
+		reader := io.Reader(c.Request.Body)
+		if maxBytes > 0 {
+			reader = io.LimitReader(c.Request.Body, maxBytes+1)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			rejectRequest(c, http.StatusBadRequest, "invalid_request_error", "Failed to read request body")
+			return
+		}
+		if maxBytes > 0 && int64(len(body)) > maxBytes {
+			rejectRequest(c, http.StatusRequestEntityTooLarge, "request_too_large",
+				fmt.Sprintf("Request body exceeds the %d MB limit (max_request_body_mb)", cfg.MaxRequestBodyMB))
+			return
+		}
+
+		if cfg.MaxRequestMessages > 0 {
+			if count := service.CountRequestMessages(body); count > cfg.MaxRequestMessages {
+				rejectRequest(c, http.StatusBadRequest, "invalid_request_error",
+					fmt.Sprintf("Request contains %d messages, exceeding the limit of %d (max_request_messages)", count, cfg.MaxRequestMessages))
+				return
+			}
+		}
+
+		if capped, changed := service.CapMaxTokens(body, vkMaxTokens, strings.HasSuffix(c.Request.URL.Path, "/responses")); changed {
+			log.Infof("[Request Limits] max_tokens capped to %d for virtual key %s", vkMaxTokens, requestVirtualKey(c).Name)
+			body = capped
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Next()
+	}
+}
+
+// rejectRequest 返回 OpenAI 格式的错误并终止请求
+func rejectRequest(c *gin.Context, status int, errType, message string) {
+	log.Warnf("[Request Limits] Rejected %s from %s: %s", c.Request.URL.Path, c.ClientIP(), message)
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"message":    message,
+			"type":       errType,
+			"request_id": c.GetString("request_id"),
+		},
+	})
+	c.Abort()
+}
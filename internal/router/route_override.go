@@ -0,0 +1,47 @@
+package router
+
+import (
+	"net/http"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// routeOverride 校验客户端指定路由/供应商的请求头（X-AnyProxy-Route-ID、X-AnyProxy-Provider）
+// 需要开启 route_override_enabled 且请求使用本地 API Key（apiKeyAuth 已校验，虚拟 Key 不允许），否则拒绝请求而不是静默忽略
+func routeOverride(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Playground 在进程内发起的请求不受限制
+		if !service.HasRouteOverride(c.Request.Header) || service.IsPlaygroundRequest(c.Request.Context()) {
+			c.Next()
+			return
+		}
+
+		message := ""
+		if !cfg.RouteOverrideEnabled {
+			message = "Route override headers are disabled (route_override_enabled)"
+		} else if cfg.AuthKey() == "" {
+			message = "Route override headers require a local API key"
+		} else if vk := requestVirtualKey(c); vk != nil {
+			message = "Route override headers are not allowed for virtual key " + vk.Name
+		}
+		if message != "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": gin.H{
+					"message":    message,
+					"type":       "permission_error",
+					"request_id": c.GetString("request_id"),
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		log.Infof("[Route Override] %s from %s: route=%q provider=%q", c.Request.URL.Path, c.ClientIP(),
+			c.GetHeader(service.RouteIDHeader), c.GetHeader(service.ProviderHeader))
+		c.Next()
+	}
+}
@@ -1,12 +1,14 @@
 package router
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"openai-router-go/internal/config"
 	"openai-router-go/internal/service"
@@ -25,8 +27,9 @@ func sendStreamError(c *gin.Context, flusher http.Flusher, err error, format str
 		errorResp := map[string]interface{}{
 			"type": "error",
 			"error": map[string]interface{}{
-				"type":    "api_error",
-				"message": errMsg,
+				"type":       "api_error",
+				"message":    errMsg,
+				"request_id": c.GetString("request_id"),
 			},
 		}
 		data, _ := json.Marshal(errorResp)
@@ -35,8 +38,9 @@ func sendStreamError(c *gin.Context, flusher http.Flusher, err error, format str
 		// OpenAI 格式的错误响应
 		errorResp := map[string]interface{}{
 			"error": map[string]interface{}{
-				"message": errMsg,
-				"type":    "proxy_error",
+				"message":    errMsg,
+				"type":       "proxy_error",
+				"request_id": c.GetString("request_id"),
 			},
 		}
 		data, _ := json.Marshal(errorResp)
@@ -52,10 +56,28 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 	r := gin.New()
 	r.Use(gin.Recovery())
 
+	// 请求ID中间件：沿用客户端传入的 X-Request-ID，否则生成新的 UUID
+	r.Use(func(c *gin.Context) {
+		requestID := c.GetHeader(service.RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = service.NewRequestID()
+		}
+		c.Set("request_id", requestID)
+		c.Request.Header.Set(service.RequestIDHeader, requestID)
+		c.Header(service.RequestIDHeader, requestID)
+		// 客户端指定的会话，用于 Traces 按对话分组
+		defer proxyService.BindClientSession(requestID, c.GetHeader(service.SessionIDHeader))()
+		c.Next()
+	})
+
+	// 客户端 IP 白名单
+	r.Use(ipFilter(cfg))
+
 	// 自定义日志中间件
 	r.Use(func(c *gin.Context) {
+		start := time.Now()
 		c.Next()
-		log.Infof("%s %s %d", c.Request.Method, c.Request.URL.Path, c.Writer.Status())
+		service.RequestLogger(c.GetString("request_id")).Infof("%s %s %d (%v)", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), time.Since(start))
 	})
 
 	// 移除请求体大小限制
@@ -67,7 +89,8 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 	// API 密钥验证中间件
 	apiKeyAuth := func(c *gin.Context) {
 		// 如果没有配置本地 API Key，则跳过验证
-		if cfg.LocalAPIKey == "" {
+		localAPIKey := cfg.AuthKey()
+		if localAPIKey == "" {
 			c.Next()
 			return
 		}
@@ -97,19 +120,36 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 			apiKey = c.Query("key")
 		}
 
+		// 支持 Realtime WebSocket 子协议 openai-insecure-api-key.xxx（浏览器无法设置请求头）
+		if apiKey == "" {
+			apiKey = service.RealtimeKeyFromProtocols(c.GetHeader("Sec-WebSocket-Protocol"))
+		}
+
 		// 调试日志：打印收到的认证信息
 		log.Debugf("API Key Auth - Authorization: %s, x-api-key: %s, x-goog-api-key: %s, query key: %s",
 			authHeader, c.GetHeader("x-api-key"), c.GetHeader("x-goog-api-key"), c.Query("key"))
 
+		// 虚拟 Key：记录下来供模型列表按 Key 过滤可见模型
+		if apiKey != localAPIKey {
+			if vk := cfg.FindVirtualKey(apiKey); vk != nil {
+				c.Set("virtual_key", *vk)
+				// 请求日志记录虚拟 Key，用于按客户端统计用量
+				defer routeService.BindVirtualKey(c.GetString("request_id"), vk.Name)()
+				c.Next()
+				return
+			}
+		}
+
 		// 验证 API Key
-		if apiKey != cfg.LocalAPIKey {
+		if apiKey != localAPIKey {
 			log.Warnf("Invalid API key from %s, path: %s, received key: '%s', expected: '%s'",
-				c.ClientIP(), c.Request.URL.Path, apiKey, cfg.LocalAPIKey)
+				c.ClientIP(), c.Request.URL.Path, apiKey, localAPIKey)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": gin.H{
-					"message": "Invalid API key. Please check your API key and try again.",
-					"type":    "invalid_api_key",
-					"code":    "invalid_api_key",
+					"message":    "Invalid API key. Please check your API key and try again.",
+					"type":       "invalid_api_key",
+					"request_id": c.GetString("request_id"),
+					"code":       "invalid_api_key",
 				},
 			})
 			c.Abort()
@@ -119,27 +159,74 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		c.Next()
 	}
 
+	// 生成参数预设中间件：根据 X-Gen-Profile 将预设参数合并到请求体
+	genProfile := func(c *gin.Context) {
+		profileName := c.GetHeader(service.GenProfileHeader)
+		if profileName == "" || c.Request.Method != http.MethodPost ||
+			strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/") {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"message":    "Failed to read request body",
+					"type":       "invalid_request_error",
+					"request_id": c.GetString("request_id"),
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		format := requestStyle(c.Request.URL.Path)
+
+		newBody, err := service.ApplyGenProfile(body, cfg.GenProfiles, profileName, format)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"message":    err.Error(),
+					"type":       "invalid_request_error",
+					"request_id": c.GetString("request_id"),
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		log.Infof("Applied generation profile '%s' (%s format)", profileName, format)
+		c.Request.Body = io.NopCloser(bytes.NewReader(newBody))
+		c.Request.ContentLength = int64(len(newBody))
+		c.Next()
+	}
+
 	// API 路由组
 	api := r.Group("/api")
-	api.Use(apiKeyAuth) // 应用 API 密钥验证中间件
+	api.Use(panicRecovery(routeService))    // panic 时记录失败请求并返回错误（流式响应发送 SSE 错误事件）
+	api.Use(apiKeyAuth)                     // 应用 API 密钥验证中间件
+	api.Use(proxyPause(proxyService))       // 代理暂停时返回 503
+	api.Use(activeRequests(proxyService))   // 登记进行中的请求（可在 GUI 中中止）
+	api.Use(routeOverride(cfg))             // 校验客户端指定路由/供应商的请求头
+	api.Use(genProfile)                     // 应用生成参数预设（在请求限制之前，预设的 max_tokens 也受虚拟 Key 上限约束）
+	api.Use(requestLimits(cfg))             // 请求体大小、消息数和虚拟 Key 的 max_tokens 上限
+	api.Use(moderation(cfg, proxyService))  // 内容审查（脱敏/拒绝）
+	api.Use(mirror(proxyService))           // 流量镜像到影子路由
+	api.Use(usageCapture(routeService))     // 上游缺少 usage 时估算 token
+	api.Use(traceConversions(proxyService)) // Trace 记录格式转换前后的内容
 	{
 		// 列出可用模型 - OpenAI 标准接口 /api/models（包含重定向关键字）
 		api.GET("/models", func(c *gin.Context) {
 			// 获取包含重定向关键字的模型列表
-			var models []string
-			var err error
-
-			if cfg.RedirectEnabled && cfg.RedirectKeyword != "" {
-				models, err = routeService.GetAvailableModelsWithRedirect(cfg.RedirectKeyword)
-			} else {
-				models, err = routeService.GetAvailableModels()
-			}
+			models, err := listModels(c, cfg, routeService)
 
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": gin.H{
-						"message": err.Error(),
-						"type":    "internal_error",
+						"message":    err.Error(),
+						"type":       "internal_error",
+						"request_id": c.GetString("request_id"),
 					},
 				})
 				return
@@ -147,12 +234,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 
 			modelsData := make([]gin.H, len(models))
 			for i, model := range models {
-				modelsData[i] = gin.H{
-					"id":       model,
-					"object":   "model",
-					"created":  1677610602,
-					"owned_by": "openai-router",
-				}
+				modelsData[i] = openAIModelInfo(model)
 			}
 
 			c.JSON(http.StatusOK, gin.H{
@@ -167,20 +249,14 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		{
 			// 列出可用模型 - Anthropic 格式
 			anthropic.GET("/v1/models", func(c *gin.Context) {
-				var models []string
-				var err error
-
-				if cfg.RedirectEnabled && cfg.RedirectKeyword != "" {
-					models, err = routeService.GetAvailableModelsWithRedirect(cfg.RedirectKeyword)
-				} else {
-					models, err = routeService.GetAvailableModels()
-				}
+				models, err := listModels(c, cfg, routeService)
 
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "internal_error",
+							"message":    err.Error(),
+							"type":       "internal_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -189,12 +265,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				// Anthropic 格式的模型列表
 				modelsData := make([]gin.H, len(models))
 				for i, model := range models {
-					modelsData[i] = gin.H{
-						"id":           model,
-						"type":         "model",
-						"display_name": model,
-						"created_at":   "2024-01-01T00:00:00Z",
-					}
+					modelsData[i] = anthropicModelInfo(model)
 				}
 
 				c.JSON(http.StatusOK, gin.H{
@@ -209,8 +280,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(http.StatusBadRequest, gin.H{
 						"error": gin.H{
-							"message": "Failed to read request body",
-							"type":    "invalid_request_error",
+							"message":    "Failed to read request body",
+							"type":       "invalid_request_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -236,11 +308,12 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 
 						flusher, ok := c.Writer.(http.Flusher)
 						if !ok {
-							log.Errorf("Streaming not supported")
+							service.RequestLogger(c.GetString("request_id")).Errorf("Streaming not supported")
 							c.JSON(http.StatusInternalServerError, gin.H{
 								"error": gin.H{
-									"message": "Streaming not supported",
-									"type":    "internal_error",
+									"message":    "Streaming not supported",
+									"type":       "internal_error",
+									"request_id": c.GetString("request_id"),
 								},
 							})
 							return
@@ -250,7 +323,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 						// 请求来自 Claude 格式，根据路由配置的 format 决定是否转换
 						err := proxyService.ProxyAnthropicStreamRequest(body, headers, c.Writer, flusher)
 						if err != nil {
-							log.Errorf("Stream proxy error: %v", err)
+							service.RequestLogger(c.GetString("request_id")).Errorf("Stream proxy error: %v", err)
 							sendStreamError(c, flusher, err, "claude")
 						}
 						return
@@ -262,8 +335,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(statusCode, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "proxy_error",
+							"message":    err.Error(),
+							"type":       "proxy_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -280,20 +354,14 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		{
 			// 列出可用模型 - Anthropic 格式
 			claudecode.GET("/v1/models", func(c *gin.Context) {
-				var models []string
-				var err error
-
-				if cfg.RedirectEnabled && cfg.RedirectKeyword != "" {
-					models, err = routeService.GetAvailableModelsWithRedirect(cfg.RedirectKeyword)
-				} else {
-					models, err = routeService.GetAvailableModels()
-				}
+				models, err := listModels(c, cfg, routeService)
 
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "internal_error",
+							"message":    err.Error(),
+							"type":       "internal_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -302,12 +370,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				// Anthropic 格式的模型列表
 				modelsData := make([]gin.H, len(models))
 				for i, model := range models {
-					modelsData[i] = gin.H{
-						"id":           model,
-						"type":         "model",
-						"display_name": model,
-						"created_at":   "2024-01-01T00:00:00Z",
-					}
+					modelsData[i] = anthropicModelInfo(model)
 				}
 
 				c.JSON(http.StatusOK, gin.H{
@@ -322,8 +385,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(http.StatusBadRequest, gin.H{
 						"error": gin.H{
-							"message": "Failed to read request body",
-							"type":    "invalid_request_error",
+							"message":    "Failed to read request body",
+							"type":       "invalid_request_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -349,11 +413,12 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 
 						flusher, ok := c.Writer.(http.Flusher)
 						if !ok {
-							log.Errorf("Streaming not supported")
+							service.RequestLogger(c.GetString("request_id")).Errorf("Streaming not supported")
 							c.JSON(http.StatusInternalServerError, gin.H{
 								"error": gin.H{
-									"message": "Streaming not supported",
-									"type":    "internal_error",
+									"message":    "Streaming not supported",
+									"type":       "internal_error",
+									"request_id": c.GetString("request_id"),
 								},
 							})
 							return
@@ -363,7 +428,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 						// 将 Claude Code 格式转换为 OpenAI 格式，响应转换回 Claude 格式
 						err := proxyService.ProxyClaudeCodeStreamRequest(body, headers, c.Writer, flusher)
 						if err != nil {
-							log.Errorf("Claude Code stream proxy error: %v", err)
+							service.RequestLogger(c.GetString("request_id")).Errorf("Claude Code stream proxy error: %v", err)
 							sendStreamError(c, flusher, err, "claude")
 						}
 						return
@@ -375,8 +440,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(statusCode, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "proxy_error",
+							"message":    err.Error(),
+							"type":       "proxy_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -393,20 +459,14 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		{
 			// 列出可用模型 - OpenAI 格式
 			cursor.GET("/v1/models", func(c *gin.Context) {
-				var models []string
-				var err error
-
-				if cfg.RedirectEnabled && cfg.RedirectKeyword != "" {
-					models, err = routeService.GetAvailableModelsWithRedirect(cfg.RedirectKeyword)
-				} else {
-					models, err = routeService.GetAvailableModels()
-				}
+				models, err := listModels(c, cfg, routeService)
 
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "internal_error",
+							"message":    err.Error(),
+							"type":       "internal_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -414,12 +474,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 
 				modelsData := make([]gin.H, len(models))
 				for i, model := range models {
-					modelsData[i] = gin.H{
-						"id":       model,
-						"object":   "model",
-						"created":  1677610602,
-						"owned_by": "openai-router",
-					}
+					modelsData[i] = openAIModelInfo(model)
 				}
 
 				c.JSON(http.StatusOK, gin.H{
@@ -435,8 +490,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(http.StatusBadRequest, gin.H{
 						"error": gin.H{
-							"message": "Failed to read request body",
-							"type":    "invalid_request_error",
+							"message":    "Failed to read request body",
+							"type":       "invalid_request_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -462,11 +518,12 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 
 						flusher, ok := c.Writer.(http.Flusher)
 						if !ok {
-							log.Errorf("Streaming not supported")
+							service.RequestLogger(c.GetString("request_id")).Errorf("Streaming not supported")
 							c.JSON(http.StatusInternalServerError, gin.H{
 								"error": gin.H{
-									"message": "Streaming not supported",
-									"type":    "internal_error",
+									"message":    "Streaming not supported",
+									"type":       "internal_error",
+									"request_id": c.GetString("request_id"),
 								},
 							})
 							return
@@ -475,7 +532,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 						// 使用 Cursor 专用流式处理
 						err := proxyService.ProxyCursorStreamRequest(body, headers, c.Writer, flusher)
 						if err != nil {
-							log.Errorf("Cursor stream proxy error: %v", err)
+							service.RequestLogger(c.GetString("request_id")).Errorf("Cursor stream proxy error: %v", err)
 							sendStreamError(c, flusher, err, "openai")
 						}
 						return
@@ -487,8 +544,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(statusCode, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "proxy_error",
+							"message":    err.Error(),
+							"type":       "proxy_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -504,22 +562,16 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		gemini := api.Group("/gemini")
 		{
 			// 列出可用模型 - Gemini 格式
-			gemini.GET("/models", func(c *gin.Context) {
+			geminiListModels := func(c *gin.Context) {
 				// 获取包含重定向关键字的模型列表
-				var models []string
-				var err error
-
-				if cfg.RedirectEnabled && cfg.RedirectKeyword != "" {
-					models, err = routeService.GetAvailableModelsWithRedirect(cfg.RedirectKeyword)
-				} else {
-					models, err = routeService.GetAvailableModels()
-				}
+				models, err := listModels(c, cfg, routeService)
 
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "internal_error",
+							"message":    err.Error(),
+							"type":       "internal_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -528,21 +580,15 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				// Gemini 格式的模型列表
 				modelsData := make([]gin.H, len(models))
 				for i, model := range models {
-					modelsData[i] = gin.H{
-						"name":                       "models/" + model,
-						"version":                    "001",
-						"displayName":                model,
-						"description":                "Model " + model,
-						"inputTokenLimit":            1048576,
-						"outputTokenLimit":           8192,
-						"supportedGenerationMethods": []string{"generateContent", "streamGenerateContent"},
-					}
+					modelsData[i] = geminiModelInfo(model)
 				}
 
 				c.JSON(http.StatusOK, gin.H{
 					"models": modelsData,
 				})
-			})
+			}
+			gemini.GET("/models", geminiListModels)
+			gemini.GET("/models/:model", geminiGetModel(cfg, routeService, "model"))
 
 			// Gemini 流式生成接口
 			gemini.POST("/completions", func(c *gin.Context) {
@@ -551,8 +597,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(http.StatusBadRequest, gin.H{
 						"error": gin.H{
-							"message": "Failed to read request body",
-							"type":    "invalid_request_error",
+							"message":    "Failed to read request body",
+							"type":       "invalid_request_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -570,29 +617,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				var reqData map[string]interface{}
 				if err := json.Unmarshal(body, &reqData); err == nil {
 					if stream, ok := reqData["stream"].(bool); ok && stream {
-						c.Header("Content-Type", "text/event-stream")
-						c.Header("Cache-Control", "no-cache")
-						c.Header("Connection", "keep-alive")
-						c.Header("X-Accel-Buffering", "no")
-
-						flusher, ok := c.Writer.(http.Flusher)
-						if !ok {
-							log.Errorf("Streaming not supported")
-							c.JSON(http.StatusInternalServerError, gin.H{
-								"error": gin.H{
-									"message": "Streaming not supported",
-									"type":    "internal_error",
-								},
-							})
-							return
-						}
-
-						// 使用 Gemini 专用流式处理，响应会转换为 Gemini 格式
-						err := proxyService.ProxyGeminiStreamRequest(body, headers, c.Writer, flusher)
-						if err != nil {
-							log.Errorf("Gemini stream proxy error: %v", err)
-							sendStreamError(c, flusher, err, "openai")
-						}
+						streamGeminiResponse(c, proxyService, body, headers, geminiJSONArray(c, false))
 						return
 					}
 				}
@@ -602,8 +627,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(statusCode, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "proxy_error",
+							"message":    err.Error(),
+							"type":       "proxy_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -613,91 +639,23 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 			})
 
 			// Gemini 模型指定接口
-			gemini.POST("/models/:model", func(c *gin.Context) {
-				// 从URL路径提取模型名
-				modelFromPath := c.Param("model")
-
-				// 读取请求体
-				body, err := io.ReadAll(c.Request.Body)
-				if err != nil {
-					c.JSON(http.StatusBadRequest, gin.H{
-						"error": gin.H{
-							"message": "Failed to read request body",
-							"type":    "invalid_request_error",
-						},
-					})
+			geminiModelAction := func(c *gin.Context) {
+				// 从URL路径提取模型名，兼容官方的 {model}:{action} 写法
+				modelFromPath, action := splitGeminiModelAction(c.Param("model"))
+				if handleGeminiAction(c, proxyService, modelFromPath, action) {
 					return
 				}
-
-				// 解析并注入模型名
-				var reqData map[string]interface{}
-				if err := json.Unmarshal(body, &reqData); err == nil {
-					reqData["model"] = modelFromPath
-					body, _ = json.Marshal(reqData)
-				}
-
-				// 提取请求头
-				headers := make(map[string]string)
-				for key, values := range c.Request.Header {
-					if len(values) > 0 {
-						headers[key] = values[0]
-					}
-				}
-
-				// 检查是否是流式请求
-				if stream, ok := reqData["stream"].(bool); ok && stream {
-					c.Header("Content-Type", "text/event-stream")
-					c.Header("Cache-Control", "no-cache")
-					c.Header("Connection", "keep-alive")
-					c.Header("X-Accel-Buffering", "no")
-
-					flusher, ok := c.Writer.(http.Flusher)
-					if !ok {
-						log.Errorf("Streaming not supported")
-						c.JSON(http.StatusInternalServerError, gin.H{
-							"error": gin.H{
-								"message": "Streaming not supported",
-								"type":    "internal_error",
-							},
-						})
-						return
-					}
-
-					// 使用 Gemini 专用流式处理
-					err := proxyService.ProxyGeminiStreamRequest(body, headers, c.Writer, flusher)
-					if err != nil {
-						log.Errorf("Gemini stream proxy error: %v", err)
-						sendStreamError(c, flusher, err, "openai")
-					}
-					return
-				}
-
-				// 非流式请求 - 使用 Gemini 专用处理
-				respBody, statusCode, err := proxyService.ProxyGeminiRequest(body, headers)
-				if err != nil {
-					c.JSON(statusCode, gin.H{
-						"error": gin.H{
-							"message": err.Error(),
-							"type":    "proxy_error",
-						},
-					})
-					return
-				}
-
-				c.Data(statusCode, "application/json", respBody)
-			})
-
-			gemini.POST("/:model", func(c *gin.Context) {
-				// 从URL路径提取模型名
-				modelFromPath := c.Param("model")
+				// 官方 SDK 路径按 Gemini 原始格式返回，不使用 {"code":200,"data":...} 包装
+				official := strings.HasPrefix(c.FullPath(), "/api/gemini/v1")
 
 				// 读取请求体
 				body, err := io.ReadAll(c.Request.Body)
 				if err != nil {
 					c.JSON(http.StatusBadRequest, gin.H{
 						"error": gin.H{
-							"message": "Failed to read request body",
-							"type":    "invalid_request_error",
+							"message":    "Failed to read request body",
+							"type":       "invalid_request_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -707,6 +665,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				var reqData map[string]interface{}
 				if err := json.Unmarshal(body, &reqData); err == nil {
 					reqData["model"] = modelFromPath
+					if action == geminiStreamAction {
+						reqData["stream"] = true
+					}
 					body, _ = json.Marshal(reqData)
 				}
 
@@ -720,29 +681,11 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 
 				// 检查是否是流式请求
 				if stream, ok := reqData["stream"].(bool); ok && stream {
-					c.Header("Content-Type", "text/event-stream")
-					c.Header("Cache-Control", "no-cache")
-					c.Header("Connection", "keep-alive")
-					c.Header("X-Accel-Buffering", "no")
-
-					flusher, ok := c.Writer.(http.Flusher)
-					if !ok {
-						log.Errorf("Streaming not supported")
-						c.JSON(http.StatusInternalServerError, gin.H{
-							"error": gin.H{
-								"message": "Streaming not supported",
-								"type":    "internal_error",
-							},
-						})
-						return
-					}
-
-					// 使用 Gemini 专用流式处理
-					err := proxyService.ProxyGeminiStreamRequest(body, headers, c.Writer, flusher)
-					if err != nil {
-						log.Errorf("Gemini stream proxy error: %v", err)
-						sendStreamError(c, flusher, err, "openai")
+					jsonArray := geminiJSONArray(c, action == geminiStreamAction)
+					if official && !jsonArray {
+						c.Writer = &geminiUnwrapSSE{ResponseWriter: c.Writer}
 					}
+					streamGeminiResponse(c, proxyService, body, headers, jsonArray)
 					return
 				}
 
@@ -751,15 +694,28 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(statusCode, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "proxy_error",
+							"message":    err.Error(),
+							"type":       "proxy_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
 				}
 
+				if official && statusCode == http.StatusOK {
+					respBody = unwrapGeminiElement(respBody)
+				}
 				c.Data(statusCode, "application/json", respBody)
-			})
+			}
+			gemini.POST("/models/:model", geminiModelAction)
+			gemini.POST("/:model", geminiModelAction)
+
+			// 官方 SDK 以 /api/gemini 为 base URL 时路径带 v1beta 或 v1 前缀
+			for _, version := range []string{"/v1beta", "/v1"} {
+				gemini.GET(version+"/models", geminiListModels)
+				gemini.GET(version+"/models/:model", geminiGetModel(cfg, routeService, "model"))
+				gemini.POST(version+"/models/:model", geminiModelAction)
+			}
 		}
 
 		// OpenAI 兼容接口 (默认)
@@ -768,20 +724,14 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 			// 列出可用模型（包含重定向关键字）
 			v1.GET("/models", func(c *gin.Context) {
 				// 获取包含重定向关键字的模型列表
-				var models []string
-				var err error
-
-				if cfg.RedirectEnabled && cfg.RedirectKeyword != "" {
-					models, err = routeService.GetAvailableModelsWithRedirect(cfg.RedirectKeyword)
-				} else {
-					models, err = routeService.GetAvailableModels()
-				}
+				models, err := listModels(c, cfg, routeService)
 
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "internal_error",
+							"message":    err.Error(),
+							"type":       "internal_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -789,12 +739,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 
 				modelsData := make([]gin.H, len(models))
 				for i, model := range models {
-					modelsData[i] = gin.H{
-						"id":       model,
-						"object":   "model",
-						"created":  1677610602,
-						"owned_by": "openai-router",
-					}
+					modelsData[i] = openAIModelInfo(model)
 				}
 
 				c.JSON(http.StatusOK, gin.H{
@@ -810,8 +755,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(http.StatusBadRequest, gin.H{
 						"error": gin.H{
-							"message": "Failed to read request body",
-							"type":    "invalid_request_error",
+							"message":    "Failed to read request body",
+							"type":       "invalid_request_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -839,11 +785,12 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 
 						flusher, ok := c.Writer.(http.Flusher)
 						if !ok {
-							log.Errorf("Streaming not supported")
+							service.RequestLogger(c.GetString("request_id")).Errorf("Streaming not supported")
 							c.JSON(http.StatusInternalServerError, gin.H{
 								"error": gin.H{
-									"message": "Streaming not supported",
-									"type":    "internal_error",
+									"message":    "Streaming not supported",
+									"type":       "internal_error",
+									"request_id": c.GetString("request_id"),
 								},
 							})
 							return
@@ -851,7 +798,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 
 						err := proxyService.ProxyStreamRequest(body, headers, c.Writer, flusher)
 						if err != nil {
-							log.Errorf("Stream proxy error: %v", err)
+							service.RequestLogger(c.GetString("request_id")).Errorf("Stream proxy error: %v", err)
 							sendStreamError(c, flusher, err, "openai")
 						}
 						return
@@ -863,8 +810,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				if err != nil {
 					c.JSON(statusCode, gin.H{
 						"error": gin.H{
-							"message": err.Error(),
-							"type":    "proxy_error",
+							"message":    err.Error(),
+							"type":       "proxy_error",
+							"request_id": c.GetString("request_id"),
 						},
 					})
 					return
@@ -873,13 +821,91 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				c.Data(statusCode, "application/json", respBody)
 			}
 
+			// 透传非对话接口：按原路径转发，响应体（音频、图片等二进制内容）原样返回
+			passthroughHandler := func(endpoint string) gin.HandlerFunc {
+				return func(c *gin.Context) {
+					body, err := io.ReadAll(c.Request.Body)
+					if err != nil {
+						c.JSON(http.StatusBadRequest, gin.H{
+							"error": gin.H{
+								"message":    "Failed to read request body",
+								"type":       "invalid_request_error",
+								"request_id": c.GetString("request_id"),
+							},
+						})
+						return
+					}
+
+					headers := make(map[string]string)
+					for key, values := range c.Request.Header {
+						if len(values) > 0 {
+							headers[key] = values[0]
+						}
+					}
+					headers["X-Real-IP"] = c.ClientIP()
+
+					statusCode, err := proxyService.ProxyPassthroughRequest(body, headers, endpoint, c.Writer)
+					if err != nil {
+						c.JSON(statusCode, gin.H{
+							"error": gin.H{
+								"message":    err.Error(),
+								"type":       "proxy_error",
+								"request_id": c.GetString("request_id"),
+							},
+						})
+					}
+				}
+			}
+
+			// multipart 上传接口（音频转写/翻译）：请求体原样转发，根据 model 表单字段选择路由
+			multipartHandler := func(endpoint string) gin.HandlerFunc {
+				return func(c *gin.Context) {
+					headers := make(map[string]string)
+					for key, values := range c.Request.Header {
+						if len(values) > 0 {
+							headers[key] = values[0]
+						}
+					}
+					headers["X-Real-IP"] = c.ClientIP()
+
+					statusCode, err := proxyService.ProxyMultipartRequest(c.Request.Body, headers, endpoint, c.Writer)
+					if err != nil {
+						c.JSON(statusCode, gin.H{
+							"error": gin.H{
+								"message":    err.Error(),
+								"type":       "proxy_error",
+								"request_id": c.GetString("request_id"),
+							},
+						})
+					}
+				}
+			}
+
 			// OpenAI 兼容接口
 			v1.POST("/chat/completions", proxyHandler)
 			v1.POST("/completions", proxyHandler)
-			v1.POST("/embeddings", proxyHandler)
-			v1.POST("/images/generations", proxyHandler)
-			v1.POST("/audio/transcriptions", proxyHandler)
-			v1.POST("/audio/speech", proxyHandler)
+			v1.POST("/embeddings", passthroughHandler("embeddings"))
+			v1.POST("/images/generations", passthroughHandler("images/generations"))
+			v1.POST("/audio/transcriptions", multipartHandler("audio/transcriptions"))
+			v1.POST("/audio/translations", multipartHandler("audio/translations"))
+			v1.POST("/audio/speech", passthroughHandler("audio/speech"))
+
+			// Files / Batch API：本地执行的批处理
+			registerBatchRoutes(v1, routeService, proxyService)
+
+			// Realtime API：WebSocket 握手后双向转发，会话结束时记录用量
+			v1.GET("/realtime", func(c *gin.Context) {
+				statusCode, err := proxyService.ProxyRealtime(c.Writer, c.Request, c.GetString("request_id"), c.ClientIP())
+				if err != nil {
+					c.JSON(statusCode, gin.H{
+						"error": gin.H{
+							"message":    err.Error(),
+							"type":       "proxy_error",
+							"request_id": c.GetString("request_id"),
+						},
+					})
+				}
+			})
 
 			// Gemini 官方 API 格式兼容
 			// 路径: /api/v1/gemini/models/{model}:generateContent
@@ -889,20 +915,14 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 				// 列出可用模型 - Gemini 格式
 				geminiV1.GET("/models", func(c *gin.Context) {
 					// 获取包含重定向关键字的模型列表
-					var models []string
-					var err error
-
-					if cfg.RedirectEnabled && cfg.RedirectKeyword != "" {
-						models, err = routeService.GetAvailableModelsWithRedirect(cfg.RedirectKeyword)
-					} else {
-						models, err = routeService.GetAvailableModels()
-					}
+					models, err := listModels(c, cfg, routeService)
 
 					if err != nil {
 						c.JSON(http.StatusInternalServerError, gin.H{
 							"error": gin.H{
-								"message": err.Error(),
-								"type":    "internal_error",
+								"message":    err.Error(),
+								"type":       "internal_error",
+								"request_id": c.GetString("request_id"),
 							},
 						})
 						return
@@ -911,15 +931,7 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 					// Gemini 格式的模型列表
 					modelsData := make([]gin.H, len(models))
 					for i, model := range models {
-						modelsData[i] = gin.H{
-							"name":                       "models/" + model,
-							"version":                    "001",
-							"displayName":                model,
-							"description":                "Model " + model,
-							"inputTokenLimit":            1048576,
-							"outputTokenLimit":           8192,
-							"supportedGenerationMethods": []string{"generateContent", "streamGenerateContent"},
-						}
+						modelsData[i] = geminiModelInfo(model)
 					}
 
 					c.JSON(http.StatusOK, gin.H{
@@ -927,6 +939,8 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 					})
 				})
 
+				geminiV1.GET("/models/:modelAction", geminiGetModel(cfg, routeService, "modelAction"))
+
 				// 使用通配符捕获整个路径
 				geminiV1.POST("/models/:modelAction", func(c *gin.Context) {
 					modelAction := c.Param("modelAction")
@@ -938,8 +952,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 					if len(parts) < 2 {
 						c.JSON(http.StatusBadRequest, gin.H{
 							"error": gin.H{
-								"message": "Invalid Gemini API path format. Expected: /models/{model}:{action}",
-								"type":    "invalid_request_error",
+								"message":    "Invalid Gemini API path format. Expected: /models/{model}:{action}",
+								"type":       "invalid_request_error",
+								"request_id": c.GetString("request_id"),
 							},
 						})
 						return
@@ -948,6 +963,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 					modelName := parts[0]
 					actionType := parts[1]
 					isStream := actionType == "streamGenerateContent"
+					if handleGeminiAction(c, proxyService, modelName, actionType) {
+						return
+					}
 
 					log.Infof("[Gemini API] Model: %s, Action: %s, Stream: %v", modelName, actionType, isStream)
 
@@ -956,8 +974,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 					if err != nil {
 						c.JSON(http.StatusBadRequest, gin.H{
 							"error": gin.H{
-								"message": "Failed to read request body",
-								"type":    "invalid_request_error",
+								"message":    "Failed to read request body",
+								"type":       "invalid_request_error",
+								"request_id": c.GetString("request_id"),
 							},
 						})
 						return
@@ -968,8 +987,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 					if err := json.Unmarshal(body, &geminiReq); err != nil {
 						c.JSON(http.StatusBadRequest, gin.H{
 							"error": gin.H{
-								"message": "Invalid JSON body: " + err.Error(),
-								"type":    "invalid_request_error",
+								"message":    "Invalid JSON body: " + err.Error(),
+								"type":       "invalid_request_error",
+								"request_id": c.GetString("request_id"),
 							},
 						})
 						return
@@ -991,29 +1011,8 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 					}
 
 					if isStream {
-						// 流式请求
-						c.Header("Content-Type", "text/event-stream")
-						c.Header("Cache-Control", "no-cache")
-						c.Header("Connection", "keep-alive")
-						c.Header("X-Accel-Buffering", "no")
-
-						flusher, ok := c.Writer.(http.Flusher)
-						if !ok {
-							c.JSON(http.StatusInternalServerError, gin.H{
-								"error": gin.H{
-									"message": "Streaming not supported",
-									"type":    "internal_error",
-								},
-							})
-							return
-						}
-
-						// 使用 Gemini 专用流式处理
-						err := proxyService.ProxyGeminiStreamRequest(body, headers, c.Writer, flusher)
-						if err != nil {
-							log.Errorf("Gemini stream proxy error: %v", err)
-							sendStreamError(c, flusher, err, "openai")
-						}
+						// 流式请求：默认 JSON 数组，alt=sse 时为 SSE
+						streamGeminiResponse(c, proxyService, body, headers, geminiJSONArray(c, true))
 						return
 					}
 
@@ -1022,8 +1021,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 					if err != nil {
 						c.JSON(statusCode, gin.H{
 							"error": gin.H{
-								"message": err.Error(),
-								"type":    "proxy_error",
+								"message":    err.Error(),
+								"type":       "proxy_error",
+								"request_id": c.GetString("request_id"),
 							},
 						})
 						return
@@ -1041,21 +1041,70 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": gin.H{
-					"message": "Invalid request format: " + err.Error(),
-					"type":    "invalid_request_error",
+					"message":    "Invalid request format: " + err.Error(),
+					"type":       "invalid_request_error",
+					"request_id": c.GetString("request_id"),
 				},
 			})
 			return
 		}
 
+		// 提取请求头（请求ID、客户端 IP、路由覆盖），与直接调用 API 一样选择路由并记录日志
+		headers := make(map[string]string)
+		for key, values := range c.Request.Header {
+			if len(values) > 0 {
+				headers[key] = values[0]
+			}
+		}
+		headers["X-Real-IP"] = c.ClientIP()
+
+		// Compare mode: same prompt to several routes, provider defaults to each route's format
+		if req.IsCompare() {
+			response, err := conversationService.CompareConversation(req, headers)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": gin.H{
+						"message":    err.Error(),
+						"type":       "invalid_request_error",
+						"request_id": c.GetString("request_id"),
+					},
+				})
+				return
+			}
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		// Aggregate mode: fan out and return a single answer chosen by the strategy
+		if req.IsAggregate() {
+			response, err := conversationService.AggregateConversation(req, headers)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": gin.H{
+						"message":    err.Error(),
+						"type":       "invalid_request_error",
+						"request_id": c.GetString("request_id"),
+					},
+				})
+				return
+			}
+			if response.Error != "" {
+				c.JSON(http.StatusBadGateway, response)
+				return
+			}
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
 		// Validate provider
 		if !strings.Contains(strings.ToLower(req.Provider), "openai") &&
 			!strings.Contains(strings.ToLower(req.Provider), "claude") &&
 			!strings.Contains(strings.ToLower(req.Provider), "gemini") {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": gin.H{
-					"message": "Provider must be one of: openai, claude, gemini",
-					"type":    "invalid_request_error",
+					"message":    "Provider must be one of: openai, claude, gemini",
+					"type":       "invalid_request_error",
+					"request_id": c.GetString("request_id"),
 				},
 			})
 			return
@@ -1072,37 +1121,29 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 			if !ok {
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": gin.H{
-						"message": "Streaming not supported",
-						"type":    "internal_error",
+						"message":    "Streaming not supported",
+						"type":       "internal_error",
+						"request_id": c.GetString("request_id"),
 					},
 				})
 				return
 			}
 
-			// For streaming, we need to handle this differently based on provider
-			// This is a simplified implementation - in practice, you'd want to
-			// stream the actual response from the provider
-			go func() {
-				response, err := conversationService.SendConversation(req)
-				if err != nil {
-					c.Writer.Write([]byte("data: " + `{"error": "` + err.Error() + `"}` + "\n\n"))
-				} else {
-					c.Writer.Write([]byte("data: " + `{"provider": "` + response.Provider + `", "content": "` + response.Content + `"}` + "\n\n"))
-				}
-				c.Writer.Write([]byte("data: [DONE]\n\n"))
-				flusher.Flush()
-			}()
-
+			// 在请求处理内同步写出流式响应，错误以 error 事件返回
+			if err := conversationService.StreamConversation(req, headers, c.Writer, flusher); err != nil {
+				service.RequestLogger(c.GetString("request_id")).Errorf("Conversation stream error: %v", err)
+			}
 			return
 		}
 
 		// Handle non-streaming request
-		response, err := conversationService.SendConversation(req)
+		response, err := conversationService.SendConversation(req, headers)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": gin.H{
-					"message": err.Error(),
-					"type":    "conversation_error",
+					"message":    err.Error(),
+					"type":       "conversation_error",
+					"request_id": c.GetString("request_id"),
 				},
 			})
 			return
@@ -1111,8 +1152,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		if response.Error != "" {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": gin.H{
-					"message": response.Error,
-					"type":    "provider_error",
+					"message":    response.Error,
+					"type":       "provider_error",
+					"request_id": c.GetString("request_id"),
 				},
 			})
 			return
@@ -1153,13 +1195,20 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		if success := c.Query("success"); success != "" {
 			filters["success"] = success
 		}
+		if requestID := c.Query("request_id"); requestID != "" {
+			filters["request_id"] = requestID
+		}
+		if search := c.Query("q"); search != "" {
+			filters["search"] = search
+		}
 
 		logs, total, err := routeService.GetRequestLogs(page, pageSize, filters)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": gin.H{
-					"message": "Failed to get request logs: " + err.Error(),
-					"type":    "internal_error",
+					"message":    "Failed to get request logs: " + err.Error(),
+					"type":       "internal_error",
+					"request_id": c.GetString("request_id"),
 				},
 			})
 			return
@@ -1173,9 +1222,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		})
 	})
 
-	// SDK Examples endpoint
+	// SDK Examples endpoint（?model= 指定客户端配置片段中使用的模型）
 	api.GET("/sdk-examples", func(c *gin.Context) {
-		examples := conversationService.GetSDKExamples()
+		examples := conversationService.GetSDKExamples(c.Query("model"))
 		c.JSON(http.StatusOK, gin.H{
 			"examples": examples,
 		})
@@ -1187,8 +1236,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": gin.H{
-					"message": "Failed to get models: " + err.Error(),
-					"type":    "internal_error",
+					"message":    "Failed to get models: " + err.Error(),
+					"type":       "internal_error",
+					"request_id": c.GetString("request_id"),
 				},
 			})
 			return
@@ -1202,6 +1252,9 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 	// Gemini 流式生成接口 (支持 streamGenerateContent)
 	// 这个接口已经通过适配器逻辑处理，不需要单独的路由
 
+	// 管理接口：只允许本地 API Key
+	registerAdminRoutes(r.Group("/api/admin", adminAuth(cfg)), cfg, routeService, proxyService)
+
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -1209,5 +1262,8 @@ func SetupAPIRouter(cfg *config.Config, routeService *service.RouteService, prox
 		})
 	})
 
+	// OpenAPI 文档
+	registerOpenAPI(r, cfg)
+
 	return r
 }
@@ -0,0 +1,245 @@
+package router
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// listener 单个监听地址
+type listener struct {
+	addr string
+	srv  *http.Server
+	ln   net.Listener
+}
+
+// Server 可热切换监听地址的 API 服务器，支持同时监听多个地址
+type Server struct {
+	handler   http.Handler
+	mu        sync.Mutex
+	listeners []*listener
+	tlsConfig *tls.Config
+}
+
+// NewServer 创建 API 服务器
+func NewServer(handler http.Handler) *Server {
+	return &Server{handler: handler}
+}
+
+// Handler API 的请求处理器，用于在进程内发起请求（Playground）
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Addr 当前主监听地址
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.listeners) == 0 {
+		return ""
+	}
+	return s.listeners[0].addr
+}
+
+// Addrs 当前所有监听地址
+func (s *Server) Addrs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addrsLocked()
+}
+
+func (s *Server) addrsLocked() []string {
+	addrs := make([]string, 0, len(s.listeners))
+	for _, l := range s.listeners {
+		addrs = append(addrs, l.addr)
+	}
+	return addrs
+}
+
+// IsTLS 当前是否以 HTTPS 方式监听
+func (s *Server) IsTLS() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tlsConfig != nil
+}
+
+// Start 在指定地址开始监听（先完成绑定再返回，便于调用方获知端口占用错误）
+// tlsConfig 为 nil 时使用 HTTP
+func (s *Server) Start(addrs []string, tlsConfig *tls.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listeners, err := s.listenAll(addrs, tlsConfig)
+	if err != nil {
+		return err
+	}
+	s.listeners = listeners
+	s.tlsConfig = tlsConfig
+	return nil
+}
+
+// Rebind 切换到新的监听地址（保持当前 HTTP/HTTPS 设置）
+func (s *Server) Rebind(addrs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.listeners) > 0 && sameAddrs(addrs, s.addrsLocked()) {
+		return nil
+	}
+	return s.restartLocked(addrs, s.tlsConfig)
+}
+
+// Reconfigure 使用新的地址和 TLS 设置重新监听（地址相同时也会重启监听）
+func (s *Server) Reconfigure(addrs []string, tlsConfig *tls.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restartLocked(addrs, tlsConfig)
+}
+
+// restartLocked 重新监听：先绑定新地址，成功后再优雅关闭旧监听
+// 旧连接上正在进行的请求（包括流式请求）会继续处理完成
+func (s *Server) restartLocked(addrs []string, tlsConfig *tls.Config) error {
+	old := s.listeners
+	oldAddrs := s.addrsLocked()
+
+	// 新旧地址相同的监听必须先释放端口
+	wanted := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		wanted[addr] = true
+	}
+	var released []*listener
+	for _, l := range old {
+		if wanted[l.addr] {
+			l.ln.Close()
+			released = append(released, l)
+		}
+	}
+
+	listeners, err := s.listenAll(addrs, tlsConfig)
+	if err != nil {
+		// 恢复已释放的旧监听，避免服务中断
+		restored := make([]*listener, 0, len(old))
+		for _, l := range old {
+			if !containsListener(released, l) {
+				restored = append(restored, l)
+				continue
+			}
+			srv, ln, restoreErr := s.listen(l.addr, s.tlsConfig)
+			if restoreErr != nil {
+				log.Errorf("Failed to restore API listener on %s: %v", l.addr, restoreErr)
+			} else {
+				restored = append(restored, &listener{addr: l.addr, srv: srv, ln: ln})
+			}
+			go l.srv.Shutdown(context.Background())
+		}
+		s.listeners = restored
+		return err
+	}
+
+	s.listeners = listeners
+	s.tlsConfig = tlsConfig
+	log.Infof("API server rebound from %v to %v (tls: %v)", oldAddrs, addrs, tlsConfig != nil)
+
+	for _, l := range old {
+		go func(l *listener) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			defer cancel()
+			if err := l.srv.Shutdown(ctx); err != nil {
+				log.Warnf("Old API listener %s did not shut down cleanly: %v", l.addr, err)
+				l.srv.Close()
+			}
+		}(l)
+	}
+	return nil
+}
+
+// Shutdown 关闭服务器
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	for _, l := range s.listeners {
+		if err := l.srv.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.listeners = nil
+	return firstErr
+}
+
+// listenAll 绑定所有地址，任一失败时关闭已打开的监听
+func (s *Server) listenAll(addrs []string, tlsConfig *tls.Config) ([]*listener, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no listen address configured")
+	}
+	listeners := make([]*listener, 0, len(addrs))
+	for _, addr := range addrs {
+		srv, ln, err := s.listen(addr, tlsConfig)
+		if err != nil {
+			for _, l := range listeners {
+				l.srv.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, &listener{addr: addr, srv: srv, ln: ln})
+	}
+	return listeners, nil
+}
+
+// listen 绑定地址并在后台开始服务
+func (s *Server) listen(addr string, tlsConfig *tls.Config) (*http.Server, net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scheme := "http"
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+		scheme = "https"
+	}
+
+	srv := &http.Server{Handler: s.handler, TLSConfig: tlsConfig}
+	go func() {
+		log.Infof("API server started at %s://%s/api", scheme, addr)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+			log.Errorf("API server on %s stopped: %v", addr, err)
+		}
+	}()
+	return srv, ln, nil
+}
+
+// sameAddrs 比较两组监听地址（忽略顺序和大小写）
+func sameAddrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]int, len(a))
+	for _, addr := range a {
+		set[strings.ToLower(addr)]++
+	}
+	for _, addr := range b {
+		key := strings.ToLower(addr)
+		if set[key] == 0 {
+			return false
+		}
+		set[key]--
+	}
+	return true
+}
+
+func containsListener(list []*listener, target *listener) bool {
+	for _, l := range list {
+		if l == target {
+			return true
+		}
+	}
+	return false
+}
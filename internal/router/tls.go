@@ -0,0 +1,107 @@
+package router
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCertFile = "certs/server.crt"
+	defaultKeyFile  = "certs/server.key"
+)
+
+// LoadTLSConfig 加载证书和私钥
+// 未配置路径或文件不存在时自动生成自签名证书（仅对 localhost / 127.0.0.1 有效）
+func LoadTLSConfig(certPath, keyPath string) (*tls.Config, error) {
+	if certPath == "" {
+		certPath = defaultCertFile
+	}
+	if keyPath == "" {
+		keyPath = defaultKeyFile
+	}
+
+	if !fileExists(certPath) || !fileExists(keyPath) {
+		if fileExists(certPath) != fileExists(keyPath) {
+			return nil, fmt.Errorf("certificate and key must both exist: %s, %s", certPath, keyPath)
+		}
+		if err := generateSelfSignedCert(certPath, keyPath); err != nil {
+			return nil, fmt.Errorf("failed to generate self-signed certificate: %v", err)
+		}
+		log.Infof("Generated self-signed certificate: %s", certPath)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %v", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"http/1.1"},
+	}, nil
+}
+
+// generateSelfSignedCert 生成 ECDSA P-256 自签名证书，有效期 10 年
+func generateSelfSignedCert(certPath, keyPath string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "AnyProxyAi Local", Organization: []string{"AnyProxyAi"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []string{certPath, keyPath} {
+		if dir := filepath.Dir(p); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"net/http"
+
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// traceConversions 启用 Traces 时记录请求格式转换后发往上游的内容和上游的原始响应，随 Trace 一起保存
+func traceConversions(proxyService *service.ProxyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		defer proxyService.CaptureConversions(c.GetString("request_id"))()
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package router
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"openai-router-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// usageCaptureWriter 将写给客户端的响应同时交给 UsageCapture
+type usageCaptureWriter struct {
+	gin.ResponseWriter
+	capture *service.UsageCapture
+}
+
+func (w *usageCaptureWriter) Write(data []byte) (int, error) {
+	w.capture.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *usageCaptureWriter) WriteString(s string) (int, error) {
+	w.capture.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// usageCapture 记录请求体和响应，上游没有返回 usage 时在请求结束后估算 token（multipart 上传不处理）
+func usageCapture(routeService *service.RouteService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/") {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		capture := routeService.BeginUsageCapture(c.GetString("request_id"), body)
+		defer routeService.EndUsageCapture(capture)
+		c.Writer = &usageCaptureWriter{ResponseWriter: c.Writer, capture: capture}
+		c.Next()
+	}
+}
@@ -0,0 +1,184 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"sync"
+	"time"
+
+	"openai-router-go/internal/config"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// 网页管理界面：在 API 服务器的 /admin 下提供桌面端同一套前端，用于没有桌面窗口的部署
+// 使用本地 API Key 登录后通过 Cookie 会话访问，前端调用 /admin/rpc/<方法名> 转发到 AppService
+
+const (
+	webAdminCookie     = "anyproxy_admin_session"
+	webAdminSessionTTL = 12 * time.Hour
+)
+
+// AdminInvoker 按方法名调用 AppService，args 为 JSON 编码的参数列表
+type AdminInvoker func(method string, args []json.RawMessage) (interface{}, error)
+
+// webAdminSessions 登录会话（只保存在内存中，重启后需要重新登录）
+type webAdminSessions struct {
+	mu       sync.Mutex
+	sessions map[string]time.Time // token -> 过期时间
+}
+
+func (s *webAdminSessions) create() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for t, expires := range s.sessions {
+		if now.After(expires) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = now.Add(webAdminSessionTTL)
+	return token, nil
+}
+
+func (s *webAdminSessions) valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.sessions[token]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(s.sessions, token)
+		return false
+	}
+	return true
+}
+
+func (s *webAdminSessions) remove(token string) {
+	s.mu.Lock()
+	delete(s.sessions, token)
+	s.mu.Unlock()
+}
+
+var webAdminLoginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>AnyProxyAi Admin</title>
+<style>
+body{font-family:system-ui,sans-serif;background:#1b2636;color:#eee;display:flex;align-items:center;justify-content:center;height:100vh;margin:0}
+form{background:#243247;padding:32px;border-radius:8px;width:320px}
+input{width:100%;box-sizing:border-box;padding:8px;margin:12px 0;border-radius:4px;border:1px solid #456;background:#1b2636;color:#eee}
+button{width:100%;padding:8px;border:0;border-radius:4px;background:#18a058;color:#fff;cursor:pointer}
+.error{color:#e88080;font-size:13px}
+</style></head>
+<body><form method="POST" action="/admin/login">
+<h3>AnyProxyAi Admin</h3>
+<input type="password" name="key" placeholder="Local API Key" autofocus>
+{{if .}}<div class="error">{{.}}</div>{{end}}
+<button type="submit">Sign in</button>
+</form></body></html>`))
+
+// RegisterWebAdmin 在 /admin 注册网页管理界面，assets 为前端构建产物（包含 index.html）
+// 需要开启 web_admin_enabled 并配置本地 API Key，否则返回 404/403
+func RegisterWebAdmin(r *gin.Engine, cfg *config.Config, assets fs.FS, invoke AdminInvoker) {
+	sessions := &webAdminSessions{sessions: make(map[string]time.Time)}
+	fileServer := http.StripPrefix("/admin", http.FileServer(http.FS(assets)))
+
+	admin := r.Group("/admin", func(c *gin.Context) {
+		if !cfg.WebAdminEnabled {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if cfg.AuthKey() == "" {
+			c.String(http.StatusForbidden, "Web admin requires a local API key")
+			c.Abort()
+			return
+		}
+		c.Next()
+	})
+
+	loggedIn := func(c *gin.Context) bool {
+		token, _ := c.Cookie(webAdminCookie)
+		return sessions.valid(token)
+	}
+
+	admin.POST("/login", func(c *gin.Context) {
+		key := c.PostForm("key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(cfg.AuthKey())) != 1 {
+			log.Warnf("[Web Admin] Failed login from %s", c.ClientIP())
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			c.Status(http.StatusUnauthorized)
+			webAdminLoginPage.Execute(c.Writer, "Invalid API key")
+			return
+		}
+		token, err := sessions.create()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.SetSameSite(http.SameSiteStrictMode)
+		c.SetCookie(webAdminCookie, token, int(webAdminSessionTTL.Seconds()), "/admin", "", c.Request.TLS != nil, true)
+		log.Infof("[Web Admin] Login from %s", c.ClientIP())
+		c.Redirect(http.StatusSeeOther, "/admin/")
+	})
+
+	admin.POST("/logout", func(c *gin.Context) {
+		token, _ := c.Cookie(webAdminCookie)
+		sessions.remove(token)
+		c.SetCookie(webAdminCookie, "", -1, "/admin", "", c.Request.TLS != nil, true)
+		c.Redirect(http.StatusSeeOther, "/admin/login")
+	})
+
+	// 前端调用：请求体为参数数组，返回方法的结果
+	admin.POST("/rpc/:method", func(c *gin.Context) {
+		if !loggedIn(c) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "not logged in"})
+			return
+		}
+		var args []json.RawMessage
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&args); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "request body must be a JSON array of arguments"})
+				return
+			}
+		}
+		result, err := invoke(c.Param("method"), args)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"result": result})
+	})
+
+	// 登录页和前端静态文件（gin 不允许通配路由与同级静态路由共存，登录页在这里处理），未登录时跳转到登录页
+	admin.GET("/*filepath", func(c *gin.Context) {
+		if c.Param("filepath") == "/login" {
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			webAdminLoginPage.Execute(c.Writer, "")
+			return
+		}
+		if !loggedIn(c) {
+			c.Redirect(http.StatusSeeOther, "/admin/login")
+			return
+		}
+		fileServer.ServeHTTP(c.Writer, c.Request)
+	})
+	r.GET("/admin", func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, "/admin/")
+	})
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package secret
+
+import "errors"
+
+var errProtectUnsupported = errors.New("OS credential protection is not supported on this platform")
+
+func protectKey(data []byte) ([]byte, error) {
+	return nil, errProtectUnsupported
+}
+
+func unprotectKey(data []byte) ([]byte, error) {
+	return nil, errProtectUnsupported
+}
@@ -0,0 +1,257 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 加密值前缀，未带前缀的值视为旧版明文
+const encPrefix = "enc:v1:"
+
+// 数据密钥的保护方式
+const (
+	ProtectionPassword = "password" // 主密码派生的密钥加密
+	ProtectionOS       = "os"       // 操作系统凭据保护（Windows DPAPI）
+	ProtectionFile     = "file"     // 仅依赖文件权限（其它平台且未设置主密码时）
+)
+
+const pbkdf2Iterations = 600000
+
+// keyFile 数据密钥文件格式（信封加密：数据密钥由主密码或系统凭据再加密）
+type keyFile struct {
+	Version    int    `json:"version"`
+	Protection string `json:"protection"`
+	Salt       string `json:"salt,omitempty"`
+	DataKey    string `json:"data_key"`
+}
+
+// Box 使用 AES-256-GCM 加解密敏感字段
+type Box struct {
+	aead       cipher.AEAD
+	protection string
+}
+
+// Protection 当前数据密钥的保护方式
+func (b *Box) Protection() string {
+	return b.protection
+}
+
+// IsEncrypted 判断值是否已加密
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encPrefix)
+}
+
+// Encrypt 加密字符串，空值和已加密的值原样返回
+func (b *Box) Encrypt(plain string) (string, error) {
+	if plain == "" || IsEncrypted(plain) {
+		return plain, nil
+	}
+	sealed, err := seal(b.aead, []byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密字符串，未加密的旧数据原样返回
+func (b *Box) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %v", err)
+	}
+	plain, err := open(b.aead, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %v", err)
+	}
+	return string(plain), nil
+}
+
+// Load 读取已有的数据密钥文件，文件不存在时返回 os.ErrNotExist
+func Load(keyPath, password string) (*Box, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", keyPath, err)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(kf.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", keyPath, err)
+	}
+
+	var dataKey []byte
+	switch kf.Protection {
+	case ProtectionPassword:
+		if password == "" {
+			return nil, errors.New("master password is required to unlock API keys")
+		}
+		salt, err := base64.StdEncoding.DecodeString(kf.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file %s: %v", keyPath, err)
+		}
+		kek, err := deriveKey(password, salt)
+		if err != nil {
+			return nil, err
+		}
+		if dataKey, err = open(kek, wrapped); err != nil {
+			return nil, errors.New("wrong master password")
+		}
+	case ProtectionOS:
+		if dataKey, err = unprotectKey(wrapped); err != nil {
+			return nil, fmt.Errorf("failed to unprotect data key: %v", err)
+		}
+	case ProtectionFile:
+		dataKey = wrapped
+	default:
+		return nil, fmt.Errorf("unknown key protection: %s", kf.Protection)
+	}
+
+	box, err := newBox(dataKey, kf.Protection)
+	if err != nil {
+		return nil, err
+	}
+
+	// 设置了主密码但密钥文件尚未使用主密码保护时，改用主密码重新保护
+	if password != "" && kf.Protection != ProtectionPassword {
+		if err := writeKeyFile(keyPath, dataKey, password); err != nil {
+			log.Warnf("Failed to re-protect data key with master password: %v", err)
+		} else {
+			box.protection = ProtectionPassword
+			log.Info("Data key re-protected with master password")
+		}
+	}
+	return box, nil
+}
+
+// LoadOrCreate 读取数据密钥，不存在时生成新的密钥文件
+// 设置 password 时使用主密码保护，否则优先使用系统凭据保护（Windows DPAPI）
+func LoadOrCreate(keyPath, password string) (*Box, error) {
+	box, err := Load(keyPath, password)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return box, err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	if err := writeKeyFile(keyPath, dataKey, password); err != nil {
+		return nil, err
+	}
+	log.Infof("Generated new data key: %s", keyPath)
+	return Load(keyPath, password)
+}
+
+// writeKeyFile 保护并写入数据密钥
+func writeKeyFile(keyPath string, dataKey []byte, password string) error {
+	kf := keyFile{Version: 1}
+	switch {
+	case password != "":
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		kek, err := deriveKey(password, salt)
+		if err != nil {
+			return err
+		}
+		wrapped, err := seal(kek, dataKey)
+		if err != nil {
+			return err
+		}
+		kf.Protection = ProtectionPassword
+		kf.Salt = base64.StdEncoding.EncodeToString(salt)
+		kf.DataKey = base64.StdEncoding.EncodeToString(wrapped)
+	default:
+		if wrapped, err := protectKey(dataKey); err == nil {
+			kf.Protection = ProtectionOS
+			kf.DataKey = base64.StdEncoding.EncodeToString(wrapped)
+		} else {
+			log.Warnf("OS credential protection unavailable (%v), data key is protected by file permissions only; set a master password for stronger protection", err)
+			kf.Protection = ProtectionFile
+			kf.DataKey = base64.StdEncoding.EncodeToString(dataKey)
+		}
+	}
+
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(keyPath); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(keyPath, data, 0600)
+}
+
+// NewPasswordBox 使用密码和盐派生的密钥创建 Box，用于导出文件等需要在其它机器上解密的场景
+func NewPasswordBox(password string, salt []byte) (*Box, error) {
+	if password == "" {
+		return nil, errors.New("password is required")
+	}
+	aead, err := deriveKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead, protection: ProtectionPassword}, nil
+}
+
+func newBox(dataKey []byte, protection string) (*Box, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead, protection: protection}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func deriveKey(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, password, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+// seal 加密，输出格式为 nonce || ciphertext
+func seal(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 活动请求监视：在内存中登记进行中的代理请求（开始时间、模型、路由、客户端 IP、已发送给客户端的字节数），
+// 可在 GUI 中中止失控的流式请求。doWithRetry 发送的上游请求都绑定到登记时创建的 context，中止时上游连接随之断开
+
+// ActiveRequest 一个进行中的请求
+type ActiveRequest struct {
+	RequestID string `json:"request_id"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Model     string `json:"model"` // 选择路由前为空
+	Route     string `json:"route"` // 当前尝试的路由名，Fallback 时更新
+	ClientIP  string `json:"client_ip"`
+	StartedAt string `json:"started_at"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+	BytesSent int64  `json:"bytes_sent"`
+	Cancelled bool   `json:"cancelled"` // 已中止，等待处理结束
+}
+
+type activeRequest struct {
+	info      ActiveRequest
+	started   time.Time
+	bytesSent func() int
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// activeRequests 进行中的请求（请求ID -> 请求）
+type activeRequests struct {
+	mu       sync.Mutex
+	requests map[string]*activeRequest
+}
+
+func newActiveRequests() *activeRequests {
+	return &activeRequests{requests: make(map[string]*activeRequest)}
+}
+
+// TrackRequest 登记进行中的请求，请求处理结束时调用返回的函数；bytesSent 返回已发送给客户端的字节数
+func (s *ProxyService) TrackRequest(requestID, method, path, clientIP string, bytesSent func() int) func() {
+	if requestID == "" {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &activeRequest{
+		info: ActiveRequest{
+			RequestID: requestID,
+			Method:    method,
+			Path:      path,
+			ClientIP:  clientIP,
+		},
+		started:   time.Now(),
+		bytesSent: bytesSent,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	a := s.active
+	a.mu.Lock()
+	a.requests[requestID] = r
+	a.mu.Unlock()
+	return func() {
+		a.mu.Lock()
+		// 客户端可能复用 X-Request-ID，只移除自己登记的请求
+		if a.requests[requestID] == r {
+			delete(a.requests, requestID)
+		}
+		a.mu.Unlock()
+		cancel()
+	}
+}
+
+// update 修改进行中的请求信息，请求未登记时忽略
+func (a *activeRequests) update(requestID string, fn func(info *ActiveRequest)) {
+	if requestID == "" {
+		return
+	}
+	a.mu.Lock()
+	if r := a.requests[requestID]; r != nil {
+		fn(&r.info)
+	}
+	a.mu.Unlock()
+}
+
+// bind 将上游请求绑定到进行中请求的 context，请求被中止时上游请求随之取消
+func (a *activeRequests) bind(req *http.Request) *http.Request {
+	requestID := requestIDFromContext(req.Context())
+	if requestID == "" {
+		return req
+	}
+	a.mu.Lock()
+	r := a.requests[requestID]
+	a.mu.Unlock()
+	if r == nil {
+		return req
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	// 请求结束时 TrackRequest 返回的函数会取消 r.ctx，这里注册的回调随之释放
+	context.AfterFunc(r.ctx, cancel)
+	if r.ctx.Err() != nil {
+		// 已中止的请求 Fallback 到其他路由时不再发送
+		cancel()
+	}
+	return req.WithContext(ctx)
+}
+
+// GetActiveRequests 进行中的请求，最早开始的在前
+func (s *ProxyService) GetActiveRequests() []ActiveRequest {
+	s.active.mu.Lock()
+	defer s.active.mu.Unlock()
+	now := time.Now()
+	result := make([]ActiveRequest, 0, len(s.active.requests))
+	for _, r := range s.active.requests {
+		info := r.info
+		info.StartedAt = r.started.Format("2006-01-02 15:04:05")
+		info.ElapsedMs = now.Sub(r.started).Milliseconds()
+		if r.bytesSent != nil {
+			info.BytesSent = int64(max(r.bytesSent(), 0))
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ElapsedMs > result[j].ElapsedMs })
+	return result
+}
+
+// CancelRequest 中止进行中的请求：取消发往上游的请求，处理函数读取上游响应失败后结束
+func (s *ProxyService) CancelRequest(requestID string) error {
+	s.active.mu.Lock()
+	r := s.active.requests[requestID]
+	if r != nil {
+		r.info.Cancelled = true
+	}
+	s.active.mu.Unlock()
+	if r == nil {
+		return fmt.Errorf("request %s is not active", requestID)
+	}
+	log.Warnf("[%s] Request cancelled from the active request monitor (model: %s, route: %s)", requestID, r.info.Model, r.info.Route)
+	r.cancel()
+	return nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 适配器插件：路由格式设为插件名称时，OpenAI 格式的请求（包括转换后的 Cursor 请求）由插件转换后发往上游，
+// 响应（流式逐块）再由插件转换回 OpenAI 格式。插件在启动和保存设置时重新加载，其他格式的请求按 OpenAI 兼容接口处理
+
+// pluginNamePattern 插件名称只能包含小写字母、数字、_ 和 -
+var pluginNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// builtinFormats 内置的格式名称，插件不能使用
+var builtinFormats = map[string]bool{
+	"openai": true, "gpt": true, "claude": true, "anthropic": true, "gemini": true, "google": true,
+	"ollama": true, "mistral": true, "xai": true, "cohere": true, "deepseek": true, "cursor": true, "claudecode": true,
+}
+
+// ValidateAdapterPlugins 校验插件配置（保存前调用），启用的插件程序必须存在
+func ValidateAdapterPlugins(plugins []config.AdapterPlugin) error {
+	seen := make(map[string]bool)
+	for _, p := range plugins {
+		name := strings.ToLower(strings.TrimSpace(p.Name))
+		if !pluginNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid plugin name %q: use lowercase letters, digits, _ and -", p.Name)
+		}
+		if builtinFormats[name] {
+			return fmt.Errorf("plugin name %q is a built-in format", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate plugin name %q", name)
+		}
+		seen[name] = true
+		if strings.TrimSpace(p.Command) == "" {
+			return fmt.Errorf("%s: command is required", name)
+		}
+		if p.Enabled {
+			if _, err := exec.LookPath(p.Command); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		if p.TimeoutSeconds < 0 {
+			return fmt.Errorf("%s: timeout must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// ReloadAdapterPlugins 按配置重新加载启用的插件，原有插件进程在正在进行的调用结束后停止
+func (s *ProxyService) ReloadAdapterPlugins() {
+	var configs []adapters.PluginConfig
+	for _, p := range s.config.AdapterPlugins {
+		if !p.Enabled {
+			continue
+		}
+		configs = append(configs, adapters.PluginConfig{
+			Name:    p.Name,
+			Command: p.Command,
+			Args:    p.Args,
+			Timeout: time.Duration(p.TimeoutSeconds) * time.Second,
+		})
+	}
+	adapters.LoadPlugins(configs)
+	if len(configs) > 0 {
+		log.Infof("Loaded %d adapter plugins", len(configs))
+	}
+}
+
+// CloseAdapterPlugins 停止所有插件进程（退出时调用）
+func (s *ProxyService) CloseAdapterPlugins() {
+	adapters.LoadPlugins(nil)
+}
+
+// adapterPlugin 格式名称对应的已启用插件，没有时返回 nil
+func (s *ProxyService) adapterPlugin(format string) *config.AdapterPlugin {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" || !adapters.IsPlugin(format) {
+		return nil
+	}
+	for i, p := range s.config.AdapterPlugins {
+		if p.Enabled && strings.ToLower(strings.TrimSpace(p.Name)) == format {
+			return &s.config.AdapterPlugins[i]
+		}
+	}
+	return nil
+}
+
+// pluginAdapterFormat 插件适配器（openai-to-<name>）对应的插件名称，不是插件适配器时返回空
+func pluginAdapterFormat(adapterName string) string {
+	if name, ok := strings.CutPrefix(adapterName, "openai-to-"); ok && adapters.IsPlugin(name) {
+		return name
+	}
+	return ""
+}
+
+// buildPluginURL 插件路由的请求地址：路由 API 地址加插件配置的路径，未配置路径时按 OpenAI 兼容接口构建
+func (s *ProxyService) buildPluginURL(apiURL, adapterName, model string, stream bool) string {
+	plugin := s.adapterPlugin(pluginAdapterFormat(adapterName))
+	if plugin == nil {
+		return buildOpenAIChatURL(apiURL)
+	}
+	path := plugin.Path
+	if stream && plugin.StreamPath != "" {
+		path = plugin.StreamPath
+	}
+	if path == "" {
+		return buildOpenAIChatURL(apiURL)
+	}
+	path = strings.ReplaceAll(path, "{model}", url.PathEscape(model))
+	return strings.TrimSuffix(apiURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
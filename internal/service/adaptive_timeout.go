@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	adaptiveTimeoutSamples  = 200             // 参与计算的最近请求数
+	adaptiveTimeoutMinCount = 20              // 样本不足时不启用自适应超时
+	adaptiveTimeoutCacheTTL = 1 * time.Minute // 计算结果缓存时间
+)
+
+// cachedTimeout 路由自适应超时缓存
+type cachedTimeout struct {
+	timeout   time.Duration
+	expiresAt time.Time
+}
+
+// timeoutCache 按路由ID缓存自适应超时，避免每次请求都查询数据库
+type timeoutCache struct {
+	mu      sync.Mutex
+	entries map[int64]cachedTimeout
+}
+
+func newTimeoutCache() *timeoutCache {
+	return &timeoutCache{entries: make(map[int64]cachedTimeout)}
+}
+
+// percentile 计算耗时分位数（p 取值 0~1）
+func percentile(values []int64, p float64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]int64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
+
+// adaptiveTimeout 根据路由历史耗时计算非流式请求超时：p99 × 系数，并限制在上下限之间
+// 返回 0 表示不设置超时
+func (s *ProxyService) adaptiveTimeout(routeID int64) time.Duration {
+	if s.config == nil || !s.config.AdaptiveTimeoutEnabled || routeID <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	s.timeouts.mu.Lock()
+	if entry, ok := s.timeouts.entries[routeID]; ok && now.Before(entry.expiresAt) {
+		s.timeouts.mu.Unlock()
+		return entry.timeout
+	}
+	s.timeouts.mu.Unlock()
+
+	var timeout time.Duration
+	latencies, err := s.routeService.GetRecentLatencies(routeID, adaptiveTimeoutSamples)
+	if err != nil {
+		log.Warnf("Adaptive timeout: failed to load latency history for route %d: %v", routeID, err)
+	} else if len(latencies) >= adaptiveTimeoutMinCount {
+		factor := s.config.AdaptiveTimeoutFactor
+		if factor <= 1 {
+			factor = 3
+		}
+		p99 := percentile(latencies, 0.99)
+		timeout = time.Duration(float64(p99)*factor) * time.Millisecond
+
+		if minTimeout := time.Duration(s.config.AdaptiveTimeoutMinMs) * time.Millisecond; minTimeout > 0 && timeout < minTimeout {
+			timeout = minTimeout
+		}
+		if maxTimeout := time.Duration(s.config.AdaptiveTimeoutMaxMs) * time.Millisecond; maxTimeout > 0 && timeout > maxTimeout {
+			timeout = maxTimeout
+		}
+		log.Debugf("Adaptive timeout for route %d: p99=%dms, samples=%d, timeout=%v", routeID, p99, len(latencies), timeout)
+	}
+
+	s.timeouts.mu.Lock()
+	s.timeouts.entries[routeID] = cachedTimeout{timeout: timeout, expiresAt: now.Add(adaptiveTimeoutCacheTTL)}
+	s.timeouts.mu.Unlock()
+	return timeout
+}
+
+// withAdaptiveTimeout 为非流式请求附加自适应超时
+// 超时后返回 context deadline exceeded，shouldFallback 会据此切换到下一个路由
+func (s *ProxyService) withAdaptiveTimeout(req *http.Request, routeID int64) (*http.Request, context.CancelFunc) {
+	timeout := s.adaptiveTimeout(routeID)
+	if timeout <= 0 {
+		return req, func() {}
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	return req.WithContext(ctx), cancel
+}
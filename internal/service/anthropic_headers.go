@@ -0,0 +1,74 @@
+package service
+
+import (
+	"net/http"
+
+	"openai-router-go/internal/database"
+)
+
+// DefaultAnthropicVersion 未配置 anthropic_version 时使用的版本
+const DefaultAnthropicVersion = "2023-06-01"
+
+// headerValue 从请求头 map 中取值，兼容规范化和原始大小写的 key
+func headerValue(headers map[string]string, name string) string {
+	if v := headers[http.CanonicalHeaderKey(name)]; v != "" {
+		return v
+	}
+	return headers[name]
+}
+
+// anthropicVersion 优先使用客户端的 anthropic-version，否则使用配置的默认版本
+func (s *ProxyService) anthropicVersion(headers map[string]string) string {
+	if v := headerValue(headers, "anthropic-version"); v != "" {
+		return v
+	}
+	if s.config != nil && s.config.AnthropicVersion != "" {
+		return s.config.AnthropicVersion
+	}
+	return DefaultAnthropicVersion
+}
+
+// setAnthropicHeaders 设置 Claude 上游的 anthropic-version，并透传客户端的 anthropic-beta
+func (s *ProxyService) setAnthropicHeaders(req *http.Request, headers map[string]string) {
+	req.Header.Set("anthropic-version", s.anthropicVersion(headers))
+	if beta := headerValue(headers, "anthropic-beta"); beta != "" {
+		req.Header.Set("anthropic-beta", beta)
+	}
+}
+
+// clientAPIKey 客户端通过 x-api-key 提供的上游 Key，与本地 API Key 或虚拟 Key 相同时视为本地认证不透传
+func (s *ProxyService) clientAPIKey(headers map[string]string) string {
+	key := headerValue(headers, "x-api-key")
+	if key == "" || (s.config != nil && s.config.IsClientKey(key)) {
+		return ""
+	}
+	return key
+}
+
+// setAnthropicAuth Claude 上游的认证：优先使用路由 Key，路由未配置 Key 时透传客户端的 x-api-key 或 Authorization
+func (s *ProxyService) setAnthropicAuth(req *http.Request, route *database.ModelRoute, headers map[string]string) {
+	if route.APIKey != "" {
+		req.Header.Set("x-api-key", route.APIKey)
+		return
+	}
+	if isLocalURL(route.APIUrl) {
+		return
+	}
+	if key := s.clientAPIKey(headers); key != "" {
+		req.Header.Set("x-api-key", key)
+	} else if auth := headers["Authorization"]; auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+}
+
+// setClientUpstreamAuth Claude 客户端请求发往非 Claude 上游时的认证
+// 路由未配置 Key 且客户端只提供 x-api-key 时，转换为 Bearer token 透传
+func (s *ProxyService) setClientUpstreamAuth(req *http.Request, route *database.ModelRoute, headers map[string]string) {
+	if route.APIKey == "" && !isLocalURL(route.APIUrl) {
+		if key := s.clientAPIKey(headers); key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+			return
+		}
+	}
+	setUpstreamAuth(req, route.APIUrl, route.APIKey, headers)
+}
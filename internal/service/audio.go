@@ -0,0 +1,349 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxMultipartBodySize multipart 请求体上限（OpenAI 音频文件上限为 25MB，这里留出余量）
+const maxMultipartBodySize = 200 << 20
+
+// maxMultipartFieldLogSize 追踪记录中文本字段值的最大长度
+const maxMultipartFieldLogSize = 256
+
+// multipartUpload 暂存到临时文件的 multipart 请求体，可重复读取以支持重试和 Fallback
+type multipartUpload struct {
+	file        *os.File
+	size        int64
+	contentType string
+	boundary    string
+}
+
+// spoolMultipartBody 将请求体原样写入临时文件（不在内存中缓冲整个上传文件）
+func spoolMultipartBody(body io.Reader, contentType string) (*multipartUpload, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, fmt.Errorf("Content-Type must be multipart/form-data with a boundary")
+	}
+
+	file, err := os.CreateTemp("", "anyproxy-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %v", err)
+	}
+	size, err := io.Copy(file, io.LimitReader(body, maxMultipartBodySize+1))
+	if err == nil && size > maxMultipartBodySize {
+		err = fmt.Errorf("request body exceeds %d MB", maxMultipartBodySize>>20)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &multipartUpload{file: file, size: size, contentType: contentType, boundary: params["boundary"]}, nil
+}
+
+// Close 关闭并删除临时文件
+func (u *multipartUpload) Close() {
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// reader 返回从头读取请求体的 Reader
+func (u *multipartUpload) reader() io.Reader {
+	return io.NewSectionReader(u.file, 0, u.size)
+}
+
+// scan 读取各字段：返回 model 字段值和用于追踪记录的请求摘要（文件内容只记录文件名和大小）
+func (u *multipartUpload) scan() (string, string, error) {
+	mr := multipart.NewReader(u.reader(), u.boundary)
+	var model string
+	var fields []string
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("invalid multipart body: %v", err)
+		}
+		name := part.FormName()
+		if filename := part.FileName(); filename != "" {
+			n, _ := io.Copy(io.Discard, part)
+			fields = append(fields, fmt.Sprintf("%s=@%s (%s, %d bytes)", name, filename, part.Header.Get("Content-Type"), n))
+			continue
+		}
+		value, _ := io.ReadAll(io.LimitReader(part, maxMultipartFieldLogSize))
+		io.Copy(io.Discard, part)
+		if name == "model" {
+			model = strings.TrimSpace(string(value))
+		}
+		fields = append(fields, fmt.Sprintf("%s=%s", name, value))
+	}
+	return model, "[multipart] " + strings.Join(fields, ", "), nil
+}
+
+// rewriteModel 重定向时替换 model 字段，其余部分（包括文件内容）逐字节复制到新的临时文件
+func (u *multipartUpload) rewriteModel(model string) (*multipartUpload, error) {
+	file, err := os.CreateTemp("", "anyproxy-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %v", err)
+	}
+	fail := func(err error) (*multipartUpload, error) {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	mw := multipart.NewWriter(file)
+	if err := mw.SetBoundary(u.boundary); err != nil {
+		return fail(err)
+	}
+	mr := multipart.NewReader(u.reader(), u.boundary)
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("invalid multipart body: %v", err))
+		}
+		pw, err := mw.CreatePart(part.Header)
+		if err != nil {
+			return fail(err)
+		}
+		if part.FormName() == "model" && part.FileName() == "" {
+			io.Copy(io.Discard, part)
+			_, err = io.WriteString(pw, model)
+		} else {
+			_, err = io.Copy(pw, part)
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return fail(err)
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fail(err)
+	}
+	return &multipartUpload{file: file, size: size, contentType: u.contentType, boundary: u.boundary}, nil
+}
+
+// newRequest 构建上游请求，设置 GetBody 使 doWithRetry 可以重发请求体
+func (u *multipartUpload) newRequest(targetURL string) (*http.Request, error) {
+	req, err := http.NewRequest("POST", targetURL, io.NopCloser(u.reader()))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = u.size
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(u.reader()), nil
+	}
+	req.Header.Set("Content-Type", u.contentType)
+	return req, nil
+}
+
+// usageFromEventStream 从 SSE 响应（如 transcript.text.done 事件）中提取最后一次出现的 token 用量
+func usageFromEventStream(body []byte) (promptTokens, completionTokens, totalTokens int) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") || !strings.Contains(line, "\"usage\"") {
+			continue
+		}
+		p, c, t := usageFromResponse([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))))
+		if t > 0 {
+			promptTokens, completionTokens, totalTokens = p, c, t
+		}
+	}
+	return promptTokens, completionTokens, totalTokens
+}
+
+// ProxyMultipartRequest 代理 multipart/form-data 上传接口（audio/transcriptions、audio/translations）
+// 请求体暂存到临时文件后原样转发给上游，根据 model 表单字段选择路由
+// 返回的状态码和错误仅在尚未向客户端写入任何数据时有效
+func (s *ProxyService) ProxyMultipartRequest(body io.Reader, headers map[string]string, endpoint string, writer http.ResponseWriter) (int, error) {
+	requestID := requestIDFromHeaders(headers)
+
+	upload, err := spoolMultipartBody(body, headers["Content-Type"])
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	defer upload.Close()
+
+	model, summary, err := upload.scan()
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if model == "" {
+		return http.StatusBadRequest, fmt.Errorf("'model' field is required")
+	}
+
+	log.Infof("=== MULTIPART REQUEST START [%s] === endpoint: %s, model: %s, size: %d bytes", requestID, endpoint, model, upload.size)
+	s.logBody(requestID, "Multipart request: %s", summary)
+
+	remoteIP := headers["X-Real-IP"]
+	if remoteIP == "" {
+		remoteIP = "unknown"
+	}
+
+	routes, targetModel, err := s.selectRoutes(model, nil, headers)
+	if err != nil {
+		return routeSelectionStatus(err), err
+	}
+	if targetModel != model {
+		rewritten, err := upload.rewriteModel(targetModel)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		defer rewritten.Close()
+		upload = rewritten
+		model = targetModel
+	}
+
+	var lastErr error
+	lastStatusCode := http.StatusBadGateway
+	for routeIndex, route := range routes {
+		targetURL := buildOpenAIEndpointURL(route.APIUrl, endpoint)
+		log.Infof("=== Trying route %d/%d: %s -> %s ===", routeIndex+1, len(routes), route.Name, targetURL)
+
+		proxyReq, err := upload.newRequest(targetURL)
+		if err != nil {
+			lastErr = err
+			lastStatusCode = http.StatusInternalServerError
+			continue
+		}
+		if accept := headers["Accept"]; accept != "" {
+			proxyReq.Header.Set("Accept", accept)
+		}
+		setUpstreamAuth(proxyReq, route.APIUrl, route.APIKey, headers)
+		applyRouteExtras(proxyReq, route.ExtraHeaders, route.ExtraQuery)
+		proxyReq = withRequestID(proxyReq, requestID)
+
+		startTime := time.Now()
+		resp, err := s.doWithRetry(proxyReq, route.Name)
+		if err != nil {
+			s.routeService.LogRequestFull(RequestLogParams{
+				RequestID:     requestID,
+				Model:         model,
+				ProviderModel: route.Model,
+				ProviderName:  route.Name,
+				RouteID:       route.ID,
+				Success:       false,
+				ErrorMessage:  err.Error(),
+				Style:         "openai",
+				ProxyTimeMs:   time.Since(startTime).Milliseconds(),
+			})
+			lastErr = fmt.Errorf("backend service unavailable: %v", err)
+			lastStatusCode = http.StatusServiceUnavailable
+			if s.fallbackNext(routes, routeIndex, 0, err) {
+				log.Warnf("Route %s failed with network error: %v, trying fallback...", route.Name, err)
+				continue
+			}
+			return lastStatusCode, lastErr
+		}
+
+		// 可切换路由的错误：读取错误信息后尝试下一个路由
+		if s.fallbackNext(routes, routeIndex, resp.StatusCode, nil) {
+			errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+			errMsg := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(errBody))
+			s.routeService.LogRequestFull(RequestLogParams{
+				RequestID:     requestID,
+				Model:         model,
+				ProviderModel: route.Model,
+				ProviderName:  route.Name,
+				RouteID:       route.ID,
+				Success:       false,
+				ErrorMessage:  errMsg,
+				ErrorType:     ErrorTypeForStatus(resp.StatusCode),
+				Style:         "openai",
+				ProxyTimeMs:   time.Since(startTime).Milliseconds(),
+			})
+			log.Warnf("Route %s failed with status %d, trying fallback...", route.Name, resp.StatusCode)
+			lastErr = fmt.Errorf("%s", errMsg)
+			lastStatusCode = resp.StatusCode
+			continue
+		}
+
+		for _, key := range passthroughResponseHeaders {
+			if v := resp.Header.Get(key); v != "" {
+				writer.Header().Set(key, v)
+			}
+		}
+		respContentType := resp.Header.Get("Content-Type")
+		if respContentType == "" {
+			writer.Header().Set("Content-Type", "application/json")
+		}
+
+		// 响应（json、text、srt、vtt 或 stream=true 时的 SSE）边读边写，同时保留一份用于统计用量
+		var responseBody bytes.Buffer
+		writer.Header().Del("Content-Length")
+		writer.WriteHeader(resp.StatusCode)
+		flusher, _ := writer.(http.Flusher)
+		_, copyErr := copyWithFlush(writer, io.TeeReader(resp.Body, &responseBody), flusher)
+		resp.Body.Close()
+
+		success := resp.StatusCode == http.StatusOK && copyErr == nil
+		errMsg, errType := "", ""
+		if copyErr != nil {
+			errMsg = copyErr.Error()
+			log.Errorf("Multipart response copy failed: %v", copyErr)
+		} else if resp.StatusCode != http.StatusOK {
+			errMsg = responseBody.String()
+			errType = ErrorTypeForStatus(resp.StatusCode)
+		}
+
+		var promptTokens, completionTokens, totalTokens int
+		if strings.HasPrefix(respContentType, "text/event-stream") {
+			promptTokens, completionTokens, totalTokens = usageFromEventStream(responseBody.Bytes())
+		} else {
+			promptTokens, completionTokens, totalTokens = usageFromResponse(responseBody.Bytes())
+		}
+		log.Infof("Multipart %s response from %s: status %d, tokens %d in %v", endpoint, route.Name, resp.StatusCode, totalTokens, time.Since(startTime))
+		s.logBody(requestID, "Multipart response: %s", responseBody.Bytes())
+
+		s.routeService.LogRequestFull(RequestLogParams{
+			RequestID:      requestID,
+			Model:          model,
+			ProviderModel:  route.Model,
+			ProviderName:   route.Name,
+			RouteID:        route.ID,
+			RequestTokens:  promptTokens,
+			ResponseTokens: completionTokens,
+			TotalTokens:    totalTokens,
+			Success:        success,
+			ErrorMessage:   errMsg,
+			ErrorType:      errType,
+			Style:          "openai",
+			ProxyTimeMs:    time.Since(startTime).Milliseconds(),
+		})
+		s.SaveTraceIfEnabled(
+			requestID, remoteIP, model, route.Model, route.Name,
+			summary, responseBody.String(),
+			promptTokens, completionTokens, totalTokens,
+			success, errMsg, "openai", false,
+			time.Since(startTime).Milliseconds(),
+		)
+		return resp.StatusCode, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("all routes failed")
+	}
+	return lastStatusCode, lastErr
+}
@@ -0,0 +1,729 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 批处理任务状态（与 OpenAI Batch API 一致）
+const (
+	BatchValidating = "validating"
+	BatchFailed     = "failed"
+	BatchInProgress = "in_progress"
+	BatchFinalizing = "finalizing"
+	BatchCompleted  = "completed"
+	BatchExpired    = "expired"
+	BatchCancelling = "cancelling"
+	BatchCancelled  = "cancelled"
+)
+
+const (
+	BatchFileMaxBytes     = 100 * 1024 * 1024 // 上传文件大小上限
+	batchMaxRequests      = 50000             // 单个批处理任务的请求数上限
+	batchCompletionWindow = "24h"
+	batchMaxConcurrency   = 32
+)
+
+// batchEndpoints 批处理支持的接口
+var batchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+}
+
+// ErrBatchNotFound 文件或批处理任务不存在
+var ErrBatchNotFound = errors.New("not found")
+
+// BatchFile 上传的文件（输入 JSONL）或批处理生成的结果文件
+type BatchFile struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Status    string `json:"status"`
+}
+
+// BatchRequestCounts 批处理请求计数
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// BatchError 输入文件校验错误
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// BatchErrors 校验错误列表
+type BatchErrors struct {
+	Object string       `json:"object"`
+	Data   []BatchError `json:"data"`
+}
+
+// Batch 批处理任务，未发生的时间点为 0（序列化时省略）
+type Batch struct {
+	ID               string             `json:"id"`
+	Object           string             `json:"object"`
+	Endpoint         string             `json:"endpoint"`
+	Errors           *BatchErrors       `json:"errors"`
+	InputFileID      string             `json:"input_file_id"`
+	CompletionWindow string             `json:"completion_window"`
+	Status           string             `json:"status"`
+	OutputFileID     string             `json:"output_file_id,omitempty"`
+	ErrorFileID      string             `json:"error_file_id,omitempty"`
+	CreatedAt        int64              `json:"created_at"`
+	InProgressAt     int64              `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64              `json:"expires_at,omitempty"`
+	FinalizingAt     int64              `json:"finalizing_at,omitempty"`
+	CompletedAt      int64              `json:"completed_at,omitempty"`
+	FailedAt         int64              `json:"failed_at,omitempty"`
+	ExpiredAt        int64              `json:"expired_at,omitempty"`
+	CancellingAt     int64              `json:"cancelling_at,omitempty"`
+	CancelledAt      int64              `json:"cancelled_at,omitempty"`
+	RequestCounts    BatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string  `json:"metadata"`
+}
+
+// BatchCreateRequest 创建批处理任务的请求
+type BatchCreateRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+// batchLine 输入文件中的一行请求
+type batchLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// batchRunner 记录正在执行的批处理任务，用于取消
+type batchRunner struct {
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+func newBatchRunner() *batchRunner {
+	return &batchRunner{running: make(map[string]context.CancelFunc)}
+}
+
+// newBatchObjectID 生成带前缀的随机ID（file-xxx、batch_xxx）
+func newBatchObjectID(prefix string) string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%s%d", prefix, time.Now().UnixNano())
+	}
+	return prefix + hex.EncodeToString(b[:])
+}
+
+// CreateBatchFile 保存上传的文件
+func (s *RouteService) CreateBatchFile(filename, purpose string, content []byte) (BatchFile, error) {
+	f := BatchFile{
+		ID:        newBatchObjectID("file-"),
+		Object:    "file",
+		Bytes:     int64(len(content)),
+		CreatedAt: time.Now().Unix(),
+		Filename:  filename,
+		Purpose:   purpose,
+		Status:    "processed",
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO batch_files (id, purpose, filename, bytes, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		f.ID, f.Purpose, f.Filename, f.Bytes, string(content), f.CreatedAt)
+	if err != nil {
+		log.Errorf("Failed to save batch file: %v", err)
+		return f, err
+	}
+	return f, nil
+}
+
+// GetBatchFiles 获取文件列表（按创建时间倒序），purpose 为空时返回全部
+func (s *RouteService) GetBatchFiles(purpose string) ([]BatchFile, error) {
+	query := `SELECT id, purpose, filename, bytes, created_at FROM batch_files`
+	var args []interface{}
+	if purpose != "" {
+		query += ` WHERE purpose = ?`
+		args = append(args, purpose)
+	}
+	rows, err := s.db.Query(query+` ORDER BY created_at DESC, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make([]BatchFile, 0)
+	for rows.Next() {
+		f := BatchFile{Object: "file", Status: "processed"}
+		if err := rows.Scan(&f.ID, &f.Purpose, &f.Filename, &f.Bytes, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// GetBatchFile 获取文件信息
+func (s *RouteService) GetBatchFile(id string) (BatchFile, error) {
+	f := BatchFile{Object: "file", Status: "processed"}
+	err := s.db.QueryRow(`SELECT id, purpose, filename, bytes, created_at FROM batch_files WHERE id = ?`, id).
+		Scan(&f.ID, &f.Purpose, &f.Filename, &f.Bytes, &f.CreatedAt)
+	if err == sql.ErrNoRows {
+		return f, fmt.Errorf("file %s %w", id, ErrBatchNotFound)
+	}
+	return f, err
+}
+
+// GetBatchFileContent 获取文件内容
+func (s *RouteService) GetBatchFileContent(id string) ([]byte, error) {
+	var content string
+	err := s.db.QueryRow(`SELECT content FROM batch_files WHERE id = ?`, id).Scan(&content)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("file %s %w", id, ErrBatchNotFound)
+	}
+	return []byte(content), err
+}
+
+// DeleteBatchFile 删除文件
+func (s *RouteService) DeleteBatchFile(id string) error {
+	result, err := s.db.Exec(`DELETE FROM batch_files WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("file %s %w", id, ErrBatchNotFound)
+	}
+	return nil
+}
+
+const batchColumns = `id, endpoint, input_file_id, completion_window, status, output_file_id, error_file_id, errors, metadata,
+	total, completed, failed, created_at, in_progress_at, finalizing_at, completed_at, failed_at, expired_at,
+	cancelling_at, cancelled_at, expires_at`
+
+func scanBatch(row rowScanner) (Batch, error) {
+	b := Batch{Object: "batch"}
+	var outputFileID, errorFileID, errorsJSON, metadataJSON sql.NullString
+	err := row.Scan(&b.ID, &b.Endpoint, &b.InputFileID, &b.CompletionWindow, &b.Status, &outputFileID, &errorFileID,
+		&errorsJSON, &metadataJSON, &b.RequestCounts.Total, &b.RequestCounts.Completed, &b.RequestCounts.Failed,
+		&b.CreatedAt, &b.InProgressAt, &b.FinalizingAt, &b.CompletedAt, &b.FailedAt, &b.ExpiredAt,
+		&b.CancellingAt, &b.CancelledAt, &b.ExpiresAt)
+	if err != nil {
+		return b, err
+	}
+	b.OutputFileID = outputFileID.String
+	b.ErrorFileID = errorFileID.String
+	if errorsJSON.String != "" {
+		json.Unmarshal([]byte(errorsJSON.String), &b.Errors)
+	}
+	if metadataJSON.String != "" {
+		json.Unmarshal([]byte(metadataJSON.String), &b.Metadata)
+	}
+	return b, nil
+}
+
+// GetBatches 获取批处理任务列表（按创建时间倒序），after 为上一页最后一个任务的 ID
+func (s *RouteService) GetBatches(limit int, after string) ([]Batch, bool, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	rows, err := s.db.Query(`SELECT ` + batchColumns + ` FROM batches ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	batches := make([]Batch, 0, limit)
+	skipping := after != ""
+	hasMore := false
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, false, err
+		}
+		if skipping {
+			skipping = b.ID != after
+			continue
+		}
+		if len(batches) == limit {
+			hasMore = true
+			break
+		}
+		batches = append(batches, b)
+	}
+	return batches, hasMore, rows.Err()
+}
+
+// GetBatch 获取批处理任务
+func (s *RouteService) GetBatch(id string) (Batch, error) {
+	b, err := scanBatch(s.db.QueryRow(`SELECT `+batchColumns+` FROM batches WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return b, fmt.Errorf("batch %s %w", id, ErrBatchNotFound)
+	}
+	return b, err
+}
+
+func (s *RouteService) insertBatch(b Batch) error {
+	var errorsJSON, metadataJSON []byte
+	if b.Errors != nil {
+		errorsJSON, _ = json.Marshal(b.Errors)
+	}
+	if b.Metadata != nil {
+		metadataJSON, _ = json.Marshal(b.Metadata)
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO batches (id, endpoint, input_file_id, completion_window, status, errors, metadata,
+			total, created_at, failed_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.ID, b.Endpoint, b.InputFileID, b.CompletionWindow, b.Status, string(errorsJSON), string(metadataJSON),
+		b.RequestCounts.Total, b.CreatedAt, b.FailedAt, b.ExpiresAt)
+	return err
+}
+
+// setBatchStatus 更新任务状态，并记录对应的时间点（列名为 <status>_at）
+func (s *RouteService) setBatchStatus(id, status string) error {
+	if status == BatchValidating {
+		_, err := s.db.Exec(`UPDATE batches SET status = ? WHERE id = ?`, status, id)
+		return err
+	}
+	_, err := s.db.Exec(`UPDATE batches SET status = ?, `+status+`_at = ? WHERE id = ?`, status, time.Now().Unix(), id)
+	return err
+}
+
+// parseBatchInput 校验输入文件，返回请求列表和校验错误
+func parseBatchInput(content []byte, endpoint string) ([]batchLine, []BatchError) {
+	var lines []batchLine
+	var errs []BatchError
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), BatchFileMaxBytes)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var line batchLine
+		if err := json.Unmarshal(text, &line); err != nil {
+			errs = append(errs, BatchError{Code: "invalid_json_line", Message: err.Error(), Line: lineNo})
+			continue
+		}
+		var body map[string]interface{}
+		switch {
+		case line.CustomID == "":
+			errs = append(errs, BatchError{Code: "missing_required_parameter", Message: "custom_id is required", Line: lineNo})
+		case seen[line.CustomID]:
+			errs = append(errs, BatchError{Code: "duplicate_custom_id", Message: fmt.Sprintf("duplicate custom_id %q", line.CustomID), Line: lineNo})
+		case !strings.EqualFold(line.Method, http.MethodPost):
+			errs = append(errs, BatchError{Code: "invalid_method", Message: "only POST requests are supported", Line: lineNo})
+		case line.URL != endpoint:
+			errs = append(errs, BatchError{Code: "mismatched_endpoint", Message: fmt.Sprintf("url %q does not match batch endpoint %q", line.URL, endpoint), Line: lineNo})
+		case json.Unmarshal(line.Body, &body) != nil || body == nil:
+			errs = append(errs, BatchError{Code: "invalid_request", Message: "body must be a JSON object", Line: lineNo})
+		case body["model"] == nil || body["model"] == "":
+			errs = append(errs, BatchError{Code: "missing_required_parameter", Message: "body.model is required", Line: lineNo})
+		default:
+			seen[line.CustomID] = true
+			lines = append(lines, line)
+			continue
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, BatchError{Code: "invalid_file", Message: err.Error()})
+	}
+	if len(errs) == 0 && len(lines) == 0 {
+		errs = append(errs, BatchError{Code: "empty_file", Message: "the input file contains no requests"})
+	}
+	if len(lines) > batchMaxRequests {
+		errs = append(errs, BatchError{Code: "too_many_requests", Message: fmt.Sprintf("a batch may contain at most %d requests", batchMaxRequests)})
+	}
+	return lines, errs
+}
+
+// CreateBatch 创建批处理任务：校验输入文件后在后台执行，校验失败时任务状态为 failed
+func (s *ProxyService) CreateBatch(req BatchCreateRequest) (Batch, error) {
+	if !batchEndpoints[req.Endpoint] {
+		return Batch{}, fmt.Errorf("unsupported endpoint %q, supported: /v1/chat/completions, /v1/completions, /v1/embeddings", req.Endpoint)
+	}
+	if req.CompletionWindow == "" {
+		req.CompletionWindow = batchCompletionWindow
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		return Batch{}, fmt.Errorf("completion_window must be %q", batchCompletionWindow)
+	}
+	file, err := s.routeService.GetBatchFile(req.InputFileID)
+	if err != nil {
+		return Batch{}, fmt.Errorf("input file %s not found", req.InputFileID)
+	}
+	if file.Purpose != "batch" {
+		return Batch{}, fmt.Errorf("input file %s must be uploaded with purpose \"batch\"", req.InputFileID)
+	}
+	content, err := s.routeService.GetBatchFileContent(req.InputFileID)
+	if err != nil {
+		return Batch{}, err
+	}
+
+	now := time.Now()
+	b := Batch{
+		ID:               newBatchObjectID("batch_"),
+		Object:           "batch",
+		Endpoint:         req.Endpoint,
+		InputFileID:      req.InputFileID,
+		CompletionWindow: req.CompletionWindow,
+		Status:           BatchValidating,
+		CreatedAt:        now.Unix(),
+		ExpiresAt:        now.Add(24 * time.Hour).Unix(),
+		Metadata:         req.Metadata,
+	}
+	lines, errs := parseBatchInput(content, req.Endpoint)
+	if len(errs) > 0 {
+		b.Status = BatchFailed
+		b.FailedAt = now.Unix()
+		b.Errors = &BatchErrors{Object: "list", Data: errs}
+	}
+	b.RequestCounts.Total = len(lines)
+	if err := s.routeService.insertBatch(b); err != nil {
+		log.Errorf("Failed to save batch: %v", err)
+		return b, err
+	}
+	if b.Status == BatchFailed {
+		log.Warnf("[Batch] %s failed validation: %d error(s)", b.ID, len(errs))
+		return b, nil
+	}
+
+	log.Infof("[Batch] %s created: %d request(s) to %s", b.ID, len(lines), b.Endpoint)
+	s.startBatch(b, lines)
+	return s.routeService.GetBatch(b.ID)
+}
+
+// CancelBatch 取消批处理任务，已完成的请求结果仍会写入输出文件
+func (s *ProxyService) CancelBatch(id string) (Batch, error) {
+	b, err := s.routeService.GetBatch(id)
+	if err != nil {
+		return b, err
+	}
+	switch b.Status {
+	case BatchValidating, BatchInProgress:
+	default:
+		return b, fmt.Errorf("cannot cancel a batch with status %q", b.Status)
+	}
+
+	s.batches.mu.Lock()
+	cancel, running := s.batches.running[id]
+	s.batches.mu.Unlock()
+	if running {
+		s.routeService.setBatchStatus(id, BatchCancelling)
+		cancel()
+	} else {
+		s.routeService.setBatchStatus(id, BatchCancelled)
+	}
+	log.Infof("[Batch] %s cancel requested", id)
+	return s.routeService.GetBatch(id)
+}
+
+// ResumeBatches 继续执行上次退出时未完成的批处理任务（启动时调用）
+func (s *ProxyService) ResumeBatches() {
+	rows, err := s.routeService.db.Query(`SELECT `+batchColumns+` FROM batches WHERE status IN (?, ?, ?, ?)`,
+		BatchValidating, BatchInProgress, BatchFinalizing, BatchCancelling)
+	if err != nil {
+		log.Warnf("[Batch] Failed to load unfinished batches: %v", err)
+		return
+	}
+	var pending []Batch
+	for rows.Next() {
+		if b, err := scanBatch(rows); err == nil {
+			pending = append(pending, b)
+		}
+	}
+	rows.Close()
+
+	for _, b := range pending {
+		content, err := s.routeService.GetBatchFileContent(b.InputFileID)
+		if err != nil {
+			log.Warnf("[Batch] %s input file %s is missing, marking as failed", b.ID, b.InputFileID)
+			s.routeService.setBatchStatus(b.ID, BatchFailed)
+			continue
+		}
+		lines, _ := parseBatchInput(content, b.Endpoint)
+		log.Infof("[Batch] Resuming %s (%s)", b.ID, b.Status)
+		s.startBatch(b, lines)
+	}
+}
+
+// startBatch 在后台执行任务，超过 completion_window 时停止并标记为 expired
+func (s *ProxyService) startBatch(b Batch, lines []batchLine) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Unix(b.ExpiresAt, 0))
+	if b.Status == BatchCancelling {
+		cancel()
+	}
+	s.batches.mu.Lock()
+	s.batches.running[b.ID] = cancel
+	s.batches.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.batches.mu.Lock()
+			delete(s.batches.running, b.ID)
+			s.batches.mu.Unlock()
+			cancel()
+		}()
+		s.runBatch(ctx, b, lines)
+	}()
+}
+
+// runBatch 按配置的并发数执行尚未完成的请求，结果逐条写入 batch_results，全部结束后生成输出文件
+func (s *ProxyService) runBatch(ctx context.Context, b Batch, lines []batchLine) {
+	if b.Status == BatchValidating {
+		s.routeService.setBatchStatus(b.ID, BatchInProgress)
+	}
+
+	done := make(map[int]bool)
+	if rows, err := s.routeService.db.Query(`SELECT line_index FROM batch_results WHERE batch_id = ?`, b.ID); err == nil {
+		for rows.Next() {
+			var index int
+			if rows.Scan(&index) == nil {
+				done[index] = true
+			}
+		}
+		rows.Close()
+	}
+	completed := int64(b.RequestCounts.Completed)
+	failed := int64(b.RequestCounts.Failed)
+
+	concurrency := s.config.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	} else if concurrency > batchMaxConcurrency {
+		concurrency = batchMaxConcurrency
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				line := lines[index]
+				requestID, status, body, errMsg := s.executeBatchLine(b, line)
+				_, err := s.routeService.db.Exec(`
+					INSERT INTO batch_results (batch_id, line_index, custom_id, request_id, status_code, response, error_message)
+					VALUES (?, ?, ?, ?, ?, ?, ?)`,
+					b.ID, index, line.CustomID, requestID, status, string(body), errMsg)
+				if err != nil {
+					log.Errorf("[Batch] %s failed to save result for %s: %v", b.ID, line.CustomID, err)
+				}
+				if status == http.StatusOK {
+					atomic.AddInt64(&completed, 1)
+				} else {
+					atomic.AddInt64(&failed, 1)
+				}
+				s.routeService.db.Exec(`UPDATE batches SET completed = ?, failed = ? WHERE id = ?`,
+					atomic.LoadInt64(&completed), atomic.LoadInt64(&failed), b.ID)
+			}
+		}()
+	}
+
+dispatch:
+	for index := range lines {
+		if done[index] {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- index:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	final := BatchCompleted
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		final = BatchExpired
+	case ctx.Err() != nil:
+		final = BatchCancelled
+	}
+	if final == BatchCompleted {
+		s.routeService.setBatchStatus(b.ID, BatchFinalizing)
+	}
+	if err := s.routeService.finalizeBatch(b.ID, final); err != nil {
+		log.Errorf("[Batch] %s failed to write output: %v", b.ID, err)
+		s.routeService.setBatchStatus(b.ID, BatchFailed)
+		return
+	}
+	log.Infof("[Batch] %s %s: %d completed, %d failed", b.ID, final, completed, failed)
+}
+
+// executeBatchLine 通过正常的代理流程执行一行请求（路由选择、Fallback、格式转换、请求日志），强制非流式
+func (s *ProxyService) executeBatchLine(b Batch, line batchLine) (requestID string, status int, body []byte, errMsg string) {
+	requestID = NewRequestID()
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		RequestIDHeader: requestID,
+		"User-Agent":    "batch/" + b.ID,
+		"X-Real-IP":     "batch",
+	}
+
+	var reqData map[string]interface{}
+	json.Unmarshal(line.Body, &reqData)
+	delete(reqData, "stream_options")
+	if _, ok := reqData["stream"]; ok {
+		reqData["stream"] = false
+	}
+	requestBody, _ := json.Marshal(reqData)
+
+	// 与 API 请求一样经过内容审查
+	result := s.ModerateRequest(requestID, requestBody)
+	defer s.FinishModeration(requestID)
+	if result.Blocked != nil {
+		errMsg = s.RecordModerationBlock(requestID, "batch", "openai", result)
+		return requestID, http.StatusBadRequest, batchErrorBody(errMsg, "moderation_blocked"), errMsg
+	}
+	requestBody = result.Body
+
+	var err error
+	if b.Endpoint == "/v1/embeddings" {
+		w := &batchResponseWriter{header: make(http.Header)}
+		status, err = s.ProxyPassthroughRequest(requestBody, headers, "embeddings", w)
+		if err == nil {
+			status, body = w.status, w.body.Bytes()
+		}
+	} else {
+		body, status, err = s.ProxyRequest(requestBody, headers)
+	}
+	if err != nil {
+		return requestID, status, batchErrorBody(err.Error(), "proxy_error"), err.Error()
+	}
+	if status != http.StatusOK {
+		errMsg = fmt.Sprintf("HTTP %d", status)
+	}
+	return requestID, status, body, errMsg
+}
+
+func batchErrorBody(message, errType string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{"message": message, "type": errType},
+	})
+	return data
+}
+
+// batchResponseWriter 在内存中接收透传接口的响应
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *batchResponseWriter) Header() http.Header { return w.header }
+
+func (w *batchResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *batchResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+// finalizeBatch 将结果写入输出文件（成功的请求）和错误文件（失败的请求），清理逐条结果并设置最终状态
+func (s *RouteService) finalizeBatch(id, status string) error {
+	rows, err := s.db.Query(`
+		SELECT line_index, custom_id, request_id, status_code, response, error_message
+		FROM batch_results WHERE batch_id = ? ORDER BY line_index`, id)
+	if err != nil {
+		return err
+	}
+	var output, errorOutput bytes.Buffer
+	for rows.Next() {
+		var index, statusCode int
+		var customID string
+		var requestID, response, errMsg sql.NullString
+		if err := rows.Scan(&index, &customID, &requestID, &statusCode, &response, &errMsg); err != nil {
+			rows.Close()
+			return err
+		}
+		var body interface{} = json.RawMessage(response.String)
+		if !json.Valid([]byte(response.String)) {
+			body = response.String
+		}
+		record := map[string]interface{}{
+			"id":        fmt.Sprintf("batch_req_%s_%d", strings.TrimPrefix(id, "batch_"), index),
+			"custom_id": customID,
+			"response": map[string]interface{}{
+				"status_code": statusCode,
+				"request_id":  requestID.String,
+				"body":        body,
+			},
+			"error": nil,
+		}
+		target := &output
+		if statusCode != http.StatusOK {
+			target = &errorOutput
+			record["error"] = map[string]interface{}{"code": "request_failed", "message": errMsg.String}
+			if statusCode == 0 {
+				record["response"] = nil
+			}
+		}
+		line, _ := json.Marshal(record)
+		target.Write(line)
+		target.WriteByte('\n')
+	}
+	rows.Close()
+
+	var outputFileID, errorFileID string
+	if output.Len() > 0 {
+		f, err := s.CreateBatchFile(id+"_output.jsonl", "batch_output", output.Bytes())
+		if err != nil {
+			return err
+		}
+		outputFileID = f.ID
+	}
+	if errorOutput.Len() > 0 {
+		f, err := s.CreateBatchFile(id+"_error.jsonl", "batch_output", errorOutput.Bytes())
+		if err != nil {
+			return err
+		}
+		errorFileID = f.ID
+	}
+
+	if _, err := s.db.Exec(`UPDATE batches SET output_file_id = ?, error_file_id = ? WHERE id = ?`,
+		outputFileID, errorFileID, id); err != nil {
+		return err
+	}
+	if err := s.setBatchStatus(id, status); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`DELETE FROM batch_results WHERE batch_id = ?`, id)
+	return err
+}
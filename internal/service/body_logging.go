@@ -0,0 +1,82 @@
+package service
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 请求/响应体日志模式（与 Traces 功能相互独立）
+const (
+	BodyLogOff     = "off"     // 不记录请求头和请求/响应体
+	BodyLogHeaders = "headers" // 只记录请求头（敏感头已脱敏）
+	BodyLogSampled = "sampled" // 记录请求头，按比例抽样记录请求/响应体
+	BodyLogFull    = "full"    // 记录所有请求/响应体
+)
+
+// bodyLogMode 当前的请求体日志模式，未配置或无法识别时为 headers
+func (s *ProxyService) bodyLogMode() string {
+	if s.config == nil {
+		return BodyLogHeaders
+	}
+	switch mode := strings.ToLower(strings.TrimSpace(s.config.BodyLogMode)); mode {
+	case BodyLogOff, BodyLogSampled, BodyLogFull:
+		return mode
+	}
+	return BodyLogHeaders
+}
+
+// shouldLogBody 判断是否记录该请求的请求/响应体
+// 抽样按请求 ID 哈希决定，同一请求的所有日志要么全部记录要么全部跳过
+func (s *ProxyService) shouldLogBody(requestID string) bool {
+	switch s.bodyLogMode() {
+	case BodyLogFull:
+		return true
+	case BodyLogSampled:
+		percent := s.config.BodyLogSamplePercent
+		if percent <= 0 {
+			return false
+		}
+		if percent >= 100 {
+			return true
+		}
+		if requestID == "" {
+			return rand.Intn(100) < percent
+		}
+		h := fnv.New32a()
+		h.Write([]byte(requestID))
+		return int(h.Sum32()%100) < percent
+	}
+	return false
+}
+
+// logBody 按配置记录请求/响应体，参数可直接传 []byte（%s 格式化），跳过时不做字符串转换
+func (s *ProxyService) logBody(requestID, format string, args ...interface{}) {
+	if s.shouldLogBody(requestID) {
+		RequestLogger(requestID).Infof(format, args...)
+	}
+}
+
+// logRequestHeaders 按配置在 debug 级别记录请求头（合并为一行），Authorization 和包含 key 的头始终脱敏
+func (s *ProxyService) logRequestHeaders(title string, headers map[string]string) {
+	if s.bodyLogMode() == BodyLogOff || !log.IsLevelEnabled(log.DebugLevel) {
+		return
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		if strings.Contains(strings.ToLower(k), "authorization") || strings.Contains(strings.ToLower(k), "key") {
+			parts = append(parts, k+": ***REDACTED***")
+		} else {
+			parts = append(parts, k+": "+headers[k])
+		}
+	}
+	requestLogger(headers).Debugf("%s: %s", title, strings.Join(parts, "; "))
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Claude 请求参数校验：Anthropic 要求 max_tokens，OpenAI/Gemini 请求经常不指定，转换后直接发送会被上游拒绝。
+// 发往 Claude 的请求在这里按模型填充默认 max_tokens（claude_max_tokens 配置，未配置时 4096），
+// 超过模型输出上限（模型元数据的最大输出，没有时使用内置的 Claude 模型上限）时改为上限，
+// 并修正 thinking 预算、采样参数、stop_sequences 等上游会拒绝的取值
+
+// claudeFallbackMaxTokens 没有配置默认值时填充的 max_tokens
+const claudeFallbackMaxTokens = 4096
+
+// claudeMinThinkingBudget Claude 要求的最小 thinking.budget_tokens
+const claudeMinThinkingBudget = 1024
+
+// claudeOutputLimits 内置的 Claude 模型最大输出 token，使用第一条匹配的规则；未列出的模型不限制
+var claudeOutputLimits = []struct {
+	pattern string
+	limit   int
+}{
+	{"claude-opus-4-5*", 64000},
+	{"claude-opus-4, claude-opus-4-0*, claude-opus-4-1*, claude-opus-4-2025*", 32000},
+	{"claude-sonnet-4, claude-sonnet-4-0*, claude-sonnet-4-5*, claude-sonnet-4-2025*", 64000},
+	{"claude-haiku-4-5*", 64000},
+	{"claude-3-7-sonnet*", 64000},
+	{"claude-3-5-sonnet*, claude-3-5-haiku*", 8192},
+	{"claude-3-opus*, claude-3-sonnet*, claude-3-haiku*", 4096},
+}
+
+// ValidateClaudeMaxTokens 校验默认 max_tokens 配置（保存前调用）
+func ValidateClaudeMaxTokens(defaults map[string]int) error {
+	for pattern, maxTokens := range defaults {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("model pattern is required")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid model pattern %q", pattern)
+		}
+		if maxTokens <= 0 {
+			return fmt.Errorf("%s: max_tokens must be positive", pattern)
+		}
+	}
+	return nil
+}
+
+// claudeOutputLimit 模型的最大输出 token：模型元数据优先，其次是内置的上限，0 表示未知
+func (s *ProxyService) claudeOutputLimit(models ...string) int {
+	if items, err := s.routeService.GetModelMetadata(); err == nil {
+		for _, model := range models {
+			for _, m := range items {
+				if model != "" && m.MaxOutputTokens > 0 && strings.EqualFold(m.Model, model) {
+					return m.MaxOutputTokens
+				}
+			}
+		}
+	}
+	for _, model := range models {
+		for _, l := range claudeOutputLimits {
+			if matchModelPattern(l.pattern, model) {
+				return l.limit
+			}
+		}
+	}
+	return 0
+}
+
+// claudeDefaultMaxTokens 模型的默认 max_tokens：claude_max_tokens 配置优先，不超过模型的输出上限
+func (s *ProxyService) claudeDefaultMaxTokens(limit int, models ...string) int {
+	maxTokens := 0
+	for _, model := range models {
+		if maxTokens = s.config.ClaudeMaxTokensFor(model); maxTokens > 0 {
+			break
+		}
+	}
+	if maxTokens <= 0 {
+		maxTokens = claudeFallbackMaxTokens
+	}
+	if limit > 0 && maxTokens > limit {
+		maxTokens = limit
+	}
+	return maxTokens
+}
+
+// applyClaudeLimits 填充并校验 Claude 格式请求体的必需参数；upstreamModel 为路由配置的上游模型名，
+// 为空时使用请求体中的模型名。没有修改或解析失败时原样返回
+func (s *ProxyService) applyClaudeLimits(upstreamModel string, body []byte) []byte {
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		return body
+	}
+	requestModel, _ := reqData["model"].(string)
+	models := []string{strings.TrimSpace(upstreamModel), requestModel}
+	if models[0] == "" {
+		models = models[1:]
+	}
+
+	changed := false
+	limit := s.claudeOutputLimit(models...)
+	maxTokens := numberValue(reqData["max_tokens"])
+	switch {
+	case maxTokens <= 0:
+		maxTokens = s.claudeDefaultMaxTokens(limit, models...)
+		log.Debugf("Claude request has no valid max_tokens, using default %d for %s", maxTokens, models[0])
+		changed = true
+	case limit > 0 && maxTokens > limit:
+		log.Debugf("Claude request max_tokens %d exceeds the output limit of %s, capped to %d", maxTokens, models[0], limit)
+		maxTokens = limit
+		changed = true
+	}
+	if fixClaudeThinking(reqData, &maxTokens, limit) {
+		changed = true
+	}
+	if changed {
+		reqData["max_tokens"] = maxTokens
+	}
+	if fixClaudeSampling(reqData) {
+		changed = true
+	}
+	if !changed {
+		return body
+	}
+	fixed, err := json.Marshal(reqData)
+	if err != nil {
+		return body
+	}
+	return fixed
+}
+
+// fixClaudeThinking 保证 thinking.budget_tokens 不少于 1024 且小于 max_tokens：先在输出上限内增大 max_tokens，
+// 仍然不够时减小预算（为回复保留一部分），max_tokens 容不下最小预算时关闭 thinking。返回请求是否被修改
+func fixClaudeThinking(reqData map[string]interface{}, maxTokens *int, limit int) bool {
+	thinking, ok := reqData["thinking"].(map[string]interface{})
+	if !ok || thinking["type"] != "enabled" {
+		return false
+	}
+	budget := numberValue(thinking["budget_tokens"])
+	original := budget
+	if budget < claudeMinThinkingBudget {
+		budget = claudeMinThinkingBudget
+	}
+	changed := false
+	if *maxTokens <= budget {
+		*maxTokens = budget + claudeFallbackMaxTokens
+		if limit > 0 && *maxTokens > limit {
+			*maxTokens = limit
+		}
+		changed = true
+	}
+	if *maxTokens <= budget {
+		budget = max(*maxTokens-min(claudeFallbackMaxTokens, *maxTokens/2), claudeMinThinkingBudget)
+	}
+	if budget >= *maxTokens {
+		delete(reqData, "thinking")
+		return true
+	}
+	if budget != original {
+		thinking["budget_tokens"] = budget
+		changed = true
+	}
+	return changed
+}
+
+// fixClaudeSampling 修正 Claude 不接受的取值：temperature、top_p 限制在 0~1，删除非正数的 top_k、
+// 空白的 stop_sequences（字符串改为数组）和空的 system。返回请求是否被修改
+func fixClaudeSampling(reqData map[string]interface{}) bool {
+	changed := false
+	for _, key := range []string{"temperature", "top_p"} {
+		v, ok := reqData[key].(float64)
+		if !ok {
+			continue
+		}
+		switch {
+		case v < 0:
+			reqData[key] = 0.0
+			changed = true
+		case v > 1:
+			reqData[key] = 1.0
+			changed = true
+		}
+	}
+	if topK, ok := reqData["top_k"].(float64); ok && topK <= 0 {
+		delete(reqData, "top_k")
+		changed = true
+	}
+	if raw, ok := reqData["stop_sequences"]; ok {
+		items, _ := raw.([]interface{})
+		stop, isString := raw.(string)
+		if isString {
+			items = []interface{}{stop}
+		}
+		stops := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			if stop, ok := item.(string); ok && strings.TrimSpace(stop) != "" {
+				stops = append(stops, stop)
+			}
+		}
+		switch {
+		case len(stops) == 0:
+			delete(reqData, "stop_sequences")
+			changed = true
+		case len(stops) != len(items) || isString:
+			reqData["stop_sequences"] = stops
+			changed = true
+		}
+	}
+	switch system := reqData["system"].(type) {
+	case string:
+		if strings.TrimSpace(system) == "" {
+			delete(reqData, "system")
+			changed = true
+		}
+	case []interface{}:
+		if len(system) == 0 {
+			delete(reqData, "system")
+			changed = true
+		}
+	case nil:
+		if _, ok := reqData["system"]; ok {
+			delete(reqData, "system")
+			changed = true
+		}
+	}
+	return changed
+}
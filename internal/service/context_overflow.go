@@ -0,0 +1,328 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"openai-router-go/internal/database"
+)
+
+// 上下文溢出处理：估算的输入 token 加上请求的输出 token 超过路由模型的上下文长度（模型元数据中配置）时，
+// 跳过上下文不足的路由、改用上下文更大的路由；没有路由容得下时按配置的策略删除最早的消息或将其总结为摘要，
+// 避免上游直接返回 400。没有配置上下文长度的模型不做处理
+
+// 没有路由容得下请求时的处理策略
+const (
+	ContextStrategyNone       = "none"        // 原样发送
+	ContextStrategyDropOldest = "drop_oldest" // 删除最早的消息（保留系统提示词和最后一条消息）
+	ContextStrategySummarize  = "summarize"   // 将删除的消息总结为摘要，失败时只删除
+)
+
+// contextModeKey headers 中的内部标记（不是合法的请求头名，客户端无法设置）
+const contextModeKey = ":context-overflow"
+
+const (
+	contextModeNoSummary = "no-summary" // 生成摘要的请求本身不再总结，避免递归
+	contextModeDryRun    = "dry-run"    // 路由预演：不调用上游生成摘要
+)
+
+const (
+	contextSummaryMaxChars  = 200000 // 发送给摘要模型的对话记录最大字符数，超出时保留最近的部分
+	contextSummaryMaxTokens = 1024   // 摘要最大输出 token
+	contextSummaryPrompt    = "Summarize the following earlier part of a conversation so it can replace those messages. " +
+		"Keep facts, decisions, open questions, code identifiers and user preferences. Reply with the summary only."
+)
+
+// ValidateContextStrategy 校验溢出处理策略
+func ValidateContextStrategy(strategy string) error {
+	switch strategy {
+	case ContextStrategyNone, ContextStrategyDropOldest, ContextStrategySummarize:
+		return nil
+	}
+	return fmt.Errorf("invalid context overflow strategy %q (allowed: none, drop_oldest, summarize)", strategy)
+}
+
+// contextWindows 按模型名返回配置了上下文长度的模型元数据
+func (s *RouteService) contextWindows() map[string]int {
+	items, err := s.GetModelMetadata()
+	if err != nil {
+		return nil
+	}
+	windows := make(map[string]int)
+	for _, m := range items {
+		if m.ContextWindow > 0 {
+			windows[strings.ToLower(m.Model)] = m.ContextWindow
+		}
+	}
+	return windows
+}
+
+// routeContextWindow 路由的上下文长度：依次使用上游模型名、路由模型名和请求的模型名的元数据，0 表示未知
+func routeContextWindow(windows map[string]int, route *database.ModelRoute, model string) int {
+	for _, name := range []string{route.UpstreamModel, route.Model, model} {
+		if w := windows[strings.ToLower(strings.TrimSpace(name))]; name != "" && w > 0 {
+			return w
+		}
+	}
+	return 0
+}
+
+// requestedOutputTokens 请求的最大输出 token（路由配置了上限时取较小值），未指定时为 0
+func requestedOutputTokens(reqData map[string]interface{}, routeLimit int) int {
+	tokens := 0
+	for _, key := range []string{"max_tokens", "max_completion_tokens", "max_output_tokens"} {
+		if v, ok := reqData[key].(float64); ok && v > 0 {
+			tokens = int(v)
+			break
+		}
+	}
+	if tokens == 0 {
+		for _, key := range []string{"generationConfig", "generation_config"} {
+			config, _ := reqData[key].(map[string]interface{})
+			for _, field := range []string{"maxOutputTokens", "max_output_tokens"} {
+				if v, ok := config[field].(float64); ok && v > 0 {
+					tokens = int(v)
+				}
+			}
+		}
+	}
+	if routeLimit > 0 && tokens > routeLimit {
+		tokens = routeLimit
+	}
+	return tokens
+}
+
+// fitContextWindow 按路由的上下文长度调整路由或裁剪请求，model 为选路后的模型名；
+// 修改了 reqData 时返回 true，调用方需要重新编码请求体
+func (s *ProxyService) fitContextWindow(routes []database.ModelRoute, model string, reqData map[string]interface{}, headers map[string]string) ([]database.ModelRoute, bool) {
+	if !s.config.ContextOverflowEnabled || len(routes) == 0 || len(reqData) == 0 {
+		return routes, false
+	}
+	windows := s.routeService.contextWindows()
+	if len(windows) == 0 {
+		return routes, false
+	}
+	body, err := json.Marshal(reqData)
+	if err != nil {
+		return routes, false
+	}
+	logger := requestLogger(headers)
+	prompt := estimatePromptTokens(model, body)
+	fits := func(route *database.ModelRoute) bool {
+		w := routeContextWindow(windows, route, model)
+		return w == 0 || prompt+requestedOutputTokens(reqData, route.MaxTokens) <= w
+	}
+
+	var fitting, small []database.ModelRoute
+	for _, route := range routes {
+		if fits(&route) {
+			fitting = append(fitting, route)
+		} else {
+			small = append(small, route)
+		}
+	}
+	if len(small) == 0 {
+		return routes, false
+	}
+	// Fallback 关闭时只选了一条路由，在模型的其它路由中找上下文足够的（请求头指定的路由除外）
+	if len(fitting) == 0 && len(routes) == 1 && headerValue(headers, RouteIDHeader) == "" {
+		if all, err := s.routeService.GetAllRoutesByModel(model); err == nil {
+			for _, route := range all {
+				if route.ID != routes[0].ID && fits(&route) {
+					fitting = append(fitting, route)
+					break
+				}
+			}
+		}
+	}
+	if len(fitting) > 0 {
+		logger.Infof("[Context] Estimated %d prompt tokens exceed the context window of %d route(s) for %s, using %s", prompt, len(small), model, fitting[0].Name)
+		return fitting, false
+	}
+
+	strategy := s.config.ContextOverflowStrategy
+	if strategy != ContextStrategyDropOldest && strategy != ContextStrategySummarize {
+		logger.Warnf("[Context] Estimated %d prompt tokens exceed the context window of every route for %s, sending as is", prompt, model)
+		return routes, false
+	}
+	// 按上下文最大的路由裁剪，该路由排在最前面
+	sort.SliceStable(small, func(i, j int) bool {
+		return routeContextWindow(windows, &small[i], model) > routeContextWindow(windows, &small[j], model)
+	})
+	largest := &small[0]
+	budget := routeContextWindow(windows, largest, model) - requestedOutputTokens(reqData, largest.MaxTokens)
+	if !s.trimContext(model, reqData, prompt, budget, strategy, headers) {
+		logger.Warnf("[Context] Request for %s cannot be trimmed to %d prompt tokens, sending as is", model, budget)
+		return routes, false
+	}
+	return small, true
+}
+
+// trimContext 删除最早的消息直到估算的输入 token 不超过 budget，summarize 策略时将删除的消息总结为摘要插入到保留的消息之前
+// 系统消息和最后一条消息始终保留，保留的对话从用户消息开始（不以助手回复或工具结果开头）；无法裁剪到 budget 以内时不修改请求
+func (s *ProxyService) trimContext(model string, reqData map[string]interface{}, prompt, budget int, strategy string, headers map[string]string) bool {
+	key := "messages"
+	if _, ok := reqData["contents"]; ok {
+		key = "contents"
+	}
+	messages, ok := reqData[key].([]interface{})
+	if !ok || len(messages) < 2 || budget <= 0 {
+		return false
+	}
+
+	costs := make([]int, len(messages))
+	for i, msg := range messages {
+		data, _ := json.Marshal(map[string]interface{}{key: []interface{}{msg}})
+		if tokens := estimatePromptTokens(model, data); tokens > 0 {
+			costs[i] = tokens - tokensReplyPriming
+		}
+	}
+
+	total := prompt
+	last := len(messages) - 1
+	dropped := make([]bool, len(messages))
+	i := 0
+	for ; i < last && total > budget; i++ {
+		if !isSystemMessage(messages[i]) {
+			dropped[i] = true
+			total -= costs[i]
+		}
+	}
+	for ; i < last; i++ {
+		if isSystemMessage(messages[i]) {
+			continue
+		}
+		if isUserTurn(messages[i]) {
+			break
+		}
+		dropped[i] = true
+		total -= costs[i]
+	}
+	if total > budget {
+		return false
+	}
+
+	var kept, removed []interface{}
+	insertAt := -1
+	for i, msg := range messages {
+		if dropped[i] {
+			removed = append(removed, msg)
+			continue
+		}
+		if insertAt < 0 && !isSystemMessage(msg) {
+			insertAt = len(kept)
+		}
+		kept = append(kept, msg)
+	}
+	if insertAt < 0 {
+		insertAt = len(kept)
+	}
+
+	logger := requestLogger(headers)
+	if strategy == ContextStrategySummarize && headers[contextModeKey] != contextModeNoSummary {
+		summary, err := s.summarizeMessages(model, removed, headers)
+		if err != nil {
+			logger.Warnf("[Context] Failed to summarize %d earlier messages, dropping them: %v", len(removed), err)
+		} else {
+			text := "[Summary of earlier conversation]\n" + summary
+			var msg map[string]interface{}
+			if key == "contents" {
+				msg = map[string]interface{}{"role": "user", "parts": []interface{}{map[string]interface{}{"text": text}}}
+			} else {
+				msg = map[string]interface{}{"role": "user", "content": text}
+			}
+			kept = append(kept[:insertAt], append([]interface{}{msg}, kept[insertAt:]...)...)
+			logger.Infof("[Context] Summarized %d earlier messages for %s (%d -> ~%d prompt tokens)", len(removed), model, prompt, total)
+			reqData[key] = kept
+			return true
+		}
+	}
+	logger.Infof("[Context] Dropped %d earlier messages for %s (%d -> ~%d prompt tokens)", len(removed), model, prompt, total)
+	reqData[key] = kept
+	return true
+}
+
+// summarizeMessages 通过代理调用摘要模型（未配置时使用请求的模型）总结删除的消息
+func (s *ProxyService) summarizeMessages(model string, messages []interface{}, headers map[string]string) (string, error) {
+	summaryModel := strings.TrimSpace(s.config.ContextSummaryModel)
+	if summaryModel == "" {
+		summaryModel = model
+	}
+	if headers[contextModeKey] == contextModeDryRun {
+		return fmt.Sprintf("(summary of %d earlier messages generated by %s)", len(messages), summaryModel), nil
+	}
+
+	var sb strings.Builder
+	for _, msg := range messages {
+		msgMap, _ := msg.(map[string]interface{})
+		role, _ := msgMap["role"].(string)
+		var text strings.Builder
+		collectUsageText(msgMap, false, &text)
+		sb.WriteString(role + ": " + strings.TrimSpace(text.String()) + "\n\n")
+	}
+	transcript := sb.String()
+	if len(transcript) > contextSummaryMaxChars {
+		transcript = transcript[len(transcript)-contextSummaryMaxChars:]
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"model": summaryModel,
+		"messages": []interface{}{
+			map[string]interface{}{"role": "system", "content": contextSummaryPrompt},
+			map[string]interface{}{"role": "user", "content": transcript},
+		},
+		"max_tokens": contextSummaryMaxTokens,
+	})
+	summaryHeaders := map[string]string{
+		contextModeKey: contextModeNoSummary,
+		"X-Real-IP":    headers["X-Real-IP"],
+	}
+	summaryHeaders[http.CanonicalHeaderKey(RequestIDHeader)] = NewRequestID()
+	respBody, statusCode, err := s.ProxyRequest(body, summaryHeaders)
+	if err != nil {
+		return "", err
+	}
+	if statusCode != http.StatusOK {
+		return "", fmt.Errorf("summary request returned HTTP %d", statusCode)
+	}
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil || len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("summary response has no content")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// isSystemMessage 是否为系统消息（裁剪时始终保留）
+func isSystemMessage(msg interface{}) bool {
+	msgMap, _ := msg.(map[string]interface{})
+	role, _ := msgMap["role"].(string)
+	return role == "system" || role == "developer"
+}
+
+// isUserTurn 是否为可以作为对话开头的用户消息（不包含工具结果）
+func isUserTurn(msg interface{}) bool {
+	msgMap, _ := msg.(map[string]interface{})
+	if role, _ := msgMap["role"].(string); role != "user" {
+		return false
+	}
+	blocks, _ := msgMap["content"].([]interface{})
+	if parts, ok := msgMap["parts"].([]interface{}); ok {
+		blocks = parts
+	}
+	for _, block := range blocks {
+		blockMap, _ := block.(map[string]interface{})
+		if blockMap["type"] == "tool_result" || blockMap["functionResponse"] != nil || blockMap["function_response"] != nil {
+			return false
+		}
+	}
+	return true
+}
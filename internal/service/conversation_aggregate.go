@@ -0,0 +1,255 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConversationModeAggregate fans out to several routes and returns a single answer
+const ConversationModeAggregate = "aggregate"
+
+// Aggregation strategies
+const (
+	AggregateFastest  = "fastest"  // first successful answer
+	AggregateMajority = "majority" // most common answer, JSON answers are compared structurally
+	AggregateJudge    = "judge"    // a judge route/model picks the best answer
+)
+
+// ConversationAggregateResponse is the single answer chosen from several routes
+type ConversationAggregateResponse struct {
+	Strategy        string                      `json:"strategy"`
+	Provider        string                      `json:"provider"`
+	Model           string                      `json:"model"`
+	Content         string                      `json:"content"`
+	TokensUsed      int                         `json:"tokens_used"`       // tokens of the winning answer
+	TotalTokensUsed int                         `json:"total_tokens_used"` // all candidates plus the judge
+	Winner          ConversationCompareResult   `json:"winner"`
+	Votes           int                         `json:"votes,omitempty"` // majority: answers equal to the winner
+	Judge           *ConversationCompareResult  `json:"judge,omitempty"`
+	Candidates      []ConversationCompareResult `json:"candidates"` // fastest: only the answers received before the winner
+	LatencyMs       int64                       `json:"latency_ms"`
+	Error           string                      `json:"error,omitempty"`
+}
+
+// IsAggregate reports whether the request uses aggregate mode
+func (req ConversationRequest) IsAggregate() bool {
+	return strings.EqualFold(strings.TrimSpace(req.Mode), ConversationModeAggregate)
+}
+
+// AggregateConversation sends the request to every route in req.Routes and picks one answer
+// with req.Strategy. The returned error is for invalid requests; when no route answers,
+// the response carries the error together with the failed candidates
+func (cs *ConversationService) AggregateConversation(req ConversationRequest, headers map[string]string) (*ConversationAggregateResponse, error) {
+	if err := validateFanOut(req, ConversationModeAggregate); err != nil {
+		return nil, err
+	}
+	strategy := strings.ToLower(strings.TrimSpace(req.Strategy))
+	switch strategy {
+	case "":
+		strategy = AggregateFastest
+	case AggregateFastest, AggregateMajority:
+	case AggregateJudge:
+		if req.JudgeRoute <= 0 && strings.TrimSpace(req.JudgeModel) == "" {
+			return nil, fmt.Errorf("judge strategy requires judge_route or judge_model")
+		}
+	default:
+		return nil, fmt.Errorf("unknown aggregation strategy: %s (allowed: fastest, majority, judge)", req.Strategy)
+	}
+
+	start := time.Now()
+	resp := &ConversationAggregateResponse{Strategy: strategy}
+	winner := -1
+	if strategy == AggregateFastest {
+		resp.Candidates, winner = cs.fastestAnswer(req, headers)
+	} else {
+		resp.Candidates = make([]ConversationCompareResult, len(req.Routes))
+		var wg sync.WaitGroup
+		for i, routeID := range req.Routes {
+			wg.Add(1)
+			go func(i int, routeID int64) {
+				defer wg.Done()
+				resp.Candidates[i] = cs.compareRoute(req, routeID, headers)
+			}(i, routeID)
+		}
+		wg.Wait()
+		if strategy == AggregateMajority {
+			winner, resp.Votes = majorityAnswer(resp.Candidates)
+		} else {
+			winner, resp.Judge = cs.judgeAnswer(req, resp.Candidates, headers)
+		}
+	}
+
+	for _, candidate := range resp.Candidates {
+		resp.TotalTokensUsed += candidate.TokensUsed
+	}
+	if resp.Judge != nil {
+		resp.TotalTokensUsed += resp.Judge.TokensUsed
+	}
+	resp.LatencyMs = time.Since(start).Milliseconds()
+	if winner < 0 {
+		resp.Error = "no route returned an answer"
+		return resp, nil
+	}
+	resp.Winner = resp.Candidates[winner]
+	resp.Provider = resp.Winner.Provider
+	resp.Model = resp.Winner.Model
+	resp.Content = resp.Winner.Content
+	resp.TokensUsed = resp.Winner.TokensUsed
+	return resp, nil
+}
+
+// fastestAnswer returns as soon as one route answers successfully. The other requests keep
+// running in the background so they are still logged; candidates are the results received so far
+func (cs *ConversationService) fastestAnswer(req ConversationRequest, headers map[string]string) ([]ConversationCompareResult, int) {
+	results := make(chan ConversationCompareResult, len(req.Routes))
+	for _, routeID := range req.Routes {
+		go func(routeID int64) {
+			results <- cs.compareRoute(req, routeID, headers)
+		}(routeID)
+	}
+	var candidates []ConversationCompareResult
+	for range req.Routes {
+		result := <-results
+		candidates = append(candidates, result)
+		if result.Error == "" {
+			return candidates, len(candidates) - 1
+		}
+	}
+	return candidates, -1
+}
+
+// majorityAnswer picks the most common successful answer; ties go to the earlier route
+func majorityAnswer(candidates []ConversationCompareResult) (winner, votes int) {
+	counts := make(map[string]int)
+	keys := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		if candidate.Error != "" {
+			continue
+		}
+		keys[i] = answerKey(candidate.Content)
+		counts[keys[i]]++
+	}
+	winner = -1
+	for i, candidate := range candidates {
+		if candidate.Error != "" {
+			continue
+		}
+		if n := counts[keys[i]]; n > votes {
+			winner, votes = i, n
+		}
+	}
+	return winner, votes
+}
+
+var codeFenceRegex = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*(.*?)\\s*```$")
+
+// answerKey normalises an answer for voting: JSON answers (optionally in a code fence) are
+// re-encoded with sorted keys, text answers are compared case-insensitively with collapsed whitespace
+func answerKey(content string) string {
+	content = strings.TrimSpace(content)
+	if m := codeFenceRegex.FindStringSubmatch(content); m != nil {
+		content = m[1]
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(content), &v); err == nil {
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return strings.ToLower(strings.Join(strings.Fields(content), " "))
+}
+
+var judgeChoiceRegex = regexp.MustCompile(`\d+`)
+
+// judgeMaxTokens is enough for the judge to reply with an answer number
+const judgeMaxTokens = 16
+
+// judgeAnswer asks the judge route/model to pick the best successful answer. If the judge fails
+// or its reply has no valid number, the first successful answer wins
+func (cs *ConversationService) judgeAnswer(req ConversationRequest, candidates []ConversationCompareResult, headers map[string]string) (int, *ConversationCompareResult) {
+	var answered []int
+	for i, candidate := range candidates {
+		if candidate.Error == "" {
+			answered = append(answered, i)
+		}
+	}
+	if len(answered) == 0 {
+		return -1, nil
+	}
+	if len(answered) == 1 {
+		return answered[0], nil
+	}
+
+	judgeReq := ConversationRequest{
+		Messages:  judgeMessages(req.Messages, candidates, answered),
+		MaxTokens: judgeMaxTokens,
+	}
+	var judge ConversationCompareResult
+	if req.JudgeRoute > 0 {
+		judge = cs.compareRoute(judgeReq, req.JudgeRoute, headers)
+	} else {
+		judge = cs.judgeByModel(judgeReq, req.JudgeModel, headers)
+	}
+	if judge.Error != "" {
+		return answered[0], &judge
+	}
+	if choice := judgeChoiceRegex.FindString(judge.Content); choice != "" {
+		if n, err := strconv.Atoi(choice); err == nil && n >= 1 && n <= len(answered) {
+			return answered[n-1], &judge
+		}
+	}
+	judge.Error = fmt.Sprintf("judge reply has no valid choice: %s", judge.Content)
+	return answered[0], &judge
+}
+
+// judgeByModel sends the judge request by model name in OpenAI format, routed like a normal request
+func (cs *ConversationService) judgeByModel(req ConversationRequest, model string, headers map[string]string) (result ConversationCompareResult) {
+	result = ConversationCompareResult{Provider: "openai", Model: model, RequestID: NewRequestID()}
+	defer func() {
+		if err := RecoverPanic(result.RequestID, recover()); err != nil {
+			result.Error = err.Error()
+		}
+	}()
+	req.Provider = result.Provider
+	req.Model = model
+	start := time.Now()
+	resp, err := cs.SendConversation(req, fanOutHeaders(headers, result.RequestID, 0))
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if resp != nil {
+		result.Content = resp.Content
+		result.TokensUsed = resp.TokensUsed
+		result.Error = resp.Error
+	}
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// judgeMessages builds the judge prompt: the last user message and the numbered answers
+func judgeMessages(messages []map[string]interface{}, candidates []ConversationCompareResult, answered []int) []map[string]interface{} {
+	question := ""
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i]["role"] == "user" {
+			question = messageText(messages[i]["content"])
+			break
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("Question:\n")
+	sb.WriteString(question)
+	sb.WriteString("\n\n")
+	for n, i := range answered {
+		fmt.Fprintf(&sb, "Answer %d:\n%s\n\n", n+1, candidates[i].Content)
+	}
+	fmt.Fprintf(&sb, "Which answer is the most correct and helpful? Reply with the answer number (1-%d) only.", len(answered))
+	return []map[string]interface{}{
+		{"role": "system", "content": "You are an impartial judge comparing answers from different AI assistants."},
+		{"role": "user", "content": sb.String()},
+	}
+}
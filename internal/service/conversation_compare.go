@@ -0,0 +1,138 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConversationModeCompare sends the same conversation to several routes concurrently
+const ConversationModeCompare = "compare"
+
+// conversationCompareMaxRoutes limits how many routes one compare request may fan out to
+const conversationCompareMaxRoutes = 8
+
+// ConversationCompareResult is the answer of one route in compare mode
+type ConversationCompareResult struct {
+	RouteID    int64  `json:"route_id"`
+	RouteName  string `json:"route_name"`
+	Provider   string `json:"provider"`
+	Model      string `json:"model"`
+	Content    string `json:"content"`
+	TokensUsed int    `json:"tokens_used"`
+	LatencyMs  int64  `json:"latency_ms"`
+	RequestID  string `json:"request_id"` // sub-request ID, can be looked up in logs and traces
+	Error      string `json:"error,omitempty"`
+}
+
+// ConversationCompareResponse holds the answers of all routes, in the order they were requested
+type ConversationCompareResponse struct {
+	Results   []ConversationCompareResult `json:"results"`
+	LatencyMs int64                       `json:"latency_ms"`
+}
+
+// IsCompare reports whether the request uses compare mode
+func (req ConversationRequest) IsCompare() bool {
+	return strings.EqualFold(strings.TrimSpace(req.Mode), ConversationModeCompare)
+}
+
+// CompareConversation sends the request to every route in req.Routes concurrently and waits
+// for all answers. Each route is pinned via the route override header and gets its own request ID,
+// so every answer is logged separately. Provider defaults to the route's own format when empty
+func (cs *ConversationService) CompareConversation(req ConversationRequest, headers map[string]string) (*ConversationCompareResponse, error) {
+	if err := validateFanOut(req, ConversationModeCompare); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	results := make([]ConversationCompareResult, len(req.Routes))
+	var wg sync.WaitGroup
+	for i, routeID := range req.Routes {
+		wg.Add(1)
+		go func(i int, routeID int64) {
+			defer wg.Done()
+			results[i] = cs.compareRoute(req, routeID, headers)
+		}(i, routeID)
+	}
+	wg.Wait()
+
+	return &ConversationCompareResponse{
+		Results:   results,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// validateFanOut checks the route list of a compare/aggregate request
+func validateFanOut(req ConversationRequest, mode string) error {
+	if len(req.Routes) == 0 {
+		return fmt.Errorf("%s mode requires at least one route", mode)
+	}
+	if len(req.Routes) > conversationCompareMaxRoutes {
+		return fmt.Errorf("%s mode supports at most %d routes", mode, conversationCompareMaxRoutes)
+	}
+	if req.Stream {
+		return fmt.Errorf("%s mode does not support streaming", mode)
+	}
+	return nil
+}
+
+// fanOutHeaders copies the client headers for one sub-request with its own request ID;
+// routeID > 0 pins the sub-request to that route
+func fanOutHeaders(headers map[string]string, requestID string, routeID int64) map[string]string {
+	routeHeaders := make(map[string]string, len(headers)+2)
+	for key, value := range headers {
+		routeHeaders[key] = value
+	}
+	for _, name := range []string{ProviderHeader, RouteIDHeader, RequestIDHeader} {
+		delete(routeHeaders, name)
+		delete(routeHeaders, http.CanonicalHeaderKey(name))
+	}
+	routeHeaders[http.CanonicalHeaderKey(RequestIDHeader)] = requestID
+	if routeID > 0 {
+		routeHeaders[http.CanonicalHeaderKey(RouteIDHeader)] = strconv.FormatInt(routeID, 10)
+	}
+	return routeHeaders
+}
+
+// compareRoute sends the conversation to a single route
+func (cs *ConversationService) compareRoute(req ConversationRequest, routeID int64, headers map[string]string) (result ConversationCompareResult) {
+	result = ConversationCompareResult{RouteID: routeID, RequestID: NewRequestID()}
+	// runs in a fan-out goroutine, a panic would otherwise take the server down
+	defer func() {
+		if err := RecoverPanic(result.RequestID, recover()); err != nil {
+			result.Error = err.Error()
+		}
+	}()
+	route, err := cs.routeService.GetRouteByID(routeID)
+	if err != nil {
+		result.Error = fmt.Sprintf("route %d not found or disabled", routeID)
+		return result
+	}
+	result.RouteName = route.Name
+	result.Model = route.Model
+
+	provider := strings.ToLower(strings.TrimSpace(req.Provider))
+	if provider == "" {
+		provider = normalizeFormat(route.Format)
+	}
+	result.Provider = provider
+
+	routeReq := req
+	routeReq.Provider = provider
+	routeReq.Model = route.Model
+	start := time.Now()
+	resp, err := cs.SendConversation(routeReq, fanOutHeaders(headers, result.RequestID, routeID))
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if resp != nil {
+		result.Content = resp.Content
+		result.TokensUsed = resp.TokensUsed
+		result.Error = resp.Error
+	}
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+	return result
+}
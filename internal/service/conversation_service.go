@@ -1,19 +1,23 @@
 package service
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
 	"openai-router-go/internal/config"
 )
 
-// ConversationService handles conversation aggregation for different AI providers
+// ConversationService handles conversation aggregation for different AI providers.
+// Requests go through the same proxy paths as direct API calls, so routing, fallback
+// and request logging (tokens, cost) behave the same
 type ConversationService struct {
-	routeService  *RouteService
-	proxyService  *ProxyService
-	config        *config.Config
+	routeService *RouteService
+	proxyService *ProxyService
+	config       *config.Config
 }
 
 // NewConversationService creates a new conversation service
@@ -27,70 +31,52 @@ func NewConversationService(routeService *RouteService, proxyService *ProxyServi
 
 // ConversationRequest represents a unified conversation request
 type ConversationRequest struct {
-	Provider    string                   `json:"provider"`    // "openai", "claude", or "gemini"
+	Provider    string                   `json:"provider"` // "openai", "claude", or "gemini"; optional in compare mode
 	Model       string                   `json:"model"`
 	Messages    []map[string]interface{} `json:"messages"`
 	Stream      bool                     `json:"stream,omitempty"`
 	MaxTokens   int                      `json:"max_tokens,omitempty"`
 	Temperature float64                  `json:"temperature,omitempty"`
+	Mode        string                   `json:"mode,omitempty"`        // "" for a single provider, "compare" or "aggregate" to fan out to Routes
+	Routes      []int64                  `json:"routes,omitempty"`      // route IDs for compare/aggregate mode
+	Strategy    string                   `json:"strategy,omitempty"`    // aggregate mode: fastest, majority or judge
+	JudgeRoute  int64                    `json:"judge_route,omitempty"` // judge strategy: route ID of the judge
+	JudgeModel  string                   `json:"judge_model,omitempty"` // judge strategy: model name when no judge route is set
 }
 
 // ConversationResponse represents a unified conversation response
 type ConversationResponse struct {
-	Provider  string      `json:"provider"`
-	Model     string      `json:"model"`
-	Content   string      `json:"content"`
-	TokensUsed int         `json:"tokens_used,omitempty"`
-	Error     string      `json:"error,omitempty"`
+	Provider    string      `json:"provider"`
+	Model       string      `json:"model"`
+	Content     string      `json:"content"`
+	TokensUsed  int         `json:"tokens_used,omitempty"`
+	Error       string      `json:"error,omitempty"`
 	RawResponse interface{} `json:"raw_response,omitempty"`
 }
 
-// SendConversation sends a conversation request to the specified provider
-func (cs *ConversationService) SendConversation(req ConversationRequest) (*ConversationResponse, error) {
-	switch strings.ToLower(req.Provider) {
-	case "openai":
-		return cs.sendOpenAIConversation(req)
-	case "claude":
-		return cs.sendClaudeConversation(req)
-	case "gemini":
-		return cs.sendGeminiConversation(req)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", req.Provider)
-	}
-}
-
-// sendOpenAIConversation sends a conversation using OpenAI format
-func (cs *ConversationService) sendOpenAIConversation(req ConversationRequest) (*ConversationResponse, error) {
-	// Construct OpenAI request
-	openaiReq := map[string]interface{}{
-		"model":    req.Model,
-		"messages": req.Messages,
-		"stream":   req.Stream,
-	}
-
-	if req.MaxTokens > 0 {
-		openaiReq["max_tokens"] = req.MaxTokens
-	}
-	if req.Temperature > 0 {
-		openaiReq["temperature"] = req.Temperature
-	}
-
-	// Convert to JSON
-	reqBody, err := json.Marshal(openaiReq)
+// SendConversation sends a conversation request to the specified provider.
+// headers are the client request headers (request ID, client IP, route override headers)
+func (cs *ConversationService) SendConversation(req ConversationRequest, headers map[string]string) (*ConversationResponse, error) {
+	provider := strings.ToLower(req.Provider)
+	body, err := cs.buildRequestBody(provider, req, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal OpenAI request: %v", err)
+		return nil, err
 	}
+	headers = conversationHeaders(provider, headers)
 
-	// Send request through proxy service
-	headers := map[string]string{
-		"Content-Type":  "application/json",
-		"Authorization": fmt.Sprintf("Bearer %s", cs.config.LocalAPIKey),
+	var respBody []byte
+	var statusCode int
+	switch provider {
+	case "openai":
+		respBody, statusCode, err = cs.proxyService.ProxyRequest(body, headers)
+	case "claude":
+		respBody, statusCode, err = cs.proxyService.ProxyAnthropicRequest(body, headers)
+	case "gemini":
+		respBody, statusCode, err = cs.proxyService.ProxyGeminiRequest(body, headers)
 	}
-
-	respBody, statusCode, err := cs.proxyService.ProxyRequest(reqBody, headers)
 	if err != nil {
 		return &ConversationResponse{
-			Provider: "openai",
+			Provider: provider,
 			Model:    req.Model,
 			Error:    err.Error(),
 		}, err
@@ -98,285 +84,400 @@ func (cs *ConversationService) sendOpenAIConversation(req ConversationRequest) (
 
 	if statusCode != http.StatusOK {
 		return &ConversationResponse{
-			Provider: "openai",
+			Provider: provider,
 			Model:    req.Model,
 			Error:    fmt.Sprintf("HTTP %d: %s", statusCode, string(respBody)),
-		}, fmt.Errorf("OpenAI API returned status %d", statusCode)
+		}, fmt.Errorf("%s API returned status %d", provider, statusCode)
 	}
 
-	// Parse OpenAI response
-	var openaiResp map[string]interface{}
-	if err := json.Unmarshal(respBody, &openaiResp); err != nil {
+	var respData map[string]interface{}
+	if err := json.Unmarshal(respBody, &respData); err != nil {
 		return &ConversationResponse{
-			Provider:  "openai",
-			Model:     req.Model,
-			Content:   string(respBody),
-			Error:     "Failed to parse response",
-			RawResponse: openaiResp,
+			Provider: provider,
+			Model:    req.Model,
+			Content:  string(respBody),
+			Error:    "Failed to parse response",
 		}, nil
 	}
 
-	// Extract content from OpenAI response
-	content := ""
-	tokensUsed := 0
+	content, tokensUsed := conversationResult(provider, respData)
+	return &ConversationResponse{
+		Provider:    provider,
+		Model:       req.Model,
+		Content:     content,
+		TokensUsed:  tokensUsed,
+		RawResponse: respData,
+	}, nil
+}
 
-	if choices, ok := openaiResp["choices"].([]interface{}); ok && len(choices) > 0 {
-		if choice, ok := choices[0].(map[string]interface{}); ok {
-			if message, ok := choice["message"].(map[string]interface{}); ok {
-				if contentStr, ok := message["content"].(string); ok {
-					content = contentStr
-				}
-			}
+// StreamConversation streams a conversation through the provider's streaming proxy and rewrites
+// the provider events as unified events: {"provider","model","content"} for each text delta,
+// then {"provider","model","done":true,"tokens_used"} and [DONE]. Errors are written as
+// {"provider","error"} events; the returned error is only for logging
+func (cs *ConversationService) StreamConversation(req ConversationRequest, headers map[string]string, writer io.Writer, flusher http.Flusher) error {
+	provider := strings.ToLower(req.Provider)
+	out := &conversationStreamWriter{writer: writer, provider: provider, model: req.Model}
+	body, err := cs.buildRequestBody(provider, req, true)
+	if err == nil {
+		headers = conversationHeaders(provider, headers)
+		switch provider {
+		case "openai":
+			err = cs.proxyService.ProxyStreamRequest(body, headers, out, flusher)
+		case "claude":
+			err = cs.proxyService.ProxyAnthropicStreamRequest(body, headers, out, flusher)
+		case "gemini":
+			err = cs.proxyService.ProxyGeminiStreamRequest(body, headers, out, flusher)
 		}
 	}
+	if err != nil {
+		out.emit(map[string]interface{}{"provider": provider, "error": err.Error()})
+	}
+	out.finish(err == nil)
+	flusher.Flush()
+	return err
+}
 
-	if usage, ok := openaiResp["usage"].(map[string]interface{}); ok {
-		if totalTokens, ok := usage["total_tokens"].(float64); ok {
-			tokensUsed = int(totalTokens)
-		}
+// conversationHeaders copies the client headers for the proxy request
+func conversationHeaders(provider string, clientHeaders map[string]string) map[string]string {
+	headers := make(map[string]string, len(clientHeaders)+2)
+	for k, v := range clientHeaders {
+		headers[k] = v
+	}
+	headers["Content-Type"] = "application/json"
+	if provider == "claude" && headers["Anthropic-Version"] == "" && headers["anthropic-version"] == "" {
+		headers["Anthropic-Version"] = "2023-06-01"
 	}
+	return headers
+}
 
-	return &ConversationResponse{
-		Provider:   "openai",
-		Model:      req.Model,
-		Content:    content,
-		TokensUsed: tokensUsed,
-		RawResponse: openaiResp,
-	}, nil
+// buildRequestBody converts the unified request to the provider's request format
+func (cs *ConversationService) buildRequestBody(provider string, req ConversationRequest, stream bool) ([]byte, error) {
+	var body map[string]interface{}
+	switch provider {
+	case "openai":
+		body = openAIConversationRequest(req, stream)
+	case "claude":
+		body = claudeConversationRequest(req, stream)
+	case "gemini":
+		body = geminiConversationRequest(req)
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", req.Provider)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %v", provider, err)
+	}
+	return data, nil
 }
 
-// sendClaudeConversation sends a conversation using Claude format
-func (cs *ConversationService) sendClaudeConversation(req ConversationRequest) (*ConversationResponse, error) {
-	// Convert OpenAI message format to Claude format
+// openAIConversationRequest builds an OpenAI chat completion request
+func openAIConversationRequest(req ConversationRequest, stream bool) map[string]interface{} {
+	openaiReq := map[string]interface{}{
+		"model":    req.Model,
+		"messages": req.Messages,
+		"stream":   stream,
+	}
+	if stream {
+		openaiReq["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	if req.MaxTokens > 0 {
+		openaiReq["max_tokens"] = req.MaxTokens
+	}
+	if req.Temperature > 0 {
+		openaiReq["temperature"] = req.Temperature
+	}
+	return openaiReq
+}
+
+// claudeConversationRequest builds a Claude messages request; system messages go to the system field
+func claudeConversationRequest(req ConversationRequest, stream bool) map[string]interface{} {
+	var system []string
 	claudeMessages := make([]map[string]interface{}, 0)
 	for _, msg := range req.Messages {
-		role, ok := msg["role"].(string)
-		if !ok {
-			continue
-		}
-
+		role, _ := msg["role"].(string)
 		content, ok := msg["content"].(string)
 		if !ok {
 			continue
 		}
-
-		// Convert roles
-		claudeRole := "user"
-		if role == "assistant" {
-			claudeRole = "assistant"
-		} else if role == "system" {
-			claudeRole = "user" // Claude expects system message as first user message
+		switch role {
+		case "system":
+			system = append(system, content)
+		case "assistant":
+			claudeMessages = append(claudeMessages, map[string]interface{}{"role": "assistant", "content": content})
+		default:
+			claudeMessages = append(claudeMessages, map[string]interface{}{"role": "user", "content": content})
 		}
-
-		claudeMessages = append(claudeMessages, map[string]interface{}{
-			"role":    claudeRole,
-			"content": content,
-		})
 	}
 
-	// Construct Claude request
 	claudeReq := map[string]interface{}{
 		"model":    req.Model,
 		"messages": claudeMessages,
-		"max_tokens": req.MaxTokens,
-	}
-
-	if req.Temperature > 0 {
-		claudeReq["temperature"] = req.Temperature
-	}
-
-	// Convert to JSON
-	reqBody, err := json.Marshal(claudeReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal Claude request: %v", err)
-	}
-
-	// Send request through anthropic adapter
-	headers := map[string]string{
-		"Content-Type":     "application/json",
-		"anthropic-version": "2023-06-01",
-		"x-api-key":         cs.config.LocalAPIKey,
-	}
-
-	respBody, statusCode, err := cs.proxyService.ProxyAnthropicRequest(reqBody, headers)
-	if err != nil {
-		return &ConversationResponse{
-			Provider: "claude",
-			Model:    req.Model,
-			Error:    err.Error(),
-		}, err
-	}
-
-	if statusCode != http.StatusOK {
-		return &ConversationResponse{
-			Provider: "claude",
-			Model:    req.Model,
-			Error:    fmt.Sprintf("HTTP %d: %s", statusCode, string(respBody)),
-		}, fmt.Errorf("Claude API returned status %d", statusCode)
+		"stream":   stream,
 	}
-
-	// Parse Claude response
-	var claudeResp map[string]interface{}
-	if err := json.Unmarshal(respBody, &claudeResp); err != nil {
-		return &ConversationResponse{
-			Provider:  "claude",
-			Model:     req.Model,
-			Content:   string(respBody),
-			Error:     "Failed to parse response",
-			RawResponse: claudeResp,
-		}, nil
+	// without max_tokens the proxy fills the per-model default (Claude requires it)
+	if req.MaxTokens > 0 {
+		claudeReq["max_tokens"] = req.MaxTokens
 	}
-
-	// Extract content from Claude response
-	content := ""
-	tokensUsed := 0
-
-	if contentBlock, ok := claudeResp["content"].([]interface{}); ok && len(contentBlock) > 0 {
-		if block, ok := contentBlock[0].(map[string]interface{}); ok {
-			if text, ok := block["text"].(string); ok {
-				content = text
-			}
-		}
+	if len(system) > 0 {
+		claudeReq["system"] = strings.Join(system, "\n\n")
 	}
-
-	if usage, ok := claudeResp["usage"].(map[string]interface{}); ok {
-		if totalTokens, ok := usage["input_tokens"].(float64); ok {
-			tokensUsed += int(totalTokens)
-		}
-		if outputTokens, ok := usage["output_tokens"].(float64); ok {
-			tokensUsed += int(outputTokens)
-		}
+	if req.Temperature > 0 {
+		claudeReq["temperature"] = req.Temperature
 	}
-
-	return &ConversationResponse{
-		Provider:   "claude",
-		Model:      req.Model,
-		Content:    content,
-		TokensUsed: tokensUsed,
-		RawResponse: claudeResp,
-	}, nil
+	return claudeReq
 }
 
-// sendGeminiConversation sends a conversation using Gemini format
-func (cs *ConversationService) sendGeminiConversation(req ConversationRequest) (*ConversationResponse, error) {
-	// Convert OpenAI message format to Gemini format
+// geminiConversationRequest builds a Gemini generateContent request; the model field is used
+// for routing like requests to /v1beta/models/{model}:generateContent
+func geminiConversationRequest(req ConversationRequest) map[string]interface{} {
+	var system []string
 	contents := make([]map[string]interface{}, 0)
 	for _, msg := range req.Messages {
-		role, ok := msg["role"].(string)
+		role, _ := msg["role"].(string)
+		content, ok := msg["content"].(string)
 		if !ok {
 			continue
 		}
-
-		content, ok := msg["content"].(string)
-		if !ok {
+		if role == "system" {
+			system = append(system, content)
 			continue
 		}
-
-		// Convert roles
 		geminiRole := "user"
 		if role == "assistant" {
 			geminiRole = "model"
 		}
-
 		contents = append(contents, map[string]interface{}{
-			"role":    geminiRole,
-			"parts": []map[string]interface{}{
-				{"text": content},
-			},
+			"role":  geminiRole,
+			"parts": []map[string]interface{}{{"text": content}},
 		})
 	}
 
-	// Construct Gemini request
 	geminiReq := map[string]interface{}{
+		"model":    req.Model,
 		"contents": contents,
 	}
-
+	if len(system) > 0 {
+		geminiReq["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]interface{}{{"text": strings.Join(system, "\n\n")}},
+		}
+	}
+	generationConfig := make(map[string]interface{})
 	if req.MaxTokens > 0 {
-		geminiReq["maxOutputTokens"] = req.MaxTokens
+		generationConfig["maxOutputTokens"] = req.MaxTokens
 	}
 	if req.Temperature > 0 {
-		geminiReq["temperature"] = req.Temperature
+		generationConfig["temperature"] = req.Temperature
+	}
+	if len(generationConfig) > 0 {
+		geminiReq["generationConfig"] = generationConfig
 	}
+	return geminiReq
+}
 
-	// Convert to JSON
-	reqBody, err := json.Marshal(geminiReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal Gemini request: %v", err)
+// conversationResult extracts the reply text and total tokens from a provider response
+func conversationResult(provider string, respData map[string]interface{}) (content string, tokensUsed int) {
+	switch provider {
+	case "openai":
+		if choices, ok := respData["choices"].([]interface{}); ok && len(choices) > 0 {
+			if choice, ok := choices[0].(map[string]interface{}); ok {
+				if message, ok := choice["message"].(map[string]interface{}); ok {
+					content, _ = message["content"].(string)
+				}
+			}
+		}
+		if usage, ok := respData["usage"].(map[string]interface{}); ok {
+			tokensUsed = numberValue(usage["total_tokens"])
+		}
+	case "claude":
+		if blocks, ok := respData["content"].([]interface{}); ok {
+			for _, block := range blocks {
+				if blockMap, ok := block.(map[string]interface{}); ok && blockMap["type"] == "text" {
+					text, _ := blockMap["text"].(string)
+					content += text
+				}
+			}
+		}
+		if usage, ok := respData["usage"].(map[string]interface{}); ok {
+			tokensUsed = numberValue(usage["input_tokens"]) + numberValue(usage["output_tokens"])
+		}
+	case "gemini":
+		respData = unwrapGeminiData(respData)
+		content = geminiCandidateText(respData)
+		if usageMetadata, ok := respData["usageMetadata"].(map[string]interface{}); ok {
+			tokensUsed = numberValue(usageMetadata["totalTokenCount"])
+		}
 	}
+	return content, tokensUsed
+}
 
-	// Send request through proxy service
-	headers := map[string]string{
-		"Content-Type": "application/json",
+// geminiCandidateText joins the text parts of the first candidate, skipping thought parts
+func geminiCandidateText(respData map[string]interface{}) string {
+	respData = unwrapGeminiData(respData)
+	candidates, _ := respData["candidates"].([]interface{})
+	if len(candidates) == 0 {
+		return ""
+	}
+	candidate, _ := candidates[0].(map[string]interface{})
+	contentMap, _ := candidate["content"].(map[string]interface{})
+	parts, _ := contentMap["parts"].([]interface{})
+	var text strings.Builder
+	for _, part := range parts {
+		if partMap, ok := part.(map[string]interface{}); ok {
+			if thought, _ := partMap["thought"].(bool); thought {
+				continue
+			}
+			if s, ok := partMap["text"].(string); ok {
+				text.WriteString(s)
+			}
+		}
 	}
+	return text.String()
+}
 
-	respBody, statusCode, err := cs.proxyService.ProxyRequest(reqBody, headers)
-	if err != nil {
-		return &ConversationResponse{
-			Provider: "gemini",
-			Model:    req.Model,
-			Error:    err.Error(),
-		}, err
+// unwrapGeminiData removes the {"code":200,"data":{...}} wrapper of Gemini proxy responses
+func unwrapGeminiData(respData map[string]interface{}) map[string]interface{} {
+	if data, ok := respData["data"].(map[string]interface{}); ok && respData["code"] != nil {
+		return data
 	}
+	return respData
+}
 
-	if statusCode != http.StatusOK {
-		return &ConversationResponse{
-			Provider: "gemini",
-			Model:    req.Model,
-			Error:    fmt.Sprintf("HTTP %d: %s", statusCode, string(respBody)),
-		}, fmt.Errorf("Gemini API returned status %d", statusCode)
+// numberValue converts a JSON number to int
+func numberValue(v interface{}) int {
+	if n, ok := v.(float64); ok {
+		return int(n)
 	}
+	return 0
+}
 
-	// Parse Gemini response
-	var geminiResp map[string]interface{}
-	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
-		return &ConversationResponse{
-			Provider:  "gemini",
-			Model:     req.Model,
-			Content:   string(respBody),
-			Error:     "Failed to parse response",
-			RawResponse: geminiResp,
-		}, nil
+// conversationStreamWriter receives the provider SSE events written by the streaming proxies
+// and writes unified conversation events to the client
+type conversationStreamWriter struct {
+	writer   io.Writer
+	provider string
+	model    string
+	buf      []byte
+	tokens   int // total tokens reported by the provider
+	input    int // Claude reports input and output tokens in separate events
+	output   int
+}
+
+// Header exposes the client response headers (request ID, route headers set by the proxy)
+func (w *conversationStreamWriter) Header() http.Header {
+	if h, ok := w.writer.(interface{ Header() http.Header }); ok {
+		return h.Header()
 	}
+	return http.Header{}
+}
 
-	// Extract content from Gemini response
-	content := ""
-	tokensUsed := 0
+func (w *conversationStreamWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		end := bytes.IndexByte(w.buf, '\n')
+		if end < 0 {
+			break
+		}
+		line := bytes.TrimSpace(w.buf[:end])
+		w.buf = w.buf[end+1:]
+		if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			if err := w.handle(bytes.TrimSpace(data)); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(p), nil
+}
 
-	if candidates, ok := geminiResp["candidates"].([]interface{}); ok && len(candidates) > 0 {
-		if candidate, ok := candidates[0].(map[string]interface{}); ok {
-			if contentParts, ok := candidate["content"].(map[string]interface{}); ok {
-				if parts, ok := contentParts["parts"].([]interface{}); ok && len(parts) > 0 {
-					if part, ok := parts[0].(map[string]interface{}); ok {
-						if text, ok := part["text"].(string); ok {
-							content = text
-						}
-					}
-				}
+// handle converts one provider event; events without text (role, stop, ping) are dropped
+func (w *conversationStreamWriter) handle(data []byte) error {
+	var event map[string]interface{}
+	if len(data) == 0 || data[0] != '{' || json.Unmarshal(data, &event) != nil {
+		return nil
+	}
+	if errValue, ok := event["error"]; ok && errValue != nil {
+		message := fmt.Sprint(errValue)
+		if errMap, ok := errValue.(map[string]interface{}); ok {
+			if m, ok := errMap["message"].(string); ok {
+				message = m
 			}
 		}
+		return w.emit(map[string]interface{}{"provider": w.provider, "error": message})
 	}
 
-	if usageMetadata, ok := geminiResp["usageMetadata"].(map[string]interface{}); ok {
-		if totalTokens, ok := usageMetadata["totalTokenCount"].(float64); ok {
-			tokensUsed = int(totalTokens)
+	text := ""
+	switch w.provider {
+	case "openai":
+		if choices, ok := event["choices"].([]interface{}); ok && len(choices) > 0 {
+			if choice, ok := choices[0].(map[string]interface{}); ok {
+				if delta, ok := choice["delta"].(map[string]interface{}); ok {
+					text, _ = delta["content"].(string)
+				}
+			}
+		}
+		if usage, ok := event["usage"].(map[string]interface{}); ok {
+			w.tokens = numberValue(usage["total_tokens"])
+		}
+	case "claude":
+		switch event["type"] {
+		case "content_block_delta":
+			if delta, ok := event["delta"].(map[string]interface{}); ok && delta["type"] == "text_delta" {
+				text, _ = delta["text"].(string)
+			}
+		case "message_start":
+			if message, ok := event["message"].(map[string]interface{}); ok {
+				if usage, ok := message["usage"].(map[string]interface{}); ok {
+					w.input = numberValue(usage["input_tokens"])
+				}
+			}
+		case "message_delta":
+			// converted streams may only report input_tokens in message_delta
+			if usage, ok := event["usage"].(map[string]interface{}); ok {
+				if input := numberValue(usage["input_tokens"]); input > 0 {
+					w.input = input
+				}
+				w.output = numberValue(usage["output_tokens"])
+			}
 		}
+		w.tokens = w.input + w.output
+	case "gemini":
+		event = unwrapGeminiData(event)
+		text = geminiCandidateText(event)
+		if usageMetadata, ok := event["usageMetadata"].(map[string]interface{}); ok {
+			w.tokens = numberValue(usageMetadata["totalTokenCount"])
+		}
+	}
+	if text == "" {
+		return nil
 	}
+	return w.emit(map[string]interface{}{"provider": w.provider, "model": w.model, "content": text})
+}
 
-	return &ConversationResponse{
-		Provider:   "gemini",
-		Model:      req.Model,
-		Content:    content,
-		TokensUsed: tokensUsed,
-		RawResponse: geminiResp,
-	}, nil
+// emit writes one unified event
+func (w *conversationStreamWriter) emit(event map[string]interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w.writer, "data: %s\n\n", data)
+	return err
+}
+
+// finish writes the closing event (for successful streams) and [DONE]
+func (w *conversationStreamWriter) finish(success bool) {
+	if success {
+		w.emit(map[string]interface{}{"provider": w.provider, "model": w.model, "done": true, "tokens_used": w.tokens})
+	}
+	io.WriteString(w.writer, "data: [DONE]\n\n")
 }
 
 // GetSDKExamples returns SDK code examples for all providers
-func (cs *ConversationService) GetSDKExamples() map[string]interface{} {
-	baseURL := fmt.Sprintf("http://%s:%d", cs.config.Host, cs.config.Port)
-	apiKey := cs.config.LocalAPIKey
+// model is used by the per-client configuration snippets, empty means the first available model
+func (cs *ConversationService) GetSDKExamples(model string) map[string]interface{} {
+	baseURL := cs.config.BaseURL()
+	apiKey := cs.config.AuthKey()
 
 	return map[string]interface{}{
+		"clients": cs.GetClientSnippets(model, apiKey),
 		"openai": map[string]interface{}{
 			"name":        "OpenAI",
 			"description": "Standard OpenAI SDK and compatible libraries",
@@ -640,7 +741,7 @@ func (cs *ConversationService) GetAvailableModels() (map[string][]string, error)
 		}
 
 		switch route.Format {
-		case "openai", "":
+		case "openai", "", "ollama", "mistral", "xai", "cohere", "deepseek":
 			models["openai"] = append(models["openai"], route.Model)
 		case "anthropic":
 			models["claude"] = append(models["claude"], route.Model)
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/database"
+)
+
+// 错误分类：失败的请求按原因归类（网络、超时、认证、限流、上游 5xx、格式转换、客户端断开等）写入 request_logs.error_type，
+// 错误统计按类型、路由和时间段汇总，用于判断错误突增是 Key 的问题还是供应商的问题。
+// 旧日志没有 error_type，读取时根据 error_message 分类
+
+// 错误类型
+const (
+	ErrorTypeNetwork     = "network"      // 连接失败、DNS、TLS、连接被重置
+	ErrorTypeTimeout     = "timeout"      // 上游超时
+	ErrorTypeAuth        = "auth"         // 上游返回 401/403
+	ErrorTypeRateLimit   = "rate_limit"   // 上游返回 429
+	ErrorTypeUpstream5xx = "upstream_5xx" // 上游返回 5xx
+	ErrorTypeUpstream4xx = "upstream_4xx" // 上游返回其他 4xx（请求参数错误等）
+	ErrorTypeConversion  = "conversion"   // 适配器格式转换失败
+	ErrorTypeClientAbort = "client_abort" // 客户端断开连接
+	ErrorTypeBlocked     = "blocked"      // 被内容审核规则或上游安全过滤拦截
+	ErrorTypeInternal    = "internal"     // 代理内部错误（panic）
+	ErrorTypeOther       = "other"
+)
+
+const (
+	errorStatsMaxSamples = 100000 // 最多读取的最近失败请求数
+	errorStatsRecent     = 50     // 返回的最近失败请求数
+	errorMessagePreview  = 300    // 最近失败请求的错误信息截断长度
+)
+
+// httpStatusPrefix 错误信息开头的上游状态码（"HTTP 429: ..."、"backend error: 503 - ..."、"backend auth error: 401 - ..."）
+var httpStatusPrefix = regexp.MustCompile(`^(?:HTTP|backend(?: \w+)? error:) (\d{3})\b`)
+
+// 错误信息关键字（小写），按顺序匹配
+var errorTypeKeywords = []struct {
+	errorType string
+	keywords  []string
+}{
+	{ErrorTypeClientAbort, []string{"context canceled", "write: broken pipe", "write: connection reset", "client disconnected", "http2: stream closed"}},
+	{ErrorTypeTimeout, []string{"deadline exceeded", "timeout", "timed out"}},
+	{ErrorTypeConversion, []string{"conversion failed", "failed to convert", "failed to adapt", "adapter not found"}},
+	{ErrorTypeBlocked, []string{"blocked by moderation", "blocked by gemini safety filters"}},
+	{ErrorTypeInternal, []string{"internal error:"}},
+	{ErrorTypeNetwork, []string{"connection refused", "connection reset", "no such host", "dial tcp", "tls:", "x509:", "eof", "network is unreachable", "server closed", "backend service unavailable"}},
+}
+
+// ErrorTypeForStatus 上游 HTTP 状态码对应的错误类型，2xx/3xx 返回空
+func ErrorTypeForStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorTypeAuth
+	case status == http.StatusTooManyRequests:
+		return ErrorTypeRateLimit
+	case status >= 500:
+		return ErrorTypeUpstream5xx
+	case status >= 400:
+		return ErrorTypeUpstream4xx
+	}
+	return ""
+}
+
+// ClassifyError 根据错误信息判断错误类型，无法识别时返回 other
+func ClassifyError(message string) string {
+	if m := httpStatusPrefix.FindStringSubmatch(message); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		if errorType := ErrorTypeForStatus(status); errorType != "" {
+			return errorType
+		}
+	}
+	lower := strings.ToLower(message)
+	for _, entry := range errorTypeKeywords {
+		for _, keyword := range entry.keywords {
+			if strings.Contains(lower, keyword) {
+				return entry.errorType
+			}
+		}
+	}
+	return ErrorTypeOther
+}
+
+// logConversionFailure 记录请求格式转换失败（未发送到上游）
+func (s *ProxyService) logConversionFailure(requestID, model string, route *database.ModelRoute, style string, stream bool, err error) {
+	s.routeService.LogRequestFull(RequestLogParams{
+		RequestID:     requestID,
+		Model:         model,
+		ProviderModel: route.Model,
+		ProviderName:  route.Name,
+		RouteID:       route.ID,
+		Success:       false,
+		ErrorMessage:  "request conversion failed: " + err.Error(),
+		ErrorType:     ErrorTypeConversion,
+		Style:         style,
+		IsStream:      stream,
+	})
+}
+
+// ErrorTypeCount 一种错误类型的次数
+type ErrorTypeCount struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// ErrorRouteStats 一个路由的请求数、失败数和各类型错误次数，Key 为空表示未选中路由的请求（如审核拦截）
+type ErrorRouteStats struct {
+	Key       string           `json:"key"`   // 路由ID
+	Label     string           `json:"label"` // 路由名
+	Requests  int64            `json:"requests"`
+	Failed    int64            `json:"failed"`
+	ErrorRate float64          `json:"error_rate"` // 百分比
+	Types     map[string]int64 `json:"types"`
+}
+
+// ErrorPoint 一个时间段内的请求数、失败数和各类型错误次数
+type ErrorPoint struct {
+	Time     string           `json:"time"` // 时间段开始时间 (2006-01-02 15:04)
+	Requests int64            `json:"requests"`
+	Failed   int64            `json:"failed"`
+	Types    map[string]int64 `json:"types"`
+}
+
+// RecentError 最近的一次失败请求
+type RecentError struct {
+	ID           int64  `json:"id"`
+	CreatedAt    string `json:"created_at"`
+	Model        string `json:"model"`
+	RouteID      int64  `json:"route_id"`
+	ProviderName string `json:"provider_name"`
+	ErrorType    string `json:"error_type"`
+	ErrorMessage string `json:"error_message"`
+	RequestID    string `json:"request_id"`
+}
+
+// ErrorStats 最近 hours 小时的错误统计
+type ErrorStats struct {
+	Hours         int               `json:"hours"`
+	BucketMinutes int               `json:"bucket_minutes"`
+	Requests      int64             `json:"requests"`
+	Failed        int64             `json:"failed"`
+	ErrorRate     float64           `json:"error_rate"` // 百分比
+	Types         []ErrorTypeCount  `json:"types"`
+	Routes        []ErrorRouteStats `json:"routes"`
+	Series        []ErrorPoint      `json:"series"`
+	Recent        []RecentError     `json:"recent"`
+}
+
+// GetErrorStats 统计最近 hours 小时的失败请求：按错误类型、按路由（按失败数降序）和按时间段
+func (s *RouteService) GetErrorStats(hours int) (*ErrorStats, error) {
+	hours, bucketMinutes, bucket, since := statsWindow(hours)
+	sinceStr := since.Format("2006-01-02 15:04:05")
+
+	stats := &ErrorStats{Hours: hours, BucketMinutes: bucketMinutes}
+	routes := make(map[int64]*ErrorRouteStats)
+	points := make(map[int64]*ErrorPoint)
+	routeStats := func(routeID int64, provider string) *ErrorRouteStats {
+		r := routes[routeID]
+		if r == nil {
+			r = &ErrorRouteStats{Label: provider, Types: make(map[string]int64)}
+			if routeID > 0 {
+				r.Key = strconv.FormatInt(routeID, 10)
+			}
+			routes[routeID] = r
+		}
+		return r
+	}
+	point := func(minute string) *ErrorPoint {
+		t, err := time.ParseInLocation("2006-01-02 15:04", minute, time.Local)
+		if err != nil {
+			return &ErrorPoint{Types: make(map[string]int64)}
+		}
+		start := t.Truncate(bucket).Unix()
+		p := points[start]
+		if p == nil {
+			p = &ErrorPoint{Types: make(map[string]int64)}
+			points[start] = p
+		}
+		return p
+	}
+
+	// 请求总数（按路由和分钟）
+	rows, err := s.db.Query(`
+		SELECT COALESCE(route_id, 0), COALESCE(provider_name, ''), substr(created_at, 1, 16), COUNT(*)
+		FROM request_logs
+		WHERE created_at >= ?
+		GROUP BY route_id, provider_name, substr(created_at, 1, 16)`, sinceStr)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var routeID, count int64
+		var provider, minute string
+		if err := rows.Scan(&routeID, &provider, &minute, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.Requests += count
+		routeStats(routeID, provider).Requests += count
+		point(minute).Requests += count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 失败请求
+	rows, err = s.db.Query(`
+		SELECT id, COALESCE(route_id, 0), COALESCE(provider_name, ''), model, COALESCE(error_type, ''), COALESCE(error_message, ''),
+			COALESCE(request_id, ''), substr(created_at, 1, 19)
+		FROM request_logs
+		WHERE success = 0 AND created_at >= ?
+		ORDER BY id DESC LIMIT ?`, sinceStr, errorStatsMaxSamples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]int64)
+	for rows.Next() {
+		var e RecentError
+		if err := rows.Scan(&e.ID, &e.RouteID, &e.ProviderName, &e.Model, &e.ErrorType, &e.ErrorMessage, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.ErrorType == "" {
+			e.ErrorType = ClassifyError(e.ErrorMessage)
+		}
+		stats.Failed++
+		types[e.ErrorType]++
+		r := routeStats(e.RouteID, e.ProviderName)
+		r.Failed++
+		r.Types[e.ErrorType]++
+		if len(e.CreatedAt) >= 16 {
+			p := point(e.CreatedAt[:16])
+			p.Failed++
+			p.Types[e.ErrorType]++
+		}
+		if len(stats.Recent) < errorStatsRecent {
+			if message := []rune(e.ErrorMessage); len(message) > errorMessagePreview {
+				e.ErrorMessage = string(message[:errorMessagePreview]) + "..."
+			}
+			stats.Recent = append(stats.Recent, e)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.Requests > 0 {
+		stats.ErrorRate = float64(stats.Failed) * 100 / float64(stats.Requests)
+	}
+	stats.Types = make([]ErrorTypeCount, 0, len(types))
+	for errorType, count := range types {
+		stats.Types = append(stats.Types, ErrorTypeCount{Type: errorType, Count: count})
+	}
+	sort.Slice(stats.Types, func(i, j int) bool {
+		if stats.Types[i].Count != stats.Types[j].Count {
+			return stats.Types[i].Count > stats.Types[j].Count
+		}
+		return stats.Types[i].Type < stats.Types[j].Type
+	})
+
+	// 路由名以当前配置为准
+	if all, err := s.GetAllRoutes(); err == nil {
+		for _, route := range all {
+			if r := routes[route.ID]; r != nil {
+				r.Label = route.Name
+			}
+		}
+	}
+	stats.Routes = make([]ErrorRouteStats, 0, len(routes))
+	for _, r := range routes {
+		if r.Failed == 0 {
+			continue
+		}
+		if r.Requests > 0 {
+			r.ErrorRate = float64(r.Failed) * 100 / float64(r.Requests)
+		}
+		stats.Routes = append(stats.Routes, *r)
+	}
+	sort.Slice(stats.Routes, func(i, j int) bool {
+		if stats.Routes[i].Failed != stats.Routes[j].Failed {
+			return stats.Routes[i].Failed > stats.Routes[j].Failed
+		}
+		return stats.Routes[i].Key < stats.Routes[j].Key
+	})
+
+	// 没有请求的时间段也输出，折线图时间轴连续
+	for t := since; !t.After(time.Now()); t = t.Add(bucket) {
+		p := ErrorPoint{Time: t.Format("2006-01-02 15:04"), Types: map[string]int64{}}
+		if existing := points[t.Unix()]; existing != nil {
+			p.Requests, p.Failed, p.Types = existing.Requests, existing.Failed, existing.Types
+		}
+		stats.Series = append(stats.Series, p)
+	}
+	return stats, nil
+}
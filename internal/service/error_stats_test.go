@@ -0,0 +1,100 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"HTTP 429: rate limited", ErrorTypeRateLimit},
+		{"HTTP 502: bad gateway", ErrorTypeUpstream5xx},
+		{"HTTP 400: invalid request", ErrorTypeUpstream4xx},
+		{"backend error: 429 - {\"error\":\"too many requests\"}", ErrorTypeRateLimit},
+		{"backend error: 503 - service unavailable (route: r1, url: https://api.example.com)", ErrorTypeUpstream5xx},
+		{"backend error: 404 - model not found", ErrorTypeUpstream4xx},
+		{"backend auth error: 401 - invalid key (route: r1, id: 1, url: https://api.example.com - please check API key configuration)", ErrorTypeAuth},
+		{"backend auth error: 403 - forbidden", ErrorTypeAuth},
+		{"backend service unavailable: dial tcp: connection refused", ErrorTypeNetwork},
+		{"request conversion failed: bad tools", ErrorTypeConversion},
+		{"context deadline exceeded", ErrorTypeTimeout},
+		{"write: broken pipe", ErrorTypeClientAbort},
+		{"something else", ErrorTypeOther},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyError(tt.message); got != tt.want {
+			t.Errorf("ClassifyError(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestProxyRequestLogsErrorType(t *testing.T) {
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"rate limited"}}`))
+	}))
+	defer limited.Close()
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"hi"}}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`))
+	}))
+	defer ok.Close()
+
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	routeService := NewRouteService(db, nil)
+	if err := routeService.AddRoute("a-limited", "gpt-test", limited.URL, "sk-test", "", "openai"); err != nil {
+		t.Fatal(err)
+	}
+	if err := routeService.AddRoute("b-ok", "gpt-test", ok.URL, "sk-test", "", "openai"); err != nil {
+		t.Fatal(err)
+	}
+	// 同优先级的路由随机排序，调低 b-ok 的优先级让 429 路由先尝试
+	var okID int64
+	if err := db.QueryRow(`SELECT id FROM model_routes WHERE name = 'b-ok'`).Scan(&okID); err != nil {
+		t.Fatal(err)
+	}
+	if err := routeService.SetRoutePriority(okID, 1); err != nil {
+		t.Fatal(err)
+	}
+	s := NewProxyService(routeService, &config.Config{FallbackEnabled: true})
+
+	body, status, err := s.ProxyRequest([]byte(`{"model":"gpt-test","messages":[{"role":"user","content":"hi"}]}`), map[string]string{RequestIDHeader: "req-1"})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("ProxyRequest = %d, %v: %s", status, err, body)
+	}
+	routeService.FlushRequestLogs()
+
+	rows, err := db.Query(`SELECT provider_name, success, COALESCE(error_type, '') FROM request_logs ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := make(map[string]string)
+	for rows.Next() {
+		var name, errorType string
+		var success bool
+		if err := rows.Scan(&name, &success, &errorType); err != nil {
+			t.Fatal(err)
+		}
+		got[name] = errorType
+		if success != (name == "b-ok") {
+			t.Errorf("route %s success = %v", name, success)
+		}
+	}
+	if got["a-limited"] != ErrorTypeRateLimit {
+		t.Errorf("error_type for the 429 route = %q, want %q (logs: %v)", got["a-limited"], ErrorTypeRateLimit, got)
+	}
+}
@@ -0,0 +1,461 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ExperimentArm 实验的一个分组：路由、流量权重和用于估算费用的单价（每百万 token）
+type ExperimentArm struct {
+	RouteID     int64   `json:"route_id"`
+	Weight      int     `json:"weight"`
+	InputPrice  float64 `json:"input_price"`
+	OutputPrice float64 `json:"output_price"`
+}
+
+// Experiment A/B 实验：在 [StartAt, EndAt) 时间窗口内，模型的请求按权重分配到各个分组的路由
+type Experiment struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Model     string          `json:"model"`
+	Arms      []ExperimentArm `json:"arms"`
+	StartAt   time.Time       `json:"start_at"`
+	EndAt     time.Time       `json:"end_at"`
+	Enabled   bool            `json:"enabled"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// active 实验是否在时间窗口内生效
+func (e *Experiment) active(now time.Time) bool {
+	return e.Enabled && !now.Before(e.StartAt) && now.Before(e.EndAt)
+}
+
+// ExperimentArmStats 单个分组在实验窗口内的统计
+type ExperimentArmStats struct {
+	RouteID        int64   `json:"route_id"`
+	RouteName      string  `json:"route_name"`
+	Weight         int     `json:"weight"`
+	Requests       int     `json:"requests"`
+	Errors         int     `json:"errors"`
+	ErrorRate      float64 `json:"error_rate"`
+	AvgLatencyMs   float64 `json:"avg_latency_ms"`
+	P95LatencyMs   int64   `json:"p95_latency_ms"`
+	AvgFirstByteMs float64 `json:"avg_first_byte_ms"`
+	RequestTokens  int64   `json:"request_tokens"`
+	ResponseTokens int64   `json:"response_tokens"`
+	Cost           float64 `json:"cost"`
+}
+
+// ExperimentPoint 图表使用的按小时统计点
+type ExperimentPoint struct {
+	Hour         string  `json:"hour"` // 2006-01-02 15
+	RouteID      int64   `json:"route_id"`
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	Tokens       int64   `json:"tokens"`
+}
+
+// ExperimentReport 实验对比报告，统计范围为实验窗口与当前时间的交集
+type ExperimentReport struct {
+	Experiment Experiment           `json:"experiment"`
+	Arms       []ExperimentArmStats `json:"arms"`
+	Hourly     []ExperimentPoint    `json:"hourly"`
+}
+
+// normalizeExperiment 校验实验配置：至少两个分组，分组路由必须能服务该模型，同一模型的启用实验时间不能重叠
+func (s *RouteService) normalizeExperiment(e *Experiment) error {
+	e.Name = strings.TrimSpace(e.Name)
+	e.Model = strings.TrimSpace(e.Model)
+	if e.Name == "" {
+		return fmt.Errorf("experiment name is required")
+	}
+	if e.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if !e.EndAt.After(e.StartAt) {
+		return fmt.Errorf("end time must be after start time")
+	}
+	e.StartAt, e.EndAt = e.StartAt.Local(), e.EndAt.Local()
+	if len(e.Arms) < 2 {
+		return fmt.Errorf("an experiment needs at least two arms")
+	}
+
+	seen := make(map[int64]bool, len(e.Arms))
+	for i := range e.Arms {
+		arm := &e.Arms[i]
+		if seen[arm.RouteID] {
+			return fmt.Errorf("route %d is used by more than one arm", arm.RouteID)
+		}
+		seen[arm.RouteID] = true
+		if arm.Weight <= 0 {
+			return fmt.Errorf("arm weight must be positive")
+		}
+		if arm.InputPrice < 0 || arm.OutputPrice < 0 {
+			return fmt.Errorf("price must not be negative")
+		}
+		route, err := s.GetRouteByID(arm.RouteID)
+		if err != nil {
+			return fmt.Errorf("route not found: id=%d", arm.RouteID)
+		}
+		if route.Model != e.Model && !strings.HasSuffix(route.Model, "/"+e.Model) {
+			return fmt.Errorf("route %s serves model %s, not %s", route.Name, route.Model, e.Model)
+		}
+	}
+
+	if e.Enabled {
+		others, err := s.experimentsForModel(e.Model)
+		if err != nil {
+			return err
+		}
+		for _, other := range others {
+			if other.ID != e.ID && other.StartAt.Before(e.EndAt) && e.StartAt.Before(other.EndAt) {
+				return fmt.Errorf("experiment %q already runs for model %s in this time window", other.Name, e.Model)
+			}
+		}
+	}
+	return nil
+}
+
+// scanExperiment 读取一行实验数据
+func scanExperiment(row rowScanner) (Experiment, error) {
+	var e Experiment
+	var arms string
+	var enabled int
+	if err := row.Scan(&e.ID, &e.Name, &e.Model, &arms, &e.StartAt, &e.EndAt, &enabled, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return e, err
+	}
+	e.Enabled = enabled == 1
+	if err := json.Unmarshal([]byte(arms), &e.Arms); err != nil {
+		log.Warnf("Invalid arms for experiment %d: %v", e.ID, err)
+	}
+	return e, nil
+}
+
+const experimentColumns = `id, name, model, arms, start_at, end_at, enabled, created_at, updated_at`
+
+// GetExperiments 获取所有实验（最近开始的在前）
+func (s *RouteService) GetExperiments() ([]Experiment, error) {
+	rows, err := s.db.Query(`SELECT ` + experimentColumns + ` FROM experiments ORDER BY start_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	experiments := make([]Experiment, 0)
+	for rows.Next() {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, e)
+	}
+	return experiments, rows.Err()
+}
+
+// GetExperiment 根据 ID 获取实验
+func (s *RouteService) GetExperiment(id int64) (*Experiment, error) {
+	e, err := scanExperiment(s.db.QueryRow(`SELECT `+experimentColumns+` FROM experiments WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("experiment not found: id=%d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// experimentsForModel 获取模型所有启用的实验
+func (s *RouteService) experimentsForModel(model string) ([]Experiment, error) {
+	rows, err := s.db.Query(`SELECT `+experimentColumns+` FROM experiments WHERE model = ? AND enabled = 1`, model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var experiments []Experiment
+	for rows.Next() {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, e)
+	}
+	return experiments, rows.Err()
+}
+
+// SaveExperiment 新增或更新实验
+func (s *RouteService) SaveExperiment(e Experiment) (Experiment, error) {
+	if err := s.normalizeExperiment(&e); err != nil {
+		return e, err
+	}
+	arms, _ := json.Marshal(e.Arms)
+	enabled := 0
+	if e.Enabled {
+		enabled = 1
+	}
+	now := time.Now()
+
+	var err error
+	if e.ID == 0 {
+		_, err = s.db.Exec(`
+			INSERT INTO experiments (name, model, arms, start_at, end_at, enabled, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.Name, e.Model, string(arms), e.StartAt, e.EndAt, enabled, now, now)
+		if err == nil {
+			err = s.db.QueryRow(`SELECT MAX(id) FROM experiments WHERE model = ? AND name = ?`, e.Model, e.Name).Scan(&e.ID)
+			e.CreatedAt = now
+		}
+	} else {
+		_, err = s.db.Exec(`
+			UPDATE experiments SET name = ?, model = ?, arms = ?, start_at = ?, end_at = ?, enabled = ?, updated_at = ?
+			WHERE id = ?`,
+			e.Name, e.Model, string(arms), e.StartAt, e.EndAt, enabled, now, e.ID)
+	}
+	if err != nil {
+		log.Errorf("Failed to save experiment: %v", err)
+		return e, err
+	}
+	e.UpdatedAt = now
+	log.Infof("Experiment saved: %s (model=%s, arms=%d, %s ~ %s, enabled=%v)",
+		e.Name, e.Model, len(e.Arms), e.StartAt.Format("2006-01-02 15:04"), e.EndAt.Format("2006-01-02 15:04"), e.Enabled)
+	return e, nil
+}
+
+// DeleteExperiment 删除实验（请求日志保留）
+func (s *RouteService) DeleteExperiment(id int64) error {
+	result, err := s.db.Exec(`DELETE FROM experiments WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("experiment not found: id=%d", id)
+	}
+	log.Infof("Experiment deleted: id=%d", id)
+	return nil
+}
+
+// activeExperiment 获取模型当前生效的实验，没有时返回 nil
+func (s *RouteService) activeExperiment(model string) *Experiment {
+	experiments, err := s.experimentsForModel(model)
+	if err != nil {
+		log.Warnf("Failed to load experiments: %v", err)
+		return nil
+	}
+	now := time.Now()
+	for i := range experiments {
+		if experiments[i].active(now) {
+			return &experiments[i]
+		}
+	}
+	return nil
+}
+
+// pickExperimentArm 按权重在候选路由中选择一个分组，返回路由 ID；候选中没有实验路由时返回 0
+func pickExperimentArm(e *Experiment, candidates map[int64]bool) int64 {
+	total := 0
+	for _, arm := range e.Arms {
+		if candidates[arm.RouteID] {
+			total += arm.Weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	n := rand.Intn(total)
+	for _, arm := range e.Arms {
+		if !candidates[arm.RouteID] {
+			continue
+		}
+		if n < arm.Weight {
+			return arm.RouteID
+		}
+		n -= arm.Weight
+	}
+	return 0
+}
+
+// applyExperiment 模型有生效的实验时，将选中分组的路由移到最前（其余路由保持顺序，仍可用于 Fallback）
+func (s *RouteService) applyExperiment(model string, routes []database.ModelRoute) []database.ModelRoute {
+	if len(routes) < 2 {
+		return routes
+	}
+	e := s.activeExperiment(model)
+	if e == nil {
+		return routes
+	}
+	candidates := make(map[int64]bool, len(routes))
+	for _, route := range routes {
+		candidates[route.ID] = true
+	}
+	chosen := pickExperimentArm(e, candidates)
+	if chosen == 0 {
+		return routes
+	}
+	ordered := make([]database.ModelRoute, 0, len(routes))
+	for _, route := range routes {
+		if route.ID == chosen {
+			ordered = append(ordered, route)
+		}
+	}
+	for _, route := range routes {
+		if route.ID != chosen {
+			ordered = append(ordered, route)
+		}
+	}
+	log.Infof("[Experiment] %s: routed %s to route %s", e.Name, model, ordered[0].Name)
+	return ordered
+}
+
+// experimentRoute 不启用 Fallback 时按实验权重选择路由，没有生效的实验时返回 nil
+func (s *RouteService) experimentRoute(model string) *database.ModelRoute {
+	e := s.activeExperiment(model)
+	if e == nil {
+		return nil
+	}
+	candidates := make(map[int64]bool, len(e.Arms))
+	for _, arm := range e.Arms {
+		candidates[arm.RouteID] = true
+	}
+	for len(candidates) > 0 {
+		id := pickExperimentArm(e, candidates)
+		route, err := s.GetRouteByID(id)
+		if err == nil && route.Enabled {
+			s.pickRouteKey(route)
+			log.Infof("[Experiment] %s: routed %s to route %s", e.Name, model, route.Name)
+			return route
+		}
+		delete(candidates, id)
+	}
+	return nil
+}
+
+// GetExperimentReport 统计实验窗口内每个分组的请求数、错误率、延迟、token 用量和费用
+func (s *RouteService) GetExperimentReport(id int64) (*ExperimentReport, error) {
+	e, err := s.GetExperiment(id)
+	if err != nil {
+		return nil, err
+	}
+	end := e.EndAt
+	if now := time.Now(); now.Before(end) {
+		end = now
+	}
+
+	report := &ExperimentReport{Experiment: *e, Arms: make([]ExperimentArmStats, 0, len(e.Arms)), Hourly: make([]ExperimentPoint, 0)}
+	arms := make(map[int64]*ExperimentArmStats, len(e.Arms))
+	prices := make(map[int64]ExperimentArm, len(e.Arms))
+	latencies := make(map[int64][]int64, len(e.Arms))
+	firstByte := make(map[int64][2]int64, len(e.Arms)) // 总和、计数
+	placeholders := make([]string, 0, len(e.Arms))
+	args := []interface{}{e.Model, e.StartAt.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05")}
+	for _, arm := range e.Arms {
+		stats := ExperimentArmStats{RouteID: arm.RouteID, Weight: arm.Weight}
+		if route, err := s.GetRouteByID(arm.RouteID); err == nil {
+			stats.RouteName = route.Name
+		}
+		report.Arms = append(report.Arms, stats)
+		prices[arm.RouteID] = arm
+		placeholders = append(placeholders, "?")
+		args = append(args, arm.RouteID)
+	}
+	for i := range report.Arms {
+		arms[report.Arms[i].RouteID] = &report.Arms[i]
+	}
+	if !end.After(e.StartAt) {
+		return report, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT route_id, success, proxy_time_ms, first_chunk_ms, request_tokens, response_tokens, substr(created_at, 1, 13)
+		FROM request_logs
+		WHERE model = ? AND created_at >= ? AND created_at < ? AND route_id IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hourly := make(map[string]*ExperimentPoint)
+	for rows.Next() {
+		var routeID, proxyMs, firstMs, reqTokens, respTokens int64
+		var success bool
+		var hour string
+		if err := rows.Scan(&routeID, &success, &proxyMs, &firstMs, &reqTokens, &respTokens, &hour); err != nil {
+			return nil, err
+		}
+		stats := arms[routeID]
+		if stats == nil {
+			continue
+		}
+		point := hourly[fmt.Sprintf("%s|%d", hour, routeID)]
+		if point == nil {
+			point = &ExperimentPoint{Hour: hour, RouteID: routeID}
+			hourly[fmt.Sprintf("%s|%d", hour, routeID)] = point
+		}
+
+		stats.Requests++
+		point.Requests++
+		if !success {
+			stats.Errors++
+			point.Errors++
+			continue
+		}
+		// 延迟和 token 只统计成功的请求
+		latencies[routeID] = append(latencies[routeID], proxyMs)
+		point.AvgLatencyMs += float64(proxyMs)
+		point.Tokens += reqTokens + respTokens
+		if firstMs > 0 {
+			fb := firstByte[routeID]
+			firstByte[routeID] = [2]int64{fb[0] + firstMs, fb[1] + 1}
+		}
+		stats.RequestTokens += reqTokens
+		stats.ResponseTokens += respTokens
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range report.Arms {
+		stats := &report.Arms[i]
+		if stats.Requests > 0 {
+			stats.ErrorRate = float64(stats.Errors) / float64(stats.Requests)
+		}
+		if values := latencies[stats.RouteID]; len(values) > 0 {
+			var sum int64
+			for _, v := range values {
+				sum += v
+			}
+			stats.AvgLatencyMs = float64(sum) / float64(len(values))
+			sort.Slice(values, func(a, b int) bool { return values[a] < values[b] })
+			stats.P95LatencyMs = values[(len(values)*95-1)/100]
+		}
+		if fb := firstByte[stats.RouteID]; fb[1] > 0 {
+			stats.AvgFirstByteMs = float64(fb[0]) / float64(fb[1])
+		}
+		price := prices[stats.RouteID]
+		stats.Cost = (float64(stats.RequestTokens)*price.InputPrice + float64(stats.ResponseTokens)*price.OutputPrice) / 1e6
+	}
+
+	for _, point := range hourly {
+		if ok := point.Requests - point.Errors; ok > 0 {
+			point.AvgLatencyMs /= float64(ok)
+		}
+		report.Hourly = append(report.Hourly, *point)
+	}
+	sort.Slice(report.Hourly, func(a, b int) bool {
+		if report.Hourly[a].Hour != report.Hourly[b].Hour {
+			return report.Hourly[a].Hour < report.Hourly[b].Hour
+		}
+		return report.Hourly[a].RouteID < report.Hourly[b].RouteID
+	})
+	return report, nil
+}
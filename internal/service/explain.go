@@ -0,0 +1,329 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/database"
+)
+
+// 路由预演：按真实代理的流程检测格式、选择路由、应用重定向和适配器并构造上游请求，但不发送，用于调试路由配置
+// 优先级相同的路由与正常请求一样随机排序，多 Key 路由同样轮换 Key，因此多次预演的顺序和 Key 可能不同
+
+// 路由选择方式
+const (
+	ExplainSelectRouteOverride    = "route_override"    // 请求头指定了路由 ID
+	ExplainSelectProviderOverride = "provider_override" // 请求头指定了供应商
+	ExplainSelectRedirect         = "redirect"          // 命中重定向关键字
+	ExplainSelectRoutingRule      = "routing_rule"      // 命中按请求特征路由的规则
+	ExplainSelectSingle           = "single"            // Fallback 关闭，只使用一条路由
+	ExplainSelectFallback         = "fallback"          // Fallback 开启，按顺序尝试所有匹配的路由
+)
+
+// explainEndpoints 支持预演的入口格式，与代理接口一一对应
+var explainEndpoints = map[string]bool{
+	"openai":     true, // /v1/chat/completions
+	"cursor":     true, // /cursor/v1/chat/completions
+	"claude":     true, // /anthropic/v1/messages
+	"claudecode": true, // /claudecode/v1/messages
+	"gemini":     true, // /gemini/v1beta/models/{model}:generateContent
+}
+
+// RouteExplanation 一次预演的结果
+type RouteExplanation struct {
+	Endpoint        string           `json:"endpoint"`
+	DetectedFormat  string           `json:"detected_format"`
+	RequestedModel  string           `json:"requested_model"`
+	TargetModel     string           `json:"target_model"`
+	Stream          bool             `json:"stream"`
+	Selection       string           `json:"selection"`
+	RoutingRule     string           `json:"routing_rule,omitempty"`
+	FallbackEnabled bool             `json:"fallback_enabled"`
+	ProxyPaused     bool             `json:"proxy_paused"`
+	ContextTrimmed  bool             `json:"context_trimmed"` // 请求超过所有路由的上下文长度，已按策略裁剪消息
+	Routes          []ExplainedRoute `json:"routes"`
+}
+
+// ExplainedRoute 按尝试顺序排列的一条路由及其上游请求（已脱敏）
+type ExplainedRoute struct {
+	Order         int               `json:"order"`
+	RouteID       int64             `json:"route_id"`
+	RouteName     string            `json:"route_name"`
+	Group         string            `json:"group"`
+	Model         string            `json:"model"`
+	UpstreamModel string            `json:"upstream_model,omitempty"`
+	TargetFormat  string            `json:"target_format"`
+	Adapter       string            `json:"adapter"`
+	Method        string            `json:"method,omitempty"`
+	URL           string            `json:"url,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Payload       json.RawMessage   `json:"payload,omitempty"`
+	Error         string            `json:"error,omitempty"`
+}
+
+// ExplainRequest 预演请求的路由过程；endpoint 为空时按请求体自动检测
+func (s *ProxyService) ExplainRequest(endpoint string, requestBody []byte, headers map[string]string) (*RouteExplanation, int, error) {
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(requestBody, &reqData); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %v", err)
+	}
+	model, ok := reqData["model"].(string)
+	if !ok || model == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("'model' field is required")
+	}
+
+	detected := detectRequestFormat(reqData)
+	if _, hasContents := reqData["contents"]; hasContents && detected == "openai" {
+		detected = "gemini"
+	}
+	endpoint = strings.ToLower(strings.TrimSpace(endpoint))
+	if endpoint == "" {
+		endpoint = detected
+	}
+	if !explainEndpoints[endpoint] {
+		return nil, http.StatusBadRequest, fmt.Errorf("unsupported endpoint: %s (expected openai, cursor, claude, claudecode or gemini)", endpoint)
+	}
+	stream, _ := reqData["stream"].(bool)
+
+	result := &RouteExplanation{
+		Endpoint:        endpoint,
+		DetectedFormat:  detected,
+		RequestedModel:  model,
+		Stream:          stream,
+		FallbackEnabled: s.config.FallbackEnabled,
+		ProxyPaused:     s.IsPaused(),
+	}
+	result.Selection, result.RoutingRule = s.explainSelection(model, reqData, headers)
+
+	routes, targetModel, err := s.selectRoutes(model, reqData, headers)
+	if err != nil {
+		return nil, routeSelectionStatus(err), err
+	}
+	result.TargetModel = targetModel
+	if targetModel != model {
+		reqData["model"] = targetModel
+		requestBody, _ = json.Marshal(reqData)
+	}
+	// 上下文溢出处理与正常请求一致，summarize 策略只标记摘要位置，不调用上游
+	dryRunHeaders := map[string]string{contextModeKey: contextModeDryRun}
+	for k, v := range headers {
+		dryRunHeaders[k] = v
+	}
+	routes, result.ContextTrimmed = s.fitContextWindow(routes, targetModel, reqData, dryRunHeaders)
+	if result.ContextTrimmed {
+		requestBody, _ = json.Marshal(reqData)
+	}
+
+	for i := range routes {
+		route := &routes[i]
+		item := ExplainedRoute{
+			Order:         i + 1,
+			RouteID:       route.ID,
+			RouteName:     route.Name,
+			Group:         route.Group,
+			Model:         route.Model,
+			UpstreamModel: route.UpstreamModel,
+			TargetFormat:  probeTargetFormat(route),
+		}
+		req, target, adapterName, err := s.explainUpstreamRequest(endpoint, requestBody, route, targetModel, headers, stream)
+		if target != "" {
+			item.TargetFormat = target
+		}
+		item.Adapter = adapterName
+		if err != nil {
+			item.Error = err.Error()
+		} else {
+			describeExplainedRequest(&item, req)
+		}
+		result.Routes = append(result.Routes, item)
+	}
+	return result, http.StatusOK, nil
+}
+
+// explainSelection 按 selectRoutes 的判断顺序说明路由是如何选出的，命中按请求特征路由的规则时同时返回规则名
+func (s *ProxyService) explainSelection(model string, reqData map[string]interface{}, headers map[string]string) (string, string) {
+	switch {
+	case headerValue(headers, RouteIDHeader) != "":
+		return ExplainSelectRouteOverride, ""
+	case headerValue(headers, ProviderHeader) != "":
+		return ExplainSelectProviderOverride, ""
+	case s.config.RedirectEnabled && (model == s.config.RedirectKeyword || strings.HasPrefix(model, s.config.RedirectKeyword+":")):
+		return ExplainSelectRedirect, ""
+	}
+	if rule := s.matchRoutingRule(model, reqData); rule != nil {
+		// 规则的目标没有可用路由时 selectRoutes 按原模型选择
+		if _, _, err := s.ruleRoutes(rule, model); err == nil {
+			return ExplainSelectRoutingRule, rule.Name
+		}
+	}
+	if !s.config.FallbackEnabled {
+		return ExplainSelectSingle, ""
+	}
+	return ExplainSelectFallback, ""
+}
+
+// explainUpstreamRequest 按入口格式构造发往路由的上游请求，与对应代理接口的转换过程一致
+func (s *ProxyService) explainUpstreamRequest(endpoint string, requestBody []byte, route *database.ModelRoute, model string, headers map[string]string, stream bool) (req *http.Request, target, adapterName string, err error) {
+	// 每条路由都从原始请求开始处理
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(requestBody, &reqData); err != nil {
+		return nil, "", "", err
+	}
+
+	switch endpoint {
+	case "claude", "claudecode":
+		inbound := inboundFormats[endpoint]
+		target, bridge, ok := pipelineTarget(inbound, route)
+		if ok && bridge.Request != nil {
+			adapterName = inbound.Format + "-to-" + target
+		}
+		if stream {
+			reqData["stream"] = true
+			requestBody, _ = json.Marshal(reqData)
+		}
+		call := &pipelineCall{inbound: inbound, reqData: reqData, body: requestBody, model: model, headers: headers, requestID: requestIDFromHeaders(headers)}
+		req, _, _, err = s.upstreamRequest(call, route, stream)
+		return req, target, adapterName, err
+	case "gemini":
+		req, target, adapterName, err = s.explainGeminiRequest(reqData, requestBody, route, model, headers, stream)
+	default:
+		req, target, adapterName, err = s.explainOpenAIRequest(reqData, route, model, headers, stream)
+	}
+	return req, target, adapterName, err
+}
+
+// explainOpenAIRequest OpenAI/Cursor 入口：Cursor 格式先转换为 OpenAI 格式，再按路由选择适配器
+func (s *ProxyService) explainOpenAIRequest(reqData map[string]interface{}, route *database.ModelRoute, model string, headers map[string]string, stream bool) (*http.Request, string, string, error) {
+	requestFormat := detectRequestFormat(reqData)
+	if requestFormat == "cursor" {
+		converted, err := s.adaptCursorRequest(reqData, model)
+		if err != nil {
+			return nil, "", "", err
+		}
+		reqData = converted
+		requestFormat = "openai"
+	}
+	routeReq, _ := s.applyPromptTemplate(reqData, route, requestFormat, model)
+
+	target := probeTargetFormat(route)
+	adapterName := s.detectAdapterForRoute(route, requestFormat)
+	cleanAPIUrl := strings.TrimSuffix(route.APIUrl, "/")
+	var body []byte
+	var targetURL string
+	if adapterName != "" {
+		adapter := adapters.GetAdapter(adapterName)
+		if adapter == nil {
+			return nil, target, adapterName, fmt.Errorf("adapter not found: %s", adapterName)
+		}
+		if stream {
+			routeReq["stream"] = true
+		}
+		transformed, err := adapter.AdaptRequest(routeReq, model)
+		if err != nil {
+			return nil, target, adapterName, err
+		}
+		body, _ = json.Marshal(transformed)
+		if stream {
+			targetURL = s.buildAdapterStreamURL(cleanAPIUrl, adapterName, model)
+		} else {
+			targetURL = s.buildAdapterURL(cleanAPIUrl, adapterName, model)
+		}
+	} else {
+		if stream {
+			routeReq["stream"] = true
+			routeReq["stream_options"] = map[string]interface{}{"include_usage": true}
+		}
+		body, _ = json.Marshal(routeReq)
+		targetURL = buildRouteChatURL(route.APIUrl, route.Format)
+	}
+
+	req, err := s.newRouteRequest(route, targetURL, body, requestIDFromHeaders(headers), func(req *http.Request) {
+		setUpstreamAuth(req, route.APIUrl, route.APIKey, headers)
+		if stream && adapterName == "anthropic" {
+			s.setAnthropicHeaders(req, headers)
+		}
+	})
+	return req, target, adapterName, err
+}
+
+// explainGeminiRequest Gemini 入口：目标为 Gemini 时透传，否则经 OpenAI 格式转换
+func (s *ProxyService) explainGeminiRequest(reqData map[string]interface{}, requestBody []byte, route *database.ModelRoute, model string, headers map[string]string, stream bool) (*http.Request, string, string, error) {
+	reqData, requestBody = s.withPromptTemplate(reqData, requestBody, route, "gemini", model)
+	target := probeTargetFormat(route)
+	cleanAPIUrl := strings.TrimSuffix(route.APIUrl, "/")
+
+	var adapterName, targetURL string
+	var body []byte
+	switch target {
+	case "gemini":
+		body = requestBody
+		if stream {
+			targetURL = fmt.Sprintf("%s/v1beta/models/%s:streamGenerateContent?alt=sse", cleanAPIUrl, model)
+		} else {
+			targetURL = fmt.Sprintf("%s/v1beta/models/%s:generateContent", cleanAPIUrl, model)
+		}
+	case "openai", "claude":
+		if stream {
+			reqData["stream"] = true
+		}
+		adapterName = "gemini-to-openai"
+		converted, err := adapters.GetAdapter("gemini-to-openai").AdaptRequest(reqData, model)
+		if err != nil {
+			return nil, target, adapterName, err
+		}
+		targetURL = buildRouteChatURL(route.APIUrl, route.Format)
+		if target == "claude" {
+			adapterName += ", openai-to-claude"
+			if converted, err = adapters.GetAdapter("openai-to-claude").AdaptRequest(converted, model); err != nil {
+				return nil, target, adapterName, err
+			}
+			targetURL = buildClaudeMessagesURL(cleanAPIUrl)
+		}
+		body, _ = json.Marshal(converted)
+	default:
+		return nil, target, "", fmt.Errorf("unsupported target format: %s", target)
+	}
+
+	req, err := s.newRouteRequest(route, targetURL, body, requestIDFromHeaders(headers), func(req *http.Request) {
+		if route.APIKey == "" {
+			return
+		}
+		switch target {
+		case "claude":
+			req.Header.Set("x-api-key", route.APIKey)
+			s.setAnthropicHeaders(req, headers)
+		case "gemini":
+			req.Header.Set("x-goog-api-key", route.APIKey)
+		default:
+			req.Header.Set("Authorization", "Bearer "+route.APIKey)
+		}
+	})
+	return req, target, adapterName, err
+}
+
+// describeExplainedRequest 记录上游请求的地址、请求头和请求体，API Key 脱敏
+func describeExplainedRequest(item *ExplainedRoute, req *http.Request) {
+	redactor := NewSecretRedactor(nil, []string{upstreamRequestKey(req)})
+	item.Method = req.Method
+	item.URL = redactor.Redact(req.URL.String())
+	item.Headers = inspectionHeaders(req.Header, redactor)
+	if req.GetBody == nil {
+		return
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return
+	}
+	body, _ := io.ReadAll(rc)
+	rc.Close()
+	payload := redactor.Redact(string(body))
+	if json.Valid([]byte(payload)) {
+		item.Payload = json.RawMessage(payload)
+	} else {
+		item.Payload, _ = json.Marshal(payload)
+	}
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// 从其它网关迁移：解析 LiteLLM config.yaml、one-api 渠道导出或 OpenRouter 模型列表并创建对应的路由
+// 上游地址和格式来自内置的供应商模板，无法对应的条目记录在导入结果中
+
+// 导入来源
+const (
+	ExternalSourceLiteLLM    = "litellm"
+	ExternalSourceOneAPI     = "one-api"
+	ExternalSourceOpenRouter = "openrouter"
+)
+
+// ExternalImportResult 外部配置导入结果
+type ExternalImportResult struct {
+	Source   string   `json:"source"`
+	Routes   int      `json:"routes"`
+	Skipped  int      `json:"skipped"`  // 已存在（名称、模型、地址相同）的路由
+	Mapped   []string `json:"mapped"`   // 已创建的路由
+	Warnings []string `json:"warnings"` // 已创建但与原配置有差异（如模型别名、环境变量未设置）
+	Unmapped []string `json:"unmapped"` // 无法导入的条目及原因
+}
+
+// liteLLMProviders LiteLLM 模型前缀 -> 供应商模板，空字符串表示 OpenAI 兼容接口（需要 api_base）
+var liteLLMProviders = map[string]string{
+	"openai":        "openai",
+	"azure":         "azure",
+	"anthropic":     "anthropic",
+	"gemini":        "gemini",
+	"groq":          "groq",
+	"deepseek":      "deepseek",
+	"openrouter":    "openrouter",
+	"together_ai":   "together",
+	"fireworks_ai":  "fireworks",
+	"mistral":       "mistral",
+	"xai":           "xai",
+	"cohere":        "cohere",
+	"cohere_chat":   "cohere",
+	"ollama":        "ollama",
+	"ollama_chat":   "ollama",
+	"lm_studio":     "lmstudio",
+	"moonshot":      "moonshot",
+	"hosted_vllm":   "",
+	"openai_like":   "",
+	"custom_openai": "",
+}
+
+// oneAPIChannelTypes one-api 渠道类型 -> 供应商模板，空字符串表示 OpenAI 兼容接口（需要 base_url）
+var oneAPIChannelTypes = map[int]string{
+	1:  "openai",
+	2:  "", // API2D
+	3:  "azure",
+	4:  "", // CloseAI
+	5:  "", // OpenAI-SB
+	6:  "", // OpenAIMax
+	7:  "", // OhMyGPT
+	8:  "", // 自定义渠道
+	10: "", // AI Proxy
+	12: "", // API2GPT
+	13: "", // AIGC2D
+	14: "anthropic",
+	20: "openrouter",
+	24: "gemini",
+	25: "moonshot",
+	28: "mistral",
+	29: "groq",
+	30: "ollama",
+	35: "cohere",
+	36: "deepseek",
+	39: "together",
+	44: "siliconflow",
+	45: "xai",
+}
+
+// liteLLMEntry LiteLLM config.yaml 中 model_list 的一项
+type liteLLMEntry struct {
+	ModelName     string                 `yaml:"model_name"`
+	LiteLLMParams map[string]interface{} `yaml:"litellm_params"`
+}
+
+// oneAPIChannel one-api 渠道导出（/api/channel 返回的 data 或渠道数组）
+type oneAPIChannel struct {
+	Name         string `yaml:"name"`
+	Type         int    `yaml:"type"`
+	Key          string `yaml:"key"`
+	BaseURL      string `yaml:"base_url"`
+	Models       string `yaml:"models"`
+	ModelMapping string `yaml:"model_mapping"`
+	Status       int    `yaml:"status"`
+}
+
+// openRouterModel OpenRouter /api/v1/models 返回的模型
+type openRouterModel struct {
+	ID string `yaml:"id"`
+}
+
+// paramString 读取 LiteLLM 参数中的字符串
+func paramString(params map[string]interface{}, key string) string {
+	if v, ok := params[key]; ok && v != nil {
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+	return ""
+}
+
+// resolveLiteLLMKey 解析 LiteLLM 的 api_key，os.environ/NAME 从环境变量读取
+func resolveLiteLLMKey(value string) (key, missingEnv string) {
+	if name, ok := strings.CutPrefix(value, "os.environ/"); ok {
+		if key = os.Getenv(name); key == "" {
+			return "", name
+		}
+		return key, ""
+	}
+	return value, ""
+}
+
+// azureV1URL Azure 资源地址转换为 v1 接口地址
+func azureV1URL(base string) string {
+	base = strings.TrimSuffix(strings.TrimSpace(base), "/")
+	if i := strings.Index(base, "/openai"); i >= 0 {
+		base = base[:i]
+	}
+	return base + "/openai/v1/"
+}
+
+// externalRouteURL 按供应商模板和自定义地址得到路由地址
+// fixedPath 为 true 时自定义地址的路径视为固定路径（LiteLLM 的 api_base 通常已包含 /v1），OpenAI 格式末尾补斜杠
+func externalRouteURL(templateID, customBase string, fixedPath bool) (apiURL, format string, err error) {
+	format = "openai"
+	if templateID != "" {
+		tpl, ok := providerTemplate(templateID)
+		if !ok {
+			return "", "", fmt.Errorf("unknown provider template %s", templateID)
+		}
+		apiURL, format = tpl.BaseURL, tpl.Format
+	}
+	customBase = strings.TrimSpace(customBase)
+	switch {
+	case templateID == "azure":
+		if customBase == "" {
+			return "", "", fmt.Errorf("azure requires the resource endpoint")
+		}
+		return azureV1URL(customBase), format, nil
+	case customBase == "":
+		if apiURL == "" {
+			return "", "", fmt.Errorf("OpenAI-compatible provider requires a base URL")
+		}
+		return apiURL, format, nil
+	case templateID == "gemini" && !strings.Contains(customBase, "/openai"):
+		// 自定义的 Gemini 地址使用其 OpenAI 兼容接口
+		return strings.TrimSuffix(customBase, "/") + "/v1beta/openai/", format, nil
+	}
+
+	customBase = strings.TrimSuffix(customBase, "/")
+	if fixedPath && format == "openai" {
+		if u, err := url.Parse(customBase); err == nil && strings.Trim(u.Path, "/") != "" {
+			return customBase + "/", format, nil
+		}
+	}
+	return customBase, format, nil
+}
+
+// addExternalRoute 创建一条导入的路由，已存在时跳过
+func (s *RouteService) addExternalRoute(result *ExternalImportResult, r BundleRoute) {
+	if s.findRouteID(r.Name, r.Model, r.APIUrl) != 0 {
+		result.Skipped++
+		return
+	}
+	if _, err := s.importRoute(r); err != nil {
+		log.Errorf("Failed to import route %s: %v", r.Name, err)
+		result.Unmapped = append(result.Unmapped, fmt.Sprintf("%s (%s): %v", r.Name, r.Model, err))
+		return
+	}
+	result.Routes++
+	result.Mapped = append(result.Mapped, fmt.Sprintf("%s: %s -> %s [%s]", r.Name, r.Model, r.APIUrl, r.Format))
+}
+
+// importLiteLLM 导入 LiteLLM model_list，模型前缀决定供应商，api_base/api_key 覆盖模板
+func (s *RouteService) importLiteLLM(result *ExternalImportResult, entries []liteLLMEntry, apiKey string) {
+	for _, entry := range entries {
+		name := strings.TrimSpace(entry.ModelName)
+		model := paramString(entry.LiteLLMParams, "model")
+		if model == "" {
+			result.Unmapped = append(result.Unmapped, fmt.Sprintf("%s: litellm_params.model is empty", name))
+			continue
+		}
+		apiBase := paramString(entry.LiteLLMParams, "api_base")
+
+		prefix, upstream, hasPrefix := strings.Cut(model, "/")
+		var templateID string
+		if hasPrefix {
+			id, ok := liteLLMProviders[prefix]
+			if !ok {
+				result.Unmapped = append(result.Unmapped, fmt.Sprintf("%s: unsupported LiteLLM provider %q", name, prefix))
+				continue
+			}
+			templateID = id
+		} else {
+			// 没有前缀时 LiteLLM 按模型名推断供应商
+			upstream = model
+			switch lower := strings.ToLower(model); {
+			case apiBase != "":
+				templateID = ""
+			case strings.HasPrefix(lower, "claude"):
+				templateID = "anthropic"
+			case strings.HasPrefix(lower, "gemini"):
+				templateID = "gemini"
+			default:
+				templateID = "openai"
+			}
+		}
+
+		apiURL, format, err := externalRouteURL(templateID, apiBase, true)
+		if err != nil {
+			result.Unmapped = append(result.Unmapped, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+
+		key, missingEnv := resolveLiteLLMKey(paramString(entry.LiteLLMParams, "api_key"))
+		if key == "" {
+			key = apiKey
+		}
+		if missingEnv != "" && key == "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: environment variable %s is not set, API key left empty", name, missingEnv))
+		}
+		if name != "" && name != upstream {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: clients must request %q (model aliases are not supported)", name, upstream))
+		}
+		if v := paramString(entry.LiteLLMParams, "api_version"); templateID == "azure" && v != "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: api_version %s ignored, using the Azure v1 API", name, v))
+		}
+
+		group := "LiteLLM"
+		if tpl, ok := providerTemplate(templateID); ok {
+			group = tpl.Name
+		}
+		if name == "" {
+			name = upstream
+		}
+		s.addExternalRoute(result, BundleRoute{Name: name, Model: upstream, APIUrl: apiURL, APIKey: key, Group: group, Format: format})
+	}
+}
+
+// importOneAPI 导入 one-api 渠道：渠道的每个模型创建一条路由，渠道名作为路由名和分组
+func (s *RouteService) importOneAPI(result *ExternalImportResult, channels []oneAPIChannel, apiKey string) {
+	for _, ch := range channels {
+		name := strings.TrimSpace(ch.Name)
+		templateID, ok := oneAPIChannelTypes[ch.Type]
+		if !ok {
+			result.Unmapped = append(result.Unmapped, fmt.Sprintf("channel %s: unsupported channel type %d", name, ch.Type))
+			continue
+		}
+		apiURL, format, err := externalRouteURL(templateID, ch.BaseURL, false)
+		if err != nil {
+			result.Unmapped = append(result.Unmapped, fmt.Sprintf("channel %s: %v", name, err))
+			continue
+		}
+		mapping := make(map[string]string)
+		if strings.TrimSpace(ch.ModelMapping) != "" {
+			if err := json.Unmarshal([]byte(ch.ModelMapping), &mapping); err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("channel %s: invalid model_mapping ignored: %v", name, err))
+			}
+		}
+		key := ch.Key
+		if key == "" {
+			key = apiKey
+		}
+		// status 2（手动禁用）、3（自动禁用）导入为禁用
+		enabled := ch.Status <= 1
+
+		models := strings.Split(ch.Models, ",")
+		imported := 0
+		for _, model := range models {
+			model = strings.TrimSpace(model)
+			if model == "" {
+				continue
+			}
+			upstream := model
+			if target := strings.TrimSpace(mapping[model]); target != "" && target != model {
+				upstream = target
+				result.Warnings = append(result.Warnings, fmt.Sprintf("channel %s: clients must request %q instead of %q (model aliases are not supported)", name, upstream, model))
+			}
+			s.addExternalRoute(result, BundleRoute{Name: name, Model: upstream, APIUrl: apiURL, APIKey: key, Group: name, Format: format, Enabled: &enabled})
+			imported++
+		}
+		if imported == 0 {
+			result.Unmapped = append(result.Unmapped, fmt.Sprintf("channel %s: no models", name))
+		}
+	}
+}
+
+// importOpenRouter 导入 OpenRouter 模型列表，所有模型共用 apiKey
+func (s *RouteService) importOpenRouter(result *ExternalImportResult, models []openRouterModel, apiKey string) {
+	tpl, _ := providerTemplate("openrouter")
+	if apiKey == "" {
+		result.Warnings = append(result.Warnings, "OpenRouter model list contains no API key, routes are created without one")
+	}
+	for _, m := range models {
+		if m.ID == "" {
+			continue
+		}
+		s.addExternalRoute(result, BundleRoute{Name: tpl.Name, Model: m.ID, APIUrl: tpl.BaseURL, APIKey: apiKey, Group: tpl.Name, Format: tpl.Format})
+	}
+}
+
+// ImportExternalConfig 识别并导入 LiteLLM config.yaml、one-api 渠道导出或 OpenRouter 模型列表（JSON/YAML）
+// apiKey 用于配置中没有 Key 的条目（如 OpenRouter 模型列表），可为空
+func (s *RouteService) ImportExternalConfig(data []byte, apiKey string) (*ExternalImportResult, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty config")
+	}
+	apiKey = strings.TrimSpace(apiKey)
+
+	// YAML 兼容 JSON，先按通用结构识别来源
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	var items interface{}
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		if list, ok := v["model_list"]; ok {
+			var cfg struct {
+				ModelList []liteLLMEntry `yaml:"model_list"`
+			}
+			if err := remarshalYAML(map[string]interface{}{"model_list": list}, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid LiteLLM config: %v", err)
+			}
+			result := newExternalImportResult(ExternalSourceLiteLLM)
+			s.importLiteLLM(result, cfg.ModelList, apiKey)
+			return logExternalImport(result), nil
+		}
+		items = v["data"]
+	case []interface{}:
+		items = v
+	}
+
+	list, _ := items.([]interface{})
+	if len(list) == 0 {
+		return nil, fmt.Errorf("unrecognized config: expected a LiteLLM config.yaml (model_list), a one-api channel export or an OpenRouter model list")
+	}
+	first, _ := list[0].(map[string]interface{})
+	if _, ok := first["type"]; ok {
+		var channels []oneAPIChannel
+		if err := remarshalYAML(list, &channels); err != nil {
+			return nil, fmt.Errorf("invalid one-api channel export: %v", err)
+		}
+		result := newExternalImportResult(ExternalSourceOneAPI)
+		s.importOneAPI(result, channels, apiKey)
+		return logExternalImport(result), nil
+	}
+	if _, ok := first["id"]; ok {
+		var models []openRouterModel
+		if err := remarshalYAML(list, &models); err != nil {
+			return nil, fmt.Errorf("invalid OpenRouter model list: %v", err)
+		}
+		result := newExternalImportResult(ExternalSourceOpenRouter)
+		s.importOpenRouter(result, models, apiKey)
+		return logExternalImport(result), nil
+	}
+	return nil, fmt.Errorf("unrecognized config: expected a LiteLLM config.yaml (model_list), a one-api channel export or an OpenRouter model list")
+}
+
+func newExternalImportResult(source string) *ExternalImportResult {
+	return &ExternalImportResult{
+		Source:   source,
+		Mapped:   make([]string, 0),
+		Warnings: make([]string, 0),
+		Unmapped: make([]string, 0),
+	}
+}
+
+func logExternalImport(result *ExternalImportResult) *ExternalImportResult {
+	log.Infof("External config imported (%s): %d routes, %d skipped, %d warnings, %d unmapped",
+		result.Source, result.Routes, result.Skipped, len(result.Warnings), len(result.Unmapped))
+	return result
+}
+
+// remarshalYAML 将通用结构转换为具体类型
+func remarshalYAML(in interface{}, out interface{}) error {
+	data, err := yaml.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
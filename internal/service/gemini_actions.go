@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// forwardGeminiAction 将官方操作（countTokens、embedContent 等）原样转发到 Gemini 格式的路由
+func (s *ProxyService) forwardGeminiAction(route database.ModelRoute, action string, body []byte, requestID string) ([]byte, int, error) {
+	targetURL := fmt.Sprintf("%s/v1beta/models/%s:%s", strings.TrimSuffix(route.APIUrl, "/"), route.Model, action)
+	log.Infof("[Gemini %s] Forwarding to: %s", action, targetURL)
+
+	proxyReq, err := http.NewRequest("POST", targetURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	proxyReq.Header.Set("Content-Type", "application/json")
+	if route.APIKey != "" {
+		proxyReq.Header.Set("x-goog-api-key", route.APIKey)
+	}
+	applyRouteExtras(proxyReq, route.ExtraHeaders, route.ExtraQuery)
+	applyUpstreamModel(proxyReq, route.UpstreamModel)
+	proxyReq = withRequestID(proxyReq, requestID)
+
+	resp, err := s.doWithRetry(proxyReq, route.Name)
+	if err != nil {
+		return nil, http.StatusServiceUnavailable, fmt.Errorf("backend service unavailable: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return respBody, resp.StatusCode, nil
+}
+
+// GeminiCountTokens 实现 models/{model}:countTokens
+// Gemini 路由转发到上游；其他格式的上游没有对应接口，使用本地 tokenizer 估算
+func (s *ProxyService) GeminiCountTokens(model string, body []byte, headers map[string]string) ([]byte, int, error) {
+	routes, _, err := s.selectRoutes(model, nil, headers)
+	if err != nil {
+		return nil, routeSelectionStatus(err), err
+	}
+	route := routes[0]
+	if probeTargetFormat(&route) == "gemini" {
+		return s.forwardGeminiAction(route, "countTokens", body, requestIDFromHeaders(headers))
+	}
+
+	var reqData map[string]interface{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &reqData); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %v", err)
+		}
+	}
+	// 请求可以是 {contents} 或 {generateContentRequest: {contents, systemInstruction, tools}}
+	if inner, ok := reqData["generateContentRequest"].(map[string]interface{}); ok {
+		reqData = inner
+	}
+	data, _ := json.Marshal(reqData)
+	total := 0
+	if tokens := estimatePromptTokens(route.Model, data); tokens > 0 {
+		total = tokens - tokensReplyPriming
+	}
+	log.Infof("[Gemini countTokens] Estimated %d tokens locally for %s (route %s is %s format)", total, model, route.Name, probeTargetFormat(&route))
+	respBody, _ := json.Marshal(map[string]interface{}{"totalTokens": total})
+	return respBody, http.StatusOK, nil
+}
+
+// GeminiEmbedContent 实现 models/{model}:embedContent 和 :batchEmbedContents
+// Gemini 路由转发到上游；OpenAI 格式的路由转换为 /embeddings 请求后将结果转换回 Gemini 格式
+func (s *ProxyService) GeminiEmbedContent(model, action string, body []byte, headers map[string]string) ([]byte, int, error) {
+	requestID := requestIDFromHeaders(headers)
+	routes, _, err := s.selectRoutes(model, nil, headers)
+	if err != nil {
+		return nil, routeSelectionStatus(err), err
+	}
+	route := routes[0]
+
+	switch probeTargetFormat(&route) {
+	case "gemini":
+		startTime := time.Now()
+		respBody, statusCode, err := s.forwardGeminiAction(route, action, body, requestID)
+		errMsg, errType := "", ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if statusCode != http.StatusOK {
+			errMsg = string(respBody)
+			errType = ErrorTypeForStatus(statusCode)
+		}
+		s.routeService.LogRequestFull(RequestLogParams{
+			RequestID:     requestID,
+			Model:         model,
+			ProviderModel: route.Model,
+			ProviderName:  route.Name,
+			RouteID:       route.ID,
+			Success:       errMsg == "",
+			ErrorMessage:  errMsg,
+			ErrorType:     errType,
+			Style:         "gemini",
+			RemoteIP:      headers["X-Real-IP"],
+			ProxyTimeMs:   time.Since(startTime).Milliseconds(),
+		})
+		return respBody, statusCode, err
+	case "openai":
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("route %s (%s format) does not support embeddings", route.Name, probeTargetFormat(&route))
+	}
+
+	// Gemini -> OpenAI embeddings
+	var reqData struct {
+		Content              json.RawMessage `json:"content"`
+		OutputDimensionality int             `json:"outputDimensionality"`
+		Requests             []struct {
+			Content              json.RawMessage `json:"content"`
+			OutputDimensionality int             `json:"outputDimensionality"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %v", err)
+	}
+	var inputs []string
+	dimensions := reqData.OutputDimensionality
+	if action == "batchEmbedContents" {
+		for _, r := range reqData.Requests {
+			inputs = append(inputs, geminiContentText(r.Content))
+			if r.OutputDimensionality > 0 {
+				dimensions = r.OutputDimensionality
+			}
+		}
+	} else {
+		inputs = append(inputs, geminiContentText(reqData.Content))
+	}
+	if len(inputs) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("no content to embed")
+	}
+
+	openaiReq := map[string]interface{}{"model": model, "input": inputs}
+	if dimensions > 0 {
+		openaiReq["dimensions"] = dimensions
+	}
+	openaiBody, _ := json.Marshal(openaiReq)
+	w := &batchResponseWriter{header: make(http.Header)}
+	statusCode, err := s.ProxyPassthroughRequest(openaiBody, headers, "embeddings", w)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if w.status != http.StatusOK {
+		return w.body.Bytes(), w.status, nil
+	}
+
+	var openaiResp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.body.Bytes(), &openaiResp); err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("invalid embeddings response: %v", err)
+	}
+	embeddings := make([]map[string]interface{}, len(inputs))
+	for i := range embeddings {
+		embeddings[i] = map[string]interface{}{"values": []float64{}}
+	}
+	for i, item := range openaiResp.Data {
+		index := item.Index
+		if index < 0 || index >= len(embeddings) {
+			index = i
+		}
+		if index < len(embeddings) {
+			embeddings[index] = map[string]interface{}{"values": item.Embedding}
+		}
+	}
+
+	var result interface{}
+	if action == "batchEmbedContents" {
+		result = map[string]interface{}{"embeddings": embeddings}
+	} else {
+		result = map[string]interface{}{"embedding": embeddings[0]}
+	}
+	respBody, _ := json.Marshal(result)
+	return respBody, http.StatusOK, nil
+}
+
+// geminiContentText 拼接 Gemini content 中所有 text part
+func geminiContentText(raw json.RawMessage) string {
+	var content struct {
+		Parts []struct {
+			Text string `json:"text"`
+		} `json:"parts"`
+	}
+	json.Unmarshal(raw, &content)
+	texts := make([]string, 0, len(content.Parts))
+	for _, part := range content.Parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/database"
+)
+
+// geminiResponseBlocked 上游 Gemini 响应被安全过滤拦截且没有输出内容时返回拦截原因，其他情况返回 nil
+func geminiResponseBlocked(adapterName string, statusCode int, responseBody []byte) *adapters.GeminiBlockedError {
+	if statusCode != http.StatusOK || (adapterName != "gemini" && adapterName != "openai-to-gemini") {
+		return nil
+	}
+	var respData map[string]interface{}
+	if err := json.Unmarshal(responseBody, &respData); err != nil {
+		return nil
+	}
+	return adapters.GeminiBlocked(respData)
+}
+
+// logGeminiBlocked 记录被 Gemini 安全过滤拦截的请求，返回 OpenAI 格式的 content_filter 错误响应（状态码 400）
+func (s *ProxyService) logGeminiBlocked(requestID, model string, route *database.ModelRoute, blocked *adapters.GeminiBlockedError, startTime time.Time) []byte {
+	RequestLogger(requestID).Warnf("Route %s: %v", route.Name, blocked)
+	s.routeService.LogRequestFull(RequestLogParams{
+		RequestID:     requestID,
+		Model:         model,
+		ProviderModel: route.Model,
+		ProviderName:  route.Name,
+		RouteID:       route.ID,
+		RequestTokens: blocked.PromptTokens,
+		TotalTokens:   blocked.PromptTokens,
+		Success:       false,
+		ErrorMessage:  blocked.Error(),
+		ErrorType:     ErrorTypeBlocked,
+		Style:         "openai",
+		ProxyTimeMs:   time.Since(startTime).Milliseconds(),
+		IsStream:      false,
+	})
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message":    blocked.Error(),
+			"type":       "content_filter",
+			"code":       blocked.Reason,
+			"request_id": requestID,
+		},
+	})
+	return body
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GenProfileHeader 客户端选择生成参数预设的请求头
+const GenProfileHeader = "X-Gen-Profile"
+
+// geminiGenerationKeys OpenAI 风格参数名到 Gemini generationConfig 字段的映射
+var geminiGenerationKeys = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "topP",
+	"top_k":             "topK",
+	"max_tokens":        "maxOutputTokens",
+	"presence_penalty":  "presencePenalty",
+	"frequency_penalty": "frequencyPenalty",
+	"stop":              "stopSequences",
+	"seed":              "seed",
+}
+
+// claudeGenerationKeys OpenAI 风格参数名到 Claude 字段的映射，映射为空的参数 Claude 不支持，不写入请求
+var claudeGenerationKeys = map[string]string{
+	"temperature":           "temperature",
+	"top_p":                 "top_p",
+	"top_k":                 "top_k",
+	"max_tokens":            "max_tokens",
+	"max_completion_tokens": "max_tokens",
+	"stop":                  "stop_sequences",
+	"presence_penalty":      "",
+	"frequency_penalty":     "",
+	"seed":                  "",
+	"logit_bias":            "",
+	"n":                     "",
+}
+
+// stopSequences Claude 和 Gemini 的停止序列必须是数组，OpenAI 的 stop 可以是单个字符串
+func stopSequences(value interface{}) interface{} {
+	if s, ok := value.(string); ok {
+		return []interface{}{s}
+	}
+	return value
+}
+
+// ApplyGenProfile 将指定预设的参数合并到请求体中（预设参数覆盖客户端传入的同名参数）
+// format 为入站请求格式: openai / claude / gemini
+// Gemini 格式的参数写入 generationConfig，并转换为驼峰字段名；Claude 格式转换字段名并忽略不支持的参数
+func ApplyGenProfile(body []byte, profiles map[string]map[string]interface{}, name, format string) ([]byte, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return body, nil
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown generation profile: %s", name)
+	}
+	if len(profile) == 0 {
+		return body, nil
+	}
+
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		return nil, fmt.Errorf("failed to parse request body: %v", err)
+	}
+
+	if format == "gemini" {
+		genConfig, _ := reqData["generationConfig"].(map[string]interface{})
+		if genConfig == nil {
+			genConfig = make(map[string]interface{})
+		}
+		for key, value := range profile {
+			if mapped, ok := geminiGenerationKeys[key]; ok {
+				key = mapped
+			}
+			if key == "stopSequences" {
+				value = stopSequences(value)
+			}
+			genConfig[key] = value
+		}
+		reqData["generationConfig"] = genConfig
+	} else if format == "claude" {
+		for key, value := range profile {
+			if mapped, ok := claudeGenerationKeys[key]; ok {
+				if mapped == "" {
+					continue
+				}
+				key = mapped
+			}
+			if key == "stop_sequences" {
+				value = stopSequences(value)
+			}
+			reqData[key] = value
+		}
+	} else {
+		for key, value := range profile {
+			reqData[key] = value
+		}
+	}
+
+	return json.Marshal(reqData)
+}
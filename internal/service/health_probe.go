@@ -0,0 +1,476 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 探测方式
+const (
+	HealthProbeModels     = "models"     // GET /models（不消耗 token）
+	HealthProbeCompletion = "completion" // 发送 max_tokens=1 的对话请求，同时验证模型可用
+)
+
+const (
+	healthProbeStartupDelay   = 30 * time.Second // 启动后首次探测的延迟
+	healthProbeCheckInterval  = 10 * time.Second // 检查是否到达探测时间的间隔
+	healthProbeTimeout        = 20 * time.Second // 单次探测超时
+	healthProbeConcurrency    = 4                // 同时探测的路由数
+	healthProbeRetentionDays  = 7                // 探测记录保留天数
+	healthProbeFailThreshold  = 2                // 连续失败次数达到该值时视为不健康
+	healthProbeErrorBodyLimit = 512              // 记录的错误响应最大长度
+)
+
+// RouteHealthCheck 单次探测结果
+type RouteHealthCheck struct {
+	RouteID      int64     `json:"route_id"`
+	Mode         string    `json:"mode"`
+	Success      bool      `json:"success"`
+	StatusCode   int       `json:"status_code"`
+	LatencyMs    int64     `json:"latency_ms"`
+	ErrorMessage string    `json:"error_message"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// HealthProbeStatus 最近一次探测的汇总
+type HealthProbeStatus struct {
+	Running   bool   `json:"running"`
+	LastRunAt string `json:"last_run_at"`
+	Probed    int    `json:"probed"`
+	Failed    int    `json:"failed"`
+}
+
+// routeProbeState 路由最近的探测状态（内存），用于健康感知路由
+type routeProbeState struct {
+	consecutiveFailures int
+	checkedAt           time.Time
+}
+
+// HealthProber 定期向每个启用的路由发送探测请求，记录延迟和状态
+// 配置在每次检查时重新读取，设置页修改后无需重启
+type HealthProber struct {
+	rs     *RouteService
+	ps     *ProxyService
+	config *config.Config
+	stop   chan struct{}
+
+	mu        sync.Mutex
+	running   bool
+	startedAt time.Time
+	lastRun   time.Time
+	status    HealthProbeStatus
+}
+
+func NewHealthProber(rs *RouteService, ps *ProxyService, cfg *config.Config) *HealthProber {
+	return &HealthProber{rs: rs, ps: ps, config: cfg, stop: make(chan struct{})}
+}
+
+// Start 启动后台探测
+func (h *HealthProber) Start() {
+	h.rs.SetHealthAwareRouting(h.config.HealthAwareRouting)
+	h.mu.Lock()
+	h.startedAt = time.Now()
+	h.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(healthProbeCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+				h.rs.SetHealthAwareRouting(h.config.HealthAwareRouting)
+				if h.due() {
+					h.run()
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止后台探测
+func (h *HealthProber) Stop() {
+	close(h.stop)
+}
+
+// RunNow 立即探测所有启用的路由
+func (h *HealthProber) RunNow() HealthProbeStatus {
+	h.run()
+	return h.Status()
+}
+
+// Status 获取最近一次探测的汇总
+func (h *HealthProber) Status() HealthProbeStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := h.status
+	status.Running = h.running
+	return status
+}
+
+// due 判断是否到达探测时间
+func (h *HealthProber) due() bool {
+	if !h.config.HealthProbeEnabled {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.running {
+		return false
+	}
+	if h.lastRun.IsZero() {
+		return time.Since(h.startedAt) >= healthProbeStartupDelay
+	}
+	return time.Since(h.lastRun) >= h.interval()
+}
+
+// interval 探测间隔，最少 30 秒
+func (h *HealthProber) interval() time.Duration {
+	seconds := h.config.HealthProbeIntervalSeconds
+	if seconds < 30 {
+		seconds = 30
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// run 并发探测所有启用的路由并记录结果
+func (h *HealthProber) run() {
+	h.mu.Lock()
+	if h.running {
+		h.mu.Unlock()
+		return
+	}
+	h.running = true
+	h.mu.Unlock()
+
+	status := HealthProbeStatus{}
+	defer func() {
+		h.mu.Lock()
+		h.running = false
+		h.lastRun = time.Now()
+		status.LastRunAt = h.lastRun.Format("2006-01-02 15:04:05")
+		h.status = status
+		h.mu.Unlock()
+	}()
+
+	routes, err := h.rs.GetAllRoutes()
+	if err != nil {
+		log.Warnf("Health probe: failed to load routes: %v", err)
+		return
+	}
+
+	mode := h.config.HealthProbeMode
+	var wg sync.WaitGroup
+	var resultMu sync.Mutex
+	sem := make(chan struct{}, healthProbeConcurrency)
+	for i := range routes {
+		route := routes[i]
+		if !route.Enabled {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			check := h.ps.ProbeRoute(&route, mode)
+			if err := h.rs.SaveHealthCheck(check); err != nil {
+				log.Warnf("Health probe: failed to save result for route %s: %v", route.Name, err)
+			}
+			if !check.Success {
+				log.Warnf("Health probe: route %s failed (%s): %s", route.Name, check.Mode, check.ErrorMessage)
+			}
+
+			resultMu.Lock()
+			status.Probed++
+			if !check.Success {
+				status.Failed++
+			}
+			resultMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if _, err := h.rs.DeleteHealthChecksBefore(time.Now().AddDate(0, 0, -healthProbeRetentionDays)); err != nil {
+		log.Warnf("Health probe: failed to delete old results: %v", err)
+	}
+	log.Infof("Health probe finished: %d routes probed, %d failed", status.Probed, status.Failed)
+}
+
+// ProbeRoute 向路由发送一次探测请求（不经过重试、限流和 Fallback）
+func (s *ProxyService) ProbeRoute(route *database.ModelRoute, mode string) RouteHealthCheck {
+	if mode != HealthProbeCompletion {
+		mode = HealthProbeModels
+	}
+	// 多 Key 路由轮询选择一个 Key 探测
+	probe := *route
+	s.routeService.pickRouteKey(&probe)
+	route = &probe
+	check := RouteHealthCheck{RouteID: route.ID, Mode: mode, CheckedAt: time.Now()}
+
+	var req *http.Request
+	var err error
+	if mode == HealthProbeCompletion {
+		req, err = s.buildCompletionProbe(route)
+	} else {
+		req, err = s.buildModelsProbe(route)
+	}
+	if err != nil {
+		check.ErrorMessage = err.Error()
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		check.LatencyMs = time.Since(start).Milliseconds()
+		check.ErrorMessage = err.Error()
+		return check
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	check.LatencyMs = time.Since(start).Milliseconds()
+	check.StatusCode = resp.StatusCode
+	check.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !check.Success {
+		if len(body) > healthProbeErrorBodyLimit {
+			body = body[:healthProbeErrorBodyLimit]
+		}
+		check.ErrorMessage = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return check
+}
+
+// probeTargetFormat 路由的目标格式：claude、gemini 或 openai（包括 OpenAI 兼容的供应商）
+func probeTargetFormat(route *database.ModelRoute) string {
+	if strings.TrimSpace(route.Format) == "" {
+		return inferFormatFromRoute(route.APIUrl, route.Model)
+	}
+	return normalizeFormat(route.Format)
+}
+
+// setProbeAuth 按目标格式设置认证头，并应用路由的自定义请求头和查询参数
+func setProbeAuth(req *http.Request, route *database.ModelRoute) {
+	switch probeTargetFormat(route) {
+	case "claude":
+		if route.APIKey != "" {
+			req.Header.Set("x-api-key", route.APIKey)
+		}
+		req.Header.Set("anthropic-version", DefaultAnthropicVersion)
+	case "gemini":
+		if route.APIKey != "" {
+			req.Header.Set("x-goog-api-key", route.APIKey)
+		}
+	default:
+		setUpstreamAuth(req, route.APIUrl, route.APIKey, nil)
+	}
+	applyRouteExtras(req, route.ExtraHeaders, route.ExtraQuery)
+}
+
+// buildModelsProbe 构建模型列表探测请求
+func (s *ProxyService) buildModelsProbe(route *database.ModelRoute) (*http.Request, error) {
+	apiURL := strings.TrimSpace(route.APIUrl)
+	if !strings.Contains(apiURL, "://") {
+		apiURL = "https://" + apiURL
+	}
+
+	var targetURL string
+	switch {
+	case isOllamaFormat(route.Format):
+		targetURL = ollamaBaseURL(apiURL) + "/api/tags"
+	case providerKind(route.APIUrl, route.Format) == "cohere":
+		targetURL = cohereBaseURL(apiURL) + "/v1/models"
+	case probeTargetFormat(route) == "gemini":
+		base := strings.TrimSuffix(apiURL, "/")
+		base = strings.TrimSuffix(strings.TrimSuffix(base, "/v1beta"), "/v1")
+		targetURL = base + "/v1beta/models"
+	default:
+		targetURL = buildOpenAIEndpointURL(apiURL, "models")
+	}
+
+	req, err := http.NewRequest("GET", targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	setProbeAuth(req, route)
+	return req, nil
+}
+
+// buildCompletionProbe 构建 max_tokens=1 的对话探测请求，按路由格式转换
+func (s *ProxyService) buildCompletionProbe(route *database.ModelRoute) (*http.Request, error) {
+	reqData := map[string]interface{}{
+		"model": route.Model,
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": "ping"},
+		},
+		"max_tokens": 1,
+		"stream":     false,
+	}
+
+	var body []byte
+	var targetURL string
+	adapterName := s.detectAdapterForRoute(route, "openai")
+	if adapterName != "" {
+		adapter := adapters.GetAdapter(adapterName)
+		if adapter == nil {
+			return nil, fmt.Errorf("adapter not found: %s", adapterName)
+		}
+		transformed, err := adapter.AdaptRequest(reqData, route.Model)
+		if err != nil {
+			return nil, err
+		}
+		body, _ = json.Marshal(transformed)
+		targetURL = s.buildAdapterURL(strings.TrimSuffix(route.APIUrl, "/"), adapterName, route.Model)
+	} else {
+		body, _ = json.Marshal(reqData)
+		targetURL = buildRouteChatURL(route.APIUrl, route.Format)
+	}
+	return s.newRouteRequest(route, targetURL, body, "", func(req *http.Request) {
+		setProbeAuth(req, route)
+	})
+}
+
+// SaveHealthCheck 保存探测结果并更新内存中的路由健康状态
+func (s *RouteService) SaveHealthCheck(check RouteHealthCheck) error {
+	s.probeMu.Lock()
+	if s.probeStates == nil {
+		s.probeStates = make(map[int64]*routeProbeState)
+	}
+	state := s.probeStates[check.RouteID]
+	if state == nil {
+		state = &routeProbeState{}
+		s.probeStates[check.RouteID] = state
+	}
+	wasUnhealthy := state.consecutiveFailures >= healthProbeFailThreshold
+	if check.Success {
+		state.consecutiveFailures = 0
+	} else {
+		state.consecutiveFailures++
+	}
+	state.checkedAt = check.CheckedAt
+	failures := state.consecutiveFailures
+	s.probeMu.Unlock()
+
+	if s.notifier != nil {
+		if !wasUnhealthy && failures == healthProbeFailThreshold {
+			s.notifyRouteHealth(check, NotifyRouteUnhealthy, failures)
+		} else if wasUnhealthy && check.Success {
+			s.notifyRouteHealth(check, NotifyRouteRecovered, failures)
+		}
+	}
+
+	success := 0
+	if check.Success {
+		success = 1
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO route_health_checks (route_id, mode, success, status_code, latency_ms, error_message, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		check.RouteID, check.Mode, success, check.StatusCode, check.LatencyMs, check.ErrorMessage, check.CheckedAt)
+	return err
+}
+
+// notifyRouteHealth 路由变为不健康或恢复时通知
+func (s *RouteService) notifyRouteHealth(check RouteHealthCheck, event string, failures int) {
+	name := fmt.Sprintf("#%d", check.RouteID)
+	fields := map[string]interface{}{"route_id": check.RouteID, "probe_mode": check.Mode}
+	if route, err := s.GetRouteByID(check.RouteID); err == nil && route != nil {
+		name = route.Name
+		fields["model"] = route.Model
+	}
+	fields["route"] = name
+	key := fmt.Sprint(check.RouteID)
+	if event == NotifyRouteRecovered {
+		s.notifier.Notify(event, key, "info", "Route recovered: "+name,
+			fmt.Sprintf("Route %s passed the health probe again (%dms)", name, check.LatencyMs), fields)
+		return
+	}
+	fields["status_code"] = check.StatusCode
+	s.notifier.Notify(event, key, "error", "Route unhealthy: "+name,
+		fmt.Sprintf("Route %s failed %d consecutive health probes: %s", name, failures, check.ErrorMessage), fields)
+}
+
+// GetRecentHealthChecks 获取路由最近的探测结果（按时间正序）
+func (s *RouteService) GetRecentHealthChecks(routeID int64, limit int) ([]RouteHealthCheck, error) {
+	rows, err := s.db.Query(`
+		SELECT route_id, mode, success, status_code, latency_ms, COALESCE(error_message, ''), checked_at
+		FROM route_health_checks WHERE route_id = ? ORDER BY id DESC LIMIT ?`, routeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []RouteHealthCheck
+	for rows.Next() {
+		var c RouteHealthCheck
+		var success int
+		if err := rows.Scan(&c.RouteID, &c.Mode, &success, &c.StatusCode, &c.LatencyMs, &c.ErrorMessage, &c.CheckedAt); err != nil {
+			return nil, err
+		}
+		c.Success = success == 1
+		checks = append([]RouteHealthCheck{c}, checks...)
+	}
+	return checks, rows.Err()
+}
+
+// DeleteHealthChecksBefore 删除指定时间之前的探测记录
+func (s *RouteService) DeleteHealthChecksBefore(before time.Time) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM route_health_checks WHERE checked_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SetHealthAwareRouting 开启后 Fallback 路由列表中连续探测失败的路由排到最后
+func (s *RouteService) SetHealthAwareRouting(enabled bool) {
+	s.healthAware.Store(enabled)
+}
+
+// isRouteUnhealthy 路由最近连续探测失败，且探测结果未过期
+func (s *RouteService) isRouteUnhealthy(routeID int64) bool {
+	s.probeMu.RLock()
+	defer s.probeMu.RUnlock()
+	state := s.probeStates[routeID]
+	if state == nil {
+		return false
+	}
+	return state.consecutiveFailures >= healthProbeFailThreshold && time.Since(state.checkedAt) < time.Hour
+}
+
+// orderRoutesByHealth 将不健康的路由移到最后（仍保留用于 Fallback），其余路由保持原有顺序
+func (s *RouteService) orderRoutesByHealth(routes []database.ModelRoute) []database.ModelRoute {
+	if !s.healthAware.Load() || len(routes) < 2 {
+		return routes
+	}
+	healthy := make([]database.ModelRoute, 0, len(routes))
+	var unhealthy []database.ModelRoute
+	for _, route := range routes {
+		if s.isRouteUnhealthy(route.ID) {
+			unhealthy = append(unhealthy, route)
+		} else {
+			healthy = append(healthy, route)
+		}
+	}
+	if len(unhealthy) > 0 && len(healthy) > 0 {
+		log.Infof("[Health] %d unhealthy route(s) moved to the end of the fallback list", len(unhealthy))
+	}
+	return append(healthy, unhealthy...)
+}
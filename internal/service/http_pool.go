@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"openai-router-go/internal/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 上游连接池：按上游地址（scheme://host）共享调优过的 Transport，指向同一供应商的多个路由复用连接；
+// 连接池参数在每次请求时从配置读取，修改后旧连接空闲时关闭，新请求使用新参数建立的 Transport
+
+const (
+	upstreamTLSHandshakeTimeout = 10 * time.Second
+	upstreamH2SendPingTimeout   = 30 * time.Second // HTTP/2 连接空闲多久后发送 PING 检查连接
+	upstreamH2PingTimeout       = 15 * time.Second // PING 无响应时关闭连接
+)
+
+// poolSettings 连接池参数
+type poolSettings struct {
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 表示不限制
+	IdleConnTimeout     time.Duration
+	DialTimeout         time.Duration
+	ForceHTTP2          bool
+	DNSCacheTTL         time.Duration // 0 表示不缓存
+}
+
+func readPoolSettings(cfg *config.Config) poolSettings {
+	settings := poolSettings{
+		MaxIdleConnsPerHost: cfg.UpstreamMaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.UpstreamMaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(cfg.UpstreamIdleConnTimeoutSeconds) * time.Second,
+		DialTimeout:         time.Duration(cfg.UpstreamDialTimeoutSeconds) * time.Second,
+		ForceHTTP2:          cfg.UpstreamForceHTTP2,
+		DNSCacheTTL:         time.Duration(cfg.UpstreamDNSCacheSeconds) * time.Second,
+	}
+	if settings.MaxIdleConnsPerHost <= 0 {
+		settings.MaxIdleConnsPerHost = http.DefaultMaxIdleConnsPerHost
+	}
+	if settings.MaxConnsPerHost < 0 {
+		settings.MaxConnsPerHost = 0
+	}
+	if settings.IdleConnTimeout <= 0 {
+		settings.IdleConnTimeout = 90 * time.Second
+	}
+	if settings.DialTimeout <= 0 {
+		settings.DialTimeout = 30 * time.Second
+	}
+	if settings.DNSCacheTTL < 0 {
+		settings.DNSCacheTTL = 0
+	}
+	return settings
+}
+
+// hostPool 一个上游地址的 Transport 和连接统计
+type hostPool struct {
+	key       string
+	transport *http.Transport
+
+	requests    atomic.Int64
+	inFlight    atomic.Int64 // 未结束的请求（流式响应在读取完成/关闭前都算）
+	openConns   atomic.Int64
+	newConns    atomic.Int64
+	reusedConns atomic.Int64
+	dialErrors  atomic.Int64
+	http2       atomic.Int64 // 通过 HTTP/2 完成的请求
+}
+
+// upstreamPool 实现 http.RoundTripper，作为 ProxyService 所有上游请求的 Transport
+type upstreamPool struct {
+	config       *config.Config
+	proxyEnabled atomic.Bool
+	dns          *dnsCache
+
+	mu       sync.Mutex
+	settings poolSettings
+	hosts    map[string]*hostPool
+}
+
+func newUpstreamPool(cfg *config.Config) *upstreamPool {
+	p := &upstreamPool{
+		config:   cfg,
+		dns:      newDNSCache(),
+		settings: readPoolSettings(cfg),
+		hosts:    make(map[string]*hostPool),
+	}
+	p.proxyEnabled.Store(cfg.ProxyEnabled)
+	return p
+}
+
+// setProxyEnabled 切换是否使用系统代理，关闭已有的空闲连接
+func (p *upstreamPool) setProxyEnabled(enabled bool) {
+	p.proxyEnabled.Store(enabled)
+	p.closeIdle()
+}
+
+func (p *upstreamPool) closeIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, hp := range p.hosts {
+		hp.transport.CloseIdleConnections()
+	}
+}
+
+func (p *upstreamPool) proxy(req *http.Request) (*url.URL, error) {
+	if p.proxyEnabled.Load() {
+		return http.ProxyFromEnvironment(req)
+	}
+	return nil, nil
+}
+
+// newTransport 按参数创建一个上游地址使用的 Transport
+func (p *upstreamPool) newTransport(hp *hostPool, settings poolSettings) *http.Transport {
+	dialer := &net.Dialer{Timeout: settings.DialTimeout, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy: p.proxy,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := p.dns.dial(ctx, dialer, network, addr, settings.DNSCacheTTL)
+			if err != nil {
+				hp.dialErrors.Add(1)
+				return nil, err
+			}
+			hp.openConns.Add(1)
+			return &countedConn{Conn: conn, open: &hp.openConns}, nil
+		},
+		ForceAttemptHTTP2:     settings.ForceHTTP2,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       settings.MaxConnsPerHost,
+		IdleConnTimeout:       settings.IdleConnTimeout,
+		TLSHandshakeTimeout:   upstreamTLSHandshakeTimeout,
+		ExpectContinueTimeout: time.Second,
+		HTTP2: &http.HTTP2Config{
+			SendPingTimeout: upstreamH2SendPingTimeout,
+			PingTimeout:     upstreamH2PingTimeout,
+		},
+	}
+}
+
+// host 返回上游地址的连接池，参数变化时为所有地址重建 Transport
+func (p *upstreamPool) host(u *url.URL) *hostPool {
+	key := u.Scheme + "://" + u.Host
+	settings := readPoolSettings(p.config)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if settings != p.settings {
+		log.Infof("Upstream connection pool settings changed, rebuilding %d transports", len(p.hosts))
+		p.settings = settings
+		for k, old := range p.hosts {
+			old.transport.CloseIdleConnections()
+			hp := &hostPool{key: k}
+			hp.transport = p.newTransport(hp, settings)
+			// 保留累计统计，旧 Transport 上未结束的连接关闭时仍计入旧对象
+			hp.requests.Store(old.requests.Load())
+			hp.newConns.Store(old.newConns.Load())
+			hp.reusedConns.Store(old.reusedConns.Load())
+			hp.dialErrors.Store(old.dialErrors.Load())
+			hp.http2.Store(old.http2.Load())
+			p.hosts[k] = hp
+		}
+	}
+	hp, ok := p.hosts[key]
+	if !ok {
+		hp = &hostPool{key: key}
+		hp.transport = p.newTransport(hp, settings)
+		p.hosts[key] = hp
+	}
+	return hp
+}
+
+// RoundTrip 通过上游地址对应的 Transport 发送请求，并记录连接复用情况
+func (p *upstreamPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	hp := p.host(req.URL)
+	hp.requests.Add(1)
+	hp.inFlight.Add(1)
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				hp.reusedConns.Add(1)
+			} else {
+				hp.newConns.Add(1)
+			}
+		},
+	}
+	resp, err := hp.transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		hp.inFlight.Add(-1)
+		return nil, err
+	}
+	if resp.ProtoMajor == 2 {
+		hp.http2.Add(1)
+	}
+	// 101 的响应体是可写的连接（WebSocket），不能包装
+	if resp.StatusCode == http.StatusSwitchingProtocols || resp.Body == nil {
+		hp.inFlight.Add(-1)
+		return resp, nil
+	}
+	resp.Body = &inFlightBody{ReadCloser: resp.Body, inFlight: &hp.inFlight}
+	return resp, nil
+}
+
+// CloseIdleConnections 关闭所有上游地址的空闲连接（http.Client.CloseIdleConnections 会调用）
+func (p *upstreamPool) CloseIdleConnections() {
+	p.closeIdle()
+}
+
+// inFlightBody 响应体关闭时结束请求计数
+type inFlightBody struct {
+	io.ReadCloser
+	inFlight *atomic.Int64
+	once     sync.Once
+}
+
+func (b *inFlightBody) Close() error {
+	b.once.Do(func() { b.inFlight.Add(-1) })
+	return b.ReadCloser.Close()
+}
+
+// countedConn 关闭时减少打开的连接数
+type countedConn struct {
+	net.Conn
+	open *atomic.Int64
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+// dnsCache 缓存上游域名解析结果，减少每个新连接的 DNS 查询
+type dnsCache struct {
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache() *dnsCache {
+	return &dnsCache{entries: make(map[string]dnsEntry)}
+}
+
+func (d *dnsCache) lookup(ctx context.Context, host string, ttl time.Duration) ([]string, error) {
+	d.mu.Lock()
+	entry, ok := d.entries[host]
+	d.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		d.hits.Add(1)
+		return entry.addrs, nil
+	}
+	d.misses.Add(1)
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(ttl)}
+	d.mu.Unlock()
+	return addrs, nil
+}
+
+func (d *dnsCache) forget(host string) {
+	d.mu.Lock()
+	delete(d.entries, host)
+	d.mu.Unlock()
+}
+
+// dial 使用缓存的解析结果依次尝试各个地址；全部失败时清除缓存，下次重新解析
+func (d *dnsCache) dial(ctx context.Context, dialer *net.Dialer, network, addr string, ttl time.Duration) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || ttl <= 0 || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	addrs, err := d.lookup(ctx, host, ttl)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ip := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	d.forget(host)
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses for %s", host)
+	}
+	return nil, lastErr
+}
+
+// HostPoolStats 一个上游地址的连接统计
+type HostPoolStats struct {
+	Host           string `json:"host"`
+	Requests       int64  `json:"requests"`
+	InFlight       int64  `json:"in_flight"`
+	OpenConns      int64  `json:"open_conns"`
+	NewConns       int64  `json:"new_conns"`
+	ReusedConns    int64  `json:"reused_conns"`
+	DialErrors     int64  `json:"dial_errors"`
+	HTTP2Responses int64  `json:"http2_responses"`
+}
+
+// ConnectionPoolStats 上游连接池统计
+type ConnectionPoolStats struct {
+	MaxIdleConnsPerHost    int             `json:"max_idle_conns_per_host"`
+	MaxConnsPerHost        int             `json:"max_conns_per_host"`
+	IdleConnTimeoutSeconds int             `json:"idle_conn_timeout_seconds"`
+	ForceHTTP2             bool            `json:"force_http2"`
+	DNSCacheSeconds        int             `json:"dns_cache_seconds"`
+	DNSCacheHits           int64           `json:"dns_cache_hits"`
+	DNSCacheMisses         int64           `json:"dns_cache_misses"`
+	Hosts                  []HostPoolStats `json:"hosts"`
+}
+
+func (p *upstreamPool) stats() ConnectionPoolStats {
+	p.mu.Lock()
+	settings := p.settings
+	hosts := make([]HostPoolStats, 0, len(p.hosts))
+	for _, hp := range p.hosts {
+		hosts = append(hosts, HostPoolStats{
+			Host:           hp.key,
+			Requests:       hp.requests.Load(),
+			InFlight:       hp.inFlight.Load(),
+			OpenConns:      hp.openConns.Load(),
+			NewConns:       hp.newConns.Load(),
+			ReusedConns:    hp.reusedConns.Load(),
+			DialErrors:     hp.dialErrors.Load(),
+			HTTP2Responses: hp.http2.Load(),
+		})
+	}
+	p.mu.Unlock()
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+
+	return ConnectionPoolStats{
+		MaxIdleConnsPerHost:    settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:        settings.MaxConnsPerHost,
+		IdleConnTimeoutSeconds: int(settings.IdleConnTimeout / time.Second),
+		ForceHTTP2:             settings.ForceHTTP2,
+		DNSCacheSeconds:        int(settings.DNSCacheTTL / time.Second),
+		DNSCacheHits:           p.dns.hits.Load(),
+		DNSCacheMisses:         p.dns.misses.Load(),
+		Hosts:                  hosts,
+	}
+}
+
+// ConnectionStats 获取上游连接池统计
+func (s *ProxyService) ConnectionStats() ConnectionPoolStats {
+	return s.pool.stats()
+}
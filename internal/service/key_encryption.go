@@ -0,0 +1,117 @@
+package service
+
+import (
+	"fmt"
+
+	"openai-router-go/internal/secret"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EnableKeyEncryption 启用 API Key 加密存储，并将已有的明文 Key 迁移为密文
+func (s *RouteService) EnableKeyEncryption(box *secret.Box) error {
+	s.keys = box
+	migrated, err := s.rewriteRouteKeys(box.Encrypt, func(v string) bool { return !secret.IsEncrypted(v) })
+	if err != nil {
+		return err
+	}
+	if migrated > 0 {
+		log.Infof("Encrypted %d plaintext API key(s) at rest (protection: %s)", migrated, box.Protection())
+	}
+	return nil
+}
+
+// DisableKeyEncryption 关闭加密存储，将密文 Key 还原为明文
+func (s *RouteService) DisableKeyEncryption(box *secret.Box) error {
+	restored, err := s.rewriteRouteKeys(box.Decrypt, secret.IsEncrypted)
+	if err != nil {
+		return err
+	}
+	s.keys = nil
+	if restored > 0 {
+		log.Infof("Decrypted %d API key(s) back to plaintext", restored)
+	}
+	return nil
+}
+
+// rewriteRouteKeys 在事务中转换 model_routes.api_key
+func (s *RouteService) rewriteRouteKeys(convert func(string) (string, error), match func(string) bool) (int, error) {
+	rows, err := s.db.Query(`SELECT id, COALESCE(api_key, '') FROM model_routes`)
+	if err != nil {
+		return 0, err
+	}
+	updates := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if key == "" || !match(key) {
+			continue
+		}
+		converted, err := convert(key)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("route %d: %v", id, err)
+		}
+		updates[id] = converted
+	}
+	rows.Close()
+
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	for id, key := range updates {
+		if _, err := tx.Exec(`UPDATE model_routes SET api_key = ? WHERE id = ?`, key, id); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return len(updates), tx.Commit()
+}
+
+// sealKey 写入数据库前加密 API Key
+func (s *RouteService) sealKey(apiKey string) (string, error) {
+	if s.keys == nil {
+		return apiKey, nil
+	}
+	return s.keys.Encrypt(apiKey)
+}
+
+// openKey 从数据库读取后解密 API Key，解密失败时返回空值
+func (s *RouteService) openKey(stored string) string {
+	if !secret.IsEncrypted(stored) {
+		return stored
+	}
+	if s.keys == nil {
+		log.Errorf("API key is encrypted but no data key is loaded")
+		return ""
+	}
+	apiKey, err := s.keys.Decrypt(stored)
+	if err != nil {
+		log.Errorf("Failed to decrypt API key: %v", err)
+		return ""
+	}
+	return apiKey
+}
+
+// MaskAPIKey 脱敏显示 API Key
+func MaskAPIKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	// 多 Key 路由只显示第一个 Key 和数量
+	if keys := SplitAPIKeys(key); len(keys) > 1 {
+		return fmt.Sprintf("%s (+%d)", MaskAPIKey(keys[0]), len(keys)-1)
+	}
+	if len(key) <= 10 {
+		return "***"
+	}
+	return key[:5] + "***" + key[len(key)-5:]
+}
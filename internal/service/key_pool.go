@@ -0,0 +1,426 @@
+package service
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 多 Key 路由：api_key 字段中保存多个 Key（换行或逗号分隔），每个请求轮询使用下一个 Key
+// Key 返回 401/403/429 时标记为耗尽，冷却结束前跳过；同一请求内会换用其他 Key 重发
+
+const (
+	maxKeySwaps        = 3         // 同一请求内因 Key 耗尽切换 Key 的最大次数
+	keyRequestTTL      = time.Hour // 请求ID -> Key 映射的保留时间（用于日志按 Key 统计）
+	keyRequestMaxCount = 10000     // 映射数量超过该值时清理过期项
+)
+
+// SplitAPIKeys 拆分路由的 API Key 列表（换行或逗号分隔），去掉空白和重复项
+func SplitAPIKeys(raw string) []string {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ','
+	})
+	keys := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, key := range fields {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// firstAPIKey 返回第一个 Key（获取模型列表等不需要轮询的场景）
+func firstAPIKey(raw string) string {
+	if keys := SplitAPIKeys(raw); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// keyFingerprint Key 的指纹（SHA-256 前 8 字节），日志中只保存指纹不保存明文
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// isKeyExhaustedStatus Key 被限流或失效的状态码
+func isKeyExhaustedStatus(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || statusCode == http.StatusTooManyRequests
+}
+
+// upstreamRequestKey 从上游请求中取出使用的 Key（Authorization、x-api-key、x-goog-api-key 或 key 查询参数）
+func upstreamRequestKey(req *http.Request) string {
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if key := req.Header.Get("x-api-key"); key != "" {
+		return key
+	}
+	if key := req.Header.Get("x-goog-api-key"); key != "" {
+		return key
+	}
+	return req.URL.Query().Get("key")
+}
+
+// replaceRequestKey 将请求中的旧 Key 替换为新 Key，只替换与旧 Key 完全一致的位置
+func replaceRequestKey(req *http.Request, oldKey, newKey string) {
+	if req.Header.Get("Authorization") == "Bearer "+oldKey {
+		req.Header.Set("Authorization", "Bearer "+newKey)
+	}
+	for _, name := range []string{"x-api-key", "x-goog-api-key"} {
+		if req.Header.Get(name) == oldKey {
+			req.Header.Set(name, newKey)
+		}
+	}
+	if query := req.URL.Query(); query.Get("key") == oldKey {
+		query.Set("key", newKey)
+		req.URL.RawQuery = query.Encode()
+	}
+}
+
+// APIKeyStat 单个 Key 的使用情况（进程内统计，重启后清零）
+type APIKeyStat struct {
+	RouteID         int64  `json:"route_id"`
+	Hash            string `json:"hash"`
+	MaskedKey       string `json:"masked_key"`
+	Requests        int64  `json:"requests"`
+	Failures        int64  `json:"failures"`
+	LastStatus      int    `json:"last_status"`
+	LastError       string `json:"last_error"`
+	LastUsedAt      string `json:"last_used_at"`
+	CooldownSeconds int64  `json:"cooldown_seconds"` // 剩余冷却时间，0 表示可用
+}
+
+// keyState 单个 Key 的状态，按指纹保存，多个路由使用同一 Key 时共享冷却状态
+type keyState struct {
+	masked        string
+	requests      int64
+	failures      int64
+	lastStatus    int
+	lastError     string
+	lastUsed      time.Time
+	cooldownUntil time.Time
+}
+
+// requestKeyRef 请求最近一次使用的 Key
+type requestKeyRef struct {
+	hash string
+	at   time.Time
+}
+
+// keyPool 路由 Key 的轮询位置和使用状态
+type keyPool struct {
+	mu        sync.Mutex
+	cursors   map[int64]uint64         // 路由 -> 轮询位置
+	routeKeys map[int64][]string       // 路由 -> 最近一次选择时的 Key 列表
+	states    map[string]*keyState     // Key 指纹 -> 状态
+	requests  map[string]requestKeyRef // 请求ID -> 最近一次使用的 Key
+}
+
+func newKeyPool() *keyPool {
+	return &keyPool{
+		cursors:   make(map[int64]uint64),
+		routeKeys: make(map[int64][]string),
+		states:    make(map[string]*keyState),
+		requests:  make(map[string]requestKeyRef),
+	}
+}
+
+// state 获取 Key 的状态，不存在时创建（调用方持有锁）
+func (p *keyPool) state(key string) *keyState {
+	hash := keyFingerprint(key)
+	st := p.states[hash]
+	if st == nil {
+		st = &keyState{masked: MaskAPIKey(key)}
+		p.states[hash] = st
+	}
+	return st
+}
+
+// pick 轮询选择路由的下一个可用 Key；全部在冷却中时选择最早结束冷却的 Key
+func (p *keyPool) pick(routeID int64, keys []string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.routeKeys[routeID] = keys
+	states := make([]*keyState, len(keys))
+	for i, key := range keys {
+		states[i] = p.state(key)
+	}
+	start := p.cursors[routeID]
+	p.cursors[routeID] = start + 1
+
+	now := time.Now()
+	var fallback string
+	var earliest time.Time
+	for i := range keys {
+		idx := (start + uint64(i)) % uint64(len(keys))
+		key, st := keys[idx], states[idx]
+		if !st.cooldownUntil.After(now) {
+			return key
+		}
+		if fallback == "" || st.cooldownUntil.Before(earliest) {
+			fallback, earliest = key, st.cooldownUntil
+		}
+	}
+	return fallback
+}
+
+// alternate 为耗尽的 Key 找到同一路由中另一个可用且本次请求未尝试过的 Key
+func (p *keyPool) alternate(key string, tried map[string]bool) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for _, keys := range p.routeKeys {
+		if !containsString(keys, key) {
+			continue
+		}
+		for _, candidate := range keys {
+			if tried[candidate] {
+				continue
+			}
+			if st := p.states[keyFingerprint(candidate)]; st == nil || !st.cooldownUntil.After(now) {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// report 记录 Key 的一次请求结果，cooldown > 0 时标记为耗尽
+// 返回 false 表示该 Key 不属于任何路由（如透传的客户端 Key），不做统计
+func (p *keyPool) report(requestID, key string, statusCode int, err error, cooldown time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hash := keyFingerprint(key)
+	st := p.states[hash]
+	if st == nil {
+		return false
+	}
+	now := time.Now()
+	st.requests++
+	st.lastUsed = now
+	st.lastStatus = statusCode
+	switch {
+	case err != nil:
+		st.failures++
+		st.lastError = err.Error()
+	case statusCode >= 400:
+		st.failures++
+		st.lastError = fmt.Sprintf("HTTP %d %s", statusCode, http.StatusText(statusCode))
+	default:
+		st.cooldownUntil = time.Time{}
+	}
+	if cooldown > 0 {
+		st.cooldownUntil = now.Add(cooldown)
+		log.Warnf("[KeyPool] Key %s exhausted (status %d), cooling down for %v", st.masked, statusCode, cooldown)
+	}
+
+	if requestID != "" {
+		if len(p.requests) >= keyRequestMaxCount {
+			for id, ref := range p.requests {
+				if now.Sub(ref.at) > keyRequestTTL {
+					delete(p.requests, id)
+				}
+			}
+		}
+		if len(p.requests) < keyRequestMaxCount {
+			p.requests[requestID] = requestKeyRef{hash: hash, at: now}
+		}
+	}
+	return true
+}
+
+// keyForRequest 请求在指定路由上最近一次使用的 Key 指纹
+func (p *keyPool) keyForRequest(requestID string, routeID int64) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ref, ok := p.requests[requestID]
+	if !ok {
+		return ""
+	}
+	for _, key := range p.routeKeys[routeID] {
+		if keyFingerprint(key) == ref.hash {
+			return ref.hash
+		}
+	}
+	return ""
+}
+
+// stats 获取指定 Key 列表的使用情况
+func (p *keyPool) stats(routeID int64, keys []string) []APIKeyStat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	result := make([]APIKeyStat, 0, len(keys))
+	for _, key := range keys {
+		stat := APIKeyStat{RouteID: routeID, Hash: keyFingerprint(key), MaskedKey: MaskAPIKey(key)}
+		if st := p.states[stat.Hash]; st != nil {
+			stat.Requests = st.requests
+			stat.Failures = st.failures
+			stat.LastStatus = st.lastStatus
+			stat.LastError = st.lastError
+			if !st.lastUsed.IsZero() {
+				stat.LastUsedAt = st.lastUsed.Format("2006-01-02 15:04:05")
+			}
+			if remaining := st.cooldownUntil.Sub(now); remaining > 0 {
+				stat.CooldownSeconds = int64(remaining.Seconds()) + 1
+			}
+		}
+		result = append(result, stat)
+	}
+	return result
+}
+
+// containsString 判断字符串切片是否包含指定值
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// pickRouteKey 将路由的 Key 列表替换为本次请求使用的单个 Key
+func (s *RouteService) pickRouteKey(route *database.ModelRoute) {
+	keys := SplitAPIKeys(route.APIKey)
+	if len(keys) == 0 {
+		route.APIKey = ""
+		return
+	}
+	route.APIKey = s.keyPool.pick(route.ID, keys)
+}
+
+// GetRouteKeyStats 获取路由每个 Key 的使用情况
+func (s *RouteService) GetRouteKeyStats(routeID int64) ([]APIKeyStat, error) {
+	var stored sql.NullString
+	err := s.db.QueryRow(`SELECT api_key FROM model_routes WHERE id = ?`, routeID).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("route not found: %d", routeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.keyPool.stats(routeID, SplitAPIKeys(s.openKey(stored.String))), nil
+}
+
+// keyCooldown Key 返回 401/403/429 时的冷却时间，其他情况返回 0
+func (s *ProxyService) keyCooldown(statusCode int, resp *http.Response) time.Duration {
+	if s.config == nil || !isKeyExhaustedStatus(statusCode) {
+		return 0
+	}
+	if statusCode != http.StatusTooManyRequests {
+		minutes := s.config.KeyAuthCooldownMinutes
+		if minutes <= 0 {
+			minutes = 30
+		}
+		return time.Duration(minutes) * time.Minute
+	}
+	cooldown := time.Duration(s.config.KeyCooldownSeconds) * time.Second
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && retryAfter > cooldown {
+			cooldown = retryAfter
+		}
+	}
+	return cooldown
+}
+
+// KeyPoolEntry Key 池看板中的一行：路由中的单个 Key
+type KeyPoolEntry struct {
+	APIKeyStat
+	RouteName     string  `json:"route_name"`
+	RouteModel    string  `json:"route_model"`
+	RouteEnabled  bool    `json:"route_enabled"`
+	SuccessRate   float64 `json:"success_rate"` // 成功率(%)，优先使用本次运行的统计，没有时使用今日日志
+	TodayRequests int64   `json:"today_requests"`
+	TodayTokens   int64   `json:"today_tokens"`
+}
+
+// keyDailyUsage 今日按 Key 指纹汇总的请求日志
+type keyDailyUsage struct {
+	requests int64
+	success  int64
+	tokens   int64
+}
+
+// GetKeyPoolStatus 获取所有路由的 Key 状态：成功率、最近错误、剩余冷却时间、今日消耗的 Token
+func (s *RouteService) GetKeyPoolStatus() ([]KeyPoolEntry, error) {
+	routes, err := s.GetAllRoutes()
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.todayKeyUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []KeyPoolEntry
+	for _, route := range routes {
+		for _, stat := range s.keyPool.stats(route.ID, SplitAPIKeys(route.APIKey)) {
+			entry := KeyPoolEntry{
+				APIKeyStat:   stat,
+				RouteName:    route.Name,
+				RouteModel:   route.Model,
+				RouteEnabled: route.Enabled,
+			}
+			if today, ok := usage[stat.Hash]; ok {
+				entry.TodayRequests = today.requests
+				entry.TodayTokens = today.tokens
+			}
+			switch {
+			case stat.Requests > 0:
+				entry.SuccessRate = float64(stat.Requests-stat.Failures) * 100 / float64(stat.Requests)
+			case entry.TodayRequests > 0:
+				entry.SuccessRate = float64(usage[stat.Hash].success) * 100 / float64(entry.TodayRequests)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// todayKeyUsage 今日每个 Key 的请求数、成功数和 Token 消耗
+func (s *RouteService) todayKeyUsage() (map[string]keyDailyUsage, error) {
+	rows, err := s.db.Query(`
+		SELECT key_hash, COUNT(*), COALESCE(SUM(CASE WHEN success = 1 THEN 1 ELSE 0 END), 0), COALESCE(SUM(total_tokens), 0)
+		FROM request_logs
+		WHERE key_hash IS NOT NULL AND key_hash != '' AND substr(created_at, 1, 10) = date('now', 'localtime')
+		GROUP BY key_hash
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[string]keyDailyUsage)
+	for rows.Next() {
+		var hash string
+		var u keyDailyUsage
+		if err := rows.Scan(&hash, &u.requests, &u.success, &u.tokens); err != nil {
+			return nil, err
+		}
+		usage[hash] = u
+	}
+	return usage, rows.Err()
+}
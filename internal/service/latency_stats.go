@@ -0,0 +1,185 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 延迟分位数：按模型、按路由统计成功请求的总耗时（proxy_time_ms）和流式首字节时间（first_chunk_ms）的 P50/P95/P99，
+// 并按时间段给出整体分位数用于折线图。平均值会掩盖长尾延迟
+
+const (
+	latencyStatsMaxSamples = 100000 // 最多读取的最近请求数
+	latencyStatsPoints     = 24     // 折线图的时间段数（延迟和错误统计共用）
+	latencyStatsMinBucket  = 5      // 时间段最短分钟数
+)
+
+// LatencyPercentiles 一组耗时的分位数（毫秒）
+type LatencyPercentiles struct {
+	Samples int   `json:"samples"`
+	Avg     int64 `json:"avg"`
+	P50     int64 `json:"p50"`
+	P95     int64 `json:"p95"`
+	P99     int64 `json:"p99"`
+}
+
+// LatencyStatsItem 一个模型或路由的延迟分位数
+type LatencyStatsItem struct {
+	Key        string             `json:"key"`         // 模型名或路由ID
+	Label      string             `json:"label"`       // 显示名称，路由为路由名
+	Total      LatencyPercentiles `json:"total"`       // 总耗时
+	FirstChunk LatencyPercentiles `json:"first_chunk"` // 首字节时间（只有流式请求）
+}
+
+// LatencyPoint 一个时间段内所有请求的总耗时分位数
+type LatencyPoint struct {
+	Time string `json:"time"` // 时间段开始时间 (2006-01-02 15:04)
+	LatencyPercentiles
+}
+
+// LatencyStats 最近 hours 小时的延迟统计
+type LatencyStats struct {
+	Hours         int                `json:"hours"`
+	BucketMinutes int                `json:"bucket_minutes"`
+	Overall       LatencyStatsItem   `json:"overall"`
+	Models        []LatencyStatsItem `json:"models"`
+	Routes        []LatencyStatsItem `json:"routes"`
+	Series        []LatencyPoint     `json:"series"`
+}
+
+// latencySamples 一组请求的总耗时和首字节时间
+type latencySamples struct {
+	label      string
+	total      []int64
+	firstChunk []int64
+}
+
+func (l *latencySamples) add(totalMs, firstChunkMs int64) {
+	l.total = append(l.total, totalMs)
+	if firstChunkMs > 0 {
+		l.firstChunk = append(l.firstChunk, firstChunkMs)
+	}
+}
+
+func (l *latencySamples) item(key string) LatencyStatsItem {
+	return LatencyStatsItem{Key: key, Label: l.label, Total: latencyPercentiles(l.total), FirstChunk: latencyPercentiles(l.firstChunk)}
+}
+
+// latencyPercentiles 计算平均值和 P50/P95/P99
+func latencyPercentiles(values []int64) LatencyPercentiles {
+	if len(values) == 0 {
+		return LatencyPercentiles{}
+	}
+	sorted := make([]int64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum int64
+	for _, v := range sorted {
+		sum += v
+	}
+	at := func(p float64) int64 { return sorted[int(float64(len(sorted)-1)*p)] }
+	return LatencyPercentiles{
+		Samples: len(sorted),
+		Avg:     sum / int64(len(sorted)),
+		P50:     at(0.50),
+		P95:     at(0.95),
+		P99:     at(0.99),
+	}
+}
+
+// statsWindow 最近 hours 小时（<= 0 时为 24）的统计窗口：分成约 latencyStatsPoints 个时间段，返回时间段长度和按时间段对齐的开始时间
+func statsWindow(hours int) (int, int, time.Duration, time.Time) {
+	if hours <= 0 {
+		hours = 24
+	}
+	bucketMinutes := max(hours*60/latencyStatsPoints, latencyStatsMinBucket)
+	bucket := time.Duration(bucketMinutes) * time.Minute
+	return hours, bucketMinutes, bucket, time.Now().Add(-time.Duration(hours) * time.Hour).Truncate(bucket)
+}
+
+// GetLatencyStats 统计最近 hours 小时成功请求的延迟分位数（按模型、按路由和按时间段），按样本数降序
+func (s *RouteService) GetLatencyStats(hours int) (*LatencyStats, error) {
+	hours, bucketMinutes, bucket, since := statsWindow(hours)
+	now := time.Now()
+
+	rows, err := s.db.Query(`
+		SELECT model, COALESCE(route_id, 0), COALESCE(provider_name, ''), proxy_time_ms, COALESCE(first_chunk_ms, 0), substr(created_at, 1, 16)
+		FROM request_logs
+		WHERE success = 1 AND proxy_time_ms > 0 AND created_at >= ?
+		ORDER BY id DESC LIMIT ?`,
+		since.Format("2006-01-02 15:04:05"), latencyStatsMaxSamples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overall := &latencySamples{label: "all"}
+	models := make(map[string]*latencySamples)
+	routes := make(map[int64]*latencySamples)
+	buckets := make(map[int64][]int64)
+	for rows.Next() {
+		var model, provider, minute string
+		var routeID, totalMs, firstChunkMs int64
+		if err := rows.Scan(&model, &routeID, &provider, &totalMs, &firstChunkMs, &minute); err != nil {
+			return nil, err
+		}
+		overall.add(totalMs, firstChunkMs)
+		if models[model] == nil {
+			models[model] = &latencySamples{label: model}
+		}
+		models[model].add(totalMs, firstChunkMs)
+		if routeID > 0 {
+			if routes[routeID] == nil {
+				routes[routeID] = &latencySamples{label: provider}
+			}
+			routes[routeID].add(totalMs, firstChunkMs)
+		}
+		if t, err := time.ParseInLocation("2006-01-02 15:04", minute, time.Local); err == nil {
+			start := t.Truncate(bucket).Unix()
+			buckets[start] = append(buckets[start], totalMs)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 路由名以当前配置为准（日志中的名称可能已过时）
+	if all, err := s.GetAllRoutes(); err == nil {
+		for _, route := range all {
+			if samples := routes[route.ID]; samples != nil {
+				samples.label = route.Name
+			}
+		}
+	}
+
+	stats := &LatencyStats{
+		Hours:         hours,
+		BucketMinutes: bucketMinutes,
+		Overall:       overall.item(""),
+		Models:        make([]LatencyStatsItem, 0, len(models)),
+		Routes:        make([]LatencyStatsItem, 0, len(routes)),
+	}
+	for model, samples := range models {
+		stats.Models = append(stats.Models, samples.item(model))
+	}
+	for routeID, samples := range routes {
+		stats.Routes = append(stats.Routes, samples.item(strconv.FormatInt(routeID, 10)))
+	}
+	for _, items := range [][]LatencyStatsItem{stats.Models, stats.Routes} {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].Total.Samples != items[j].Total.Samples {
+				return items[i].Total.Samples > items[j].Total.Samples
+			}
+			return items[i].Key < items[j].Key
+		})
+	}
+	// 没有请求的时间段也输出，折线图时间轴连续
+	for t := since; !t.After(now); t = t.Add(bucket) {
+		stats.Series = append(stats.Series, LatencyPoint{
+			Time:               t.Format("2006-01-02 15:04"),
+			LatencyPercentiles: latencyPercentiles(buckets[t.Unix()]),
+		})
+	}
+	return stats, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// 实时请求流：每条请求日志入队时同时发布给 GUI（Wails 事件），前端据此显示实时请求列表和 RPS/延迟曲线，不需要轮询
+// 事件按 liveFlushInterval 合并成一批发送，避免高并发时逐条发送阻塞前端；没有订阅者时不做任何处理
+
+// LiveRequestsEventName 实时请求事件发给前端的 Wails 事件名，数据为 []LiveRequestEvent
+const LiveRequestsEventName = "anyproxy:requests"
+
+const (
+	liveFlushInterval = 250 * time.Millisecond // 合并发送的间隔
+	liveBatchLimit    = 1000                   // 一批最多保留的事件数，超出时丢弃最旧的
+)
+
+// LiveRequestEvent 一次请求的结果
+type LiveRequestEvent struct {
+	Time           int64  `json:"time"` // 完成时间（Unix 毫秒）
+	RequestID      string `json:"request_id"`
+	Model          string `json:"model"`
+	ProviderModel  string `json:"provider_model"`
+	ProviderName   string `json:"provider_name"`
+	RouteID        int64  `json:"route_id"`
+	Style          string `json:"style"`
+	Stream         bool   `json:"stream"`
+	Success        bool   `json:"success"`
+	ErrorType      string `json:"error_type,omitempty"`
+	RequestTokens  int    `json:"request_tokens"`
+	ResponseTokens int    `json:"response_tokens"`
+	TotalTokens    int    `json:"total_tokens"`
+	LatencyMs      int64  `json:"latency_ms"`
+	FirstChunkMs   int64  `json:"first_chunk_ms"`
+}
+
+// liveFeed 收集请求事件并定时批量发送给订阅者
+type liveFeed struct {
+	mu        sync.Mutex
+	handler   func([]LiveRequestEvent)
+	pending   []LiveRequestEvent
+	scheduled bool
+}
+
+// OnRequestLogged 订阅实时请求事件（每批在后台协程中回调），传入 nil 取消订阅
+func (s *RouteService) OnRequestLogged(handler func([]LiveRequestEvent)) {
+	s.live.mu.Lock()
+	s.live.handler = handler
+	s.live.pending = nil
+	s.live.mu.Unlock()
+}
+
+// publish 记录一条请求日志对应的事件，本批第一条事件时安排发送
+func (f *liveFeed) publish(params RequestLogParams) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.handler == nil {
+		return
+	}
+	f.pending = append(f.pending, LiveRequestEvent{
+		Time:           params.CreatedAt.UnixMilli(),
+		RequestID:      params.RequestID,
+		Model:          params.Model,
+		ProviderModel:  params.ProviderModel,
+		ProviderName:   params.ProviderName,
+		RouteID:        params.RouteID,
+		Style:          params.Style,
+		Stream:         params.IsStream,
+		Success:        params.Success,
+		ErrorType:      params.ErrorType,
+		RequestTokens:  params.RequestTokens,
+		ResponseTokens: params.ResponseTokens,
+		TotalTokens:    params.TotalTokens,
+		LatencyMs:      params.ProxyTimeMs,
+		FirstChunkMs:   params.FirstChunkMs,
+	})
+	if over := len(f.pending) - liveBatchLimit; over > 0 {
+		f.pending = f.pending[over:]
+	}
+	if !f.scheduled {
+		f.scheduled = true
+		time.AfterFunc(liveFlushInterval, f.flush)
+	}
+}
+
+// flush 发送已收集的事件
+func (f *liveFeed) flush() {
+	f.mu.Lock()
+	batch, handler := f.pending, f.handler
+	f.pending = nil
+	f.scheduled = false
+	f.mu.Unlock()
+	if handler != nil && len(batch) > 0 {
+		handler(batch)
+	}
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+)
+
+// 请求日志导出：按时间范围和筛选条件将请求日志逐行写入 CSV/JSON（不一次性读入内存），可选择导出的列，用于在表格中整理用量和费用
+// 只导出未压缩的请求日志，已压缩到 hourly_stats 的历史数据没有单条记录
+
+const (
+	LogExportCSV  = "csv"
+	LogExportJSON = "json" // 记录数组
+)
+
+// RequestLogExportOptions 导出条件
+type RequestLogExportOptions struct {
+	DateFrom string            `json:"date_from"` // 2006-01-02 或 2006-01-02 15:04:05，为空表示不限
+	DateTo   string            `json:"date_to"`   // 只有日期时包含当天
+	Filters  map[string]string `json:"filters"`   // 与请求日志列表相同：model、provider_name、style、success、search 等
+	Format   string            `json:"format"`    // csv（默认）、json
+	Columns  []string          `json:"columns"`   // 导出的列（按顺序），为空时导出全部列
+}
+
+// RequestLogExportResult 导出结果，Path 为空表示取消了保存
+type RequestLogExportResult struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	Count  int    `json:"count"`
+}
+
+// logExportRow 导出的一条请求日志
+type logExportRow struct {
+	database.RequestLog
+	VirtualKey    string
+	EstimatedCost float64
+}
+
+// logExportColumn 导出列：列名和取值
+type logExportColumn struct {
+	name  string
+	value func(r *logExportRow) interface{}
+}
+
+// logExportColumns 可导出的列（默认顺序）
+var logExportColumns = []logExportColumn{
+	{"id", func(r *logExportRow) interface{} { return r.ID }},
+	{"created_at", func(r *logExportRow) interface{} { return r.CreatedAt.Format("2006-01-02 15:04:05") }},
+	{"request_id", func(r *logExportRow) interface{} { return r.RequestID }},
+	{"model", func(r *logExportRow) interface{} { return r.Model }},
+	{"provider_model", func(r *logExportRow) interface{} { return r.ProviderModel }},
+	{"provider_name", func(r *logExportRow) interface{} { return r.ProviderName }},
+	{"route_id", func(r *logExportRow) interface{} { return r.RouteID }},
+	{"style", func(r *logExportRow) interface{} { return r.Style }},
+	{"virtual_key", func(r *logExportRow) interface{} { return r.VirtualKey }},
+	{"is_stream", func(r *logExportRow) interface{} { return r.IsStream }},
+	{"success", func(r *logExportRow) interface{} { return r.Success }},
+	{"error_type", func(r *logExportRow) interface{} { return r.ErrorType }},
+	{"error_message", func(r *logExportRow) interface{} { return r.ErrorMessage }},
+	{"request_tokens", func(r *logExportRow) interface{} { return r.RequestTokens }},
+	{"response_tokens", func(r *logExportRow) interface{} { return r.ResponseTokens }},
+	{"total_tokens", func(r *logExportRow) interface{} { return r.TotalTokens }},
+	{"tokens_estimated", func(r *logExportRow) interface{} { return r.TokensEstimated }},
+	{"cost", func(r *logExportRow) interface{} { return r.Cost }},                    // 上游返回的实际费用
+	{"estimated_cost", func(r *logExportRow) interface{} { return r.EstimatedCost }}, // 实际费用，没有时按 model_prices 估算
+	{"proxy_time_ms", func(r *logExportRow) interface{} { return r.ProxyTimeMs }},
+	{"first_chunk_ms", func(r *logExportRow) interface{} { return r.FirstChunkMs }},
+	{"remote_ip", func(r *logExportRow) interface{} { return r.RemoteIP }},
+	{"user_agent", func(r *logExportRow) interface{} { return r.UserAgent }},
+}
+
+// RequestLogExportColumns 可导出的列名（默认顺序）
+func RequestLogExportColumns() []string {
+	names := make([]string, len(logExportColumns))
+	for i, column := range logExportColumns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// Normalize 校验导出选项并填充默认值（格式、列、时间范围）
+func (o *RequestLogExportOptions) Normalize() error {
+	o.Format = strings.ToLower(strings.TrimSpace(o.Format))
+	if o.Format == "" {
+		o.Format = LogExportCSV
+	}
+	if o.Format != LogExportCSV && o.Format != LogExportJSON {
+		return fmt.Errorf("unsupported export format: %s (expected csv or json)", o.Format)
+	}
+	if len(o.Columns) == 0 {
+		o.Columns = RequestLogExportColumns()
+	}
+	for _, name := range o.Columns {
+		if findLogExportColumn(name) == nil {
+			return fmt.Errorf("unknown export column: %s", name)
+		}
+	}
+	o.DateFrom = strings.TrimSpace(o.DateFrom)
+	o.DateTo = strings.TrimSpace(o.DateTo)
+	if len(o.DateFrom) == len("2006-01-02") {
+		o.DateFrom += " 00:00:00"
+	}
+	if len(o.DateTo) == len("2006-01-02") {
+		o.DateTo += " 23:59:59"
+	}
+	return nil
+}
+
+// Filename 默认文件名：request-logs[-开始日期][-结束日期].csv/json
+func (o *RequestLogExportOptions) Filename() string {
+	name := "request-logs"
+	for _, t := range []string{o.DateFrom, o.DateTo} {
+		if len(t) >= len("2006-01-02") {
+			name += "-" + t[:len("2006-01-02")]
+		}
+	}
+	return name + "." + o.Format
+}
+
+func findLogExportColumn(name string) *logExportColumn {
+	for i := range logExportColumns {
+		if logExportColumns[i].name == name {
+			return &logExportColumns[i]
+		}
+	}
+	return nil
+}
+
+// logExportCSVValue CSV 单元格的值
+func logExportCSVValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// ExportRequestLogs 按 ID 升序将请求日志写入 w，返回导出的记录数；费用估算使用 cfg 中的模型价格
+func (s *RouteService) ExportRequestLogs(w io.Writer, opts RequestLogExportOptions, cfg *config.Config) (int, error) {
+	if err := opts.Normalize(); err != nil {
+		return 0, err
+	}
+	columns := make([]*logExportColumn, len(opts.Columns))
+	for i, name := range opts.Columns {
+		columns[i] = findLogExportColumn(name)
+	}
+
+	filters := make(map[string]string, len(opts.Filters)+2)
+	for k, v := range opts.Filters {
+		filters[k] = v
+	}
+	filters["start_time"] = opts.DateFrom
+	filters["end_time"] = opts.DateTo
+	whereClause, args := s.requestLogWhere(filters)
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT id, model, COALESCE(provider_model, ''), COALESCE(provider_name, ''),
+			COALESCE(route_id, 0), request_tokens, response_tokens, total_tokens,
+			success, COALESCE(error_message, ''), COALESCE(style, ''),
+			COALESCE(user_agent, ''), COALESCE(remote_ip, ''),
+			COALESCE(proxy_time_ms, 0), COALESCE(first_chunk_ms, 0),
+			COALESCE(is_stream, 0), COALESCE(request_id, ''), COALESCE(tokens_estimated, 0), COALESCE(cost, 0),
+			COALESCE(error_type, ''), COALESCE(virtual_key, ''), created_at
+		FROM request_logs %s
+		ORDER BY id`, whereClause), args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var csvWriter *csv.Writer
+	if opts.Format == LogExportCSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(opts.Columns); err != nil {
+			return 0, err
+		}
+	} else if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	record := make([]string, len(columns))
+	for rows.Next() {
+		var r logExportRow
+		var isStream, estimated int
+		if err := rows.Scan(
+			&r.ID, &r.Model, &r.ProviderModel, &r.ProviderName,
+			&r.RouteID, &r.RequestTokens, &r.ResponseTokens, &r.TotalTokens,
+			&r.Success, &r.ErrorMessage, &r.Style,
+			&r.UserAgent, &r.RemoteIP,
+			&r.ProxyTimeMs, &r.FirstChunkMs, &isStream, &r.RequestID, &estimated, &r.Cost,
+			&r.ErrorType, &r.VirtualKey, &r.CreatedAt,
+		); err != nil {
+			return count, err
+		}
+		r.IsStream = isStream == 1
+		r.TokensEstimated = estimated == 1
+		if !r.Success && r.ErrorType == "" {
+			r.ErrorType = ClassifyError(r.ErrorMessage)
+		}
+		r.EstimatedCost = r.Cost
+		if r.Cost == 0 {
+			if price, ok := cfg.PriceFor(r.Model); ok {
+				r.EstimatedCost = (float64(r.RequestTokens)*price.Input + float64(r.ResponseTokens)*price.Output) / 1e6
+			}
+		}
+
+		if csvWriter != nil {
+			for i, column := range columns {
+				record[i] = logExportCSVValue(column.value(&r))
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return count, err
+			}
+		} else {
+			// 手动拼接对象，保持列的顺序
+			var b strings.Builder
+			if count > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n  {")
+			for i, column := range columns {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				key, _ := json.Marshal(column.name)
+				value, _ := json.Marshal(column.value(&r))
+				b.Write(key)
+				b.WriteString(": ")
+				b.Write(value)
+			}
+			b.WriteString("}")
+			if _, err := io.WriteString(w, b.String()); err != nil {
+				return count, err
+			}
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return count, csvWriter.Error()
+	}
+	closing := "]\n"
+	if count > 0 {
+		closing = "\n]\n"
+	}
+	_, err = io.WriteString(w, closing)
+	return count, err
+}
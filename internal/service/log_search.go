@@ -0,0 +1,98 @@
+package service
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"openai-router-go/internal/database"
+)
+
+// 全文搜索：请求日志搜索错误信息，Traces 搜索模型、供应商、客户端 IP、请求/响应内容和错误信息。
+// SQLite 使用 FTS5 trigram 索引（按子串匹配，不区分大小写，支持中文），PostgreSQL 使用 tsvector 索引（按词匹配），
+// MySQL 使用 FULLTEXT 索引；trigram 无法匹配少于 3 个字符的搜索词，这时改用 LIKE。多个搜索词需要同时匹配
+
+// minTrigramTerm trigram 索引可匹配的最短搜索词（字符数）
+const minTrigramTerm = 3
+
+// traceSearchColumns Traces 全文索引中的列
+var traceSearchColumns = []string{"model", "provider_name", "remote_ip", "request_content", "response_content", "error_message"}
+
+// searchTerms 按空白拆分搜索词
+func searchTerms(query string) []string {
+	return strings.Fields(query)
+}
+
+// trigramSearchable 所有搜索词都足够长，可以使用 trigram 索引
+func trigramSearchable(terms []string) bool {
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < minTrigramTerm {
+			return false
+		}
+	}
+	return true
+}
+
+// ftsMatchQuery 转换为 FTS5 查询：每个搜索词作为短语（trigram 下即子串）匹配
+func ftsMatchQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// mysqlMatchQuery 转换为 MySQL 布尔模式查询：每个搜索词必须出现
+func mysqlMatchQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `+"` + strings.ReplaceAll(term, `"`, ``) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// likeSearchCondition 每个搜索词都出现在任一列中（! 为 LIKE 转义字符）
+func likeSearchCondition(columns, terms []string) (string, []interface{}) {
+	escaper := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	var conditions []string
+	var args []interface{}
+	for _, term := range terms {
+		pattern := "%" + escaper.Replace(term) + "%"
+		var matches []string
+		for _, column := range columns {
+			matches = append(matches, column+" LIKE ? ESCAPE '!'")
+			args = append(args, pattern)
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// requestLogSearchCondition 请求日志错误信息的搜索条件，query 为空时返回空条件
+func (s *RouteService) requestLogSearchCondition(query string) (string, []interface{}) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return "", nil
+	}
+	switch s.db.Dialect {
+	case database.DialectPostgres:
+		return "to_tsvector('simple', COALESCE(error_message, '')) @@ plainto_tsquery('simple', ?)", []interface{}{strings.Join(terms, " ")}
+	case database.DialectMySQL:
+		return "MATCH(error_message) AGAINST (? IN BOOLEAN MODE)", []interface{}{mysqlMatchQuery(terms)}
+	}
+	if trigramSearchable(terms) {
+		return "id IN (SELECT rowid FROM request_logs_fts WHERE request_logs_fts MATCH ?)", []interface{}{ftsMatchQuery(terms)}
+	}
+	return likeSearchCondition([]string{"error_message"}, terms)
+}
+
+// traceSearchCondition Traces 的搜索条件（Traces 始终存储在 SQLite），query 为空时返回空条件
+func traceSearchCondition(query string) (string, []interface{}) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return "", nil
+	}
+	if trigramSearchable(terms) {
+		return "id IN (SELECT rowid FROM conversation_traces_fts WHERE conversation_traces_fts MATCH ?)", []interface{}{ftsMatchQuery(terms)}
+	}
+	return likeSearchCondition(traceSearchColumns, terms)
+}
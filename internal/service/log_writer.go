@@ -0,0 +1,207 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logQueueSize     = 4096             // 待写入队列长度
+	logBatchSize     = 200              // 单个事务最多写入的日志条数
+	logPendingLimit  = 10000            // 写入失败时内存中最多保留的日志条数
+	logRetryInterval = 5 * time.Second  // 数据库不可用时的重试间隔
+	logFlushTimeout  = 10 * time.Second // 退出时等待日志落盘的最长时间
+)
+
+// requestLogWriter 异步批量写入请求日志
+// 代理请求只负责入队，不等待数据库；后台协程把队列中已积累的日志合并到一个事务中写入，
+// 数据库被锁定或不可用时日志暂存在内存中，稍后重试。所有写入都在 run 协程中进行
+type requestLogWriter struct {
+	rs      *RouteService
+	queue   chan RequestLogParams
+	flushCh chan chan struct{}
+
+	mu      sync.Mutex
+	pending []RequestLogParams // 写入失败待重试的日志
+	writing int                // 正在写入的条数
+	dropped int64              // 内存缓冲溢出丢弃的条数
+	failing bool               // 数据库当前是否处于写入失败状态
+}
+
+func newRequestLogWriter(rs *RouteService) *requestLogWriter {
+	w := &requestLogWriter{
+		rs:      rs,
+		queue:   make(chan RequestLogParams, logQueueSize),
+		flushCh: make(chan chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue 提交日志，队列满时直接放入内存缓冲，永不阻塞调用方
+func (w *requestLogWriter) enqueue(params RequestLogParams) {
+	select {
+	case w.queue <- params:
+	default:
+		w.hold(params)
+	}
+}
+
+// hold 暂存写入失败的日志，超出上限时丢弃最旧的记录
+func (w *requestLogWriter) hold(batch ...RequestLogParams) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, batch...)
+	if over := len(w.pending) - logPendingLimit; over > 0 {
+		w.pending = w.pending[over:]
+		before := w.dropped
+		w.dropped += int64(over)
+		if before == 0 || before/1000 != w.dropped/1000 {
+			log.Warnf("Request log buffer full, %d log(s) dropped", w.dropped)
+		}
+	}
+}
+
+func (w *requestLogWriter) run() {
+	ticker := time.NewTicker(logRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case params := <-w.queue:
+			w.write(w.collect(params))
+		case <-ticker.C:
+			w.retryPending()
+		case done := <-w.flushCh:
+			w.drainQueue()
+			w.retryPending()
+			close(done)
+		}
+	}
+}
+
+// drainQueue 将队列中的日志全部转入内存缓冲
+func (w *requestLogWriter) drainQueue() {
+	for {
+		select {
+		case params := <-w.queue:
+			w.hold(params)
+		default:
+			return
+		}
+	}
+}
+
+// collect 取出队列中已积累的日志（不等待），与 first 合并为一个批次
+func (w *requestLogWriter) collect(first RequestLogParams) []RequestLogParams {
+	batch := []RequestLogParams{first}
+	for len(batch) < logBatchSize {
+		select {
+		case params := <-w.queue:
+			batch = append(batch, params)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// write 写入一个批次，失败时整批转入内存缓冲
+func (w *requestLogWriter) write(batch []RequestLogParams) {
+	// 数据库不可用期间保持顺序，新日志排在缓冲之后
+	w.mu.Lock()
+	if w.failing {
+		w.mu.Unlock()
+		w.hold(batch...)
+		return
+	}
+	w.writing = len(batch)
+	w.mu.Unlock()
+
+	err := w.rs.insertRequestLogs(batch)
+
+	w.mu.Lock()
+	w.writing = 0
+	if err != nil {
+		w.failing = true
+	}
+	w.mu.Unlock()
+	if err != nil {
+		log.Warnf("Request log write failed, buffering %d log(s) in memory: %v", len(batch), err)
+		w.hold(batch...)
+	}
+}
+
+// retryPending 分批重试写入缓冲中的日志，遇到错误时停止等待下次重试
+func (w *requestLogWriter) retryPending() {
+	w.mu.Lock()
+	remaining := w.pending
+	w.pending = nil
+	w.writing = len(remaining)
+	w.mu.Unlock()
+	if len(remaining) == 0 {
+		return
+	}
+
+	written := 0
+	for len(remaining) > 0 {
+		n := len(remaining)
+		if n > logBatchSize {
+			n = logBatchSize
+		}
+		if err := w.rs.insertRequestLogs(remaining[:n]); err != nil {
+			log.Warnf("Request log retry failed (%d pending): %v", len(remaining), err)
+			break
+		}
+		remaining = remaining[n:]
+		written += n
+	}
+
+	w.mu.Lock()
+	w.writing = 0
+	w.pending = append(remaining, w.pending...)
+	if over := len(w.pending) - logPendingLimit; over > 0 {
+		w.dropped += int64(over)
+		w.pending = w.pending[over:]
+	}
+	w.failing = len(w.pending) > 0
+	w.mu.Unlock()
+
+	if written > 0 {
+		log.Infof("Flushed %d buffered request log(s)", written)
+	}
+}
+
+// flush 由写入协程写完队列和缓冲中的所有日志（用于退出前），超时后放弃
+func (w *requestLogWriter) flush(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for {
+		done := make(chan struct{})
+		select {
+		case w.flushCh <- done:
+			select {
+			case <-done:
+			case <-time.After(time.Until(deadline)):
+			}
+		case <-time.After(time.Until(deadline)):
+		}
+
+		pending, _, _ := w.status()
+		if pending == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			log.Warnf("Giving up on %d unsaved request log(s)", pending)
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// status 缓冲状态
+func (w *requestLogWriter) status() (pending int, dropped int64, failing bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending) + w.writing + len(w.queue), w.dropped, w.failing
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"openai-router-go/internal/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maintenanceCheckInterval = time.Minute     // 检查是否到达执行时间的间隔
+	maintenanceStartupDelay  = 2 * time.Minute // 启动后首次执行的延迟，避开启动时的初始化
+)
+
+// MaintenanceStatus 后台维护任务状态
+type MaintenanceStatus struct {
+	Running       bool   `json:"running"`
+	LastRunAt     string `json:"last_run_at"`
+	LastVacuumAt  string `json:"last_vacuum_at"`
+	DeletedTraces int64  `json:"deleted_traces"`
+	DeletedLogs   int64  `json:"deleted_logs"`
+	LastError     string `json:"last_error"`
+}
+
+// MaintenanceScheduler 按配置定期清理过期 Traces、将旧请求日志聚合为小时统计并 VACUUM
+// 配置在每次检查时重新读取，设置页修改后无需重启
+type MaintenanceScheduler struct {
+	rs     *RouteService
+	config *config.Config
+	stop   chan struct{}
+
+	mu         sync.Mutex
+	running    bool
+	startedAt  time.Time
+	lastRun    time.Time
+	lastVacuum time.Time
+	status     MaintenanceStatus
+}
+
+func NewMaintenanceScheduler(rs *RouteService, cfg *config.Config) *MaintenanceScheduler {
+	return &MaintenanceScheduler{rs: rs, config: cfg, stop: make(chan struct{})}
+}
+
+// Start 启动后台调度
+func (m *MaintenanceScheduler) Start() {
+	m.mu.Lock()
+	m.startedAt = time.Now()
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(maintenanceCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				if m.due() {
+					m.run(false)
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止后台调度（正在执行的任务会继续完成）
+func (m *MaintenanceScheduler) Stop() {
+	close(m.stop)
+}
+
+// RunNow 立即执行一次维护（包含 VACUUM）
+func (m *MaintenanceScheduler) RunNow() MaintenanceStatus {
+	m.run(true)
+	return m.Status()
+}
+
+// Status 获取最近一次执行的结果
+func (m *MaintenanceScheduler) Status() MaintenanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := m.status
+	status.Running = m.running
+	return status
+}
+
+// due 判断是否到达执行时间：启动后延迟首次执行，之后按配置的间隔执行
+func (m *MaintenanceScheduler) due() bool {
+	if !m.config.MaintenanceEnabled {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return false
+	}
+	if m.lastRun.IsZero() {
+		return time.Since(m.startedAt) >= maintenanceStartupDelay
+	}
+	interval := time.Duration(m.config.MaintenanceIntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 6 * time.Hour
+	}
+	return time.Since(m.lastRun) >= interval
+}
+
+// run 执行一次维护，force 为 true 时无论间隔都执行 VACUUM
+func (m *MaintenanceScheduler) run(force bool) {
+	m.mu.Lock()
+	if m.running {
+		m.mu.Unlock()
+		return
+	}
+	m.running = true
+	// 重启后首次执行时也 VACUUM，避免应用每天重启导致永远到不了间隔
+	vacuumInterval := time.Duration(m.config.VacuumIntervalHours) * time.Hour
+	vacuum := force || (vacuumInterval > 0 && (m.lastVacuum.IsZero() || time.Since(m.lastVacuum) >= vacuumInterval))
+	m.mu.Unlock()
+
+	start := time.Now()
+	status := MaintenanceStatus{LastRunAt: start.Format("2006-01-02 15:04:05")}
+
+	// 1. 清理超过保留天数的 Traces（关闭追踪后旧记录同样按保留天数清理）
+	if days := m.config.TracesRetentionDays; days > 0 {
+		if deleted, err := m.rs.ClearTraces(days); err != nil {
+			status.LastError = "traces: " + err.Error()
+		} else {
+			status.DeletedTraces = deleted
+		}
+	}
+
+	// 2. 将保留期之前的请求日志聚合为小时统计
+	result, err := m.rs.CompressRequestLogs(m.config.LogRetentionDays, false)
+	if err != nil {
+		status.LastError = "request logs: " + err.Error()
+	} else if deleted, ok := result["deleted_logs"].(int64); ok {
+		status.DeletedLogs = deleted
+	}
+
+	// 3. 定期 VACUUM
+	if vacuum {
+		m.rs.Vacuum()
+	}
+
+	m.mu.Lock()
+	m.running = false
+	m.lastRun = start
+	if vacuum {
+		m.lastVacuum = time.Now()
+	}
+	if !m.lastVacuum.IsZero() {
+		status.LastVacuumAt = m.lastVacuum.Format("2006-01-02 15:04:05")
+	}
+	m.status = status
+	m.mu.Unlock()
+
+	if status.LastError != "" {
+		log.Warnf("Scheduled maintenance finished with error: %s", status.LastError)
+	} else {
+		log.Infof("Scheduled maintenance done in %v: deleted_traces=%d, compressed_logs=%d, vacuum=%v",
+			time.Since(start), status.DeletedTraces, status.DeletedLogs, vacuum)
+	}
+}
@@ -0,0 +1,504 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/database"
+	"openai-router-go/internal/tokenizer"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	mirrorTimeout       = 5 * time.Minute // 影子请求超时
+	mirrorConcurrency   = 8               // 同时进行的影子请求数，超出时跳过镜像
+	mirrorRetentionDays = 30              // 镜像对比结果保留天数
+	mirrorErrorLimit    = 512             // 记录的错误响应最大长度
+)
+
+// mirrorSlots 限制影子请求并发，避免镜像流量影响主请求
+var mirrorSlots = make(chan struct{}, mirrorConcurrency)
+
+// MirrorRule 模型的流量镜像规则：请求副本按采样比例异步发送到影子路由
+type MirrorRule struct {
+	ID            int64     `json:"id"`
+	Model         string    `json:"model"`
+	ShadowRouteID int64     `json:"shadow_route_id"`
+	SamplePercent int       `json:"sample_percent"`
+	Enabled       bool      `json:"enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// MirrorResult 一次镜像请求的对比结果，similarity 为两次响应文本的相似度（0-1）
+type MirrorResult struct {
+	ID               int64     `json:"id"`
+	RequestID        string    `json:"request_id"`
+	Model            string    `json:"model"`
+	ShadowRouteID    int64     `json:"shadow_route_id"`
+	PrimaryStatus    int       `json:"primary_status"`
+	PrimaryLatencyMs int64     `json:"primary_latency_ms"`
+	PrimaryTokens    int       `json:"primary_tokens"`
+	ShadowStatus     int       `json:"shadow_status"`
+	ShadowLatencyMs  int64     `json:"shadow_latency_ms"`
+	ShadowTokens     int       `json:"shadow_tokens"`
+	Similarity       float64   `json:"similarity"`
+	ErrorMessage     string    `json:"error_message"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// MirrorSummary 按模型和影子路由汇总的对比结果
+type MirrorSummary struct {
+	Model            string  `json:"model"`
+	ShadowRouteID    int64   `json:"shadow_route_id"`
+	Requests         int     `json:"requests"`
+	ShadowFailures   int     `json:"shadow_failures"`
+	PrimaryLatencyMs float64 `json:"primary_latency_ms"`
+	ShadowLatencyMs  float64 `json:"shadow_latency_ms"`
+	PrimaryTokens    float64 `json:"primary_tokens"`
+	ShadowTokens     float64 `json:"shadow_tokens"`
+	Similarity       float64 `json:"similarity"`
+}
+
+// GetMirrorRules 获取所有镜像规则
+func (s *RouteService) GetMirrorRules() ([]MirrorRule, error) {
+	rows, err := s.db.Query(`
+		SELECT id, model, shadow_route_id, sample_percent, enabled, created_at, updated_at
+		FROM mirror_rules ORDER BY model`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]MirrorRule, 0)
+	for rows.Next() {
+		var r MirrorRule
+		var enabled int
+		if err := rows.Scan(&r.ID, &r.Model, &r.ShadowRouteID, &r.SamplePercent, &enabled, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		r.Enabled = enabled == 1
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// SaveMirrorRule 新增或更新镜像规则，同一模型只能有一条规则
+func (s *RouteService) SaveMirrorRule(r MirrorRule) (MirrorRule, error) {
+	r.Model = strings.TrimSpace(r.Model)
+	if r.Model == "" {
+		return r, fmt.Errorf("model is required")
+	}
+	if _, err := s.GetRouteByID(r.ShadowRouteID); err != nil {
+		return r, fmt.Errorf("shadow route not found: id=%d", r.ShadowRouteID)
+	}
+	if r.SamplePercent <= 0 || r.SamplePercent > 100 {
+		r.SamplePercent = 100
+	}
+
+	var existingID int64
+	err := s.db.QueryRow(`SELECT id FROM mirror_rules WHERE model = ?`, r.Model).Scan(&existingID)
+	if err == nil && existingID != r.ID {
+		if r.ID != 0 {
+			return r, fmt.Errorf("a mirror rule for model %s already exists", r.Model)
+		}
+		r.ID = existingID
+	}
+
+	enabled := 0
+	if r.Enabled {
+		enabled = 1
+	}
+	now := time.Now()
+	if r.ID == 0 {
+		_, err = s.db.Exec(`
+			INSERT INTO mirror_rules (model, shadow_route_id, sample_percent, enabled, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			r.Model, r.ShadowRouteID, r.SamplePercent, enabled, now, now)
+		if err == nil {
+			err = s.db.QueryRow(`SELECT id FROM mirror_rules WHERE model = ?`, r.Model).Scan(&r.ID)
+		}
+	} else {
+		_, err = s.db.Exec(`
+			UPDATE mirror_rules SET model = ?, shadow_route_id = ?, sample_percent = ?, enabled = ?, updated_at = ?
+			WHERE id = ?`,
+			r.Model, r.ShadowRouteID, r.SamplePercent, enabled, now, r.ID)
+	}
+	if err != nil {
+		log.Errorf("Failed to save mirror rule: %v", err)
+		return r, err
+	}
+	r.UpdatedAt = now
+	log.Infof("Mirror rule saved: %s -> route %d (%d%%, enabled=%v)", r.Model, r.ShadowRouteID, r.SamplePercent, r.Enabled)
+	return r, nil
+}
+
+// DeleteMirrorRule 删除镜像规则
+func (s *RouteService) DeleteMirrorRule(id int64) error {
+	result, err := s.db.Exec(`DELETE FROM mirror_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("mirror rule not found: id=%d", id)
+	}
+	log.Infof("Mirror rule deleted: id=%d", id)
+	return nil
+}
+
+// mirrorRuleForModel 查找模型启用的镜像规则，没有时返回 nil
+func (s *RouteService) mirrorRuleForModel(model string) *MirrorRule {
+	var r MirrorRule
+	err := s.db.QueryRow(`
+		SELECT id, model, shadow_route_id, sample_percent
+		FROM mirror_rules WHERE model = ? AND enabled = 1`, model).
+		Scan(&r.ID, &r.Model, &r.ShadowRouteID, &r.SamplePercent)
+	if err != nil {
+		return nil
+	}
+	r.Enabled = true
+	return &r
+}
+
+// SaveMirrorResult 保存对比结果，并清理过期记录
+func (s *RouteService) SaveMirrorResult(r MirrorResult) error {
+	_, err := s.db.Exec(`
+		INSERT INTO mirror_results (request_id, model, shadow_route_id, primary_status, primary_latency_ms, primary_tokens,
+			shadow_status, shadow_latency_ms, shadow_tokens, similarity, error_message, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.RequestID, r.Model, r.ShadowRouteID, r.PrimaryStatus, r.PrimaryLatencyMs, r.PrimaryTokens,
+		r.ShadowStatus, r.ShadowLatencyMs, r.ShadowTokens, r.Similarity, r.ErrorMessage, r.CreatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`DELETE FROM mirror_results WHERE created_at < ?`, time.Now().AddDate(0, 0, -mirrorRetentionDays))
+	return err
+}
+
+// GetMirrorResults 获取最近的对比结果，model 为空时返回所有模型
+func (s *RouteService) GetMirrorResults(model string, limit int) ([]MirrorResult, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	query := `
+		SELECT id, COALESCE(request_id, ''), model, shadow_route_id, primary_status, primary_latency_ms, primary_tokens,
+			shadow_status, shadow_latency_ms, shadow_tokens, similarity, COALESCE(error_message, ''), created_at
+		FROM mirror_results`
+	args := []interface{}{}
+	if model != "" {
+		query += ` WHERE model = ?`
+		args = append(args, model)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]MirrorResult, 0)
+	for rows.Next() {
+		var r MirrorResult
+		if err := rows.Scan(&r.ID, &r.RequestID, &r.Model, &r.ShadowRouteID, &r.PrimaryStatus, &r.PrimaryLatencyMs, &r.PrimaryTokens,
+			&r.ShadowStatus, &r.ShadowLatencyMs, &r.ShadowTokens, &r.Similarity, &r.ErrorMessage, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// GetMirrorSummary 按模型和影子路由汇总对比结果，延迟、token 和相似度只统计双方都成功的请求
+func (s *RouteService) GetMirrorSummary() ([]MirrorSummary, error) {
+	rows, err := s.db.Query(`
+		SELECT model, shadow_route_id, COUNT(*),
+			SUM(CASE WHEN shadow_status >= 200 AND shadow_status < 300 THEN 0 ELSE 1 END),
+			AVG(CASE WHEN ok = 1 THEN primary_latency_ms END),
+			AVG(CASE WHEN ok = 1 THEN shadow_latency_ms END),
+			AVG(CASE WHEN ok = 1 THEN primary_tokens END),
+			AVG(CASE WHEN ok = 1 THEN shadow_tokens END),
+			AVG(CASE WHEN ok = 1 THEN similarity END)
+		FROM (
+			SELECT *, CASE WHEN primary_status >= 200 AND primary_status < 300
+				AND shadow_status >= 200 AND shadow_status < 300 THEN 1 ELSE 0 END AS ok
+			FROM mirror_results
+		) r
+		GROUP BY model, shadow_route_id
+		ORDER BY model`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make([]MirrorSummary, 0)
+	for rows.Next() {
+		var m MirrorSummary
+		var primaryLatency, shadowLatency, primaryTokens, shadowTokens, similarity *float64
+		if err := rows.Scan(&m.Model, &m.ShadowRouteID, &m.Requests, &m.ShadowFailures,
+			&primaryLatency, &shadowLatency, &primaryTokens, &shadowTokens, &similarity); err != nil {
+			return nil, err
+		}
+		for _, v := range []struct {
+			src *float64
+			dst *float64
+		}{
+			{primaryLatency, &m.PrimaryLatencyMs}, {shadowLatency, &m.ShadowLatencyMs},
+			{primaryTokens, &m.PrimaryTokens}, {shadowTokens, &m.ShadowTokens}, {similarity, &m.Similarity},
+		} {
+			if v.src != nil {
+				*v.dst = *v.src
+			}
+		}
+		summaries = append(summaries, m)
+	}
+	return summaries, rows.Err()
+}
+
+// ClearMirrorResults 清空对比结果
+func (s *RouteService) ClearMirrorResults() error {
+	_, err := s.db.Exec(`DELETE FROM mirror_results`)
+	return err
+}
+
+// MirrorRun 一次进行中的镜像：影子请求在后台发送，主请求的响应通过 Write 记录
+type MirrorRun struct {
+	requestID string
+	model     string
+	rule      *MirrorRule
+	start     time.Time
+	done      chan struct{}
+	shadow    mirrorShadowResult
+
+	mu     sync.Mutex
+	output bytes.Buffer
+}
+
+// mirrorShadowResult 影子请求的结果
+type mirrorShadowResult struct {
+	status    int
+	latencyMs int64
+	tokens    int
+	text      string
+	err       string
+}
+
+// Write 追加主请求返回给客户端的响应
+func (m *MirrorRun) Write(p []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if remaining := usageCaptureLimit - m.output.Len(); remaining > 0 {
+		if len(p) > remaining {
+			p = p[:remaining]
+		}
+		m.output.Write(p)
+	}
+}
+
+// StartMirror 模型配置了镜像规则时异步发送影子请求，未命中规则、未被采样或并发已满时返回 nil
+// requestFormat 为客户端请求格式（openai、claude、gemini），影子请求统一以非流式发送
+func (s *ProxyService) StartMirror(requestID, requestFormat, model string, body []byte) *MirrorRun {
+	if model == "" {
+		return nil
+	}
+	rule := s.routeService.mirrorRuleForModel(model)
+	if rule == nil || rand.Intn(100) >= rule.SamplePercent {
+		return nil
+	}
+	route, err := s.routeService.GetRouteByID(rule.ShadowRouteID)
+	if err != nil {
+		log.Warnf("[Mirror] [%s] Shadow route %d not found: %v", requestID, rule.ShadowRouteID, err)
+		return nil
+	}
+
+	select {
+	case mirrorSlots <- struct{}{}:
+	default:
+		log.Warnf("[Mirror] [%s] Too many shadow requests in flight, skipping", requestID)
+		return nil
+	}
+
+	run := &MirrorRun{requestID: requestID, model: model, rule: rule, start: time.Now(), done: make(chan struct{})}
+	safeGo(requestID, func() {
+		defer func() { <-mirrorSlots }()
+		defer close(run.done)
+		run.shadow = s.sendShadowRequest(route, requestFormat, model, body)
+	})
+	return run
+}
+
+// FinishMirror 主请求结束：等待影子请求完成后对比并保存结果（不阻塞调用方）
+func (s *ProxyService) FinishMirror(run *MirrorRun, primaryStatus int) {
+	primaryLatency := time.Since(run.start).Milliseconds()
+	run.mu.Lock()
+	output := append([]byte(nil), run.output.Bytes()...)
+	run.mu.Unlock()
+
+	safeGo(run.requestID, func() {
+		<-run.done
+		primaryText := extractResponseText(output)
+		result := MirrorResult{
+			RequestID:        run.requestID,
+			Model:            run.model,
+			ShadowRouteID:    run.rule.ShadowRouteID,
+			PrimaryStatus:    primaryStatus,
+			PrimaryLatencyMs: primaryLatency,
+			PrimaryTokens:    responseTokens(run.model, output, primaryText),
+			ShadowStatus:     run.shadow.status,
+			ShadowLatencyMs:  run.shadow.latencyMs,
+			ShadowTokens:     run.shadow.tokens,
+			Similarity:       textSimilarity(primaryText, run.shadow.text),
+			ErrorMessage:     run.shadow.err,
+			CreatedAt:        time.Now(),
+		}
+		log.Infof("[Mirror] [%s] %s: primary %dms/%d tokens, shadow(route %d) HTTP %d %dms/%d tokens, similarity %.2f",
+			run.requestID, run.model, result.PrimaryLatencyMs, result.PrimaryTokens, result.ShadowRouteID,
+			result.ShadowStatus, result.ShadowLatencyMs, result.ShadowTokens, result.Similarity)
+		if err := s.routeService.SaveMirrorResult(result); err != nil {
+			log.Warnf("[Mirror] [%s] Failed to save result: %v", run.requestID, err)
+		}
+	})
+}
+
+// sendShadowRequest 将客户端请求转换为 OpenAI 格式后按影子路由的格式发送（不经过重试、限流和 Fallback，不记录请求日志）
+func (s *ProxyService) sendShadowRequest(route *database.ModelRoute, requestFormat, model string, body []byte) mirrorShadowResult {
+	var result mirrorShadowResult
+	req, err := s.buildShadowRequest(route, requestFormat, model, body)
+	if err != nil {
+		result.err = err.Error()
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		result.latencyMs = time.Since(start).Milliseconds()
+		result.err = err.Error()
+		return result
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, usageCaptureLimit))
+	resp.Body.Close()
+	result.latencyMs = time.Since(start).Milliseconds()
+	result.status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if len(respBody) > mirrorErrorLimit {
+			respBody = respBody[:mirrorErrorLimit]
+		}
+		result.err = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		return result
+	}
+	result.text = extractResponseText(respBody)
+	result.tokens = responseTokens(route.Model, respBody, result.text)
+	return result
+}
+
+// buildShadowRequest 构建影子请求，路由的系统提示词模板、自定义请求头和供应商兼容处理与正常请求一致
+func (s *ProxyService) buildShadowRequest(route *database.ModelRoute, requestFormat, model string, body []byte) (*http.Request, error) {
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		return nil, fmt.Errorf("invalid request body: %v", err)
+	}
+	shadow := *route
+	s.routeService.pickRouteKey(&shadow)
+	route = &shadow
+	reqData, _ = s.applyPromptTemplate(reqData, route, requestFormat, model)
+
+	// 先统一转换为 OpenAI 格式
+	var toOpenAI string
+	switch requestFormat {
+	case "claude":
+		toOpenAI = "claude-to-openai"
+	case "gemini":
+		toOpenAI = "gemini-to-openai"
+	}
+	if toOpenAI != "" {
+		adapter := adapters.GetAdapter(toOpenAI)
+		if adapter == nil {
+			return nil, fmt.Errorf("adapter not found: %s", toOpenAI)
+		}
+		converted, err := adapter.AdaptRequest(reqData, route.Model)
+		if err != nil {
+			return nil, err
+		}
+		reqData = converted
+	}
+	reqData["model"] = route.Model
+	reqData["stream"] = false
+	delete(reqData, "stream_options")
+
+	var shadowBody []byte
+	var targetURL string
+	adapterName := s.detectAdapterForRoute(route, "openai")
+	if adapterName != "" {
+		adapter := adapters.GetAdapter(adapterName)
+		if adapter == nil {
+			return nil, fmt.Errorf("adapter not found: %s", adapterName)
+		}
+		transformed, err := adapter.AdaptRequest(reqData, route.Model)
+		if err != nil {
+			return nil, err
+		}
+		shadowBody, _ = json.Marshal(transformed)
+		targetURL = s.buildAdapterURL(strings.TrimSuffix(route.APIUrl, "/"), adapterName, route.Model)
+	} else {
+		shadowBody, _ = json.Marshal(reqData)
+		targetURL = buildRouteChatURL(route.APIUrl, route.Format)
+	}
+	return s.newRouteRequest(route, targetURL, shadowBody, "", func(req *http.Request) {
+		setProbeAuth(req, route)
+	})
+}
+
+// responseTokens 响应的输出 token 数：优先使用上游返回的 usage，没有时按响应文本估算
+func responseTokens(model string, output []byte, text string) int {
+	_, completion, _ := usageFromResponse(bytes.TrimSpace(output))
+	if completion == 0 {
+		_, completion, _ = usageFromEventStream(output)
+	}
+	if completion == 0 {
+		completion = tokenizer.Count(model, text)
+	}
+	return completion
+}
+
+// textSimilarity 按字符二元组计算两段文本的 Jaccard 相似度（中英文通用），都为空时返回 1
+func textSimilarity(a, b string) float64 {
+	setA, setB := runeBigrams(a), runeBigrams(b)
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+	common := 0
+	for k := range setA {
+		if setB[k] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(setA)+len(setB)-common)
+}
+
+// runeBigrams 忽略大小写和空白的字符二元组集合
+func runeBigrams(text string) map[string]bool {
+	runes := []rune(strings.ToLower(strings.Join(strings.Fields(text), " ")))
+	set := make(map[string]bool, len(runes))
+	if len(runes) == 1 {
+		set[string(runes)] = true
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		set[string(runes[i:i+2])] = true
+	}
+	return set
+}
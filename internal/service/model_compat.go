@@ -0,0 +1,213 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/config"
+)
+
+// 模型参数兼容：按模型名匹配兼容规则，修正发往 OpenAI 兼容接口的请求参数，
+// 例如 o1/o3 需要 max_completion_tokens、不接受 temperature，system 消息需要改为 developer
+
+// ValidateModelCompatRules 校验规则配置（保存前调用）
+func ValidateModelCompatRules(rules []config.ModelCompatRule) error {
+	for _, rule := range rules {
+		if len(compatPatterns(rule.Pattern)) == 0 {
+			return fmt.Errorf("rule %q: model pattern is required", rule.Name)
+		}
+		for _, pattern := range compatPatterns(rule.Pattern) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %q: invalid model pattern %q", rule.Name, pattern)
+			}
+		}
+		for from, to := range rule.Rename {
+			if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+				return fmt.Errorf("rule %q: rename requires both parameter names", rule.Name)
+			}
+		}
+		switch rule.SystemRole {
+		case "", "developer", "user":
+		default:
+			return fmt.Errorf("rule %q: invalid system role %q (allowed: developer, user)", rule.Name, rule.SystemRole)
+		}
+	}
+	return nil
+}
+
+// compatPatterns 拆分逗号分隔的模型名规则
+func compatPatterns(pattern string) []string {
+	var patterns []string
+	for _, p := range strings.Split(pattern, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// matchModelPattern 模型名是否匹配逗号分隔的规则，带供应商前缀时也匹配前缀之后的部分
+func matchModelPattern(pattern, model string) bool {
+	lower := strings.ToLower(strings.TrimSpace(model))
+	if lower == "" {
+		return false
+	}
+	base := lower
+	if i := strings.LastIndex(base, "/"); i >= 0 {
+		base = base[i+1:]
+	}
+	for _, p := range compatPatterns(pattern) {
+		if ok, _ := path.Match(p, lower); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchModelCompatRule 返回第一条匹配模型名的启用规则
+func matchModelCompatRule(rules []config.ModelCompatRule, model string) *config.ModelCompatRule {
+	for i := range rules {
+		if rules[i].Enabled && matchModelPattern(rules[i].Pattern, model) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// applyModelCompat 按模型兼容规则修正 OpenAI 格式的请求体；upstreamModel 为路由配置的上游模型名，
+// 为空时使用请求体中的模型名。Claude 格式的请求填充并校验 max_tokens 等参数（不受兼容规则开关影响），
+// 其他格式、没有匹配的规则或解析失败时原样返回
+func (s *ProxyService) applyModelCompat(format, upstreamModel string, body []byte) []byte {
+	if normalizeFormat(format) == "claude" {
+		return s.applyClaudeLimits(upstreamModel, body)
+	}
+	// 插件路由的请求体由插件生成，不修改
+	if adapters.IsPlugin(format) {
+		return body
+	}
+	if !s.config.ModelCompatEnabled || normalizeFormat(format) != "openai" {
+		return body
+	}
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		return body
+	}
+	model := strings.TrimSpace(upstreamModel)
+	if model == "" {
+		model, _ = reqData["model"].(string)
+	}
+	rule := matchModelCompatRule(s.config.ModelCompatRules, model)
+	if rule == nil || !fixModelCompatRequest(reqData, rule) {
+		return body
+	}
+	fixed, err := json.Marshal(reqData)
+	if err != nil {
+		return body
+	}
+	return fixed
+}
+
+// fixModelCompatRequest 按规则改名、删除参数并转换 system 消息，返回请求是否被修改
+func fixModelCompatRequest(reqData map[string]interface{}, rule *config.ModelCompatRule) bool {
+	changed := false
+	for from, to := range rule.Rename {
+		v, ok := reqData[from]
+		if !ok || from == to {
+			continue
+		}
+		if _, exists := reqData[to]; !exists {
+			reqData[to] = v
+		}
+		delete(reqData, from)
+		changed = true
+	}
+	for _, key := range rule.Remove {
+		if _, ok := reqData[key]; ok {
+			delete(reqData, key)
+			changed = true
+		}
+	}
+	messages, ok := reqData["messages"].([]interface{})
+	if !ok {
+		return changed
+	}
+	switch rule.SystemRole {
+	case "developer":
+		for _, msg := range messages {
+			if msgMap, ok := msg.(map[string]interface{}); ok && msgMap["role"] == "system" {
+				msgMap["role"] = "developer"
+				changed = true
+			}
+		}
+	case "user":
+		if merged, ok := mergeSystemIntoUser(messages); ok {
+			reqData["messages"] = merged
+			changed = true
+		}
+	}
+	return changed
+}
+
+// mergeSystemIntoUser 去掉 system/developer 消息，将其文本放到第一条 user 消息开头；
+// 没有 user 消息时改为 user 消息。没有 system 消息时 ok 为 false
+func mergeSystemIntoUser(messages []interface{}) (merged []interface{}, ok bool) {
+	var instructions []string
+	merged = make([]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		msgMap, isMap := msg.(map[string]interface{})
+		if isMap && (msgMap["role"] == "system" || msgMap["role"] == "developer") {
+			if text := messageText(msgMap["content"]); text != "" {
+				instructions = append(instructions, text)
+			}
+			ok = true
+			continue
+		}
+		merged = append(merged, msg)
+	}
+	if !ok || len(instructions) == 0 {
+		return merged, ok
+	}
+	prefix := strings.Join(instructions, "\n\n")
+	for _, msg := range merged {
+		msgMap, isMap := msg.(map[string]interface{})
+		if !isMap || msgMap["role"] != "user" {
+			continue
+		}
+		switch content := msgMap["content"].(type) {
+		case string:
+			msgMap["content"] = prefix + "\n\n" + content
+		case []interface{}:
+			part := map[string]interface{}{"type": "text", "text": prefix}
+			msgMap["content"] = append([]interface{}{part}, content...)
+		default:
+			msgMap["content"] = prefix
+		}
+		return merged, true
+	}
+	return append([]interface{}{map[string]interface{}{"role": "user", "content": prefix}}, merged...), true
+}
+
+// messageText 提取消息内容中的文本（字符串或 text 类型的内容块）
+func messageText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var parts []string
+		for _, part := range c {
+			if partMap, ok := part.(map[string]interface{}); ok {
+				if text, ok := partMap["text"].(string); ok && text != "" {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ModelMetadata 模型的附加信息，在各格式的模型列表接口中返回
+// 没有配置元数据的模型只有 Model 字段
+type ModelMetadata struct {
+	ID              int64     `json:"id"`
+	Model           string    `json:"model"`
+	DisplayName     string    `json:"display_name"`
+	Description     string    `json:"description"`
+	ContextWindow   int       `json:"context_window"`    // 上下文长度(token)，0 表示未知
+	MaxOutputTokens int       `json:"max_output_tokens"` // 最大输出(token)，0 表示未知
+	Capabilities    []string  `json:"capabilities"`      // 能力标签，如 vision、tools、reasoning
+	Hidden          bool      `json:"hidden"`            // 不在模型列表中显示（仍可请求）
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Name 显示名，未配置时使用模型名
+func (m ModelMetadata) Name() string {
+	if m.DisplayName != "" {
+		return m.DisplayName
+	}
+	return m.Model
+}
+
+// parseCapabilities 解析逗号分隔的能力标签，统一小写并去重
+func parseCapabilities(value string) []string {
+	capabilities := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, c := range strings.Split(value, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c != "" && !seen[c] {
+			seen[c] = true
+			capabilities = append(capabilities, c)
+		}
+	}
+	return capabilities
+}
+
+// GetModelMetadata 获取所有模型元数据
+func (s *RouteService) GetModelMetadata() ([]ModelMetadata, error) {
+	rows, err := s.db.Query(`
+		SELECT id, model, COALESCE(display_name, ''), COALESCE(description, ''), context_window, max_output_tokens,
+			COALESCE(capabilities, ''), hidden, created_at, updated_at
+		FROM model_metadata ORDER BY model`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]ModelMetadata, 0)
+	for rows.Next() {
+		var m ModelMetadata
+		var capabilities string
+		var hidden int
+		if err := rows.Scan(&m.ID, &m.Model, &m.DisplayName, &m.Description, &m.ContextWindow, &m.MaxOutputTokens,
+			&capabilities, &hidden, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		m.Capabilities = parseCapabilities(capabilities)
+		m.Hidden = hidden == 1
+		items = append(items, m)
+	}
+	return items, rows.Err()
+}
+
+// SaveModelMetadata 新增或更新模型元数据，同一模型只能有一条
+func (s *RouteService) SaveModelMetadata(m ModelMetadata) (ModelMetadata, error) {
+	m.Model = strings.TrimSpace(m.Model)
+	m.DisplayName = strings.TrimSpace(m.DisplayName)
+	if m.Model == "" {
+		return m, fmt.Errorf("model is required")
+	}
+	if m.ContextWindow < 0 || m.MaxOutputTokens < 0 {
+		return m, fmt.Errorf("token limits must not be negative")
+	}
+	m.Capabilities = parseCapabilities(strings.Join(m.Capabilities, ","))
+
+	var existingID int64
+	err := s.db.QueryRow(`SELECT id FROM model_metadata WHERE model = ?`, m.Model).Scan(&existingID)
+	if err == nil && existingID != m.ID {
+		if m.ID != 0 {
+			return m, fmt.Errorf("metadata for model %s already exists", m.Model)
+		}
+		m.ID = existingID
+	}
+
+	hidden := 0
+	if m.Hidden {
+		hidden = 1
+	}
+	capabilities := strings.Join(m.Capabilities, ",")
+	now := time.Now()
+	if m.ID == 0 {
+		_, err = s.db.Exec(`
+			INSERT INTO model_metadata (model, display_name, description, context_window, max_output_tokens, capabilities, hidden, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			m.Model, m.DisplayName, m.Description, m.ContextWindow, m.MaxOutputTokens, capabilities, hidden, now, now)
+		if err == nil {
+			err = s.db.QueryRow(`SELECT id FROM model_metadata WHERE model = ?`, m.Model).Scan(&m.ID)
+		}
+	} else {
+		_, err = s.db.Exec(`
+			UPDATE model_metadata SET model = ?, display_name = ?, description = ?, context_window = ?, max_output_tokens = ?,
+				capabilities = ?, hidden = ?, updated_at = ?
+			WHERE id = ?`,
+			m.Model, m.DisplayName, m.Description, m.ContextWindow, m.MaxOutputTokens, capabilities, hidden, now, m.ID)
+	}
+	if err != nil {
+		log.Errorf("Failed to save model metadata: %v", err)
+		return m, err
+	}
+	m.UpdatedAt = now
+	log.Infof("Model metadata saved: %s (context=%d, max_output=%d, capabilities=%v, hidden=%v)",
+		m.Model, m.ContextWindow, m.MaxOutputTokens, m.Capabilities, m.Hidden)
+	return m, nil
+}
+
+// DeleteModelMetadata 删除模型元数据
+func (s *RouteService) DeleteModelMetadata(id int64) error {
+	result, err := s.db.Exec(`DELETE FROM model_metadata WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("model metadata not found: id=%d", id)
+	}
+	log.Infof("Model metadata deleted: id=%d", id)
+	return nil
+}
+
+// ListModels 模型列表接口返回的模型：redirectKeyword 非空时放在最前面
+// 跳过元数据标记为隐藏的模型和 visible 返回 false 的模型（visible 为 nil 表示不过滤）
+func (s *RouteService) ListModels(redirectKeyword string, visible func(model string) bool) ([]ModelMetadata, error) {
+	var models []string
+	var err error
+	if redirectKeyword != "" {
+		models, err = s.GetAvailableModelsWithRedirect(redirectKeyword)
+	} else {
+		models, err = s.GetAvailableModels()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 元数据读取失败时仍返回模型列表
+	metadata := make(map[string]ModelMetadata)
+	if items, err := s.GetModelMetadata(); err != nil {
+		log.Warnf("Failed to load model metadata: %v", err)
+	} else {
+		for _, m := range items {
+			metadata[m.Model] = m
+		}
+	}
+
+	result := make([]ModelMetadata, 0, len(models))
+	for _, model := range models {
+		m, ok := metadata[model]
+		if !ok {
+			m = ModelMetadata{Model: model}
+		}
+		if m.Hidden || (visible != nil && !visible(model)) {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
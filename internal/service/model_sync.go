@@ -0,0 +1,491 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 模型同步变更类型
+const (
+	ModelSyncAdd     = "add"     // 上游新增的模型：审批后在目标分组中创建路由
+	ModelSyncRemove  = "remove"  // 上游已下架的模型：审批后禁用对应路由
+	ModelSyncRestore = "restore" // 之前因下架被禁用的模型重新出现：审批后重新启用路由
+)
+
+// 模型同步变更状态
+const (
+	ModelSyncPending   = "pending"
+	ModelSyncApplied   = "applied"
+	ModelSyncDismissed = "dismissed" // 忽略后同一模型的同类变更不再提示
+)
+
+const (
+	modelSyncCheckInterval = time.Minute // 检查是否有来源到达同步时间的间隔
+	modelSyncMinInterval   = 10          // 最短同步间隔(分钟)
+	modelSyncErrorLimit    = 512         // 记录的错误信息最大长度
+)
+
+// ModelSyncSource 模型同步来源：使用路由的 API 地址和 Key 获取模型列表，变更应用到目标分组
+type ModelSyncSource struct {
+	ID              int64     `json:"id"`
+	RouteID         int64     `json:"route_id"`         // 提供 API 地址和 Key 的路由
+	Group           string    `json:"group"`            // 新建路由所在的分组，只对比该分组中同一 API 地址的路由
+	Format          string    `json:"format"`           // 新建路由的格式，为空时使用来源路由的格式
+	IntervalMinutes int       `json:"interval_minutes"` // 同步间隔(分钟)，0 表示只手动同步
+	Enabled         bool      `json:"enabled"`
+	LastSyncAt      int64     `json:"last_sync_at"`
+	LastError       string    `json:"last_error"`
+	Pending         int       `json:"pending"` // 待审批的变更数
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ModelSyncChange 同步发现的一项变更，审批后才会修改路由
+type ModelSyncChange struct {
+	ID         int64  `json:"id"`
+	SourceID   int64  `json:"source_id"`
+	Action     string `json:"action"`
+	Model      string `json:"model"`
+	RouteID    int64  `json:"route_id"` // remove/restore 对应的路由
+	Status     string `json:"status"`
+	DetectedAt int64  `json:"detected_at"`
+}
+
+// GetModelSyncSources 获取所有模型同步来源
+func (s *RouteService) GetModelSyncSources() ([]ModelSyncSource, error) {
+	rows, err := s.db.Query(`
+		SELECT id, route_id, COALESCE(target_group, ''), COALESCE(format, ''), interval_minutes, enabled,
+		       last_sync_at, COALESCE(last_error, ''), created_at, updated_at,
+		       (SELECT COUNT(*) FROM model_sync_changes c WHERE c.source_id = model_sync_sources.id AND c.status = ?)
+		FROM model_sync_sources ORDER BY id`, ModelSyncPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := make([]ModelSyncSource, 0)
+	for rows.Next() {
+		src, err := scanModelSyncSource(rows)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, rows.Err()
+}
+
+func scanModelSyncSource(row rowScanner) (ModelSyncSource, error) {
+	var src ModelSyncSource
+	var enabled int
+	err := row.Scan(&src.ID, &src.RouteID, &src.Group, &src.Format, &src.IntervalMinutes, &enabled,
+		&src.LastSyncAt, &src.LastError, &src.CreatedAt, &src.UpdatedAt, &src.Pending)
+	src.Enabled = enabled == 1
+	return src, err
+}
+
+// getModelSyncSource 按 ID 获取同步来源
+func (s *RouteService) getModelSyncSource(id int64) (*ModelSyncSource, error) {
+	src, err := scanModelSyncSource(s.db.QueryRow(`
+		SELECT id, route_id, COALESCE(target_group, ''), COALESCE(format, ''), interval_minutes, enabled,
+		       last_sync_at, COALESCE(last_error, ''), created_at, updated_at, 0
+		FROM model_sync_sources WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("model sync source not found: id=%d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
+
+// routeByIDAny 按 ID 获取路由（包括已禁用的路由）
+func (s *RouteService) routeByIDAny(id int64) (*database.ModelRoute, error) {
+	route, err := s.scanRoute(s.db.QueryRow(`SELECT `+routeColumns+` FROM model_routes WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("route not found: %d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
+
+// SaveModelSyncSource 新增或更新模型同步来源
+func (s *RouteService) SaveModelSyncSource(src ModelSyncSource) (ModelSyncSource, error) {
+	if _, err := s.routeByIDAny(src.RouteID); err != nil {
+		return src, err
+	}
+	src.Group = strings.TrimSpace(src.Group)
+	src.Format = strings.TrimSpace(src.Format)
+	if src.IntervalMinutes < 0 {
+		src.IntervalMinutes = 0
+	} else if src.IntervalMinutes > 0 && src.IntervalMinutes < modelSyncMinInterval {
+		src.IntervalMinutes = modelSyncMinInterval
+	}
+
+	enabled := 0
+	if src.Enabled {
+		enabled = 1
+	}
+	now := time.Now()
+	var err error
+	if src.ID == 0 {
+		_, err = s.db.Exec(`
+			INSERT INTO model_sync_sources (route_id, target_group, format, interval_minutes, enabled, last_sync_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, 0, ?, ?)`,
+			src.RouteID, src.Group, src.Format, src.IntervalMinutes, enabled, now, now)
+		if err == nil {
+			err = s.db.QueryRow(`SELECT MAX(id) FROM model_sync_sources WHERE route_id = ?`, src.RouteID).Scan(&src.ID)
+			src.CreatedAt = now
+		}
+	} else {
+		var result sql.Result
+		result, err = s.db.Exec(`
+			UPDATE model_sync_sources SET route_id = ?, target_group = ?, format = ?, interval_minutes = ?, enabled = ?, updated_at = ?
+			WHERE id = ?`,
+			src.RouteID, src.Group, src.Format, src.IntervalMinutes, enabled, now, src.ID)
+		if err == nil {
+			if rows, _ := result.RowsAffected(); rows == 0 {
+				return src, fmt.Errorf("model sync source not found: id=%d", src.ID)
+			}
+		}
+	}
+	if err != nil {
+		log.Errorf("Failed to save model sync source: %v", err)
+		return src, err
+	}
+	src.UpdatedAt = now
+	log.Infof("Model sync source saved: id=%d, route=%d -> group %q (every %d min, enabled=%v)",
+		src.ID, src.RouteID, src.Group, src.IntervalMinutes, src.Enabled)
+	return src, nil
+}
+
+// DeleteModelSyncSource 删除同步来源及其变更记录（已创建的路由保留）
+func (s *RouteService) DeleteModelSyncSource(id int64) error {
+	result, err := s.db.Exec(`DELETE FROM model_sync_sources WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("model sync source not found: id=%d", id)
+	}
+	if _, err := s.db.Exec(`DELETE FROM model_sync_changes WHERE source_id = ?`, id); err != nil {
+		log.Warnf("Failed to delete model sync changes: %v", err)
+	}
+	log.Infof("Model sync source deleted: id=%d", id)
+	return nil
+}
+
+// GetModelSyncChanges 获取同步来源待审批的变更，sourceID 为 0 时返回所有来源
+func (s *RouteService) GetModelSyncChanges(sourceID int64) ([]ModelSyncChange, error) {
+	query := `SELECT id, source_id, action, model, route_id, status, detected_at
+		FROM model_sync_changes WHERE status = ?`
+	args := []interface{}{ModelSyncPending}
+	if sourceID > 0 {
+		query += ` AND source_id = ?`
+		args = append(args, sourceID)
+	}
+	query += ` ORDER BY source_id, action, model`
+	return s.queryModelSyncChanges(query, args...)
+}
+
+func (s *RouteService) queryModelSyncChanges(query string, args ...interface{}) ([]ModelSyncChange, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]ModelSyncChange, 0)
+	for rows.Next() {
+		var c ModelSyncChange
+		if err := rows.Scan(&c.ID, &c.SourceID, &c.Action, &c.Model, &c.RouteID, &c.Status, &c.DetectedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// diffModelSync 对比上游模型列表和目标分组中使用同一 API 地址的路由，返回新的待审批变更
+func (s *RouteService) diffModelSync(src *ModelSyncSource, cred *database.ModelRoute, models []string) ([]ModelSyncChange, error) {
+	routes, err := s.GetAllRoutes()
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.queryModelSyncChanges(`SELECT id, source_id, action, model, route_id, status, detected_at
+		FROM model_sync_changes WHERE source_id = ? AND status <> ?`, src.ID, ModelSyncPending)
+	if err != nil {
+		return nil, err
+	}
+	dismissed := make(map[string]bool)
+	removedRoutes := make(map[int64]bool) // 因下架被禁用的路由
+	for _, c := range history {
+		switch {
+		case c.Status == ModelSyncDismissed:
+			dismissed[c.Action+"\x00"+c.Model] = true
+		case c.Action == ModelSyncRemove:
+			removedRoutes[c.RouteID] = true
+		case c.Action == ModelSyncRestore:
+			delete(removedRoutes, c.RouteID)
+		}
+	}
+
+	remote := make(map[string]bool, len(models))
+	for _, m := range models {
+		remote[m] = true
+	}
+
+	now := time.Now().Unix()
+	changes := make([]ModelSyncChange, 0)
+	add := func(action, model string, routeID int64) {
+		if dismissed[action+"\x00"+model] {
+			return
+		}
+		changes = append(changes, ModelSyncChange{
+			SourceID: src.ID, Action: action, Model: model, RouteID: routeID,
+			Status: ModelSyncPending, DetectedAt: now,
+		})
+	}
+
+	existing := make(map[string]bool)
+	for _, route := range routes {
+		if route.Group != src.Group || route.APIUrl != cred.APIUrl {
+			continue
+		}
+		// 设置了上游模型名的路由按上游模型名对比
+		model := route.Model
+		if route.UpstreamModel != "" {
+			model = route.UpstreamModel
+		}
+		existing[model] = true
+		switch {
+		case route.Enabled && !remote[model]:
+			add(ModelSyncRemove, model, route.ID)
+		case !route.Enabled && remote[model] && removedRoutes[route.ID]:
+			add(ModelSyncRestore, model, route.ID)
+		}
+	}
+	for _, m := range models {
+		if !existing[m] {
+			add(ModelSyncAdd, m, 0)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Action != changes[j].Action {
+			return changes[i].Action < changes[j].Action
+		}
+		return changes[i].Model < changes[j].Model
+	})
+	return changes, nil
+}
+
+// saveModelSyncResult 用本次同步的结果替换来源待审批的变更，并记录同步时间和错误
+func (s *RouteService) saveModelSyncResult(sourceID int64, changes []ModelSyncChange, syncErr error) error {
+	errMsg := ""
+	if syncErr != nil {
+		errMsg = syncErr.Error()
+		if len(errMsg) > modelSyncErrorLimit {
+			errMsg = errMsg[:modelSyncErrorLimit]
+		}
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE model_sync_sources SET last_sync_at = ?, last_error = ? WHERE id = ?`,
+		time.Now().Unix(), errMsg, sourceID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// 获取失败时保留上次的变更
+	if syncErr == nil {
+		if _, err := tx.Exec(`DELETE FROM model_sync_changes WHERE source_id = ? AND status = ?`, sourceID, ModelSyncPending); err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, c := range changes {
+			if _, err := tx.Exec(`
+				INSERT INTO model_sync_changes (source_id, action, model, route_id, status, detected_at)
+				VALUES (?, ?, ?, ?, ?, ?)`,
+				c.SourceID, c.Action, c.Model, c.RouteID, c.Status, c.DetectedAt); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// ApplyModelSyncChange 审批一项变更：新增模型创建路由，下架模型禁用路由，恢复的模型重新启用路由
+func (s *RouteService) ApplyModelSyncChange(id int64) error {
+	changes, err := s.queryModelSyncChanges(`SELECT id, source_id, action, model, route_id, status, detected_at
+		FROM model_sync_changes WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return fmt.Errorf("model sync change not found: id=%d", id)
+	}
+	c := changes[0]
+	if c.Status != ModelSyncPending {
+		return fmt.Errorf("model sync change %d is already %s", id, c.Status)
+	}
+
+	switch c.Action {
+	case ModelSyncAdd:
+		src, err := s.getModelSyncSource(c.SourceID)
+		if err != nil {
+			return err
+		}
+		cred, err := s.routeByIDAny(src.RouteID)
+		if err != nil {
+			return fmt.Errorf("source route of model sync %d: %v", src.ID, err)
+		}
+		format := src.Format
+		if format == "" {
+			format = cred.Format
+		}
+		if err := s.AddRoute(c.Model, c.Model, cred.APIUrl, cred.APIKey, src.Group, format); err != nil {
+			return err
+		}
+	case ModelSyncRemove:
+		if err := s.ToggleRoute(c.RouteID, false); err != nil {
+			return err
+		}
+	case ModelSyncRestore:
+		if err := s.ToggleRoute(c.RouteID, true); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown model sync action: %s", c.Action)
+	}
+
+	if _, err := s.db.Exec(`UPDATE model_sync_changes SET status = ? WHERE id = ?`, ModelSyncApplied, id); err != nil {
+		return err
+	}
+	log.Infof("Model sync change applied: %s %s (source %d)", c.Action, c.Model, c.SourceID)
+	return nil
+}
+
+// DismissModelSyncChange 忽略一项变更，之后同步不再提示同一模型的同类变更
+func (s *RouteService) DismissModelSyncChange(id int64) error {
+	result, err := s.db.Exec(`UPDATE model_sync_changes SET status = ? WHERE id = ? AND status = ?`,
+		ModelSyncDismissed, id, ModelSyncPending)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("pending model sync change not found: id=%d", id)
+	}
+	return nil
+}
+
+// ModelSyncer 按各来源的间隔定期获取上游模型列表并生成待审批的变更
+type ModelSyncer struct {
+	rs   *RouteService
+	ps   *ProxyService
+	stop chan struct{}
+
+	mu      sync.Mutex
+	running map[int64]bool
+}
+
+func NewModelSyncer(rs *RouteService, ps *ProxyService) *ModelSyncer {
+	return &ModelSyncer{rs: rs, ps: ps, stop: make(chan struct{}), running: make(map[int64]bool)}
+}
+
+// Start 启动后台同步
+func (m *ModelSyncer) Start() {
+	go func() {
+		ticker := time.NewTicker(modelSyncCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.syncDue()
+			}
+		}
+	}()
+}
+
+// Stop 停止后台同步
+func (m *ModelSyncer) Stop() {
+	close(m.stop)
+}
+
+// syncDue 同步所有到达同步时间的来源
+func (m *ModelSyncer) syncDue() {
+	sources, err := m.rs.GetModelSyncSources()
+	if err != nil {
+		log.Warnf("Model sync: failed to load sources: %v", err)
+		return
+	}
+	now := time.Now().Unix()
+	for i := range sources {
+		src := &sources[i]
+		if !src.Enabled || src.IntervalMinutes <= 0 || now-src.LastSyncAt < int64(src.IntervalMinutes)*60 {
+			continue
+		}
+		if _, err := m.Sync(src.ID); err != nil {
+			log.Warnf("Model sync: source %d failed: %v", src.ID, err)
+		}
+	}
+}
+
+// Sync 立即同步一个来源，返回待审批的变更
+func (m *ModelSyncer) Sync(sourceID int64) ([]ModelSyncChange, error) {
+	m.mu.Lock()
+	if m.running[sourceID] {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("model sync source %d is already syncing", sourceID)
+	}
+	m.running[sourceID] = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, sourceID)
+		m.mu.Unlock()
+	}()
+
+	src, err := m.rs.getModelSyncSource(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	changes, syncErr := m.diff(src)
+	if err := m.rs.saveModelSyncResult(src.ID, changes, syncErr); err != nil {
+		return nil, err
+	}
+	if syncErr != nil {
+		return nil, syncErr
+	}
+	log.Infof("Model sync: source %d found %d change(s) pending approval", src.ID, len(changes))
+	return m.rs.GetModelSyncChanges(src.ID)
+}
+
+// diff 获取来源路由的上游模型列表（跳过缓存）并与目标分组对比
+func (m *ModelSyncer) diff(src *ModelSyncSource) ([]ModelSyncChange, error) {
+	cred, err := m.rs.routeByIDAny(src.RouteID)
+	if err != nil {
+		return nil, err
+	}
+	models, err := m.ps.GetRemoteModels(cred.APIUrl, cred.APIKey, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		// 上游返回空列表时不生成下架变更，避免误删全部路由
+		return nil, fmt.Errorf("provider returned an empty model list")
+	}
+	return m.rs.diffModelSync(src, cred, models)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// modelsCacheKey 模型列表缓存键：API 地址 + Key 哈希（不保存明文 Key）
+func modelsCacheKey(apiUrl, apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return strings.TrimSpace(apiUrl) + "#" + hex.EncodeToString(sum[:8])
+}
+
+// modelsCacheTTL 模型列表缓存有效期
+func (s *ProxyService) modelsCacheTTL() time.Duration {
+	if s.config == nil || s.config.ModelsCacheTTLMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(s.config.ModelsCacheTTLMinutes) * time.Minute
+}
+
+// GetRemoteModels 获取上游模型列表，优先使用缓存
+// forceRefresh 为 true 时跳过缓存直接请求上游；上游不可用时回退到过期缓存（离线可用）
+func (s *ProxyService) GetRemoteModels(apiUrl, apiKey string, forceRefresh bool) ([]string, error) {
+	apiKey = firstAPIKey(apiKey) // 多 Key 路由使用第一个 Key
+	cacheKey := modelsCacheKey(apiUrl, apiKey)
+
+	cached, fetchedAt, found, err := s.routeService.GetCachedModels(cacheKey)
+	if err != nil {
+		log.Warnf("Failed to read models cache for %s: %v", apiUrl, err)
+	}
+	if found && !forceRefresh && time.Since(fetchedAt) < s.modelsCacheTTL() {
+		log.Debugf("Models cache hit for %s (%d models, fetched at %s)", apiUrl, len(cached), fetchedAt.Format(time.RFC3339))
+		return cached, nil
+	}
+
+	models, err := s.FetchRemoteModels(apiUrl, apiKey)
+	if err != nil {
+		if found {
+			log.Warnf("Failed to refresh models from %s, using cached list from %s: %v", apiUrl, fetchedAt.Format(time.RFC3339), err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	if err := s.routeService.SaveCachedModels(cacheKey, apiUrl, models); err != nil {
+		log.Warnf("Failed to save models cache for %s: %v", apiUrl, err)
+	}
+	return models, nil
+}
+
+// WarmModelsCache 预热所有已启用路由的模型列表缓存（相同 API 地址和 Key 只请求一次）
+func (s *ProxyService) WarmModelsCache() {
+	routes, err := s.routeService.GetAllRoutes()
+	if err != nil {
+		log.Warnf("Models cache warmup: failed to load routes: %v", err)
+		return
+	}
+
+	seen := make(map[string]bool)
+	warmed := 0
+	for _, route := range routes {
+		if !route.Enabled || route.APIUrl == "" {
+			continue
+		}
+		apiKey := firstAPIKey(route.APIKey)
+		cacheKey := modelsCacheKey(route.APIUrl, apiKey)
+		if seen[cacheKey] {
+			continue
+		}
+		seen[cacheKey] = true
+
+		if _, fetchedAt, found, _ := s.routeService.GetCachedModels(cacheKey); found && time.Since(fetchedAt) < s.modelsCacheTTL() {
+			continue
+		}
+		if _, err := s.GetRemoteModels(route.APIUrl, apiKey, true); err != nil {
+			log.Debugf("Models cache warmup skipped %s: %v", route.APIUrl, err)
+			continue
+		}
+		warmed++
+	}
+	log.Infof("Models cache warmup completed: %d provider(s) refreshed", warmed)
+}
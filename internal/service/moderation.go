@@ -0,0 +1,338 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"openai-router-go/internal/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 内容审查规则的处理方式
+const (
+	ModerationMask  = "mask"  // 将命中的内容替换为 [REDACTED] 后继续发送
+	ModerationBlock = "block" // 拒绝请求，不发往上游
+	ModerationLog   = "log"   // 只记录，不修改请求
+)
+
+// 内容审查规则类型
+const (
+	ModerationTypeKeyword = "keyword"
+	ModerationTypeRegex   = "regex"
+	ModerationTypePII     = "pii"
+)
+
+// builtinPIIPatterns 内置 PII 规则
+var builtinPIIPatterns = map[string][]*regexp.Regexp{
+	"email": {
+		regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	},
+	"credit_card": {
+		regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+	},
+	"api_key": builtinRedactionPatterns,
+	"phone": {
+		// 中国大陆手机号、带国际区号的号码
+		regexp.MustCompile(`(?:\+?86[ \-]?)?\b1[3-9]\d{9}\b`),
+		regexp.MustCompile(`\+\d{1,3}[ \-]?\(?\d{1,4}\)?[ \-]?\d{3,4}[ \-]?\d{3,4}\b`),
+	},
+}
+
+// builtinPIIValidators 过滤内置规则的误报
+var builtinPIIValidators = map[string]func(string) bool{
+	"credit_card": luhnValid,
+}
+
+// moderationSkipKeys 不检查的 JSON 字段（模型名、角色、ID、签名、二进制数据等）
+var moderationSkipKeys = map[string]bool{
+	"model": true, "role": true, "type": true, "id": true, "name": true,
+	"tool_call_id": true, "tool_use_id": true, "signature": true, "thoughtSignature": true,
+	"data": true, "mime_type": true, "mimeType": true, "media_type": true,
+}
+
+// ModerationViolation 请求命中的审查规则，不记录命中的原文
+type ModerationViolation struct {
+	Rule    string `json:"rule"`
+	Action  string `json:"action"`
+	Matches int    `json:"matches"`
+}
+
+// ModerationResult 审查结果，Body 为处理后的请求体（mask/block 规则命中的内容已替换）
+type ModerationResult struct {
+	Body       []byte
+	Violations []ModerationViolation
+	Blocked    *ModerationViolation // 命中的第一条 block 规则，为 nil 表示放行
+}
+
+type moderationRule struct {
+	name     string
+	action   string
+	patterns []*regexp.Regexp
+	validate func(string) bool
+}
+
+// moderator 按配置的规则检查请求内容，规则变化时重新编译
+type moderator struct {
+	mu         sync.Mutex
+	source     []config.ModerationRule
+	rules      []moderationRule
+	violations sync.Map // 请求ID -> []ModerationViolation，保存 Trace 时取出
+}
+
+func newModerator() *moderator {
+	return &moderator{}
+}
+
+// compileModerationRule 编译一条规则，未启用的规则返回 nil
+func compileModerationRule(rule config.ModerationRule) (*moderationRule, error) {
+	if !rule.Enabled {
+		return nil, nil
+	}
+	compiled := &moderationRule{name: rule.Name, action: rule.Action}
+	if compiled.name == "" {
+		compiled.name = rule.Pattern
+	}
+	switch rule.Action {
+	case ModerationMask, ModerationBlock, ModerationLog:
+	default:
+		return nil, fmt.Errorf("rule %q: invalid action %q", compiled.name, rule.Action)
+	}
+
+	switch rule.Type {
+	case ModerationTypeKeyword:
+		var words []string
+		for _, word := range strings.FieldsFunc(rule.Pattern, func(r rune) bool { return r == ',' || r == '\n' }) {
+			if word = strings.TrimSpace(word); word != "" {
+				words = append(words, regexp.QuoteMeta(word))
+			}
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("rule %q: no keywords", compiled.name)
+		}
+		compiled.patterns = []*regexp.Regexp{regexp.MustCompile(`(?i)` + strings.Join(words, "|"))}
+	case ModerationTypeRegex:
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", compiled.name, err)
+		}
+		if re.MatchString("") {
+			return nil, fmt.Errorf("rule %q: pattern matches empty text", compiled.name)
+		}
+		compiled.patterns = []*regexp.Regexp{re}
+	case ModerationTypePII:
+		patterns, ok := builtinPIIPatterns[rule.Pattern]
+		if !ok {
+			return nil, fmt.Errorf("rule %q: unknown PII pattern %q", compiled.name, rule.Pattern)
+		}
+		compiled.patterns = patterns
+		compiled.validate = builtinPIIValidators[rule.Pattern]
+	default:
+		return nil, fmt.Errorf("rule %q: invalid type %q", compiled.name, rule.Type)
+	}
+	return compiled, nil
+}
+
+// ValidateModerationRules 校验规则配置（保存前调用）
+func ValidateModerationRules(rules []config.ModerationRule) error {
+	for _, rule := range rules {
+		rule.Enabled = true
+		if _, err := compileModerationRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load 规则配置变化时重新编译，无效的规则跳过
+func (m *moderator) load(rules []config.ModerationRule) []moderationRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.source != nil && reflect.DeepEqual(m.source, rules) {
+		return m.rules
+	}
+
+	compiled := make([]moderationRule, 0, len(rules))
+	for _, rule := range rules {
+		r, err := compileModerationRule(rule)
+		if err != nil {
+			log.Warnf("[Moderation] Ignoring invalid rule: %v", err)
+			continue
+		}
+		if r != nil {
+			compiled = append(compiled, *r)
+		}
+	}
+	m.source = append([]config.ModerationRule{}, rules...)
+	m.rules = compiled
+	return compiled
+}
+
+// check 检查请求体中所有文本字段，返回处理结果
+func (m *moderator) check(rules []config.ModerationRule, body []byte) ModerationResult {
+	result := ModerationResult{Body: body}
+	compiled := m.load(rules)
+	if len(compiled) == 0 {
+		return result
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return result
+	}
+
+	counts := make([]int, len(compiled))
+	changed := false
+	payload = walkModerationStrings(payload, "", func(text string) string {
+		for i, rule := range compiled {
+			redacted, matches := rule.apply(text)
+			if matches == 0 {
+				continue
+			}
+			counts[i] += matches
+			if rule.action != ModerationLog {
+				text = redacted
+				changed = true
+			}
+		}
+		return text
+	})
+
+	for i, rule := range compiled {
+		if counts[i] == 0 {
+			continue
+		}
+		violation := ModerationViolation{Rule: rule.name, Action: rule.action, Matches: counts[i]}
+		result.Violations = append(result.Violations, violation)
+		if rule.action == ModerationBlock && result.Blocked == nil {
+			result.Blocked = &violation
+		}
+	}
+	if changed {
+		if data, err := json.Marshal(payload); err == nil {
+			result.Body = data
+		}
+	}
+	return result
+}
+
+// apply 替换文本中命中的内容，返回替换后的文本和命中次数
+// 正则的第一个分组（如果有）为保留的前缀，与 Trace 脱敏规则一致
+func (r *moderationRule) apply(text string) (string, int) {
+	matches := 0
+	for _, re := range r.patterns {
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			if r.validate != nil && !r.validate(match) {
+				return match
+			}
+			matches++
+			sub := re.FindStringSubmatchIndex(match)
+			if len(sub) >= 4 && sub[2] >= 0 {
+				return match[:sub[3]] + redactedText
+			}
+			return redactedText
+		})
+	}
+	return text, matches
+}
+
+// walkModerationStrings 遍历 JSON 中的字符串值并替换，跳过不含用户内容的字段和 data: URL
+func walkModerationStrings(v interface{}, key string, fn func(string) string) interface{} {
+	switch val := v.(type) {
+	case string:
+		if moderationSkipKeys[key] || strings.HasPrefix(val, "data:") {
+			return val
+		}
+		return fn(val)
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = walkModerationStrings(item, k, fn)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = walkModerationStrings(item, key, fn)
+		}
+	}
+	return v
+}
+
+// luhnValid 信用卡号 Luhn 校验（忽略空格和连字符）
+func luhnValid(number string) bool {
+	sum, digits := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+// record 保存请求的审查记录，写入 Trace 时取出
+func (m *moderator) record(requestID string, violations []ModerationViolation) {
+	if requestID != "" && len(violations) > 0 {
+		m.violations.Store(requestID, violations)
+	}
+}
+
+// take 取出请求的审查记录（JSON），没有时返回空字符串
+func (m *moderator) take(requestID string) string {
+	v, ok := m.violations.LoadAndDelete(requestID)
+	if !ok {
+		return ""
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// ModerateRequest 内容审查开启时检查请求体，命中的规则会记录到该请求的 Trace
+func (s *ProxyService) ModerateRequest(requestID string, body []byte) ModerationResult {
+	if s.config == nil || !s.config.ModerationEnabled {
+		return ModerationResult{Body: body}
+	}
+	result := s.moderator.check(s.config.ModerationRules, body)
+	for _, v := range result.Violations {
+		log.Warnf("[Moderation] [%s] Rule %q matched %d time(s), action=%s", requestID, v.Rule, v.Matches, v.Action)
+	}
+	s.moderator.record(requestID, result.Violations)
+	return result
+}
+
+// FinishModeration 请求结束时清理未写入 Trace 的审查记录
+func (s *ProxyService) FinishModeration(requestID string) {
+	s.moderator.violations.Delete(requestID)
+}
+
+// RecordModerationBlock 记录被审查规则拒绝的请求（请求日志和 Trace），Trace 中保存的是脱敏后的请求体
+func (s *ProxyService) RecordModerationBlock(requestID, remoteIP, style string, result ModerationResult) string {
+	var reqData map[string]interface{}
+	json.Unmarshal(result.Body, &reqData)
+	model, _ := reqData["model"].(string)
+	isStream, _ := reqData["stream"].(bool)
+	message := fmt.Sprintf("request blocked by moderation rule %q", result.Blocked.Rule)
+
+	s.routeService.LogRequestFull(RequestLogParams{
+		RequestID:    requestID,
+		Model:        model,
+		RemoteIP:     remoteIP,
+		Success:      false,
+		ErrorMessage: message,
+		Style:        style,
+		IsStream:     isStream,
+	})
+	s.SaveTraceIfEnabled(requestID, remoteIP, model, "", "", string(result.Body), "", 0, 0, 0, false, message, style, isStream, 0)
+	return message
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"openai-router-go/internal/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 通知事件
+const (
+	NotifyRouteUnhealthy  = "route_unhealthy"   // 路由连续探测失败
+	NotifyRouteRecovered  = "route_recovered"   // 不健康的路由探测恢复
+	NotifyAllRoutesFailed = "all_routes_failed" // 模型的所有路由（含 Fallback）都失败
+	NotifyBudgetWarning   = "budget_warning"    // 今日 token 用量达到预算的 90%
+	NotifyBudgetExceeded  = "budget_exceeded"   // 今日 token 用量超过预算
+	NotifyTest            = "test"              // 设置页发送的测试通知
+	NotifyUsageReport     = "usage_report"      // 定期用量报告
+)
+
+// NotifyEventTypes 可在设置中开关的事件
+var NotifyEventTypes = []string{NotifyRouteUnhealthy, NotifyRouteRecovered, NotifyAllRoutesFailed, NotifyBudgetWarning, NotifyBudgetExceeded}
+
+// NotificationEventName 桌面通知发给前端的 Wails 事件名
+const NotificationEventName = "anyproxy:notification"
+
+const (
+	notifyWebhookTimeout = 10 * time.Second
+	budgetWarningPercent = 90
+)
+
+// Notification 一条通知
+type Notification struct {
+	Event   string                 `json:"event"`
+	Level   string                 `json:"level"` // info, warning, error
+	Title   string                 `json:"title"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Data    interface{}            `json:"data,omitempty"` // 附加数据，只在通用 JSON Webhook 中发送（如用量报告）
+	Time    string                 `json:"time"`
+}
+
+// Notifier 按配置将事件发送到 Webhook 和桌面通知，同一事件（同一路由/模型）在冷却时间内只通知一次
+// 配置在每次发送时重新读取，设置页修改后无需重启
+type Notifier struct {
+	config *config.Config
+	client *http.Client
+
+	mu      sync.Mutex
+	desktop func(Notification)   // 桌面通知，由 GUI 设置
+	lastAt  map[string]time.Time // 事件键 -> 上次通知时间
+
+	budgetMu    sync.Mutex
+	budgetDay   string
+	budgetUsed  int64
+	budgetFired map[string]bool // 今日已通知的预算事件
+}
+
+func NewNotifier(cfg *config.Config) *Notifier {
+	return &Notifier{
+		config: cfg,
+		client: &http.Client{
+			Timeout: notifyWebhookTimeout,
+			Transport: &http.Transport{
+				// 与代理请求一样按设置决定是否使用系统代理
+				Proxy: func(req *http.Request) (*url.URL, error) {
+					if cfg.ProxyEnabled {
+						return http.ProxyFromEnvironment(req)
+					}
+					return nil, nil
+				},
+			},
+		},
+		lastAt: make(map[string]time.Time),
+	}
+}
+
+// SetDesktopHandler 设置桌面通知的显示方式
+func (n *Notifier) SetDesktopHandler(handler func(Notification)) {
+	n.mu.Lock()
+	n.desktop = handler
+	n.mu.Unlock()
+}
+
+// eventEnabled 事件是否开启通知，未配置的事件默认开启
+func (n *Notifier) eventEnabled(event string) bool {
+	if enabled, ok := n.config.NotifyEvents[event]; ok {
+		return enabled
+	}
+	return true
+}
+
+// Notify 发送通知，key 区分同一事件的不同对象（路由ID、模型名），用于冷却去重
+func (n *Notifier) Notify(event, key, level, title, message string, fields map[string]interface{}) {
+	if n == nil || !n.config.NotificationsEnabled || !n.eventEnabled(event) {
+		return
+	}
+
+	cooldown := time.Duration(n.config.NotifyCooldownMinutes) * time.Minute
+	dedupKey := event + "|" + key
+	n.mu.Lock()
+	if last, ok := n.lastAt[dedupKey]; ok && time.Since(last) < cooldown {
+		n.mu.Unlock()
+		return
+	}
+	n.lastAt[dedupKey] = time.Now()
+	n.mu.Unlock()
+
+	n.send(Notification{
+		Event:   event,
+		Level:   level,
+		Title:   title,
+		Message: message,
+		Fields:  fields,
+		Time:    time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// SendTest 向所有启用的 Webhook 和桌面（开启时）发送测试通知（不检查事件开关和冷却），返回各 Webhook 的发送错误
+func (n *Notifier) SendTest() map[string]string {
+	note := Notification{
+		Event:   NotifyTest,
+		Level:   "info",
+		Title:   "AnyProxyAi test notification",
+		Message: "Notifications are working.",
+		Time:    time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if n.config.NotifyDesktop {
+		n.showDesktop(note)
+	}
+	errs := make(map[string]string)
+	for _, hook := range n.config.NotifyWebhooks {
+		if !hook.Enabled || hook.URL == "" {
+			continue
+		}
+		if err := n.post(hook, note); err != nil {
+			errs[webhookName(hook)] = err.Error()
+		}
+	}
+	return errs
+}
+
+// Deliver 同步发送到订阅了该事件的所有启用的 Webhook（不检查通知开关和冷却），返回各 Webhook 的发送错误
+func (n *Notifier) Deliver(note Notification) map[string]string {
+	errs := make(map[string]string)
+	for _, hook := range n.config.NotifyWebhooks {
+		if !hook.Enabled || hook.URL == "" || !webhookWants(hook, note.Event) {
+			continue
+		}
+		if err := n.post(hook, note); err != nil {
+			errs[webhookName(hook)] = err.Error()
+		}
+	}
+	return errs
+}
+
+// send 显示桌面通知，并异步发送到订阅了该事件的 Webhook
+func (n *Notifier) send(note Notification) {
+	log.Warnf("[Notify] %s: %s - %s", note.Event, note.Title, note.Message)
+	if n.config.NotifyDesktop {
+		n.showDesktop(note)
+	}
+	for _, hook := range n.config.NotifyWebhooks {
+		if !hook.Enabled || hook.URL == "" || !webhookWants(hook, note.Event) {
+			continue
+		}
+		go func(hook config.NotifyWebhook) {
+			if err := n.post(hook, note); err != nil {
+				log.Warnf("[Notify] Webhook %s failed: %v", webhookName(hook), err)
+			}
+		}(hook)
+	}
+}
+
+func (n *Notifier) showDesktop(note Notification) {
+	n.mu.Lock()
+	desktop := n.desktop
+	n.mu.Unlock()
+	if desktop != nil {
+		desktop(note)
+	}
+}
+
+func webhookWants(hook config.NotifyWebhook, event string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, e := range hook.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func webhookName(hook config.NotifyWebhook) string {
+	if hook.Name != "" {
+		return hook.Name
+	}
+	return hook.URL
+}
+
+// webhookPayload 按 Webhook 类型构建请求体
+func webhookPayload(hookType string, note Notification) interface{} {
+	text := note.Message
+	if len(note.Fields) > 0 {
+		keys := make([]string, 0, len(note.Fields))
+		for k, v := range note.Fields {
+			keys = append(keys, fmt.Sprintf("%s: %v", k, v))
+		}
+		sort.Strings(keys)
+		text += "\n" + strings.Join(keys, "\n")
+	}
+	switch hookType {
+	case "slack":
+		return map[string]interface{}{"text": fmt.Sprintf("*%s*\n%s", note.Title, text)}
+	case "discord":
+		// Discord 消息最多 2000 个字符
+		content := []rune(fmt.Sprintf("**%s**\n%s", note.Title, text))
+		if len(content) > 2000 {
+			content = content[:2000]
+		}
+		return map[string]interface{}{"content": string(content)}
+	}
+	return note
+}
+
+// post 发送一次 Webhook 请求
+func (n *Notifier) post(hook config.NotifyWebhook, note Notification) error {
+	data, err := json.Marshal(webhookPayload(hook.Type, note))
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(hook.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// addTokens 累计今日 token 用量，达到预算的 90% 和 100% 时各通知一次；
+// today 在每天第一次调用时从数据库读取今日已有用量
+func (n *Notifier) addTokens(tokens int64, today func() int64) {
+	if n == nil || n.config.DailyTokenBudget <= 0 || tokens <= 0 {
+		return
+	}
+	budget := n.config.DailyTokenBudget
+
+	n.budgetMu.Lock()
+	day := time.Now().Format("2006-01-02")
+	if n.budgetDay != day {
+		// 新的一天：今日用量已包含本批刚写入的日志
+		n.budgetDay = day
+		n.budgetUsed = today()
+		n.budgetFired = make(map[string]bool)
+	} else {
+		n.budgetUsed += tokens
+	}
+	used := n.budgetUsed
+	var event string
+	switch {
+	case used >= budget && !n.budgetFired[NotifyBudgetExceeded]:
+		event = NotifyBudgetExceeded
+		n.budgetFired[NotifyBudgetExceeded] = true
+		n.budgetFired[NotifyBudgetWarning] = true
+	case used*100 >= budget*budgetWarningPercent && !n.budgetFired[NotifyBudgetWarning]:
+		event = NotifyBudgetWarning
+		n.budgetFired[NotifyBudgetWarning] = true
+	}
+	n.budgetMu.Unlock()
+
+	fields := map[string]interface{}{"used_tokens": used, "daily_budget": budget}
+	switch event {
+	case NotifyBudgetExceeded:
+		n.Notify(event, day, "error", "Daily token budget exceeded",
+			fmt.Sprintf("%d tokens used today, budget is %d", used, budget), fields)
+	case NotifyBudgetWarning:
+		n.Notify(event, day, "warning", fmt.Sprintf("Daily token budget %d%% consumed", used*100/budget),
+			fmt.Sprintf("%d of %d tokens used today", used, budget), fields)
+	}
+}
+
+// ValidateNotifyWebhooks 检查 Webhook 配置
+func ValidateNotifyWebhooks(hooks []config.NotifyWebhook) error {
+	for i, hook := range hooks {
+		switch hook.Type {
+		case "slack", "discord", "generic":
+		default:
+			return fmt.Errorf("webhook %d: unsupported type %q", i+1, hook.Type)
+		}
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %d: invalid URL %q", i+1, hook.URL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ollamaDefaultPort Ollama 默认监听端口
+const ollamaDefaultPort = "11434"
+
+// isOllamaFormat 路由格式是否为 Ollama 原生接口
+func isOllamaFormat(format string) bool {
+	return strings.EqualFold(strings.TrimSpace(format), "ollama")
+}
+
+// ollamaBaseURL 去掉 /v1、/api、/api/chat 等后缀，得到 Ollama 服务根地址
+func ollamaBaseURL(apiURL string) string {
+	base := strings.TrimSuffix(strings.TrimSpace(apiURL), "/")
+	for _, suffix := range []string{"/api/chat", "/api/tags", "/api", "/v1"} {
+		if strings.HasSuffix(base, suffix) {
+			return strings.TrimSuffix(base, suffix)
+		}
+	}
+	return base
+}
+
+// buildOllamaChatURL 构建 Ollama /api/chat URL
+func buildOllamaChatURL(apiURL string) string {
+	return ollamaBaseURL(apiURL) + "/api/chat"
+}
+
+// isLocalURL 判断上游地址是否指向本机
+func isLocalURL(apiURL string) bool {
+	if !strings.Contains(apiURL, "://") {
+		apiURL = "http://" + apiURL
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// looksLikeOllamaURL 根据默认端口或 /api 路径判断是否为 Ollama 原生接口地址
+func looksLikeOllamaURL(apiURL string) bool {
+	if !strings.Contains(apiURL, "://") {
+		apiURL = "http://" + apiURL
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return false
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	return u.Port() == ollamaDefaultPort || strings.HasSuffix(path, "/api")
+}
+
+// fetchOllamaModels 通过 Ollama /api/tags 获取本地已下载的模型
+func (s *ProxyService) fetchOllamaModels(apiURL string) ([]string, error) {
+	if !strings.Contains(apiURL, "://") {
+		apiURL = "http://" + apiURL
+	}
+	tagsURL := ollamaBaseURL(apiURL) + "/api/tags"
+	log.Infof("Fetching Ollama models from: %s", tagsURL)
+
+	resp, err := s.httpClient.Get(tagsURL)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Models []struct {
+			Name  string `json:"name"`
+			Model string `json:"model"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %v (body: %s)", err, string(body))
+	}
+
+	models := make([]string, 0, len(result.Models))
+	for _, m := range result.Models {
+		name := m.Name
+		if name == "" {
+			name = m.Model
+		}
+		if name != "" {
+			models = append(models, name)
+		}
+	}
+	log.Infof("Successfully fetched %d Ollama models", len(models))
+	return models, nil
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OpenRouter 集成：发送应用标识请求头，附加路由配置的供应商偏好（provider、transforms），
+// 从响应的 usage.cost 读取实际费用写入请求日志，从模型目录获取价格
+
+const (
+	openRouterReferer   = "https://github.com/loqwe/anyproxyai"
+	openRouterTitle     = "AnyProxyAi"
+	openRouterModelsURL = "https://openrouter.ai/api/v1/models"
+	openRouterCostLimit = 4 << 20          // 非流式响应中查找 usage.cost 时最多缓存的字节数
+	upstreamCostTTL     = 10 * time.Minute // 未写入日志的费用记录保留时间
+)
+
+// isOpenRouterURL 是否为 OpenRouter 的 API 地址
+func isOpenRouterURL(apiURL string) bool {
+	return strings.Contains(strings.ToLower(apiURL), "openrouter.ai")
+}
+
+// NormalizeProviderPrefs 校验路由的 OpenRouter 供应商偏好：JSON 对象，只允许 provider（对象）和 transforms（字符串数组）
+// 返回压缩后的 JSON，为空时返回空字符串
+func NormalizeProviderPrefs(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "{}" || raw == "null" {
+		return "", nil
+	}
+	var prefs map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &prefs); err != nil {
+		return "", fmt.Errorf("provider preferences must be a JSON object: %v", err)
+	}
+	for key, value := range prefs {
+		switch key {
+		case "provider":
+			var provider map[string]interface{}
+			if err := json.Unmarshal(value, &provider); err != nil {
+				return "", fmt.Errorf("provider must be a JSON object")
+			}
+		case "transforms":
+			var transforms []string
+			if err := json.Unmarshal(value, &transforms); err != nil {
+				return "", fmt.Errorf("transforms must be an array of strings")
+			}
+		default:
+			return "", fmt.Errorf("unsupported provider preference field %q (allowed: provider, transforms)", key)
+		}
+	}
+	if len(prefs) == 0 {
+		return "", nil
+	}
+	data, _ := json.Marshal(prefs)
+	return string(data), nil
+}
+
+// SetRouteProviderPrefs 设置路由发往 OpenRouter 时附加的供应商偏好
+func (s *RouteService) SetRouteProviderPrefs(id int64, prefs string) error {
+	normalized, err := NormalizeProviderPrefs(prefs)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(`UPDATE model_routes SET provider_prefs = ? WHERE id = ?`, normalized, id)
+	if err != nil {
+		log.Errorf("Failed to set route provider preferences: %v", err)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("route not found: id=%d", id)
+	}
+	log.Infof("Route provider preferences updated: id=%d -> %s", id, normalized)
+	return nil
+}
+
+// applyOpenRouter 发往 OpenRouter 的请求：补充应用标识请求头（路由自定义请求头优先），
+// 请求体附加路由的供应商偏好，并请求在 usage 中返回费用
+func applyOpenRouter(req *http.Request, route *database.ModelRoute) {
+	if route == nil || !isOpenRouterURL(req.URL.Host) {
+		return
+	}
+	if req.Header.Get("HTTP-Referer") == "" {
+		req.Header.Set("HTTP-Referer", openRouterReferer)
+	}
+	if req.Header.Get("X-Title") == "" {
+		req.Header.Set("X-Title", openRouterTitle)
+	}
+
+	if req.GetBody == nil {
+		return
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return
+	}
+	body, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return
+	}
+	var reqData map[string]json.RawMessage
+	if json.Unmarshal(body, &reqData) != nil {
+		return
+	}
+	// 只修改对话/补全请求，透传的其他接口（embeddings 等）保持原样
+	_, isChat := reqData["messages"]
+	_, isCompletion := reqData["prompt"]
+	if !isChat && !isCompletion {
+		return
+	}
+	if route.ProviderPrefs != "" {
+		var prefs map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(route.ProviderPrefs), &prefs); err != nil {
+			log.Warnf("Invalid provider preferences for route %s: %v", route.Name, err)
+		}
+		for key, value := range prefs {
+			reqData[key] = value
+		}
+	}
+	if _, ok := reqData["usage"]; !ok {
+		reqData["usage"] = json.RawMessage(`{"include":true}`)
+	}
+	rewritten, err := json.Marshal(reqData)
+	if err != nil {
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(rewritten))
+	req.ContentLength = int64(len(rewritten))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(rewritten)), nil
+	}
+}
+
+// upstreamCostStore 上游返回的实际费用，按请求ID暂存，写入请求日志时取出
+type upstreamCostStore struct {
+	mu    sync.Mutex
+	costs map[string]*upstreamCost
+}
+
+type upstreamCost struct {
+	amount float64
+	at     time.Time
+}
+
+// add 累加请求的费用（重试、Fallback 的每次上游请求分别计费）
+func (c *upstreamCostStore) add(requestID string, amount float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.costs == nil {
+		c.costs = make(map[string]*upstreamCost)
+	}
+	for id, cost := range c.costs {
+		if now.Sub(cost.at) > upstreamCostTTL {
+			delete(c.costs, id)
+		}
+	}
+	if cost, ok := c.costs[requestID]; ok {
+		cost.amount += amount
+		cost.at = now
+		return
+	}
+	c.costs[requestID] = &upstreamCost{amount: amount, at: now}
+}
+
+// take 取出请求的费用，没有记录时返回 0
+func (c *upstreamCostStore) take(requestID string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cost, ok := c.costs[requestID]
+	if !ok {
+		return 0
+	}
+	delete(c.costs, requestID)
+	return cost.amount
+}
+
+// captureUpstreamCost 包装 OpenRouter 的响应体，读到 usage.cost 时记录请求的实际费用
+func (s *ProxyService) captureUpstreamCost(requestID string, req *http.Request, resp *http.Response) *http.Response {
+	if requestID == "" || resp == nil || !isOpenRouterURL(req.URL.Host) {
+		return resp
+	}
+	resp.Body = &costCaptureBody{ReadCloser: resp.Body, store: &s.routeService.upstreamCosts, requestID: requestID}
+	return resp
+}
+
+// costCaptureBody 从响应中查找 usage.cost：流式响应逐行检查 data 事件，非流式响应读完后整体解析
+type costCaptureBody struct {
+	io.ReadCloser
+	store     *upstreamCostStore
+	requestID string
+
+	detected bool // 已根据第一个非空字节判断响应类型
+	whole    bool // 非流式 JSON 响应
+	buf      []byte
+	done     bool
+}
+
+func (b *costCaptureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.done {
+		b.write(p[:n])
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *costCaptureBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *costCaptureBody) write(p []byte) {
+	if !b.detected {
+		trimmed := bytes.TrimLeft(p, " \t\r\n")
+		if len(trimmed) == 0 {
+			return
+		}
+		b.detected = true
+		b.whole = trimmed[0] == '{'
+	}
+	if b.whole {
+		if len(b.buf)+len(p) > openRouterCostLimit {
+			b.buf, b.done = nil, true
+			return
+		}
+		b.buf = append(b.buf, p...)
+		return
+	}
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n')
+		if end < 0 {
+			if len(b.buf)+len(p) <= openRouterCostLimit {
+				b.buf = append(b.buf, p...)
+			}
+			return
+		}
+		b.buf = append(b.buf, p[:end]...)
+		b.scan(b.buf)
+		b.buf = b.buf[:0]
+		p = p[end+1:]
+	}
+}
+
+func (b *costCaptureBody) finish() {
+	if b.done {
+		return
+	}
+	b.done = true
+	b.scan(b.buf)
+	b.buf = nil
+}
+
+// scan 解析一行 data 事件或完整的 JSON 响应，找到 usage.cost 时记录
+func (b *costCaptureBody) scan(data []byte) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("data:")))
+	if len(data) == 0 || data[0] != '{' || !bytes.Contains(data, []byte(`"cost"`)) {
+		return
+	}
+	var chunk struct {
+		Usage *struct {
+			Cost *float64 `json:"cost"`
+		} `json:"usage"`
+	}
+	if json.Unmarshal(data, &chunk) != nil || chunk.Usage == nil || chunk.Usage.Cost == nil {
+		return
+	}
+	if cost := *chunk.Usage.Cost; cost > 0 {
+		b.store.add(b.requestID, cost)
+	}
+}
+
+// openRouterCatalogModel OpenRouter 模型目录中的一项，价格为每 token 美元（字符串）
+type openRouterCatalogModel struct {
+	ID      string `json:"id"`
+	Pricing struct {
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion"`
+	} `json:"pricing"`
+}
+
+// FetchOpenRouterPrices 从 OpenRouter 模型目录获取 OpenRouter 路由所用模型的价格（每百万 token），
+// 按路由的模型名（报告中的模型名）返回，目录中没有或价格不固定的模型不返回
+func (s *ProxyService) FetchOpenRouterPrices() (map[string]config.ModelPrice, error) {
+	routes, err := s.routeService.GetAllRoutes()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string][]string) // OpenRouter 模型ID -> 路由模型名
+	for _, route := range routes {
+		if !isOpenRouterURL(route.APIUrl) {
+			continue
+		}
+		upstream := route.UpstreamModel
+		if upstream == "" {
+			upstream = route.Model
+		}
+		wanted[upstream] = append(wanted[upstream], route.Model)
+	}
+	if len(wanted) == 0 {
+		return nil, fmt.Errorf("no OpenRouter routes configured")
+	}
+
+	resp, err := s.httpClient.Get(openRouterModelsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OpenRouter models: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenRouter models: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenRouter returned status %d: %s", resp.StatusCode, upstreamErrorMessage(body))
+	}
+	var catalog struct {
+		Data []openRouterCatalogModel `json:"data"`
+	}
+	if err := json.Unmarshal(body, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenRouter models: %v", err)
+	}
+
+	prices := make(map[string]config.ModelPrice)
+	for _, m := range catalog.Data {
+		models, ok := wanted[m.ID]
+		if !ok {
+			continue
+		}
+		input, inErr := strconv.ParseFloat(m.Pricing.Prompt, 64)
+		output, outErr := strconv.ParseFloat(m.Pricing.Completion, 64)
+		if inErr != nil || outErr != nil || input < 0 || output < 0 {
+			continue
+		}
+		price := config.ModelPrice{Input: perMillion(input), Output: perMillion(output)}
+		for _, model := range models {
+			prices[model] = price
+		}
+	}
+	log.Infof("Fetched OpenRouter prices for %d of %d models", len(prices), len(wanted))
+	return prices, nil
+}
+
+// perMillion 每 token 价格转换为每百万 token 价格，保留 6 位小数
+func perMillion(perToken float64) float64 {
+	return math.Round(perToken*1e12) / 1e6
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// 请求处理中的 panic（例如格式转换器遇到意外的上游数据）：记录堆栈，转换为错误返回，
+// 流式请求记为失败并由路由层向客户端发送 SSE 错误事件，后台协程中的 panic 不会导致进程退出
+
+// PanicError 由 panic 转换的错误
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("internal error: %v", e.Value)
+}
+
+// RecoverPanic 记录 panic 的堆栈并返回对应的错误，r 为 nil 时返回 nil。
+// 需要在 defer 的函数中直接传入 recover() 的结果；http.ErrAbortHandler 会继续向上抛出
+func RecoverPanic(requestID string, r interface{}) error {
+	if r == nil {
+		return nil
+	}
+	if r == http.ErrAbortHandler {
+		panic(r)
+	}
+	RequestLogger(requestID).Errorf("[Panic] %v\n%s", r, debug.Stack())
+	return &PanicError{Value: r}
+}
+
+// safeGo 启动协程，协程中的 panic 只记录日志
+func safeGo(requestID string, fn func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				RecoverPanic(requestID, r)
+			}
+		}()
+		fn()
+	}()
+}
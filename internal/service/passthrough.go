@@ -0,0 +1,375 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/database"
+)
+
+// passthroughResponseHeaders 透传时需要保留的上游响应头
+var passthroughResponseHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Disposition",
+	"Cache-Control",
+}
+
+// isBinaryContentType 判断响应是否为二进制内容（音频、图片等），二进制内容不做 JSON 解析和日志记录
+func isBinaryContentType(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return false
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/x-ndjson", mediaType == "application/xml":
+		return false
+	}
+	return true
+}
+
+// buildOpenAIEndpointURL 构建 OpenAI 兼容接口地址，规则与 buildOpenAIChatURL 一致
+func buildOpenAIEndpointURL(apiUrl, endpoint string) string {
+	endpoint = strings.TrimPrefix(endpoint, "/")
+	if strings.HasSuffix(apiUrl, "/") {
+		return apiUrl + endpoint
+	}
+	return apiUrl + "/v1/" + endpoint
+}
+
+// usageFromResponse 从 JSON 响应中提取 token 用量
+func usageFromResponse(body []byte) (promptTokens, completionTokens, totalTokens int) {
+	var respData map[string]interface{}
+	if err := json.Unmarshal(body, &respData); err != nil {
+		return 0, 0, 0
+	}
+	usage, ok := respData["usage"].(map[string]interface{})
+	if !ok {
+		// Gemini 原生响应
+		if metadata, ok := respData["usageMetadata"].(map[string]interface{}); ok {
+			return numberValue(metadata["promptTokenCount"]), numberValue(metadata["candidatesTokenCount"]), numberValue(metadata["totalTokenCount"])
+		}
+		return 0, 0, 0
+	}
+	if v, ok := usage["prompt_tokens"].(float64); ok {
+		promptTokens = int(v)
+	} else if v, ok := usage["input_tokens"].(float64); ok {
+		promptTokens = int(v)
+	}
+	if v, ok := usage["completion_tokens"].(float64); ok {
+		completionTokens = int(v)
+	} else if v, ok := usage["output_tokens"].(float64); ok {
+		completionTokens = int(v)
+	}
+	if v, ok := usage["total_tokens"].(float64); ok {
+		totalTokens = int(v)
+	}
+	if totalTokens == 0 {
+		totalTokens = promptTokens + completionTokens
+	}
+	return promptTokens, completionTokens, totalTokens
+}
+
+// copyWithFlush 边读边写并及时 flush，避免大文件或分块音频在内存中缓冲
+func copyWithFlush(dst io.Writer, src io.Reader, flusher http.Flusher) (int64, error) {
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			w, err := dst.Write(buf[:n])
+			written += int64(w)
+			if err != nil {
+				return written, err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if readErr == io.EOF {
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+}
+
+// selectRoutes 根据模型名选择路由，所有代理入口共用：请求头指定路由/供应商时优先使用，
+// 重定向关键字使用重定向目标，Fallback 开启时返回所有匹配的路由
+func (s *ProxyService) selectRoutes(model string, reqData map[string]interface{}, headers map[string]string) ([]database.ModelRoute, string, error) {
+	logger := requestLogger(headers)
+	s.active.update(requestIDFromHeaders(headers), func(info *ActiveRequest) { info.Model = model })
+	if routes, targetModel, ok, err := s.overrideRoutes(model, headers); ok {
+		return routes, targetModel, err
+	}
+
+	if s.config.RedirectEnabled && (model == s.config.RedirectKeyword || strings.HasPrefix(model, s.config.RedirectKeyword+":")) {
+		route, err := s.getRedirectRoute()
+		if err != nil {
+			return nil, model, fmt.Errorf("redirect target not configured or not found: %v", err)
+		}
+		logger.Infof("Redirecting %s to route: %s (model: %s, id: %d)", model, route.Name, route.Model, route.ID)
+		return []database.ModelRoute{*route}, route.Model, nil
+	}
+
+	// 按请求特征路由，目标没有可用路由时按原模型选择
+	if rule := s.matchRoutingRule(model, reqData); rule != nil {
+		routes, targetModel, err := s.ruleRoutes(rule, model)
+		if err == nil {
+			logger.Infof("[Routing Rule] %s: %s -> %s, %d route(s), first: %s", rule.Name, model, targetModel, len(routes), routes[0].Name)
+			return routes, targetModel, nil
+		}
+		logger.Warnf("[Routing Rule] %s: %v, using default routes for %s", rule.Name, err, model)
+	}
+
+	if !s.config.FallbackEnabled {
+		route, err := s.routeService.GetRouteByModel(model)
+		if errors.Is(err, ErrRouteQuotaExhausted) {
+			return nil, model, err
+		}
+		if err != nil {
+			availableModels, _ := s.routeService.GetAvailableModels()
+			return nil, model, fmt.Errorf("model '%s' not found in route list. Available models: %v", model, availableModels)
+		}
+		logger.Debugf("Fallback 已关闭：模型 %s 使用单一路由 %s (id: %d)", model, route.Name, route.ID)
+		return []database.ModelRoute{*route}, model, nil
+	}
+
+	routes, err := s.routeService.GetAllRoutesByModel(model)
+	if errors.Is(err, ErrRouteQuotaExhausted) {
+		return nil, model, err
+	}
+	if err != nil || len(routes) == 0 {
+		availableModels, _ := s.routeService.GetAvailableModels()
+		return nil, model, fmt.Errorf("model '%s' not found in route list. Available models: %v", model, availableModels)
+	}
+	logger.Debugf("Fallback 已开启：模型 %s 找到 %d 条路由", model, len(routes))
+	return routes, model, nil
+}
+
+// ProxyPassthroughRequest 透传 OpenAI 兼容的非对话接口（audio/speech、images/generations、embeddings 等）
+// 不经过适配器，响应体按上游 Content-Type 原样写回；二进制响应直接流式转发，不解析也不记录内容
+// 返回的状态码和错误仅在尚未向客户端写入任何数据时有效
+func (s *ProxyService) ProxyPassthroughRequest(requestBody []byte, headers map[string]string, endpoint string, writer http.ResponseWriter) (int, error) {
+	requestID := requestIDFromHeaders(headers)
+	logger := RequestLogger(requestID)
+
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(requestBody, &reqData); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid JSON body: %v", err)
+	}
+	model, ok := reqData["model"].(string)
+	if !ok || model == "" {
+		return http.StatusBadRequest, fmt.Errorf("'model' field is required")
+	}
+
+	logger.Infof("Passthrough request: endpoint=%s, model=%s", endpoint, model)
+
+	remoteIP := headers["X-Real-IP"]
+	if remoteIP == "" {
+		remoteIP = "unknown"
+	}
+
+	routes, targetModel, err := s.selectRoutes(model, reqData, headers)
+	if err != nil {
+		return routeSelectionStatus(err), err
+	}
+	if targetModel != model {
+		model = targetModel
+		reqData["model"] = model
+		requestBody, _ = json.Marshal(reqData)
+	}
+
+	contentType := headers["Content-Type"]
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	var lastErr error
+	lastStatusCode := http.StatusBadGateway
+	for routeIndex, route := range routes {
+		targetURL := buildOpenAIEndpointURL(route.APIUrl, endpoint)
+		logger.Debugf("Trying route %d/%d: %s -> %s", routeIndex+1, len(routes), route.Name, targetURL)
+
+		proxyReq, err := http.NewRequest("POST", targetURL, bytes.NewReader(requestBody))
+		if err != nil {
+			lastErr = err
+			lastStatusCode = http.StatusInternalServerError
+			continue
+		}
+		proxyReq.Header.Set("Content-Type", contentType)
+		if accept := headers["Accept"]; accept != "" {
+			proxyReq.Header.Set("Accept", accept)
+		}
+		setUpstreamAuth(proxyReq, route.APIUrl, route.APIKey, headers)
+		applyRouteExtras(proxyReq, route.ExtraHeaders, route.ExtraQuery)
+		applyUpstreamModel(proxyReq, route.UpstreamModel)
+		applyOpenRouter(proxyReq, &route)
+		proxyReq = withRequestID(proxyReq, requestID)
+
+		startTime := time.Now()
+		resp, err := s.doWithRetry(proxyReq, route.Name)
+		if err != nil {
+			s.routeService.LogRequestFull(RequestLogParams{
+				RequestID:     requestID,
+				Model:         model,
+				ProviderModel: route.Model,
+				ProviderName:  route.Name,
+				RouteID:       route.ID,
+				Success:       false,
+				ErrorMessage:  err.Error(),
+				Style:         "openai",
+				ProxyTimeMs:   time.Since(startTime).Milliseconds(),
+			})
+			lastErr = fmt.Errorf("backend service unavailable: %v", err)
+			lastStatusCode = http.StatusServiceUnavailable
+			if s.fallbackNext(routes, routeIndex, 0, err) {
+				logger.Warnf("Route %s failed with network error: %v, trying fallback...", route.Name, err)
+				continue
+			}
+			return lastStatusCode, lastErr
+		}
+
+		respContentType := resp.Header.Get("Content-Type")
+		binary := isBinaryContentType(respContentType)
+
+		// 可切换路由的错误：读取错误信息后尝试下一个路由
+		if s.fallbackNext(routes, routeIndex, resp.StatusCode, nil) {
+			errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+			errMsg := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(errBody))
+			if binary {
+				errMsg = fmt.Sprintf("HTTP %d (%s)", resp.StatusCode, respContentType)
+			}
+			s.routeService.LogRequestFull(RequestLogParams{
+				RequestID:     requestID,
+				Model:         model,
+				ProviderModel: route.Model,
+				ProviderName:  route.Name,
+				RouteID:       route.ID,
+				Success:       false,
+				ErrorMessage:  errMsg,
+				ErrorType:     ErrorTypeForStatus(resp.StatusCode),
+				Style:         "openai",
+				ProxyTimeMs:   time.Since(startTime).Milliseconds(),
+			})
+			logger.Warnf("Route %s failed with status %d, trying fallback...", route.Name, resp.StatusCode)
+			lastErr = fmt.Errorf("%s", errMsg)
+			lastStatusCode = resp.StatusCode
+			continue
+		}
+
+		for _, key := range passthroughResponseHeaders {
+			if v := resp.Header.Get(key); v != "" {
+				writer.Header().Set(key, v)
+			}
+		}
+
+		if binary {
+			// 二进制响应：原样流式转发，不解析、不记录响应体
+			writer.WriteHeader(resp.StatusCode)
+			flusher, _ := writer.(http.Flusher)
+			written, copyErr := copyWithFlush(writer, resp.Body, flusher)
+			resp.Body.Close()
+
+			success := resp.StatusCode == http.StatusOK && copyErr == nil
+			errMsg := ""
+			if copyErr != nil {
+				errMsg = copyErr.Error()
+				logger.Errorf("Passthrough copy failed after %d bytes: %v", written, copyErr)
+			} else if resp.StatusCode != http.StatusOK {
+				errMsg = fmt.Sprintf("HTTP %d (%s)", resp.StatusCode, respContentType)
+			}
+			logger.Infof("Passthrough %s response from %s: %d bytes (%s) in %v", endpoint, route.Name, written, respContentType, time.Since(startTime))
+
+			s.routeService.LogRequestFull(RequestLogParams{
+				RequestID:     requestID,
+				Model:         model,
+				ProviderModel: route.Model,
+				ProviderName:  route.Name,
+				RouteID:       route.ID,
+				Success:       success,
+				ErrorMessage:  errMsg,
+				ErrorType:     ErrorTypeForStatus(resp.StatusCode),
+				Style:         "openai",
+				ProxyTimeMs:   time.Since(startTime).Milliseconds(),
+			})
+			s.SaveTraceIfEnabled(
+				requestID, remoteIP, model, route.Model, route.Name,
+				string(requestBody), fmt.Sprintf("[binary %s, %d bytes]", respContentType, written),
+				0, 0, 0,
+				success, errMsg, "openai", false,
+				time.Since(startTime).Milliseconds(),
+			)
+			return resp.StatusCode, nil
+		}
+
+		// 文本/JSON 响应：读取后写回，并记录用量
+		responseBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			lastStatusCode = http.StatusInternalServerError
+			if routeIndex < len(routes)-1 {
+				logger.Warnf("Route %s failed to read response: %v, trying fallback...", route.Name, err)
+				continue
+			}
+			return lastStatusCode, lastErr
+		}
+		if respContentType == "" {
+			writer.Header().Set("Content-Type", "application/json")
+		}
+		writer.Header().Del("Content-Length")
+		writer.WriteHeader(resp.StatusCode)
+		writer.Write(responseBody)
+
+		success := resp.StatusCode == http.StatusOK
+		errMsg := ""
+		if !success {
+			errMsg = string(responseBody)
+		}
+		promptTokens, completionTokens, totalTokens := usageFromResponse(responseBody)
+		s.routeService.LogRequestFull(RequestLogParams{
+			RequestID:      requestID,
+			Model:          model,
+			ProviderModel:  route.Model,
+			ProviderName:   route.Name,
+			RouteID:        route.ID,
+			RequestTokens:  promptTokens,
+			ResponseTokens: completionTokens,
+			TotalTokens:    totalTokens,
+			Success:        success,
+			ErrorMessage:   errMsg,
+			ErrorType:      ErrorTypeForStatus(resp.StatusCode),
+			Style:          "openai",
+			ProxyTimeMs:    time.Since(startTime).Milliseconds(),
+		})
+		s.SaveTraceIfEnabled(
+			requestID, remoteIP, model, route.Model, route.Name,
+			string(requestBody), string(responseBody),
+			promptTokens, completionTokens, totalTokens,
+			success, errMsg, "openai", false,
+			time.Since(startTime).Milliseconds(),
+		)
+		return resp.StatusCode, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("all routes failed")
+	}
+	return lastStatusCode, lastErr
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"openai-router-go/internal/database"
+)
+
+// 代理管道：入站格式解析 → 路由选择 → 出站格式编码 → 流式/非流式执行
+// 入站格式、出站格式和两者之间的转换都通过注册表提供，新增接口只需注册格式并调用 proxyPipeline/proxyPipelineStream
+
+// InboundFormat 客户端请求格式
+type InboundFormat struct {
+	Name     string                  // 注册名
+	Format   string                  // 请求体格式（openai、claude、gemini），用于注入提示词模板
+	Style    string                  // 请求日志中的调用方式
+	Fallback string                  // 路由格式没有注册转换时使用的出站格式，为空表示不支持
+	Bridges  map[string]FormatBridge // 按出站格式注册的转换
+}
+
+// FormatBridge 入站格式到出站格式的转换，字段为 nil 时原样透传
+type FormatBridge struct {
+	// Request 将客户端请求转换为上游请求
+	Request func(reqData map[string]interface{}, model string) (map[string]interface{}, error)
+	// Response 将上游非流式响应转换为客户端格式
+	Response func(s *ProxyService, respData map[string]interface{}) (map[string]interface{}, error)
+	// Stream 将上游流式响应转换为客户端格式写出，并记录请求日志
+	Stream func(s *ProxyService, reader io.Reader, writer io.Writer, flusher http.Flusher, model string, logCtx StreamLogContext) error
+}
+
+// OutboundFormat 上游接口格式：构造上游地址和请求头
+type OutboundFormat interface {
+	// URL 上游接口地址
+	URL(route *database.ModelRoute, stream bool) string
+	// SetHeaders 设置认证等请求头
+	SetHeaders(s *ProxyService, req *http.Request, route *database.ModelRoute, headers map[string]string)
+}
+
+var (
+	inboundFormats  = make(map[string]*InboundFormat)
+	outboundFormats = make(map[string]OutboundFormat)
+)
+
+// RegisterInboundFormat 注册客户端请求格式
+func RegisterInboundFormat(format *InboundFormat) {
+	if format.Bridges == nil {
+		format.Bridges = make(map[string]FormatBridge)
+	}
+	inboundFormats[format.Name] = format
+}
+
+// RegisterOutboundFormat 注册上游接口格式，name 与路由的目标格式一致
+func RegisterOutboundFormat(name string, format OutboundFormat) {
+	outboundFormats[name] = format
+}
+
+// RegisterFormatBridge 注册入站格式到出站格式的转换
+func RegisterFormatBridge(inbound, outbound string, bridge FormatBridge) {
+	if format, ok := inboundFormats[inbound]; ok {
+		format.Bridges[outbound] = bridge
+	}
+}
+
+// pipelineCall 解析后的客户端请求
+type pipelineCall struct {
+	inbound   *InboundFormat
+	reqData   map[string]interface{}
+	body      []byte
+	model     string
+	headers   map[string]string
+	requestID string
+}
+
+// newPipelineCall 解析客户端请求并选择路由，流式请求统一打开 stream
+func (s *ProxyService) newPipelineCall(inbound string, requestBody []byte, headers map[string]string, stream bool) (*pipelineCall, []database.ModelRoute, int, error) {
+	logger := requestLogger(headers)
+	format := inboundFormats[inbound]
+	if format == nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("unknown inbound format: %s", inbound)
+	}
+
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(requestBody, &reqData); err != nil {
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %v", err)
+	}
+	model, ok := reqData["model"].(string)
+	if !ok || model == "" {
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("'model' field is required")
+	}
+	logger.Infof("[Pipeline] Received %s request for model: %s (stream: %v)", inbound, model, stream)
+
+	routes, targetModel, err := s.selectRoutes(model, reqData, headers)
+	if err != nil {
+		return nil, nil, routeSelectionStatus(err), err
+	}
+	changed := targetModel != model
+	if changed {
+		model = targetModel
+		reqData["model"] = model
+	}
+	// 估算的输入超过路由的上下文长度时改用上下文更大的路由或裁剪消息
+	routes, trimmed := s.fitContextWindow(routes, model, reqData, headers)
+	changed = changed || trimmed
+	if isStream, _ := reqData["stream"].(bool); stream && !isStream {
+		reqData["stream"] = true
+		changed = true
+	}
+	if changed {
+		requestBody, _ = json.Marshal(reqData)
+	}
+
+	return &pipelineCall{
+		inbound:   format,
+		reqData:   reqData,
+		body:      requestBody,
+		model:     model,
+		headers:   headers,
+		requestID: requestIDFromHeaders(headers),
+	}, routes, 0, nil
+}
+
+// pipelineTarget 选择路由使用的出站格式：路由格式没有注册转换时使用入站格式的 Fallback，ok 为 false 表示不支持
+func pipelineTarget(inbound *InboundFormat, route *database.ModelRoute) (target string, bridge FormatBridge, ok bool) {
+	target = probeTargetFormat(route)
+	bridge, ok = inbound.Bridges[target]
+	if !ok && inbound.Fallback != "" {
+		target = inbound.Fallback
+		bridge, ok = inbound.Bridges[target]
+	}
+	return target, bridge, ok && outboundFormats[target] != nil
+}
+
+// upstreamRequest 按路由的目标格式构造上游请求
+func (s *ProxyService) upstreamRequest(call *pipelineCall, route *database.ModelRoute, stream bool) (*http.Request, FormatBridge, int, error) {
+	logger := RequestLogger(call.requestID)
+	target, bridge, ok := pipelineTarget(call.inbound, route)
+	if !ok {
+		return nil, bridge, http.StatusNotImplemented, fmt.Errorf("route %s (%s format) does not support %s requests", route.Name, probeTargetFormat(route), call.inbound.Name)
+	}
+	outbound := outboundFormats[target]
+
+	// 注入路由/分组的系统提示词模板
+	reqData, body := s.withPromptTemplate(call.reqData, call.body, route, call.inbound.Format, call.model)
+	if bridge.Request != nil {
+		converted, err := bridge.Request(reqData, call.model)
+		if err != nil {
+			logger.Errorf("[Pipeline] Failed to convert %s request for %s route %s: %v", call.inbound.Name, target, route.Name, err)
+			s.logConversionFailure(call.requestID, call.model, route, call.inbound.Style, stream, err)
+			return nil, bridge, http.StatusInternalServerError, err
+		}
+		body, _ = json.Marshal(converted)
+	}
+
+	targetURL := outbound.URL(route, stream)
+	logger.Infof("[Pipeline] %s -> %s: %s (route: %s)", call.inbound.Name, target, targetURL, route.Name)
+	s.logBody(call.requestID, "[Pipeline] Upstream request body: %s", body)
+
+	proxyReq, err := s.newRouteRequest(route, targetURL, body, call.requestID, func(req *http.Request) {
+		outbound.SetHeaders(s, req, route, call.headers)
+	})
+	if err != nil {
+		return nil, bridge, http.StatusInternalServerError, err
+	}
+	return proxyReq, bridge, 0, nil
+}
+
+// newRouteRequest 创建发往路由的上游请求，所有入口共用同一处理顺序：
+// 请求体依次应用供应商兼容、max_tokens 上限和模型兼容，setHeaders 设置认证等请求头，再应用路由的自定义请求头/查询参数、
+// 上游模型名、OpenRouter 设置和请求ID
+func (s *ProxyService) newRouteRequest(route *database.ModelRoute, targetURL string, body []byte, requestID string, setHeaders func(req *http.Request)) (*http.Request, error) {
+	body = applyProviderQuirks(route.APIUrl, route.Format, route.Model, body)
+	body = capRouteMaxTokens(body, route.MaxTokens, targetURL)
+	body = s.applyModelCompat(route.Format, route.UpstreamModel, body)
+	req, err := http.NewRequest("POST", targetURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if setHeaders != nil {
+		setHeaders(req)
+	}
+	applyRouteExtras(req, route.ExtraHeaders, route.ExtraQuery)
+	applyUpstreamModel(req, route.UpstreamModel)
+	applyOpenRouter(req, route)
+	return withRequestID(req, requestID), nil
+}
+
+// logPipelineFailure 记录一次失败的上游请求，statusCode 为上游状态码（网络错误时为 0）
+func (s *ProxyService) logPipelineFailure(call *pipelineCall, route *database.ModelRoute, message string, statusCode int, startTime time.Time, stream bool) {
+	s.routeService.LogRequestFull(RequestLogParams{
+		RequestID:     call.requestID,
+		Model:         call.model,
+		ProviderModel: route.Model,
+		ProviderName:  route.Name,
+		RouteID:       route.ID,
+		Success:       false,
+		ErrorMessage:  message,
+		ErrorType:     ErrorTypeForStatus(statusCode),
+		Style:         call.inbound.Style,
+		ProxyTimeMs:   time.Since(startTime).Milliseconds(),
+		IsStream:      stream,
+	})
+}
+
+// proxyPipeline 非流式请求：按路由依次尝试（Fallback），成功后将响应转换回客户端格式
+func (s *ProxyService) proxyPipeline(inbound string, requestBody []byte, headers map[string]string) ([]byte, int, error) {
+	logger := requestLogger(headers)
+	call, routes, statusCode, err := s.newPipelineCall(inbound, requestBody, headers, false)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return s.routeFallback(routes, func(route *database.ModelRoute) ([]byte, int, error) {
+		proxyReq, bridge, statusCode, err := s.upstreamRequest(call, route, false)
+		if err != nil {
+			return nil, statusCode, err
+		}
+
+		startTime := time.Now()
+		proxyReq, cancelTimeout := s.withAdaptiveTimeout(proxyReq, route.ID)
+		defer cancelTimeout()
+		resp, err := s.doWithRetry(proxyReq, route.Name)
+		if err != nil {
+			s.logPipelineFailure(call, route, err.Error(), 0, startTime, false)
+			return nil, http.StatusServiceUnavailable, fmt.Errorf("backend service unavailable: %v", err)
+		}
+		defer resp.Body.Close()
+
+		responseBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			s.logPipelineFailure(call, route, err.Error(), 0, startTime, false)
+			return nil, http.StatusInternalServerError, err
+		}
+		logger.Infof("[Pipeline] Response received from %s in %v, status: %d", route.Name, time.Since(startTime), resp.StatusCode)
+		s.logBody(call.requestID, "[Pipeline] Upstream response body: %s", responseBody)
+
+		if resp.StatusCode != http.StatusOK {
+			s.logPipelineFailure(call, route, fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(responseBody)), resp.StatusCode, startTime, false)
+			return responseBody, resp.StatusCode, nil
+		}
+
+		// 采样校验上游响应结构
+		s.checkSchemaDrift(route.Name, route.Format, call.requestID, responseBody)
+
+		// 响应没有 usage 时 token 记为 0，请求结束后根据请求和响应文本估算
+		promptTokens, completionTokens, totalTokens := usageFromResponse(responseBody)
+		s.routeService.LogRequestFull(RequestLogParams{
+			RequestID:      call.requestID,
+			Model:          call.model,
+			ProviderModel:  route.Model,
+			ProviderName:   route.Name,
+			RouteID:        route.ID,
+			RequestTokens:  promptTokens,
+			ResponseTokens: completionTokens,
+			TotalTokens:    totalTokens,
+			Success:        true,
+			Style:          call.inbound.Style,
+			ProxyTimeMs:    time.Since(startTime).Milliseconds(),
+			IsStream:       false,
+		})
+
+		if bridge.Response == nil {
+			return responseBody, resp.StatusCode, nil
+		}
+		// 转换失败时返回上游原始响应
+		var respData map[string]interface{}
+		if err := json.Unmarshal(responseBody, &respData); err != nil {
+			logger.Errorf("[Pipeline] Failed to unmarshal response from %s: %v", route.Name, err)
+			return responseBody, resp.StatusCode, nil
+		}
+		converted, err := bridge.Response(s, respData)
+		if err != nil {
+			logger.Errorf("[Pipeline] Failed to convert response from %s: %v", route.Name, err)
+			return responseBody, resp.StatusCode, nil
+		}
+		convertedBody, err := json.Marshal(converted)
+		if err != nil {
+			logger.Errorf("[Pipeline] Failed to marshal converted response: %v", err)
+			return responseBody, resp.StatusCode, nil
+		}
+		return convertedBody, resp.StatusCode, nil
+	})
+}
+
+// proxyPipelineStream 流式请求：连接阶段按路由依次尝试（Fallback），连接成功后将上游流转换为客户端格式写出
+func (s *ProxyService) proxyPipelineStream(inbound string, requestBody []byte, headers map[string]string, writer io.Writer, flusher http.Flusher) error {
+	logger := requestLogger(headers)
+	call, routes, _, err := s.newPipelineCall(inbound, requestBody, headers, true)
+	if err != nil {
+		return err
+	}
+
+	return s.routeStreamFallback(routes, func(route *database.ModelRoute) error {
+		proxyReq, bridge, statusCode, err := s.upstreamRequest(call, route, true)
+		if err != nil {
+			return &fallbackError{statusCode: statusCode, err: err}
+		}
+
+		startTime := time.Now()
+		resp, err := s.doWithRetry(proxyReq, route.Name)
+		if err != nil {
+			s.logPipelineFailure(call, route, err.Error(), 0, startTime, true)
+			return upstreamNetworkError(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			s.logPipelineFailure(call, route, fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)), resp.StatusCode, startTime, true)
+			return upstreamStatusError(resp.StatusCode, body)
+		}
+		logger.Debugf("[Pipeline] Stream connection established with route %s", route.Name)
+
+		logCtx := StreamLogContext{
+			RouteID:       route.ID,
+			ProviderModel: route.Model,
+			ProviderName:  route.Name,
+			Style:         call.inbound.Style,
+			StartTime:     startTime,
+		}
+		if bridge.Stream != nil {
+			return bridge.Stream(s, resp.Body, writer, flusher, call.model, logCtx)
+		}
+		return s.streamDirectContext(resp.Body, writer, flusher, call.model, logCtx)
+	})
+}
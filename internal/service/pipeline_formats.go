@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/database"
+)
+
+// openAIOutbound OpenAI Chat Completions 接口（包括 OpenAI 兼容的供应商）
+type openAIOutbound struct{}
+
+func (openAIOutbound) URL(route *database.ModelRoute, stream bool) string {
+	return buildRouteChatURL(route.APIUrl, route.Format)
+}
+
+func (openAIOutbound) SetHeaders(s *ProxyService, req *http.Request, route *database.ModelRoute, headers map[string]string) {
+	s.setClientUpstreamAuth(req, route, headers)
+}
+
+// claudeOutbound Anthropic Messages 接口
+type claudeOutbound struct{}
+
+func (claudeOutbound) URL(route *database.ModelRoute, stream bool) string {
+	return buildClaudeMessagesURL(strings.TrimSuffix(route.APIUrl, "/"))
+}
+
+func (claudeOutbound) SetHeaders(s *ProxyService, req *http.Request, route *database.ModelRoute, headers map[string]string) {
+	s.setAnthropicAuth(req, route, headers)
+	s.setAnthropicHeaders(req, headers)
+}
+
+// adapterRequest 使用已注册的适配器转换请求
+func adapterRequest(name string) func(map[string]interface{}, string) (map[string]interface{}, error) {
+	return func(reqData map[string]interface{}, model string) (map[string]interface{}, error) {
+		adapter := adapters.GetAdapter(name)
+		if adapter == nil {
+			return nil, fmt.Errorf("%s adapter not found", name)
+		}
+		return adapter.AdaptRequest(reqData, model)
+	}
+}
+
+// bridgeOpenAIToClaudeStream 将 OpenAI 流式响应转换为 Claude 流式响应
+func bridgeOpenAIToClaudeStream(s *ProxyService, reader io.Reader, writer io.Writer, flusher http.Flusher, model string, logCtx StreamLogContext) error {
+	usage := &streamUsage{}
+	return s.runStream(reader, writer, flusher, model, newOpenAIToClaudeStream(model, usage), usage, logCtx)
+}
+
+func init() {
+	RegisterOutboundFormat("openai", openAIOutbound{})
+	RegisterOutboundFormat("claude", claudeOutbound{})
+
+	// /api/anthropic：Claude 格式，其他格式的路由按 Claude 接口透传
+	RegisterInboundFormat(&InboundFormat{Name: "claude", Format: "claude", Style: "claude", Fallback: "claude"})
+	RegisterFormatBridge("claude", "claude", FormatBridge{})
+	RegisterFormatBridge("claude", "openai", FormatBridge{
+		Request: adapterRequest("claude-to-openai"),
+		Response: func(s *ProxyService, respData map[string]interface{}) (map[string]interface{}, error) {
+			return s.convertOpenAIToAnthropicResponse(respData), nil
+		},
+		Stream: bridgeOpenAIToClaudeStream,
+	})
+
+	// /api/claudecode：Claude 格式（包含工具链、系统提示词等），非 Claude 路由按 OpenAI 接口转换
+	RegisterInboundFormat(&InboundFormat{Name: "claudecode", Format: "claude", Style: "claude", Fallback: "openai"})
+	RegisterFormatBridge("claudecode", "claude", FormatBridge{})
+	RegisterFormatBridge("claudecode", "openai", FormatBridge{
+		Request: adapterRequest("claudecode-to-openai"),
+		Response: func(s *ProxyService, respData map[string]interface{}) (map[string]interface{}, error) {
+			adapter := adapters.GetAdapter("claudecode-to-openai")
+			if adapter == nil {
+				return nil, fmt.Errorf("claudecode-to-openai adapter not found")
+			}
+			return adapter.AdaptResponse(respData)
+		},
+		Stream: bridgeOpenAIToClaudeStream,
+	})
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+)
+
+func TestNewRouteRequestMaxTokens(t *testing.T) {
+	s := &ProxyService{config: &config.Config{ModelCompatEnabled: true, ModelCompatRules: config.DefaultModelCompatRules()}}
+	tests := []struct {
+		name      string
+		model     string
+		targetURL string
+		body      string
+		want      map[string]interface{} // 字段值，nil 表示字段不应存在
+	}{
+		{
+			name:      "omitted for o-series",
+			model:     "o3-mini",
+			targetURL: "https://api.openai.com/v1/chat/completions",
+			body:      `{"model":"o3-mini","messages":[{"role":"user","content":"hi"}]}`,
+			want:      map[string]interface{}{"max_completion_tokens": 1000.0, "max_tokens": nil},
+		},
+		{
+			name:      "over limit for o-series",
+			model:     "o3-mini",
+			targetURL: "https://api.openai.com/v1/chat/completions",
+			body:      `{"model":"o3-mini","messages":[{"role":"user","content":"hi"}],"max_tokens":5000}`,
+			want:      map[string]interface{}{"max_completion_tokens": 1000.0, "max_tokens": nil},
+		},
+		{
+			name:      "omitted for gpt-4o",
+			model:     "gpt-4o",
+			targetURL: "https://api.openai.com/v1/chat/completions",
+			body:      `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`,
+			want:      map[string]interface{}{"max_tokens": 1000.0, "max_completion_tokens": nil},
+		},
+		{
+			name:      "responses api",
+			model:     "o3-mini",
+			targetURL: "https://api.openai.com/v1/responses",
+			body:      `{"model":"o3-mini","input":"hi"}`,
+			want:      map[string]interface{}{"max_output_tokens": 1000.0, "max_tokens": nil, "max_completion_tokens": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &database.ModelRoute{Name: "test", Model: tt.model, APIUrl: "https://api.openai.com/v1", Format: "openai", MaxTokens: 1000}
+			req, err := s.newRouteRequest(route, tt.targetURL, []byte(tt.body), "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("invalid body %s: %v", data, err)
+			}
+			for field, want := range tt.want {
+				value, ok := got[field]
+				if want == nil && ok {
+					t.Errorf("%s should not be sent: %s", field, data)
+				} else if want != nil && value != want {
+					t.Errorf("%s = %v, want %v: %s", field, value, want, data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Playground：在 GUI 中向本地 API 发送对话请求，请求在进程内经过完整的路由、中间件和格式转换，
+// 同时记录客户端看到的请求/响应和实际发往上游的请求/原始响应
+
+// Playground 会话状态
+const (
+	PlaygroundRunning   = "running"
+	PlaygroundCompleted = "completed"
+	PlaygroundFailed    = "failed"
+	PlaygroundCancelled = "cancelled"
+)
+
+const (
+	playgroundTimeout       = 10 * time.Minute
+	playgroundSessionTTL    = 10 * time.Minute // 结束后保留的时间
+	playgroundResponseLimit = 1 << 20          // 保存的客户端响应最大字节数
+	playgroundMaxTokens     = 1024             // Claude 格式必须指定 max_tokens，未填写时使用
+)
+
+// PlaygroundRequest Playground 请求
+type PlaygroundRequest struct {
+	Model       string   `json:"model"`
+	RouteID     int64    `json:"route_id"` // 指定路由，0 表示按模型名路由
+	Format      string   `json:"format"`   // 客户端请求格式：openai, claude, gemini
+	System      string   `json:"system"`
+	Prompt      string   `json:"prompt"`
+	Stream      bool     `json:"stream"`
+	MaxTokens   int      `json:"max_tokens"`
+	Temperature *float64 `json:"temperature"`
+}
+
+// PlaygroundSession Playground 会话的当前状态
+type PlaygroundSession struct {
+	ID                string             `json:"id"` // 与请求ID相同，可在日志和 Traces 中查找
+	Status            string             `json:"status"`
+	Format            string             `json:"format"`
+	Answer            string             `json:"answer"` // 从客户端响应中提取的回复文本
+	ClientURL         string             `json:"client_url"`
+	ClientRequest     string             `json:"client_request"`
+	ClientResponse    string             `json:"client_response"`
+	ResponseTruncated bool               `json:"response_truncated"`
+	StatusCode        int                `json:"status_code"`
+	LatencyMs         int64              `json:"latency_ms"`
+	Error             string             `json:"error"`
+	Upstream          []UpstreamExchange `json:"upstream"`
+}
+
+// playgroundContextKey 标记进程内发起的 Playground 请求
+type playgroundContextKey struct{}
+
+// IsPlaygroundRequest 请求是否由 Playground 在进程内发起（外部请求无法设置）
+func IsPlaygroundRequest(ctx context.Context) bool {
+	ok, _ := ctx.Value(playgroundContextKey{}).(bool)
+	return ok
+}
+
+// playgroundRun 正在执行或已结束的会话
+type playgroundRun struct {
+	mu         sync.Mutex
+	session    PlaygroundSession
+	stream     bool
+	line       []byte // SSE 响应中未完成的一行
+	answer     strings.Builder
+	response   bytes.Buffer
+	start      time.Time
+	finishedAt time.Time
+	cancel     context.CancelFunc
+	inspection *upstreamInspection
+}
+
+// playgroundRunner 记录 Playground 会话
+type playgroundRunner struct {
+	mu   sync.Mutex
+	runs map[string]*playgroundRun
+}
+
+func newPlaygroundRunner() *playgroundRunner {
+	return &playgroundRunner{runs: make(map[string]*playgroundRun)}
+}
+
+// prune 移除结束超过保留时间的会话
+func (p *playgroundRunner) prune() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, run := range p.runs {
+		run.mu.Lock()
+		expired := !run.finishedAt.IsZero() && time.Since(run.finishedAt) > playgroundSessionTTL
+		run.mu.Unlock()
+		if expired {
+			delete(p.runs, id)
+		}
+	}
+}
+
+func (p *playgroundRunner) get(id string) *playgroundRun {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.runs[id]
+}
+
+// buildPlaygroundRequest 按客户端格式构建请求路径和请求体
+func buildPlaygroundRequest(req PlaygroundRequest) (string, []byte, error) {
+	var path string
+	body := map[string]interface{}{}
+	switch req.Format {
+	case "", "openai":
+		path = "/api/v1/chat/completions"
+		var messages []map[string]interface{}
+		if req.System != "" {
+			messages = append(messages, map[string]interface{}{"role": "system", "content": req.System})
+		}
+		messages = append(messages, map[string]interface{}{"role": "user", "content": req.Prompt})
+		body["model"] = req.Model
+		body["messages"] = messages
+		body["stream"] = req.Stream
+		if req.MaxTokens > 0 {
+			body["max_tokens"] = req.MaxTokens
+		}
+		if req.Temperature != nil {
+			body["temperature"] = *req.Temperature
+		}
+	case "claude":
+		path = "/api/anthropic/v1/messages"
+		maxTokens := req.MaxTokens
+		if maxTokens <= 0 {
+			maxTokens = playgroundMaxTokens
+		}
+		body["model"] = req.Model
+		body["max_tokens"] = maxTokens
+		if req.System != "" {
+			body["system"] = req.System
+		}
+		body["messages"] = []map[string]interface{}{{"role": "user", "content": req.Prompt}}
+		body["stream"] = req.Stream
+		if req.Temperature != nil {
+			body["temperature"] = *req.Temperature
+		}
+	case "gemini":
+		action := "generateContent"
+		if req.Stream {
+			action = "streamGenerateContent?alt=sse"
+		}
+		path = "/api/gemini/v1beta/models/" + url.PathEscape(req.Model) + ":" + action
+		body["contents"] = []map[string]interface{}{
+			{"role": "user", "parts": []map[string]interface{}{{"text": req.Prompt}}},
+		}
+		if req.System != "" {
+			body["systemInstruction"] = map[string]interface{}{
+				"parts": []map[string]interface{}{{"text": req.System}},
+			}
+		}
+		generationConfig := map[string]interface{}{}
+		if req.MaxTokens > 0 {
+			generationConfig["maxOutputTokens"] = req.MaxTokens
+		}
+		if req.Temperature != nil {
+			generationConfig["temperature"] = *req.Temperature
+		}
+		if len(generationConfig) > 0 {
+			body["generationConfig"] = generationConfig
+		}
+	default:
+		return "", nil, fmt.Errorf("unsupported format: %s", req.Format)
+	}
+	data, err := json.MarshalIndent(body, "", "  ")
+	return path, data, err
+}
+
+// StartPlayground 在进程内将请求发给本地 API（handler），立即返回会话ID，通过 GetPlaygroundSession 查看进度
+func (s *ProxyService) StartPlayground(handler http.Handler, req PlaygroundRequest) (string, error) {
+	if handler == nil {
+		return "", fmt.Errorf("API server not running")
+	}
+	req.Model = strings.TrimSpace(req.Model)
+	if req.RouteID > 0 && req.Model == "" {
+		route, err := s.routeService.GetRouteByID(req.RouteID)
+		if err != nil {
+			return "", err
+		}
+		req.Model = route.Model
+	}
+	if req.Model == "" {
+		return "", fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return "", fmt.Errorf("prompt is required")
+	}
+	path, body, err := buildPlaygroundRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	requestID := NewRequestID()
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), playgroundContextKey{}, true), playgroundTimeout)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return "", err
+	}
+	httpReq.RemoteAddr = "127.0.0.1:0"
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "anyproxy-playground")
+	httpReq.Header.Set(RequestIDHeader, requestID)
+	if key := s.config.AuthKey(); key != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+	}
+	if req.RouteID > 0 {
+		httpReq.Header.Set(RouteIDHeader, strconv.FormatInt(req.RouteID, 10))
+	}
+
+	run := &playgroundRun{
+		session: PlaygroundSession{
+			ID:            requestID,
+			Status:        PlaygroundRunning,
+			Format:        req.Format,
+			ClientURL:     path,
+			ClientRequest: string(body),
+		},
+		start:      time.Now(),
+		cancel:     cancel,
+		inspection: s.inspections.begin(requestID),
+	}
+	if run.session.Format == "" {
+		run.session.Format = "openai"
+	}
+	s.playground.prune()
+	s.playground.mu.Lock()
+	s.playground.runs[requestID] = run
+	s.playground.mu.Unlock()
+
+	log.Infof("[Playground] %s: %s (format: %s, model: %s, route: %d, stream: %v)",
+		requestID, path, run.session.Format, req.Model, req.RouteID, req.Stream)
+	go func() {
+		defer cancel()
+		defer s.inspections.end(requestID)
+		w := &playgroundWriter{run: run, header: make(http.Header)}
+		handler.ServeHTTP(w, httpReq)
+		run.finish(ctx.Err())
+	}()
+	return requestID, nil
+}
+
+// GetPlaygroundSession 返回会话的当前状态
+func (s *ProxyService) GetPlaygroundSession(id string) (PlaygroundSession, error) {
+	run := s.playground.get(id)
+	if run == nil {
+		return PlaygroundSession{}, fmt.Errorf("playground session not found: %s", id)
+	}
+	session := run.snapshot()
+	session.Upstream = run.inspection.snapshot()
+	return session, nil
+}
+
+// CancelPlayground 中止正在执行的会话
+func (s *ProxyService) CancelPlayground(id string) error {
+	run := s.playground.get(id)
+	if run == nil {
+		return fmt.Errorf("playground session not found: %s", id)
+	}
+	run.mu.Lock()
+	if run.session.Status == PlaygroundRunning {
+		run.session.Status = PlaygroundCancelled
+	}
+	run.mu.Unlock()
+	run.cancel()
+	return nil
+}
+
+// snapshot 返回会话状态的副本
+func (r *playgroundRun) snapshot() PlaygroundSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session := r.session
+	session.Answer = r.answer.String()
+	session.ClientResponse = r.response.String()
+	if r.finishedAt.IsZero() {
+		session.LatencyMs = time.Since(r.start).Milliseconds()
+	}
+	return session
+}
+
+// write 追加客户端响应，流式响应逐行提取回复文本
+func (r *playgroundRun) write(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if room := playgroundResponseLimit - r.response.Len(); room > 0 {
+		if len(p) > room {
+			r.response.Write(p[:room])
+			r.session.ResponseTruncated = true
+		} else {
+			r.response.Write(p)
+		}
+	} else {
+		r.session.ResponseTruncated = true
+	}
+	if !r.stream {
+		return
+	}
+	r.line = append(r.line, p...)
+	for {
+		end := bytes.IndexByte(r.line, '\n')
+		if end < 0 {
+			return
+		}
+		r.collectLine(string(r.line[:end]))
+		r.line = r.line[end+1:]
+	}
+}
+
+// collectLine 提取一行 SSE data: 中的回复文本
+func (r *playgroundRun) collectLine(line string) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "data:") {
+		return
+	}
+	payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	var chunk map[string]interface{}
+	if payload == "" || payload == "[DONE]" || json.Unmarshal([]byte(payload), &chunk) != nil {
+		return
+	}
+	r.answer.WriteString(playgroundText(chunk))
+}
+
+// finish 请求结束：非流式响应提取回复文本，记录状态和耗时
+func (r *playgroundRun) finish(ctxErr error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stream {
+		if len(r.line) > 0 {
+			r.collectLine(string(r.line))
+			r.line = nil
+		}
+	} else {
+		var data map[string]interface{}
+		if json.Unmarshal(r.response.Bytes(), &data) == nil {
+			r.answer.WriteString(playgroundText(data))
+			if r.session.StatusCode >= 400 {
+				r.session.Error = upstreamErrorMessage(r.response.Bytes())
+			}
+		} else {
+			// 未按 text/event-stream 返回的流式响应
+			for _, line := range strings.Split(r.response.String(), "\n") {
+				r.collectLine(line)
+			}
+		}
+	}
+	r.session.LatencyMs = time.Since(r.start).Milliseconds()
+	r.finishedAt = time.Now()
+
+	switch {
+	case r.session.Status == PlaygroundCancelled:
+	case ctxErr != nil:
+		r.session.Status = PlaygroundFailed
+		if r.session.Error == "" {
+			r.session.Error = ctxErr.Error()
+		}
+	case r.session.StatusCode >= 400:
+		r.session.Status = PlaygroundFailed
+		if r.session.Error == "" {
+			r.session.Error = fmt.Sprintf("HTTP %d", r.session.StatusCode)
+		}
+	default:
+		r.session.Status = PlaygroundCompleted
+	}
+}
+
+// playgroundText 提取 OpenAI、Claude、Gemini 格式响应（或流式事件）中的回复文本
+func playgroundText(data map[string]interface{}) string {
+	var sb strings.Builder
+	// OpenAI: choices[0].message.content / choices[0].delta.content
+	if choices, ok := data["choices"].([]interface{}); ok && len(choices) > 0 {
+		choice, _ := choices[0].(map[string]interface{})
+		for _, key := range []string{"message", "delta"} {
+			if msg, ok := choice[key].(map[string]interface{}); ok {
+				content, _ := msg["content"].(string)
+				sb.WriteString(content)
+			}
+		}
+		return sb.String()
+	}
+	// Claude: content[].text / content_block_delta 事件的 delta.text
+	if content, ok := data["content"].([]interface{}); ok {
+		for _, item := range content {
+			block, _ := item.(map[string]interface{})
+			if block["type"] == "text" {
+				text, _ := block["text"].(string)
+				sb.WriteString(text)
+			}
+		}
+		return sb.String()
+	}
+	if delta, ok := data["delta"].(map[string]interface{}); ok && delta["type"] == "text_delta" {
+		text, _ := delta["text"].(string)
+		return text
+	}
+	// Gemini: candidates[0].content.parts[].text（不含思考内容）
+	if candidates, ok := data["candidates"].([]interface{}); ok && len(candidates) > 0 {
+		candidate, _ := candidates[0].(map[string]interface{})
+		content, _ := candidate["content"].(map[string]interface{})
+		parts, _ := content["parts"].([]interface{})
+		for _, item := range parts {
+			part, _ := item.(map[string]interface{})
+			if thought, _ := part["thought"].(bool); thought {
+				continue
+			}
+			text, _ := part["text"].(string)
+			sb.WriteString(text)
+		}
+	}
+	return sb.String()
+}
+
+// playgroundWriter 接收本地 API 的响应，写入会话
+type playgroundWriter struct {
+	run         *playgroundRun
+	header      http.Header
+	wroteHeader bool
+}
+
+func (w *playgroundWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *playgroundWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.run.mu.Lock()
+	w.run.session.StatusCode = status
+	w.run.stream = strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream")
+	w.run.mu.Unlock()
+}
+
+func (w *playgroundWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.run.write(p)
+	return len(p), nil
+}
+
+// Flush 流式响应每个事件都会 flush，数据已直接写入会话
+func (w *playgroundWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 提示词模板的作用范围
+const (
+	PromptScopeRoute = "route" // target 为路由 ID
+	PromptScopeGroup = "group" // target 为分组名，空分组使用 "default"
+)
+
+// 提示词注入方式
+const (
+	PromptModePrepend = "prepend" // 放在客户端 system 提示词之前
+	PromptModeAppend  = "append"  // 放在客户端 system 提示词之后
+	PromptModeReplace = "replace" // 替换客户端的 system 提示词
+)
+
+// PromptTemplate 路由或分组的系统提示词模板
+// content 支持变量：{{model}} 请求的模型名、{{route}} 路由名、{{group}} 分组名、{{date}} 当天日期
+type PromptTemplate struct {
+	ID        int64     `json:"id"`
+	Scope     string    `json:"scope"`
+	Target    string    `json:"target"`
+	Mode      string    `json:"mode"`
+	Content   string    `json:"content"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// promptGroupName 分组模板匹配使用的分组名
+func promptGroupName(group string) string {
+	if group == "" {
+		return "default"
+	}
+	return group
+}
+
+// normalizePromptTemplate 校验并规范化模板
+func normalizePromptTemplate(t *PromptTemplate) error {
+	t.Scope = strings.ToLower(strings.TrimSpace(t.Scope))
+	t.Target = strings.TrimSpace(t.Target)
+	t.Mode = strings.ToLower(strings.TrimSpace(t.Mode))
+
+	switch t.Scope {
+	case PromptScopeRoute:
+		if id, err := strconv.ParseInt(t.Target, 10, 64); err != nil || id <= 0 {
+			return fmt.Errorf("invalid route id: %q", t.Target)
+		}
+	case PromptScopeGroup:
+		t.Target = promptGroupName(t.Target)
+	default:
+		return fmt.Errorf("invalid prompt scope: %q", t.Scope)
+	}
+
+	switch t.Mode {
+	case "":
+		t.Mode = PromptModePrepend
+	case PromptModePrepend, PromptModeAppend, PromptModeReplace:
+	default:
+		return fmt.Errorf("invalid prompt mode: %q", t.Mode)
+	}
+
+	if strings.TrimSpace(t.Content) == "" && t.Mode != PromptModeReplace {
+		return fmt.Errorf("prompt content is required")
+	}
+	return nil
+}
+
+// GetPromptTemplates 获取所有提示词模板
+func (s *RouteService) GetPromptTemplates() ([]PromptTemplate, error) {
+	rows, err := s.db.Query(`
+		SELECT id, scope, target, mode, COALESCE(content, ''), enabled, created_at, updated_at
+		FROM prompt_templates ORDER BY scope DESC, target`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]PromptTemplate, 0)
+	for rows.Next() {
+		var t PromptTemplate
+		var enabled int
+		if err := rows.Scan(&t.ID, &t.Scope, &t.Target, &t.Mode, &t.Content, &enabled, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		t.Enabled = enabled == 1
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// SavePromptTemplate 新增或更新提示词模板，同一路由或分组只能有一个模板
+func (s *RouteService) SavePromptTemplate(t PromptTemplate) (PromptTemplate, error) {
+	if err := normalizePromptTemplate(&t); err != nil {
+		return t, err
+	}
+
+	var existingID int64
+	err := s.db.QueryRow(`SELECT id FROM prompt_templates WHERE scope = ? AND target = ?`, t.Scope, t.Target).Scan(&existingID)
+	if err == nil && existingID != t.ID {
+		if t.ID != 0 {
+			return t, fmt.Errorf("a prompt template for %s %s already exists", t.Scope, t.Target)
+		}
+		t.ID = existingID
+	}
+
+	enabled := 0
+	if t.Enabled {
+		enabled = 1
+	}
+	now := time.Now()
+	if t.ID == 0 {
+		_, err = s.db.Exec(`
+			INSERT INTO prompt_templates (scope, target, mode, content, enabled, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			t.Scope, t.Target, t.Mode, t.Content, enabled, now, now)
+		if err == nil {
+			err = s.db.QueryRow(`SELECT id FROM prompt_templates WHERE scope = ? AND target = ?`, t.Scope, t.Target).Scan(&t.ID)
+		}
+	} else {
+		_, err = s.db.Exec(`
+			UPDATE prompt_templates SET scope = ?, target = ?, mode = ?, content = ?, enabled = ?, updated_at = ?
+			WHERE id = ?`,
+			t.Scope, t.Target, t.Mode, t.Content, enabled, now, t.ID)
+	}
+	if err != nil {
+		log.Errorf("Failed to save prompt template: %v", err)
+		return t, err
+	}
+	t.UpdatedAt = now
+	log.Infof("Prompt template saved: %s %s (mode=%s, enabled=%v)", t.Scope, t.Target, t.Mode, t.Enabled)
+	return t, nil
+}
+
+// DeletePromptTemplate 删除提示词模板
+func (s *RouteService) DeletePromptTemplate(id int64) error {
+	result, err := s.db.Exec(`DELETE FROM prompt_templates WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("prompt template not found: id=%d", id)
+	}
+	log.Infof("Prompt template deleted: id=%d", id)
+	return nil
+}
+
+// PromptTemplateForRoute 查找路由生效的模板：路由模板优先，其次是分组模板，都没有时返回 nil
+func (s *RouteService) PromptTemplateForRoute(route *database.ModelRoute) *PromptTemplate {
+	rows, err := s.db.Query(`
+		SELECT id, scope, target, mode, COALESCE(content, '')
+		FROM prompt_templates
+		WHERE enabled = 1 AND ((scope = ? AND target = ?) OR (scope = ? AND target = ?))`,
+		PromptScopeRoute, strconv.FormatInt(route.ID, 10), PromptScopeGroup, promptGroupName(route.Group))
+	if err != nil {
+		log.Warnf("Failed to load prompt templates: %v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var found *PromptTemplate
+	for rows.Next() {
+		var t PromptTemplate
+		if err := rows.Scan(&t.ID, &t.Scope, &t.Target, &t.Mode, &t.Content); err != nil {
+			return nil
+		}
+		t.Enabled = true
+		if found == nil || t.Scope == PromptScopeRoute {
+			found = &t
+		}
+	}
+	return found
+}
+
+// renderPromptTemplate 替换模板变量
+func renderPromptTemplate(content, model string, route *database.ModelRoute) string {
+	return strings.NewReplacer(
+		"{{model}}", model,
+		"{{route}}", route.Name,
+		"{{group}}", promptGroupName(route.Group),
+		"{{date}}", time.Now().Format("2006-01-02"),
+	).Replace(content)
+}
+
+// joinSystemPrompt 按注入方式合并模板和客户端的 system 提示词
+func joinSystemPrompt(mode, prompt, existing string) string {
+	switch {
+	case mode == PromptModeReplace || existing == "":
+		return prompt
+	case prompt == "":
+		return existing
+	case mode == PromptModeAppend:
+		return existing + "\n\n" + prompt
+	default:
+		return prompt + "\n\n" + existing
+	}
+}
+
+// applyPromptTemplate 在格式转换前将路由的提示词模板注入客户端请求
+// 返回新的请求对象（不修改原请求，Fallback 时每条路由可能使用不同的模板），未注入时返回原请求和 false
+func (s *ProxyService) applyPromptTemplate(reqData map[string]interface{}, route *database.ModelRoute, requestFormat, model string) (map[string]interface{}, bool) {
+	tpl := s.routeService.PromptTemplateForRoute(route)
+	if tpl == nil {
+		return reqData, false
+	}
+	prompt := renderPromptTemplate(tpl.Content, model, route)
+
+	result := make(map[string]interface{}, len(reqData)+1)
+	for k, v := range reqData {
+		result[k] = v
+	}
+	switch requestFormat {
+	case "claude":
+		injectClaudeSystem(result, tpl.Mode, prompt)
+	case "gemini":
+		injectGeminiSystem(result, tpl.Mode, prompt)
+	default:
+		injectOpenAISystem(result, tpl.Mode, prompt)
+	}
+	log.Infof("[Prompt] Applied %s prompt template (%s %s, mode=%s) for route %s", requestFormat, tpl.Scope, tpl.Target, tpl.Mode, route.Name)
+	return result, true
+}
+
+// withPromptTemplate 注入提示词模板并在需要时重新序列化请求体，没有模板时原样返回
+func (s *ProxyService) withPromptTemplate(reqData map[string]interface{}, requestBody []byte, route *database.ModelRoute, requestFormat, model string) (map[string]interface{}, []byte) {
+	injected, ok := s.applyPromptTemplate(reqData, route, requestFormat, model)
+	if !ok {
+		return reqData, requestBody
+	}
+	body, err := json.Marshal(injected)
+	if err != nil {
+		log.Warnf("[Prompt] Failed to encode request with prompt template: %v", err)
+		return reqData, requestBody
+	}
+	return injected, body
+}
+
+// injectOpenAISystem OpenAI 格式：插入 system 消息，replace 时移除客户端的 system/developer 消息
+func injectOpenAISystem(reqData map[string]interface{}, mode, prompt string) {
+	messages, _ := reqData["messages"].([]interface{})
+	result := make([]interface{}, 0, len(messages)+1)
+
+	// 开头连续的 system/developer 消息视为客户端的 system 提示词
+	leading := 0
+	for leading < len(messages) {
+		msg, _ := messages[leading].(map[string]interface{})
+		if role, _ := msg["role"].(string); role != "system" && role != "developer" {
+			break
+		}
+		leading++
+	}
+
+	systemMsg := map[string]interface{}{"role": "system", "content": prompt}
+	switch mode {
+	case PromptModeReplace:
+		if prompt != "" {
+			result = append(result, systemMsg)
+		}
+		for _, msg := range messages {
+			msgMap, _ := msg.(map[string]interface{})
+			if role, _ := msgMap["role"].(string); role != "system" && role != "developer" {
+				result = append(result, msg)
+			}
+		}
+	case PromptModeAppend:
+		result = append(result, messages[:leading]...)
+		result = append(result, systemMsg)
+		result = append(result, messages[leading:]...)
+	default:
+		result = append(result, systemMsg)
+		result = append(result, messages...)
+	}
+	reqData["messages"] = result
+}
+
+// injectClaudeSystem Claude 格式：system 可以是字符串或文本块数组
+func injectClaudeSystem(reqData map[string]interface{}, mode, prompt string) {
+	blocks, isBlocks := reqData["system"].([]interface{})
+	if !isBlocks || mode == PromptModeReplace {
+		existing, _ := reqData["system"].(string)
+		if system := joinSystemPrompt(mode, prompt, existing); system != "" {
+			reqData["system"] = system
+		} else {
+			delete(reqData, "system")
+		}
+		return
+	}
+
+	// 保留客户端的文本块（可能带 cache_control），在前面或后面增加一个文本块
+	block := map[string]interface{}{"type": "text", "text": prompt}
+	result := make([]interface{}, 0, len(blocks)+1)
+	if mode == PromptModeAppend {
+		result = append(append(result, blocks...), block)
+	} else {
+		result = append(append(result, block), blocks...)
+	}
+	reqData["system"] = result
+}
+
+// injectGeminiSystem Gemini 格式：修改 systemInstruction.parts（system_instruction 写法统一为 systemInstruction）
+func injectGeminiSystem(reqData map[string]interface{}, mode, prompt string) {
+	instruction, ok := reqData["systemInstruction"].(map[string]interface{})
+	if !ok {
+		instruction, _ = reqData["system_instruction"].(map[string]interface{})
+	}
+	delete(reqData, "system_instruction")
+
+	var parts []interface{}
+	if instruction != nil && mode != PromptModeReplace {
+		parts, _ = instruction["parts"].([]interface{})
+	}
+	// 各 part 的文本会直接拼接，与客户端内容之间加空行分隔
+	result := make([]interface{}, 0, len(parts)+1)
+	switch {
+	case prompt == "":
+		result = append(result, parts...)
+	case len(parts) == 0:
+		result = append(result, map[string]interface{}{"text": prompt})
+	case mode == PromptModeAppend:
+		result = append(append(result, parts...), map[string]interface{}{"text": "\n\n" + prompt})
+	default:
+		result = append(append(result, map[string]interface{}{"text": prompt + "\n\n"}), parts...)
+	}
+
+	if len(result) == 0 {
+		delete(reqData, "systemInstruction")
+		return
+	}
+	reqData["systemInstruction"] = map[string]interface{}{"parts": result}
+}
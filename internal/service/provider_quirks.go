@@ -0,0 +1,180 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"openai-router-go/internal/adapters"
+)
+
+// providerKind 识别需要特殊处理的供应商：优先使用路由格式，其次根据 API 地址判断
+// 返回 mistral、xai、cohere、ollama、deepseek 或空字符串
+func providerKind(apiURL, format string) string {
+	switch f := strings.ToLower(strings.TrimSpace(format)); f {
+	case "mistral", "xai", "cohere", "ollama", "deepseek":
+		return f
+	case "grok":
+		return "xai"
+	}
+	lowerURL := strings.ToLower(apiURL)
+	switch {
+	case strings.Contains(lowerURL, "api.mistral.ai"):
+		return "mistral"
+	case strings.Contains(lowerURL, "api.x.ai"):
+		return "xai"
+	case strings.Contains(lowerURL, "api.cohere.com"), strings.Contains(lowerURL, "api.cohere.ai"):
+		return "cohere"
+	case strings.Contains(lowerURL, "api.deepseek.com"):
+		return "deepseek"
+	}
+	return ""
+}
+
+// buildRouteChatURL 构建 OpenAI 兼容的 chat/completions URL
+// Ollama 和 Cohere 的 OpenAI 兼容接口不在标准路径下，需要单独处理
+func buildRouteChatURL(apiURL, format string) string {
+	switch providerKind(apiURL, format) {
+	case "ollama":
+		return ollamaBaseURL(apiURL) + "/v1/chat/completions"
+	case "cohere":
+		if !strings.HasSuffix(apiURL, "/") {
+			return cohereBaseURL(apiURL) + "/compatibility/v1/chat/completions"
+		}
+	}
+	return buildOpenAIChatURL(apiURL)
+}
+
+// cohereBaseURL 去掉 /v1、/v2、/compatibility/v1 等后缀，得到 Cohere API 根地址
+func cohereBaseURL(apiURL string) string {
+	base := strings.TrimSuffix(strings.TrimSpace(apiURL), "/")
+	for _, suffix := range []string{"/compatibility/v1", "/v2/chat", "/v2", "/v1"} {
+		if strings.HasSuffix(base, suffix) {
+			return strings.TrimSuffix(base, suffix)
+		}
+	}
+	return base
+}
+
+// buildCohereChatURL 构建 Cohere Chat API v2 URL
+func buildCohereChatURL(apiURL string) string {
+	return cohereBaseURL(apiURL) + "/v2/chat"
+}
+
+// mistral 不支持的 OpenAI 参数（传入会返回 422）
+var mistralUnsupportedParams = []string{
+	"stream_options", "user", "logit_bias", "logprobs", "top_logprobs",
+	"service_tier", "store", "metadata", "modalities", "audio", "reasoning_effort",
+}
+
+// mistralToolCallIDPattern Mistral 要求 tool_call id 为 9 位字母数字
+var mistralToolCallIDPattern = regexp.MustCompile(`^[a-zA-Z0-9]{9}$`)
+
+// applyProviderQuirks 按供应商修正发往 OpenAI 兼容接口的请求体中已知不兼容的参数
+// 无需修正或解析失败时原样返回
+func applyProviderQuirks(apiURL, format, model string, body []byte) []byte {
+	kind := providerKind(apiURL, format)
+	if kind != "mistral" && kind != "xai" && kind != "deepseek" {
+		return body
+	}
+	// DeepSeek 的 Anthropic 兼容接口（格式为 claude 的路由）不需要修正
+	if kind == "deepseek" && normalizeFormat(format) != "openai" {
+		return body
+	}
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		return body
+	}
+
+	switch kind {
+	case "mistral":
+		fixMistralRequest(reqData)
+	case "xai":
+		fixXAIRequest(reqData, model)
+	case "deepseek":
+		// OpenAI 请求已由 openai-to-deepseek 适配器处理，这里修正 Claude/Gemini 请求转换后的结果
+		adapters.FixDeepSeekRequest(reqData, model)
+	}
+
+	fixed, err := json.Marshal(reqData)
+	if err != nil {
+		return body
+	}
+	return fixed
+}
+
+// fixMistralRequest Mistral: 去掉不支持的参数，seed -> random_seed，tool_choice required -> any，tool_call id 规范化
+func fixMistralRequest(reqData map[string]interface{}) {
+	for _, key := range mistralUnsupportedParams {
+		delete(reqData, key)
+	}
+	if v, ok := reqData["max_completion_tokens"]; ok {
+		if _, exists := reqData["max_tokens"]; !exists {
+			reqData["max_tokens"] = v
+		}
+		delete(reqData, "max_completion_tokens")
+	}
+	if v, ok := reqData["seed"]; ok {
+		reqData["random_seed"] = v
+		delete(reqData, "seed")
+	}
+	if reqData["tool_choice"] == "required" {
+		reqData["tool_choice"] = "any"
+	}
+
+	messages, _ := reqData["messages"].([]interface{})
+	for _, msg := range messages {
+		msgMap, ok := msg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if toolCalls, ok := msgMap["tool_calls"].([]interface{}); ok {
+			for _, tc := range toolCalls {
+				if tcMap, ok := tc.(map[string]interface{}); ok {
+					if id, ok := tcMap["id"].(string); ok {
+						tcMap["id"] = mistralToolCallID(id)
+					}
+				}
+			}
+		}
+		if id, ok := msgMap["tool_call_id"].(string); ok {
+			msgMap["tool_call_id"] = mistralToolCallID(id)
+		}
+	}
+}
+
+// mistralToolCallID 将其他供应商的 tool_call id（如 toolu_xxx、call_xxx）映射为 9 位字母数字
+// 同一 id 的映射结果固定，保证 assistant 和 tool 消息中的 id 一致
+func mistralToolCallID(id string) string {
+	if mistralToolCallIDPattern.MatchString(id) {
+		return id
+	}
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return fmt.Sprintf("%016x", h.Sum64())[:9]
+}
+
+// isXAIReasoningModel grok-3-mini、grok-4、grok-code 等推理模型
+func isXAIReasoningModel(model string) bool {
+	lower := strings.ToLower(model)
+	return strings.Contains(lower, "grok-3-mini") || strings.HasPrefix(lower, "grok-4") || strings.HasPrefix(lower, "grok-code")
+}
+
+// fixXAIRequest xAI: 推理模型不支持 presence_penalty、frequency_penalty、stop，只有 grok-3-mini 支持 reasoning_effort
+func fixXAIRequest(reqData map[string]interface{}, model string) {
+	if m, ok := reqData["model"].(string); ok && m != "" {
+		model = m
+	}
+	if !isXAIReasoningModel(model) {
+		delete(reqData, "reasoning_effort")
+		return
+	}
+	delete(reqData, "presence_penalty")
+	delete(reqData, "frequency_penalty")
+	delete(reqData, "stop")
+	if !strings.Contains(strings.ToLower(model), "grok-3-mini") {
+		delete(reqData, "reasoning_effort")
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+// 供应商上游认证方式
+const (
+	AuthStyleBearer  = "bearer"    // Authorization: Bearer <key>
+	AuthStyleXAPIKey = "x-api-key" // x-api-key: <key>（Anthropic）
+	AuthStyleNone    = "none"      // 本地服务，不需要 Key
+)
+
+// 获取模型列表的方式
+const (
+	ModelsHintOpenAI    = "openai"    // GET {base}/v1/models（或末尾带斜杠时 {base}models）
+	ModelsHintAnthropic = "anthropic" // GET /v1/models，使用 x-api-key 认证
+	ModelsHintOllama    = "ollama"    // GET /api/tags，列出本地已下载的模型
+)
+
+// ProviderTemplate 常用供应商的路由模板，新增路由时选择供应商后只需填写 Key
+type ProviderTemplate struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	BaseURL      string   `json:"base_url"`      // 路由 API 地址，末尾斜杠表示固定路径；{resource} 等占位符需要用户替换
+	Format       string   `json:"format"`        // 路由格式
+	AuthStyle    string   `json:"auth_style"`    // 上游认证方式
+	ModelsHint   string   `json:"models_hint"`   // 获取模型列表的方式
+	DefaultModel string   `json:"default_model"` // 默认填入的模型，为空表示需要先获取模型列表
+	Models       []string `json:"models"`        // 常用模型，获取模型列表失败时可直接选择
+	KeyRequired  bool     `json:"key_required"`
+	DocsURL      string   `json:"docs_url"`
+}
+
+// providerTemplates 内置的供应商目录
+var providerTemplates = []ProviderTemplate{
+	{
+		ID: "openai", Name: "OpenAI", BaseURL: "https://api.openai.com", Format: "openai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DefaultModel: "gpt-4o-mini", Models: []string{"gpt-4o", "gpt-4o-mini", "gpt-4.1", "o3-mini"},
+		DocsURL: "https://platform.openai.com/docs/api-reference",
+	},
+	{
+		ID: "anthropic", Name: "Anthropic", BaseURL: "https://api.anthropic.com", Format: "claude",
+		AuthStyle: AuthStyleXAPIKey, ModelsHint: ModelsHintAnthropic, KeyRequired: true,
+		DefaultModel: "claude-sonnet-4-20250514", Models: []string{"claude-opus-4-20250514", "claude-sonnet-4-20250514", "claude-3-5-haiku-20241022"},
+		DocsURL: "https://docs.anthropic.com/en/api",
+	},
+	{
+		// Gemini 的 OpenAI 兼容接口
+		ID: "gemini", Name: "Google Gemini", BaseURL: "https://generativelanguage.googleapis.com/v1beta/openai/", Format: "openai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DefaultModel: "gemini-2.5-flash", Models: []string{"gemini-2.5-pro", "gemini-2.5-flash", "gemini-2.0-flash"},
+		DocsURL: "https://ai.google.dev/gemini-api/docs/openai",
+	},
+	{
+		ID: "groq", Name: "Groq", BaseURL: "https://api.groq.com/openai/v1/", Format: "openai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DefaultModel: "llama-3.3-70b-versatile", Models: []string{"llama-3.3-70b-versatile", "llama-3.1-8b-instant"},
+		DocsURL: "https://console.groq.com/docs/openai",
+	},
+	{
+		ID: "deepseek", Name: "DeepSeek", BaseURL: "https://api.deepseek.com", Format: "deepseek",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DefaultModel: "deepseek-chat", Models: []string{"deepseek-chat", "deepseek-reasoner"},
+		DocsURL: "https://api-docs.deepseek.com",
+	},
+	{
+		ID: "openrouter", Name: "OpenRouter", BaseURL: "https://openrouter.ai/api", Format: "openai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DocsURL: "https://openrouter.ai/docs/api-reference/overview",
+	},
+	{
+		ID: "together", Name: "Together AI", BaseURL: "https://api.together.xyz", Format: "openai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DocsURL: "https://docs.together.ai/docs/openai-api-compatibility",
+	},
+	{
+		ID: "fireworks", Name: "Fireworks AI", BaseURL: "https://api.fireworks.ai/inference", Format: "openai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DocsURL: "https://docs.fireworks.ai/tools-sdks/openai-compatibility",
+	},
+	{
+		ID: "moonshot", Name: "Moonshot", BaseURL: "https://api.moonshot.cn", Format: "openai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DocsURL: "https://platform.moonshot.cn/docs/api/chat",
+	},
+	{
+		ID: "siliconflow", Name: "SiliconFlow", BaseURL: "https://api.siliconflow.cn", Format: "openai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DocsURL: "https://docs.siliconflow.cn/cn/api-reference/chat-completions/chat-completions",
+	},
+	{
+		ID: "mistral", Name: "Mistral", BaseURL: "https://api.mistral.ai", Format: "mistral",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DefaultModel: "mistral-large-latest", Models: []string{"mistral-large-latest", "mistral-small-latest", "codestral-latest"},
+		DocsURL: "https://docs.mistral.ai/api/",
+	},
+	{
+		ID: "xai", Name: "xAI Grok", BaseURL: "https://api.x.ai", Format: "xai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DefaultModel: "grok-4", Models: []string{"grok-4", "grok-3-mini"},
+		DocsURL: "https://docs.x.ai/docs/api-reference",
+	},
+	{
+		ID: "cohere", Name: "Cohere", BaseURL: "https://api.cohere.com", Format: "cohere",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DefaultModel: "command-a-03-2025", Models: []string{"command-a-03-2025", "command-r-plus"},
+		DocsURL: "https://docs.cohere.com/reference/about",
+	},
+	{
+		ID: "ollama", Name: "Ollama", BaseURL: "http://localhost:11434", Format: "ollama",
+		AuthStyle: AuthStyleNone, ModelsHint: ModelsHintOllama,
+		DocsURL: "https://github.com/ollama/ollama/blob/main/docs/api.md",
+	},
+	{
+		ID: "lmstudio", Name: "LM Studio", BaseURL: "http://localhost:1234", Format: "openai",
+		AuthStyle: AuthStyleNone, ModelsHint: ModelsHintOpenAI,
+		DocsURL: "https://lmstudio.ai/docs/app/api/endpoints/openai",
+	},
+	{
+		// Azure OpenAI v1 接口，{resource} 替换为资源名，模型填部署名
+		ID: "azure", Name: "Azure OpenAI", BaseURL: "https://{resource}.openai.azure.com/openai/v1/", Format: "openai",
+		AuthStyle: AuthStyleBearer, ModelsHint: ModelsHintOpenAI, KeyRequired: true,
+		DocsURL: "https://learn.microsoft.com/azure/ai-foundry/openai/api-version-lifecycle",
+	},
+}
+
+// providerTemplate 按 ID 查找内置模板
+func providerTemplate(id string) (ProviderTemplate, bool) {
+	for _, tpl := range providerTemplates {
+		if tpl.ID == id {
+			return tpl, true
+		}
+	}
+	return ProviderTemplate{}, false
+}
+
+// GetProviderTemplates 返回内置的供应商模板
+func GetProviderTemplates() []ProviderTemplate {
+	templates := make([]ProviderTemplate, len(providerTemplates))
+	copy(templates, providerTemplates)
+	return templates
+}
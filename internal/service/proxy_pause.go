@@ -0,0 +1,17 @@
+package service
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// SetPaused 暂停/恢复代理，暂停时代理接口返回 503，管理接口和网页管理界面不受影响
+func (s *ProxyService) SetPaused(paused bool) {
+	if s.paused.Swap(paused) != paused {
+		log.Infof("Proxy paused: %v", paused)
+	}
+}
+
+// IsPaused 代理是否已暂停
+func (s *ProxyService) IsPaused() bool {
+	return s.paused.Load()
+}
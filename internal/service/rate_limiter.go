@@ -0,0 +1,133 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket 简单令牌桶，按每分钟请求数 (RPM) 补充令牌
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64 // 每秒补充的令牌数
+	last     time.Time
+}
+
+// take 尝试取出一个令牌，失败时返回需要等待的时间
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// providerLimiter 供应商级别的限流器
+// 以上游账号（API 主机 + API Key）为单位共享令牌桶，多个模型别名指向同一账号时共同计数
+type providerLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newProviderLimiter() *providerLimiter {
+	return &providerLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+// providerKey 根据请求生成供应商账号标识（不保存明文 Key）
+func providerKey(req *http.Request) string {
+	credential := req.Header.Get("Authorization")
+	if credential == "" {
+		credential = req.Header.Get("x-api-key")
+	}
+	if credential == "" {
+		credential = req.Header.Get("x-goog-api-key")
+	}
+	if credential == "" {
+		credential = req.URL.Query().Get("key")
+	}
+	sum := sha256.Sum256([]byte(credential))
+	return strings.ToLower(req.URL.Host) + "#" + hex.EncodeToString(sum[:8])
+}
+
+// allow 检查并消耗一个令牌，rpm <= 0 表示不限流
+func (l *providerLimiter) allow(key string, rpm int) (bool, time.Duration) {
+	if rpm <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[key]
+	if !ok || bucket.capacity != float64(rpm) {
+		bucket = &tokenBucket{
+			tokens:   float64(rpm),
+			capacity: float64(rpm),
+			rate:     float64(rpm) / 60,
+			last:     now,
+		}
+		l.buckets[key] = bucket
+	}
+	return bucket.take(now)
+}
+
+// providerRPM 获取指定主机的 RPM 限制，"*" 为默认值
+func (s *ProxyService) providerRPM(host string) int {
+	if s.config == nil || len(s.config.ProviderRPMLimits) == 0 {
+		return 0
+	}
+	host = strings.ToLower(host)
+	if rpm, ok := s.config.ProviderRPMLimits[host]; ok {
+		return rpm
+	}
+	if h, _, found := strings.Cut(host, ":"); found {
+		if rpm, ok := s.config.ProviderRPMLimits[h]; ok {
+			return rpm
+		}
+	}
+	return s.config.ProviderRPMLimits["*"]
+}
+
+// checkProviderLimit 检查供应商账号限流，超限时返回本地构造的 429 响应（以便触发 Fallback）
+func (s *ProxyService) checkProviderLimit(req *http.Request, routeName string) *http.Response {
+	rpm := s.providerRPM(req.URL.Host)
+	if rpm <= 0 {
+		return nil
+	}
+	ok, wait := s.limiter.allow(providerKey(req), rpm)
+	if ok {
+		return nil
+	}
+
+	retryAfter := int(wait.Seconds()) + 1
+	body := fmt.Sprintf(`{"error":{"message":"provider rate limit exceeded for %s (%d rpm), retry after %ds","type":"rate_limit_error"}}`,
+		req.URL.Host, rpm, retryAfter)
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+	return &http.Response{
+		Status:        "429 Too Many Requests",
+		StatusCode:    http.StatusTooManyRequests,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
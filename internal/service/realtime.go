@@ -0,0 +1,366 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RealtimeKeyProtocol 浏览器客户端无法设置请求头时，通过子协议 openai-insecure-api-key.<key> 传递 API Key
+const RealtimeKeyProtocol = "openai-insecure-api-key."
+
+// realtimeMaxEventSize 解析 usage 时单条事件的上限，超过的帧（通常是音频数据）直接跳过
+const realtimeMaxEventSize = 4 * 1024 * 1024
+
+// realtimeForwardHeaders 握手时转发给上游的客户端请求头
+// 不转发 Sec-WebSocket-Extensions：协商 permessage-deflate 后无法从帧中解析 usage
+var realtimeForwardHeaders = []string{
+	"Sec-WebSocket-Key",
+	"Sec-WebSocket-Version",
+	"OpenAI-Beta",
+	"OpenAI-Organization",
+	"OpenAI-Project",
+	"User-Agent",
+}
+
+// RealtimeKeyFromProtocols 从 Sec-WebSocket-Protocol 中取出客户端传递的 API Key
+func RealtimeKeyFromProtocols(header string) string {
+	for _, p := range strings.Split(header, ",") {
+		if p = strings.TrimSpace(p); strings.HasPrefix(p, RealtimeKeyProtocol) {
+			return strings.TrimPrefix(p, RealtimeKeyProtocol)
+		}
+	}
+	return ""
+}
+
+// upstreamProtocols 去掉携带本地 API Key 的子协议，其余原样转发
+func upstreamProtocols(header string) string {
+	var protocols []string
+	for _, p := range strings.Split(header, ",") {
+		if p = strings.TrimSpace(p); p != "" && !strings.HasPrefix(p, RealtimeKeyProtocol) {
+			protocols = append(protocols, p)
+		}
+	}
+	return strings.Join(protocols, ", ")
+}
+
+// buildRealtimeURL 构建上游 Realtime 地址，ws/wss 地址转换为 http/https 交给 HTTP 客户端握手
+// 客户端的其他查询参数（如 intent）保留，model 替换为路由的模型
+func buildRealtimeURL(apiUrl, model string, query url.Values) string {
+	if strings.HasPrefix(apiUrl, "wss://") {
+		apiUrl = "https://" + strings.TrimPrefix(apiUrl, "wss://")
+	} else if strings.HasPrefix(apiUrl, "ws://") {
+		apiUrl = "http://" + strings.TrimPrefix(apiUrl, "ws://")
+	}
+	params := url.Values{}
+	for key, values := range query {
+		if key != "model" && key != "key" {
+			params[key] = values
+		}
+	}
+	params.Set("model", model)
+	return buildOpenAIEndpointURL(apiUrl, "realtime") + "?" + params.Encode()
+}
+
+// realtimeUsage 解析上游发给客户端的 WebSocket 帧，累计 response.done 事件中的 usage
+// 只读不改，转发的数据不受影响
+type realtimeUsage struct {
+	buf     []byte
+	skip    uint64 // 当前超大帧还需跳过的字节数
+	message []byte // 分片的文本消息
+	inText  bool
+
+	responses    int
+	inputTokens  int
+	outputTokens int
+	totalTokens  int
+}
+
+func (u *realtimeUsage) Write(p []byte) (int, error) {
+	u.buf = append(u.buf, p...)
+	for u.next() {
+	}
+	return len(p), nil
+}
+
+// next 解析一帧，数据不完整时返回 false
+func (u *realtimeUsage) next() bool {
+	if u.skip > 0 {
+		n := u.skip
+		if n > uint64(len(u.buf)) {
+			n = uint64(len(u.buf))
+		}
+		u.buf = u.buf[n:]
+		u.skip -= n
+		if u.skip > 0 {
+			return false
+		}
+	}
+	if len(u.buf) < 2 {
+		return false
+	}
+
+	fin := u.buf[0]&0x80 != 0
+	opcode := u.buf[0] & 0x0f
+	masked := u.buf[1]&0x80 != 0
+	length := uint64(u.buf[1] & 0x7f)
+	headerLen := 2
+	switch length {
+	case 126:
+		if len(u.buf) < 4 {
+			return false
+		}
+		length = uint64(binary.BigEndian.Uint16(u.buf[2:4]))
+		headerLen = 4
+	case 127:
+		if len(u.buf) < 10 {
+			return false
+		}
+		length = binary.BigEndian.Uint64(u.buf[2:10])
+		headerLen = 10
+	}
+	if masked {
+		headerLen += 4
+	}
+	if len(u.buf) < headerLen {
+		return false
+	}
+
+	if length > realtimeMaxEventSize || (opcode == 0 && u.inText && uint64(len(u.message))+length > realtimeMaxEventSize) {
+		u.buf = u.buf[headerLen:]
+		u.skip = length
+		if opcode < 8 {
+			u.message = u.message[:0]
+			u.inText = false
+		}
+		return true
+	}
+	if uint64(len(u.buf)-headerLen) < length {
+		return false
+	}
+
+	payload := u.buf[headerLen : headerLen+int(length)]
+	if masked {
+		mask := u.buf[headerLen-4 : headerLen]
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	u.buf = u.buf[headerLen+int(length):]
+
+	switch {
+	case opcode == 1:
+		u.message = append(u.message[:0], payload...)
+		u.inText = true
+	case opcode == 0 && u.inText:
+		u.message = append(u.message, payload...)
+	default:
+		// 二进制帧和控制帧（ping/pong/close）不解析
+		return true
+	}
+	if fin {
+		u.handle(u.message)
+		u.message = u.message[:0]
+		u.inText = false
+	}
+	return true
+}
+
+// handle 处理一条完整的服务端事件
+func (u *realtimeUsage) handle(message []byte) {
+	if !bytes.Contains(message, []byte(`"response.done"`)) {
+		return
+	}
+	var event struct {
+		Type     string `json:"type"`
+		Response struct {
+			Usage struct {
+				InputTokens  int `json:"input_tokens"`
+				OutputTokens int `json:"output_tokens"`
+				TotalTokens  int `json:"total_tokens"`
+			} `json:"usage"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(message, &event); err != nil {
+		return
+	}
+	if event.Type != "response.done" {
+		return
+	}
+	usage := event.Response.Usage
+	u.responses++
+	u.inputTokens += usage.InputTokens
+	u.outputTokens += usage.OutputTokens
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	}
+	u.totalTokens += usage.TotalTokens
+}
+
+// ProxyRealtime 代理 OpenAI Realtime API 的 WebSocket 连接（GET /v1/realtime?model=xxx）
+// 握手阶段按模型选择路由并注入上游 Key，上游握手失败时按 Fallback 规则切换路由；
+// 连接建立后双向原样转发，会话结束时按 response.done 事件累计的 usage 记录一条请求日志
+// 返回的状态码和错误仅在握手完成前有效
+func (s *ProxyService) ProxyRealtime(w http.ResponseWriter, r *http.Request, requestID, remoteIP string) (int, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return http.StatusBadRequest, fmt.Errorf("websocket upgrade required")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return http.StatusInternalServerError, fmt.Errorf("connection does not support hijacking")
+	}
+	model := r.URL.Query().Get("model")
+	if model == "" {
+		return http.StatusBadRequest, fmt.Errorf("'model' query parameter is required")
+	}
+
+	log.Infof("=== REALTIME SESSION START [%s] === model: %s", requestID, model)
+
+	routes, targetModel, err := s.selectRoutes(model, nil, routeOverrideHeaders(r.Header))
+	if err != nil {
+		return routeSelectionStatus(err), err
+	}
+	model = targetModel
+
+	headers := map[string]string{"Authorization": r.Header.Get("Authorization")}
+	var lastErr error
+	lastStatusCode := http.StatusBadGateway
+	for routeIndex, route := range routes {
+		targetURL := buildRealtimeURL(route.APIUrl, route.Model, r.URL.Query())
+		log.Infof("=== Trying route %d/%d: %s -> %s ===", routeIndex+1, len(routes), route.Name, targetURL)
+
+		proxyReq, err := http.NewRequest("GET", targetURL, nil)
+		if err != nil {
+			lastErr = err
+			lastStatusCode = http.StatusInternalServerError
+			continue
+		}
+		for _, key := range realtimeForwardHeaders {
+			if v := r.Header.Get(key); v != "" {
+				proxyReq.Header.Set(key, v)
+			}
+		}
+		if protocols := upstreamProtocols(r.Header.Get("Sec-WebSocket-Protocol")); protocols != "" {
+			proxyReq.Header.Set("Sec-WebSocket-Protocol", protocols)
+		}
+		proxyReq.Header.Set("Connection", "Upgrade")
+		proxyReq.Header.Set("Upgrade", "websocket")
+		setUpstreamAuth(proxyReq, route.APIUrl, route.APIKey, headers)
+		applyRouteExtras(proxyReq, route.ExtraHeaders, route.ExtraQuery)
+		proxyReq = withRequestID(proxyReq, requestID)
+
+		startTime := time.Now()
+		resp, err := s.httpClient.Do(proxyReq)
+		if err == nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+			lastStatusCode = resp.StatusCode
+			err = fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(errBody))
+		} else if err != nil {
+			lastStatusCode = http.StatusServiceUnavailable
+		}
+		if err != nil {
+			s.routeService.LogRequestFull(RequestLogParams{
+				RequestID:     requestID,
+				Model:         model,
+				ProviderModel: route.Model,
+				ProviderName:  route.Name,
+				RouteID:       route.ID,
+				Success:       false,
+				ErrorMessage:  err.Error(),
+				Style:         "openai",
+				UserAgent:     r.UserAgent(),
+				RemoteIP:      remoteIP,
+				IsStream:      true,
+				ProxyTimeMs:   time.Since(startTime).Milliseconds(),
+			})
+			lastErr = err
+			if s.fallbackNext(routes, routeIndex, lastStatusCode, nil) {
+				log.Warnf("Route %s realtime handshake failed: %v, trying fallback...", route.Name, err)
+				continue
+			}
+			return lastStatusCode, lastErr
+		}
+
+		upstream, ok := resp.Body.(io.ReadWriteCloser)
+		if !ok {
+			resp.Body.Close()
+			return http.StatusBadGateway, fmt.Errorf("upstream connection is not writable")
+		}
+		handshakeMs := time.Since(startTime).Milliseconds()
+
+		conn, brw, err := hijacker.Hijack()
+		if err != nil {
+			upstream.Close()
+			return http.StatusInternalServerError, err
+		}
+		resp.Header.Set("Connection", "Upgrade")
+		resp.Header.Set("Upgrade", "websocket")
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+		resp.Header.Write(brw)
+		brw.WriteString("\r\n")
+		if err := brw.Flush(); err != nil {
+			conn.Close()
+			upstream.Close()
+			return 0, nil
+		}
+
+		log.Infof("Realtime session [%s] connected to %s in %dms", requestID, route.Name, handshakeMs)
+		usage := s.relayRealtime(requestID, conn, brw.Reader, upstream)
+		duration := time.Since(startTime)
+		log.Infof("=== REALTIME SESSION END [%s] === %s, %d response(s), tokens: %d/%d/%d",
+			requestID, duration.Round(time.Millisecond), usage.responses, usage.inputTokens, usage.outputTokens, usage.totalTokens)
+
+		s.routeService.LogRequestFull(RequestLogParams{
+			RequestID:      requestID,
+			Model:          model,
+			ProviderModel:  route.Model,
+			ProviderName:   route.Name,
+			RouteID:        route.ID,
+			RequestTokens:  usage.inputTokens,
+			ResponseTokens: usage.outputTokens,
+			TotalTokens:    usage.totalTokens,
+			Success:        true,
+			Style:          "openai",
+			UserAgent:      r.UserAgent(),
+			RemoteIP:       remoteIP,
+			IsStream:       true,
+			ProxyTimeMs:    duration.Milliseconds(),
+			FirstChunkMs:   handshakeMs,
+		})
+		return http.StatusSwitchingProtocols, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("all routes failed")
+	}
+	return lastStatusCode, lastErr
+}
+
+// relayRealtime 双向转发直到任一方断开，返回会话的 usage
+func (s *ProxyService) relayRealtime(requestID string, client io.ReadWriteCloser, clientReader *bufio.Reader, upstream io.ReadWriteCloser) *realtimeUsage {
+	usage := &realtimeUsage{}
+	done := make(chan struct{}, 2)
+	safeGo(requestID, func() {
+		defer func() { done <- struct{}{} }()
+		io.Copy(upstream, clientReader)
+	})
+	safeGo(requestID, func() {
+		defer func() { done <- struct{}{} }()
+		io.Copy(client, io.TeeReader(upstream, usage))
+	})
+	<-done
+	client.Close()
+	upstream.Close()
+	<-done
+	return usage
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// RequestIDHeader 请求唯一ID头，贯穿日志、Traces 和响应
+const RequestIDHeader = "X-Request-ID"
+
+// ClientRequestIDHeader 转发给上游的请求ID头，便于在供应商后台对照排查
+const ClientRequestIDHeader = "X-Client-Request-Id"
+
+// NewRequestID 生成 UUID v4 格式的请求ID
+func NewRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// 随机源不可用时退化为时间戳
+		return fmt.Sprintf("req-%d", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// requestIDFromHeaders 从转发的请求头中读取请求ID
+func requestIDFromHeaders(headers map[string]string) string {
+	if id := headers[http.CanonicalHeaderKey(RequestIDHeader)]; id != "" {
+		return id
+	}
+	return headers[RequestIDHeader]
+}
+
+// requestIDFromWriter 从响应头中读取请求ID（路由中间件已写入 X-Request-ID）
+func requestIDFromWriter(writer io.Writer) string {
+	if w, ok := writer.(interface{ Header() http.Header }); ok {
+		return w.Header().Get(RequestIDHeader)
+	}
+	return ""
+}
+
+// requestIDContextKey 上游请求 context 中保存请求ID的键
+type requestIDContextKey struct{}
+
+// withRequestID 将请求ID写入上游请求的 context（doWithRetry 据此记录每个请求使用的 Key），并以 X-Client-Request-Id 转发给上游
+func withRequestID(req *http.Request, requestID string) *http.Request {
+	if requestID == "" {
+		return req
+	}
+	req.Header.Set(ClientRequestIDHeader, requestID)
+	return req.WithContext(context.WithValue(req.Context(), requestIDContextKey{}, requestID))
+}
+
+// requestIDFromContext 从上游请求的 context 中读取请求ID
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
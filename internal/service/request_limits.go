@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 请求护栏：限制单个请求的消息数和 max_tokens（请求体大小在 API 路由中间件里限制）
+
+// maxTokensFields 各格式表示最大输出 token 的字段
+var maxTokensFields = []string{"max_tokens", "max_completion_tokens", "max_output_tokens"}
+
+// CountRequestMessages 请求中的消息数（OpenAI/Claude 的 messages、Gemini 的 contents、Responses API 的 input 数组）
+func CountRequestMessages(body []byte) int {
+	var req struct {
+		Messages []json.RawMessage `json:"messages"`
+		Contents []json.RawMessage `json:"contents"`
+		Input    json.RawMessage   `json:"input"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return 0
+	}
+	count := len(req.Messages) + len(req.Contents)
+	var input []json.RawMessage
+	if len(req.Input) > 0 && json.Unmarshal(req.Input, &input) == nil {
+		count += len(input)
+	}
+	return count
+}
+
+// CapMaxTokens 将请求的 max_tokens 限制在 limit 以内，请求未指定时补上 limit
+// responsesAPI 为 true 时缺省字段使用 max_output_tokens；返回修改后的请求体和是否有修改
+func CapMaxTokens(body []byte, limit int, responsesAPI bool) ([]byte, bool) {
+	if limit <= 0 {
+		return body, false
+	}
+	var req map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&req) != nil || req == nil {
+		return body, false
+	}
+
+	found, changed := false, false
+	clamp := func(obj map[string]interface{}, field string) {
+		value, ok := obj[field]
+		if !ok || value == nil {
+			return
+		}
+		found = true
+		if n, ok := value.(json.Number); ok {
+			if v, err := n.Float64(); err == nil && v <= float64(limit) {
+				return
+			}
+		}
+		obj[field] = limit
+		changed = true
+	}
+	for _, field := range maxTokensFields {
+		clamp(req, field)
+	}
+	genConfig, _ := req["generationConfig"].(map[string]interface{})
+	if genConfig != nil {
+		clamp(genConfig, "maxOutputTokens")
+	}
+
+	if !found {
+		switch {
+		case responsesAPI:
+			req["max_output_tokens"] = limit
+		case req["contents"] != nil:
+			if genConfig == nil {
+				genConfig = make(map[string]interface{})
+				req["generationConfig"] = genConfig
+			}
+			genConfig["maxOutputTokens"] = limit
+		case req["messages"] != nil || req["prompt"] != nil:
+			req["max_tokens"] = limit
+		default:
+			// 嵌入、图片等没有输出 token 的请求不处理
+			return body, false
+		}
+		changed = true
+	}
+	if !changed {
+		return body, false
+	}
+	capped, err := json.Marshal(req)
+	if err != nil {
+		return body, false
+	}
+	return capped, true
+}
+
+// capRouteMaxTokens 按路由的 max_tokens 上限改写上游请求体，需在模型兼容处理之前调用，
+// 补上的 max_tokens 才会按兼容规则改名（o 系列、GPT-5 等模型不接受 max_tokens）
+func capRouteMaxTokens(body []byte, limit int, targetURL string) []byte {
+	responsesAPI := false
+	if u, err := url.Parse(targetURL); err == nil {
+		responsesAPI = strings.HasSuffix(u.Path, "/responses")
+	}
+	capped, changed := CapMaxTokens(body, limit, responsesAPI)
+	if changed {
+		log.Debugf("Request max_tokens capped to route limit %d", limit)
+	}
+	return capped
+}
+
+// SetRouteMaxTokens 设置路由的 max_tokens 上限，0 表示不限制
+func (s *RouteService) SetRouteMaxTokens(id int64, maxTokens int) error {
+	if maxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative")
+	}
+	result, err := s.db.Exec(`UPDATE model_routes SET max_tokens = ? WHERE id = ?`, maxTokens, id)
+	if err != nil {
+		log.Errorf("Failed to set route max_tokens: %v", err)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("route not found: id=%d", id)
+	}
+	log.Infof("Route max_tokens updated: id=%d -> %d", id, maxTokens)
+	return nil
+}
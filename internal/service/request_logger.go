@@ -0,0 +1,18 @@
+package service
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestLogger 带请求ID的日志记录器，同一请求的每行日志都带 request_id 字段，并发请求的日志可以按请求过滤
+func RequestLogger(requestID string) *log.Entry {
+	if requestID == "" {
+		return log.NewEntry(log.StandardLogger())
+	}
+	return log.WithField("request_id", requestID)
+}
+
+// requestLogger 根据转发的请求头创建带请求ID的日志记录器
+func requestLogger(headers map[string]string) *log.Entry {
+	return RequestLogger(requestIDFromHeaders(headers))
+}
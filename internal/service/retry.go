@@ -0,0 +1,185 @@
+package service
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy 同一路由的重试策略（在 Fallback 切换路由之前生效）
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数（包含首次请求），<=1 表示不重试
+	BaseDelay   time.Duration // 指数退避的基础延迟
+	MaxDelay    time.Duration // 单次等待的最大延迟
+	Jitter      float64       // 随机抖动比例 (0~1)
+}
+
+// retryPolicy 从配置构建重试策略
+func (s *ProxyService) retryPolicy() RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: 1,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.2,
+	}
+	if s.config == nil {
+		return policy
+	}
+	if s.config.RetryMaxAttempts > 1 {
+		policy.MaxAttempts = s.config.RetryMaxAttempts
+	}
+	if s.config.RetryBaseDelayMs > 0 {
+		policy.BaseDelay = time.Duration(s.config.RetryBaseDelayMs) * time.Millisecond
+	}
+	if s.config.RetryMaxDelayMs > 0 {
+		policy.MaxDelay = time.Duration(s.config.RetryMaxDelayMs) * time.Millisecond
+	}
+	if s.config.RetryJitter >= 0 && s.config.RetryJitter <= 1 {
+		policy.Jitter = s.config.RetryJitter
+	}
+	return policy
+}
+
+// backoff 计算第 attempt 次重试前的等待时间（attempt 从 1 开始）
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration((rand.Float64()*2 - 1) * spread)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// isRetryable 判断是否为可在同一路由上重试的瞬时错误
+// 401/403/404 等错误重试同一路由没有意义，直接交给 Fallback
+func isRetryable(statusCode int, err error) bool {
+	if err != nil {
+		return shouldFallback(0, err)
+	}
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter 解析 Retry-After 头（支持秒数和 HTTP 日期两种格式）
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// doWithRetry 发送请求，对瞬时错误按退避策略重试同一路由
+// 返回最后一次的响应/错误，调用方再根据 shouldFallback 决定是否切换路由
+func (s *ProxyService) doWithRetry(req *http.Request, routeName string) (*http.Response, error) {
+	policy := s.retryPolicy()
+	requestID := requestIDFromContext(req.Context())
+	req = s.active.bind(req)
+	s.active.update(requestID, func(info *ActiveRequest) { info.Route = routeName })
+	replayable := req.Body == nil || req.GetBody != nil
+	triedKeys := make(map[string]bool)
+	keySwaps := 0
+
+	var resp *http.Response
+	var err error
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if (attempt > 1 || keySwaps > 0) && req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		limited := s.checkProviderLimit(attemptReq, routeName)
+		if limited != nil {
+			log.Warnf("Route %s: provider %s rate limited locally", routeName, attemptReq.URL.Host)
+			resp, err = limited, nil
+		} else {
+			start := time.Now()
+			resp, err = s.httpClient.Do(attemptReq)
+			resp = s.inspections.record(requestID, routeName, attemptReq, resp, err, start)
+			resp = s.captureUpstreamCost(requestID, attemptReq, resp)
+		}
+		statusCode := 0
+		if err == nil {
+			statusCode = resp.StatusCode
+		}
+
+		// 记录 Key 的使用情况；Key 被限流或失效时换用同一路由的其他 Key 重发（不计入重试次数）
+		if key := upstreamRequestKey(attemptReq); key != "" && limited == nil {
+			triedKeys[key] = true
+			cooldown := s.keyCooldown(statusCode, resp)
+			if s.routeService.keyPool.report(requestID, key, statusCode, err, cooldown) && cooldown > 0 && replayable && keySwaps < maxKeySwaps {
+				if next := s.routeService.keyPool.alternate(key, triedKeys); next != "" {
+					log.Warnf("Route %s: key %s returned %d, switching to key %s", routeName, MaskAPIKey(key), statusCode, MaskAPIKey(next))
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					req = req.Clone(req.Context())
+					replaceRequestKey(req, key, next)
+					keySwaps++
+					attempt--
+					continue
+				}
+			}
+		}
+
+		if attempt >= policy.MaxAttempts || !isRetryable(statusCode, err) || !replayable {
+			if attempt > 1 {
+				log.Infof("Route %s: finished after %d attempt(s), status: %d, err: %v", routeName, attempt, statusCode, err)
+			}
+			return resp, err
+		}
+
+		delay := policy.backoff(attempt)
+		if err == nil {
+			// 429 时优先遵循上游的 Retry-After
+			if statusCode == http.StatusTooManyRequests {
+				if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+					if retryAfter > policy.MaxDelay {
+						// 等待时间超过上限，不再重试，交给 Fallback
+						log.Warnf("Route %s: Retry-After %v exceeds max delay %v, giving up retries", routeName, retryAfter, policy.MaxDelay)
+						return resp, err
+					}
+					delay = retryAfter
+				}
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		log.Warnf("Route %s: attempt %d/%d failed (status: %d, err: %v), retrying in %v",
+			routeName, attempt, policy.MaxAttempts, statusCode, err, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/secret"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// 路由配置包的版本，格式不兼容时递增
+const routeBundleVersion = 1
+
+// 导出时 API Key 的处理方式
+const (
+	BundleKeysPlain     = "plain"     // 明文导出
+	BundleKeysExclude   = "exclude"   // 不导出，导入后需要重新填写
+	BundleKeysEncrypted = "encrypted" // 使用导出密码加密
+)
+
+// RouteBundle 路由配置包：路由、分组优先级、重定向别名、模型元数据和提示词模板
+// 用于迁移到其它机器或纳入版本管理，JSON 和 YAML 使用相同的字段名
+type RouteBundle struct {
+	Version         int             `json:"version" yaml:"version"`
+	ExportedAt      time.Time       `json:"exported_at" yaml:"exported_at"`
+	KeyMode         string          `json:"key_mode" yaml:"key_mode"`
+	KeySalt         string          `json:"key_salt,omitempty" yaml:"key_salt,omitempty"` // encrypted 模式派生密钥的盐
+	Redirect        *BundleRedirect `json:"redirect,omitempty" yaml:"redirect,omitempty"`
+	Routes          []BundleRoute   `json:"routes" yaml:"routes"`
+	Groups          []BundleGroup   `json:"groups,omitempty" yaml:"groups,omitempty"`
+	ModelMetadata   []BundleModel   `json:"model_metadata,omitempty" yaml:"model_metadata,omitempty"`
+	PromptTemplates []BundlePrompt  `json:"prompt_templates,omitempty" yaml:"prompt_templates,omitempty"`
+}
+
+// BundleRoute 路由，ID 只用于关联包内的提示词模板和重定向目标，导入时重新分配
+type BundleRoute struct {
+	ID            int64             `json:"id" yaml:"id"`
+	Name          string            `json:"name" yaml:"name"`
+	Model         string            `json:"model" yaml:"model"`
+	APIUrl        string            `json:"api_url" yaml:"api_url"`
+	APIKey        string            `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	Group         string            `json:"group,omitempty" yaml:"group,omitempty"`
+	Format        string            `json:"format,omitempty" yaml:"format,omitempty"`
+	Enabled       *bool             `json:"enabled,omitempty" yaml:"enabled,omitempty"` // 为空表示启用（兼容旧版导出的路由数组）
+	Priority      int               `json:"priority,omitempty" yaml:"priority,omitempty"`
+	MaxTokens     int               `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	UpstreamModel string            `json:"upstream_model,omitempty" yaml:"upstream_model,omitempty"`
+	ProviderPrefs string            `json:"provider_prefs,omitempty" yaml:"provider_prefs,omitempty"`
+	DailyTokens   int64             `json:"daily_token_limit,omitempty" yaml:"daily_token_limit,omitempty"`
+	DailyRequests int               `json:"daily_request_limit,omitempty" yaml:"daily_request_limit,omitempty"`
+	ExtraHeaders  map[string]string `json:"extra_headers,omitempty" yaml:"extra_headers,omitempty"`
+	ExtraQuery    map[string]string `json:"extra_query,omitempty" yaml:"extra_query,omitempty"`
+}
+
+// BundleGroup 分组优先级
+type BundleGroup struct {
+	Name     string `json:"name" yaml:"name"`
+	Priority int    `json:"priority" yaml:"priority"`
+}
+
+// BundleRedirect 重定向关键字（模型别名）
+type BundleRedirect struct {
+	Enabled       bool   `json:"enabled" yaml:"enabled"`
+	Keyword       string `json:"keyword" yaml:"keyword"`
+	TargetModel   string `json:"target_model,omitempty" yaml:"target_model,omitempty"`
+	TargetName    string `json:"target_name,omitempty" yaml:"target_name,omitempty"`
+	TargetRouteID int64  `json:"target_route_id,omitempty" yaml:"target_route_id,omitempty"`
+}
+
+// BundleModel 模型元数据
+type BundleModel struct {
+	Model           string   `json:"model" yaml:"model"`
+	DisplayName     string   `json:"display_name,omitempty" yaml:"display_name,omitempty"`
+	Description     string   `json:"description,omitempty" yaml:"description,omitempty"`
+	ContextWindow   int      `json:"context_window,omitempty" yaml:"context_window,omitempty"`
+	MaxOutputTokens int      `json:"max_output_tokens,omitempty" yaml:"max_output_tokens,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	Hidden          bool     `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+}
+
+// BundlePrompt 提示词模板，route 范围的 target 为包内的路由 ID
+type BundlePrompt struct {
+	Scope   string `json:"scope" yaml:"scope"`
+	Target  string `json:"target" yaml:"target"`
+	Mode    string `json:"mode" yaml:"mode"`
+	Content string `json:"content" yaml:"content"`
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+}
+
+// RouteExportOptions 导出选项
+type RouteExportOptions struct {
+	Format     string `json:"format"`     // json（默认）或 yaml
+	KeyMode    string `json:"key_mode"`   // plain（默认）、exclude、encrypted
+	Passphrase string `json:"passphrase"` // encrypted 模式的导出密码
+}
+
+// RouteImportResult 导入结果
+type RouteImportResult struct {
+	Routes          int      `json:"routes"`
+	Skipped         int      `json:"skipped"` // 已存在（名称、模型、地址相同）的路由
+	Groups          int      `json:"groups"`
+	ModelMetadata   int      `json:"model_metadata"`
+	PromptTemplates int      `json:"prompt_templates"`
+	Redirect        bool     `json:"redirect"`
+	Errors          []string `json:"errors"`
+}
+
+// ExportRoutes 导出路由配置包
+func (s *RouteService) ExportRoutes(cfg *config.Config, opts RouteExportOptions) ([]byte, error) {
+	bundle := RouteBundle{Version: routeBundleVersion, ExportedAt: time.Now(), KeyMode: opts.KeyMode}
+	if bundle.KeyMode == "" {
+		bundle.KeyMode = BundleKeysPlain
+	}
+
+	var box *secret.Box
+	switch bundle.KeyMode {
+	case BundleKeysPlain, BundleKeysExclude:
+	case BundleKeysEncrypted:
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		var err error
+		if box, err = secret.NewPasswordBox(opts.Passphrase, salt); err != nil {
+			return nil, fmt.Errorf("encrypted export: %v", err)
+		}
+		bundle.KeySalt = base64.StdEncoding.EncodeToString(salt)
+	default:
+		return nil, fmt.Errorf("invalid key mode: %q", opts.KeyMode)
+	}
+
+	routes, err := s.GetAllRoutes()
+	if err != nil {
+		return nil, err
+	}
+	bundle.Routes = make([]BundleRoute, 0, len(routes))
+	for i := len(routes) - 1; i >= 0; i-- { // 按创建顺序导出
+		route := routes[i]
+		enabled := route.Enabled
+		item := BundleRoute{
+			ID:            route.ID,
+			Name:          route.Name,
+			Model:         route.Model,
+			APIUrl:        route.APIUrl,
+			Group:         route.Group,
+			Format:        route.Format,
+			Enabled:       &enabled,
+			Priority:      route.Priority,
+			MaxTokens:     route.MaxTokens,
+			UpstreamModel: route.UpstreamModel,
+			ProviderPrefs: route.ProviderPrefs,
+			DailyTokens:   route.DailyTokenLimit,
+			DailyRequests: route.DailyRequestLimit,
+			ExtraHeaders:  route.ExtraHeaders,
+			ExtraQuery:    route.ExtraQuery,
+		}
+		switch bundle.KeyMode {
+		case BundleKeysPlain:
+			item.APIKey = route.APIKey
+		case BundleKeysEncrypted:
+			if item.APIKey, err = box.Encrypt(route.APIKey); err != nil {
+				return nil, err
+			}
+		}
+		bundle.Routes = append(bundle.Routes, item)
+	}
+
+	if groups, err := s.GetRouteGroups(); err == nil {
+		for _, g := range groups {
+			if g.Priority != 0 {
+				bundle.Groups = append(bundle.Groups, BundleGroup{Name: g.Name, Priority: g.Priority})
+			}
+		}
+	}
+	if cfg != nil && cfg.RedirectKeyword != "" {
+		bundle.Redirect = &BundleRedirect{
+			Enabled:       cfg.RedirectEnabled,
+			Keyword:       cfg.RedirectKeyword,
+			TargetModel:   cfg.RedirectTargetModel,
+			TargetName:    cfg.RedirectTargetName,
+			TargetRouteID: cfg.RedirectTargetRouteID,
+		}
+	}
+	if items, err := s.GetModelMetadata(); err == nil {
+		for _, m := range items {
+			bundle.ModelMetadata = append(bundle.ModelMetadata, BundleModel{
+				Model:           m.Model,
+				DisplayName:     m.DisplayName,
+				Description:     m.Description,
+				ContextWindow:   m.ContextWindow,
+				MaxOutputTokens: m.MaxOutputTokens,
+				Capabilities:    m.Capabilities,
+				Hidden:          m.Hidden,
+			})
+		}
+	}
+	if templates, err := s.GetPromptTemplates(); err == nil {
+		for _, t := range templates {
+			bundle.PromptTemplates = append(bundle.PromptTemplates, BundlePrompt{
+				Scope: t.Scope, Target: t.Target, Mode: t.Mode, Content: t.Content, Enabled: t.Enabled,
+			})
+		}
+	}
+
+	log.Infof("Exporting %d routes (format=%s, keys=%s)", len(bundle.Routes), opts.Format, bundle.KeyMode)
+	switch strings.ToLower(opts.Format) {
+	case "yaml", "yml":
+		return yaml.Marshal(bundle)
+	case "", "json":
+		return json.MarshalIndent(bundle, "", "  ")
+	default:
+		return nil, fmt.Errorf("invalid export format: %q", opts.Format)
+	}
+}
+
+// parseRouteBundle 解析 JSON/YAML 配置包，兼容旧版导出的路由数组
+func parseRouteBundle(data []byte) (*RouteBundle, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty route bundle")
+	}
+	var bundle RouteBundle
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &bundle.Routes); err != nil {
+			return nil, fmt.Errorf("invalid route list: %v", err)
+		}
+		bundle.Version = routeBundleVersion
+		bundle.KeyMode = BundleKeysPlain
+		return &bundle, nil
+	}
+	// YAML 兼容 JSON
+	if err := yaml.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid route bundle: %v", err)
+	}
+	if bundle.Version > routeBundleVersion {
+		return nil, fmt.Errorf("unsupported route bundle version: %d", bundle.Version)
+	}
+	return &bundle, nil
+}
+
+// findRouteID 查找名称、模型和地址都相同的路由
+func (s *RouteService) findRouteID(name, model, apiURL string) int64 {
+	var id int64
+	if err := s.db.QueryRow(`SELECT id FROM model_routes WHERE name = ? AND model = ? AND api_url = ?`, name, model, apiURL).Scan(&id); err != nil {
+		return 0
+	}
+	return id
+}
+
+// importRoute 写入一条路由并返回新 ID
+func (s *RouteService) importRoute(r BundleRoute) (int64, error) {
+	storedKey, err := s.sealKey(r.APIKey)
+	if err != nil {
+		return 0, err
+	}
+	headers, err := normalizeExtraHeaders(r.ExtraHeaders)
+	if err != nil {
+		return 0, err
+	}
+	query, err := normalizeExtraQuery(r.ExtraQuery)
+	if err != nil {
+		return 0, err
+	}
+	format := r.Format
+	if format == "" {
+		format = "openai"
+	}
+	enabled := 1
+	if r.Enabled != nil && !*r.Enabled {
+		enabled = 0
+	}
+	prefs, err := NormalizeProviderPrefs(r.ProviderPrefs)
+	if err != nil {
+		return 0, fmt.Errorf("route %s: %v", r.Name, err)
+	}
+
+	now := time.Now()
+	_, err = s.db.Exec(`
+		INSERT INTO model_routes (name, model, api_url, api_key, "group", format, enabled, priority, max_tokens, upstream_model, provider_prefs, daily_token_limit, daily_request_limit, extra_headers, extra_query, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Name, r.Model, r.APIUrl, storedKey, r.Group, format, enabled, r.Priority, r.MaxTokens, strings.TrimSpace(r.UpstreamModel), prefs,
+		max(r.DailyTokens, 0), max(r.DailyRequests, 0), marshalRouteExtras(headers), marshalRouteExtras(query), now, now)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = s.db.QueryRow(`SELECT MAX(id) FROM model_routes WHERE name = ? AND model = ? AND api_url = ?`, r.Name, r.Model, r.APIUrl).Scan(&id)
+	return id, err
+}
+
+// ImportRoutes 导入路由配置包（JSON/YAML），已存在的路由跳过
+// 配置包包含重定向设置时同时更新 cfg 并保存
+func (s *RouteService) ImportRoutes(cfg *config.Config, data []byte, passphrase string) (*RouteImportResult, error) {
+	bundle, err := parseRouteBundle(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var box *secret.Box
+	if bundle.KeyMode == BundleKeysEncrypted {
+		salt, err := base64.StdEncoding.DecodeString(bundle.KeySalt)
+		if err != nil || len(salt) == 0 {
+			return nil, fmt.Errorf("invalid key_salt in route bundle")
+		}
+		if box, err = secret.NewPasswordBox(passphrase, salt); err != nil {
+			return nil, fmt.Errorf("encrypted bundle: %v", err)
+		}
+		// 先校验密码，避免导入一半后才发现密码错误
+		for _, r := range bundle.Routes {
+			if r.APIKey != "" {
+				if _, err := box.Decrypt(r.APIKey); err != nil {
+					return nil, fmt.Errorf("wrong passphrase for encrypted API keys")
+				}
+				break
+			}
+		}
+	}
+
+	result := &RouteImportResult{Errors: make([]string, 0)}
+	idMap := make(map[int64]int64) // 包内路由 ID -> 导入后的路由 ID
+	for _, r := range bundle.Routes {
+		r.Name, r.Model, r.APIUrl = strings.TrimSpace(r.Name), strings.TrimSpace(r.Model), strings.TrimSpace(r.APIUrl)
+		if r.Model == "" || r.APIUrl == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("route %q: model and api_url are required", r.Name))
+			continue
+		}
+		if id := s.findRouteID(r.Name, r.Model, r.APIUrl); id != 0 {
+			idMap[r.ID] = id
+			result.Skipped++
+			continue
+		}
+		if box != nil {
+			if r.APIKey, err = box.Decrypt(r.APIKey); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("route %q: %v", r.Name, err))
+				continue
+			}
+		}
+		id, err := s.importRoute(r)
+		if err != nil {
+			log.Errorf("Failed to import route %s: %v", r.Name, err)
+			result.Errors = append(result.Errors, fmt.Sprintf("route %q: %v", r.Name, err))
+			continue
+		}
+		idMap[r.ID] = id
+		result.Routes++
+	}
+
+	for _, g := range bundle.Groups {
+		if err := s.SetGroupPriority(g.Name, g.Priority); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("group %q: %v", g.Name, err))
+			continue
+		}
+		result.Groups++
+	}
+
+	for _, m := range bundle.ModelMetadata {
+		_, err := s.SaveModelMetadata(ModelMetadata{
+			Model:           m.Model,
+			DisplayName:     m.DisplayName,
+			Description:     m.Description,
+			ContextWindow:   m.ContextWindow,
+			MaxOutputTokens: m.MaxOutputTokens,
+			Capabilities:    m.Capabilities,
+			Hidden:          m.Hidden,
+		})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("model metadata %q: %v", m.Model, err))
+			continue
+		}
+		result.ModelMetadata++
+	}
+
+	for _, p := range bundle.PromptTemplates {
+		if p.Scope == PromptScopeRoute {
+			oldID, _ := strconv.ParseInt(p.Target, 10, 64)
+			newID, ok := idMap[oldID]
+			if !ok {
+				result.Errors = append(result.Errors, fmt.Sprintf("prompt template for route %s: route not in bundle", p.Target))
+				continue
+			}
+			p.Target = strconv.FormatInt(newID, 10)
+		}
+		_, err := s.SavePromptTemplate(PromptTemplate{Scope: p.Scope, Target: p.Target, Mode: p.Mode, Content: p.Content, Enabled: p.Enabled})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("prompt template %s %s: %v", p.Scope, p.Target, err))
+			continue
+		}
+		result.PromptTemplates++
+	}
+
+	if bundle.Redirect != nil && bundle.Redirect.Keyword != "" && cfg != nil {
+		cfg.RedirectEnabled = bundle.Redirect.Enabled
+		cfg.RedirectKeyword = bundle.Redirect.Keyword
+		cfg.RedirectTargetModel = bundle.Redirect.TargetModel
+		cfg.RedirectTargetName = bundle.Redirect.TargetName
+		cfg.RedirectTargetRouteID = idMap[bundle.Redirect.TargetRouteID]
+		if err := cfg.Save(); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("redirect: %v", err))
+		} else {
+			result.Redirect = true
+		}
+	}
+
+	log.Infof("Route bundle imported: %d routes, %d skipped, %d groups, %d model metadata, %d prompt templates, %d errors",
+		result.Routes, result.Skipped, result.Groups, result.ModelMetadata, result.PromptTemplates, len(result.Errors))
+	return result, nil
+}
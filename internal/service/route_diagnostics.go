@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/adapters"
+)
+
+// 连通性测试的失败分类
+const (
+	RouteTestDNS       = "dns"        // 域名解析失败
+	RouteTestConnect   = "connect"    // 无法建立连接（端口未监听、被拒绝、代理不可用）
+	RouteTestTLS       = "tls"        // TLS 握手或证书校验失败
+	RouteTestTimeout   = "timeout"    // 请求超时
+	RouteTestNetwork   = "network"    // 其它网络错误
+	RouteTestAuth      = "auth"       // 401/403：API Key 无效或没有权限
+	RouteTestNotFound  = "not_found"  // 404：API 地址或模型名错误
+	RouteTestRateLimit = "rate_limit" // 429：限流或余额不足
+	RouteTestFormat    = "format"     // 请求被拒绝（400/422）或响应不是所选格式，通常是格式选错
+	RouteTestUpstream  = "upstream"   // 上游服务错误（5xx 等）
+	RouteTestConfig    = "config"     // 无法构建请求（地址、适配器配置错误）
+)
+
+const (
+	routeTestTimeout      = 30 * time.Second
+	routeTestSnippetLimit = 1024 // 返回的响应片段最大长度
+)
+
+// RouteTestResult 路由连通性测试结果
+type RouteTestResult struct {
+	RouteID    int64  `json:"route_id"`
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"status_code"`
+	LatencyMs  int64  `json:"latency_ms"`
+	TargetURL  string `json:"target_url"` // 实际请求的地址（已脱敏）
+	Adapter    string `json:"adapter"`    // 使用的格式适配器，为空表示 OpenAI 兼容格式直连
+	Failure    string `json:"failure"`    // 失败分类，成功时为空
+	Message    string `json:"message"`    // 失败原因
+	Response   string `json:"response"`   // 响应片段（已脱敏）
+	Reply      string `json:"reply"`      // 转换为 OpenAI 格式后的回复文本
+}
+
+// TestRoute 通过与代理相同的适配器路径向路由发送一次最小的对话请求（max_tokens=1），
+// 不经过重试、限流和 Fallback，已禁用的路由也可以测试
+func (s *ProxyService) TestRoute(id int64) (RouteTestResult, error) {
+	stored, err := s.routeService.routeByIDAny(id)
+	if err != nil {
+		return RouteTestResult{}, err
+	}
+	route := *stored
+	s.routeService.pickRouteKey(&route)
+	redactor := NewSecretRedactor(nil, SplitAPIKeys(stored.APIKey))
+
+	result := RouteTestResult{RouteID: id, Adapter: s.detectAdapterForRoute(&route, "openai")}
+	req, err := s.buildCompletionProbe(&route)
+	if err != nil {
+		result.Failure = RouteTestConfig
+		result.Message = redactor.Redact(err.Error())
+		return result, nil
+	}
+	result.TargetURL = redactor.Redact(req.URL.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), routeTestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		result.LatencyMs = time.Since(start).Milliseconds()
+		result.Failure = classifyTransportError(err)
+		result.Message = redactor.Redact(err.Error())
+		return result, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+	resp.Body.Close()
+	result.LatencyMs = time.Since(start).Milliseconds()
+	result.StatusCode = resp.StatusCode
+
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > routeTestSnippetLimit {
+		snippet = snippet[:routeTestSnippetLimit]
+	}
+	result.Response = redactor.Redact(snippet)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		result.Failure = classifyStatusCode(resp.StatusCode)
+		result.Message = redactor.Redact(fmt.Sprintf("HTTP %d: %s", resp.StatusCode, upstreamErrorMessage(body)))
+		return result, nil
+	}
+
+	reply, err := testReply(result.Adapter, body)
+	if err != nil {
+		result.Failure = RouteTestFormat
+		result.Message = err.Error()
+		return result, nil
+	}
+	result.Success = true
+	result.Reply = reply
+	return result, nil
+}
+
+// testReply 按代理的转换方式将响应转换为 OpenAI 格式并提取回复文本，结构不符时返回错误
+func testReply(adapterName string, body []byte) (string, error) {
+	var respData map[string]interface{}
+	if err := json.Unmarshal(body, &respData); err != nil {
+		return "", fmt.Errorf("response is not JSON: %v", err)
+	}
+	if adapterName != "" {
+		adapter := adapters.GetAdapter(adapterName)
+		if adapter == nil {
+			return "", fmt.Errorf("adapter not found: %s", adapterName)
+		}
+		converted, err := adapter.AdaptResponse(respData)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert response with %s adapter: %v", adapterName, err)
+		}
+		respData = converted
+	}
+	choices, _ := respData["choices"].([]interface{})
+	if len(choices) == 0 {
+		return "", fmt.Errorf("response has no choices, the API format of the route may be wrong")
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	message, _ := choice["message"].(map[string]interface{})
+	reply, _ := message["content"].(string)
+	return reply, nil
+}
+
+// upstreamErrorMessage 上游错误响应中的错误信息（OpenAI/Claude/Gemini 的 error.message），没有时返回响应片段
+func upstreamErrorMessage(body []byte) string {
+	var errResp struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if json.Unmarshal(body, &errResp) == nil {
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(errResp.Error, &detail) == nil && detail.Message != "" {
+			return detail.Message
+		}
+		var text string
+		if json.Unmarshal(errResp.Error, &text) == nil && text != "" {
+			return text
+		}
+		if errResp.Message != "" {
+			return errResp.Message
+		}
+	}
+	text := strings.TrimSpace(string(body))
+	if len(text) > healthProbeErrorBodyLimit {
+		text = text[:healthProbeErrorBodyLimit]
+	}
+	return text
+}
+
+// classifyStatusCode 按上游状态码分类失败原因
+func classifyStatusCode(status int) string {
+	switch {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return RouteTestAuth
+	case status == http.StatusNotFound:
+		return RouteTestNotFound
+	case status == http.StatusTooManyRequests, status == http.StatusPaymentRequired:
+		return RouteTestRateLimit
+	case status == http.StatusBadRequest, status == http.StatusMethodNotAllowed,
+		status == http.StatusUnsupportedMediaType, status == http.StatusUnprocessableEntity:
+		return RouteTestFormat
+	}
+	return RouteTestUpstream
+}
+
+// classifyTransportError 按请求错误分类失败原因
+func classifyTransportError(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return RouteTestDNS
+	case errors.As(err, &certErr), errors.As(err, &authorityErr), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr), errors.As(err, &recordErr), strings.Contains(err.Error(), "tls: "):
+		return RouteTestTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return RouteTestTimeout
+	case errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "proxyconnect"):
+		return RouteTestConnect
+	}
+	return RouteTestNetwork
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reservedExtraHeaders 由代理自行维护、不允许通过路由配置覆盖的请求头
+var reservedExtraHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// parseRouteExtras 解析数据库中保存的 JSON 对象，为空或无效时返回 nil
+func parseRouteExtras(raw string) map[string]string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		log.Warnf("Invalid route extras %q: %v", raw, err)
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// marshalRouteExtras 转为 JSON 保存，为空时保存空字符串
+func marshalRouteExtras(values map[string]string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// normalizeExtraHeaders 校验并规范化自定义请求头（名称转为标准格式，去掉空名称）
+func normalizeExtraHeaders(headers map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(headers))
+	for name, value := range headers {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if strings.ContainsAny(name, " \t\r\n:") {
+			return nil, fmt.Errorf("invalid header name: %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("header %s contains a line break", name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if reservedExtraHeaders[name] {
+			return nil, fmt.Errorf("header %s cannot be overridden", name)
+		}
+		result[name] = strings.TrimSpace(value)
+	}
+	return result, nil
+}
+
+// normalizeExtraQuery 校验自定义查询参数（去掉空名称）
+func normalizeExtraQuery(query map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(query))
+	for name, value := range query {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if strings.ContainsAny(name, "&=#? \t\r\n") {
+			return nil, fmt.Errorf("invalid query parameter name: %q", name)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// applyRouteExtras 将路由的自定义请求头和查询参数应用到上游请求
+// 在认证头之后调用，路由配置的请求头优先（可用于替换认证方式）
+func applyRouteExtras(req *http.Request, extraHeaders, extraQuery map[string]string) {
+	for name, value := range extraHeaders {
+		req.Header.Set(name, value)
+	}
+	if len(extraQuery) == 0 {
+		return
+	}
+	query := req.URL.Query()
+	for name, value := range extraQuery {
+		query.Set(name, value)
+	}
+	req.URL.RawQuery = query.Encode()
+}
+
+// SetRouteExtras 设置路由的自定义请求头和查询参数
+func (s *RouteService) SetRouteExtras(id int64, extraHeaders, extraQuery map[string]string) error {
+	headers, err := normalizeExtraHeaders(extraHeaders)
+	if err != nil {
+		return err
+	}
+	query, err := normalizeExtraQuery(extraQuery)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`UPDATE model_routes SET extra_headers = ?, extra_query = ? WHERE id = ?`,
+		marshalRouteExtras(headers), marshalRouteExtras(query), id)
+	if err != nil {
+		log.Errorf("Failed to update route extras: %v", err)
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("route not found: id=%d", id)
+	}
+	log.Infof("Route extras updated: id=%d (%d headers, %d query params)", id, len(headers), len(query))
+	return nil
+}
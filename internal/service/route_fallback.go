@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fallbackError 路由在向客户端写入任何数据之前失败（网络错误或上游返回非 200），流式请求据此判断能否切换路由
+type fallbackError struct {
+	statusCode int // 上游状态码，网络错误时为 0
+	err        error
+}
+
+func (e *fallbackError) Error() string { return e.err.Error() }
+func (e *fallbackError) Unwrap() error { return e.err }
+
+// upstreamStatusError 上游返回非 200 时流式请求的错误
+func upstreamStatusError(statusCode int, body []byte) error {
+	return &fallbackError{statusCode: statusCode, err: fmt.Errorf("backend error: %d - %s", statusCode, string(body))}
+}
+
+// upstreamNetworkError 上游连接失败时流式请求的错误
+func upstreamNetworkError(err error) error {
+	return &fallbackError{err: fmt.Errorf("backend service unavailable: %v", err)}
+}
+
+// routeFallback 依次尝试 selectRoutes 选出的路由（非流式）
+// attempt 返回的状态码和错误满足 shouldFallback 且还有下一条路由时切换，否则直接返回（所有路由都失败时通知）
+func (s *ProxyService) routeFallback(routes []database.ModelRoute, attempt func(route *database.ModelRoute) ([]byte, int, error)) ([]byte, int, error) {
+	var body []byte
+	var statusCode int
+	var err error
+	for i := range routes {
+		route := &routes[i]
+		if len(routes) > 1 {
+			log.Infof("=== Trying route %d/%d: %s ===", i+1, len(routes), route.Name)
+		}
+		body, statusCode, err = attempt(route)
+		if !s.fallbackNext(routes, i, statusCode, err) {
+			return body, statusCode, err
+		}
+		log.Warnf("Route %s failed (status %d, err: %v), trying fallback...", route.Name, statusCode, err)
+	}
+	return body, statusCode, err
+}
+
+// routeStreamFallback 依次尝试 selectRoutes 选出的路由（流式）
+// 只有 attempt 返回 fallbackError（尚未向客户端写入数据）时才切换，开始输出后的错误直接返回
+func (s *ProxyService) routeStreamFallback(routes []database.ModelRoute, attempt func(route *database.ModelRoute) error) error {
+	var err error
+	for i := range routes {
+		route := &routes[i]
+		if len(routes) > 1 {
+			log.Infof("=== Trying stream route %d/%d: %s ===", i+1, len(routes), route.Name)
+		}
+		err = attempt(route)
+		var fe *fallbackError
+		if err == nil || !errors.As(err, &fe) || !s.fallbackNext(routes, i, fe.statusCode, fe.err) {
+			return err
+		}
+		log.Warnf("Stream route %s failed: %v, trying fallback...", route.Name, err)
+	}
+	return err
+}
+
+// fallbackNext 当前路由的失败满足 shouldFallback 且还有下一条路由时返回 true；最后一条路由也失败时发送通知
+func (s *ProxyService) fallbackNext(routes []database.ModelRoute, routeIndex, statusCode int, err error) bool {
+	if !shouldFallback(statusCode, err) {
+		return false
+	}
+	if routeIndex < len(routes)-1 {
+		return true
+	}
+	s.notifyAllRoutesFailed(routes, statusCode, err)
+	return false
+}
+
+// notifyAllRoutesFailed 模型的所有路由都因上游错误失败时通知（同一模型在冷却时间内只通知一次）
+func (s *ProxyService) notifyAllRoutesFailed(routes []database.ModelRoute, statusCode int, err error) {
+	notifier := s.routeService.notifier
+	if notifier == nil || len(routes) == 0 {
+		return
+	}
+	model := routes[0].Model
+	names := make([]string, 0, len(routes))
+	for _, route := range routes {
+		names = append(names, route.Name)
+	}
+	message := fmt.Sprintf("All %d route(s) for model %s failed, last status %d", len(routes), model, statusCode)
+	if err != nil {
+		detail := []rune(err.Error())
+		if len(detail) > 300 {
+			detail = append(detail[:300], []rune("...")...)
+		}
+		message += ": " + string(detail)
+	}
+	notifier.Notify(NotifyAllRoutesFailed, model, "error", "All routes failed: "+model, message, map[string]interface{}{
+		"model":       model,
+		"routes":      strings.Join(names, ", "),
+		"status_code": statusCode,
+	})
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// routePriorityOrder 路由的故障转移顺序：先按分组优先级（主用 → 备用 → 应急），再按分组内的路由优先级
+const routePriorityOrder = `COALESCE((SELECT route_groups.priority FROM route_groups WHERE route_groups.name = model_routes."group"), 0),
+	COALESCE(model_routes.priority, 0)`
+
+// RouteGroup 路由分组，作为 Fallback 的故障转移层级
+type RouteGroup struct {
+	Name       string `json:"name"`
+	Priority   int    `json:"priority"` // 数字越小越先尝试
+	RouteCount int    `json:"route_count"`
+}
+
+// GetRouteGroups 获取所有分组及其优先级，按故障转移顺序排列
+func (s *RouteService) GetRouteGroups() ([]RouteGroup, error) {
+	priorities := make(map[string]int)
+	rows, err := s.db.Query(`SELECT name, COALESCE(priority, 0) FROM route_groups`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var priority int
+		if err := rows.Scan(&name, &priority); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		priorities[name] = priority
+	}
+	rows.Close()
+
+	rows, err = s.db.Query(`SELECT "group", COUNT(*) FROM model_routes GROUP BY "group"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]RouteGroup, 0)
+	for rows.Next() {
+		var g RouteGroup
+		if err := rows.Scan(&g.Name, &g.RouteCount); err != nil {
+			return nil, err
+		}
+		g.Priority = priorities[g.Name]
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Priority != groups[j].Priority {
+			return groups[i].Priority < groups[j].Priority
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups, rows.Err()
+}
+
+// SetGroupPriority 设置分组的优先级
+func (s *RouteService) SetGroupPriority(name string, priority int) error {
+	name = strings.TrimSpace(name)
+	_, err := s.db.Exec(`
+		INSERT INTO route_groups (name, priority, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET priority = excluded.priority, updated_at = excluded.updated_at`,
+		name, priority, time.Now())
+	if err != nil {
+		log.Errorf("Failed to set group priority: %v", err)
+		return err
+	}
+	log.Infof("Group priority updated: %q -> %d", name, priority)
+	return nil
+}
+
+// SetRoutePriority 设置路由在分组内的优先级
+func (s *RouteService) SetRoutePriority(id int64, priority int) error {
+	result, err := s.db.Exec(`UPDATE model_routes SET priority = ? WHERE id = ?`, priority, id)
+	if err != nil {
+		log.Errorf("Failed to set route priority: %v", err)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("route not found: id=%d", id)
+	}
+	log.Infof("Route priority updated: id=%d -> %d", id, priority)
+	return nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 路由的上游模型名：客户端按路由的 Model 请求（如 gpt-4o），发往上游时替换为 UpstreamModel（如 deepseek-chat）
+
+// geminiModelPath Gemini 接口路径中的模型名（/models/{model}:generateContent）
+var geminiModelPath = regexp.MustCompile(`(/models/)[^/:]+(:)`)
+
+// applyUpstreamModel 将上游请求中的模型名替换为路由的上游模型名（请求体的 model 字段和 Gemini 路径中的模型名）
+func applyUpstreamModel(req *http.Request, upstreamModel string) {
+	if upstreamModel == "" {
+		return
+	}
+	if path := geminiModelPath.ReplaceAllString(req.URL.Path, "${1}"+upstreamModel+"${2}"); path != req.URL.Path {
+		req.URL.Path = path
+		req.URL.RawPath = ""
+	}
+	if req.GetBody == nil {
+		return
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return
+	}
+	body, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return
+	}
+
+	var reqData map[string]json.RawMessage
+	if json.Unmarshal(body, &reqData) != nil {
+		return
+	}
+	current, ok := reqData["model"]
+	if !ok {
+		return
+	}
+	var model string
+	if json.Unmarshal(current, &model) != nil || model == upstreamModel {
+		return
+	}
+	reqData["model"], _ = json.Marshal(upstreamModel)
+	rewritten, err := json.Marshal(reqData)
+	if err != nil {
+		return
+	}
+	log.Debugf("Upstream model: %s -> %s", model, upstreamModel)
+	req.Body = io.NopCloser(bytes.NewReader(rewritten))
+	req.ContentLength = int64(len(rewritten))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(rewritten)), nil
+	}
+}
+
+// SetRouteUpstreamModel 设置路由的上游模型名，为空时使用客户端请求的模型名
+func (s *RouteService) SetRouteUpstreamModel(id int64, upstreamModel string) error {
+	upstreamModel = strings.TrimSpace(upstreamModel)
+	result, err := s.db.Exec(`UPDATE model_routes SET upstream_model = ? WHERE id = ?`, upstreamModel, id)
+	if err != nil {
+		log.Errorf("Failed to set route upstream model: %v", err)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("route not found: id=%d", id)
+	}
+	log.Infof("Route upstream model updated: id=%d -> %q", id, upstreamModel)
+	return nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 客户端指定路由/供应商的请求头，用于调试某个上游而不修改模型名
+const (
+	RouteIDHeader  = "X-AnyProxy-Route-ID"
+	ProviderHeader = "X-AnyProxy-Provider"
+)
+
+// HasRouteOverride 请求头中是否指定了路由或供应商
+func HasRouteOverride(header http.Header) bool {
+	return header.Get(RouteIDHeader) != "" || header.Get(ProviderHeader) != ""
+}
+
+// routeOverrideHeaders 从 http.Header 中取出路由覆盖相关的请求头（供不经过 headers map 的入口使用）
+func routeOverrideHeaders(header http.Header) map[string]string {
+	return map[string]string{
+		http.CanonicalHeaderKey(RouteIDHeader):  header.Get(RouteIDHeader),
+		http.CanonicalHeaderKey(ProviderHeader): header.Get(ProviderHeader),
+	}
+}
+
+// matchesProvider 路由是否属于指定供应商：匹配路由名、分组、格式、供应商类型或上游域名
+func matchesProvider(route *database.ModelRoute, provider string) bool {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if provider == "" {
+		return false
+	}
+	for _, v := range []string{route.Name, route.Group, route.Format, providerKind(route.APIUrl, route.Format)} {
+		if strings.EqualFold(strings.TrimSpace(v), provider) {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(route.APIUrl), provider)
+}
+
+// overrideRoutes 按请求头指定的路由或供应商选择路由，ok 为 false 表示请求没有指定
+// 指定路由 ID 时直接使用该路由（模型改为路由的模型）；指定供应商时在模型的路由中筛选
+func (s *ProxyService) overrideRoutes(model string, headers map[string]string) (routes []database.ModelRoute, targetModel string, ok bool, err error) {
+	if idStr := headerValue(headers, RouteIDHeader); idStr != "" {
+		id, parseErr := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if parseErr != nil {
+			return nil, model, true, fmt.Errorf("invalid %s: %s", RouteIDHeader, idStr)
+		}
+		route, getErr := s.routeService.GetRouteByID(id)
+		if getErr != nil {
+			return nil, model, true, getErr
+		}
+		s.routeService.pickRouteKey(route)
+		log.Infof("[Route Override] %s: %d -> route %s (model: %s)", RouteIDHeader, id, route.Name, route.Model)
+		return []database.ModelRoute{*route}, route.Model, true, nil
+	}
+
+	provider := headerValue(headers, ProviderHeader)
+	if provider == "" {
+		return nil, model, false, nil
+	}
+	all, getErr := s.routeService.GetAllRoutesByModel(model)
+	if errors.Is(getErr, ErrRouteQuotaExhausted) {
+		return nil, model, true, getErr
+	}
+	if getErr != nil {
+		return nil, model, true, fmt.Errorf("model '%s' not found in route list", model)
+	}
+	for _, route := range all {
+		if matchesProvider(&route, provider) {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) == 0 {
+		return nil, model, true, fmt.Errorf("no route for model '%s' matches provider '%s'", model, provider)
+	}
+	if !s.config.FallbackEnabled {
+		routes = routes[:1]
+	}
+	log.Infof("[Route Override] %s: %s -> %d route(s) for model %s", ProviderHeader, provider, len(routes), model)
+	return routes, model, true, nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata" // Windows 等没有系统时区数据库的环境也能加载配额时区
+
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 路由每日配额：免费额度的 Key 每天有 token/请求数上限，当天用量达到上限后选择路由时跳过该路由，
+// 到重置时间（配额时区的零点）后恢复。当天用量在写入请求日志时累计，每天第一次使用时从请求日志读取
+
+// ErrRouteQuotaExhausted 匹配的路由都已用完今日配额
+var ErrRouteQuotaExhausted = errors.New("daily quota exhausted")
+
+// RouteQuota 路由今日配额的使用情况，未设置上限的一项剩余量为 -1
+type RouteQuota struct {
+	TokenLimit        int64  `json:"token_limit"`
+	RequestLimit      int64  `json:"request_limit"`
+	TokensUsed        int64  `json:"tokens_used"`
+	RequestsUsed      int64  `json:"requests_used"`
+	TokensRemaining   int64  `json:"tokens_remaining"`
+	RequestsRemaining int64  `json:"requests_remaining"`
+	Exhausted         bool   `json:"exhausted"`
+	ResetAt           string `json:"reset_at"` // 下次重置时间（RFC 3339，配额时区）
+}
+
+// routeQuotaUsage 路由当天的请求数和 token 消耗
+type routeQuotaUsage struct {
+	requests int64
+	tokens   int64
+}
+
+// ValidateQuotaTimezone 检查配额时区（IANA 名称），为空表示本地时区
+func ValidateQuotaTimezone(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(strings.TrimSpace(name)); err != nil {
+		return fmt.Errorf("invalid quota timezone %q: %v", name, err)
+	}
+	return nil
+}
+
+// SetQuotaTimezone 设置每日配额的重置时区，为空时使用本地时区；当天用量按新的日期边界重新读取
+func (s *RouteService) SetQuotaTimezone(name string) error {
+	loc := time.Local
+	if name = strings.TrimSpace(name); name != "" {
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return fmt.Errorf("invalid quota timezone %q: %v", name, err)
+		}
+	}
+	s.quotaMu.Lock()
+	s.quotaLoc = loc
+	s.quotaDay = ""
+	s.quotaMu.Unlock()
+	return nil
+}
+
+// SetRouteQuota 设置路由的每日 token 和请求数上限，0 表示不限制
+func (s *RouteService) SetRouteQuota(id int64, dailyTokens int64, dailyRequests int) error {
+	if dailyTokens < 0 || dailyRequests < 0 {
+		return fmt.Errorf("daily quota must not be negative")
+	}
+	result, err := s.db.Exec(`UPDATE model_routes SET daily_token_limit = ?, daily_request_limit = ? WHERE id = ?`, dailyTokens, dailyRequests, id)
+	if err != nil {
+		log.Errorf("Failed to set route quota: %v", err)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("route not found: id=%d", id)
+	}
+	log.Infof("Route quota updated: id=%d -> tokens=%d, requests=%d", id, dailyTokens, dailyRequests)
+	return nil
+}
+
+// hasQuota 路由是否设置了每日上限
+func hasQuota(route *database.ModelRoute) bool {
+	return route.DailyTokenLimit > 0 || route.DailyRequestLimit > 0
+}
+
+// quotaPeriod 当前配额日（配额时区的日期）的开始和结束时间，调用方持有 quotaMu
+func (s *RouteService) quotaPeriod(now time.Time) (time.Time, time.Time) {
+	loc := s.quotaLoc
+	if loc == nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// refreshQuotaLocked 进入新的配额日时从请求日志重新读取当天用量，调用方持有 quotaMu
+func (s *RouteService) refreshQuotaLocked(now time.Time) time.Time {
+	start, end := s.quotaPeriod(now)
+	day := start.Format("2006-01-02")
+	if s.quotaDay == day {
+		return end
+	}
+	usage, err := s.loadQuotaUsage(start)
+	if err != nil {
+		log.Warnf("[Quota] Failed to read today's route usage: %v", err)
+		usage = make(map[int64]routeQuotaUsage)
+	}
+	s.quotaDay = day
+	s.quotaUsed = usage
+	return end
+}
+
+// loadQuotaUsage 从请求日志汇总 since 之后每个路由的请求数和 token（日志时间为本地时间）
+func (s *RouteService) loadQuotaUsage(since time.Time) (map[int64]routeQuotaUsage, error) {
+	rows, err := s.db.Query(`
+		SELECT route_id, COUNT(*), COALESCE(SUM(total_tokens), 0)
+		FROM request_logs
+		WHERE route_id > 0 AND created_at >= ?
+		GROUP BY route_id
+	`, since.In(time.Local).Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[int64]routeQuotaUsage)
+	for rows.Next() {
+		var routeID int64
+		var u routeQuotaUsage
+		if err := rows.Scan(&routeID, &u.requests, &u.tokens); err != nil {
+			return nil, err
+		}
+		usage[routeID] = u
+	}
+	return usage, rows.Err()
+}
+
+// addQuotaUsage 累计刚写入的请求日志，新的一天时直接从数据库读取（已包含本批日志）
+func (s *RouteService) addQuotaUsage(batch []RequestLogParams) {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+	day := s.quotaDay
+	s.refreshQuotaLocked(time.Now())
+	if day != s.quotaDay {
+		return
+	}
+	start, _ := s.quotaPeriod(time.Now())
+	for _, params := range batch {
+		if params.RouteID <= 0 || params.CreatedAt.Before(start) {
+			continue
+		}
+		u := s.quotaUsed[params.RouteID]
+		u.requests++
+		u.tokens += int64(params.TotalTokens)
+		s.quotaUsed[params.RouteID] = u
+	}
+}
+
+// RouteQuota 路由今日配额的使用情况，未设置上限时返回 nil
+func (s *RouteService) RouteQuota(route *database.ModelRoute) *RouteQuota {
+	if !hasQuota(route) {
+		return nil
+	}
+	s.quotaMu.Lock()
+	end := s.refreshQuotaLocked(time.Now())
+	used := s.quotaUsed[route.ID]
+	s.quotaMu.Unlock()
+
+	quota := &RouteQuota{
+		TokenLimit:        route.DailyTokenLimit,
+		RequestLimit:      int64(route.DailyRequestLimit),
+		TokensUsed:        used.tokens,
+		RequestsUsed:      used.requests,
+		TokensRemaining:   -1,
+		RequestsRemaining: -1,
+		ResetAt:           end.Format(time.RFC3339),
+	}
+	if quota.TokenLimit > 0 {
+		quota.TokensRemaining = max(quota.TokenLimit-used.tokens, 0)
+		quota.Exhausted = quota.TokensRemaining == 0
+	}
+	if quota.RequestLimit > 0 {
+		quota.RequestsRemaining = max(quota.RequestLimit-used.requests, 0)
+		quota.Exhausted = quota.Exhausted || quota.RequestsRemaining == 0
+	}
+	return quota
+}
+
+// quotaExhausted 路由今日的 token 或请求数是否已达到上限
+func (s *RouteService) quotaExhausted(route *database.ModelRoute) bool {
+	if quota := s.RouteQuota(route); quota != nil && quota.Exhausted {
+		log.Infof("[Quota] Route %s (id: %d) reached its daily quota, skipped until %s", route.Name, route.ID, quota.ResetAt)
+		return true
+	}
+	return false
+}
+
+// routesWithinQuota 去掉已用完今日配额的路由，全部用完时返回 ErrRouteQuotaExhausted
+func (s *RouteService) routesWithinQuota(model string, routes []database.ModelRoute) ([]database.ModelRoute, error) {
+	available := routes[:0:0]
+	for i := range routes {
+		if !s.quotaExhausted(&routes[i]) {
+			available = append(available, routes[i])
+		}
+	}
+	if len(available) == 0 && len(routes) > 0 {
+		return nil, fmt.Errorf("all routes for model %s: %w", model, ErrRouteQuotaExhausted)
+	}
+	return available, nil
+}
+
+// excludeRoutesClause 排除 n 个路由ID的查询条件，路由ID追加在查询参数最后
+func excludeRoutesClause(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return " AND id NOT IN (" + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + ")"
+}
+
+// routeSelectionStatus 选择路由失败时返回给客户端的状态码：配额用完为 429，其余为 404
+func routeSelectionStatus(err error) int {
+	if errors.Is(err, ErrRouteQuotaExhausted) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusNotFound
+}
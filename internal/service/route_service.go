@@ -2,34 +2,104 @@ package service
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"openai-router-go/internal/database"
+	"openai-router-go/internal/secret"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type RouteService struct {
-	db      *sql.DB
-	traceDB *sql.DB
+	db      *database.DB
+	traceDB *database.DB
+	keys    *secret.Box // API Key 加密存储，为 nil 时使用明文
+	logs    *requestLogWriter
+
+	keyPool       *keyPool          // 多 Key 路由的轮询和冷却状态
+	usageCaptures sync.Map          // 请求ID -> *UsageCapture，用于估算上游缺失的 usage
+	upstreamCosts upstreamCostStore // 上游返回的实际费用，写入请求日志时按请求ID取出
+	virtualKeys   sync.Map          // 请求ID -> 客户端使用的虚拟 Key 名称，写入请求日志时使用
+	probeMu       sync.RWMutex
+	probeStates   map[int64]*routeProbeState // 路由最近的主动探测状态
+	healthAware   atomic.Bool                // 健康感知路由
+	quotaMu       sync.Mutex
+	quotaLoc      *time.Location            // 每日配额的重置时区，为 nil 时使用本地时区
+	quotaDay      string                    // quotaUsed 对应的配额日
+	quotaUsed     map[int64]routeQuotaUsage // 路由当天的请求数和 token 消耗
+	notifier      *Notifier                  // 事件通知，为 nil 时不通知
+	live          liveFeed                   // 实时请求事件（GUI 实时请求流）
 }
 
-func NewRouteService(db *sql.DB, traceDB *sql.DB) *RouteService {
-	return &RouteService{db: db, traceDB: traceDB}
+func NewRouteService(db *database.DB, traceDB *database.DB) *RouteService {
+	s := &RouteService{db: db, traceDB: traceDB, keyPool: newKeyPool()}
+	s.logs = newRequestLogWriter(s)
+	return s
 }
 
-func (s *RouteService) getTraceDB() *sql.DB {
+// SetNotifier 设置事件通知
+func (s *RouteService) SetNotifier(n *Notifier) {
+	s.notifier = n
+}
+
+// FlushRequestLogs 退出前写入缓冲中的请求日志
+func (s *RouteService) FlushRequestLogs() {
+	s.logs.flush(logFlushTimeout)
+}
+
+// GetLogBufferStatus 请求日志缓冲状态（数据库不可用时用于提示）
+func (s *RouteService) GetLogBufferStatus() map[string]interface{} {
+	pending, dropped, failing := s.logs.status()
+	return map[string]interface{}{
+		"pending":   pending,
+		"dropped":   dropped,
+		"dbFailing": failing,
+	}
+}
+
+func (s *RouteService) getTraceDB() *database.DB {
 	if s.traceDB != nil {
 		return s.traceDB
 	}
 	return s.db
 }
 
+// routeColumns 路由查询的列，顺序与 scanRoute 一致
+const routeColumns = `id, name, model, api_url, api_key, "group", COALESCE(format, 'openai'), enabled, created_at, updated_at,
+	COALESCE(extra_headers, ''), COALESCE(extra_query, ''), COALESCE(priority, 0), COALESCE(max_tokens, 0),
+	COALESCE(upstream_model, ''), COALESCE(provider_prefs, ''), COALESCE(daily_token_limit, 0), COALESCE(daily_request_limit, 0)`
+
+// rowScanner *sql.Row 和 *sql.Rows 的公共接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRoute 读取一行路由（列见 routeColumns），解密 API Key 并解析自定义请求头和查询参数
+func (s *RouteService) scanRoute(row rowScanner) (database.ModelRoute, error) {
+	var route database.ModelRoute
+	var extraHeaders, extraQuery string
+	err := row.Scan(&route.ID, &route.Name, &route.Model, &route.APIUrl, &route.APIKey,
+		&route.Group, &route.Format, &route.Enabled, &route.CreatedAt, &route.UpdatedAt,
+		&extraHeaders, &extraQuery, &route.Priority, &route.MaxTokens, &route.UpstreamModel, &route.ProviderPrefs,
+		&route.DailyTokenLimit, &route.DailyRequestLimit)
+	if err != nil {
+		return route, err
+	}
+	route.APIKey = s.openKey(route.APIKey)
+	route.ExtraHeaders = parseRouteExtras(extraHeaders)
+	route.ExtraQuery = parseRouteExtras(extraQuery)
+	return route, nil
+}
+
 // GetAllRoutes 获取所有路由
 func (s *RouteService) GetAllRoutes() ([]database.ModelRoute, error) {
-	query := `SELECT id, name, model, api_url, api_key, "group", COALESCE(format, 'openai'), enabled, created_at, updated_at
+	query := `SELECT ` + routeColumns + `
 	          FROM model_routes ORDER BY created_at DESC`
 
 	rows, err := s.db.Query(query)
@@ -40,9 +110,7 @@ func (s *RouteService) GetAllRoutes() ([]database.ModelRoute, error) {
 
 	var routes []database.ModelRoute
 	for rows.Next() {
-		var route database.ModelRoute
-		err := rows.Scan(&route.ID, &route.Name, &route.Model, &route.APIUrl, &route.APIKey,
-			&route.Group, &route.Format, &route.Enabled, &route.CreatedAt, &route.UpdatedAt)
+		route, err := s.scanRoute(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -53,43 +121,60 @@ func (s *RouteService) GetAllRoutes() ([]database.ModelRoute, error) {
 }
 
 // GetRouteByModel 根据模型名获取路由(支持负载均衡和后缀匹配)
-// 匹配规则: 精确匹配 + 后缀匹配 一起参与负载均衡
+// 匹配规则: 精确匹配 + 后缀匹配 一起参与负载均衡，只在优先级最高的路由中选择
 // 例如: 请求 "gemini-3-flash" 可匹配 "gemini-3-flash" 和 "流式抗截断/gemini-3-flash"
 func (s *RouteService) GetRouteByModel(model string) (*database.ModelRoute, error) {
-	// 精确匹配 + 后缀匹配 一起参与负载均衡
-	query := `SELECT id, name, model, api_url, api_key, "group", COALESCE(format, 'openai'), enabled, created_at, updated_at
-	          FROM model_routes 
-	          WHERE (model = ? OR model LIKE '%/' || ?) AND enabled = 1 
-	          ORDER BY RANDOM() LIMIT 1`
+	// A/B 实验生效时按分组权重选择路由
+	if route := s.experimentRoute(model); route != nil && !s.quotaExhausted(route) {
+		return route, nil
+	}
 
+	// 精确匹配 + 后缀匹配 一起参与负载均衡，跳过已用完今日配额的路由
 	var route database.ModelRoute
-	err := s.db.QueryRow(query, model, model).Scan(&route.ID, &route.Name, &route.Model, &route.APIUrl,
-		&route.APIKey, &route.Group, &route.Format, &route.Enabled, &route.CreatedAt, &route.UpdatedAt)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("model not found: %s", model)
-	}
-	if err != nil {
-		return nil, err
+	skipped := 0
+	args := []interface{}{model, "%/" + model}
+	for {
+		query := `SELECT ` + routeColumns + `
+		          FROM model_routes 
+		          WHERE (model = ? OR model LIKE ?) AND enabled = 1` + excludeRoutesClause(skipped) + `
+		          ORDER BY ` + routePriorityOrder + `, RANDOM() LIMIT 1`
+
+		var err error
+		route, err = s.scanRoute(s.db.QueryRow(query, args...))
+		if err == sql.ErrNoRows {
+			if skipped > 0 {
+				return nil, fmt.Errorf("all routes for model %s: %w", model, ErrRouteQuotaExhausted)
+			}
+			return nil, fmt.Errorf("model not found: %s", model)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !s.quotaExhausted(&route) {
+			break
+		}
+		skipped++
+		args = append(args, route.ID)
 	}
 
 	// 如果是后缀匹配，记录日志
 	if route.Model != model {
 		log.Infof("[Suffix Match] '%s' matched to '%s'", model, route.Model)
 	}
+	s.pickRouteKey(&route)
 	return &route, nil
 }
 
 // GetAllRoutesByModel 根据模型名获取所有匹配的路由(用于 Fallback 故障转移)
-// 返回所有匹配的路由，随机排序用于负载均衡
+// 返回所有匹配的路由，按分组优先级和路由优先级排序，优先级相同的随机排序用于负载均衡
 // 匹配规则: 精确匹配 + 后缀匹配
 func (s *RouteService) GetAllRoutesByModel(model string) ([]database.ModelRoute, error) {
-	query := `SELECT id, name, model, api_url, api_key, "group", COALESCE(format, 'openai'), enabled, created_at, updated_at
+	query := `SELECT ` + routeColumns + `
 	          FROM model_routes 
-	          WHERE (model = ? OR model LIKE '%/' || ?) AND enabled = 1 
-	          ORDER BY RANDOM()`
+	          WHERE (model = ? OR model LIKE ?) AND enabled = 1 
+	          ORDER BY ` + routePriorityOrder + `, RANDOM()`
 
-	rows, err := s.db.Query(query, model, model)
+	rows, err := s.db.Query(query, model, "%/"+model)
 	if err != nil {
 		return nil, err
 	}
@@ -97,30 +182,30 @@ func (s *RouteService) GetAllRoutesByModel(model string) ([]database.ModelRoute,
 
 	var routes []database.ModelRoute
 	for rows.Next() {
-		var route database.ModelRoute
-		err := rows.Scan(&route.ID, &route.Name, &route.Model, &route.APIUrl, &route.APIKey,
-			&route.Group, &route.Format, &route.Enabled, &route.CreatedAt, &route.UpdatedAt)
+		route, err := s.scanRoute(rows)
 		if err != nil {
 			return nil, err
 		}
+		s.pickRouteKey(&route)
 		routes = append(routes, route)
 	}
 
 	if len(routes) == 0 {
 		return nil, fmt.Errorf("model not found: %s", model)
 	}
+	if routes, err = s.routesWithinQuota(model, routes); err != nil {
+		return nil, err
+	}
 
-	return routes, nil
+	return s.orderRoutesByHealth(s.applyExperiment(model, routes)), nil
 }
 
 // GetRouteByID 根据路由ID获取路由
 func (s *RouteService) GetRouteByID(id int64) (*database.ModelRoute, error) {
-	query := `SELECT id, name, model, api_url, api_key, "group", COALESCE(format, 'openai'), enabled, created_at, updated_at
+	query := `SELECT ` + routeColumns + `
 	          FROM model_routes WHERE id = ? AND enabled = 1`
 
-	var route database.ModelRoute
-	err := s.db.QueryRow(query, id).Scan(&route.ID, &route.Name, &route.Model, &route.APIUrl,
-		&route.APIKey, &route.Group, &route.Format, &route.Enabled, &route.CreatedAt, &route.UpdatedAt)
+	route, err := s.scanRoute(s.db.QueryRow(query, id))
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("route not found: %d", id)
@@ -137,8 +222,14 @@ func (s *RouteService) AddRoute(name, model, apiUrl, apiKey, group, format strin
 	query := `INSERT INTO model_routes (name, model, api_url, api_key, "group", format, enabled, created_at, updated_at)
 	          VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?)`
 
+	storedKey, err := s.sealKey(apiKey)
+	if err != nil {
+		log.Errorf("Failed to encrypt API key: %v", err)
+		return err
+	}
+
 	now := time.Now()
-	_, err := s.db.Exec(query, name, model, apiUrl, apiKey, group, format, now, now)
+	_, err = s.db.Exec(query, name, model, apiUrl, storedKey, group, format, now, now)
 	if err != nil {
 		log.Errorf("Failed to add route: %v", err)
 		return err
@@ -153,7 +244,13 @@ func (s *RouteService) UpdateRoute(id int64, name, model, apiUrl, apiKey, group,
 	query := `UPDATE model_routes SET name = ?, model = ?, api_url = ?, api_key = ?, "group" = ?, format = ?, updated_at = ?
 	          WHERE id = ?`
 
-	result, err := s.db.Exec(query, name, model, apiUrl, apiKey, group, format, time.Now(), id)
+	storedKey, err := s.sealKey(apiKey)
+	if err != nil {
+		log.Errorf("Failed to encrypt API key: %v", err)
+		return err
+	}
+
+	result, err := s.db.Exec(query, name, model, apiUrl, storedKey, group, format, time.Now(), id)
 	if err != nil {
 		log.Errorf("Failed to update route: %v", err)
 		return err
@@ -177,6 +274,16 @@ func (s *RouteService) DeleteRoute(id int64) error {
 		return err
 	}
 
+	if _, err := s.db.Exec(`DELETE FROM route_health_checks WHERE route_id = ?`, id); err != nil {
+		log.Warnf("Failed to delete route health checks: %v", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM prompt_templates WHERE scope = ? AND target = ?`, PromptScopeRoute, strconv.FormatInt(id, 10)); err != nil {
+		log.Warnf("Failed to delete route prompt template: %v", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM mirror_rules WHERE shadow_route_id = ?`, id); err != nil {
+		log.Warnf("Failed to delete route mirror rules: %v", err)
+	}
+
 	// 再删除路由
 	query := `DELETE FROM model_routes WHERE id = ?`
 	result, err := s.db.Exec(query, id)
@@ -284,21 +391,28 @@ func (s *RouteService) GetStats() (map[string]interface{}, error) {
 
 // RequestLogParams 请求日志参数
 type RequestLogParams struct {
-	Model          string // 请求的模型名
-	ProviderModel  string // 实际使用的提供商模型
-	ProviderName   string // 提供商/路由名称
-	RouteID        int64
-	RequestTokens  int
-	ResponseTokens int
-	TotalTokens    int
-	Success        bool
-	ErrorMessage   string
-	Style          string // 请求类型: openai, claude, gemini
-	UserAgent      string
-	RemoteIP       string
-	ProxyTimeMs    int64 // 代理总耗时(毫秒)
-	FirstChunkMs   int64 // 首字节时间(毫秒)
-	IsStream       bool  // 是否流式请求
+	Model           string // 请求的模型名
+	ProviderModel   string // 实际使用的提供商模型
+	ProviderName    string // 提供商/路由名称
+	RouteID         int64
+	RequestTokens   int
+	ResponseTokens  int
+	TotalTokens     int
+	Success         bool
+	ErrorMessage    string
+	Style           string // 请求类型: openai, claude, gemini
+	UserAgent       string
+	RemoteIP        string
+	ProxyTimeMs     int64   // 代理总耗时(毫秒)
+	FirstChunkMs    int64   // 首字节时间(毫秒)
+	IsStream        bool    // 是否流式请求
+	RequestID       string  // 请求唯一ID
+	KeyHash         string  // 使用的上游 Key 指纹，为空时根据 RequestID 自动补全
+	TokensEstimated bool    // token 数为本地估算值（上游未返回 usage）
+	Cost            float64 // 上游返回的实际费用（美元），为 0 时根据 RequestID 取 doWithRetry 记录的费用
+	VirtualKey      string  // 客户端使用的虚拟 Key 名称，为空时根据 RequestID 取请求绑定的虚拟 Key
+	ErrorType       string  // 失败请求的错误类型，为空时根据 ErrorMessage 分类
+	CreatedAt       time.Time
 }
 
 // LogRequest 记录请求日志（兼容旧版本 - 自动从 routeID 查询补全信息）
@@ -314,38 +428,64 @@ func (s *RouteService) LogRequest(model string, routeID int64, requestTokens, re
 		IsStream:       true, // 旧版 LogRequest 主要被流式请求使用
 	}
 
-	// 自动根据 routeID 查询补全 ProviderName 和 ProviderModel
-	if routeID > 0 {
-		route, err := s.GetRouteByID(routeID)
-		if err == nil && route != nil {
-			params.ProviderName = route.Name
-			params.ProviderModel = route.Model
-			// 根据路由 format 推断 Style
-			if route.Format != "" {
-				params.Style = strings.ToLower(route.Format)
-			}
-		}
-	}
-
+	// ProviderName、ProviderModel 和 Style 在写入时根据 routeID 自动补全
 	return s.LogRequestFull(params)
 }
 
 // LogRequestFull 记录完整的请求日志
+// 日志异步写入，不阻塞代理请求；数据库被锁定或不可用时暂存在内存中稍后重试
 func (s *RouteService) LogRequestFull(params RequestLogParams) error {
-	// 如果 ProviderName/ProviderModel 为空且有 RouteID，尝试查询补全
-	if (params.ProviderName == "" || params.ProviderModel == "") && params.RouteID > 0 {
-		route, err := s.GetRouteByID(params.RouteID)
-		if err == nil && route != nil {
-			if params.ProviderName == "" {
-				params.ProviderName = route.Name
-			}
-			if params.ProviderModel == "" {
-				params.ProviderModel = route.Model
-			}
-			// 如果 Style 为空，根据路由 format 推断
-			if params.Style == "" && route.Format != "" {
-				params.Style = strings.ToLower(route.Format)
-			}
+	if params.CreatedAt.IsZero() {
+		params.CreatedAt = time.Now()
+	}
+	if params.KeyHash == "" && params.RequestID != "" {
+		params.KeyHash = s.keyPool.keyForRequest(params.RequestID, params.RouteID)
+	}
+	if params.Cost == 0 && params.RequestID != "" {
+		params.Cost = s.upstreamCosts.take(params.RequestID)
+	}
+	if params.VirtualKey == "" && params.RequestID != "" {
+		params.VirtualKey = s.requestVirtualKey(params.RequestID)
+	}
+	if params.Success {
+		params.ErrorType = ""
+	} else if params.ErrorType == "" {
+		params.ErrorType = ClassifyError(params.ErrorMessage)
+	}
+	if s.deferForEstimate(params) {
+		return nil
+	}
+	s.live.publish(params)
+	s.logs.enqueue(params)
+	return nil
+}
+
+// insertRequestLogs 在一个事务中批量写入请求日志，失败时整批回滚
+func (s *RouteService) insertRequestLogs(batch []RequestLogParams) error {
+	// 如果 ProviderName/ProviderModel 为空且有 RouteID，尝试查询补全（同一批次内按路由缓存）
+	routes := make(map[int64]*database.ModelRoute)
+	for i := range batch {
+		params := &batch[i]
+		if (params.ProviderName != "" && params.ProviderModel != "") || params.RouteID <= 0 {
+			continue
+		}
+		route, ok := routes[params.RouteID]
+		if !ok {
+			route, _ = s.GetRouteByID(params.RouteID)
+			routes[params.RouteID] = route
+		}
+		if route == nil {
+			continue
+		}
+		if params.ProviderName == "" {
+			params.ProviderName = route.Name
+		}
+		if params.ProviderModel == "" {
+			params.ProviderModel = route.Model
+		}
+		// 如果 Style 为空，根据路由 format 推断
+		if params.Style == "" && route.Format != "" {
+			params.Style = strings.ToLower(route.Format)
 		}
 	}
 
@@ -353,27 +493,55 @@ func (s *RouteService) LogRequestFull(params RequestLogParams) error {
 		model, provider_model, provider_name, route_id, 
 		request_tokens, response_tokens, total_tokens, 
 		success, error_message, style, user_agent, remote_ip,
-		proxy_time_ms, first_chunk_ms, is_stream, created_at
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now', 'localtime'))`
-
-	_, err := s.db.Exec(query,
-		params.Model, params.ProviderModel, params.ProviderName, params.RouteID,
-		params.RequestTokens, params.ResponseTokens, params.TotalTokens,
-		params.Success, params.ErrorMessage, params.Style, params.UserAgent, params.RemoteIP,
-		params.ProxyTimeMs, params.FirstChunkMs, params.IsStream,
-	)
+		proxy_time_ms, first_chunk_ms, is_stream, request_id, key_hash, tokens_estimated, cost, virtual_key, error_type, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	tx, err := s.db.Begin()
 	if err != nil {
-		log.Errorf("LogRequestFull error: %v", err)
-	} else {
-		log.Infof("LogRequest: model=%s, provider=%s, tokens=%d, success=%v, time=%dms, stream=%v",
+		return err
+	}
+	for _, params := range batch {
+		_, err := tx.Exec(query,
+			params.Model, params.ProviderModel, params.ProviderName, params.RouteID,
+			params.RequestTokens, params.ResponseTokens, params.TotalTokens,
+			params.Success, params.ErrorMessage, params.Style, params.UserAgent, params.RemoteIP,
+			params.ProxyTimeMs, params.FirstChunkMs, params.IsStream, params.RequestID, params.KeyHash, params.TokensEstimated, params.Cost, params.VirtualKey, params.ErrorType,
+			params.CreatedAt.Format("2006-01-02 15:04:05"),
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	var tokens int64
+	for _, params := range batch {
+		RequestLogger(params.RequestID).Infof("LogRequest: model=%s, provider=%s, tokens=%d, success=%v, time=%dms, stream=%v",
 			params.Model, params.ProviderName, params.TotalTokens, params.Success, params.ProxyTimeMs, params.IsStream)
+		tokens += int64(params.TotalTokens)
 	}
-	return err
+	s.notifier.addTokens(tokens, s.todayTokens)
+	s.addQuotaUsage(batch)
+	return nil
 }
 
-// GetRequestLogs 获取请求日志（支持分页和筛选）
-func (s *RouteService) GetRequestLogs(page, pageSize int, filters map[string]string) ([]database.RequestLog, int, error) {
-	// 构建 WHERE 子句
+// todayTokens 今日请求日志的 token 总量
+func (s *RouteService) todayTokens() int64 {
+	var tokens int64
+	if err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(total_tokens), 0) FROM request_logs
+		WHERE substr(created_at, 1, 10) = date('now', 'localtime')
+	`).Scan(&tokens); err != nil {
+		log.Warnf("Failed to read today's token usage: %v", err)
+	}
+	return tokens
+}
+
+// requestLogWhere 按筛选条件（model、provider_name、request_id、style、success、start_time、end_time、search）构建 WHERE 子句
+func (s *RouteService) requestLogWhere(filters map[string]string) (string, []interface{}) {
 	var conditions []string
 	var args []interface{}
 
@@ -385,6 +553,10 @@ func (s *RouteService) GetRequestLogs(page, pageSize int, filters map[string]str
 		conditions = append(conditions, "provider_name = ?")
 		args = append(args, providerName)
 	}
+	if requestID, ok := filters["request_id"]; ok && requestID != "" {
+		conditions = append(conditions, "request_id = ?")
+		args = append(args, requestID)
+	}
 	if style, ok := filters["style"]; ok && style != "" {
 		conditions = append(conditions, "style = ?")
 		args = append(args, style)
@@ -405,11 +577,22 @@ func (s *RouteService) GetRequestLogs(page, pageSize int, filters map[string]str
 		conditions = append(conditions, "created_at <= ?")
 		args = append(args, endTime)
 	}
+	// 全文搜索错误信息
+	if condition, searchArgs := s.requestLogSearchCondition(filters["search"]); condition != "" {
+		conditions = append(conditions, condition)
+		args = append(args, searchArgs...)
+	}
 
 	whereClause := ""
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
 	}
+	return whereClause, args
+}
+
+// GetRequestLogs 获取请求日志（支持分页和筛选）
+func (s *RouteService) GetRequestLogs(page, pageSize int, filters map[string]string) ([]database.RequestLog, int, error) {
+	whereClause, args := s.requestLogWhere(filters)
 
 	// 查询总数
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM request_logs %s", whereClause)
@@ -426,7 +609,7 @@ func (s *RouteService) GetRequestLogs(page, pageSize int, filters map[string]str
 		       success, COALESCE(error_message, ''), COALESCE(style, ''), 
 		       COALESCE(user_agent, ''), COALESCE(remote_ip, ''),
 		       COALESCE(proxy_time_ms, 0), COALESCE(first_chunk_ms, 0), 
-		       COALESCE(is_stream, 0), created_at
+		       COALESCE(is_stream, 0), COALESCE(request_id, ''), COALESCE(tokens_estimated, 0), COALESCE(cost, 0), COALESCE(error_type, ''), created_at
 		FROM request_logs %s
 		ORDER BY id DESC
 		LIMIT ? OFFSET ?`, whereClause)
@@ -441,18 +624,22 @@ func (s *RouteService) GetRequestLogs(page, pageSize int, filters map[string]str
 	var logs []database.RequestLog
 	for rows.Next() {
 		var l database.RequestLog
-		var isStream int
+		var isStream, estimated int
 		err := rows.Scan(
 			&l.ID, &l.Model, &l.ProviderModel, &l.ProviderName,
 			&l.RouteID, &l.RequestTokens, &l.ResponseTokens, &l.TotalTokens,
 			&l.Success, &l.ErrorMessage, &l.Style,
 			&l.UserAgent, &l.RemoteIP,
-			&l.ProxyTimeMs, &l.FirstChunkMs, &isStream, &l.CreatedAt,
+			&l.ProxyTimeMs, &l.FirstChunkMs, &isStream, &l.RequestID, &estimated, &l.Cost, &l.ErrorType, &l.CreatedAt,
 		)
 		if err != nil {
 			return nil, 0, err
 		}
 		l.IsStream = isStream == 1
+		l.TokensEstimated = estimated == 1
+		if !l.Success && l.ErrorType == "" {
+			l.ErrorType = ClassifyError(l.ErrorMessage)
+		}
 		logs = append(logs, l)
 	}
 
@@ -569,7 +756,7 @@ func (s *RouteService) GetDailyStats(days int) ([]map[string]interface{}, error)
 			FROM request_logs
 			WHERE substr(created_at, 1, 10) >= date('now', 'localtime', ?)
 			GROUP BY substr(created_at, 1, 10)
-		)
+		) combined
 		GROUP BY date
 		ORDER BY date
 	`
@@ -654,7 +841,7 @@ func (s *RouteService) GetSecondlyStats(minutes int) ([]map[string]interface{},
 	// 获取今日全天的秒级数据
 	query := `
 		SELECT
-			created_at as timestamp,
+			MIN(substr(created_at, 1, 19)) as timestamp,
 			COUNT(*) as requests,
 			COALESCE(SUM(request_tokens), 0) as request_tokens,
 			COALESCE(SUM(response_tokens), 0) as response_tokens,
@@ -954,7 +1141,7 @@ func (s *RouteService) GetModelRanking(limit int) ([]map[string]interface{}, err
 				SUM(CASE WHEN success = 1 THEN 1 ELSE 0 END) as success_count
 			FROM request_logs
 			GROUP BY model
-		)
+		) combined
 		GROUP BY model
 		ORDER BY total_tokens DESC
 	`
@@ -1005,12 +1192,21 @@ func (s *RouteService) GetModelRanking(limit int) ([]map[string]interface{}, err
 }
 
 // CompressDatabase 压缩数据库
-// 1. 将 request_logs 中今天之前的数据按小时聚合到 hourly_stats
+// 1. 将 request_logs 中今天之前的数据按小时聚合到 hourly_stats（已存在的记录累加）
 // 2. 删除已聚合的 request_logs 数据
 // 3. 更新 usage_summary 中的周/年/总用量
 // 4. 删除超过 366 天的 hourly_stats 数据
 func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
+	return s.CompressRequestLogs(0, true)
+}
+
+// CompressRequestLogs 将 keepDays 天之前的请求日志聚合到 hourly_stats（0 表示今天之前），vacuum 为 true 时随后压缩数据库文件
+func (s *RouteService) CompressRequestLogs(keepDays int, vacuum bool) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
+	if keepDays < 0 {
+		keepDays = 0
+	}
+	cutoff := fmt.Sprintf("-%d days", keepDays)
 
 	// 开始事务
 	tx, err := s.db.Begin()
@@ -1027,10 +1223,10 @@ func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
 	}
 	result["before_count"] = beforeCount
 
-	// 2. 将今天之前的数据按小时聚合
-	// 先创建临时表存储聚合结果
+	// 2. 将今天之前的数据按小时聚合，合并到 hourly_stats（累加已存在的记录，插入新记录）
 	_, err = tx.Exec(`
-		CREATE TEMP TABLE temp_hourly AS
+		INSERT INTO hourly_stats (date, hour, model, request_count, request_tokens, response_tokens, total_tokens, success_count, fail_count,
+			reported_cost, reported_request_tokens, reported_response_tokens)
 		SELECT 
 			substr(created_at, 1, 10) as date,
 			CAST(substr(created_at, 12, 2) AS INTEGER) as hour,
@@ -1040,64 +1236,43 @@ func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
 			COALESCE(SUM(response_tokens), 0) as response_tokens,
 			COALESCE(SUM(total_tokens), 0) as total_tokens,
 			SUM(CASE WHEN success = 1 THEN 1 ELSE 0 END) as success_count,
-			SUM(CASE WHEN success = 0 THEN 1 ELSE 0 END) as fail_count
+			SUM(CASE WHEN success = 0 THEN 1 ELSE 0 END) as fail_count,
+			COALESCE(SUM(cost), 0) as reported_cost,
+			COALESCE(SUM(CASE WHEN cost > 0 THEN request_tokens ELSE 0 END), 0) as reported_request_tokens,
+			COALESCE(SUM(CASE WHEN cost > 0 THEN response_tokens ELSE 0 END), 0) as reported_response_tokens
 		FROM request_logs
-		WHERE substr(created_at, 1, 10) < date('now', 'localtime')
+		WHERE substr(created_at, 1, 10) < date('now', 'localtime', ?)
 		GROUP BY substr(created_at, 1, 10), CAST(substr(created_at, 12, 2) AS INTEGER), model
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp table: %v", err)
-	}
-
-	// 3. 合并到 hourly_stats（累加已存在的记录，插入新记录）
-	_, err = tx.Exec(`
-		INSERT INTO hourly_stats (date, hour, model, request_count, request_tokens, response_tokens, total_tokens, success_count, fail_count)
-		SELECT date, hour, model, request_count, request_tokens, response_tokens, total_tokens, success_count, fail_count
-		FROM temp_hourly
-		WHERE NOT EXISTS (
-			SELECT 1 FROM hourly_stats h 
-			WHERE h.date = temp_hourly.date AND h.hour = temp_hourly.hour AND h.model = temp_hourly.model
-		)
-	`)
+		ON CONFLICT(date, hour, model) DO UPDATE SET
+			request_count = hourly_stats.request_count + excluded.request_count,
+			request_tokens = hourly_stats.request_tokens + excluded.request_tokens,
+			response_tokens = hourly_stats.response_tokens + excluded.response_tokens,
+			total_tokens = hourly_stats.total_tokens + excluded.total_tokens,
+			success_count = hourly_stats.success_count + excluded.success_count,
+			fail_count = hourly_stats.fail_count + excluded.fail_count,
+			reported_cost = COALESCE(hourly_stats.reported_cost, 0) + excluded.reported_cost,
+			reported_request_tokens = COALESCE(hourly_stats.reported_request_tokens, 0) + excluded.reported_request_tokens,
+			reported_response_tokens = COALESCE(hourly_stats.reported_response_tokens, 0) + excluded.reported_response_tokens
+	`, cutoff)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert new hourly stats: %v", err)
+		return nil, fmt.Errorf("failed to merge hourly stats: %v", err)
 	}
 
-	_, err = tx.Exec(`
-		UPDATE hourly_stats SET
-			request_count = hourly_stats.request_count + (SELECT request_count FROM temp_hourly t WHERE t.date = hourly_stats.date AND t.hour = hourly_stats.hour AND t.model = hourly_stats.model),
-			request_tokens = hourly_stats.request_tokens + (SELECT request_tokens FROM temp_hourly t WHERE t.date = hourly_stats.date AND t.hour = hourly_stats.hour AND t.model = hourly_stats.model),
-			response_tokens = hourly_stats.response_tokens + (SELECT response_tokens FROM temp_hourly t WHERE t.date = hourly_stats.date AND t.hour = hourly_stats.hour AND t.model = hourly_stats.model),
-			total_tokens = hourly_stats.total_tokens + (SELECT total_tokens FROM temp_hourly t WHERE t.date = hourly_stats.date AND t.hour = hourly_stats.hour AND t.model = hourly_stats.model),
-			success_count = hourly_stats.success_count + (SELECT success_count FROM temp_hourly t WHERE t.date = hourly_stats.date AND t.hour = hourly_stats.hour AND t.model = hourly_stats.model),
-			fail_count = hourly_stats.fail_count + (SELECT fail_count FROM temp_hourly t WHERE t.date = hourly_stats.date AND t.hour = hourly_stats.hour AND t.model = hourly_stats.model)
-		WHERE EXISTS (SELECT 1 FROM temp_hourly t WHERE t.date = hourly_stats.date AND t.hour = hourly_stats.hour AND t.model = hourly_stats.model)
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update hourly stats: %v", err)
-	}
-
-	// 4. 删除临时表
-	_, err = tx.Exec("DROP TABLE temp_hourly")
-	if err != nil {
-		return nil, fmt.Errorf("failed to drop temp table: %v", err)
-	}
-
-	// 5. 删除今天之前的原始请求日志
+	// 3. 删除已聚合的原始请求日志
 	deleteResult, err := tx.Exec(`
 		DELETE FROM request_logs 
-		WHERE substr(created_at, 1, 10) < date('now', 'localtime')
-	`)
+		WHERE substr(created_at, 1, 10) < date('now', 'localtime', ?)
+	`, cutoff)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete old logs: %v", err)
 	}
 	deletedLogs, _ := deleteResult.RowsAffected()
 	result["deleted_logs"] = deletedLogs
 
-	// 6. 更新 usage_summary
+	// 4. 更新 usage_summary
 	// 周用量
 	_, err = tx.Exec(`
-		INSERT OR REPLACE INTO usage_summary (period_type, period_key, request_count, request_tokens, response_tokens, total_tokens, success_count, fail_count, updated_at)
+		INSERT INTO usage_summary (period_type, period_key, request_count, request_tokens, response_tokens, total_tokens, success_count, fail_count, updated_at)
 		SELECT 
 			'week' as period_type,
 			strftime('%Y-W%W', date) as period_key,
@@ -1105,6 +1280,10 @@ func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
 			datetime('now', 'localtime')
 		FROM hourly_stats
 		GROUP BY strftime('%Y-W%W', date)
+		ON CONFLICT(period_type, period_key) DO UPDATE SET
+			request_count = excluded.request_count, request_tokens = excluded.request_tokens,
+			response_tokens = excluded.response_tokens, total_tokens = excluded.total_tokens,
+			success_count = excluded.success_count, fail_count = excluded.fail_count, updated_at = excluded.updated_at
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update week summary: %v", err)
@@ -1112,7 +1291,7 @@ func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
 
 	// 年用量
 	_, err = tx.Exec(`
-		INSERT OR REPLACE INTO usage_summary (period_type, period_key, request_count, request_tokens, response_tokens, total_tokens, success_count, fail_count, updated_at)
+		INSERT INTO usage_summary (period_type, period_key, request_count, request_tokens, response_tokens, total_tokens, success_count, fail_count, updated_at)
 		SELECT 
 			'year' as period_type,
 			strftime('%Y', date) as period_key,
@@ -1120,6 +1299,10 @@ func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
 			datetime('now', 'localtime')
 		FROM hourly_stats
 		GROUP BY strftime('%Y', date)
+		ON CONFLICT(period_type, period_key) DO UPDATE SET
+			request_count = excluded.request_count, request_tokens = excluded.request_tokens,
+			response_tokens = excluded.response_tokens, total_tokens = excluded.total_tokens,
+			success_count = excluded.success_count, fail_count = excluded.fail_count, updated_at = excluded.updated_at
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update year summary: %v", err)
@@ -1127,18 +1310,23 @@ func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
 
 	// 总用量
 	_, err = tx.Exec(`
-		INSERT OR REPLACE INTO usage_summary (period_type, period_key, request_count, request_tokens, response_tokens, total_tokens, success_count, fail_count, updated_at)
+		INSERT INTO usage_summary (period_type, period_key, request_count, request_tokens, response_tokens, total_tokens, success_count, fail_count, updated_at)
 		SELECT 
 			'total', 'total',
 			SUM(request_count), SUM(request_tokens), SUM(response_tokens), SUM(total_tokens), SUM(success_count), SUM(fail_count),
 			datetime('now', 'localtime')
 		FROM hourly_stats
+		WHERE 1 = 1
+		ON CONFLICT(period_type, period_key) DO UPDATE SET
+			request_count = excluded.request_count, request_tokens = excluded.request_tokens,
+			response_tokens = excluded.response_tokens, total_tokens = excluded.total_tokens,
+			success_count = excluded.success_count, fail_count = excluded.fail_count, updated_at = excluded.updated_at
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update total summary: %v", err)
 	}
 
-	// 7. 删除超过 366 天的 hourly_stats 数据
+	// 5. 删除超过 366 天的 hourly_stats 数据
 	deleteStatsResult, err := tx.Exec(`
 		DELETE FROM hourly_stats 
 		WHERE date < date('now', 'localtime', '-366 days')
@@ -1149,7 +1337,7 @@ func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
 	deletedStats, _ := deleteStatsResult.RowsAffected()
 	result["deleted_hourly_stats"] = deletedStats
 
-	// 8. 统计压缩后的数据量
+	// 6. 统计压缩后的数据量
 	var afterCount int
 	err = tx.QueryRow("SELECT COUNT(*) FROM request_logs").Scan(&afterCount)
 	if err != nil {
@@ -1169,10 +1357,9 @@ func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
 		return nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	// 9. 执行 VACUUM 压缩数据库文件
-	_, err = s.db.Exec("VACUUM")
-	if err != nil {
-		log.Warnf("VACUUM failed: %v", err)
+	// 7. 执行 VACUUM 压缩数据库文件
+	if vacuum {
+		s.Vacuum()
 	}
 
 	log.Infof("Database compressed: before=%d, after=%d, deleted_logs=%d, deleted_stats=%d, hourly_stats=%d",
@@ -1181,6 +1368,26 @@ func (s *RouteService) CompressDatabase() (map[string]interface{}, error) {
 	return result, nil
 }
 
+// Vacuum 压缩主数据库和对话追踪数据库文件并截断 WAL（服务端数据库由其自身维护存储空间）
+func (s *RouteService) Vacuum() {
+	dbs := []*database.DB{s.db}
+	if s.traceDB != nil && s.traceDB != s.db {
+		dbs = append(dbs, s.traceDB)
+	}
+	for _, db := range dbs {
+		if db.Dialect != database.DialectSQLite {
+			continue
+		}
+		if _, err := db.Exec("VACUUM"); err != nil {
+			log.Warnf("VACUUM failed: %v", err)
+			continue
+		}
+		if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			log.Warnf("WAL checkpoint failed: %v", err)
+		}
+	}
+}
+
 // GetUsageSummary 获取用量汇总
 func (s *RouteService) GetUsageSummary() (map[string]interface{}, error) {
 	result := make(map[string]interface{})
@@ -1281,6 +1488,10 @@ type RouteHealthInfo struct {
 	StatusHistory []bool `json:"status_history"` // Last N requests, true=success, index 0 is oldest
 	SuccessRate   float64 `json:"success_rate"`
 	TotalRequests int    `json:"total_requests"`
+	ProbeHistory  []bool `json:"probe_history"`    // 最近的主动探测结果，index 0 is oldest
+	ProbeLatencyMs int64 `json:"probe_latency_ms"` // 最近一次探测耗时
+	LastProbeAt   string `json:"last_probe_at"`
+	LastProbeError string `json:"last_probe_error"`
 }
 
 // GroupHealthInfo represents health information for a group of routes
@@ -1291,6 +1502,57 @@ type GroupHealthInfo struct {
 	RouteCount  int               `json:"route_count"`
 }
 
+// GetRecentLatencies 获取路由最近成功的非流式请求耗时（毫秒，按时间倒序）
+func (s *RouteService) GetRecentLatencies(routeID int64, limit int) ([]int64, error) {
+	rows, err := s.db.Query(`
+		SELECT proxy_time_ms FROM request_logs
+		WHERE route_id = ? AND success = 1 AND COALESCE(is_stream, 0) = 0 AND proxy_time_ms > 0
+		ORDER BY id DESC LIMIT ?`, routeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var latencies []int64
+	for rows.Next() {
+		var ms int64
+		if err := rows.Scan(&ms); err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, ms)
+	}
+	return latencies, rows.Err()
+}
+// GetCachedModels 获取缓存的上游模型列表，found 为 false 表示无缓存
+func (s *RouteService) GetCachedModels(cacheKey string) (models []string, fetchedAt time.Time, found bool, err error) {
+	var raw string
+	err = s.db.QueryRow(`SELECT models, fetched_at FROM remote_models_cache WHERE cache_key = ?`, cacheKey).Scan(&raw, &fetchedAt)
+	if err == sql.ErrNoRows {
+		return nil, time.Time{}, false, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	if err = json.Unmarshal([]byte(raw), &models); err != nil {
+		return nil, time.Time{}, false, err
+	}
+	return models, fetchedAt, true, nil
+}
+
+// SaveCachedModels 保存上游模型列表缓存
+func (s *RouteService) SaveCachedModels(cacheKey, apiUrl string, models []string) error {
+	data, err := json.Marshal(models)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO remote_models_cache (cache_key, api_url, models, fetched_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(cache_key) DO UPDATE SET api_url = excluded.api_url, models = excluded.models, fetched_at = excluded.fetched_at`,
+		cacheKey, apiUrl, string(data), time.Now())
+	return err
+}
+
+
 // GetHealthStatus returns health status for all routes, grouped by their group field
 // historyCount specifies how many recent requests to include in status_history (e.g., 50)
 func (s *RouteService) GetHealthStatus(historyCount int) ([]GroupHealthInfo, error) {
@@ -1361,6 +1623,19 @@ func (s *RouteService) GetHealthStatus(historyCount int) ([]GroupHealthInfo, err
 			TotalRequests: totalReqs,
 		}
 
+		// 主动探测结果
+		if checks, err := s.GetRecentHealthChecks(r.ID, historyCount); err != nil {
+			log.Warnf("GetHealthStatus: failed to get health checks for route %d: %v", r.ID, err)
+		} else if len(checks) > 0 {
+			for _, c := range checks {
+				routeHealth.ProbeHistory = append(routeHealth.ProbeHistory, c.Success)
+			}
+			last := checks[len(checks)-1]
+			routeHealth.ProbeLatencyMs = last.LatencyMs
+			routeHealth.LastProbeAt = last.CheckedAt.Local().Format("2006-01-02 15:04:05")
+			routeHealth.LastProbeError = last.ErrorMessage
+		}
+
 		groupName := r.Group
 		if groupName == "" {
 			groupName = "default"
@@ -1524,8 +1799,9 @@ func (s *RouteService) SaveTrace(trace *database.ConversationTrace) error {
 	query := `INSERT INTO conversation_traces 
 		(session_id, remote_ip, model, provider_model, provider_name, 
 		 request_content, response_content, request_tokens, response_tokens, total_tokens,
-		 success, error_message, style, is_stream, proxy_time_ms, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		 success, error_message, style, is_stream, proxy_time_ms, request_id, moderation,
+		 fingerprint, parent_id, client_key, created_at, upstream_request, raw_response)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	createdAt := trace.CreatedAt
 	if createdAt.IsZero() {
@@ -1538,7 +1814,8 @@ func (s *RouteService) SaveTrace(trace *database.ConversationTrace) error {
 	_, err := traceDB.Exec(query,
 		trace.SessionID, trace.RemoteIP, trace.Model, trace.ProviderModel, trace.ProviderName,
 		trace.RequestContent, trace.ResponseContent, trace.RequestTokens, trace.ResponseTokens, trace.TotalTokens,
-		trace.Success, trace.ErrorMessage, trace.Style, trace.IsStream, trace.ProxyTimeMs, createdAtStr)
+		trace.Success, trace.ErrorMessage, trace.Style, trace.IsStream, trace.ProxyTimeMs, trace.RequestID, trace.Moderation,
+		trace.Fingerprint, trace.ParentID, trace.ClientKey, createdAtStr, trace.UpstreamRequest, trace.RawResponse)
 
 	if err != nil {
 		log.Errorf("SaveTrace error: %v", err)
@@ -1623,7 +1900,8 @@ func (s *RouteService) GetTracesBySession(sessionId string) ([]database.Conversa
 	query := `
 		SELECT id, session_id, remote_ip, model, provider_model, provider_name,
 		       request_content, response_content, request_tokens, response_tokens, total_tokens,
-		       success, error_message, style, is_stream, proxy_time_ms, created_at
+		       success, error_message, style, is_stream, proxy_time_ms, COALESCE(request_id, ''), COALESCE(moderation, ''),
+		       COALESCE(fingerprint, ''), COALESCE(parent_id, 0), COALESCE(client_key, ''), created_at
 		FROM conversation_traces
 		WHERE session_id = ?
 		ORDER BY created_at DESC
@@ -1643,7 +1921,8 @@ func (s *RouteService) GetTracesBySession(sessionId string) ([]database.Conversa
 		err := rows.Scan(&trace.ID, &trace.SessionID, &trace.RemoteIP, &trace.Model,
 			&trace.ProviderModel, &trace.ProviderName, &trace.RequestContent, &trace.ResponseContent,
 			&trace.RequestTokens, &trace.ResponseTokens, &trace.TotalTokens,
-			&trace.Success, &trace.ErrorMessage, &trace.Style, &trace.IsStream, &trace.ProxyTimeMs, &createdAtRaw)
+			&trace.Success, &trace.ErrorMessage, &trace.Style, &trace.IsStream, &trace.ProxyTimeMs, &trace.RequestID, &trace.Moderation,
+			&trace.Fingerprint, &trace.ParentID, &trace.ClientKey, &createdAtRaw)
 		if err != nil {
 			log.Warnf("GetTracesBySession scan error: %v", err)
 			continue
@@ -1661,6 +1940,33 @@ func (s *RouteService) GetTracesBySession(sessionId string) ([]database.Conversa
 	return traces, nil
 }
 
+// GetTraceByID 获取单条对话记录
+func (s *RouteService) GetTraceByID(id int64) (*database.ConversationTrace, error) {
+	query := `
+		SELECT id, session_id, remote_ip, model, provider_model, provider_name,
+		       request_content, response_content, request_tokens, response_tokens, total_tokens,
+		       success, error_message, style, is_stream, proxy_time_ms, COALESCE(request_id, ''), COALESCE(moderation, ''),
+		       COALESCE(fingerprint, ''), COALESCE(parent_id, 0), COALESCE(client_key, ''), created_at
+		FROM conversation_traces
+		WHERE id = ?
+	`
+
+	var trace database.ConversationTrace
+	var createdAtRaw string
+	err := s.getTraceDB().QueryRow(query, id).Scan(&trace.ID, &trace.SessionID, &trace.RemoteIP, &trace.Model,
+		&trace.ProviderModel, &trace.ProviderName, &trace.RequestContent, &trace.ResponseContent,
+		&trace.RequestTokens, &trace.ResponseTokens, &trace.TotalTokens,
+		&trace.Success, &trace.ErrorMessage, &trace.Style, &trace.IsStream, &trace.ProxyTimeMs, &trace.RequestID, &trace.Moderation,
+		&trace.Fingerprint, &trace.ParentID, &trace.ClientKey, &createdAtRaw)
+	if err != nil {
+		return nil, err
+	}
+	if t, err := parseTraceTime(createdAtRaw); err == nil {
+		trace.CreatedAt = t
+	}
+	return &trace, nil
+}
+
 // ClearTraces 清理过期对话记录
 func (s *RouteService) ClearTraces(beforeDays int) (int64, error) {
 	query := `DELETE FROM conversation_traces WHERE created_at < datetime('now', 'localtime', ? || ' days')`
@@ -1722,6 +2028,19 @@ func (s *RouteService) GetAllTraces(page, pageSize int, filters map[string]strin
 		conditions = append(conditions, "created_at <= ?")
 		args = append(args, endTime)
 	}
+	if requestID, ok := filters["request_id"]; ok && requestID != "" {
+		conditions = append(conditions, "request_id = ?")
+		args = append(args, requestID)
+	}
+	if sessionID, ok := filters["session_id"]; ok && sessionID != "" {
+		conditions = append(conditions, "session_id = ?")
+		args = append(args, sessionID)
+	}
+	// 全文搜索请求/响应内容和错误信息
+	if condition, searchArgs := traceSearchCondition(filters["search"]); condition != "" {
+		conditions = append(conditions, condition)
+		args = append(args, searchArgs...)
+	}
 
 	whereClause := ""
 	if len(conditions) > 0 {
@@ -1739,7 +2058,8 @@ func (s *RouteService) GetAllTraces(page, pageSize int, filters map[string]strin
 	query := fmt.Sprintf(`
 		SELECT id, session_id, remote_ip, model, provider_model, provider_name,
 		       request_content, response_content, request_tokens, response_tokens, total_tokens,
-		       success, error_message, style, is_stream, proxy_time_ms, created_at
+		       success, error_message, style, is_stream, proxy_time_ms, COALESCE(request_id, ''), COALESCE(moderation, ''),
+		       COALESCE(fingerprint, ''), COALESCE(parent_id, 0), COALESCE(client_key, ''), created_at
 		FROM conversation_traces %s
 		ORDER BY created_at DESC
 		LIMIT ? OFFSET ?
@@ -1759,7 +2079,8 @@ func (s *RouteService) GetAllTraces(page, pageSize int, filters map[string]strin
 		err := rows.Scan(&trace.ID, &trace.SessionID, &trace.RemoteIP, &trace.Model,
 			&trace.ProviderModel, &trace.ProviderName, &trace.RequestContent, &trace.ResponseContent,
 			&trace.RequestTokens, &trace.ResponseTokens, &trace.TotalTokens,
-			&trace.Success, &trace.ErrorMessage, &trace.Style, &trace.IsStream, &trace.ProxyTimeMs, &createdAtRaw)
+			&trace.Success, &trace.ErrorMessage, &trace.Style, &trace.IsStream, &trace.ProxyTimeMs, &trace.RequestID, &trace.Moderation,
+			&trace.Fingerprint, &trace.ParentID, &trace.ClientKey, &createdAtRaw)
 		if err != nil {
 			log.Warnf("GetAllTraces scan error: %v", err)
 			continue
@@ -1777,7 +2098,8 @@ func (s *RouteService) GetAllTraces(page, pageSize int, filters map[string]strin
 
 // MigrateLegacyTraces 迁移旧 routes.db 中的对话追踪到 traces.db（复制，不删除）
 func (s *RouteService) MigrateLegacyTraces() (int64, error) {
-	if s.traceDB == nil || s.traceDB == s.db {
+	// 旧版 traces 只可能存在于 SQLite 主数据库中
+	if s.traceDB == nil || s.traceDB == s.db || s.db.Dialect != database.DialectSQLite {
 		return 0, nil
 	}
 
@@ -1873,7 +2195,8 @@ func (s *RouteService) MigrateLegacyTraces() (int64, error) {
 
 // DeleteLegacyTraces removes legacy conversation_traces from routes.db after safety checks.
 func (s *RouteService) DeleteLegacyTraces() (int64, error) {
-	if s.traceDB == nil || s.traceDB == s.db {
+	// 旧版 traces 只可能存在于 SQLite 主数据库中
+	if s.traceDB == nil || s.traceDB == s.db || s.db.Dialect != database.DialectSQLite {
 		return 0, nil
 	}
 
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+)
+
+// 按请求特征路由：长上下文请求发往长上下文路由、带图片的请求发往支持视觉的路由、工具多的请求发往函数调用可靠的供应商
+// 规则只在没有指定路由/供应商、没有命中重定向时生效；目标没有可用路由时按原模型正常选择
+
+// ValidateRoutingRules 校验规则配置（保存前调用）
+func ValidateRoutingRules(rules []config.RoutingRule) error {
+	for _, rule := range rules {
+		patterns := compatPatterns(rule.Model)
+		if len(patterns) == 0 {
+			return fmt.Errorf("rule %q: model pattern is required", rule.Name)
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %q: invalid model pattern %q", rule.Name, pattern)
+			}
+		}
+		if rule.MinPromptTokens < 0 || rule.MinTools < 0 {
+			return fmt.Errorf("rule %q: thresholds must not be negative", rule.Name)
+		}
+		if rule.MinPromptTokens == 0 && !rule.HasImages && rule.MinTools == 0 {
+			return fmt.Errorf("rule %q: at least one condition is required (prompt tokens, images or tools)", rule.Name)
+		}
+		if strings.TrimSpace(rule.TargetModel) == "" && strings.TrimSpace(rule.TargetGroup) == "" {
+			return fmt.Errorf("rule %q: target model or target group is required", rule.Name)
+		}
+	}
+	return nil
+}
+
+// requestTraits 规则匹配使用的请求特征，输入 token 只在需要时估算
+type requestTraits struct {
+	model        string
+	reqData      map[string]interface{}
+	promptTokens int
+	estimated    bool
+}
+
+// tokens 估算的输入 token 数
+func (t *requestTraits) tokens() int {
+	if !t.estimated {
+		t.estimated = true
+		if body, err := json.Marshal(t.reqData); err == nil {
+			t.promptTokens = estimatePromptTokens(t.model, body)
+		}
+	}
+	return t.promptTokens
+}
+
+// matches 请求是否满足规则设置的所有条件
+func (t *requestTraits) matches(rule *config.RoutingRule) bool {
+	if rule.HasImages && !containsImage(t.reqData) {
+		return false
+	}
+	if rule.MinTools > 0 && countTools(t.reqData) < rule.MinTools {
+		return false
+	}
+	if rule.MinPromptTokens > 0 && t.tokens() < rule.MinPromptTokens {
+		return false
+	}
+	return true
+}
+
+// matchRoutingRule 返回第一条匹配请求的启用规则，请求体为空（音频、Realtime 等）时不匹配
+func (s *ProxyService) matchRoutingRule(model string, reqData map[string]interface{}) *config.RoutingRule {
+	if !s.config.RoutingRulesEnabled || len(reqData) == 0 {
+		return nil
+	}
+	traits := &requestTraits{model: model, reqData: reqData}
+	rules := s.config.RoutingRules
+	for i := range rules {
+		if rules[i].Enabled && matchModelPattern(rules[i].Model, model) && traits.matches(&rules[i]) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// ruleRoutes 按规则选择路由：改用目标模型，指定分组时该分组的路由排在前面
+func (s *ProxyService) ruleRoutes(rule *config.RoutingRule, model string) ([]database.ModelRoute, string, error) {
+	target := strings.TrimSpace(rule.TargetModel)
+	if target == "" {
+		target = model
+	}
+	routes, err := s.routeService.GetAllRoutesByModel(target)
+	if err != nil || len(routes) == 0 {
+		return nil, target, fmt.Errorf("model '%s' not found in route list", target)
+	}
+	if group := strings.TrimSpace(rule.TargetGroup); group != "" {
+		var preferred, others []database.ModelRoute
+		for _, route := range routes {
+			if strings.EqualFold(strings.TrimSpace(route.Group), group) {
+				preferred = append(preferred, route)
+			} else {
+				others = append(others, route)
+			}
+		}
+		if len(preferred) == 0 {
+			return nil, target, fmt.Errorf("no route for model '%s' in group '%s'", target, group)
+		}
+		routes = append(preferred, others...)
+	}
+	if !s.config.FallbackEnabled {
+		routes = routes[:1]
+	}
+	return routes, target, nil
+}
+
+// containsImage 请求中是否包含图片（OpenAI image_url/input_image、Claude image 块、Gemini inline_data/file_data）
+func containsImage(v interface{}) bool {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		switch val["type"] {
+		case "image_url", "input_image", "image":
+			return true
+		}
+		for _, key := range []string{"inline_data", "inlineData", "file_data", "fileData"} {
+			if data, ok := val[key].(map[string]interface{}); ok {
+				mime, _ := data["mime_type"].(string)
+				if mime == "" {
+					mime, _ = data["mimeType"].(string)
+				}
+				if strings.HasPrefix(mime, "image/") {
+					return true
+				}
+			}
+		}
+		for key, child := range val {
+			// 工具定义中的 image 字样不代表请求带图片
+			if key == "tools" || key == "functions" {
+				continue
+			}
+			if containsImage(child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range val {
+			if containsImage(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countTools 请求中的工具（函数）定义数，Gemini 按 functionDeclarations 计数
+func countTools(reqData map[string]interface{}) int {
+	count := 0
+	if functions, ok := reqData["functions"].([]interface{}); ok {
+		count += len(functions)
+	}
+	tools, _ := reqData["tools"].([]interface{})
+	for _, tool := range tools {
+		toolMap, _ := tool.(map[string]interface{})
+		declarations, ok := toolMap["functionDeclarations"].([]interface{})
+		if !ok {
+			declarations, ok = toolMap["function_declarations"].([]interface{})
+		}
+		if ok {
+			count += len(declarations)
+		} else {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	driftQueueSize     = 256  // 待校验样本队列长度，满时直接丢弃样本
+	driftBaselineSize  = 20   // 建立基线所需的样本数，基线建立前每个响应都采样
+	driftRequiredRatio = 0.95 // 字段在基线中出现的比例达到该值时视为必有字段
+	driftMaxDepth      = 6    // 字段路径最大深度
+	driftMaxWarnings   = 200  // 内存中最多保留的告警条数
+
+	driftDefaultSampleRate = 0.2 // 基线建立后的默认采样率
+)
+
+// 各格式已知的结束原因，出现其他值时告警
+var knownFinishReasons = map[string]map[string]bool{
+	"openai": {"stop": true, "length": true, "tool_calls": true, "content_filter": true, "function_call": true},
+	"claude": {"end_turn": true, "max_tokens": true, "stop_sequence": true, "tool_use": true, "pause_turn": true, "refusal": true},
+	"gemini": {"STOP": true, "MAX_TOKENS": true, "SAFETY": true, "RECITATION": true, "OTHER": true, "BLOCKLIST": true,
+		"PROHIBITED_CONTENT": true, "SPII": true, "MALFORMED_FUNCTION_CALL": true, "LANGUAGE": true,
+		"FINISH_REASON_UNSPECIFIED": true, "IMAGE_SAFETY": true},
+	"ollama": {"stop": true, "length": true, "load": true, "unload": true},
+	"cohere": {"COMPLETE": true, "STOP_SEQUENCE": true, "MAX_TOKENS": true, "TOOL_CALL": true, "ERROR": true, "TIMEOUT": true},
+}
+
+// 内容块数组只记录数组本身，不展开元素（文本/工具调用等内容块结构因请求而异）
+var driftOpaqueArrays = map[string]bool{"content": true, "parts": true, "tool_calls": true, "annotations": true, "logprobs": true}
+
+// SchemaDriftWarning 上游响应结构变化告警
+type SchemaDriftWarning struct {
+	Provider  string    `json:"provider"`
+	Format    string    `json:"format"`
+	Kind      string    `json:"kind"` // missing_usage, new_finish_reason, missing_field, new_field
+	Detail    string    `json:"detail"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Example   string    `json:"example,omitempty"` // 触发告警的请求 ID
+}
+
+type driftSample struct {
+	provider  string
+	format    string
+	requestID string
+	body      []byte
+}
+
+// providerSchema 单个供应商已观察到的响应结构
+type providerSchema struct {
+	samples   int
+	usageSeen int
+	fieldSeen map[string]int
+}
+
+// schemaDriftDetector 后台采样校验上游响应结构，发现字段缺失、新的结束原因等变化时产生告警
+type schemaDriftDetector struct {
+	queue chan driftSample
+
+	mu        sync.Mutex
+	providers map[string]*providerSchema
+	warnings  map[string]*SchemaDriftWarning
+}
+
+func newSchemaDriftDetector() *schemaDriftDetector {
+	d := &schemaDriftDetector{
+		queue:     make(chan driftSample, driftQueueSize),
+		providers: make(map[string]*providerSchema),
+		warnings:  make(map[string]*SchemaDriftWarning),
+	}
+	go d.run()
+	return d
+}
+
+// observe 提交一个成功的非流式响应，按采样率入队，永不阻塞调用方
+func (d *schemaDriftDetector) observe(provider, routeFormat, requestID string, body []byte, sampleRate float64) {
+	if len(body) == 0 || provider == "" {
+		return
+	}
+	d.mu.Lock()
+	state := d.providers[provider]
+	warming := state == nil || state.samples < driftBaselineSize
+	d.mu.Unlock()
+	if !warming && rand.Float64() >= sampleRate {
+		return
+	}
+
+	sample := driftSample{provider: provider, format: normalizeFormat(routeFormat), requestID: requestID, body: body}
+	select {
+	case d.queue <- sample:
+	default:
+	}
+}
+
+func (d *schemaDriftDetector) run() {
+	for sample := range d.queue {
+		d.check(sample)
+	}
+}
+
+// check 将样本与供应商基线比较，然后并入基线
+func (d *schemaDriftDetector) check(sample driftSample) {
+	var resp map[string]interface{}
+	if err := json.Unmarshal(sample.body, &resp); err != nil {
+		return
+	}
+	format := sniffResponseFormat(resp, sample.format)
+
+	fields := make(map[string]bool)
+	collectFieldPaths(resp, "", 0, fields)
+	_, hasUsage := resp[usageField(format)]
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state := d.providers[sample.provider]
+	if state == nil {
+		state = &providerSchema{fieldSeen: make(map[string]int)}
+		d.providers[sample.provider] = state
+	}
+
+	for _, reason := range finishReasons(resp, format) {
+		if known := knownFinishReasons[format]; known != nil && !known[reason] {
+			d.warnLocked(sample, format, "new_finish_reason", reason)
+		}
+	}
+
+	if state.samples >= driftBaselineSize {
+		if !hasUsage && state.usageSeen > 0 {
+			d.warnLocked(sample, format, "missing_usage", usageField(format))
+		}
+		for path, seen := range state.fieldSeen {
+			// usage 缺失已单独告警
+			if isNullPath(path) || fields[path] || fields[path+"?"] || path == usageField(format) {
+				continue
+			}
+			// 只报告最上层缺失的字段，父字段缺失或为空时不再报告子字段
+			if parent := parentPath(path); parent != "" && !fields[parent] {
+				continue
+			}
+			if float64(seen)/float64(state.samples) >= driftRequiredRatio {
+				d.warnLocked(sample, format, "missing_field", path)
+			}
+		}
+		for path := range fields {
+			if isNullPath(path) || state.fieldSeen[path] > 0 {
+				continue
+			}
+			// 新增对象只报告对象本身，不再逐个报告其子字段
+			if parent := parentPath(path); parent != "" && state.fieldSeen[parent] == 0 {
+				continue
+			}
+			d.warnLocked(sample, format, "new_field", path)
+		}
+	}
+
+	state.samples++
+	if hasUsage {
+		state.usageSeen++
+	}
+	for path := range fields {
+		state.fieldSeen[path]++
+	}
+}
+
+func (d *schemaDriftDetector) warnLocked(sample driftSample, format, kind, detail string) {
+	key := sample.provider + "|" + format + "|" + kind + "|" + detail
+	now := time.Now()
+	if w, ok := d.warnings[key]; ok {
+		w.Count++
+		w.LastSeen = now
+		w.Example = sample.requestID
+		return
+	}
+	if len(d.warnings) >= driftMaxWarnings {
+		return
+	}
+	d.warnings[key] = &SchemaDriftWarning{
+		Provider:  sample.provider,
+		Format:    format,
+		Kind:      kind,
+		Detail:    detail,
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+		Example:   sample.requestID,
+	}
+	log.Warnf("[%s] Schema drift from %s (%s): %s %s", sample.requestID, sample.provider, format, kind, detail)
+}
+
+// list 按最近出现时间倒序返回告警
+func (d *schemaDriftDetector) list() []SchemaDriftWarning {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	warnings := make([]SchemaDriftWarning, 0, len(d.warnings))
+	for _, w := range d.warnings {
+		warnings = append(warnings, *w)
+	}
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].LastSeen.After(warnings[j].LastSeen) })
+	return warnings
+}
+
+// clear 清除告警；供应商已确认变更时同时重建基线
+func (d *schemaDriftDetector) clear(resetBaseline bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.warnings = make(map[string]*SchemaDriftWarning)
+	if resetBaseline {
+		d.providers = make(map[string]*providerSchema)
+	}
+}
+
+// sniffResponseFormat 根据响应内容判断上游格式，无法判断时使用路由配置的格式
+func sniffResponseFormat(resp map[string]interface{}, fallback string) string {
+	if _, ok := resp["candidates"]; ok {
+		return "gemini"
+	}
+	if t, _ := resp["type"].(string); t == "message" {
+		return "claude"
+	}
+	if _, ok := resp["choices"]; ok {
+		return "openai"
+	}
+	if _, ok := resp["done_reason"]; ok {
+		return "ollama"
+	}
+	if _, ok := resp["finish_reason"].(string); ok {
+		return "cohere"
+	}
+	return fallback
+}
+
+func usageField(format string) string {
+	switch format {
+	case "gemini":
+		return "usageMetadata"
+	case "ollama":
+		return "eval_count"
+	}
+	return "usage"
+}
+
+// finishReasons 提取响应中的结束原因
+func finishReasons(resp map[string]interface{}, format string) []string {
+	var reasons []string
+	switch format {
+	case "claude":
+		if r, ok := resp["stop_reason"].(string); ok {
+			reasons = append(reasons, r)
+		}
+	case "gemini":
+		candidates, _ := resp["candidates"].([]interface{})
+		for _, c := range candidates {
+			if cm, ok := c.(map[string]interface{}); ok {
+				if r, ok := cm["finishReason"].(string); ok {
+					reasons = append(reasons, r)
+				}
+			}
+		}
+	case "ollama":
+		if r, ok := resp["done_reason"].(string); ok {
+			reasons = append(reasons, r)
+		}
+	case "cohere":
+		if r, ok := resp["finish_reason"].(string); ok {
+			reasons = append(reasons, r)
+		}
+	default:
+		choices, _ := resp["choices"].([]interface{})
+		for _, c := range choices {
+			if cm, ok := c.(map[string]interface{}); ok {
+				if r, ok := cm["finish_reason"].(string); ok {
+					reasons = append(reasons, r)
+				}
+			}
+		}
+	}
+	return reasons
+}
+
+// collectFieldPaths 收集 JSON 字段路径，数组元素记为 name[]
+// 值为 null 的字段记为 path?，便于区分“字段缺失”与“字段为空”
+func collectFieldPaths(v interface{}, prefix string, depth int, fields map[string]bool) {
+	if depth >= driftMaxDepth {
+		return
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for k, child := range obj {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if child == nil {
+			fields[path+"?"] = true
+			continue
+		}
+		fields[path] = true
+		if arr, ok := child.([]interface{}); ok {
+			if driftOpaqueArrays[k] {
+				continue
+			}
+			for _, item := range arr {
+				collectFieldPaths(item, path+"[]", depth+1, fields)
+			}
+			continue
+		}
+		collectFieldPaths(child, path, depth+1, fields)
+	}
+}
+
+// parentPath 父字段路径，顶层字段返回空字符串（数组元素的父字段为数组本身）
+func parentPath(path string) string {
+	i := strings.LastIndex(path, ".")
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSuffix(path[:i], "[]")
+}
+
+func isNullPath(path string) bool {
+	return strings.HasSuffix(path, "?")
+}
+
+// GetSchemaDriftWarnings 获取上游响应结构变化告警
+func (s *ProxyService) GetSchemaDriftWarnings() []SchemaDriftWarning {
+	return s.drift.list()
+}
+
+// ClearSchemaDriftWarnings 清除告警，resetBaseline 为 true 时重新学习各供应商的响应结构
+func (s *ProxyService) ClearSchemaDriftWarnings(resetBaseline bool) {
+	s.drift.clear(resetBaseline)
+	if resetBaseline {
+		log.Info("Schema drift baselines reset")
+	}
+}
+
+// checkSchemaDrift 采样校验上游响应结构
+func (s *ProxyService) checkSchemaDrift(providerName, routeFormat, requestID string, body []byte) {
+	if s.config == nil || !s.config.SchemaDriftEnabled {
+		return
+	}
+	rate := s.config.SchemaDriftSampleRate
+	if rate <= 0 {
+		rate = driftDefaultSampleRate
+	}
+	s.drift.observe(providerName, routeFormat, requestID, body, rate)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxSnippetModels 片段中列出的模型数量上限（Cursor 自定义模型、Continue 模型列表）
+const maxSnippetModels = 10
+
+// ClientSnippet 客户端配置片段，按当前端口、Key 和已配置的模型生成，可直接粘贴使用
+type ClientSnippet struct {
+	Client      string `json:"client"`
+	Name        string `json:"name"`
+	Language    string `json:"language"` // json, bash, python, yaml
+	Description string `json:"description"`
+	Code        string `json:"code"`
+}
+
+// GetClientSnippets 生成各客户端的配置片段
+// model 为空时使用第一个可用模型，apiKey 为空时使用当前本地 API Key
+func (cs *ConversationService) GetClientSnippets(model, apiKey string) []ClientSnippet {
+	baseURL := cs.config.BaseURL()
+	if apiKey == "" {
+		apiKey = cs.config.AuthKey()
+	}
+	models := cs.snippetModels()
+	if model == "" {
+		model = models[0]
+	}
+
+	return []ClientSnippet{
+		cursorSnippet(baseURL, apiKey, models),
+		claudeCodeEnvSnippet(baseURL, apiKey, model),
+		claudeCodeSettingsSnippet(baseURL, apiKey, model),
+		openAIPythonSnippet(baseURL, apiKey, model),
+		langChainSnippet(baseURL, apiKey, model),
+		continueSnippet(baseURL, apiKey, models),
+	}
+}
+
+// snippetModels 已启用路由的模型（去重），开启重定向时关键字排在最前；没有路由时返回示例模型
+func (cs *ConversationService) snippetModels() []string {
+	var models []string
+	seen := make(map[string]bool)
+	add := func(m string) {
+		if m != "" && !seen[m] && len(models) < maxSnippetModels {
+			seen[m] = true
+			models = append(models, m)
+		}
+	}
+
+	if cs.config.RedirectEnabled {
+		add(cs.config.RedirectKeyword)
+	}
+	if routes, err := cs.routeService.GetAllRoutes(); err == nil {
+		for _, route := range routes {
+			if route.Enabled {
+				add(route.Model)
+			}
+		}
+	}
+	if len(models) == 0 {
+		models = []string{"gpt-4o"}
+	}
+	return models
+}
+
+func cursorSnippet(baseURL, apiKey string, models []string) ClientSnippet {
+	settings, _ := json.MarshalIndent(struct {
+		BaseURL string   `json:"openAIBaseUrl"`
+		APIKey  string   `json:"openAIApiKey"`
+		Models  []string `json:"customModels"`
+	}{baseURL + "/api/cursor/v1", apiKey, models}, "", "  ")
+	return ClientSnippet{
+		Client:      "cursor",
+		Name:        "Cursor",
+		Language:    "json",
+		Description: "Settings → Models: enable \"Override OpenAI Base URL\", paste the base URL and API key, then add the custom models",
+		Code:        string(settings),
+	}
+}
+
+func claudeCodeEnvSnippet(baseURL, apiKey, model string) ClientSnippet {
+	code := fmt.Sprintf(`# macOS / Linux
+export ANTHROPIC_BASE_URL=%s
+export ANTHROPIC_AUTH_TOKEN=%s
+export ANTHROPIC_MODEL=%s
+export ANTHROPIC_SMALL_FAST_MODEL=%s
+
+# Windows PowerShell
+$env:ANTHROPIC_BASE_URL = %s
+$env:ANTHROPIC_AUTH_TOKEN = %s
+$env:ANTHROPIC_MODEL = %s
+$env:ANTHROPIC_SMALL_FAST_MODEL = %s`,
+		shellQuote(baseURL+"/api/claudecode"), shellQuote(apiKey), shellQuote(model), shellQuote(model),
+		psQuote(baseURL+"/api/claudecode"), psQuote(apiKey), psQuote(model), psQuote(model))
+	return ClientSnippet{
+		Client:      "claude_code",
+		Name:        "Claude Code (env)",
+		Language:    "bash",
+		Description: "Set these environment variables before running claude",
+		Code:        code,
+	}
+}
+
+func claudeCodeSettingsSnippet(baseURL, apiKey, model string) ClientSnippet {
+	settings, _ := json.MarshalIndent(map[string]interface{}{
+		"env": map[string]string{
+			"ANTHROPIC_BASE_URL":         baseURL + "/api/claudecode",
+			"ANTHROPIC_AUTH_TOKEN":       apiKey,
+			"ANTHROPIC_MODEL":            model,
+			"ANTHROPIC_SMALL_FAST_MODEL": model,
+		},
+	}, "", "  ")
+	return ClientSnippet{
+		Client:      "claude_code_settings",
+		Name:        "Claude Code (settings.json)",
+		Language:    "json",
+		Description: "Merge into ~/.claude/settings.json to apply to every session",
+		Code:        string(settings),
+	}
+}
+
+func openAIPythonSnippet(baseURL, apiKey, model string) ClientSnippet {
+	code := fmt.Sprintf(`from openai import OpenAI
+
+client = OpenAI(
+    api_key=%s,
+    base_url=%s,
+)
+
+response = client.chat.completions.create(
+    model=%s,
+    messages=[{"role": "user", "content": "Hello!"}],
+)
+print(response.choices[0].message.content)`,
+		strconv.Quote(apiKey), strconv.Quote(baseURL+"/api/v1"), strconv.Quote(model))
+	return ClientSnippet{
+		Client:      "openai_python",
+		Name:        "openai-python",
+		Language:    "python",
+		Description: "pip install openai",
+		Code:        code,
+	}
+}
+
+func langChainSnippet(baseURL, apiKey, model string) ClientSnippet {
+	code := fmt.Sprintf(`from langchain_openai import ChatOpenAI
+
+llm = ChatOpenAI(
+    model=%s,
+    api_key=%s,
+    base_url=%s,
+)
+print(llm.invoke("Hello!").content)`,
+		strconv.Quote(model), strconv.Quote(apiKey), strconv.Quote(baseURL+"/api/v1"))
+	return ClientSnippet{
+		Client:      "langchain",
+		Name:        "LangChain",
+		Language:    "python",
+		Description: "pip install langchain-openai",
+		Code:        code,
+	}
+}
+
+func continueSnippet(baseURL, apiKey string, models []string) ClientSnippet {
+	var b strings.Builder
+	b.WriteString("name: AnyProxyAi\nversion: 1.0.0\nschema: v1\nmodels:\n")
+	for _, m := range models {
+		fmt.Fprintf(&b, "  - name: %s\n", strconv.Quote(m+" (AnyProxyAi)"))
+		b.WriteString("    provider: openai\n")
+		fmt.Fprintf(&b, "    model: %s\n", strconv.Quote(m))
+		fmt.Fprintf(&b, "    apiBase: %s\n", strconv.Quote(baseURL+"/api/v1"))
+		fmt.Fprintf(&b, "    apiKey: %s\n", strconv.Quote(apiKey))
+	}
+	return ClientSnippet{
+		Client:      "continue",
+		Name:        "Continue.dev",
+		Language:    "yaml",
+		Description: "Add to ~/.continue/config.yaml",
+		Code:        strings.TrimSuffix(b.String(), "\n"),
+	}
+}
+
+// shellQuote 单引号包裹，用于 POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// psQuote 单引号包裹，用于 PowerShell（单引号字符串内不展开变量）
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
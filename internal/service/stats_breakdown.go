@@ -0,0 +1,239 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+)
+
+// 按维度（模型、路由、分组、供应商名、请求类型、虚拟 Key）汇总用量和费用，用于看板按供应商/客户端展示用量
+// 只有模型维度包含已压缩到 hourly_stats 的历史数据，其他维度只统计未压缩的请求日志
+
+// 统计维度
+const (
+	BreakdownModel      = "model"
+	BreakdownRoute      = "route"
+	BreakdownGroup      = "group"
+	BreakdownProvider   = "provider"
+	BreakdownStyle      = "style"
+	BreakdownVirtualKey = "virtual_key"
+)
+
+// StatsBreakdownItem 一个维度值的用量
+type StatsBreakdownItem struct {
+	Key              string  `json:"key"`   // 维度值，路由维度为路由ID，为空表示未知/未设置
+	Label            string  `json:"label"` // 显示名称，路由维度为路由名
+	Requests         int64   `json:"requests"`
+	Success          int64   `json:"success"`
+	Failed           int64   `json:"failed"`
+	SuccessRate      float64 `json:"success_rate"` // 百分比
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`   // 实际费用加上按 model_prices 估算的费用
+	Priced           bool    `json:"priced"` // 至少一个模型配置了价格或有实际费用
+}
+
+// breakdownRow 请求日志按模型和各维度分组后的一行
+type breakdownRow struct {
+	model, provider, style, virtualKey string
+	routeID                            int64
+	requests, success                  int64
+	promptTokens, completionTokens     int64
+	totalTokens                        int64
+	reportedCost                       float64
+	reportedPrompt, reportedCompletion int64
+}
+
+// ValidBreakdownDimension 是否为支持的统计维度
+func ValidBreakdownDimension(dimension string) bool {
+	switch dimension {
+	case BreakdownModel, BreakdownRoute, BreakdownGroup, BreakdownProvider, BreakdownStyle, BreakdownVirtualKey:
+		return true
+	}
+	return false
+}
+
+// BindVirtualKey 记录请求使用的虚拟 Key 名称，写入请求日志时使用；请求结束后调用返回的函数清除
+func (s *RouteService) BindVirtualKey(requestID, name string) func() {
+	if requestID == "" || name == "" {
+		return func() {}
+	}
+	s.virtualKeys.Store(requestID, name)
+	return func() { s.virtualKeys.Delete(requestID) }
+}
+
+// requestVirtualKey 返回请求绑定的虚拟 Key 名称
+func (s *RouteService) requestVirtualKey(requestID string) string {
+	if v, ok := s.virtualKeys.Load(requestID); ok {
+		return v.(string)
+	}
+	return ""
+}
+
+// GetStatsBreakdown 按维度汇总最近 days 天（包括今天）的用量，days <= 0 表示全部，按 token 用量降序
+func (s *RouteService) GetStatsBreakdown(dimension string, days int, cfg *config.Config) ([]StatsBreakdownItem, error) {
+	if !ValidBreakdownDimension(dimension) {
+		return nil, fmt.Errorf("unsupported stats dimension: %s", dimension)
+	}
+	var since time.Time
+	if days > 0 {
+		now := time.Now()
+		since = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+	}
+	rows, err := s.breakdownRows(since, dimension == BreakdownModel)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make(map[int64]database.ModelRoute)
+	if dimension == BreakdownRoute || dimension == BreakdownGroup {
+		all, err := s.GetAllRoutes()
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range all {
+			routes[route.ID] = route
+		}
+	}
+
+	// 费用按模型估算：先按 (维度值, 模型) 汇总，再按维度值合并
+	type itemModel struct{ key, model string }
+	perModel := make(map[itemModel]*breakdownRow)
+	labels := make(map[string]string)
+	for i := range rows {
+		r := &rows[i]
+		key, label := breakdownKey(dimension, r, routes)
+		if labels[key] == "" {
+			labels[key] = label
+		}
+		acc := perModel[itemModel{key, r.model}]
+		if acc == nil {
+			acc = &breakdownRow{model: r.model}
+			perModel[itemModel{key, r.model}] = acc
+		}
+		acc.requests += r.requests
+		acc.success += r.success
+		acc.promptTokens += r.promptTokens
+		acc.completionTokens += r.completionTokens
+		acc.totalTokens += r.totalTokens
+		acc.reportedCost += r.reportedCost
+		acc.reportedPrompt += r.reportedPrompt
+		acc.reportedCompletion += r.reportedCompletion
+	}
+
+	items := make(map[string]*StatsBreakdownItem)
+	for km, acc := range perModel {
+		item := items[km.key]
+		if item == nil {
+			item = &StatsBreakdownItem{Key: km.key, Label: labels[km.key]}
+			items[km.key] = item
+		}
+		item.Requests += acc.requests
+		item.Success += acc.success
+		item.PromptTokens += acc.promptTokens
+		item.CompletionTokens += acc.completionTokens
+		item.TotalTokens += acc.totalTokens
+		cost := acc.reportedCost
+		priced := cost > 0
+		if price, ok := cfg.PriceFor(acc.model); ok {
+			priced = true
+			cost += (float64(acc.promptTokens-acc.reportedPrompt)*price.Input + float64(acc.completionTokens-acc.reportedCompletion)*price.Output) / 1e6
+		}
+		if priced {
+			item.Cost += cost
+			item.Priced = true
+		}
+	}
+
+	result := make([]StatsBreakdownItem, 0, len(items))
+	for _, item := range items {
+		item.Failed = item.Requests - item.Success
+		if item.Requests > 0 {
+			item.SuccessRate = float64(item.Success) * 100 / float64(item.Requests)
+		}
+		result = append(result, *item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalTokens != result[j].TotalTokens {
+			return result[i].TotalTokens > result[j].TotalTokens
+		}
+		if result[i].Requests != result[j].Requests {
+			return result[i].Requests > result[j].Requests
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
+
+// breakdownKey 一行日志在指定维度下的值和显示名称
+func breakdownKey(dimension string, r *breakdownRow, routes map[int64]database.ModelRoute) (string, string) {
+	switch dimension {
+	case BreakdownRoute:
+		if r.routeID <= 0 {
+			return "", ""
+		}
+		label := r.provider
+		if route, ok := routes[r.routeID]; ok {
+			label = route.Name
+		}
+		return strconv.FormatInt(r.routeID, 10), label
+	case BreakdownGroup:
+		group := routes[r.routeID].Group
+		return group, group
+	case BreakdownProvider:
+		return r.provider, r.provider
+	case BreakdownStyle:
+		return r.style, r.style
+	case BreakdownVirtualKey:
+		return r.virtualKey, r.virtualKey
+	}
+	return r.model, r.model
+}
+
+// breakdownRows 读取 since 之后（为零时读取全部）按模型和各维度分组的请求日志，withHistory 时加上 hourly_stats 中按模型聚合的历史数据
+func (s *RouteService) breakdownRows(since time.Time, withHistory bool) ([]breakdownRow, error) {
+	const layout = "2006-01-02 15:04:05"
+	query := `
+		SELECT model, COALESCE(route_id, 0), COALESCE(provider_name, ''), COALESCE(style, ''), COALESCE(virtual_key, ''),
+			COUNT(*), COALESCE(SUM(success), 0),
+			COALESCE(SUM(request_tokens), 0), COALESCE(SUM(response_tokens), 0), COALESCE(SUM(total_tokens), 0),
+			COALESCE(SUM(cost), 0),
+			COALESCE(SUM(CASE WHEN cost > 0 THEN request_tokens ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN cost > 0 THEN response_tokens ELSE 0 END), 0)
+		FROM request_logs
+		WHERE created_at >= ?
+		GROUP BY model, route_id, provider_name, style, virtual_key`
+	args := []interface{}{since.Format(layout)}
+	if withHistory {
+		query += `
+		UNION ALL
+		SELECT model, 0, '', '', '', request_count, success_count, request_tokens, response_tokens, total_tokens,
+			COALESCE(reported_cost, 0), COALESCE(reported_request_tokens, 0), COALESCE(reported_response_tokens, 0)
+		FROM hourly_stats
+		WHERE date >= ?`
+		args = append(args, since.Format("2006-01-02"))
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []breakdownRow
+	for rows.Next() {
+		var r breakdownRow
+		if err := rows.Scan(&r.model, &r.routeID, &r.provider, &r.style, &r.virtualKey,
+			&r.requests, &r.success, &r.promptTokens, &r.completionTokens, &r.totalTokens,
+			&r.reportedCost, &r.reportedPrompt, &r.reportedCompletion); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"openai-router-go/internal/sse"
+)
+
+// 流式响应的 Trace 记录：从上游事件中拼接助手回复文本和工具调用（兼容 OpenAI、Claude、Gemini、Ollama、Cohere 格式），
+// 写入 Trace 的调用方按请求ID登记，流结束后取出；内容长度受 trace_stream_max_kb 限制
+
+// capturedToolCall 拼接中的工具调用
+type capturedToolCall struct {
+	ID        string
+	Name      string
+	Arguments strings.Builder
+}
+
+// streamCapture 拼接一次流式响应的内容
+type streamCapture struct {
+	limit        int // 最大字节数
+	size         int
+	truncated    bool
+	content      strings.Builder
+	reasoning    strings.Builder
+	toolCalls    []*capturedToolCall
+	toolIndex    map[string]*capturedToolCall // 按上游的索引/ID 查找工具调用
+	finishReason string
+	usage        streamUsage // 流结束时的 token 用量
+}
+
+// newStreamCapture Traces 未启用或 trace_stream_max_kb 为 0 时返回 nil（不记录）
+func (s *ProxyService) newStreamCapture() *streamCapture {
+	if s.config == nil || !s.config.TracesEnabled || s.config.TraceStreamMaxKB <= 0 {
+		return nil
+	}
+	return &streamCapture{limit: s.config.TraceStreamMaxKB * 1024, toolIndex: make(map[string]*capturedToolCall)}
+}
+
+// write 追加内容，超出上限的部分丢弃
+func (c *streamCapture) write(b *strings.Builder, text string) {
+	if text == "" || c.truncated {
+		return
+	}
+	if c.size+len(text) > c.limit {
+		text = text[:c.limit-c.size]
+		// 不截断多字节字符
+		for len(text) > 0 && !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+		c.truncated = true
+	}
+	c.size += len(text)
+	b.WriteString(text)
+}
+
+// toolCall 按键查找或新建工具调用
+func (c *streamCapture) toolCall(key, id, name string) *capturedToolCall {
+	call, ok := c.toolIndex[key]
+	if !ok {
+		if len(c.toolCalls) >= 128 {
+			c.truncated = true
+			return &capturedToolCall{}
+		}
+		call = &capturedToolCall{}
+		c.toolIndex[key] = call
+		c.toolCalls = append(c.toolCalls, call)
+	}
+	if id != "" {
+		call.ID = id
+	}
+	if name != "" {
+		call.Name = name
+	}
+	return call
+}
+
+// observe 检查一个上游事件中的回复内容
+func (c *streamCapture) observe(ev *sse.Event) {
+	if c == nil {
+		return
+	}
+	chunk, err := ev.JSON()
+	if err != nil {
+		return
+	}
+
+	// OpenAI
+	if choices, ok := chunk["choices"].([]interface{}); ok && len(choices) > 0 {
+		choice, _ := choices[0].(map[string]interface{})
+		delta, _ := choice["delta"].(map[string]interface{})
+		if text, ok := delta["content"].(string); ok {
+			c.write(&c.content, text)
+		}
+		for _, key := range []string{"reasoning_content", "reasoning"} {
+			if text, ok := delta[key].(string); ok {
+				c.write(&c.reasoning, text)
+			}
+		}
+		toolCalls, _ := delta["tool_calls"].([]interface{})
+		for _, item := range toolCalls {
+			tc, _ := item.(map[string]interface{})
+			fn, _ := tc["function"].(map[string]interface{})
+			id, _ := tc["id"].(string)
+			name, _ := fn["name"].(string)
+			call := c.toolCall(fmt.Sprintf("openai-%v", tc["index"]), id, name)
+			if args, ok := fn["arguments"].(string); ok {
+				c.write(&call.Arguments, args)
+			}
+		}
+		if reason, ok := choice["finish_reason"].(string); ok && reason != "" {
+			c.finishReason = reason
+		}
+	}
+
+	switch chunk["type"] {
+	// Claude
+	case "content_block_start":
+		block, _ := chunk["content_block"].(map[string]interface{})
+		if block["type"] == "tool_use" {
+			id, _ := block["id"].(string)
+			name, _ := block["name"].(string)
+			c.toolCall(fmt.Sprintf("claude-%v", chunk["index"]), id, name)
+		}
+	case "content_block_delta":
+		delta, _ := chunk["delta"].(map[string]interface{})
+		switch delta["type"] {
+		case "text_delta":
+			text, _ := delta["text"].(string)
+			c.write(&c.content, text)
+		case "thinking_delta":
+			text, _ := delta["thinking"].(string)
+			c.write(&c.reasoning, text)
+		case "input_json_delta":
+			args, _ := delta["partial_json"].(string)
+			c.write(&c.toolCall(fmt.Sprintf("claude-%v", chunk["index"]), "", "").Arguments, args)
+		}
+	case "message_delta":
+		delta, _ := chunk["delta"].(map[string]interface{})
+		if reason, ok := delta["stop_reason"].(string); ok && reason != "" {
+			c.finishReason = reason
+		}
+	// Cohere
+	case "content-delta":
+		delta, _ := chunk["delta"].(map[string]interface{})
+		message, _ := delta["message"].(map[string]interface{})
+		content, _ := message["content"].(map[string]interface{})
+		text, _ := content["text"].(string)
+		c.write(&c.content, text)
+	case "tool-call-start", "tool-call-delta":
+		delta, _ := chunk["delta"].(map[string]interface{})
+		message, _ := delta["message"].(map[string]interface{})
+		tc, _ := message["tool_calls"].(map[string]interface{})
+		fn, _ := tc["function"].(map[string]interface{})
+		id, _ := tc["id"].(string)
+		name, _ := fn["name"].(string)
+		call := c.toolCall(fmt.Sprintf("cohere-%v", chunk["index"]), id, name)
+		if args, ok := fn["arguments"].(string); ok {
+			c.write(&call.Arguments, args)
+		}
+	case "message-end":
+		delta, _ := chunk["delta"].(map[string]interface{})
+		if reason, ok := delta["finish_reason"].(string); ok && reason != "" {
+			c.finishReason = reason
+		}
+	}
+
+	// Gemini
+	if candidates, ok := chunk["candidates"].([]interface{}); ok && len(candidates) > 0 {
+		candidate, _ := candidates[0].(map[string]interface{})
+		content, _ := candidate["content"].(map[string]interface{})
+		parts, _ := content["parts"].([]interface{})
+		for _, item := range parts {
+			part, _ := item.(map[string]interface{})
+			text, _ := part["text"].(string)
+			if thought, _ := part["thought"].(bool); thought {
+				c.write(&c.reasoning, text)
+			} else {
+				c.write(&c.content, text)
+			}
+			if fn, ok := part["functionCall"].(map[string]interface{}); ok {
+				name, _ := fn["name"].(string)
+				args, _ := json.Marshal(fn["args"])
+				call := c.toolCall(fmt.Sprintf("gemini-%d", len(c.toolCalls)), "", name)
+				c.write(&call.Arguments, string(args))
+			}
+		}
+		if reason, ok := candidate["finishReason"].(string); ok && reason != "" {
+			c.finishReason = reason
+		}
+	}
+
+	// Ollama
+	if message, ok := chunk["message"].(map[string]interface{}); ok && chunk["type"] == nil {
+		text, _ := message["content"].(string)
+		c.write(&c.content, text)
+		text, _ = message["thinking"].(string)
+		c.write(&c.reasoning, text)
+		toolCalls, _ := message["tool_calls"].([]interface{})
+		for _, item := range toolCalls {
+			tc, _ := item.(map[string]interface{})
+			fn, _ := tc["function"].(map[string]interface{})
+			name, _ := fn["name"].(string)
+			args, _ := json.Marshal(fn["arguments"])
+			call := c.toolCall(fmt.Sprintf("ollama-%d", len(c.toolCalls)), "", name)
+			c.write(&call.Arguments, string(args))
+		}
+		if reason, ok := chunk["done_reason"].(string); ok && reason != "" {
+			c.finishReason = reason
+		}
+	}
+}
+
+// result 拼接结果（OpenAI 助手消息格式的 JSON），没有任何内容时返回空字符串
+func (c *streamCapture) result() string {
+	if c == nil || (c.content.Len() == 0 && c.reasoning.Len() == 0 && len(c.toolCalls) == 0) {
+		return ""
+	}
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": c.content.String(),
+	}
+	if c.reasoning.Len() > 0 {
+		message["reasoning_content"] = c.reasoning.String()
+	}
+	if len(c.toolCalls) > 0 {
+		calls := make([]map[string]interface{}, 0, len(c.toolCalls))
+		for _, call := range c.toolCalls {
+			calls = append(calls, map[string]interface{}{
+				"id":   call.ID,
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      call.Name,
+					"arguments": call.Arguments.String(),
+				},
+			})
+		}
+		message["tool_calls"] = calls
+	}
+	result := map[string]interface{}{
+		"stream":  true,
+		"message": message,
+	}
+	if c.finishReason != "" {
+		result["finish_reason"] = c.finishReason
+	}
+	if c.truncated {
+		result["truncated"] = true
+	}
+	data, _ := json.Marshal(result)
+	return string(data)
+}
+
+// streamTraceStore 正在记录的流式响应，按请求ID查找；只有写入 Trace 的调用方登记后才会记录
+type streamTraceStore struct {
+	streams sync.Map // requestID -> *streamCapture
+}
+
+// begin 登记请求的流式响应记录，Traces 未启用时不登记
+func (t *streamTraceStore) begin(requestID string, capture *streamCapture) {
+	if capture != nil && requestID != "" {
+		t.streams.Store(requestID, capture)
+	}
+}
+
+// get 返回请求登记的记录，没有登记时返回 nil
+func (t *streamTraceStore) get(requestID string) *streamCapture {
+	if requestID == "" {
+		return nil
+	}
+	if v, ok := t.streams.Load(requestID); ok {
+		return v.(*streamCapture)
+	}
+	return nil
+}
+
+// take 取出并移除请求的记录
+func (t *streamTraceStore) take(requestID string) *streamCapture {
+	if v, ok := t.streams.LoadAndDelete(requestID); ok {
+		return v.(*streamCapture)
+	}
+	return nil
+}
@@ -0,0 +1,741 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/sse"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// partialToolCall 用于累积流式 tool_calls 的分片数据
+type partialToolCall struct {
+	index   int
+	id      string
+	name    string
+	args    string // 已收到的 arguments 分片
+	sent    int    // 已作为 input_json_delta 发出的 arguments 长度
+	started bool   // 是否已发送 content_block_start
+}
+
+// sendContentBlockStart 发送 Claude content_block_start 事件
+func sendContentBlockStart(w *sse.Writer, index int, blockType, blockID string) {
+	contentBlock := map[string]interface{}{
+		"type": blockType,
+	}
+
+	// 根据不同的块类型添加必需的字段
+	switch blockType {
+	case "thinking":
+		// thinking 块必须有 thinking 字段
+		contentBlock["thinking"] = ""
+	case "text":
+		// text 块必须有 text 字段
+		contentBlock["text"] = ""
+	}
+
+	w.Send("content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         index,
+		"content_block": contentBlock,
+	})
+}
+
+// sendToolUseBlockStart 发送 tool_use 块的 content_block_start 事件，id 和 name 必须在开始时给出
+func sendToolUseBlockStart(w *sse.Writer, index int, id, name string) {
+	w.Send("content_block_start", map[string]interface{}{
+		"type":  "content_block_start",
+		"index": index,
+		"content_block": map[string]interface{}{
+			"type":  "tool_use",
+			"id":    id,
+			"name":  name,
+			"input": map[string]interface{}{},
+		},
+	})
+}
+
+// claudeStopReason 将 OpenAI 的 finish_reason 转换为 Claude 的 stop_reason
+func claudeStopReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
+
+// sendContentBlockStop 发送 Claude content_block_stop 事件
+func sendContentBlockStop(w *sse.Writer, index int) {
+	w.Send("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": index,
+	})
+}
+
+// sendClaudeMessageStart 发送 Claude message_start 事件
+func sendClaudeMessageStart(w *sse.Writer, model string) {
+	w.Send("message_start", map[string]interface{}{
+		"type": "message_start",
+		"message": map[string]interface{}{
+			"id":            fmt.Sprintf("msg_%d", time.Now().UnixNano()),
+			"type":          "message",
+			"role":          "assistant",
+			"content":       []interface{}{},
+			"model":         model,
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage": map[string]interface{}{
+				"input_tokens":  0,
+				"output_tokens": 0,
+			},
+		},
+	})
+}
+
+// sendClaudeMessageEnd 发送 Claude message_delta 和 message_stop 事件
+func sendClaudeMessageEnd(w *sse.Writer, stopReason string, outputTokens int) {
+	w.Send("message_delta", map[string]interface{}{
+		"type": "message_delta",
+		"delta": map[string]interface{}{
+			"stop_reason":   stopReason,
+			"stop_sequence": nil,
+		},
+		"usage": map[string]interface{}{
+			"output_tokens": outputTokens,
+		},
+	})
+	w.Send("message_stop", map[string]interface{}{
+		"type": "message_stop",
+	})
+}
+
+// openAIDelta 取出 OpenAI chunk 的第一个 choice 及其 delta
+func openAIDelta(chunk map[string]interface{}) (choice, delta map[string]interface{}) {
+	choices, ok := chunk["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return nil, nil
+	}
+	choice, _ = choices[0].(map[string]interface{})
+	if choice != nil {
+		delta, _ = choice["delta"].(map[string]interface{})
+	}
+	return choice, delta
+}
+
+// ============ 适配器流（streamWithAdapter） ============
+
+// adapterStream 使用 adapters 包中的反向适配器逐块转换上游事件
+type adapterStream struct {
+	s           *ProxyService
+	requestID   string
+	adapter     adapters.Adapter
+	adapterName string // 反向适配器名称，如 claude-to-openai
+	model       string
+	chunkCount  int
+	chunkID     string // 输出为 OpenAI 格式时所有分片使用同一个 id
+}
+
+// eventName 输出为 Claude 格式时使用事件的 type 作为 event 名
+func (t *adapterStream) eventName(event map[string]interface{}) string {
+	if strings.HasSuffix(t.adapterName, "-to-claude") {
+		name, _ := event["type"].(string)
+		return name
+	}
+	return ""
+}
+
+// normalizeChunk 适配器生成的分片 id 每块不同、模型名为占位值，输出为 OpenAI 格式时改为本次响应的 id 和请求的模型名
+func (t *adapterStream) normalizeChunk(chunk map[string]interface{}) {
+	if !strings.HasSuffix(t.adapterName, "-to-openai") {
+		return
+	}
+	if t.chunkID == "" {
+		t.chunkID = fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	}
+	chunk["id"] = t.chunkID
+	chunk["model"] = t.model
+}
+
+func (t *adapterStream) Start(w *sse.Writer) error {
+	startEvents := t.adapter.AdaptStreamStart(t.model)
+	log.Infof("[Stream Adapter] Sending %d start events", len(startEvents))
+	for _, event := range startEvents {
+		t.normalizeChunk(event)
+		w.Send(t.eventName(event), event)
+	}
+	return nil
+}
+
+func (t *adapterStream) Transform(ev *sse.Event, w *sse.Writer) error {
+	chunk, err := ev.JSON()
+	if err != nil {
+		log.Warnf("Failed to parse chunk: %v, data: %s", err, ev.Data)
+		return nil
+	}
+
+	// Gemini 在输出任何内容之前拦截时返回错误，由调用方向客户端发送错误事件
+	if t.chunkCount == 0 && t.adapterName == "gemini-to-openai" {
+		if blocked := adapters.GeminiBlocked(chunk); blocked != nil {
+			return blocked
+		}
+	}
+
+	adaptedChunk, err := t.adapter.AdaptStreamChunk(chunk)
+	if err != nil {
+		log.Warnf("[Stream Adapter] Failed to adapt chunk: %v", err)
+		return nil
+	}
+	// 适配器返回 nil 表示该块不需要发送
+	if adaptedChunk == nil {
+		return nil
+	}
+
+	t.normalizeChunk(adaptedChunk)
+
+	t.chunkCount++
+	adaptedData, _ := json.Marshal(adaptedChunk)
+	t.s.logBody(t.requestID, "[STREAM TO CLIENT] Chunk #%d: %s", t.chunkCount, adaptedData)
+	w.SendRaw(t.eventName(adaptedChunk), string(adaptedData))
+	return nil
+}
+
+func (t *adapterStream) Finish(w *sse.Writer) error {
+	log.Infof("[Stream Adapter] Finished reading stream. Total chunks sent: %d", t.chunkCount)
+	for _, event := range t.adapter.AdaptStreamEnd() {
+		t.normalizeChunk(event)
+		w.Send(t.eventName(event), event)
+	}
+	// 输出为 OpenAI 格式时补发 [DONE]（Claude、Gemini、Ollama、Cohere 上游都没有该标记）
+	if strings.HasSuffix(t.adapterName, "-to-openai") {
+		w.Done()
+	}
+	return nil
+}
+
+// ============ OpenAI -> Claude（/api/anthropic） ============
+
+// openAIToClaudeStream 将 OpenAI 流转换为 Claude 流，支持普通文本、thinking（reasoning_content）、tool_calls
+type openAIToClaudeStream struct {
+	model string
+	usage *streamUsage
+
+	// 当前 active 的 content_block 类型，可能的值: "text", "thinking", "tool_use"
+	currentBlockType string
+	blockIndex       int
+
+	// 用于累积 tool_calls（OpenAI 流式发送 tool_calls 是分片的：先发 id 和 name，再分片发 arguments）
+	toolCalls   map[int]*partialToolCall
+	currentTool *partialToolCall // 当前打开的 tool_use 块对应的 tool_call
+	lastTool    *partialToolCall // 最近收到分片的 tool_call，用于缺少 index 的分片
+
+	finishReason string
+}
+
+func newOpenAIToClaudeStream(model string, usage *streamUsage) *openAIToClaudeStream {
+	return &openAIToClaudeStream{model: model, usage: usage, toolCalls: make(map[int]*partialToolCall)}
+}
+
+func (t *openAIToClaudeStream) Start(w *sse.Writer) error {
+	sendClaudeMessageStart(w, t.model)
+	return nil
+}
+
+// closeBlock 关闭当前 content block
+func (t *openAIToClaudeStream) closeBlock(w *sse.Writer) {
+	if t.currentBlockType == "" {
+		return
+	}
+	sendContentBlockStop(w, t.blockIndex)
+	t.blockIndex++
+	t.currentBlockType = ""
+	t.currentTool = nil
+}
+
+// switchBlock 切换到指定类型的 content block，必要时先关闭当前 block
+func (t *openAIToClaudeStream) switchBlock(w *sse.Writer, blockType string) {
+	if t.currentBlockType == blockType {
+		return
+	}
+	t.closeBlock(w)
+	sendContentBlockStart(w, t.blockIndex, blockType, "")
+	t.currentBlockType = blockType
+}
+
+func (t *openAIToClaudeStream) sendDelta(w *sse.Writer, delta map[string]interface{}) {
+	w.Send("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": t.blockIndex,
+		"delta": delta,
+	})
+}
+
+func (t *openAIToClaudeStream) Transform(ev *sse.Event, w *sse.Writer) error {
+	chunk, err := ev.JSON()
+	if err != nil {
+		return nil
+	}
+	choice, delta := openAIDelta(chunk)
+	if choice == nil {
+		return nil
+	}
+
+	if delta != nil {
+		// reasoning_content (thinking 内容)
+		if reasoningContent, ok := delta["reasoning_content"].(string); ok && reasoningContent != "" {
+			t.switchBlock(w, "thinking")
+			t.sendDelta(w, map[string]interface{}{
+				"type":     "thinking_delta",
+				"thinking": reasoningContent,
+			})
+		}
+
+		// 普通 content 文本
+		if content, ok := delta["content"].(string); ok && content != "" {
+			t.switchBlock(w, "text")
+			t.sendDelta(w, map[string]interface{}{
+				"type": "text_delta",
+				"text": content,
+			})
+		}
+
+		// tool_calls
+		if toolCalls, ok := delta["tool_calls"].([]interface{}); ok {
+			for _, tc := range toolCalls {
+				t.handleToolCall(w, tc)
+			}
+		}
+	}
+
+	if finishReason, ok := choice["finish_reason"].(string); ok && finishReason != "" {
+		t.finishReason = finishReason
+	}
+	return nil
+}
+
+// handleToolCall 处理一个 tool_call 分片
+// arguments 分片先累积，tool_use 块开始后按原样作为 input_json_delta 发出（两者都是 input 对象 JSON 的片段）
+func (t *openAIToClaudeStream) handleToolCall(w *sse.Writer, tc interface{}) {
+	tcMap, ok := tc.(map[string]interface{})
+	if !ok {
+		return
+	}
+	id, _ := tcMap["id"].(string)
+
+	pt := t.lookupToolCall(tcMap, id)
+	if id != "" && !pt.started {
+		pt.id = id
+	}
+	if function, ok := tcMap["function"].(map[string]interface{}); ok {
+		if name, ok := function["name"].(string); ok && name != "" {
+			pt.name = name
+		}
+		if args, ok := function["arguments"].(string); ok {
+			pt.args += args
+		}
+	}
+
+	switch {
+	case pt == t.currentTool:
+		t.flushToolArgs(w, pt)
+		t.startPendingTool(w)
+	case !pt.started && pt.name != "" && (t.currentTool == nil || json.Valid([]byte(t.currentTool.args))):
+		// 当前没有 tool_use 块，或当前工具的参数已完整：开始新的 tool_use 块
+		t.startTool(w, pt)
+	case pt.started && len(pt.args) > pt.sent:
+		log.Warnf("[OpenAI->Claude Stream] Dropping arguments for closed tool call %s (index %d)", pt.name, pt.index)
+		pt.sent = len(pt.args)
+	}
+	// 其他情况（并行 tool_calls 交错到达）先缓存，等当前工具的参数完整后再依次输出
+}
+
+// startTool 开始一个 tool_use 块并发送已缓存的参数（Claude 要求 content_block_start 中带有 id 和 name）
+func (t *openAIToClaudeStream) startTool(w *sse.Writer, pt *partialToolCall) {
+	t.closeBlock(w)
+	sendToolUseBlockStart(w, t.blockIndex, pt.id, pt.name)
+	t.currentBlockType = "tool_use"
+	t.currentTool = pt
+	pt.started = true
+	t.flushToolArgs(w, pt)
+}
+
+// startPendingTool 当前工具的参数完整后，切换到下一个已缓存的 tool_call
+func (t *openAIToClaudeStream) startPendingTool(w *sse.Writer) {
+	if t.currentTool == nil || !json.Valid([]byte(t.currentTool.args)) {
+		return
+	}
+	if next := t.nextPendingTool(); next != nil {
+		t.startTool(w, next)
+	}
+}
+
+// nextPendingTool 返回 index 最小的、已知 name 但尚未开始的 tool_call
+func (t *openAIToClaudeStream) nextPendingTool() *partialToolCall {
+	var next *partialToolCall
+	for _, pt := range t.toolCalls {
+		if !pt.started && pt.name != "" && (next == nil || pt.index < next.index) {
+			next = pt
+		}
+	}
+	return next
+}
+
+// lookupToolCall 按 index 查找 tool_call，上游缺少 index 时按 id 或最近的 tool_call 查找
+func (t *openAIToClaudeStream) lookupToolCall(tcMap map[string]interface{}, id string) *partialToolCall {
+	index := -1
+	if v, ok := tcMap["index"].(float64); ok {
+		index = int(v)
+	} else {
+		for i, pt := range t.toolCalls {
+			if id != "" && pt.id == id {
+				index = i
+				break
+			}
+		}
+		if index < 0 && id == "" && t.lastTool != nil {
+			index = t.lastTool.index
+		}
+		if index < 0 {
+			index = len(t.toolCalls)
+		}
+	}
+
+	pt := t.toolCalls[index]
+	if pt == nil {
+		pt = &partialToolCall{index: index, id: fmt.Sprintf("toolu_%d", time.Now().UnixNano())}
+		t.toolCalls[index] = pt
+	}
+	t.lastTool = pt
+	return pt
+}
+
+// flushToolArgs 发送尚未发出的 arguments 分片
+func (t *openAIToClaudeStream) flushToolArgs(w *sse.Writer, pt *partialToolCall) {
+	if len(pt.args) <= pt.sent {
+		return
+	}
+	t.sendDelta(w, map[string]interface{}{
+		"type":         "input_json_delta",
+		"partial_json": pt.args[pt.sent:],
+	})
+	pt.sent = len(pt.args)
+}
+
+func (t *openAIToClaudeStream) Finish(w *sse.Writer) error {
+	// 依次输出仍在缓存中的 tool_call，然后停止最后的 content block
+	for next := t.nextPendingTool(); next != nil; next = t.nextPendingTool() {
+		t.startTool(w, next)
+	}
+	t.closeBlock(w)
+
+	stopReason := claudeStopReason(t.finishReason)
+	for _, pt := range t.toolCalls {
+		if pt.started {
+			stopReason = "tool_use"
+		} else {
+			log.Warnf("[OpenAI->Claude Stream] Tool call at index %d has no name, skipped", pt.index)
+		}
+	}
+	sendClaudeMessageEnd(w, stopReason, t.usage.completionTokens)
+	return nil
+}
+
+// ============ OpenAI / Claude -> Gemini ============
+
+// geminiTextChunk 构建只包含一段文本的 Gemini 流式响应块
+func geminiTextChunk(text string) map[string]interface{} {
+	return geminiPartChunk(map[string]interface{}{"text": text})
+}
+
+// geminiThoughtChunk 构建只包含一段思考内容（thought: true）的 Gemini 流式响应块
+func geminiThoughtChunk(text string) map[string]interface{} {
+	return geminiPartChunk(map[string]interface{}{"text": text, "thought": true})
+}
+
+// geminiPartChunk 构建只包含一个 part 的 Gemini 流式响应块
+func geminiPartChunk(part map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"content": map[string]interface{}{
+					"role":  "model",
+					"parts": []interface{}{part},
+				},
+				"index": 0,
+			},
+		},
+	}
+}
+
+// geminiFinalChunk 构建带有 finishReason 和 usageMetadata 的最终块（包装为 APIMart 格式）
+func geminiFinalChunk(promptTokens, completionTokens int) map[string]interface{} {
+	geminiData := map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"finishReason": "STOP",
+				"index":        0,
+				"content": map[string]interface{}{
+					"role":  "model",
+					"parts": []interface{}{},
+				},
+				"safetyRatings": []interface{}{
+					map[string]interface{}{
+						"category":    "HARM_CATEGORY_HATE_SPEECH",
+						"probability": "NEGLIGIBLE",
+					},
+				},
+			},
+		},
+		"usageMetadata": map[string]interface{}{
+			"promptTokenCount":     promptTokens,
+			"candidatesTokenCount": completionTokens,
+			"totalTokenCount":      promptTokens + completionTokens,
+		},
+	}
+	return map[string]interface{}{
+		"code": 200,
+		"data": geminiData,
+	}
+}
+
+// toolCallAccumulator 累积 OpenAI 流式 tool_call 分片
+type toolCallAccumulator struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// openAIToGeminiStream 将 OpenAI 流转换为 Gemini 流
+type openAIToGeminiStream struct {
+	s          *ProxyService
+	requestID  string
+	usage      *streamUsage
+	chunkCount int
+	toolCalls  map[int]*toolCallAccumulator // key 是 tool_call 的 index
+}
+
+func newOpenAIToGeminiStream(s *ProxyService, requestID string, usage *streamUsage) *openAIToGeminiStream {
+	return &openAIToGeminiStream{s: s, requestID: requestID, usage: usage, toolCalls: make(map[int]*toolCallAccumulator)}
+}
+
+func (t *openAIToGeminiStream) Start(w *sse.Writer) error {
+	return nil
+}
+
+func (t *openAIToGeminiStream) Transform(ev *sse.Event, w *sse.Writer) error {
+	chunk, err := ev.JSON()
+	if err != nil {
+		log.Warnf("[OpenAI->Gemini Stream] Failed to parse chunk: %v", err)
+		return nil
+	}
+	choice, delta := openAIDelta(chunk)
+	if choice == nil {
+		return nil
+	}
+
+	if delta != nil {
+		if reasoning, ok := delta["reasoning_content"].(string); ok && reasoning != "" {
+			t.chunkCount++
+			w.Send("", geminiThoughtChunk(reasoning))
+		}
+
+		if content, ok := delta["content"].(string); ok && content != "" {
+			t.chunkCount++
+			geminiChunk := geminiTextChunk(content)
+			chunkData, _ := json.Marshal(geminiChunk)
+			t.s.logBody(t.requestID, "[OpenAI->Gemini Stream] Chunk #%d: %s", t.chunkCount, chunkData)
+			w.SendRaw("", string(chunkData))
+		}
+
+		// tool_calls 分片先累积，结束时一次性发送
+		toolCalls, _ := delta["tool_calls"].([]interface{})
+		for _, tc := range toolCalls {
+			toolCall, ok := tc.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			tcIndex := 0
+			if idx, ok := toolCall["index"].(float64); ok {
+				tcIndex = int(idx)
+			}
+			if t.toolCalls[tcIndex] == nil {
+				t.toolCalls[tcIndex] = &toolCallAccumulator{}
+			}
+			acc := t.toolCalls[tcIndex]
+			if id, ok := toolCall["id"].(string); ok && id != "" {
+				acc.ID = id
+			}
+			if function, ok := toolCall["function"].(map[string]interface{}); ok {
+				if name, ok := function["name"].(string); ok && name != "" {
+					acc.Name = name
+					log.Infof("[OpenAI->Gemini Stream] Tool call #%d name: %s", tcIndex, name)
+				}
+				if argsFragment, ok := function["arguments"].(string); ok {
+					acc.Arguments += argsFragment
+				}
+			}
+		}
+	}
+
+	if finishReason, ok := choice["finish_reason"].(string); ok && finishReason == "tool_calls" {
+		t.sendToolCalls(w)
+	}
+	return nil
+}
+
+// sendToolCalls 发送累积的 tool_calls
+func (t *openAIToGeminiStream) sendToolCalls(w *sse.Writer) {
+	chunk := geminiFunctionCallChunk(t.toolCalls)
+	t.toolCalls = make(map[int]*toolCallAccumulator)
+	if chunk == nil {
+		return
+	}
+	t.chunkCount++
+	w.Send("", chunk)
+}
+
+// geminiFunctionCallChunk 按 index 顺序将累积的工具调用转换为包含多个 functionCall part 的 Gemini 块，没有工具调用时返回 nil
+func geminiFunctionCallChunk(toolCalls map[int]*toolCallAccumulator) map[string]interface{} {
+	indexes := make([]int, 0, len(toolCalls))
+	for idx := range toolCalls {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	var functionCallParts []interface{}
+	for _, idx := range indexes {
+		acc := toolCalls[idx]
+		if acc.Name == "" {
+			continue
+		}
+		var args map[string]interface{}
+		if acc.Arguments != "" {
+			if err := json.Unmarshal([]byte(acc.Arguments), &args); err != nil {
+				log.Warnf("[Gemini Stream] Failed to parse tool call arguments: %v", err)
+			}
+		}
+		if args == nil {
+			args = make(map[string]interface{})
+		}
+		functionCallParts = append(functionCallParts, map[string]interface{}{
+			"functionCall": map[string]interface{}{
+				"name": acc.Name,
+				"args": args,
+			},
+		})
+		log.Infof("[Gemini Stream] Sending tool call: name=%s, args=%s", acc.Name, acc.Arguments)
+	}
+	if len(functionCallParts) == 0 {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"content": map[string]interface{}{
+					"role":  "model",
+					"parts": functionCallParts,
+				},
+				"index": 0,
+			},
+		},
+	}
+}
+
+// Finish 最终块在上游结束后发送，此时 usage（通常在 finish_reason 之后单独一块）已经到达
+func (t *openAIToGeminiStream) Finish(w *sse.Writer) error {
+	log.Infof("[OpenAI->Gemini Stream] Upstream finished, total chunks: %d", t.chunkCount)
+	// 部分上游以 finish_reason=stop 结束工具调用，此时 tool_calls 还未发送
+	t.sendToolCalls(w)
+	w.Send("", geminiFinalChunk(t.usage.promptTokens, t.usage.completionTokens))
+	return nil
+}
+
+// claudeToGeminiStream 将 Claude 流转换为 Gemini 流
+type claudeToGeminiStream struct {
+	s          *ProxyService
+	requestID  string
+	usage      *streamUsage
+	chunkCount int
+	toolCalls  map[int]*toolCallAccumulator // key 是 tool_use 块的 index
+}
+
+func newClaudeToGeminiStream(s *ProxyService, requestID string, usage *streamUsage) *claudeToGeminiStream {
+	return &claudeToGeminiStream{s: s, requestID: requestID, usage: usage, toolCalls: make(map[int]*toolCallAccumulator)}
+}
+
+func (t *claudeToGeminiStream) Start(w *sse.Writer) error {
+	return nil
+}
+
+func (t *claudeToGeminiStream) Transform(ev *sse.Event, w *sse.Writer) error {
+	event, err := ev.JSON()
+	if err != nil {
+		log.Warnf("[Claude->Gemini Stream] Failed to parse JSON: %v, data: %s", err, ev.Data)
+		return nil
+	}
+
+	index := 0
+	if v, ok := event["index"].(float64); ok {
+		index = int(v)
+	}
+
+	switch event["type"] {
+	case "content_block_start":
+		// tool_use 块先累积，消息结束时与其他并行调用一起作为 functionCall part 发送
+		if block, ok := event["content_block"].(map[string]interface{}); ok && block["type"] == "tool_use" {
+			id, _ := block["id"].(string)
+			name, _ := block["name"].(string)
+			t.toolCalls[index] = &toolCallAccumulator{ID: id, Name: name}
+		}
+
+	case "content_block_delta":
+		delta, _ := event["delta"].(map[string]interface{})
+		var chunk map[string]interface{}
+		switch delta["type"] {
+		case "input_json_delta":
+			if acc := t.toolCalls[index]; acc != nil {
+				partial, _ := delta["partial_json"].(string)
+				acc.Arguments += partial
+			}
+		case "text_delta":
+			if text, ok := delta["text"].(string); ok && text != "" {
+				chunk = geminiTextChunk(text)
+			}
+		case "thinking_delta":
+			if thinking, ok := delta["thinking"].(string); ok && thinking != "" {
+				chunk = geminiThoughtChunk(thinking)
+			}
+		}
+		if chunk != nil {
+			t.chunkCount++
+			chunkData, _ := json.Marshal(map[string]interface{}{
+				"code": 200,
+				"data": chunk,
+			})
+			t.s.logBody(t.requestID, "[Claude->Gemini Stream] Sending to client: %s", chunkData)
+			w.SendRaw("", string(chunkData))
+		}
+
+	case "message_stop":
+		if chunk := geminiFunctionCallChunk(t.toolCalls); chunk != nil {
+			t.chunkCount++
+			w.Send("", map[string]interface{}{"code": 200, "data": chunk})
+		}
+		w.Send("", geminiFinalChunk(t.usage.promptTokens, t.usage.completionTokens))
+	}
+	return nil
+}
+
+func (t *claudeToGeminiStream) Finish(w *sse.Writer) error {
+	log.Infof("[Claude->Gemini Stream] Stream completed. Total chunks: %d, Input tokens: %d, Output tokens: %d",
+		t.chunkCount, t.usage.promptTokens, t.usage.completionTokens)
+	return nil
+}
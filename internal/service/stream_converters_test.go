@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/sse"
+)
+
+// runConverter 逐字节读取上游（事件被拆到多次读取中），经 transformer 转换后返回写给客户端的内容
+func runConverter(t *testing.T, transformer sse.Transformer, upstream string, usage *streamUsage) string {
+	t.Helper()
+	var out bytes.Buffer
+	r := sse.NewReader(iotest.OneByteReader(strings.NewReader(upstream)))
+	if err := sse.Pipe(r, sse.NewWriter(&out, nil), transformer, usage.observe); err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	return out.String()
+}
+
+// clientEvents 解析写给客户端的 SSE 事件
+func clientEvents(t *testing.T, output string) []*sse.Event {
+	t.Helper()
+	r := sse.NewReader(strings.NewReader(output))
+	var events []*sse.Event
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("client stream: %v", err)
+		}
+		events = append(events, ev)
+	}
+}
+
+// openAIStreamResult 从 OpenAI 分片中拼接出的文本、finish_reason，以及是否以 [DONE] 结束
+func openAIStreamResult(t *testing.T, events []*sse.Event) (content, finishReason string, done bool) {
+	t.Helper()
+	for i, ev := range events {
+		if ev.IsDone() {
+			if i != len(events)-1 {
+				t.Errorf("[DONE] is not the last event")
+			}
+			done = true
+			continue
+		}
+		chunk, err := ev.JSON()
+		if err != nil {
+			t.Fatalf("invalid chunk %q: %v", ev.Data, err)
+		}
+		choice, delta := openAIDelta(chunk)
+		if choice == nil {
+			continue
+		}
+		if text, ok := delta["content"].(string); ok {
+			content += text
+		}
+		if reason, ok := choice["finish_reason"].(string); ok {
+			finishReason = reason
+		}
+	}
+	return content, finishReason, done
+}
+
+func newAdapterStream(adapterName, model string) *adapterStream {
+	return &adapterStream{
+		s:           &ProxyService{},
+		adapter:     adapters.GetStreamAdapter(adapterName),
+		adapterName: adapterName,
+		model:       model,
+	}
+}
+
+func TestStreamConverters(t *testing.T) {
+	tests := []struct {
+		name        string
+		transformer func() sse.Transformer
+		upstream    string
+		check       func(t *testing.T, events []*sse.Event)
+	}{
+		{
+			name:        "openai to claude",
+			transformer: func() sse.Transformer { return newOpenAIToClaudeStream("claude-test", &streamUsage{}) },
+			upstream: "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"length\"}]}\n\n" +
+				"data: [DONE]\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"after done\"}}]}\n\n",
+			check: func(t *testing.T, events []*sse.Event) {
+				var names []string
+				text := ""
+				for _, ev := range events {
+					names = append(names, ev.Name)
+					chunk, _ := ev.JSON()
+					if delta, ok := chunk["delta"].(map[string]interface{}); ok && delta["type"] == "text_delta" {
+						text += delta["text"].(string)
+					}
+					if ev.Name == "message_delta" {
+						if reason := chunk["delta"].(map[string]interface{})["stop_reason"]; reason != "max_tokens" {
+							t.Errorf("stop_reason = %v, want max_tokens", reason)
+						}
+					}
+				}
+				want := "message_start content_block_start content_block_delta content_block_delta content_block_stop message_delta message_stop"
+				if got := strings.Join(names, " "); got != want {
+					t.Errorf("events = %s, want %s", got, want)
+				}
+				if text != "Hello" {
+					t.Errorf("text = %q, want Hello", text)
+				}
+			},
+		},
+		{
+			name:        "claude to openai",
+			transformer: func() sse.Transformer { return newAdapterStream("claude-to-openai", "claude-test") },
+			upstream: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":5}}}\n\n" +
+				"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n" +
+				"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"lo\"}}\n\n" +
+				"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":2}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			check: func(t *testing.T, events []*sse.Event) {
+				content, finishReason, done := openAIStreamResult(t, events)
+				if content != "Hello" || finishReason != "stop" || !done {
+					t.Errorf("content=%q finish_reason=%q done=%v", content, finishReason, done)
+				}
+				ids := make(map[string]bool)
+				for _, ev := range events[:len(events)-1] {
+					chunk, _ := ev.JSON()
+					ids[chunk["id"].(string)] = true
+					if chunk["model"] != "claude-test" {
+						t.Errorf("model = %v, want claude-test", chunk["model"])
+					}
+				}
+				if len(ids) != 1 {
+					t.Errorf("chunks use %d different ids, want 1", len(ids))
+				}
+			},
+		},
+		{
+			name:        "gemini to openai",
+			transformer: func() sse.Transformer { return newAdapterStream("gemini-to-openai", "gemini-test") },
+			upstream: "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"Hel\"}]},\"index\":0}]}\r\n\r\n" +
+				"data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"lo\"}]},\"finishReason\":\"STOP\",\"index\":0}]," +
+				"\"usageMetadata\":{\"promptTokenCount\":3,\"candidatesTokenCount\":2}}\r\n\r\n",
+			check: func(t *testing.T, events []*sse.Event) {
+				content, finishReason, done := openAIStreamResult(t, events)
+				if content != "Hello" || finishReason != "stop" || !done {
+					t.Errorf("content=%q finish_reason=%q done=%v", content, finishReason, done)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := runConverter(t, tt.transformer(), tt.upstream, &streamUsage{})
+			tt.check(t, clientEvents(t, output))
+		})
+	}
+}
+
+func TestOpenAIToGeminiJSONArrayStream(t *testing.T) {
+	// Gemini :streamGenerateContent 不带 alt=sse 时，转换后的 SSE 经 JSONArrayWriter 输出为 JSON 数组
+	upstream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2}}\n\n" +
+		"data: [DONE]\n\n"
+
+	var out bytes.Buffer
+	usage := &streamUsage{}
+	array := sse.NewJSONArrayWriter(&out, nil)
+	r := sse.NewReader(iotest.OneByteReader(strings.NewReader(upstream)))
+	if err := sse.Pipe(r, sse.NewWriter(array, nil), newOpenAIToGeminiStream(&ProxyService{}, "", usage), usage.observe); err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	if err := array.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var elements []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &elements); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, out.String())
+	}
+	if len(elements) != 3 {
+		t.Fatalf("got %d elements, want 3: %s", len(elements), out.String())
+	}
+	text := ""
+	for _, element := range elements[:2] {
+		candidate := element["candidates"].([]interface{})[0].(map[string]interface{})
+		part := candidate["content"].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})
+		text += part["text"].(string)
+	}
+	if text != "Hello" {
+		t.Errorf("text = %q, want Hello", text)
+	}
+	final := elements[2]["data"].(map[string]interface{})
+	metadata := final["usageMetadata"].(map[string]interface{})
+	if metadata["promptTokenCount"] != 3.0 || metadata["candidatesTokenCount"] != 2.0 {
+		t.Errorf("usageMetadata = %v", metadata)
+	}
+}
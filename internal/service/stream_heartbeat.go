@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 流式响应心跳：上游长时间没有数据（慢模型首 token、长时间思考）时定期向客户端发送心跳，
+// 避免中间代理或客户端因空闲超时断开连接。只在事件边界写入，不会打断正在转发的事件；
+// 默认发送 SSE 注释行（所有 SSE 客户端都会忽略），输出为 Claude 格式时发送 Claude 的 ping 事件
+
+var (
+	sseCommentHeartbeat = []byte(": ping\n\n")
+	claudePingHeartbeat = []byte("event: ping\ndata: {\"type\": \"ping\"}\n\n")
+)
+
+// heartbeatWriter 包装客户端写入，与心跳协程互斥写入
+type heartbeatWriter struct {
+	w        io.Writer
+	flusher  http.Flusher
+	interval time.Duration
+
+	mu        sync.Mutex
+	lastWrite time.Time
+	tail      []byte // 最后写入的几个字节，用于判断是否处于事件边界
+	claude    bool   // 已输出 Claude 格式的事件
+	err       error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startHeartbeat 为 SSE 响应启动心跳，返回替代原 writer/flusher 的写入器和停止函数；
+// 未开启心跳或响应不是 SSE（如 Gemini JSON 数组、NDJSON）时原样返回
+func (s *ProxyService) startHeartbeat(writer io.Writer, flusher http.Flusher) (io.Writer, http.Flusher, func()) {
+	interval := time.Duration(s.config.StreamHeartbeatSeconds) * time.Second
+	w, ok := writer.(interface{ Header() http.Header })
+	if interval <= 0 || !ok || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		return writer, flusher, func() {}
+	}
+
+	hb := &heartbeatWriter{
+		w:         writer,
+		flusher:   flusher,
+		interval:  interval,
+		lastWrite: time.Now(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	safeGo(requestIDFromWriter(writer), hb.run)
+	return hb, hb, func() {
+		close(hb.stop)
+		<-hb.done
+	}
+}
+
+func (h *heartbeatWriter) run() {
+	defer close(h.done)
+	// 检查间隔取心跳间隔的一半，保证空闲时间超过间隔后尽快发送
+	ticker := time.NewTicker(h.interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			h.beat()
+		}
+	}
+}
+
+// beat 空闲时间达到间隔且处于事件边界时发送一次心跳
+func (h *heartbeatWriter) beat() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err != nil || time.Since(h.lastWrite) < h.interval || !h.atBoundary() {
+		return
+	}
+	beat := sseCommentHeartbeat
+	if h.claude {
+		beat = claudePingHeartbeat
+	}
+	if _, err := h.w.Write(beat); err != nil {
+		// 客户端已断开，之后的写入直接返回错误
+		h.err = err
+		return
+	}
+	h.lastWrite = time.Now()
+	if h.flusher != nil {
+		h.flusher.Flush()
+	}
+}
+
+// atBoundary 还没有输出或最后输出的是完整事件（以空行结尾）
+func (h *heartbeatWriter) atBoundary() bool {
+	return len(h.tail) == 0 || bytes.HasSuffix(h.tail, []byte("\n\n")) || bytes.HasSuffix(h.tail, []byte("\r\n\r\n"))
+}
+
+func (h *heartbeatWriter) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err != nil {
+		return 0, h.err
+	}
+	n, err := h.w.Write(p)
+	if err != nil {
+		h.err = err
+	}
+	if n > 0 {
+		h.lastWrite = time.Now()
+		if n >= 4 {
+			h.tail = append(h.tail[:0], p[n-4:n]...)
+		} else if h.tail = append(h.tail, p[:n]...); len(h.tail) > 4 {
+			h.tail = h.tail[len(h.tail)-4:]
+		}
+		if !h.claude && (bytes.Contains(p[:n], []byte("event: message_start")) || bytes.Contains(p[:n], []byte("event:message_start"))) {
+			h.claude = true
+		}
+	}
+	return n, err
+}
+
+func (h *heartbeatWriter) Flush() {
+	if h.flusher == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flusher.Flush()
+}
+
+// Header 保留原 writer 的响应头（requestIDFromWriter 据此读取请求ID）
+func (h *heartbeatWriter) Header() http.Header {
+	return h.w.(interface{ Header() http.Header }).Header()
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"openai-router-go/internal/sse"
+)
+
+// 零拷贝透传：同格式路由的流式响应按读到的字节原样写给客户端，不逐行解析事件；
+// 只保留开头和结尾的少量数据，流结束后从中提取 token 用量
+// （Claude 的 input_tokens 在开头的 message_start，其它格式的用量在最后几个事件）
+
+const (
+	passthroughHeadSize = 16 << 10 // 保留开头的字节数
+	passthroughTailSize = 64 << 10 // 保留结尾的字节数
+	passthroughCopySize = 32 << 10 // 每次读取上游的缓冲大小
+)
+
+// usageWindow 只保留流开头和结尾部分的写入器，内存占用有上限
+type usageWindow struct {
+	head    []byte
+	tail    []byte
+	skipped bool // 中间有数据被丢弃，head 和 tail 不连续
+}
+
+func (w *usageWindow) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := passthroughHeadSize - len(w.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		w.head = append(w.head, p[:room]...)
+		p = p[room:]
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+	w.tail = append(w.tail, p...)
+	// 超过两倍上限时才整理，避免每次写入都移动数据
+	if len(w.tail) > 2*passthroughTailSize {
+		w.tail = append(w.tail[:0], w.tail[len(w.tail)-passthroughTailSize:]...)
+		w.skipped = true
+	}
+	return n, nil
+}
+
+// usage 从保留的数据中提取 token 用量
+func (w *usageWindow) usage() *streamUsage {
+	usage := &streamUsage{}
+	scan := func(data []byte) {
+		events := sse.NewReader(bytes.NewReader(data))
+		for {
+			ev, err := events.Next()
+			if err != nil {
+				return
+			}
+			usage.observe(ev)
+		}
+	}
+	if !w.skipped {
+		scan(append(w.head, w.tail...))
+		return usage
+	}
+	scan(w.head)
+	// tail 的第一行可能不完整
+	tail := w.tail
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+	scan(tail)
+	return usage
+}
+
+// usePassthrough 是否对该请求使用零拷贝透传：需要开启 stream_passthrough，
+// 且该请求不需要逐个事件的内容（未记录 Trace 内容、未记录响应体日志）
+func (s *ProxyService) usePassthrough(requestID string) bool {
+	return s.config != nil && s.config.StreamPassthrough &&
+		s.streamTraces.get(requestID) == nil && !s.shouldLogBody(requestID)
+}
+
+// streamPassthrough 将上游流原样复制给客户端，每次读到数据立即 flush
+func (s *ProxyService) streamPassthrough(upstream *firstChunkReader, writer io.Writer, flusher http.Flusher, model string, logCtx StreamLogContext) error {
+	requestID := requestIDFromWriter(writer)
+	window := &usageWindow{}
+	out := sse.NewWriter(writer, flusher)
+	_, err := io.CopyBuffer(io.MultiWriter(out, window), upstream, make([]byte, passthroughCopySize))
+	s.logStreamResult(requestID, model, window.usage(), logCtx, upstream, err)
+	return err
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/sse"
+)
+
+// streamUsage 从上游流式事件中累计 token 用量，兼容 OpenAI、Claude、Gemini、Ollama、Cohere 格式
+type streamUsage struct {
+	promptTokens     int
+	completionTokens int
+}
+
+// observe 检查一个上游事件中的用量信息
+func (u *streamUsage) observe(ev *sse.Event) {
+	chunk, err := ev.JSON()
+	if err != nil {
+		return
+	}
+
+	// OpenAI 的 usage 以及 Claude message_delta 的 usage 都在顶层
+	if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+		u.read(usage)
+	}
+	switch chunk["type"] {
+	case "message_start":
+		// Claude：input_tokens 在 message.usage 中
+		if message, ok := chunk["message"].(map[string]interface{}); ok {
+			if usage, ok := message["usage"].(map[string]interface{}); ok {
+				u.read(usage)
+			}
+		}
+	case "message-end":
+		// Cohere：message-end 事件携带 token 统计
+		if delta, ok := chunk["delta"].(map[string]interface{}); ok {
+			u.read(adapters.CohereUsage(delta["usage"]))
+		}
+	}
+	// Gemini
+	if metadata, ok := chunk["usageMetadata"].(map[string]interface{}); ok {
+		if v, ok := metadata["promptTokenCount"].(float64); ok && v > 0 {
+			u.promptTokens = int(v)
+		}
+		if v, ok := metadata["candidatesTokenCount"].(float64); ok && v > 0 {
+			u.completionTokens = int(v)
+		}
+	}
+	// Ollama：最后一块（done=true）携带 token 统计
+	if done, _ := chunk["done"].(bool); done {
+		if v, ok := chunk["prompt_eval_count"].(float64); ok && v > 0 {
+			u.promptTokens = int(v)
+		}
+		if v, ok := chunk["eval_count"].(float64); ok && v > 0 {
+			u.completionTokens = int(v)
+		}
+	}
+}
+
+// read 读取 OpenAI（prompt/completion_tokens）或 Claude（input/output_tokens）格式的 usage，为 0 的字段不覆盖已有值
+func (u *streamUsage) read(usage map[string]interface{}) {
+	for _, key := range []string{"prompt_tokens", "input_tokens"} {
+		if v, ok := usage[key].(float64); ok && v > 0 {
+			u.promptTokens = int(v)
+			break
+		}
+	}
+	for _, key := range []string{"completion_tokens", "output_tokens"} {
+		if v, ok := usage[key].(float64); ok && v > 0 {
+			u.completionTokens = int(v)
+			break
+		}
+	}
+}
+
+// firstChunkReader 记录第一次从上游读到数据的时间，用于统计流式请求的首字节时间
+type firstChunkReader struct {
+	io.Reader
+	at time.Time
+}
+
+func (r *firstChunkReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 && r.at.IsZero() {
+		r.at = time.Now()
+	}
+	return n, err
+}
+
+// runStream 通过 SSE 管道将上游流经 transformer 转换后写给客户端，结束后记录请求日志
+func (s *ProxyService) runStream(reader io.Reader, writer io.Writer, flusher http.Flusher, model string, transformer sse.Transformer, usage *streamUsage, logCtx StreamLogContext) (err error) {
+	requestID := requestIDFromWriter(writer)
+	if logCtx.StartTime.IsZero() {
+		logCtx.StartTime = time.Now()
+	}
+	upstream := &firstChunkReader{Reader: reader}
+	// 转换器 panic 时记为失败请求，错误由调用方作为 SSE 错误事件发送给客户端
+	defer func() {
+		if perr := RecoverPanic(requestID, recover()); perr != nil {
+			err = perr
+			s.logStreamResult(requestID, model, usage, logCtx, upstream, err)
+		}
+	}()
+
+	writer, flusher, stopHeartbeat := s.startHeartbeat(writer, flusher)
+	defer stopHeartbeat()
+
+	capture := s.streamTraces.get(requestID)
+	observe := func(ev *sse.Event) {
+		s.logBody(requestID, "[Stream] Upstream event %q: %s", ev.Name, ev.Data)
+		usage.observe(ev)
+		capture.observe(ev)
+	}
+	err = sse.Pipe(sse.NewReader(upstream), sse.NewWriter(writer, flusher), transformer, observe)
+	s.logStreamResult(requestID, model, usage, logCtx, upstream, err)
+	return err
+}
+
+// streamDirect 直接转发流式响应：原样写给客户端，同时解析事件提取 token 用量
+func (s *ProxyService) streamDirect(reader io.Reader, writer io.Writer, flusher http.Flusher, model string, routeID int64, startTime ...time.Time) error {
+	return s.streamDirectContext(reader, writer, flusher, model, streamLogContext(routeID, "", startTime))
+}
+
+// streamDirectContext 与 streamDirect 相同，使用调用方提供的日志上下文；开启零拷贝透传时不解析事件
+func (s *ProxyService) streamDirectContext(reader io.Reader, writer io.Writer, flusher http.Flusher, model string, logCtx StreamLogContext) (err error) {
+	requestID := requestIDFromWriter(writer)
+	if logCtx.StartTime.IsZero() {
+		logCtx.StartTime = time.Now()
+	}
+	usage := &streamUsage{}
+	upstream := &firstChunkReader{Reader: reader}
+	defer func() {
+		if perr := RecoverPanic(requestID, recover()); perr != nil {
+			err = perr
+			s.logStreamResult(requestID, model, usage, logCtx, upstream, err)
+		}
+	}()
+
+	writer, flusher, stopHeartbeat := s.startHeartbeat(writer, flusher)
+	defer stopHeartbeat()
+
+	if s.usePassthrough(requestID) {
+		return s.streamPassthrough(upstream, writer, flusher, model, logCtx)
+	}
+
+	capture := s.streamTraces.get(requestID)
+	events := sse.NewReader(io.TeeReader(upstream, sse.NewWriter(writer, flusher)))
+	for {
+		var ev *sse.Event
+		if ev, err = events.Next(); err != nil {
+			break
+		}
+		s.logBody(requestID, "[Stream Direct] Upstream event %q: %s", ev.Name, ev.Data)
+		usage.observe(ev)
+		capture.observe(ev)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	s.logStreamResult(requestID, model, usage, logCtx, upstream, err)
+	return err
+}
+
+// logStreamResult 记录流式请求日志，首字节时间取 upstream 第一次读到数据的时间；err 不为 nil 时记为失败（上游中断或客户端断开）
+func (s *ProxyService) logStreamResult(requestID, model string, usage *streamUsage, logCtx StreamLogContext, upstream *firstChunkReader, err error) {
+	logger := RequestLogger(requestID)
+	if capture := s.streamTraces.get(requestID); capture != nil {
+		capture.usage = *usage
+	}
+	params := RequestLogParams{
+		RequestID:      requestID,
+		Model:          model,
+		ProviderModel:  logCtx.ProviderModel,
+		ProviderName:   logCtx.ProviderName,
+		RouteID:        logCtx.RouteID,
+		RequestTokens:  usage.promptTokens,
+		ResponseTokens: usage.completionTokens,
+		TotalTokens:    usage.promptTokens + usage.completionTokens,
+		Success:        err == nil,
+		Style:          logCtx.Style,
+		IsStream:       true,
+		ProxyTimeMs:    time.Since(logCtx.StartTime).Milliseconds(),
+	}
+	if !upstream.at.IsZero() {
+		params.FirstChunkMs = upstream.at.Sub(logCtx.StartTime).Milliseconds()
+	}
+	if err != nil {
+		logger.Errorf("[Stream] Stream for model %s failed: %v", model, err)
+		params.ErrorMessage = err.Error()
+	} else {
+		logger.Infof("[Stream] Completed: model=%s, promptTokens=%d, completionTokens=%d", model, usage.promptTokens, usage.completionTokens)
+	}
+	s.routeService.LogRequestFull(params)
+}
+
+// streamLogContext 构建流式请求日志上下文，未传入开始时间时使用当前时间
+func streamLogContext(routeID int64, style string, startTime []time.Time) StreamLogContext {
+	logCtx := StreamLogContext{RouteID: routeID, Style: style, StartTime: time.Now()}
+	if len(startTime) > 0 {
+		logCtx.StartTime = startTime[0]
+	}
+	return logCtx
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+)
+
+// delayedReader 第一次读取前等待 delay，模拟上游首字节延迟
+type delayedReader struct {
+	io.Reader
+	delay time.Duration
+	read  bool
+}
+
+func (r *delayedReader) Read(p []byte) (int, error) {
+	if !r.read {
+		r.read = true
+		time.Sleep(r.delay)
+	}
+	return r.Reader.Read(p)
+}
+
+func TestStreamFirstChunkMs(t *testing.T) {
+	const delay = 30 * time.Millisecond
+	upstream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":1}}\n\n" +
+		"data: [DONE]\n\n"
+
+	tests := []struct {
+		name        string
+		passthrough bool
+		stream      func(s *ProxyService, reader io.Reader, startTime time.Time) error
+	}{
+		{
+			name: "direct",
+			stream: func(s *ProxyService, reader io.Reader, startTime time.Time) error {
+				return s.streamDirect(reader, io.Discard, nil, "gpt-test", 1, startTime)
+			},
+		},
+		{
+			name:        "passthrough",
+			passthrough: true,
+			stream: func(s *ProxyService, reader io.Reader, startTime time.Time) error {
+				return s.streamDirect(reader, io.Discard, nil, "gpt-test", 1, startTime)
+			},
+		},
+		{
+			name: "converted",
+			stream: func(s *ProxyService, reader io.Reader, startTime time.Time) error {
+				usage := &streamUsage{}
+				return s.runStream(reader, io.Discard, nil, "gpt-test", newOpenAIToClaudeStream("gpt-test", usage), usage, streamLogContext(1, "claude", []time.Time{startTime}))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			routeService := NewRouteService(db, nil)
+			s := &ProxyService{routeService: routeService, config: &config.Config{StreamPassthrough: tt.passthrough}}
+
+			if err := tt.stream(s, &delayedReader{Reader: strings.NewReader(upstream), delay: delay}, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			routeService.FlushRequestLogs()
+
+			stats, err := routeService.GetLatencyStats(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := stats.Overall.FirstChunk
+			if first.Samples != 1 || first.P50 < delay.Milliseconds() {
+				t.Errorf("first chunk stats = %+v, want 1 sample >= %dms", first, delay.Milliseconds())
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"openai-router-go/internal/sse"
+)
+
+// 流式工具调用转换的测试数据在 testdata/stream_tool_calls/<方向>/ 下：<名称>.sse 为上游流，
+// <名称>.json 为客户端按协议拼接分片后得到的消息（工具参数解析为对象，便于比较）
+
+// claudeStreamMessage 按 Claude 协议拼接的消息
+type claudeStreamMessage struct {
+	Content      []map[string]interface{} `json:"content"`
+	StopReason   string                   `json:"stop_reason"`
+	OutputTokens int                      `json:"output_tokens,omitempty"`
+}
+
+// assembleClaudeStream 像 Claude 客户端一样拼接事件：content block 必须按 index 顺序开始和结束，
+// tool_use 块的 input_json_delta 拼接后必须是完整的 JSON 对象
+func assembleClaudeStream(t *testing.T, events []*sse.Event) claudeStreamMessage {
+	t.Helper()
+	var msg claudeStreamMessage
+	var partial strings.Builder
+	open := -1
+	for i, ev := range events {
+		chunk, err := ev.JSON()
+		if err != nil {
+			t.Fatalf("invalid event %q: %v", ev.Data, err)
+		}
+		if chunk["type"] != ev.Name {
+			t.Errorf("event name %q does not match type %v", ev.Name, chunk["type"])
+		}
+		index, _ := chunk["index"].(float64)
+		switch ev.Name {
+		case "content_block_start":
+			if open >= 0 || int(index) != len(msg.Content) {
+				t.Fatalf("content_block_start index %v while block %d is open, %d blocks done", index, open, len(msg.Content))
+			}
+			open = int(index)
+			block := chunk["content_block"].(map[string]interface{})
+			if block["type"] == "tool_use" && (block["id"] == "" || block["name"] == "") {
+				t.Errorf("tool_use block without id or name: %v", block)
+			}
+			msg.Content = append(msg.Content, block)
+			partial.Reset()
+		case "content_block_delta":
+			if int(index) != open {
+				t.Fatalf("delta for block %v while block %d is open", index, open)
+			}
+			block := msg.Content[open]
+			delta := chunk["delta"].(map[string]interface{})
+			switch delta["type"] {
+			case "text_delta":
+				block["text"] = block["text"].(string) + delta["text"].(string)
+			case "input_json_delta":
+				partial.WriteString(delta["partial_json"].(string))
+			default:
+				t.Errorf("unexpected delta %v", delta)
+			}
+		case "content_block_stop":
+			if int(index) != open {
+				t.Fatalf("content_block_stop for block %v while block %d is open", index, open)
+			}
+			if block := msg.Content[open]; block["type"] == "tool_use" {
+				input := map[string]interface{}{}
+				if partial.Len() > 0 {
+					if err := json.Unmarshal([]byte(partial.String()), &input); err != nil {
+						t.Fatalf("tool %v input %q is not a JSON object: %v", block["name"], partial.String(), err)
+					}
+				}
+				block["input"] = input
+			}
+			open = -1
+		case "message_delta":
+			msg.StopReason, _ = chunk["delta"].(map[string]interface{})["stop_reason"].(string)
+			if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+				tokens, _ := usage["output_tokens"].(float64)
+				msg.OutputTokens = int(tokens)
+			}
+		case "message_stop":
+			if i != len(events)-1 {
+				t.Errorf("message_stop is not the last event")
+			}
+		}
+	}
+	if open >= 0 {
+		t.Errorf("block %d was never stopped", open)
+	}
+	if len(events) == 0 || events[len(events)-1].Name != "message_stop" {
+		t.Errorf("stream does not end with message_stop")
+	}
+	return msg
+}
+
+// openAIStreamMessage 按 OpenAI 协议拼接的消息
+type openAIStreamMessage struct {
+	Content      string                   `json:"content,omitempty"`
+	ToolCalls    []map[string]interface{} `json:"tool_calls"`
+	FinishReason string                   `json:"finish_reason"`
+}
+
+// assembleOpenAIStream 像 OpenAI 客户端一样按 index 拼接 tool_calls：id 取第一次出现的值，name 和 arguments 逐片拼接，
+// 最后 arguments 必须是完整的 JSON 对象
+func assembleOpenAIStream(t *testing.T, events []*sse.Event) openAIStreamMessage {
+	t.Helper()
+	var msg openAIStreamMessage
+	type toolCall struct {
+		id, name, arguments string
+	}
+	calls := make(map[int]*toolCall)
+	content, finishReason, done := openAIStreamResult(t, events)
+	if !done {
+		t.Errorf("stream does not end with [DONE]")
+	}
+	msg.Content, msg.FinishReason = content, finishReason
+	for _, ev := range events {
+		if ev.IsDone() {
+			continue
+		}
+		chunk, _ := ev.JSON()
+		_, delta := openAIDelta(chunk)
+		toolCalls, _ := delta["tool_calls"].([]interface{})
+		for _, tc := range toolCalls {
+			tcMap := tc.(map[string]interface{})
+			index, ok := tcMap["index"].(float64)
+			if !ok {
+				t.Fatalf("tool call without index: %v", tcMap)
+			}
+			call := calls[int(index)]
+			if call == nil {
+				call = &toolCall{}
+				calls[int(index)] = call
+			}
+			if id, _ := tcMap["id"].(string); id != "" && call.id == "" {
+				call.id = id
+			}
+			if function, ok := tcMap["function"].(map[string]interface{}); ok {
+				name, _ := function["name"].(string)
+				arguments, _ := function["arguments"].(string)
+				call.name += name
+				call.arguments += arguments
+			}
+		}
+	}
+
+	indexes := make([]int, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		call := calls[index]
+		var arguments map[string]interface{}
+		if err := json.Unmarshal([]byte(call.arguments), &arguments); err != nil {
+			t.Fatalf("tool %s arguments %q are not a JSON object: %v", call.name, call.arguments, err)
+		}
+		msg.ToolCalls = append(msg.ToolCalls, map[string]interface{}{
+			"index": index, "id": call.id, "name": call.name, "arguments": arguments,
+		})
+	}
+	return msg
+}
+
+// normalizeJSON 转换为 JSON 再解析，便于与测试数据比较
+func normalizeJSON(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestStreamToolCallFixtures(t *testing.T) {
+	directions := []struct {
+		dir         string
+		transformer func(usage *streamUsage) sse.Transformer
+		assemble    func(t *testing.T, events []*sse.Event) interface{}
+	}{
+		{
+			dir:         "openai_to_claude",
+			transformer: func(usage *streamUsage) sse.Transformer { return newOpenAIToClaudeStream("claude-test", usage) },
+			assemble:    func(t *testing.T, events []*sse.Event) interface{} { return assembleClaudeStream(t, events) },
+		},
+		{
+			dir:         "claude_to_openai",
+			transformer: func(usage *streamUsage) sse.Transformer { return newAdapterStream("claude-to-openai", "claude-test") },
+			assemble:    func(t *testing.T, events []*sse.Event) interface{} { return assembleOpenAIStream(t, events) },
+		},
+	}
+
+	for _, d := range directions {
+		inputs, err := filepath.Glob(filepath.Join("testdata", "stream_tool_calls", d.dir, "*.sse"))
+		if err != nil || len(inputs) == 0 {
+			t.Fatalf("no fixtures for %s: %v", d.dir, err)
+		}
+		for _, input := range inputs {
+			name := strings.TrimSuffix(filepath.Base(input), ".sse")
+			t.Run(d.dir+"/"+name, func(t *testing.T) {
+				upstream, err := os.ReadFile(input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantData, err := os.ReadFile(strings.TrimSuffix(input, ".sse") + ".json")
+				if err != nil {
+					t.Fatal(err)
+				}
+				var want interface{}
+				if err := json.Unmarshal(wantData, &want); err != nil {
+					t.Fatalf("invalid fixture: %v", err)
+				}
+
+				usage := &streamUsage{}
+				output := runConverter(t, d.transformer(usage), string(upstream), usage)
+				got := normalizeJSON(t, d.assemble(t, clientEvents(t, output)))
+				if !reflect.DeepEqual(got, want) {
+					gotData, _ := json.MarshalIndent(got, "", "  ")
+					t.Errorf("assembled message:\n%s\nwant:\n%s\nclient stream:\n%s", gotData, wantData, output)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+// 转换对比：记录 Trace 时同时保存格式转换后的上游请求和转换前的上游响应，
+// 与 Trace 中的客户端请求、返回给客户端的响应一起在 GUI 中对比
+
+// TraceConversion 一条 Trace 在适配器转换前后的四份内容
+type TraceConversion struct {
+	TraceID         int64  `json:"trace_id"`
+	RequestID       string `json:"request_id"`
+	Style           string `json:"style"`            // 客户端请求格式
+	ProviderName    string `json:"provider_name"`    // 路由名称
+	InboundRequest  string `json:"inbound_request"`  // 客户端发来的请求（转换前）
+	UpstreamRequest string `json:"upstream_request"` // 发往上游的请求（转换后）
+	RawResponse     string `json:"raw_response"`     // 上游返回的原始响应（转换前）
+	ClientResponse  string `json:"client_response"`  // 返回给客户端的响应（转换后）
+}
+
+// CaptureConversions 记录 Traces 时登记请求，使 doWithRetry 记录发往上游的请求和原始响应，请求结束后调用返回的函数
+func (s *ProxyService) CaptureConversions(requestID string) func() {
+	if s.config == nil || !s.config.TracesEnabled {
+		return func() {}
+	}
+	return s.inspections.acquire(requestID)
+}
+
+// GetTraceConversion 获取 Trace 转换前后的内容，redactor 不为空时脱敏
+func (s *RouteService) GetTraceConversion(id int64, redactor *SecretRedactor) (TraceConversion, error) {
+	var conv TraceConversion
+	err := s.getTraceDB().QueryRow(`
+		SELECT id, COALESCE(request_id, ''), COALESCE(style, ''), COALESCE(provider_name, ''),
+		       COALESCE(request_content, ''), COALESCE(upstream_request, ''), COALESCE(raw_response, ''), COALESCE(response_content, '')
+		FROM conversation_traces
+		WHERE id = ?`, id).Scan(&conv.TraceID, &conv.RequestID, &conv.Style, &conv.ProviderName,
+		&conv.InboundRequest, &conv.UpstreamRequest, &conv.RawResponse, &conv.ClientResponse)
+	if err != nil {
+		return conv, err
+	}
+	if redactor != nil {
+		conv.InboundRequest = redactor.Redact(conv.InboundRequest)
+		conv.UpstreamRequest = redactor.Redact(conv.UpstreamRequest)
+		conv.RawResponse = redactor.Redact(conv.RawResponse)
+		conv.ClientResponse = redactor.Redact(conv.ClientResponse)
+	}
+	return conv, nil
+}
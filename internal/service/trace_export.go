@@ -0,0 +1,369 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"openai-router-go/internal/adapters"
+	"openai-router-go/internal/database"
+)
+
+// Trace 导出：按会话或时间范围导出为 OpenAI 微调格式 JSONL、原始记录 JSONL/JSON 或 CSV，用于整理数据集
+
+const (
+	TraceExportFinetune = "finetune" // OpenAI 微调格式：每行 {"messages": [...请求消息, 助手回复]}
+	TraceExportJSONL    = "jsonl"    // 每行一条记录：{messages, response, ...}
+	TraceExportJSON     = "json"     // 记录数组
+	TraceExportCSV      = "csv"
+
+	maxTraceExport = 50000 // 单次最多导出的记录数
+)
+
+// TraceExportOptions 导出条件，SessionID 为空时按时间范围导出
+type TraceExportOptions struct {
+	SessionID   string `json:"session_id"`
+	StartTime   string `json:"start_time"` // 2006-01-02 15:04:05
+	EndTime     string `json:"end_time"`
+	Format      string `json:"format"`       // finetune（默认）、jsonl、json、csv
+	SuccessOnly bool   `json:"success_only"` // 只导出成功的请求（finetune 格式始终只导出成功且有回复的请求）
+}
+
+// TraceExportResult 导出结果
+type TraceExportResult struct {
+	Format   string `json:"format"`
+	Content  string `json:"content"`
+	Count    int    `json:"count"`
+	Skipped  int    `json:"skipped"` // 无法转换为对话（请求格式不支持或没有回复）的记录
+	Filename string `json:"filename"`
+}
+
+// traceExportRecord jsonl/json 格式的一条记录
+type traceExportRecord struct {
+	ID            int64                    `json:"id"`
+	SessionID     string                   `json:"session_id"`
+	ParentID      int64                    `json:"parent_id,omitempty"`
+	CreatedAt     string                   `json:"created_at"`
+	Model         string                   `json:"model"`
+	ProviderModel string                   `json:"provider_model"`
+	ProviderName  string                   `json:"provider_name"`
+	Success       bool                     `json:"success"`
+	Error         string                   `json:"error,omitempty"`
+	Messages      []map[string]interface{} `json:"messages,omitempty"`
+	Tools         interface{}              `json:"tools,omitempty"`
+	Response      map[string]interface{}   `json:"response,omitempty"`
+	Request       string                   `json:"request,omitempty"` // 无法转换为对话时保留原始请求
+	RawResponse   string                   `json:"raw_response,omitempty"`
+	Usage         map[string]int           `json:"usage"`
+}
+
+// traceRequestFormat 根据 Trace 的调用方式和请求结构判断请求格式
+func traceRequestFormat(style string, req map[string]interface{}) string {
+	switch {
+	case style == "claude" || style == "gemini":
+		return style
+	case req["contents"] != nil:
+		return "gemini"
+	case req["system"] != nil || req["anthropic_version"] != nil:
+		return "claude"
+	}
+	if messages, ok := req["messages"].([]interface{}); ok {
+		// Claude 的内容块（tool_use/tool_result）
+		for _, item := range messages {
+			msg, _ := item.(map[string]interface{})
+			blocks, _ := msg["content"].([]interface{})
+			for _, b := range blocks {
+				block, _ := b.(map[string]interface{})
+				if t, _ := block["type"].(string); t == "tool_use" || t == "tool_result" {
+					return "claude"
+				}
+			}
+		}
+	}
+	return "openai"
+}
+
+// exportMessageFields 微调数据中保留的消息字段
+var exportMessageFields = []string{"role", "content", "name", "tool_calls", "tool_call_id", "function_call"}
+
+func cleanExportMessage(msg map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(exportMessageFields))
+	for _, key := range exportMessageFields {
+		if v, ok := msg[key]; ok && v != nil {
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// traceConversation 将 Trace 转换为 OpenAI 格式的请求消息、工具定义和助手回复，无法转换时返回 false
+func traceConversation(trace database.ConversationTrace) ([]map[string]interface{}, interface{}, map[string]interface{}, bool) {
+	var req map[string]interface{}
+	if err := json.Unmarshal([]byte(trace.RequestContent), &req); err != nil {
+		return nil, nil, nil, false
+	}
+
+	// 请求：Claude/Gemini 格式先转换为 OpenAI 格式
+	format := traceRequestFormat(trace.Style, req)
+	if format != "openai" {
+		adapter := adapters.GetAdapter(format + "-to-openai")
+		if adapter == nil {
+			return nil, nil, nil, false
+		}
+		converted, err := adapter.AdaptRequest(req, trace.Model)
+		if err != nil {
+			return nil, nil, nil, false
+		}
+		req = converted
+	}
+	items, ok := req["messages"].([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, nil, nil, false
+	}
+	messages := make([]map[string]interface{}, 0, len(items)+1)
+	for _, item := range items {
+		if msg, ok := item.(map[string]interface{}); ok {
+			messages = append(messages, cleanExportMessage(msg))
+		}
+	}
+
+	// 回复：流式记录的拼接结果，或 OpenAI/Claude/Gemini 的非流式响应
+	var resp map[string]interface{}
+	if err := json.Unmarshal([]byte(trace.ResponseContent), &resp); err != nil {
+		return messages, req["tools"], nil, true
+	}
+	if msg, ok := resp["message"].(map[string]interface{}); ok && resp["stream"] == true {
+		return messages, req["tools"], cleanExportMessage(msg), true
+	}
+	if resp["choices"] == nil && format != "openai" {
+		// openai-to-<格式> 适配器的 AdaptResponse 将该格式的响应转换为 OpenAI 响应
+		if adapter := adapters.GetAdapter("openai-to-" + format); adapter != nil {
+			if converted, err := adapter.AdaptResponse(resp); err == nil {
+				resp = converted
+			}
+		}
+	}
+	choices, _ := resp["choices"].([]interface{})
+	if len(choices) == 0 {
+		return messages, req["tools"], nil, true
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	msg, _ := choice["message"].(map[string]interface{})
+	if msg == nil {
+		return messages, req["tools"], nil, true
+	}
+	return messages, req["tools"], cleanExportMessage(msg), true
+}
+
+// hasAssistantReply 回复中有文本或工具调用
+func hasAssistantReply(msg map[string]interface{}) bool {
+	if msg == nil {
+		return false
+	}
+	if content, _ := msg["content"].(string); content != "" {
+		return true
+	}
+	switch calls := msg["tool_calls"].(type) {
+	case []interface{}:
+		return len(calls) > 0
+	case []map[string]interface{}:
+		return len(calls) > 0
+	}
+	return false
+}
+
+// exportMessageText 消息内容的纯文本（CSV 使用）
+func exportMessageText(msg map[string]interface{}) string {
+	if msg == nil {
+		return ""
+	}
+	switch content := msg["content"].(type) {
+	case string:
+		return content
+	case []interface{}:
+		var parts []string
+		for _, item := range content {
+			if part, ok := item.(map[string]interface{}); ok {
+				if text, ok := part["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// loadExportTraces 按条件读取 Trace（按 ID 升序）
+func (s *RouteService) loadExportTraces(opts TraceExportOptions) ([]database.ConversationTrace, error) {
+	filters := map[string]string{}
+	if opts.SessionID != "" {
+		filters["session_id"] = opts.SessionID
+	} else {
+		filters["start_time"] = opts.StartTime
+		filters["end_time"] = opts.EndTime
+	}
+	if opts.SuccessOnly || opts.Format == TraceExportFinetune {
+		filters["success"] = "true"
+	}
+
+	const pageSize = 500
+	var traces []database.ConversationTrace
+	for page := 1; len(traces) < maxTraceExport; page++ {
+		batch, total, err := s.GetAllTraces(page, pageSize, filters)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, batch...)
+		if len(batch) < pageSize || int64(len(traces)) >= total {
+			break
+		}
+	}
+	if len(traces) > maxTraceExport {
+		traces = traces[:maxTraceExport]
+	}
+	sort.Slice(traces, func(i, j int) bool { return traces[i].ID < traces[j].ID })
+	return traces, nil
+}
+
+// ExportTraces 导出 Trace，redactor 不为空时先脱敏请求/响应中的密钥
+func (s *RouteService) ExportTraces(opts TraceExportOptions, redactor *SecretRedactor) (*TraceExportResult, error) {
+	if opts.Format == "" {
+		opts.Format = TraceExportFinetune
+	}
+	switch opts.Format {
+	case TraceExportFinetune, TraceExportJSONL, TraceExportJSON, TraceExportCSV:
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", opts.Format)
+	}
+
+	traces, err := s.loadExportTraces(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TraceExportResult{Format: opts.Format}
+	var buf bytes.Buffer
+	var records []traceExportRecord
+	var csvWriter *csv.Writer
+	if opts.Format == TraceExportCSV {
+		csvWriter = csv.NewWriter(&buf)
+		csvWriter.Write([]string{"id", "session_id", "parent_id", "created_at", "model", "provider_model", "provider_name",
+			"success", "prompt_tokens", "completion_tokens", "user_message", "assistant_message", "error"})
+	}
+
+	for _, trace := range traces {
+		if redactor != nil {
+			trace.RequestContent = redactor.Redact(trace.RequestContent)
+			trace.ResponseContent = redactor.Redact(trace.ResponseContent)
+			trace.ErrorMessage = redactor.Redact(trace.ErrorMessage)
+		}
+		messages, tools, reply, ok := traceConversation(trace)
+
+		switch opts.Format {
+		case TraceExportFinetune:
+			if !ok || !hasAssistantReply(reply) {
+				result.Skipped++
+				continue
+			}
+			line := map[string]interface{}{"messages": append(messages, reply)}
+			if tools != nil {
+				line["tools"] = tools
+			}
+			data, err := json.Marshal(line)
+			if err != nil {
+				result.Skipped++
+				continue
+			}
+			buf.Write(data)
+			buf.WriteByte('\n')
+
+		case TraceExportCSV:
+			userText := ""
+			for i := len(messages) - 1; i >= 0; i-- {
+				if messages[i]["role"] == "user" {
+					userText = exportMessageText(messages[i])
+					break
+				}
+			}
+			if !ok {
+				userText = trace.RequestContent
+			}
+			csvWriter.Write([]string{
+				strconv.FormatInt(trace.ID, 10), trace.SessionID, strconv.FormatInt(trace.ParentID, 10),
+				trace.CreatedAt.Format("2006-01-02 15:04:05"), trace.Model, trace.ProviderModel, trace.ProviderName,
+				strconv.FormatBool(trace.Success), strconv.Itoa(trace.RequestTokens), strconv.Itoa(trace.ResponseTokens),
+				userText, exportMessageText(reply), trace.ErrorMessage,
+			})
+
+		default:
+			record := traceExportRecord{
+				ID:            trace.ID,
+				SessionID:     trace.SessionID,
+				ParentID:      trace.ParentID,
+				CreatedAt:     trace.CreatedAt.Format("2006-01-02 15:04:05"),
+				Model:         trace.Model,
+				ProviderModel: trace.ProviderModel,
+				ProviderName:  trace.ProviderName,
+				Success:       trace.Success,
+				Error:         trace.ErrorMessage,
+				Messages:      messages,
+				Tools:         tools,
+				Response:      reply,
+				Usage:         map[string]int{"prompt_tokens": trace.RequestTokens, "completion_tokens": trace.ResponseTokens},
+			}
+			if !ok {
+				record.Request = trace.RequestContent
+				result.Skipped++
+			}
+			if reply == nil {
+				record.RawResponse = trace.ResponseContent
+			}
+			if opts.Format == TraceExportJSON {
+				records = append(records, record)
+				break
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				result.Skipped++
+				continue
+			}
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+		result.Count++
+	}
+
+	switch opts.Format {
+	case TraceExportJSON:
+		if records == nil {
+			records = []traceExportRecord{}
+		}
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	case TraceExportCSV:
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return nil, err
+		}
+	}
+
+	ext := map[string]string{TraceExportFinetune: "jsonl", TraceExportJSONL: "jsonl", TraceExportJSON: "json", TraceExportCSV: "csv"}[opts.Format]
+	name := "traces"
+	if opts.SessionID != "" {
+		name += "-" + opts.SessionID
+	}
+	if opts.Format == TraceExportFinetune {
+		name += "-finetune"
+	}
+	result.Filename = name + "." + ext
+	result.Content = buf.String()
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const redactedText = "[REDACTED]"
+
+// minKnownSecretLen 已知密钥（路由 Key、本地 Key）按原文替换的最小长度，过短的值容易误伤正常内容
+const minKnownSecretLen = 8
+
+// 内置脱敏规则，第一个分组（如果有）为保留的前缀，其余部分替换为 [REDACTED]
+var builtinRedactionPatterns = []*regexp.Regexp{
+	// Authorization: Bearer xxx / "authorization": "Basic xxx"
+	regexp.MustCompile(`(?i)((?:proxy-)?authorization\\?"?\s*[:=]\s*\\?"?(?:bearer\s+|basic\s+|token\s+)?)[^"\\\s,;}]+`),
+	// "api_key": "xxx"、x-api-key: xxx、password=xxx 等键值对
+	regexp.MustCompile(`(?i)((?:api[_-]?key|x-goog-api-key|access[_-]?token|refresh[_-]?token|secret[_-]?key|client[_-]?secret|password|passwd)\\?"?\s*[:=]\s*\\?"?)[^"\\\s,;}&]+`),
+	// OpenAI / Anthropic / DeepSeek 等 sk- 开头的 Key
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`),
+	// Google API Key
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`),
+	// GitHub Token
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`),
+	// AWS Access Key ID
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	// Slack / HuggingFace Token
+	regexp.MustCompile(`\b(?:xox[abprs]-[A-Za-z0-9\-]{10,}|hf_[A-Za-z0-9]{30,})`),
+	// JWT
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`),
+	// PEM 私钥
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+}
+
+// SecretRedactor 按规则脱敏 Trace 内容中的密钥，只在读取时处理，数据库中保存原文
+type SecretRedactor struct {
+	patterns []*regexp.Regexp
+	known    []string
+}
+
+// NewSecretRedactor 创建脱敏器，extraPatterns 为配置中的自定义正则，knownSecrets 为需要按原文替换的密钥
+func NewSecretRedactor(extraPatterns []string, knownSecrets []string) *SecretRedactor {
+	r := &SecretRedactor{patterns: builtinRedactionPatterns}
+	for _, p := range extraPatterns {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log.Warnf("Ignoring invalid trace redaction pattern %q: %v", p, err)
+			continue
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	seen := make(map[string]bool)
+	for _, secret := range knownSecrets {
+		if len(secret) < minKnownSecretLen || seen[secret] {
+			continue
+		}
+		seen[secret] = true
+		r.known = append(r.known, secret)
+	}
+	// 先替换较长的密钥，避免一个密钥是另一个的前缀时只替换一部分
+	sort.Slice(r.known, func(i, j int) bool { return len(r.known[i]) > len(r.known[j]) })
+	return r
+}
+
+// Redact 返回脱敏后的文本
+func (r *SecretRedactor) Redact(text string) string {
+	if text == "" {
+		return text
+	}
+	for _, secret := range r.known {
+		text = strings.ReplaceAll(text, secret, redactedText)
+	}
+	for _, re := range r.patterns {
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			sub := re.FindStringSubmatchIndex(match)
+			if len(sub) >= 4 && sub[2] >= 0 {
+				return match[:sub[3]] + redactedText
+			}
+			return redactedText
+		})
+	}
+	return text
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"openai-router-go/internal/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Trace 会话识别：按以下顺序确定请求属于哪个对话
+//  1. 客户端通过 X-Session-Id 请求头指定的会话
+//  2. 对话指纹：请求的消息链以之前某个请求的完整消息链开头时，视为同一对话的下一轮
+//  3. 请求体中的 user / metadata.user_id，在会话超时时间内归入同一会话
+//  4. 以上都没有时，单轮请求作为新会话，其它请求按 IP + 超时时间分组（旧逻辑）
+
+// SessionIDHeader 客户端指定会话的请求头
+const SessionIDHeader = "X-Session-Id"
+
+const (
+	maxClientSessionLength = 256 // 客户端会话标识的最大长度
+	maxFingerprintLookup   = 64  // 查找上一轮对话时最多比较的消息链前缀数
+)
+
+// BindClientSession 记录请求携带的客户端会话标识，写入 Trace 时使用；请求结束后调用返回的函数清除
+func (s *ProxyService) BindClientSession(requestID, sessionID string) func() {
+	sessionID = strings.TrimSpace(sessionID)
+	if requestID == "" || sessionID == "" || len(sessionID) > maxClientSessionLength {
+		return func() {}
+	}
+	s.clientSessions.Store(requestID, sessionID)
+	return func() { s.clientSessions.Delete(requestID) }
+}
+
+// clientSession 返回请求绑定的客户端会话标识
+func (s *ProxyService) clientSession(requestID string) string {
+	if v, ok := s.clientSessions.Load(requestID); ok {
+		return v.(string)
+	}
+	return ""
+}
+
+func shortHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:8])
+}
+
+// stripCacheControl 移除 cache_control 标记（客户端每轮会移动缓存断点，不影响对话内容）
+func stripCacheControl(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			if k != "cache_control" {
+				out[k] = stripCacheControl(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = stripCacheControl(item)
+		}
+		return out
+	}
+	return v
+}
+
+// conversationFingerprints 计算请求消息链每个前缀的指纹（系统提示词作为第一条），
+// 最后一个为整个请求的指纹；支持 OpenAI、Claude、Gemini 和 Responses 格式，无法解析时返回 nil
+func conversationFingerprints(body string) []string {
+	var req map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return nil
+	}
+
+	var items []interface{}
+	for _, key := range []string{"system", "systemInstruction", "instructions"} {
+		if v, ok := req[key]; ok && v != nil && v != "" {
+			items = append(items, map[string]interface{}{"role": "system", "content": v})
+		}
+	}
+	found := false
+	for _, key := range []string{"messages", "contents", "input"} {
+		switch v := req[key].(type) {
+		case []interface{}:
+			items = append(items, v...)
+			found = true
+		case string:
+			items = append(items, map[string]interface{}{"role": "user", "content": v})
+			found = true
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	fingerprints := make([]string, 0, len(items))
+	hash := ""
+	for _, item := range items {
+		data, _ := json.Marshal(stripCacheControl(item))
+		hash = shortHash(hash + string(data))
+		fingerprints = append(fingerprints, hash)
+	}
+	return fingerprints
+}
+
+// requestUserKey 请求体中的用户标识（OpenAI 的 user、Claude 的 metadata.user_id）
+func requestUserKey(body string) string {
+	var req struct {
+		User     string `json:"user"`
+		Metadata struct {
+			UserID string `json:"user_id"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return ""
+	}
+	key := req.Metadata.UserID
+	if key == "" {
+		key = req.User
+	}
+	if len(key) > maxClientSessionLength {
+		return ""
+	}
+	return key
+}
+
+// traceMatch 消息链指纹匹配到的之前的 Trace
+type traceMatch struct {
+	id        int64
+	parentID  int64
+	sessionID string
+	exact     bool // 消息链完全相同（重试/重新生成）
+}
+
+// findPreviousTrace 查找消息链是当前请求前缀的最近一条 Trace，优先匹配最长的前缀；sessionID 不为空时只在该会话内查找
+func (s *RouteService) findPreviousTrace(fingerprints []string, sessionID string) (*traceMatch, bool) {
+	if len(fingerprints) == 0 {
+		return nil, false
+	}
+	if len(fingerprints) > maxFingerprintLookup {
+		fingerprints = fingerprints[len(fingerprints)-maxFingerprintLookup:]
+	}
+	rank := make(map[string]int, len(fingerprints))
+	args := make([]interface{}, 0, len(fingerprints)+1)
+	for i, fp := range fingerprints {
+		rank[fp] = i
+		args = append(args, fp)
+	}
+	query := `SELECT id, COALESCE(parent_id, 0), session_id, fingerprint FROM conversation_traces
+		WHERE fingerprint IN (?` + strings.Repeat(", ?", len(fingerprints)-1) + `)`
+	if sessionID != "" {
+		query += ` AND session_id = ?`
+		args = append(args, sessionID)
+	}
+	query += ` ORDER BY id DESC LIMIT 100`
+
+	rows, err := s.getTraceDB().Query(query, args...)
+	if err != nil {
+		log.Warnf("findPreviousTrace query error: %v", err)
+		return nil, false
+	}
+	defer rows.Close()
+
+	var best *traceMatch
+	bestRank := -1
+	for rows.Next() {
+		var m traceMatch
+		var fp string
+		if err := rows.Scan(&m.id, &m.parentID, &m.sessionID, &fp); err != nil {
+			continue
+		}
+		// 结果按 ID 倒序，相同前缀取最近一条
+		if r := rank[fp]; r > bestRank {
+			bestRank = r
+			m.exact = r == len(fingerprints)-1
+			best = &m
+		}
+	}
+	return best, best != nil
+}
+
+// latestClientSession 返回使用相同客户端标识、在超时时间内的最近会话
+func (s *RouteService) latestClientSession(clientKey string, timeoutMinutes int) (string, bool) {
+	var sessionID, createdAtRaw string
+	err := s.getTraceDB().QueryRow(`SELECT session_id, created_at FROM conversation_traces
+		WHERE client_key = ? ORDER BY id DESC LIMIT 1`, clientKey).Scan(&sessionID, &createdAtRaw)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Warnf("latestClientSession query error: %v", err)
+		}
+		return "", false
+	}
+	createdAt, err := parseTraceTime(createdAtRaw)
+	if err != nil || time.Since(createdAt) > time.Duration(timeoutMinutes)*time.Minute {
+		return "", false
+	}
+	return sessionID, true
+}
+
+// AssignTraceSession 为 Trace 确定会话、上一轮对话和指纹，clientSession 为 X-Session-Id 请求头
+func (s *RouteService) AssignTraceSession(trace *database.ConversationTrace, clientSession string, timeoutMinutes int) {
+	fingerprints := conversationFingerprints(trace.RequestContent)
+	if len(fingerprints) > 0 {
+		trace.Fingerprint = fingerprints[len(fingerprints)-1]
+	}
+	link := func(m *traceMatch) {
+		trace.SessionID = m.sessionID
+		trace.ParentID = m.id
+		if m.exact {
+			// 重试/重新生成：与被重试的请求接在同一轮之后
+			trace.ParentID = m.parentID
+		}
+	}
+
+	// 1. 客户端指定的会话
+	if clientSession != "" {
+		trace.ClientKey = "session:" + clientSession
+		trace.SessionID = "sess_c_" + shortHash(clientSession)
+		if m, ok := s.findPreviousTrace(fingerprints, trace.SessionID); ok {
+			link(m)
+		}
+		return
+	}
+
+	// 2. 对话指纹
+	if m, ok := s.findPreviousTrace(fingerprints, ""); ok {
+		link(m)
+		if userKey := requestUserKey(trace.RequestContent); userKey != "" {
+			trace.ClientKey = "user:" + userKey
+		}
+		return
+	}
+
+	// 3. 请求体中的用户标识
+	if userKey := requestUserKey(trace.RequestContent); userKey != "" {
+		trace.ClientKey = "user:" + userKey
+		if sessionID, ok := s.latestClientSession(trace.ClientKey, timeoutMinutes); ok {
+			trace.SessionID = sessionID
+		} else {
+			trace.SessionID = s.generateSessionId(trace.RemoteIP)
+		}
+		return
+	}
+
+	// 4. 单轮请求（只有系统提示词和一条消息）作为新对话，其它按 IP + 超时时间分组
+	if len(fingerprints) > 0 && len(fingerprints) <= 2 {
+		trace.SessionID = s.generateSessionId(trace.RemoteIP)
+		return
+	}
+	trace.SessionID = s.GetOrCreateSessionId(trace.RemoteIP, timeoutMinutes)
+}
@@ -0,0 +1,524 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"openai-router-go/internal/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 自动更新：从 GitHub Releases 检查新版本，下载当前平台的发布文件并校验 SHA-256，
+// 替换当前可执行文件后由界面提示重启（旧文件保留为 .old，下次启动时删除）
+
+const (
+	updateRepo          = "cniu6/anyproxyai"
+	updateCheckInterval = 24 * time.Hour
+	updateStartupDelay  = time.Minute // 启动后首次检查的延迟
+	updateAPITimeout    = 30 * time.Second
+	updateDownloadLimit = 10 * time.Minute
+
+	UpdateChannelStable = "stable"
+	UpdateChannelBeta   = "beta"
+)
+
+// UpdateStatus 更新检查和安装状态
+type UpdateStatus struct {
+	CurrentVersion string `json:"current_version"`
+	Channel        string `json:"channel"`
+	Available      bool   `json:"available"`
+	LatestVersion  string `json:"latest_version"`
+	Prerelease     bool   `json:"prerelease"`
+	ReleaseName    string `json:"release_name"`
+	ReleaseNotes   string `json:"release_notes"`
+	ReleaseURL     string `json:"release_url"`
+	PublishedAt    string `json:"published_at"`
+	AssetName      string `json:"asset_name"` // 当前平台的发布文件，为空表示该版本没有当前平台的文件
+	AssetSize      int64  `json:"asset_size"`
+	Checking       bool   `json:"checking"`
+	Installing     bool   `json:"installing"`
+	Downloaded     int64  `json:"downloaded"`
+	Installed      string `json:"installed"` // 已安装、等待重启的版本
+	LastCheckAt    string `json:"last_check_at"`
+	LastError      string `json:"last_error"`
+}
+
+// githubRelease GitHub Releases API 返回的发布信息
+type githubRelease struct {
+	TagName     string        `json:"tag_name"`
+	Name        string        `json:"name"`
+	Body        string        `json:"body"`
+	HTMLURL     string        `json:"html_url"`
+	Draft       bool          `json:"draft"`
+	Prerelease  bool          `json:"prerelease"`
+	PublishedAt string        `json:"published_at"`
+	Assets      []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	Digest             string `json:"digest"` // sha256:<hex>，GitHub 为发布文件计算的摘要
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Updater 按配置定期检查新版本，安装需要在界面中确认
+type Updater struct {
+	config     *config.Config
+	client     *http.Client
+	executable string // 启动时的可执行文件路径，替换后用于重启
+	stop       chan struct{}
+
+	mu          sync.Mutex
+	status      UpdateStatus
+	release     *githubRelease
+	onAvailable func(UpdateStatus)
+}
+
+func NewUpdater(cfg *config.Config) *Updater {
+	u := &Updater{
+		config: cfg,
+		client: &http.Client{Timeout: updateAPITimeout},
+		stop:   make(chan struct{}),
+	}
+	if exe, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		u.executable = exe
+		// 上次更新留下的旧文件
+		if err := os.Remove(exe + ".old"); err == nil {
+			log.Info("Removed executable left over from the last update")
+		}
+	}
+	u.status.CurrentVersion = config.Version
+	return u
+}
+
+// OnAvailable 设置发现新版本时的回调（定期检查时调用）
+func (u *Updater) OnAvailable(fn func(UpdateStatus)) {
+	u.mu.Lock()
+	u.onAvailable = fn
+	u.mu.Unlock()
+}
+
+// ExecutablePath 启动时的可执行文件路径（更新替换文件后仍指向新版本）
+func (u *Updater) ExecutablePath() string {
+	return u.executable
+}
+
+// Start 启动后台定期检查，配置在每次检查时重新读取
+func (u *Updater) Start() {
+	go func() {
+		timer := time.NewTimer(updateStartupDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-u.stop:
+				return
+			case <-timer.C:
+				if u.config.UpdateCheckEnabled {
+					u.checkAndNotify()
+				}
+				timer.Reset(updateCheckInterval)
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查
+func (u *Updater) Stop() {
+	close(u.stop)
+}
+
+// Status 获取最近一次检查和安装的状态
+func (u *Updater) Status() UpdateStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.status
+}
+
+func (u *Updater) checkAndNotify() {
+	status, err := u.Check()
+	if err != nil {
+		log.Warnf("Update check failed: %v", err)
+		return
+	}
+	if !status.Available || status.Installed == status.LatestVersion {
+		return
+	}
+	log.Infof("New version available: %s (current %s)", status.LatestVersion, status.CurrentVersion)
+	u.mu.Lock()
+	fn := u.onAvailable
+	u.mu.Unlock()
+	if fn != nil {
+		fn(status)
+	}
+}
+
+// Check 查询 GitHub 上所选通道的最新版本
+func (u *Updater) Check() (UpdateStatus, error) {
+	u.mu.Lock()
+	if u.status.Checking || u.status.Installing {
+		status := u.status
+		u.mu.Unlock()
+		return status, fmt.Errorf("an update check or installation is already running")
+	}
+	u.status.Checking = true
+	u.mu.Unlock()
+
+	channel := normalizeUpdateChannel(u.config.UpdateChannel)
+	release, err := u.latestRelease(channel)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.status.Checking = false
+	u.status.Channel = channel
+	u.status.LastCheckAt = time.Now().Format(time.RFC3339)
+	if err != nil {
+		u.status.LastError = err.Error()
+		return u.status, err
+	}
+	u.status.LastError = ""
+	u.release = release
+	u.status.Available = false
+	u.status.LatestVersion, u.status.ReleaseName, u.status.ReleaseNotes = "", "", ""
+	u.status.ReleaseURL, u.status.PublishedAt, u.status.AssetName = "", "", ""
+	u.status.Prerelease, u.status.AssetSize = false, 0
+	if release == nil {
+		return u.status, nil
+	}
+	u.status.LatestVersion = release.TagName
+	u.status.Available = compareVersions(release.TagName, config.Version) > 0
+	u.status.Prerelease = release.Prerelease
+	u.status.ReleaseName = release.Name
+	u.status.ReleaseNotes = release.Body
+	u.status.ReleaseURL = release.HTMLURL
+	u.status.PublishedAt = release.PublishedAt
+	if asset := findAsset(release, updateAssetName()); asset != nil {
+		u.status.AssetName = asset.Name
+		u.status.AssetSize = asset.Size
+	}
+	return u.status, nil
+}
+
+// Install 下载最近一次检查到的新版本，校验后替换当前可执行文件，需要重启生效
+func (u *Updater) Install() (UpdateStatus, error) {
+	u.mu.Lock()
+	release := u.release
+	if u.status.Checking || u.status.Installing {
+		status := u.status
+		u.mu.Unlock()
+		return status, fmt.Errorf("an update check or installation is already running")
+	}
+	if release == nil || !u.status.Available {
+		status := u.status
+		u.mu.Unlock()
+		return status, fmt.Errorf("no update available, check for updates first")
+	}
+	u.status.Installing = true
+	u.status.Downloaded = 0
+	u.mu.Unlock()
+
+	err := u.install(release)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.status.Installing = false
+	if err != nil {
+		u.status.LastError = err.Error()
+		log.Errorf("Failed to install update %s: %v", release.TagName, err)
+		return u.status, err
+	}
+	u.status.LastError = ""
+	u.status.Installed = release.TagName
+	log.Infof("Update %s installed, restart to use the new version", release.TagName)
+	return u.status, nil
+}
+
+func (u *Updater) install(release *githubRelease) error {
+	if u.executable == "" {
+		return fmt.Errorf("cannot determine the executable path")
+	}
+	asset := findAsset(release, updateAssetName())
+	if asset == nil {
+		return fmt.Errorf("release %s has no file for %s/%s", release.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	expected, err := u.assetChecksum(release, asset)
+	if err != nil {
+		return err
+	}
+
+	download := u.executable + ".download"
+	defer os.Remove(download)
+	if err := u.download(asset, download, expected); err != nil {
+		return err
+	}
+
+	next := u.executable + ".new"
+	defer os.Remove(next)
+	if strings.HasSuffix(asset.Name, ".zip") {
+		if err := extractAppBinary(download, next); err != nil {
+			return err
+		}
+	} else if err := os.Rename(download, next); err != nil {
+		return err
+	}
+	if err := os.Chmod(next, 0o755); err != nil {
+		return err
+	}
+
+	// 运行中的可执行文件不能覆盖（Windows），先改名再放入新文件
+	old := u.executable + ".old"
+	os.Remove(old)
+	if err := os.Rename(u.executable, old); err != nil {
+		return fmt.Errorf("failed to replace executable: %v", err)
+	}
+	if err := os.Rename(next, u.executable); err != nil {
+		os.Rename(old, u.executable)
+		return fmt.Errorf("failed to replace executable: %v", err)
+	}
+	return nil
+}
+
+// download 下载发布文件，校验大小和 SHA-256
+func (u *Updater) download(asset *githubAsset, path, expected string) error {
+	client := &http.Client{Timeout: updateDownloadLimit}
+	resp, err := client.Get(asset.BrowserDownloadURL)
+	if err != nil {
+		return fmt.Errorf("download failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(file, hash, &downloadProgress{u: u}), resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download failed: %v", err)
+	}
+	if asset.Size > 0 && n != asset.Size {
+		return fmt.Errorf("download incomplete: got %d of %d bytes", n, asset.Size)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", asset.Name, expected, actual)
+	}
+	return nil
+}
+
+// downloadProgress 记录已下载的字节数，界面轮询状态显示进度
+type downloadProgress struct {
+	u *Updater
+}
+
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	p.u.mu.Lock()
+	p.u.status.Downloaded += int64(len(b))
+	p.u.mu.Unlock()
+	return len(b), nil
+}
+
+// assetChecksum 获取发布文件的 SHA-256：优先使用 GitHub 计算的摘要，其次是发布中的校验文件
+func (u *Updater) assetChecksum(release *githubRelease, asset *githubAsset) (string, error) {
+	if digest, ok := strings.CutPrefix(asset.Digest, "sha256:"); ok && digest != "" {
+		return digest, nil
+	}
+	for _, name := range []string{asset.Name + ".sha256", "SHA256SUMS", "sha256sums.txt", "checksums.txt"} {
+		sums := findAsset(release, name)
+		if sums == nil {
+			continue
+		}
+		resp, err := u.client.Get(sums.BrowserDownloadURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to download %s: %v", name, err)
+		}
+		sum := parseChecksumFile(io.LimitReader(resp.Body, 1<<20), asset.Name)
+		resp.Body.Close()
+		if sum != "" {
+			return sum, nil
+		}
+	}
+	return "", fmt.Errorf("release %s publishes no SHA-256 checksum for %s, refusing to install", release.TagName, asset.Name)
+}
+
+// parseChecksumFile 解析 sha256sum 格式（"<hex>  <文件名>"）或只有摘要的校验文件
+func parseChecksumFile(r io.Reader, name string) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 1 && len(fields[0]) == 64 {
+			return fields[0]
+		}
+		if len(fields) >= 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// latestRelease 返回通道中版本号最高的发布，stable 通道忽略预发布版本
+func (u *Updater) latestRelease(channel string) (*githubRelease, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.github.com/repos/"+updateRepo+"/releases?per_page=30", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "AnyProxyAi/"+config.Version)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query releases: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("failed to query releases: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var releases []githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("invalid releases response: %v", err)
+	}
+
+	var latest *githubRelease
+	for i := range releases {
+		release := &releases[i]
+		if release.Draft || (release.Prerelease && channel != UpdateChannelBeta) {
+			continue
+		}
+		if _, ok := parseVersion(release.TagName); !ok {
+			continue
+		}
+		if latest == nil || compareVersions(release.TagName, latest.TagName) > 0 {
+			latest = release
+		}
+	}
+	return latest, nil
+}
+
+func normalizeUpdateChannel(channel string) string {
+	if strings.EqualFold(strings.TrimSpace(channel), UpdateChannelBeta) {
+		return UpdateChannelBeta
+	}
+	return UpdateChannelStable
+}
+
+// updateAssetName 当前平台的发布文件名（与构建流程生成的文件名一致）
+func updateAssetName() string {
+	name := fmt.Sprintf("anyproxyai-%s-%s", runtime.GOOS, runtime.GOARCH)
+	switch runtime.GOOS {
+	case "windows":
+		return name + ".exe"
+	case "darwin":
+		return name + ".zip"
+	}
+	return name
+}
+
+func findAsset(release *githubRelease, name string) *githubAsset {
+	for i := range release.Assets {
+		if release.Assets[i].Name == name {
+			return &release.Assets[i]
+		}
+	}
+	return nil
+}
+
+// extractAppBinary 从 macOS 发布的 .app 压缩包中取出可执行文件
+func extractAppBinary(zipPath, dest string) error {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("invalid update archive: %v", err)
+	}
+	defer archive.Close()
+	for _, f := range archive.File {
+		if !strings.HasSuffix(f.Name, ".app/Contents/MacOS/AnyProxyAi") {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, src); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+	return fmt.Errorf("update archive has no application binary")
+}
+
+// parseVersion 解析 v1.2.3 或 1.2.3-beta.1 格式的版本号
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	core, _, _ := strings.Cut(v, "-")
+	parts := strings.Split(core, ".")
+	nums := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		nums = append(nums, n)
+	}
+	return nums, len(nums) > 0
+}
+
+// compareVersions 比较两个版本号，正式版高于同号的预发布版本
+func compareVersions(a, b string) int {
+	na, _ := parseVersion(a)
+	nb, _ := parseVersion(b)
+	for i := 0; i < len(na) || i < len(nb); i++ {
+		var x, y int
+		if i < len(na) {
+			x = na[i]
+		}
+		if i < len(nb) {
+			y = nb[i]
+		}
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	_, preA, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	_, preB, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	case preA > preB:
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,13 @@
+package service
+
+import "net/http"
+
+// setUpstreamAuth 设置上游 Authorization：优先使用路由 Key，否则透传客户端的 Authorization
+// 本机路由（如 Ollama）不透传客户端 Authorization，避免把本地 API Key 发给本机其他服务
+func setUpstreamAuth(req *http.Request, apiURL, apiKey string, headers map[string]string) {
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	} else if auth := headers["Authorization"]; auth != "" && !isLocalURL(apiURL) {
+		req.Header.Set("Authorization", auth)
+	}
+}
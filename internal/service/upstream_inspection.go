@@ -0,0 +1,188 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 上游请求检查：登记了检查的请求（Playground、记录 Trace 的请求）在 doWithRetry 中记录每次发往上游的请求和原始响应，
+// 每次重试、换 Key 和 Fallback 各记录一条
+
+const inspectionBodyLimit = 256 << 10 // 每条记录保存的请求体/响应体最大字节数
+
+// UpstreamExchange 一次发往上游的请求及其原始响应（已脱敏）
+type UpstreamExchange struct {
+	Route             string            `json:"route"`
+	Method            string            `json:"method"`
+	URL               string            `json:"url"`
+	RequestHeaders    map[string]string `json:"request_headers"`
+	RequestBody       string            `json:"request_body"`
+	StatusCode        int               `json:"status_code"`
+	ResponseHeaders   map[string]string `json:"response_headers"`
+	ResponseBody      string            `json:"response_body"`
+	ResponseTruncated bool              `json:"response_truncated"`
+	Error             string            `json:"error"`
+	LatencyMs         int64             `json:"latency_ms"` // 收到响应头的耗时
+}
+
+// upstreamInspection 一个请求的上游记录
+type upstreamInspection struct {
+	mu        sync.Mutex
+	exchanges []*inspectedExchange
+}
+
+// inspectedExchange 正在记录的一次上游请求，响应体随客户端读取逐步追加
+type inspectedExchange struct {
+	UpstreamExchange
+	redactor *SecretRedactor
+	response strings.Builder
+}
+
+// snapshot 返回当前已记录的内容（已脱敏）
+func (i *upstreamInspection) snapshot() []UpstreamExchange {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	result := make([]UpstreamExchange, 0, len(i.exchanges))
+	for _, ex := range i.exchanges {
+		item := ex.UpstreamExchange
+		item.ResponseBody = ex.redactor.Redact(ex.response.String())
+		result = append(result, item)
+	}
+	return result
+}
+
+// inspectionStore 登记了检查的请求，按请求ID查找
+type inspectionStore struct {
+	requests sync.Map // requestID -> *upstreamInspection
+}
+
+// begin 登记请求，返回的记录在请求结束后仍可读取
+func (t *inspectionStore) begin(requestID string) *upstreamInspection {
+	inspection := &upstreamInspection{}
+	t.requests.Store(requestID, inspection)
+	return inspection
+}
+
+// end 取消登记
+func (t *inspectionStore) end(requestID string) {
+	t.requests.Delete(requestID)
+}
+
+// acquire 登记请求，已登记（Playground 发起的请求）时沿用已有记录；返回的 release 只移除自己登记的记录
+func (t *inspectionStore) acquire(requestID string) (release func()) {
+	if requestID == "" {
+		return func() {}
+	}
+	if _, loaded := t.requests.LoadOrStore(requestID, &upstreamInspection{}); loaded {
+		return func() {}
+	}
+	return func() { t.requests.Delete(requestID) }
+}
+
+// latest 返回请求最近一次发往上游的请求及响应，请求未登记或还没有发出请求时 ok 为 false
+func (t *inspectionStore) latest(requestID string) (exchange UpstreamExchange, ok bool) {
+	if requestID == "" {
+		return exchange, false
+	}
+	v, found := t.requests.Load(requestID)
+	if !found {
+		return exchange, false
+	}
+	exchanges := v.(*upstreamInspection).snapshot()
+	if len(exchanges) == 0 {
+		return exchange, false
+	}
+	return exchanges[len(exchanges)-1], true
+}
+
+// record 记录一次上游请求；请求未登记时原样返回响应，否则包装响应体以记录读到的数据
+func (t *inspectionStore) record(requestID, routeName string, req *http.Request, resp *http.Response, err error, start time.Time) *http.Response {
+	if requestID == "" {
+		return resp
+	}
+	v, ok := t.requests.Load(requestID)
+	if !ok {
+		return resp
+	}
+	inspection := v.(*upstreamInspection)
+
+	key := upstreamRequestKey(req)
+	redactor := NewSecretRedactor(nil, []string{key})
+	ex := &inspectedExchange{redactor: redactor}
+	ex.Route = routeName
+	ex.Method = req.Method
+	ex.URL = redactor.Redact(req.URL.String())
+	ex.RequestHeaders = inspectionHeaders(req.Header, redactor)
+	ex.LatencyMs = time.Since(start).Milliseconds()
+	if req.GetBody != nil {
+		if rc, bodyErr := req.GetBody(); bodyErr == nil {
+			body, _ := io.ReadAll(io.LimitReader(rc, inspectionBodyLimit+1))
+			rc.Close()
+			if len(body) > inspectionBodyLimit {
+				body = append(body[:inspectionBodyLimit], "..."...)
+			}
+			ex.RequestBody = redactor.Redact(string(body))
+		}
+	}
+	if err != nil {
+		ex.Error = redactor.Redact(err.Error())
+	} else {
+		ex.StatusCode = resp.StatusCode
+		ex.ResponseHeaders = inspectionHeaders(resp.Header, redactor)
+		resp.Body = &inspectedBody{ReadCloser: resp.Body, inspection: inspection, exchange: ex}
+	}
+
+	inspection.mu.Lock()
+	inspection.exchanges = append(inspection.exchanges, ex)
+	inspection.mu.Unlock()
+	return resp
+}
+
+// inspectionHeaders 将请求头合并为 map，Authorization 和包含 key 的头始终脱敏
+func inspectionHeaders(header http.Header, redactor *SecretRedactor) map[string]string {
+	names := make([]string, 0, len(header))
+	for k := range header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	result := make(map[string]string, len(names))
+	for _, k := range names {
+		lower := strings.ToLower(k)
+		if strings.Contains(lower, "authorization") || strings.Contains(lower, "key") || strings.Contains(lower, "cookie") {
+			result[k] = redactedText
+			continue
+		}
+		result[k] = redactor.Redact(strings.Join(header[k], ", "))
+	}
+	return result
+}
+
+// inspectedBody 记录读到的上游响应数据，超过上限的部分只读取不记录
+type inspectedBody struct {
+	io.ReadCloser
+	inspection *upstreamInspection
+	exchange   *inspectedExchange
+}
+
+func (b *inspectedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.inspection.mu.Lock()
+		if room := inspectionBodyLimit - b.exchange.response.Len(); room > 0 {
+			chunk := p[:n]
+			if len(chunk) > room {
+				chunk = chunk[:room]
+				b.exchange.ResponseTruncated = true
+			}
+			b.exchange.response.Write(chunk)
+		} else {
+			b.exchange.ResponseTruncated = true
+		}
+		b.inspection.mu.Unlock()
+	}
+	return n, err
+}
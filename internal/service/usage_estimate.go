@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"openai-router-go/internal/tokenizer"
+)
+
+const (
+	usageCaptureLimit  = 4 << 20 // 捕获的响应（或从流式响应中提取的文本）最大字节数，超出部分不参与估算
+	tokensPerMessage   = 4       // 每条消息的格式开销（角色、分隔符）
+	tokensReplyPriming = 3       // 回复的起始开销
+)
+
+// usageTextKeys 估算 token 时提取的文本字段（OpenAI、Claude、Gemini 格式通用）
+var usageTextKeys = map[string]bool{
+	"content":           true,
+	"text":              true,
+	"reasoning_content": true,
+	"reasoning":         true,
+	"thinking":          true,
+	"refusal":           true,
+	"arguments":         true,
+	"partial_json":      true,
+	"system":            true,
+	"prompt":            true,
+	"input":             true,
+	"instructions":      true,
+}
+
+// usageObjectKeys 以 JSON 形式计入的结构化字段（工具调用参数）
+var usageObjectKeys = map[string]bool{
+	"args":  true,
+	"input": true,
+}
+
+// usagePromptKeys 请求体中参与估算的顶层字段
+var usagePromptKeys = []string{"messages", "system", "contents", "systemInstruction", "system_instruction", "prompt", "input", "instructions"}
+
+// UsageCapture 记录一次 API 请求的请求体和返回给客户端的响应，上游没有返回 usage 时据此估算 token
+// 流式（SSE）响应逐行提取文本，只保存未完成的一行和提取出的文本，不保存原始事件
+type UsageCapture struct {
+	requestID string
+	body      []byte
+
+	mu       sync.Mutex
+	detected bool               // 已根据第一个非空字节判断响应类型
+	stream   bool               // SSE 响应
+	output   bytes.Buffer       // 非流式响应（JSON）原样保存
+	line     []byte             // SSE 响应中未完成的一行
+	skipLine bool               // 当前行超出上限，丢弃到行尾
+	text     strings.Builder    // 从 SSE 响应中提取的文本
+	pending  []RequestLogParams // 等待估算的日志
+}
+
+// Write 追加写给客户端的响应数据
+func (c *UsageCapture) Write(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.detected {
+		trimmed := bytes.TrimLeft(p, " \t\r\n")
+		if len(trimmed) == 0 {
+			return
+		}
+		c.detected = true
+		c.stream = trimmed[0] != '{' && trimmed[0] != '['
+	}
+	if !c.stream {
+		if remaining := usageCaptureLimit - c.output.Len(); remaining > 0 {
+			if len(p) > remaining {
+				p = p[:remaining]
+			}
+			c.output.Write(p)
+		}
+		return
+	}
+
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n')
+		chunk := p
+		if end >= 0 {
+			chunk = p[:end]
+		}
+		if !c.skipLine {
+			if len(c.line)+len(chunk) > usageCaptureLimit {
+				c.line, c.skipLine = c.line[:0], true
+			} else {
+				c.line = append(c.line, chunk...)
+			}
+		}
+		if end < 0 {
+			return
+		}
+		if !c.skipLine && c.text.Len() < usageCaptureLimit {
+			collectSSELineText(string(c.line), &c.text)
+		}
+		c.line, c.skipLine = c.line[:0], false
+		if cap(c.line) > 64<<10 {
+			c.line = nil // 不长期占用超长行的缓冲
+		}
+		p = p[end+1:]
+	}
+}
+
+// responseText 返回给客户端的响应中的文本
+func (c *UsageCapture) responseText() string {
+	if !c.stream {
+		return extractResponseText(c.output.Bytes())
+	}
+	if len(c.line) > 0 && !c.skipLine {
+		collectSSELineText(string(c.line), &c.text)
+	}
+	return c.text.String()
+}
+
+// BeginUsageCapture 开始记录请求，请求处理结束后必须调用 EndUsageCapture
+func (s *RouteService) BeginUsageCapture(requestID string, body []byte) *UsageCapture {
+	capture := &UsageCapture{requestID: requestID, body: body}
+	s.usageCaptures.Store(requestID, capture)
+	return capture
+}
+
+// EndUsageCapture 请求结束：为缺少 usage 的日志估算 token 后写入
+func (s *RouteService) EndUsageCapture(capture *UsageCapture) {
+	s.usageCaptures.Delete(capture.requestID)
+
+	capture.mu.Lock()
+	pending := capture.pending
+	capture.pending = nil
+	var output string
+	if len(pending) > 0 {
+		output = capture.responseText()
+	}
+	capture.mu.Unlock()
+
+	for _, params := range pending {
+		model := params.ProviderModel
+		if model == "" {
+			model = params.Model
+		}
+		if params.RequestTokens == 0 {
+			if n := estimatePromptTokens(model, capture.body); n > 0 {
+				params.RequestTokens = n
+				params.TokensEstimated = true
+			}
+		}
+		if params.ResponseTokens == 0 {
+			if n := tokenizer.Count(model, output); n > 0 {
+				params.ResponseTokens = n
+				params.TokensEstimated = true
+			}
+		}
+		if params.TokensEstimated {
+			params.TotalTokens = params.RequestTokens + params.ResponseTokens
+		}
+		s.live.publish(params)
+		s.logs.enqueue(params)
+	}
+}
+
+// deferForEstimate 成功但缺少 usage 的日志暂存到请求结束时估算，返回 false 表示无需估算
+func (s *RouteService) deferForEstimate(params RequestLogParams) bool {
+	if !params.Success || params.RequestID == "" {
+		return false
+	}
+	if params.RequestTokens > 0 && params.ResponseTokens > 0 {
+		return false
+	}
+	// 只返回了总数时保留上游的值
+	if params.TotalTokens > params.RequestTokens+params.ResponseTokens {
+		return false
+	}
+	value, ok := s.usageCaptures.Load(params.RequestID)
+	if !ok {
+		return false
+	}
+	capture := value.(*UsageCapture)
+	capture.mu.Lock()
+	capture.pending = append(capture.pending, params)
+	capture.mu.Unlock()
+	return true
+}
+
+// estimatePromptTokens 根据请求体中的消息、系统提示词和工具定义估算输入 token
+func estimatePromptTokens(model string, body []byte) int {
+	var reqData map[string]interface{}
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		return 0
+	}
+
+	var sb strings.Builder
+	for _, key := range usagePromptKeys {
+		if value, ok := reqData[key]; ok {
+			collectUsageText(value, true, &sb)
+		}
+	}
+	for _, key := range []string{"tools", "functions"} {
+		if tools, ok := reqData[key]; ok {
+			data, _ := json.Marshal(tools)
+			sb.WriteString(string(data))
+			sb.WriteByte('\n')
+		}
+	}
+	if sb.Len() == 0 {
+		return 0
+	}
+
+	tokens := tokenizer.Count(model, sb.String())
+	for _, key := range []string{"messages", "contents"} {
+		if messages, ok := reqData[key].([]interface{}); ok {
+			tokens += len(messages) * tokensPerMessage
+		}
+	}
+	return tokens + tokensReplyPriming
+}
+
+// extractResponseText 从返回给客户端的响应中提取文本（支持 JSON、JSON 数组和 SSE）
+func extractResponseText(output []byte) string {
+	trimmed := bytes.TrimSpace(output)
+	if len(trimmed) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		var data interface{}
+		if err := json.Unmarshal(trimmed, &data); err == nil {
+			collectUsageText(data, false, &sb)
+			return sb.String()
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 64*1024), usageCaptureLimit)
+	for scanner.Scan() {
+		collectSSELineText(scanner.Text(), &sb)
+	}
+	return sb.String()
+}
+
+// collectSSELineText 提取一行 SSE data: 中的文本，其它行忽略
+func collectSSELineText(line string, sb *strings.Builder) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "data:") {
+		return
+	}
+	payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	if payload == "" || payload == "[DONE]" {
+		return
+	}
+	var chunk interface{}
+	if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+		return
+	}
+	collectUsageText(chunk, false, sb)
+}
+
+// collectUsageText 递归提取文本字段，inText 表示当前值位于文本字段下
+func collectUsageText(value interface{}, inText bool, sb *strings.Builder) {
+	switch v := value.(type) {
+	case string:
+		if inText && v != "" {
+			sb.WriteString(v)
+			sb.WriteByte('\n')
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectUsageText(item, inText, sb)
+		}
+	case map[string]interface{}:
+		for key, child := range v {
+			if obj, ok := child.(map[string]interface{}); ok && usageObjectKeys[key] {
+				data, _ := json.Marshal(obj)
+				sb.WriteString(string(data))
+				sb.WriteByte('\n')
+				continue
+			}
+			collectUsageText(child, usageTextKeys[key], sb)
+		}
+	}
+}
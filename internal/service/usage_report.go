@@ -0,0 +1,631 @@
+package service
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"openai-router-go/internal/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 用量报告：按 daily/weekly 定时汇总请求日志（包括已聚合为小时统计的部分），
+// 通过通知 Webhook 和邮件发送；配置在每次检查时重新读取，设置页修改后无需重启
+
+const (
+	reportCheckInterval  = time.Minute
+	reportTopModels      = 10
+	reportTopErrors      = 5
+	reportSpikeMinFailed = 5   // 一小时内至少失败这么多次才算错误突增
+	reportSpikeMinRate   = 0.2 // 且失败率不低于 20%
+	reportSMTPTimeout    = 30 * time.Second
+)
+
+// DefaultReportTemplate 默认报告模板
+const DefaultReportTemplate = `AnyProxyAi {{.Period}} usage report: {{.Start}}{{if ne .Start .End}} ~ {{.End}}{{end}}
+
+Requests: {{num .Requests}} (success rate {{printf "%.1f" .SuccessRate}}%, {{num .Failed}} failed){{if .PrevRequests}}, {{change .RequestsChange}} vs previous period{{end}}
+Tokens: {{num .TotalTokens}} (prompt {{num .PromptTokens}}, completion {{num .CompletionTokens}}){{if .PrevTotalTokens}}, {{change .TokensChange}} vs previous period{{end}}
+{{- if .Priced}}
+Estimated cost: ${{printf "%.2f" .Cost}}
+{{- end}}
+{{if .TopModels}}
+Top models:
+{{- range .TopModels}}
+- {{.Model}}: {{num .Requests}} requests, {{num .TotalTokens}} tokens{{if .Priced}}, ${{printf "%.2f" .Cost}}{{end}}{{if .Failed}}, {{num .Failed}} failed{{end}}
+{{- end}}
+{{end}}
+{{- if .ErrorSpikes}}
+Error spikes:
+{{- range .ErrorSpikes}}
+- {{.Hour}}: {{num .Failed}} of {{num .Requests}} requests failed ({{printf "%.0f" .ErrorRate}}%)
+{{- end}}
+{{end}}
+{{- if .TopErrors}}
+Top errors:
+{{- range .TopErrors}}
+- {{num .Count}}x {{.Message}}
+{{- end}}
+{{end}}`
+
+// UsageReportModel 报告中单个模型的用量
+type UsageReportModel struct {
+	Model            string  `json:"model"`
+	Requests         int64   `json:"requests"`
+	Failed           int64   `json:"failed"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+	Priced           bool    `json:"priced"` // 配置了价格或上游返回了实际费用
+
+	reportedPrompt     int64 // 已有实际费用的 token 数，不再按价格估算
+	reportedCompletion int64
+}
+
+// UsageReportSpike 失败率异常的小时
+type UsageReportSpike struct {
+	Hour      string  `json:"hour"` // 2006-01-02 15:00
+	Requests  int64   `json:"requests"`
+	Failed    int64   `json:"failed"`
+	ErrorRate float64 `json:"error_rate"` // 百分比
+}
+
+// UsageReportError 出现次数最多的错误
+type UsageReportError struct {
+	Message string `json:"message"`
+	Count   int64  `json:"count"`
+}
+
+// UsageReport 一个周期的用量汇总
+type UsageReport struct {
+	Period           string             `json:"period"` // daily, weekly
+	Start            string             `json:"start"`  // 第一天 (2006-01-02)
+	End              string             `json:"end"`    // 最后一天（包含）
+	Requests         int64              `json:"requests"`
+	Success          int64              `json:"success"`
+	Failed           int64              `json:"failed"`
+	SuccessRate      float64            `json:"success_rate"`
+	PromptTokens     int64              `json:"prompt_tokens"`
+	CompletionTokens int64              `json:"completion_tokens"`
+	TotalTokens      int64              `json:"total_tokens"`
+	Cost             float64            `json:"cost"`   // 上游返回的实际费用（如 OpenRouter）加上按 model_prices 估算的费用，未配置价格的模型只计实际费用
+	Priced           bool               `json:"priced"` // 至少一个模型配置了价格或有实际费用
+	PrevRequests     int64              `json:"prev_requests"`
+	PrevTotalTokens  int64              `json:"prev_total_tokens"`
+	RequestsChange   float64            `json:"requests_change"` // 与上一周期相比的变化百分比
+	TokensChange     float64            `json:"tokens_change"`
+	TopModels        []UsageReportModel `json:"top_models"`
+	ErrorSpikes      []UsageReportSpike `json:"error_spikes"`
+	TopErrors        []UsageReportError `json:"top_errors"`
+}
+
+// usageBucket 一个小时内一个模型的用量
+type usageBucket struct {
+	date             string
+	hour             int
+	model            string
+	requests         int64
+	success          int64
+	promptTokens     int64
+	completionTokens int64
+	totalTokens      int64
+
+	reportedCost       float64 // 上游返回的实际费用
+	reportedPrompt     int64   // 有实际费用的请求的 token 数，不再按价格估算
+	reportedCompletion int64
+}
+
+// usageBuckets 读取 [start, end) 之间按小时和模型汇总的用量，包括已聚合到 hourly_stats 的历史数据
+func (s *RouteService) usageBuckets(start, end time.Time) ([]usageBucket, error) {
+	const layout = "2006-01-02 15:04:05"
+	rows, err := s.db.Query(`
+		SELECT substr(created_at, 1, 10) AS day, CAST(substr(created_at, 12, 2) AS INTEGER) AS hour, model,
+			COUNT(*), COALESCE(SUM(success), 0),
+			COALESCE(SUM(request_tokens), 0), COALESCE(SUM(response_tokens), 0), COALESCE(SUM(total_tokens), 0),
+			COALESCE(SUM(cost), 0),
+			COALESCE(SUM(CASE WHEN cost > 0 THEN request_tokens ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN cost > 0 THEN response_tokens ELSE 0 END), 0)
+		FROM request_logs
+		WHERE created_at >= ? AND created_at < ?
+		GROUP BY day, hour, model
+		UNION ALL
+		SELECT date, hour, model, request_count, success_count, request_tokens, response_tokens, total_tokens,
+			COALESCE(reported_cost, 0), COALESCE(reported_request_tokens, 0), COALESCE(reported_response_tokens, 0)
+		FROM hourly_stats
+		WHERE date >= ? AND date < ?`,
+		start.Format(layout), end.Format(layout), start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []usageBucket
+	for rows.Next() {
+		var b usageBucket
+		if err := rows.Scan(&b.date, &b.hour, &b.model, &b.requests, &b.success, &b.promptTokens, &b.completionTokens, &b.totalTokens,
+			&b.reportedCost, &b.reportedPrompt, &b.reportedCompletion); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// topErrors 周期内出现次数最多的错误信息（只有未聚合的请求日志有错误信息）
+func (s *RouteService) topErrors(start, end time.Time, limit int) ([]UsageReportError, error) {
+	const layout = "2006-01-02 15:04:05"
+	rows, err := s.db.Query(`
+		SELECT substr(COALESCE(error_message, ''), 1, 160) AS message, COUNT(*) AS cnt
+		FROM request_logs
+		WHERE success = 0 AND created_at >= ? AND created_at < ?
+		GROUP BY message ORDER BY cnt DESC LIMIT ?`,
+		start.Format(layout), end.Format(layout), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var errs []UsageReportError
+	for rows.Next() {
+		var e UsageReportError
+		if err := rows.Scan(&e.Message, &e.Count); err != nil {
+			return nil, err
+		}
+		if e.Message == "" {
+			e.Message = "(no error message)"
+		}
+		errs = append(errs, e)
+	}
+	return errs, rows.Err()
+}
+
+// reportPeriod 在 scheduledAt 发送的报告覆盖的时间范围 [start, end)：daily 为前一天，weekly 为前 7 天
+func reportPeriod(schedule string, scheduledAt time.Time) (time.Time, time.Time) {
+	end := time.Date(scheduledAt.Year(), scheduledAt.Month(), scheduledAt.Day(), 0, 0, 0, 0, scheduledAt.Location())
+	if schedule == "weekly" {
+		return end.AddDate(0, 0, -7), end
+	}
+	return end.AddDate(0, 0, -1), end
+}
+
+// BuildUsageReport 汇总 [start, end) 的用量，并与之前同样长度的周期比较
+func (s *RouteService) BuildUsageReport(schedule string, start, end time.Time, cfg *config.Config) (*UsageReport, error) {
+	buckets, err := s.usageBuckets(start, end)
+	if err != nil {
+		return nil, err
+	}
+	report := &UsageReport{
+		Period: schedule,
+		Start:  start.Format("2006-01-02"),
+		End:    end.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+
+	models := make(map[string]*UsageReportModel)
+	hours := make(map[string]*UsageReportSpike)
+	for _, b := range buckets {
+		report.Requests += b.requests
+		report.Success += b.success
+		report.PromptTokens += b.promptTokens
+		report.CompletionTokens += b.completionTokens
+		report.TotalTokens += b.totalTokens
+
+		m := models[b.model]
+		if m == nil {
+			m = &UsageReportModel{Model: b.model}
+			models[b.model] = m
+		}
+		m.Requests += b.requests
+		m.Failed += b.requests - b.success
+		m.PromptTokens += b.promptTokens
+		m.CompletionTokens += b.completionTokens
+		m.TotalTokens += b.totalTokens
+		m.Cost += b.reportedCost
+		m.reportedPrompt += b.reportedPrompt
+		m.reportedCompletion += b.reportedCompletion
+
+		key := fmt.Sprintf("%s %02d:00", b.date, b.hour)
+		h := hours[key]
+		if h == nil {
+			h = &UsageReportSpike{Hour: key}
+			hours[key] = h
+		}
+		h.Requests += b.requests
+		h.Failed += b.requests - b.success
+	}
+	report.Failed = report.Requests - report.Success
+	if report.Requests > 0 {
+		report.SuccessRate = float64(report.Success) * 100 / float64(report.Requests)
+	}
+
+	for _, m := range models {
+		m.Priced = m.Cost > 0
+		if price, ok := cfg.PriceFor(m.Model); ok {
+			m.Priced = true
+			m.Cost += (float64(m.PromptTokens-m.reportedPrompt)*price.Input + float64(m.CompletionTokens-m.reportedCompletion)*price.Output) / 1e6
+		}
+		if m.Priced {
+			report.Cost += m.Cost
+			report.Priced = true
+		}
+		report.TopModels = append(report.TopModels, *m)
+	}
+	sort.Slice(report.TopModels, func(i, j int) bool {
+		a, b := report.TopModels[i], report.TopModels[j]
+		if a.TotalTokens != b.TotalTokens {
+			return a.TotalTokens > b.TotalTokens
+		}
+		return a.Requests > b.Requests
+	})
+	if len(report.TopModels) > reportTopModels {
+		report.TopModels = report.TopModels[:reportTopModels]
+	}
+
+	for _, h := range hours {
+		if h.Failed < reportSpikeMinFailed {
+			continue
+		}
+		h.ErrorRate = float64(h.Failed) * 100 / float64(h.Requests)
+		if h.ErrorRate >= reportSpikeMinRate*100 {
+			report.ErrorSpikes = append(report.ErrorSpikes, *h)
+		}
+	}
+	sort.Slice(report.ErrorSpikes, func(i, j int) bool {
+		return report.ErrorSpikes[i].Failed > report.ErrorSpikes[j].Failed
+	})
+	if len(report.ErrorSpikes) > reportTopErrors {
+		report.ErrorSpikes = report.ErrorSpikes[:reportTopErrors]
+	}
+
+	if report.TopErrors, err = s.topErrors(start, end, reportTopErrors); err != nil {
+		log.Warnf("Usage report: failed to load top errors: %v", err)
+	}
+
+	// 上一周期
+	prevStart := start.Add(-end.Sub(start))
+	if prev, err := s.usageBuckets(prevStart, start); err == nil {
+		for _, b := range prev {
+			report.PrevRequests += b.requests
+			report.PrevTotalTokens += b.totalTokens
+		}
+		report.RequestsChange = percentChange(report.Requests, report.PrevRequests)
+		report.TokensChange = percentChange(report.TotalTokens, report.PrevTotalTokens)
+	}
+	return report, nil
+}
+
+func percentChange(current, previous int64) float64 {
+	if previous == 0 {
+		return 0
+	}
+	return float64(current-previous) * 100 / float64(previous)
+}
+
+// formatNumber 千分位格式的整数
+func formatNumber(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	if neg {
+		return "-" + b.String()
+	}
+	return b.String()
+}
+
+var reportFuncs = template.FuncMap{
+	"num": formatNumber,
+	"change": func(v float64) string {
+		return fmt.Sprintf("%+.1f%%", v)
+	},
+}
+
+// ValidateReportTemplate 检查报告模板能否解析
+func ValidateReportTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	_, err := template.New("report").Funcs(reportFuncs).Parse(text)
+	return err
+}
+
+// RenderUsageReport 用模板渲染报告，模板为空时使用默认模板
+func RenderUsageReport(report *UsageReport, text string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultReportTemplate
+	}
+	tmpl, err := template.New("report").Funcs(reportFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// ReportStatus 最近一次发送的结果
+type ReportStatus struct {
+	Running    bool   `json:"running"`
+	LastSentAt string `json:"last_sent_at"`
+	LastPeriod string `json:"last_period"`
+	LastError  string `json:"last_error"`
+}
+
+// ReportScheduler 按配置定时生成并发送用量报告
+type ReportScheduler struct {
+	rs       *RouteService
+	notifier *Notifier
+	config   *config.Config
+	stop     chan struct{}
+
+	mu       sync.Mutex
+	running  bool
+	baseline time.Time // 未记录发送时间时，启动（或开启报告）后的第一个计划时间之前不发送
+	status   ReportStatus
+}
+
+func NewReportScheduler(rs *RouteService, notifier *Notifier, cfg *config.Config) *ReportScheduler {
+	return &ReportScheduler{rs: rs, notifier: notifier, config: cfg, stop: make(chan struct{})}
+}
+
+// Start 启动后台调度
+func (r *ReportScheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(reportCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if scheduledAt, ok := r.due(time.Now()); ok {
+					r.send(scheduledAt, true)
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止后台调度
+func (r *ReportScheduler) Stop() {
+	close(r.stop)
+}
+
+// Status 获取最近一次发送的结果
+func (r *ReportScheduler) Status() ReportStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.status
+	status.Running = r.running
+	return status
+}
+
+// lastScheduled 不晚于 now 的最近一个计划发送时间
+func (r *ReportScheduler) lastScheduled(now time.Time) time.Time {
+	hour := r.config.ReportHour
+	if hour < 0 || hour > 23 {
+		hour = 9
+	}
+	t := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if r.config.ReportSchedule == "weekly" {
+		weekday := time.Weekday(((r.config.ReportWeekday % 7) + 7) % 7)
+		t = t.AddDate(0, 0, -((int(t.Weekday()) - int(weekday) + 7) % 7))
+		if t.After(now) {
+			t = t.AddDate(0, 0, -7)
+		}
+		return t
+	}
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// due 判断是否到达发送时间，返回本次报告的计划时间
+func (r *ReportScheduler) due(now time.Time) (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.config.ReportEnabled {
+		r.baseline = time.Time{}
+		return time.Time{}, false
+	}
+	if r.running {
+		return time.Time{}, false
+	}
+	scheduledAt := r.lastScheduled(now)
+	if last, err := time.ParseInLocation("2006-01-02 15:04:05", r.config.ReportLastSentAt, now.Location()); err == nil {
+		return scheduledAt, scheduledAt.After(last)
+	}
+	// 从未发送过：等到下一个计划时间，避免开启后立即补发
+	if r.baseline.IsZero() {
+		r.baseline = scheduledAt
+	}
+	return scheduledAt, scheduledAt.After(r.baseline)
+}
+
+// Preview 生成最近一个周期的报告（不发送）
+func (r *ReportScheduler) Preview() (string, error) {
+	report, err := r.build(r.lastScheduled(time.Now()))
+	if err != nil {
+		return "", err
+	}
+	return RenderUsageReport(report, r.config.ReportTemplate)
+}
+
+// SendNow 立即发送最近一个周期的报告（不影响定时发送）
+func (r *ReportScheduler) SendNow() ReportStatus {
+	r.send(r.lastScheduled(time.Now()), false)
+	return r.Status()
+}
+
+func (r *ReportScheduler) build(scheduledAt time.Time) (*UsageReport, error) {
+	schedule := r.config.ReportSchedule
+	if schedule != "weekly" {
+		schedule = "daily"
+	}
+	start, end := reportPeriod(schedule, scheduledAt)
+	return r.rs.BuildUsageReport(schedule, start, end, r.config)
+}
+
+// send 生成并发送报告，scheduled 为 true 时记录发送时间
+func (r *ReportScheduler) send(scheduledAt time.Time, scheduled bool) {
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		return
+	}
+	r.running = true
+	r.mu.Unlock()
+
+	status := ReportStatus{LastSentAt: time.Now().Format("2006-01-02 15:04:05")}
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.status = status
+		r.mu.Unlock()
+	}()
+
+	report, err := r.build(scheduledAt)
+	if err != nil {
+		status.LastError = "build: " + err.Error()
+		log.Warnf("Usage report: %s", status.LastError)
+		return
+	}
+	status.LastPeriod = report.Start
+	if report.End != report.Start {
+		status.LastPeriod += " ~ " + report.End
+	}
+	text, err := RenderUsageReport(report, r.config.ReportTemplate)
+	if err != nil {
+		status.LastError = "template: " + err.Error()
+		log.Warnf("Usage report: %s", status.LastError)
+		return
+	}
+
+	title := fmt.Sprintf("AnyProxyAi %s usage report (%s)", report.Period, status.LastPeriod)
+	var failures []string
+	if r.config.ReportWebhooks && r.notifier != nil {
+		errs := r.notifier.Deliver(Notification{
+			Event:   NotifyUsageReport,
+			Level:   "info",
+			Title:   title,
+			Message: text,
+			Data:    report,
+			Time:    status.LastSentAt,
+		})
+		for name, e := range errs {
+			failures = append(failures, fmt.Sprintf("webhook %s: %s", name, e))
+		}
+	}
+	if r.config.ReportEmail.Enabled {
+		if err := sendReportEmail(r.config.ReportEmail, title, text); err != nil {
+			failures = append(failures, "email: "+err.Error())
+		}
+	}
+	sort.Strings(failures)
+	status.LastError = strings.Join(failures, "; ")
+
+	if scheduled {
+		r.config.ReportLastSentAt = scheduledAt.Format("2006-01-02 15:04:05")
+		if err := r.config.Save(); err != nil {
+			log.Warnf("Usage report: failed to save last sent time: %v", err)
+		}
+	}
+	if status.LastError != "" {
+		log.Warnf("Usage report for %s sent with errors: %s", status.LastPeriod, status.LastError)
+	} else {
+		log.Infof("Usage report for %s sent", status.LastPeriod)
+	}
+}
+
+// sendReportEmail 通过 SMTP 发送纯文本邮件：465 端口使用 TLS 连接，其它端口在服务器支持时使用 STARTTLS
+func sendReportEmail(cfg config.ReportEmail, subject, body string) error {
+	if cfg.SMTPHost == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return fmt.Errorf("smtp host, from and to are required")
+	}
+	port := cfg.SMTPPort
+	if port <= 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: cfg.SMTPHost}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: reportSMTPTimeout}
+	if port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(reportSMTPTimeout))
+	client, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPHost)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,98 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// JSONArrayWriter 将 SSE 输出转换为 JSON 数组流（Gemini streamGenerateContent 不带 alt=sse 时的格式）
+// 每个 data 事件成为数组的一个元素，元素之间以 ",\r\n" 分隔，Close 时写入结尾的 "]"
+type JSONArrayWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+	line    []byte // 尚未读到换行的部分
+	count   int
+	err     error
+
+	// Element 可选，写入前转换每个元素，返回 nil 表示丢弃
+	Element func(data []byte) []byte
+}
+
+// NewJSONArrayWriter 创建 JSON 数组写入器，flusher 可以为 nil
+func NewJSONArrayWriter(w io.Writer, flusher http.Flusher) *JSONArrayWriter {
+	return &JSONArrayWriter{w: w, flusher: flusher}
+}
+
+// Write 接收 SSE 格式的数据，每读到一个完整的 data 行就输出一个数组元素
+func (a *JSONArrayWriter) Write(p []byte) (int, error) {
+	if a.err != nil {
+		return 0, a.err
+	}
+	a.line = append(a.line, p...)
+	for {
+		idx := bytes.IndexByte(a.line, '\n')
+		if idx < 0 {
+			break
+		}
+		line := bytes.TrimSuffix(a.line[:idx], []byte("\r"))
+		a.line = a.line[idx+1:]
+		if err := a.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// writeLine 处理一行 SSE，只有 data 行中的 JSON 会输出
+func (a *JSONArrayWriter) writeLine(line []byte) error {
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return nil
+	}
+	data := bytes.TrimSpace(line[len("data:"):])
+	if len(data) == 0 || string(data) == DoneData || !json.Valid(data) {
+		return nil
+	}
+	if a.Element != nil {
+		if data = a.Element(data); data == nil {
+			return nil
+		}
+	}
+
+	sep := ",\r\n"
+	if a.count == 0 {
+		sep = "["
+	}
+	a.count++
+	return a.write(append([]byte(sep), data...))
+}
+
+// Close 结束数组，没有任何元素时输出 "[]"
+func (a *JSONArrayWriter) Close() error {
+	if len(a.line) > 0 {
+		line := a.line
+		a.line = nil
+		if err := a.writeLine(bytes.TrimSuffix(line, []byte("\r"))); err != nil {
+			return err
+		}
+	}
+	if a.count == 0 {
+		return a.write([]byte("[]"))
+	}
+	return a.write([]byte("]"))
+}
+
+func (a *JSONArrayWriter) write(p []byte) error {
+	if a.err != nil {
+		return a.err
+	}
+	if _, err := a.w.Write(p); err != nil {
+		a.err = err
+		return err
+	}
+	if a.flusher != nil {
+		a.flusher.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONArrayWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		writes  []string
+		element func(data []byte) []byte
+		want    string
+	}{
+		{
+			name:   "events split across writes",
+			writes: []string{"data: {\"a\"", ":1}\n\nda", "ta: {\"a\":2}\r\n", "\r\n"},
+			want:   "[{\"a\":1},\r\n{\"a\":2}]",
+		},
+		{
+			name:   "done and non-data lines dropped",
+			writes: []string{"event: ping\n: comment\ndata: {\"a\":1}\n\ndata: [DONE]\n\n"},
+			want:   "[{\"a\":1}]",
+		},
+		{
+			name:   "invalid json dropped",
+			writes: []string{"data: oops\n\n"},
+			want:   "[]",
+		},
+		{
+			name:   "last line without newline",
+			writes: []string{"data: {\"a\":1}"},
+			want:   "[{\"a\":1}]",
+		},
+		{
+			name:   "element filter",
+			writes: []string{"data: {\"a\":1}\n\ndata: {\"skip\":true}\n\n"},
+			element: func(data []byte) []byte {
+				if bytes.Contains(data, []byte("skip")) {
+					return nil
+				}
+				return data
+			},
+			want: "[{\"a\":1}]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			a := NewJSONArrayWriter(&buf, nil)
+			a.Element = tt.element
+			for _, p := range tt.writes {
+				if _, err := a.Write([]byte(p)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := a.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+			if !json.Valid(buf.Bytes()) {
+				t.Errorf("output is not valid JSON: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestJSONArrayWriterFromSSEWriter(t *testing.T) {
+	// 服务层通过 Writer 输出 SSE，JSON 数组模式下由 JSONArrayWriter 转换
+	var buf bytes.Buffer
+	a := NewJSONArrayWriter(&buf, nil)
+	w := NewWriter(a, nil)
+	w.Send("", map[string]int{"a": 1})
+	w.Send("", map[string]int{"a": 2})
+	w.Done()
+	a.Close()
+
+	var got []map[string]int
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON array %q: %v", buf.String(), err)
+	}
+	if len(got) != 2 || got[0]["a"] != 1 || got[1]["a"] != 2 {
+		t.Errorf("got %v", got)
+	}
+}
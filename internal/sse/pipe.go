@@ -0,0 +1,64 @@
+package sse
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNoTransformer Pipe 未指定转换器
+var ErrNoTransformer = errors.New("sse: nil transformer")
+
+// Transformer 将上游事件转换为客户端格式的事件
+// 写入错误由 Writer 记录，Pipe 在每次调用后检查，实现中可以忽略 Send 的返回值
+type Transformer interface {
+	// Start 在读取上游之前调用，发送开始事件
+	Start(w *Writer) error
+	// Transform 处理一个上游事件（[DONE] 不会传入）
+	Transform(ev *Event, w *Writer) error
+	// Finish 上游正常结束（收到 [DONE] 或 EOF）后调用，发送结束事件
+	Finish(w *Writer) error
+}
+
+// Observer 在事件交给 Transformer 之前查看上游事件（如提取 token 用量）
+type Observer func(ev *Event)
+
+// Pipe 从 r 读取上游事件，经 t 转换后写入 w
+// 上游读取失败或客户端断开时立即返回错误，不发送结束事件
+func Pipe(r *Reader, w *Writer, t Transformer, observe Observer) error {
+	if t == nil {
+		return ErrNoTransformer
+	}
+	if err := t.Start(w); err != nil {
+		return err
+	}
+	if err := w.Err(); err != nil {
+		return err
+	}
+
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if observe != nil {
+			observe(ev)
+		}
+		if ev.IsDone() {
+			break
+		}
+		if err := t.Transform(ev, w); err != nil {
+			return err
+		}
+		if err := w.Err(); err != nil {
+			return err
+		}
+	}
+
+	if err := t.Finish(w); err != nil {
+		return err
+	}
+	return w.Err()
+}
@@ -0,0 +1,106 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// echoTransformer 发送开始和结束事件，其余事件原样转发
+type echoTransformer struct {
+	finished bool
+}
+
+func (t *echoTransformer) Start(w *Writer) error {
+	return w.SendRaw("start", "{}")
+}
+
+func (t *echoTransformer) Transform(ev *Event, w *Writer) error {
+	return w.SendRaw("", ev.Data)
+}
+
+func (t *echoTransformer) Finish(w *Writer) error {
+	t.finished = true
+	return w.SendRaw("stop", "{}")
+}
+
+func TestPipe(t *testing.T) {
+	errUpstream := errors.New("upstream reset")
+	tests := []struct {
+		name     string
+		upstream func() io.Reader
+		want     string
+		wantErr  error
+		finished bool
+		observed int
+	}{
+		{
+			name: "stops at done",
+			upstream: func() io.Reader {
+				return strings.NewReader("data: {\"a\":1}\n\ndata: [DONE]\n\ndata: {\"a\":2}\n\n")
+			},
+			want:     "event: start\ndata: {}\n\ndata: {\"a\":1}\n\nevent: stop\ndata: {}\n\n",
+			finished: true,
+			observed: 2,
+		},
+		{
+			name: "eof without done",
+			upstream: func() io.Reader {
+				return iotest.OneByteReader(strings.NewReader("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+			},
+			want:     "event: start\ndata: {}\n\ndata: {\"type\":\"message_stop\"}\n\nevent: stop\ndata: {}\n\n",
+			finished: true,
+			observed: 1,
+		},
+		{
+			name: "upstream error skips finish",
+			upstream: func() io.Reader {
+				return io.MultiReader(strings.NewReader("data: {\"a\":1}\n\n"), iotest.ErrReader(errUpstream))
+			},
+			want:     "event: start\ndata: {}\n\ndata: {\"a\":1}\n\n",
+			wantErr:  errUpstream,
+			observed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			transformer := &echoTransformer{}
+			observed := 0
+			err := Pipe(NewReader(tt.upstream()), NewWriter(&buf, nil), transformer, func(ev *Event) { observed++ })
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Pipe error = %v, want %v", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output = %q, want %q", buf.String(), tt.want)
+			}
+			if transformer.finished != tt.finished {
+				t.Errorf("finished = %v, want %v", transformer.finished, tt.finished)
+			}
+			if observed != tt.observed {
+				t.Errorf("observed %d events, want %d", observed, tt.observed)
+			}
+		})
+	}
+}
+
+func TestPipeClientDisconnect(t *testing.T) {
+	transformer := &echoTransformer{}
+	err := Pipe(NewReader(strings.NewReader("data: {}\n\n")), NewWriter(&failingWriter{}, nil), transformer, nil)
+	if !errors.Is(err, errClosed) {
+		t.Fatalf("Pipe error = %v, want %v", err, errClosed)
+	}
+	if transformer.finished {
+		t.Error("Finish should not be called after the client disconnects")
+	}
+}
+
+func TestPipeNilTransformer(t *testing.T) {
+	if err := Pipe(NewReader(strings.NewReader("")), NewWriter(io.Discard, nil), nil, nil); err != ErrNoTransformer {
+		t.Fatalf("Pipe error = %v, want %v", err, ErrNoTransformer)
+	}
+}
@@ -0,0 +1,207 @@
+// Package sse 提供统一的 SSE 流读取、写入和事件转换管道，供各格式之间的流式响应转换共用
+package sse
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MaxLineSize 单行最大字节数（图片 base64 等大块数据可能超过 1MB）
+const MaxLineSize = 16 << 20
+
+// DoneData OpenAI 流结束标记
+const DoneData = "[DONE]"
+
+// Event 一个 SSE 事件
+type Event struct {
+	Name string // event: 字段，未指定时为空
+	ID   string // id: 字段
+	Data string // 多行 data 以 \n 连接
+
+	parsed   map[string]interface{}
+	parseErr error
+	decoded  bool
+}
+
+// IsDone 是否为 [DONE] 结束标记
+func (e *Event) IsDone() bool {
+	return strings.TrimSpace(e.Data) == DoneData
+}
+
+// JSON 将 data 解析为 JSON 对象，结果会被缓存
+func (e *Event) JSON() (map[string]interface{}, error) {
+	if !e.decoded {
+		e.decoded = true
+		e.parseErr = json.Unmarshal([]byte(e.Data), &e.parsed)
+	}
+	return e.parsed, e.parseErr
+}
+
+// Reader 从上游响应中逐个读取 SSE 事件
+// 兼容常见的不规范写法：data: 后无空格、CRLF 换行、事件之间缺少空行，以及没有 data: 前缀的 NDJSON 行（Ollama）
+type Reader struct {
+	scanner *bufio.Scanner
+	name    string
+	id      string
+	data    []string
+	hasData bool
+	next    *Event // NDJSON 行打断当前事件时暂存
+}
+
+// NewReader 创建 SSE 读取器
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), MaxLineSize)
+	return &Reader{scanner: scanner}
+}
+
+// Next 返回下一个事件，流结束时返回 io.EOF
+func (r *Reader) Next() (*Event, error) {
+	if r.next != nil {
+		ev := r.next
+		r.next = nil
+		return ev, nil
+	}
+
+	for r.scanner.Scan() {
+		line := strings.TrimSuffix(r.scanner.Text(), "\r")
+
+		if line == "" {
+			if ev := r.dispatch(); ev != nil {
+				return ev, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // 注释 / 心跳
+		}
+		// NDJSON：整行就是一个 JSON
+		if line[0] == '{' || line[0] == '[' {
+			ndjson := &Event{Data: line}
+			if ev := r.dispatch(); ev != nil {
+				r.next = ndjson
+				return ev, nil
+			}
+			return ndjson, nil
+		}
+
+		field, value := line, ""
+		if idx := strings.IndexByte(line, ':'); idx >= 0 {
+			field, value = line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		}
+		switch field {
+		case "event":
+			r.name = value
+		case "id":
+			r.id = value
+		case "data":
+			// 上一条 data 已是完整 JSON 且中间没有空行：视为两个事件
+			if r.hasData && json.Valid([]byte(strings.Join(r.data, "\n"))) {
+				ev := r.dispatch()
+				r.data, r.hasData = append(r.data, value), true
+				return ev, nil
+			}
+			r.data, r.hasData = append(r.data, value), true
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	if ev := r.dispatch(); ev != nil {
+		return ev, nil
+	}
+	return nil, io.EOF
+}
+
+// dispatch 结束当前事件，没有 data 时返回 nil
+func (r *Reader) dispatch() *Event {
+	if !r.hasData {
+		r.name = ""
+		return nil
+	}
+	ev := &Event{Name: r.name, ID: r.id, Data: strings.Join(r.data, "\n")}
+	r.name, r.data, r.hasData = "", nil, false
+	return ev
+}
+
+// Writer 向客户端写 SSE 事件，每个事件写完立即 flush
+// 第一次写入失败（通常是客户端断开）后不再写入，错误通过 Err 返回
+type Writer struct {
+	w       io.Writer
+	flusher http.Flusher
+	err     error
+}
+
+// NewWriter 创建 SSE 写入器，flusher 可以为 nil
+func NewWriter(w io.Writer, flusher http.Flusher) *Writer {
+	return &Writer{w: w, flusher: flusher}
+}
+
+// Send 发送 JSON 事件，name 为空时不写 event: 行
+func (w *Writer) Send(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return w.SendRaw(name, string(data))
+}
+
+// SendRaw 发送原始 data，多行 data 会拆成多个 data: 行
+func (w *Writer) SendRaw(name, data string) error {
+	if w.err != nil {
+		return w.err
+	}
+	var sb strings.Builder
+	if name != "" {
+		sb.WriteString("event: ")
+		sb.WriteString(name)
+		sb.WriteByte('\n')
+	}
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: ")
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	sb.WriteByte('\n')
+	if _, err := io.WriteString(w.w, sb.String()); err != nil {
+		w.err = err
+		return err
+	}
+	w.Flush()
+	return nil
+}
+
+// Done 发送 [DONE] 结束标记
+func (w *Writer) Done() error {
+	return w.SendRaw("", DoneData)
+}
+
+// Write 原样写入字节（用于直接透传），写入后 flush
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	if err != nil {
+		w.err = err
+		return n, err
+	}
+	w.Flush()
+	return n, nil
+}
+
+// Flush 刷新缓冲
+func (w *Writer) Flush() {
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+}
+
+// Err 返回第一次写入失败的错误
+func (w *Writer) Err() error {
+	return w.err
+}
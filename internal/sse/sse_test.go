@@ -0,0 +1,188 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// event 用于比较的事件字段
+type event struct {
+	Name, ID, Data string
+}
+
+// readAll 读取全部事件
+func readAll(t *testing.T, r io.Reader) []event {
+	t.Helper()
+	reader := NewReader(r)
+	var events []event
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		events = append(events, event{Name: ev.Name, ID: ev.ID, Data: ev.Data})
+	}
+}
+
+func TestReaderNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []event
+	}{
+		{
+			name:  "openai",
+			input: "data: {\"a\":1}\n\ndata: {\"a\":2}\n\ndata: [DONE]\n\n",
+			want:  []event{{Data: `{"a":1}`}, {Data: `{"a":2}`}, {Data: DoneData}},
+		},
+		{
+			name:  "claude events",
+			input: "event: message_start\ndata: {\"type\":\"message_start\"}\n\nevent: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			want: []event{
+				{Name: "message_start", Data: `{"type":"message_start"}`},
+				{Name: "message_stop", Data: `{"type":"message_stop"}`},
+			},
+		},
+		{
+			name:  "crlf without space",
+			input: "id: 7\r\ndata:{\"a\":1}\r\n\r\n",
+			want:  []event{{ID: "7", Data: `{"a":1}`}},
+		},
+		{
+			name:  "missing blank line",
+			input: "data: {\"a\":1}\ndata: {\"a\":2}\n",
+			want:  []event{{Data: `{"a":1}`}, {Data: `{"a":2}`}},
+		},
+		{
+			name:  "multi-line data",
+			input: "data: {\"a\":\ndata: 1}\n\n",
+			want:  []event{{Data: "{\"a\":\n1}"}},
+		},
+		{
+			name:  "comments and heartbeats",
+			input: ": ping\n\nevent: ping\n\ndata: {\"a\":1}\n\n",
+			want:  []event{{Data: `{"a":1}`}},
+		},
+		{
+			name:  "ndjson",
+			input: "{\"done\":false}\n{\"done\":true}\n",
+			want:  []event{{Data: `{"done":false}`}, {Data: `{"done":true}`}},
+		},
+		{
+			name:  "ndjson after unfinished event",
+			input: "data: partial\n{\"done\":true}\n",
+			want:  []event{{Data: "partial"}, {Data: `{"done":true}`}},
+		},
+		{
+			name:  "last event without blank line",
+			input: "data: {\"a\":1}",
+			want:  []event{{Data: `{"a":1}`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 一次读完和逐字节读取（事件被拆到多次读取中）的结果应相同
+			for _, r := range []io.Reader{strings.NewReader(tt.input), iotest.OneByteReader(strings.NewReader(tt.input))} {
+				got := readAll(t, r)
+				if len(got) != len(tt.want) {
+					t.Fatalf("got %d events %+v, want %d %+v", len(got), got, len(tt.want), tt.want)
+				}
+				for i := range got {
+					if got[i] != tt.want[i] {
+						t.Errorf("event %d = %+v, want %+v", i, got[i], tt.want[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEventIsDoneAndJSON(t *testing.T) {
+	if !(&Event{Data: " [DONE] "}).IsDone() {
+		t.Error("[DONE] with spaces should be done")
+	}
+	ev := &Event{Data: `{"type":"ping"}`}
+	chunk, err := ev.JSON()
+	if err != nil || chunk["type"] != "ping" {
+		t.Fatalf("JSON() = %v, %v", chunk, err)
+	}
+	if _, err := (&Event{Data: "not json"}).JSON(); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestWriterSend(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(w *Writer) error
+		want string
+	}{
+		{
+			name: "json without name",
+			send: func(w *Writer) error { return w.Send("", map[string]int{"a": 1}) },
+			want: "data: {\"a\":1}\n\n",
+		},
+		{
+			name: "named event",
+			send: func(w *Writer) error { return w.Send("message_stop", map[string]string{"type": "message_stop"}) },
+			want: "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		},
+		{
+			name: "multi-line raw data",
+			send: func(w *Writer) error { return w.SendRaw("", "a\nb") },
+			want: "data: a\ndata: b\n\n",
+		},
+		{
+			name: "done",
+			send: func(w *Writer) error { return w.Done() },
+			want: "data: [DONE]\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.send(NewWriter(&buf, nil)); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+// failingWriter 第一次写入就失败（模拟客户端断开）
+type failingWriter struct{ writes int }
+
+var errClosed = errors.New("client closed")
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	f.writes++
+	return 0, errClosed
+}
+
+func TestWriterStopsAfterError(t *testing.T) {
+	fw := &failingWriter{}
+	w := NewWriter(fw, nil)
+	if err := w.SendRaw("", "a"); !errors.Is(err, errClosed) {
+		t.Fatalf("first send error = %v", err)
+	}
+	if err := w.Done(); !errors.Is(err, errClosed) {
+		t.Fatalf("second send error = %v", err)
+	}
+	if fw.writes != 1 {
+		t.Errorf("writes = %d, want 1", fw.writes)
+	}
+	if !errors.Is(w.Err(), errClosed) {
+		t.Errorf("Err() = %v", w.Err())
+	}
+}
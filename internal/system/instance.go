@@ -0,0 +1,157 @@
+package system
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 单实例：桌面端启动时锁定工作目录下的锁文件，并在锁文件中写入本地 IPC 地址；
+// 第二次启动时获取锁失败，通过该地址通知已运行的实例显示主窗口后退出
+
+// InstanceLockFile 单实例锁文件名（与 config.json 位于同一目录）
+const InstanceLockFile = "anyproxyai.lock"
+
+// ErrAlreadyRunning 已有实例在运行
+var ErrAlreadyRunning = errors.New("another instance is already running")
+
+const (
+	instanceActivateCommand = "activate"
+	instanceIPCTimeout      = 2 * time.Second
+)
+
+// SingleInstance 持有单实例锁和 IPC 监听
+type SingleInstance struct {
+	file     *os.File
+	listener net.Listener
+
+	mu         sync.Mutex
+	onActivate func()
+	pending    bool // 设置回调前收到的激活请求
+}
+
+// AcquireSingleInstance 获取 dir 下的单实例锁，已有实例运行时返回 ErrAlreadyRunning
+func AcquireSingleInstance(dir string) (*SingleInstance, error) {
+	path := filepath.Join(dir, InstanceLockFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, ErrAlreadyRunning
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		unlockFile(file)
+		file.Close()
+		return nil, fmt.Errorf("failed to start instance listener: %v", err)
+	}
+	if err := file.Truncate(0); err == nil {
+		_, err = file.WriteAt([]byte(listener.Addr().String()+"\n"), 0)
+	}
+	if err != nil {
+		log.Warnf("Failed to write instance lock file: %v", err)
+	}
+
+	inst := &SingleInstance{file: file, listener: listener}
+	go inst.serve()
+	return inst, nil
+}
+
+// WaitSingleInstance 等待已运行的实例退出后获取锁（重启时新进程使用），超时返回 ErrAlreadyRunning
+func WaitSingleInstance(dir string, timeout time.Duration) (*SingleInstance, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		inst, err := AcquireSingleInstance(dir)
+		if err != ErrAlreadyRunning || time.Now().After(deadline) {
+			return inst, err
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// OnActivate 设置第二个实例请求显示主窗口时的回调
+func (s *SingleInstance) OnActivate(fn func()) {
+	s.mu.Lock()
+	s.onActivate = fn
+	pending := s.pending
+	s.pending = false
+	s.mu.Unlock()
+	if pending && fn != nil {
+		fn()
+	}
+}
+
+// Release 关闭 IPC 监听并释放锁
+func (s *SingleInstance) Release() {
+	s.listener.Close()
+	unlockFile(s.file)
+	s.file.Close()
+}
+
+func (s *SingleInstance) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *SingleInstance) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(instanceIPCTimeout))
+	line, err := bufio.NewReader(io.LimitReader(conn, 256)).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != instanceActivateCommand {
+		return
+	}
+	log.Info("Another instance was launched, showing main window")
+	s.mu.Lock()
+	fn := s.onActivate
+	if fn == nil {
+		s.pending = true
+	}
+	s.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+	conn.Write([]byte("ok\n"))
+}
+
+// ActivateRunningInstance 通知 dir 下已运行的实例显示主窗口
+func ActivateRunningInstance(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, InstanceLockFile))
+	if err != nil {
+		return fmt.Errorf("failed to read lock file: %v", err)
+	}
+	addr := strings.TrimSpace(string(data))
+	if addr == "" {
+		return fmt.Errorf("lock file has no instance address")
+	}
+	conn, err := net.DialTimeout("tcp", addr, instanceIPCTimeout)
+	if err != nil {
+		return fmt.Errorf("running instance is not reachable: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(instanceIPCTimeout))
+	if _, err := conn.Write([]byte(instanceActivateCommand + "\n")); err != nil {
+		return err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || strings.TrimSpace(reply) != "ok" {
+		return fmt.Errorf("running instance did not respond")
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package system
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile 对锁文件加排他锁（非阻塞），进程退出时自动释放
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func unlockFile(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
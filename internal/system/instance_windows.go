@@ -0,0 +1,24 @@
+//go:build windows
+// +build windows
+
+package system
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Windows 文件锁是强制锁，锁定文件内容之外的区域，第二个实例仍可读取 IPC 地址
+const instanceLockOffset = 1 << 20
+
+// lockFile 对锁文件加排他锁（非阻塞），进程退出时自动释放
+func lockFile(f *os.File) error {
+	ol := &windows.Overlapped{Offset: instanceLockOffset}
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+}
+
+func unlockFile(f *os.File) {
+	ol := &windows.Overlapped{Offset: instanceLockOffset}
+	windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+}
@@ -0,0 +1,34 @@
+package system
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 系统服务：以无界面模式（serve 子命令）运行 API 服务器，Windows 注册为系统服务，Linux 安装 systemd 用户单元
+
+// ServiceName 系统服务名
+const ServiceName = "AnyProxyAi"
+
+// ServiceStatus 系统服务状态
+type ServiceStatus struct {
+	Supported bool   `json:"supported"` // 当前平台是否支持安装服务
+	Installed bool   `json:"installed"`
+	Running   bool   `json:"running"`
+	Kind      string `json:"kind"`   // windows-service 或 systemd-user
+	Detail    string `json:"detail"` // 服务状态或单元文件路径等说明
+}
+
+// serviceArgs 服务的启动参数：无界面模式，工作目录为 config.json 所在目录
+func serviceArgs(workDir string) []string {
+	return []string{"serve", "--dir", workDir}
+}
+
+// waitForSignal 等待 Ctrl+C 或 SIGTERM
+func waitForSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	<-ch
+	signal.Stop(ch)
+}
@@ -0,0 +1,163 @@
+//go:build linux
+// +build linux
+
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	systemdUnitTemplate = `[Unit]
+Description=AnyProxyAi API proxy server
+After=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s
+WorkingDirectory=%s
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=default.target
+`
+	systemdUnitName = "anyproxyai.service"
+)
+
+type ServiceManager struct{}
+
+func NewServiceManager() *ServiceManager {
+	return &ServiceManager{}
+}
+
+// unitPath systemd 用户单元文件路径（~/.config/systemd/user/anyproxyai.service）
+func (m *ServiceManager) unitPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %v", err)
+	}
+	return filepath.Join(configDir, "systemd", "user", systemdUnitName), nil
+}
+
+// systemctl 执行 systemctl --user 命令，返回输出
+func systemctl(args ...string) (string, error) {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil && output != "" {
+		return output, fmt.Errorf("systemctl %s: %s", strings.Join(args, " "), output)
+	}
+	return output, err
+}
+
+// Install 生成 systemd 用户单元并设置为登录后自动启动
+// 未登录时也保持运行需要执行 loginctl enable-linger
+func (m *ServiceManager) Install(workDir string) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %v", err)
+	}
+	exePath, err = filepath.Abs(exePath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	path, err := m.unitPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("service is already installed: %s", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create systemd user directory: %v", err)
+	}
+
+	command := []string{systemdQuote(exePath)}
+	for _, arg := range serviceArgs(workDir) {
+		command = append(command, systemdQuote(arg))
+	}
+	content := fmt.Sprintf(systemdUnitTemplate, strings.Join(command, " "), systemdQuote(workDir))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write systemd unit: %v", err)
+	}
+	if _, err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if _, err := systemctl("enable", systemdUnitName); err != nil {
+		return err
+	}
+	log.Infof("systemd user unit installed: %s", path)
+	return nil
+}
+
+// Uninstall 停止、禁用并删除 systemd 用户单元
+func (m *ServiceManager) Uninstall() error {
+	path, err := m.unitPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("service is not installed")
+	}
+	if _, err := systemctl("disable", "--now", systemdUnitName); err != nil {
+		log.Warnf("Failed to disable systemd unit: %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove systemd unit: %v", err)
+	}
+	systemctl("daemon-reload")
+	log.Infof("systemd user unit removed: %s", path)
+	return nil
+}
+
+// Start 启动服务
+func (m *ServiceManager) Start() error {
+	_, err := systemctl("start", systemdUnitName)
+	return err
+}
+
+// Stop 停止服务
+func (m *ServiceManager) Stop() error {
+	_, err := systemctl("stop", systemdUnitName)
+	return err
+}
+
+// Status 查询服务状态
+func (m *ServiceManager) Status() ServiceStatus {
+	status := ServiceStatus{Supported: true, Kind: "systemd-user"}
+	path, err := m.unitPath()
+	if err != nil {
+		status.Detail = err.Error()
+		return status
+	}
+	if _, err := os.Stat(path); err != nil {
+		return status
+	}
+	status.Installed = true
+	// is-active 在服务未运行时返回非零退出码，只看输出
+	state, _ := systemctl("is-active", systemdUnitName)
+	status.Running = state == "active"
+	status.Detail = state + " (" + path + ")"
+	return status
+}
+
+// systemdQuote 为 ExecStart/WorkingDirectory 中的参数加引号
+func systemdQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// WaitForStop 无界面模式下阻塞直到收到 SIGTERM（systemctl stop）或 Ctrl+C
+func WaitForStop() error {
+	waitForSignal()
+	return nil
+}
@@ -0,0 +1,33 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package system
+
+import "fmt"
+
+type ServiceManager struct{}
+
+func NewServiceManager() *ServiceManager {
+	return &ServiceManager{}
+}
+
+var errServiceUnsupported = fmt.Errorf("service installation is not supported on this platform")
+
+func (m *ServiceManager) Install(workDir string) error { return errServiceUnsupported }
+
+func (m *ServiceManager) Uninstall() error { return errServiceUnsupported }
+
+func (m *ServiceManager) Start() error { return errServiceUnsupported }
+
+func (m *ServiceManager) Stop() error { return errServiceUnsupported }
+
+// Status 不支持的平台只返回 Supported=false
+func (m *ServiceManager) Status() ServiceStatus {
+	return ServiceStatus{}
+}
+
+// WaitForStop 无界面模式下阻塞直到收到 Ctrl+C 或 SIGTERM
+func WaitForStop() error {
+	waitForSignal()
+	return nil
+}
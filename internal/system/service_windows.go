@@ -0,0 +1,232 @@
+//go:build windows
+// +build windows
+
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const serviceStopTimeout = 15 * time.Second
+
+type ServiceManager struct{}
+
+func NewServiceManager() *ServiceManager {
+	return &ServiceManager{}
+}
+
+// connect 连接服务控制管理器（安装、启动、停止服务需要管理员权限）
+func (m *ServiceManager) connect() (*mgr.Mgr, error) {
+	scm, err := mgr.Connect()
+	if err != nil {
+		if errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+			return nil, fmt.Errorf("managing the Windows service requires administrator privileges")
+		}
+		return nil, fmt.Errorf("failed to connect to service manager: %v", err)
+	}
+	return scm, nil
+}
+
+// Install 注册 Windows 服务（开机自动启动，异常退出后重启）
+func (m *ServiceManager) Install(workDir string) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %v", err)
+	}
+	exePath, err = filepath.Abs(exePath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	scm, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer scm.Disconnect()
+
+	if s, err := scm.OpenService(ServiceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", ServiceName)
+	}
+	s, err := scm.CreateService(ServiceName, exePath, mgr.Config{
+		DisplayName: ServiceName,
+		Description: "AnyProxyAi API proxy server (headless)",
+		StartType:   mgr.StartAutomatic,
+	}, serviceArgs(workDir)...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %v", err)
+	}
+	defer s.Close()
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 5 * time.Second}}, 86400); err != nil {
+		log.Warnf("Failed to set service recovery actions: %v", err)
+	}
+	log.Infof("Windows service installed: %s (%s)", ServiceName, exePath)
+	return nil
+}
+
+// Uninstall 停止并删除 Windows 服务
+func (m *ServiceManager) Uninstall() error {
+	scm, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer scm.Disconnect()
+
+	s, err := scm.OpenService(ServiceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", ServiceName)
+	}
+	defer s.Close()
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		if err := stopService(s); err != nil {
+			log.Warnf("Failed to stop service before removal: %v", err)
+		}
+	}
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %v", err)
+	}
+	log.Infof("Windows service removed: %s", ServiceName)
+	return nil
+}
+
+// Start 启动 Windows 服务
+func (m *ServiceManager) Start() error {
+	scm, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer scm.Disconnect()
+
+	s, err := scm.OpenService(ServiceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", ServiceName)
+	}
+	defer s.Close()
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %v", err)
+	}
+	return nil
+}
+
+// Stop 停止 Windows 服务并等待其退出
+func (m *ServiceManager) Stop() error {
+	scm, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer scm.Disconnect()
+
+	s, err := scm.OpenService(ServiceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", ServiceName)
+	}
+	defer s.Close()
+	return stopService(s)
+}
+
+func stopService(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("failed to stop service: %v", err)
+	}
+	deadline := time.Now().Add(serviceStopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("failed to query service: %v", err)
+		}
+	}
+	return nil
+}
+
+// Status 查询服务状态（只需要查询权限，普通用户也可以调用）
+func (m *ServiceManager) Status() ServiceStatus {
+	status := ServiceStatus{Supported: true, Kind: "windows-service"}
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		status.Detail = err.Error()
+		return status
+	}
+	defer windows.CloseServiceHandle(scm)
+
+	name, err := windows.UTF16PtrFromString(ServiceName)
+	if err != nil {
+		status.Detail = err.Error()
+		return status
+	}
+	h, err := windows.OpenService(scm, name, windows.SERVICE_QUERY_STATUS)
+	if err != nil {
+		if !errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			status.Detail = err.Error()
+		}
+		return status
+	}
+	s := &mgr.Service{Name: ServiceName, Handle: h}
+	defer s.Close()
+	status.Installed = true
+	q, err := s.Query()
+	if err != nil {
+		status.Detail = err.Error()
+		return status
+	}
+	status.Running = q.State == svc.Running
+	status.Detail = serviceStateName(q.State)
+	return status
+}
+
+func serviceStateName(state svc.State) string {
+	switch state {
+	case svc.Stopped:
+		return "stopped"
+	case svc.StartPending:
+		return "starting"
+	case svc.StopPending:
+		return "stopping"
+	case svc.Running:
+		return "running"
+	case svc.Paused, svc.PausePending, svc.ContinuePending:
+		return "paused"
+	}
+	return "unknown"
+}
+
+// serviceHandler 响应服务控制管理器的停止/关机请求
+type serviceHandler struct{}
+
+func (serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			changes <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			log.Info("Service stop requested")
+			changes <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// WaitForStop 无界面模式下阻塞直到需要退出：作为 Windows 服务运行时等待服务控制管理器的停止请求，
+// 否则等待 Ctrl+C
+func WaitForStop() error {
+	if isService, err := svc.IsWindowsService(); err == nil && isService {
+		return svc.Run(ServiceName, serviceHandler{})
+	}
+	waitForSignal()
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package tokenizer 在上游没有返回 usage 时根据文本估算 token 数
+// OpenAI 及兼容模型使用 tiktoken（内置词表，无需联网），Claude 和 Gemini 使用近似算法
+package tokenizer
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	encodingO200K  = "o200k_base"
+	encodingCL100K = "cl100k_base"
+
+	// claudeFactor Claude 的分词比 cl100k 略细，同样文本大约多 10% 的 token
+	claudeFactor = 1.1
+)
+
+var (
+	loaderOnce sync.Once
+	encMu      sync.Mutex
+	encodings  = make(map[string]*tiktoken.Tiktoken)
+	failed     = make(map[string]bool)
+)
+
+// o200kPrefixes 使用 o200k_base 词表的模型前缀
+var o200kPrefixes = []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "chatgpt-4o", "o1", "o3", "o4"}
+
+// Count 估算文本在指定模型下的 token 数
+func Count(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	model = normalizeModel(model)
+	switch {
+	case strings.Contains(model, "claude"):
+		if n, ok := countTiktoken(encodingCL100K, text); ok {
+			return int(math.Ceil(float64(n) * claudeFactor))
+		}
+	case strings.Contains(model, "gemini"), strings.Contains(model, "gemma"):
+		return Heuristic(text)
+	default:
+		if n, ok := countTiktoken(encodingForModel(model), text); ok {
+			return n
+		}
+	}
+	return Heuristic(text)
+}
+
+// Heuristic 按字符近似估算：中日韩字符约 1 个 token，其余约 4 个字符 1 个 token
+func Heuristic(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// normalizeModel 去掉 provider/ 前缀并转为小写，如 openai/gpt-4o -> gpt-4o
+func normalizeModel(model string) string {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if idx := strings.LastIndex(model, "/"); idx >= 0 {
+		model = model[idx+1:]
+	}
+	return model
+}
+
+// encodingForModel 新版 OpenAI 模型使用 o200k_base，其余（包括 OpenAI 兼容的开源模型）使用 cl100k_base 近似
+func encodingForModel(model string) string {
+	for _, prefix := range o200kPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return encodingO200K
+		}
+	}
+	return encodingCL100K
+}
+
+// countTiktoken 使用 tiktoken 计数，词表加载失败时返回 false
+func countTiktoken(encoding, text string) (int, bool) {
+	enc := getEncoding(encoding)
+	if enc == nil {
+		return 0, false
+	}
+	return len(enc.EncodeOrdinary(text)), true
+}
+
+// getEncoding 按需加载词表（首次加载约需数百毫秒），加载失败后不再重试
+func getEncoding(name string) *tiktoken.Tiktoken {
+	loaderOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+	})
+
+	encMu.Lock()
+	defer encMu.Unlock()
+	if enc, ok := encodings[name]; ok {
+		return enc
+	}
+	if failed[name] {
+		return nil
+	}
+	enc, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		log.Warnf("Failed to load tokenizer %s, falling back to heuristic counting: %v", name, err)
+		failed[name] = true
+		return nil
+	}
+	encodings[name] = enc
+	return enc
+}
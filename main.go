@@ -1,10 +1,13 @@
 package main
 
 import (
+	"crypto/tls"
 	"embed"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"os"
 	"path/filepath"
@@ -13,7 +16,9 @@ import (
 
 	"openai-router-go/internal/config"
 	"openai-router-go/internal/database"
+	"openai-router-go/internal/logging"
 	"openai-router-go/internal/router"
+	"openai-router-go/internal/secret"
 	"openai-router-go/internal/service"
 	"openai-router-go/internal/system"
 	"openai-router-go/services"
@@ -33,17 +38,11 @@ var assets embed.FS
 var trayIcons embed.FS
 
 // 全局日志文件句柄
-var logFile *os.File
+var logFile *logging.RotatingFile
 
-// setupFileLogging 设置文件日志
-func setupFileLogging() (*os.File, error) {
-	logDir := "log"
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create log directory: %v", err)
-	}
-
-	today := time.Now().Format("2006-01-02")
-	logPath := filepath.Join(logDir, today+".log")
+// setupFileLogging 设置文件日志（按大小滚动，保留最近 LogMaxBackups 个文件）
+func setupFileLogging(cfg *config.Config) (*logging.RotatingFile, error) {
+	logPath := filepath.Join("log", "anyproxyai.log")
 
 	if wd, err := os.Getwd(); err == nil {
 		log.Infof("工作目录=%s", wd)
@@ -52,9 +51,9 @@ func setupFileLogging() (*os.File, error) {
 	}
 	log.Infof("日志文件路径=%s", logPath)
 
-	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	file, err := logging.OpenRotatingFile(logPath, cfg.LogMaxSizeMB, cfg.LogMaxBackups)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %v", err)
+		return nil, err
 	}
 
 	mw := io.MultiWriter(os.Stdout, file)
@@ -98,15 +97,63 @@ func main() {
 	log.SetFormatter(&log.TextFormatter{
 		FullTimestamp: true,
 	})
-	log.SetLevel(log.DebugLevel)
+
+	// 无界面模式：serve [--dir <config.json 所在目录>]，需要在加载配置前切换工作目录
+	headless := isServeCommand()
+	if headless {
+		if err := prepareServe(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 
 	// 加载配置
 	cfg := config.LoadConfig()
+	logging.Apply(cfg.LogLevel, cfg.LogFormat, cfg.LogMaxMessageBytes)
+
+	// 命令行迁移工具：migrate status | up | down <version>
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(cfg, os.Args[2:]))
+	}
+
+	// 命令行管理工具：ctl routes | logs | stats，通过管理接口操作正在运行的代理
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(runCtlCommand(cfg, os.Args[2:]))
+	}
+
+	// MCP stdio 传输：转发到正在运行的代理的 /mcp
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		os.Exit(runMCPCommand(cfg))
+	}
+
+	// 单实例：已有桌面端在运行时通知其显示主窗口后退出，而不是提示端口被占用
+	var instance *system.SingleInstance
+	if !headless {
+		var err error
+		instance, err = system.AcquireSingleInstance(".")
+		if errors.Is(err, system.ErrAlreadyRunning) && os.Getenv(services.RestartEnv) != "" {
+			// RestartApp 启动的新进程：等待旧进程退出
+			instance, err = system.WaitSingleInstance(".", 15*time.Second)
+		}
+		os.Unsetenv(services.RestartEnv)
+		if errors.Is(err, system.ErrAlreadyRunning) {
+			if err := system.ActivateRunningInstance("."); err == nil {
+				log.Info("AnyProxyAi is already running, activated the existing window")
+				os.Exit(0)
+			} else {
+				log.Warnf("Failed to activate the running instance: %v", err)
+			}
+		} else if err != nil {
+			log.Warnf("Single instance check failed: %v", err)
+		} else {
+			defer instance.Release()
+		}
+	}
 
 	// 如果启用了文件日志，设置文件日志
 	if cfg.EnableFileLog {
 		var err error
-		logFile, err = setupFileLogging()
+		logFile, err = setupFileLogging(cfg)
 		if err != nil {
 			log.Warnf("Failed to setup file logging: %v", err)
 		} else {
@@ -118,13 +165,15 @@ func main() {
 	// 检查端口是否被占用
 	if err := checkPortAvailable(cfg.Host, cfg.Port); err != nil {
 		log.Errorf("Port check failed: %v", err)
-		showPortInUseError(cfg.Port)
+		if !headless {
+			showPortInUseError(cfg.Port)
+		}
 		os.Exit(1)
 	}
 	log.Infof("Port %d is available", cfg.Port)
 
-	// 初始化数据库
-	db, err := database.InitDB(cfg.DatabasePath)
+	// 初始化数据库（路由和请求日志可使用 PostgreSQL/MySQL，对话追踪始终使用本地 SQLite）
+	db, err := database.InitDBWithDriver(cfg.DatabaseSource())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -142,6 +191,29 @@ func main() {
 
 	// 创建服务
 	routeService := service.NewRouteService(db, traceDB)
+	defer routeService.FlushRequestLogs()
+	// 路由每日配额按配置的时区重置
+	if err := routeService.SetQuotaTimezone(cfg.QuotaTimezone); err != nil {
+		log.Warnf("Quota timezone ignored: %v", err)
+	}
+
+	// API Key 加密存储（主密码通过环境变量 ANYPROXY_MASTER_PASSWORD 提供）
+	secretKeyPath := cfg.SecretKeyPath
+	if secretKeyPath == "" {
+		secretKeyPath = filepath.Join(filepath.Dir(cfg.DatabasePath), "secret.key")
+	}
+	masterPassword := os.Getenv("ANYPROXY_MASTER_PASSWORD")
+	if cfg.EncryptAPIKeys {
+		if box, err := secret.LoadOrCreate(secretKeyPath, masterPassword); err != nil {
+			log.Errorf("Failed to load data key, API keys cannot be decrypted: %v", err)
+		} else if err := routeService.EnableKeyEncryption(box); err != nil {
+			log.Errorf("Failed to enable API key encryption: %v", err)
+		}
+	} else if box, err := secret.Load(secretKeyPath, masterPassword); err == nil {
+		if err := routeService.DisableKeyEncryption(box); err != nil {
+			log.Errorf("Failed to decrypt API keys: %v", err)
+		}
+	}
 
 	if migrated, err := routeService.MigrateLegacyTraces(); err != nil {
 		log.Warnf("Legacy trace migration failed: %v", err)
@@ -155,24 +227,88 @@ func main() {
 		log.Infof("Legacy traces deleted: %d", deleted)
 	}
 
+	// 路由故障、预算等事件通知（Webhook 和桌面通知）
+	notifier := service.NewNotifier(cfg)
+	routeService.SetNotifier(notifier)
+
+	// 后台定期清理过期 Traces、压缩请求日志
+	maintenance := service.NewMaintenanceScheduler(routeService, cfg)
+	maintenance.Start()
+	defer maintenance.Stop()
+
+	// 定期用量报告（通过通知 Webhook 或邮件发送）
+	reporter := service.NewReportScheduler(routeService, notifier, cfg)
+	reporter.Start()
+	defer reporter.Stop()
+
 	proxyService := service.NewProxyService(routeService, cfg)
+	defer proxyService.CloseAdapterPlugins()
+	if cfg.ModelsCacheWarmup {
+		go proxyService.WarmModelsCache()
+	}
+	// 继续执行上次退出时未完成的批处理任务
+	go proxyService.ResumeBatches()
+
+	// 主动健康探测（定期向每个启用的路由发送探测请求）
+	healthProber := service.NewHealthProber(routeService, proxyService, cfg)
+	healthProber.Start()
+	defer healthProber.Stop()
+
+	// 按计划同步上游模型列表，变更在界面中审批后应用
+	modelSyncer := service.NewModelSyncer(routeService, proxyService)
+	modelSyncer.Start()
+	defer modelSyncer.Stop()
 
 	// 初始化开机自启动管理器
 	autoStart := system.NewAutoStart()
 
 	// 创建应用服务实例（使用 services 包）
 	appSvc := services.NewAppService(routeService, proxyService, cfg, autoStart)
-
-	// 启动后台 API 服务器
-	go func() {
-		gin.SetMode(gin.ReleaseMode)
-		r := router.SetupAPIRouter(cfg, routeService, proxyService)
-		addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-		log.Infof("API server started at %s/api", addr)
-		if err := r.Run(addr); err != nil {
-			log.Errorf("Failed to start API server: %v", err)
+	appSvc.SetMaintenance(maintenance)
+	appSvc.SetHealthProber(healthProber)
+	appSvc.SetModelSyncer(modelSyncer)
+	appSvc.SetNotifier(notifier)
+	appSvc.SetReporter(reporter)
+
+	// 自动更新（检查 GitHub Releases，安装需要在界面中确认）
+	updater := service.NewUpdater(cfg)
+	appSvc.SetUpdater(updater)
+
+	// 启动后台 API 服务器（支持运行时切换端口）
+	gin.SetMode(gin.ReleaseMode)
+	apiRouter := router.SetupAPIRouter(cfg, routeService, proxyService)
+	// 网页管理界面（/admin），使用与桌面窗口相同的前端
+	if dist, err := fs.Sub(assets, "frontend/dist"); err == nil {
+		router.RegisterWebAdmin(apiRouter, cfg, dist, appSvc.Invoke)
+	}
+	// 管理 REST 接口（/api/admin/routes、/config、/stats 等）
+	router.RegisterAdminAPI(apiRouter, cfg, appSvc.Invoke)
+	// MCP 服务器（/mcp），供 MCP 客户端调用管理工具
+	router.RegisterMCPServer(apiRouter, cfg, appSvc.Invoke)
+	apiServer := router.NewServer(apiRouter)
+	var tlsConfig *tls.Config
+	if cfg.TLSEnabled {
+		if tlsConfig, err = router.LoadTLSConfig(cfg.CertPath, cfg.KeyPath); err != nil {
+			log.Errorf("Failed to load TLS certificate, falling back to HTTP: %v", err)
+		}
+	}
+	if err := apiServer.Start(cfg.ListenAddrs(), tlsConfig); err != nil {
+		log.Errorf("Failed to start API server: %v", err)
+	}
+	appSvc.SetAPIServer(apiServer)
+	if cfg.LocalAPIKey == "" && len(cfg.AllowedCIDRs) == 0 {
+		for _, addr := range apiServer.Addrs() {
+			if host, _, _ := net.SplitHostPort(addr); host != "localhost" && !net.ParseIP(host).IsLoopback() {
+				log.Warnf("API server listens on %s without local_api_key or allowed_cidrs, anyone on the network can use your upstream keys", addr)
+			}
 		}
-	}()
+	}
+
+	// 无界面模式（系统服务）：不创建窗口和托盘，网页管理界面仍可使用
+	if headless {
+		runHeadless(apiServer)
+		return
+	}
 
 	// 创建 Wails v3 应用
 	log.Info("Starting Wails v3 GUI application...")
@@ -196,6 +332,14 @@ func main() {
 	})
 
 	appSvc.SetApp(app)
+	// 桌面通知由前端通过系统通知显示
+	notifier.SetDesktopHandler(func(n service.Notification) {
+		app.Event.Emit(service.NotificationEventName, n)
+	})
+	// 实时请求流推送给前端（看板实时曲线和请求列表）
+	routeService.OnRequestLogged(func(events []service.LiveRequestEvent) {
+		app.Event.Emit(service.LiveRequestsEventName, events)
+	})
 
 	// 创建主窗口
 	mainWindow := app.Window.NewWithOptions(application.WebviewWindowOptions{
@@ -252,6 +396,22 @@ func main() {
 	// 初始显示窗口
 	showMainWindow(false)
 
+	// 定期检查发现新版本时通知前端
+	updater.OnAvailable(func(status service.UpdateStatus) {
+		app.Event.Emit(services.UpdateAvailableEventName, status)
+	})
+	updater.Start()
+	defer updater.Stop()
+
+	// 第二次启动时显示已有的主窗口
+	if instance != nil {
+		instance.OnActivate(func() {
+			application.InvokeAsync(func() {
+				showMainWindow(true)
+			})
+		})
+	}
+
 	// 注册窗口关闭事件钩子 - 最小化到托盘
 	mainWindow.RegisterHook(events.Common.WindowClosing, func(e *application.WindowEvent) {
 		if cfg.MinimizeToTray {
@@ -286,25 +446,17 @@ func main() {
 		systray.SetDarkModeIcon(darkIcon)
 	}
 
-	// 托盘菜单文本（根据语言设置）
-	showWindowText := "Show Window"
-	quitText := "Quit"
-	if cfg.Language == "zh-CN" {
-		showWindowText = "显示主窗口"
-		quitText = "退出"
+	// 托盘菜单：快捷操作和今日用量，定时刷新
+	tray := &trayController{
+		app:    app,
+		tray:   systray,
+		appSvc: appSvc,
+		texts:  newTrayTexts(cfg.Language),
+		showWindow: func() {
+			showMainWindow(true)
+		},
 	}
-
-	// 创建托盘菜单
-	trayMenu := application.NewMenu()
-	trayMenu.Add(showWindowText).OnClick(func(ctx *application.Context) {
-		showMainWindow(true)
-	})
-	trayMenu.AddSeparator()
-	trayMenu.Add(quitText).OnClick(func(ctx *application.Context) {
-		log.Info("Quit from tray menu")
-		app.Quit()
-	})
-	systray.SetMenu(trayMenu)
+	tray.start()
 
 	// 托盘点击事件
 	systray.OnClick(func() {
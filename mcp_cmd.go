@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"openai-router-go/internal/config"
+)
+
+// mcpStdioMaxMessage stdio 传输单条消息的最大长度
+const mcpStdioMaxMessage = 16 << 20
+
+// runMCPCommand MCP stdio 传输：从标准输入逐行读取 JSON-RPC 消息，转发到正在运行的代理的 /mcp，
+// 响应写到标准输出。需要代理已启动并开启 mcp_enabled，例如 Claude Desktop 中配置：
+//
+//	{"command": "<path>/openai-router-go", "args": ["mcp"]}
+func runMCPCommand(cfg *config.Config) int {
+	base, client := localAPIBase(cfg, 5*time.Minute)
+	endpoint := base + "/mcp"
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), mcpStdioMaxMessage)
+	out := bufio.NewWriter(os.Stdout)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		resp, err := forwardMCPMessage(client, endpoint, cfg.AuthKey(), line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mcp: %v\n", err)
+			resp = mcpStdioError(line, err)
+		}
+		if len(resp) > 0 {
+			out.Write(resp)
+			out.WriteByte('\n')
+			out.Flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "mcp: failed to read stdin: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// forwardMCPMessage 发送一条消息，返回压缩为单行的响应（通知没有响应）
+func forwardMCPMessage(client *http.Client, endpoint, apiKey string, message []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("proxy is not reachable at %s: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusAccepted:
+		return nil, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("MCP server is disabled, enable it in the settings (mcp_enabled)")
+	default:
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	return compact.Bytes(), nil
+}
+
+// mcpStdioError 转发失败时为请求生成 JSON-RPC 错误响应，通知不返回
+func mcpStdioError(message []byte, err error) []byte {
+	var msg struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(message, &msg) != nil || len(msg.ID) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      msg.ID,
+		"error":   map[string]interface{}{"code": -32603, "message": err.Error()},
+	})
+	return data
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+)
+
+// runMigrateCommand 命令行迁移工具
+//
+//	migrate status           查看已执行的迁移
+//	migrate up               执行所有未执行的迁移
+//	migrate down <version>   回滚到指定版本（0 表示回滚全部版本化迁移）
+func runMigrateCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: migrate status | up | down <version>")
+		return 2
+	}
+
+	db, err := database.OpenDriver(cfg.DatabaseSource())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	migrations := database.MigrationsFor(db.Dialect)
+
+	switch args[0] {
+	case "status":
+		applied, err := database.AppliedMigrations(db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read schema version: %v\n", err)
+			return 1
+		}
+		known, err := database.LoadMigrations(migrations)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load migrations: %v\n", err)
+			return 1
+		}
+		done := make(map[int]bool)
+		for _, m := range applied {
+			done[m.Version] = true
+			fmt.Printf("applied  %04d_%s  %s\n", m.Version, m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		for _, m := range known {
+			if !done[m.Version] {
+				fmt.Printf("pending  %04d_%s\n", m.Version, m.Name)
+			}
+		}
+	case "up":
+		if err := database.Migrate(db, migrations); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	case "down":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: migrate down <version>")
+			return 2
+		}
+		target, err := strconv.Atoi(args[1])
+		if err != nil || target < 0 {
+			fmt.Fprintf(os.Stderr, "invalid version: %s\n", args[1])
+			return 2
+		}
+		if err := database.MigrateDown(db, migrations, target); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command: %s\n", args[0])
+		return 2
+	}
+	return 0
+}
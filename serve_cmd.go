@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"openai-router-go/internal/router"
+	"openai-router-go/internal/system"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// serveShutdownTimeout 无界面模式退出时等待进行中请求完成的时间
+const serveShutdownTimeout = 10 * time.Second
+
+// isServeCommand 是否以无界面模式运行（serve 子命令，系统服务使用）
+func isServeCommand() bool {
+	return len(os.Args) > 1 && os.Args[1] == "serve"
+}
+
+// prepareServe 解析 serve 参数：--dir 为 config.json 所在目录（服务的默认工作目录不是程序目录）
+func prepareServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	dir := fs.String("dir", "", "working directory containing config.json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir != "" {
+		if err := os.Chdir(*dir); err != nil {
+			return fmt.Errorf("failed to change directory to %s: %v", *dir, err)
+		}
+	}
+	return nil
+}
+
+// runHeadless 只运行 API 服务器，直到服务管理器或 Ctrl+C 要求退出
+func runHeadless(apiServer *router.Server) {
+	log.Infof("Running headless on %v, press Ctrl+C to stop", apiServer.Addrs())
+	if err := system.WaitForStop(); err != nil {
+		log.Errorf("Service error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancel()
+	if err := apiServer.Shutdown(ctx); err != nil {
+		log.Warnf("API server shutdown: %v", err)
+	}
+	log.Info("Headless server stopped")
+}
@@ -1,11 +1,20 @@
 package services
 
 import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"strings"
 
 	"openai-router-go/internal/config"
+	"openai-router-go/internal/database"
+	"openai-router-go/internal/logging"
+	"openai-router-go/internal/router"
 	"openai-router-go/internal/service"
 	"openai-router-go/internal/system"
 
@@ -25,6 +34,17 @@ type RouteInfo struct {
 	Enabled bool   `json:"enabled"`
 	Created string `json:"created"`
 	Updated string `json:"updated"`
+
+	ExtraHeaders  map[string]string `json:"extra_headers"`  // 自定义请求头
+	ExtraQuery    map[string]string `json:"extra_query"`    // 自定义查询参数
+	Priority      int               `json:"priority"`       // 分组内的优先级
+	MaxTokens     int               `json:"max_tokens"`     // max_tokens 上限，0 表示不限制
+	UpstreamModel string            `json:"upstream_model"` // 发往上游的模型名，为空时使用请求的模型名
+	ProviderPrefs string            `json:"provider_prefs"` // OpenRouter 供应商偏好（JSON 对象）
+
+	DailyTokenLimit   int64               `json:"daily_token_limit"`   // 每日 token 上限，0 表示不限制
+	DailyRequestLimit int                 `json:"daily_request_limit"` // 每日请求数上限，0 表示不限制
+	Quota             *service.RouteQuota `json:"quota"`               // 今日配额使用情况（剩余量、重置时间），未设置上限时为空
 }
 
 // StatsInfo 统计信息结构体
@@ -94,6 +114,14 @@ type AppService struct {
 	ProxyService *service.ProxyService
 	Config       *config.Config
 	AutoStart    *system.AutoStart
+	Service      *system.ServiceManager
+	APIServer    *router.Server
+	Maintenance  *service.MaintenanceScheduler
+	HealthProber *service.HealthProber
+	Notifier     *service.Notifier
+	Reporter     *service.ReportScheduler
+	Updater      *service.Updater
+	ModelSyncer  *service.ModelSyncer
 }
 
 // NewAppService 创建新的 AppService 实例
@@ -103,6 +131,7 @@ func NewAppService(routeService *service.RouteService, proxyService *service.Pro
 		ProxyService: proxyService,
 		Config:       cfg,
 		AutoStart:    autoStart,
+		Service:      system.NewServiceManager(),
 	}
 }
 
@@ -111,6 +140,41 @@ func (a *AppService) SetApp(app *application.App) {
 	a.App = app
 }
 
+// SetMaintenance 设置后台维护任务引用
+func (a *AppService) SetMaintenance(m *service.MaintenanceScheduler) {
+	a.Maintenance = m
+}
+
+// SetHealthProber 设置主动健康探测引用
+func (a *AppService) SetHealthProber(h *service.HealthProber) {
+	a.HealthProber = h
+}
+
+// SetNotifier 设置事件通知引用
+func (a *AppService) SetNotifier(n *service.Notifier) {
+	a.Notifier = n
+}
+
+// SetUpdater 设置自动更新引用
+func (a *AppService) SetUpdater(u *service.Updater) {
+	a.Updater = u
+}
+
+// SetReporter 设置定期用量报告引用
+func (a *AppService) SetReporter(r *service.ReportScheduler) {
+	a.Reporter = r
+}
+
+// SetModelSyncer 设置模型列表自动同步引用
+func (a *AppService) SetModelSyncer(m *service.ModelSyncer) {
+	a.ModelSyncer = m
+}
+
+// SetAPIServer 设置 API 服务器引用（用于热切换端口）
+func (a *AppService) SetAPIServer(server *router.Server) {
+	a.APIServer = server
+}
+
 // GetLanguage 获取当前语言设置
 func (a *AppService) GetLanguage() string {
 	return a.Config.Language
@@ -122,8 +186,17 @@ func (a *AppService) SetLanguage(lang string) error {
 	return a.Config.Save()
 }
 
-// GetRoutes 获取所有路由
+// GetRoutes 获取所有路由（API Key 脱敏显示）
 func (a *AppService) GetRoutes() ([]RouteInfo, error) {
+	return a.listRoutes(false)
+}
+
+// GetRoutesWithKeys 获取所有路由（包含完整 API Key，用于导出）
+func (a *AppService) GetRoutesWithKeys() ([]RouteInfo, error) {
+	return a.listRoutes(true)
+}
+
+func (a *AppService) listRoutes(revealKeys bool) ([]RouteInfo, error) {
 	routes, err := a.RouteService.GetAllRoutes()
 	if err != nil {
 		return nil, err
@@ -131,22 +204,61 @@ func (a *AppService) GetRoutes() ([]RouteInfo, error) {
 
 	result := make([]RouteInfo, len(routes))
 	for i, route := range routes {
+		apiKey := route.APIKey
+		if !revealKeys {
+			apiKey = service.MaskAPIKey(apiKey)
+		}
 		result[i] = RouteInfo{
 			ID:      route.ID,
 			Name:    route.Name,
 			Model:   route.Model,
 			APIUrl:  route.APIUrl,
-			APIKey:  route.APIKey,
+			APIKey:  apiKey,
 			Group:   route.Group,
 			Format:  route.Format,
 			Enabled: route.Enabled,
 			Created: route.CreatedAt.Format("2006-01-02 15:04:05"),
 			Updated: route.UpdatedAt.Format("2006-01-02 15:04:05"),
+
+			ExtraHeaders:  route.ExtraHeaders,
+			ExtraQuery:    route.ExtraQuery,
+			Priority:      route.Priority,
+			MaxTokens:     route.MaxTokens,
+			UpstreamModel: route.UpstreamModel,
+			ProviderPrefs: route.ProviderPrefs,
+
+			DailyTokenLimit:   route.DailyTokenLimit,
+			DailyRequestLimit: route.DailyRequestLimit,
+			Quota:             a.RouteService.RouteQuota(&route),
 		}
 	}
 	return result, nil
 }
 
+// RevealRouteKey 获取路由的完整 API Key（用于编辑和复制）
+func (a *AppService) RevealRouteKey(id int64) (string, error) {
+	routes, err := a.RouteService.GetAllRoutes()
+	if err != nil {
+		return "", err
+	}
+	for _, route := range routes {
+		if route.ID == id {
+			return route.APIKey, nil
+		}
+	}
+	return "", fmt.Errorf("route not found: %d", id)
+}
+
+// GetRouteKeyStats 获取路由每个 API Key 的使用情况（请求数、失败数、冷却状态）
+func (a *AppService) GetRouteKeyStats(id int64) ([]service.APIKeyStat, error) {
+	return a.RouteService.GetRouteKeyStats(id)
+}
+
+// GetKeyPoolStatus 获取所有路由 API Key 的状态（Key 池看板）
+func (a *AppService) GetKeyPoolStatus() ([]service.KeyPoolEntry, error) {
+	return a.RouteService.GetKeyPoolStatus()
+}
+
 // AddRoute 添加路由
 func (a *AppService) AddRoute(name, model, apiUrl, apiKey, group, format string) error {
 	return a.RouteService.AddRoute(name, model, apiUrl, apiKey, group, format)
@@ -154,9 +266,230 @@ func (a *AppService) AddRoute(name, model, apiUrl, apiKey, group, format string)
 
 // UpdateRoute 更新路由
 func (a *AppService) UpdateRoute(id int64, name, model, apiUrl, apiKey, group, format string) error {
+	// 前端提交的是脱敏后的 Key 时保留原值
+	if apiKey != "" && strings.Contains(apiKey, "***") {
+		if current, err := a.RevealRouteKey(id); err == nil && service.MaskAPIKey(current) == apiKey {
+			apiKey = current
+		}
+	}
 	return a.RouteService.UpdateRoute(id, name, model, apiUrl, apiKey, group, format)
 }
 
+// SetRouteExtras 设置路由发往上游的自定义请求头和查询参数
+func (a *AppService) SetRouteExtras(id int64, extraHeaders, extraQuery map[string]string) error {
+	return a.RouteService.SetRouteExtras(id, extraHeaders, extraQuery)
+}
+
+// ExportRoutes 导出路由配置包（JSON/YAML），可选择不导出或使用密码加密 API Key
+func (a *AppService) ExportRoutes(opts service.RouteExportOptions) (string, error) {
+	data, err := a.RouteService.ExportRoutes(a.Config, opts)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ImportRoutes 导入路由配置包，兼容旧版导出的路由数组
+func (a *AppService) ImportRoutes(data, passphrase string) (*service.RouteImportResult, error) {
+	return a.RouteService.ImportRoutes(a.Config, []byte(data), passphrase)
+}
+
+// GetRouteGroups 获取路由分组及其故障转移优先级
+func (a *AppService) GetRouteGroups() ([]service.RouteGroup, error) {
+	return a.RouteService.GetRouteGroups()
+}
+
+// SetGroupPriority 设置分组的故障转移优先级（数字越小越先尝试）
+func (a *AppService) SetGroupPriority(name string, priority int) error {
+	return a.RouteService.SetGroupPriority(name, priority)
+}
+
+// SetRoutePriority 设置路由在分组内的优先级
+func (a *AppService) SetRoutePriority(id int64, priority int) error {
+	return a.RouteService.SetRoutePriority(id, priority)
+}
+
+// SetRouteMaxTokens 设置路由的 max_tokens 上限（发往该路由的请求超过时被截断为上限），0 表示不限制
+func (a *AppService) SetRouteMaxTokens(id int64, maxTokens int) error {
+	return a.RouteService.SetRouteMaxTokens(id, maxTokens)
+}
+
+// SetRouteQuota 设置路由的每日 token 和请求数上限（达到后当天选择路由时跳过），0 表示不限制
+func (a *AppService) SetRouteQuota(id int64, dailyTokens int64, dailyRequests int) error {
+	return a.RouteService.SetRouteQuota(id, dailyTokens, dailyRequests)
+}
+
+// TestRoute 向路由发送一次最小的对话请求，返回状态、延迟、请求地址、响应片段和失败分类
+func (a *AppService) TestRoute(id int64) (service.RouteTestResult, error) {
+	return a.ProxyService.TestRoute(id)
+}
+
+// SetRouteUpstreamModel 设置路由发往上游的模型名（客户端仍使用路由的模型名请求），为空时不替换
+func (a *AppService) SetRouteUpstreamModel(id int64, upstreamModel string) error {
+	return a.RouteService.SetRouteUpstreamModel(id, upstreamModel)
+}
+
+// SetRouteProviderPrefs 设置 OpenRouter 路由的供应商偏好（provider、transforms），为空时不附加
+func (a *AppService) SetRouteProviderPrefs(id int64, prefs string) error {
+	return a.RouteService.SetRouteProviderPrefs(id, prefs)
+}
+
+// GetPromptTemplates 获取路由/分组的系统提示词模板
+func (a *AppService) GetPromptTemplates() ([]service.PromptTemplate, error) {
+	return a.RouteService.GetPromptTemplates()
+}
+
+// SavePromptTemplate 新增或更新系统提示词模板
+func (a *AppService) SavePromptTemplate(template service.PromptTemplate) (service.PromptTemplate, error) {
+	return a.RouteService.SavePromptTemplate(template)
+}
+
+// DeletePromptTemplate 删除系统提示词模板
+func (a *AppService) DeletePromptTemplate(id int64) error {
+	return a.RouteService.DeletePromptTemplate(id)
+}
+
+// GetModelMetadata 获取模型元数据（模型列表接口返回的显示名、上下文长度、能力等）
+func (a *AppService) GetModelMetadata() ([]service.ModelMetadata, error) {
+	return a.RouteService.GetModelMetadata()
+}
+
+// SaveModelMetadata 新增或更新模型元数据
+func (a *AppService) SaveModelMetadata(metadata service.ModelMetadata) (service.ModelMetadata, error) {
+	return a.RouteService.SaveModelMetadata(metadata)
+}
+
+// DeleteModelMetadata 删除模型元数据
+func (a *AppService) DeleteModelMetadata(id int64) error {
+	return a.RouteService.DeleteModelMetadata(id)
+}
+
+// GetMirrorRules 获取模型的流量镜像规则
+func (a *AppService) GetMirrorRules() ([]service.MirrorRule, error) {
+	return a.RouteService.GetMirrorRules()
+}
+
+// SaveMirrorRule 新增或更新流量镜像规则
+func (a *AppService) SaveMirrorRule(rule service.MirrorRule) (service.MirrorRule, error) {
+	return a.RouteService.SaveMirrorRule(rule)
+}
+
+// DeleteMirrorRule 删除流量镜像规则
+func (a *AppService) DeleteMirrorRule(id int64) error {
+	return a.RouteService.DeleteMirrorRule(id)
+}
+
+// GetMirrorSummary 获取主路由与影子路由的对比汇总
+func (a *AppService) GetMirrorSummary() ([]service.MirrorSummary, error) {
+	return a.RouteService.GetMirrorSummary()
+}
+
+// GetMirrorResults 获取最近的镜像对比结果
+func (a *AppService) GetMirrorResults(model string, limit int) ([]service.MirrorResult, error) {
+	return a.RouteService.GetMirrorResults(model, limit)
+}
+
+// ClearMirrorResults 清空镜像对比结果
+func (a *AppService) ClearMirrorResults() error {
+	return a.RouteService.ClearMirrorResults()
+}
+
+// GetModelSyncSources 获取模型列表自动同步的来源
+func (a *AppService) GetModelSyncSources() ([]service.ModelSyncSource, error) {
+	return a.RouteService.GetModelSyncSources()
+}
+
+// SaveModelSyncSource 新增或更新模型同步来源
+func (a *AppService) SaveModelSyncSource(source service.ModelSyncSource) (service.ModelSyncSource, error) {
+	return a.RouteService.SaveModelSyncSource(source)
+}
+
+// DeleteModelSyncSource 删除模型同步来源
+func (a *AppService) DeleteModelSyncSource(id int64) error {
+	return a.RouteService.DeleteModelSyncSource(id)
+}
+
+// SyncModelSourceNow 立即同步一个来源，返回待审批的变更
+func (a *AppService) SyncModelSourceNow(id int64) ([]service.ModelSyncChange, error) {
+	if a.ModelSyncer == nil {
+		return nil, fmt.Errorf("model syncer not initialized")
+	}
+	return a.ModelSyncer.Sync(id)
+}
+
+// GetModelSyncChanges 获取待审批的模型变更，sourceID 为 0 时返回所有来源
+func (a *AppService) GetModelSyncChanges(sourceID int64) ([]service.ModelSyncChange, error) {
+	return a.RouteService.GetModelSyncChanges(sourceID)
+}
+
+// ApplyModelSyncChange 审批模型变更（创建、禁用或重新启用路由）
+func (a *AppService) ApplyModelSyncChange(id int64) error {
+	return a.RouteService.ApplyModelSyncChange(id)
+}
+
+// DismissModelSyncChange 忽略模型变更
+func (a *AppService) DismissModelSyncChange(id int64) error {
+	return a.RouteService.DismissModelSyncChange(id)
+}
+
+// StartPlayground 通过本地 API 发送 Playground 请求，返回会话ID
+func (a *AppService) StartPlayground(req service.PlaygroundRequest) (string, error) {
+	if a.APIServer == nil {
+		return "", fmt.Errorf("API server not running")
+	}
+	return a.ProxyService.StartPlayground(a.APIServer.Handler(), req)
+}
+
+// GetPlaygroundSession 获取 Playground 会话的回复和上游请求/响应
+func (a *AppService) GetPlaygroundSession(id string) (service.PlaygroundSession, error) {
+	return a.ProxyService.GetPlaygroundSession(id)
+}
+
+// CancelPlayground 中止 Playground 请求
+func (a *AppService) CancelPlayground(id string) error {
+	return a.ProxyService.CancelPlayground(id)
+}
+
+// GetActiveRequests 进行中的代理请求（开始时间、模型、路由、客户端 IP、已发送字节数）
+func (a *AppService) GetActiveRequests() []service.ActiveRequest {
+	return a.ProxyService.GetActiveRequests()
+}
+
+// CancelActiveRequest 中止进行中的代理请求（断开上游连接）
+func (a *AppService) CancelActiveRequest(requestID string) error {
+	return a.ProxyService.CancelRequest(requestID)
+}
+
+// CompareConversation 将同一对话并发发送到多个路由，返回各路由的回复、延迟和 Token 用量
+func (a *AppService) CompareConversation(req service.ConversationRequest) (*service.ConversationCompareResponse, error) {
+	return service.NewConversationService(a.RouteService, a.ProxyService, a.Config).CompareConversation(req, map[string]string{})
+}
+
+// AggregateConversation 将同一对话发送到多个路由，按策略（最快、多数投票、裁判模型）选出一个回复
+func (a *AppService) AggregateConversation(req service.ConversationRequest) (*service.ConversationAggregateResponse, error) {
+	return service.NewConversationService(a.RouteService, a.ProxyService, a.Config).AggregateConversation(req, map[string]string{})
+}
+
+// GetExperiments 获取 A/B 实验列表
+func (a *AppService) GetExperiments() ([]service.Experiment, error) {
+	return a.RouteService.GetExperiments()
+}
+
+// SaveExperiment 新增或更新 A/B 实验
+func (a *AppService) SaveExperiment(experiment service.Experiment) (service.Experiment, error) {
+	return a.RouteService.SaveExperiment(experiment)
+}
+
+// DeleteExperiment 删除 A/B 实验
+func (a *AppService) DeleteExperiment(id int64) error {
+	return a.RouteService.DeleteExperiment(id)
+}
+
+// GetExperimentReport 获取 A/B 实验各分组的对比报告
+func (a *AppService) GetExperimentReport(id int64) (*service.ExperimentReport, error) {
+	return a.RouteService.GetExperimentReport(id)
+}
+
 // DeleteRoute 删除路由
 func (a *AppService) DeleteRoute(id int64) error {
 	return a.RouteService.DeleteRoute(id)
@@ -220,11 +553,26 @@ func (a *AppService) GetModelRanking(limit int) ([]map[string]interface{}, error
 	return a.RouteService.GetModelRanking(limit)
 }
 
+// GetStatsBreakdown 按维度（model、route、group、provider、style、virtual_key）汇总最近 days 天的用量和费用，days <= 0 表示全部
+func (a *AppService) GetStatsBreakdown(dimension string, days int) ([]service.StatsBreakdownItem, error) {
+	return a.RouteService.GetStatsBreakdown(dimension, days, a.Config)
+}
+
+// GetLatencyStats 最近 hours 小时成功请求的延迟分位数（P50/P95/P99），按模型、按路由和按时间段
+func (a *AppService) GetLatencyStats(hours int) (*service.LatencyStats, error) {
+	return a.RouteService.GetLatencyStats(hours)
+}
+
+// GetErrorStats 最近 hours 小时失败请求按错误类型、路由和时间段的统计，以及最近的失败请求
+func (a *AppService) GetErrorStats(hours int) (*service.ErrorStats, error) {
+	return a.RouteService.GetErrorStats(hours)
+}
+
 // GetConfig 获取配置
 func (a *AppService) GetConfig() map[string]interface{} {
 	return map[string]interface{}{
 		"localApiKey":           a.Config.LocalAPIKey,
-		"openaiEndpoint":        fmt.Sprintf("http://%s:%d", a.Config.Host, a.Config.Port),
+		"openaiEndpoint":        a.Config.BaseURL(),
 		"redirectEnabled":       a.Config.RedirectEnabled,
 		"redirectKeyword":       a.Config.RedirectKeyword,
 		"redirectTargetModel":   a.Config.RedirectTargetModel,
@@ -234,10 +582,14 @@ func (a *AppService) GetConfig() map[string]interface{} {
 		"autoStart":             a.Config.AutoStart,
 		"enableFileLog":         a.Config.EnableFileLog,
 		"fallbackEnabled":       a.Config.FallbackEnabled,
+		"proxyPaused":           a.ProxyService.IsPaused(),
 		"proxyEnabled":          a.Config.ProxyEnabled,
 		"tracesEnabled":         a.Config.TracesEnabled,
 		"tracesRetentionDays":   a.Config.TracesRetentionDays,
+		"traceStreamMaxKB":      a.Config.TraceStreamMaxKB,
+		"traceRedactionEnabled": a.Config.TraceRedactionEnabled,
 		"port":                  a.Config.Port,
+		"tlsEnabled":            a.Config.TLSEnabled,
 	}
 }
 
@@ -250,21 +602,199 @@ func (a *AppService) UpdateConfig(redirectEnabled bool, redirectKeyword, redirec
 	return a.Config.Save()
 }
 
-// UpdatePort 更新端口配置
+// UpdatePort 更新端口配置（立即重新绑定监听端口，无需重启）
 func (a *AppService) UpdatePort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port: %d", port)
+	}
+	oldPort := a.Config.Port
 	a.Config.Port = port
+	if a.APIServer != nil {
+		if err := a.APIServer.Rebind(a.Config.ListenAddrs()); err != nil {
+			a.Config.Port = oldPort
+			log.Errorf("Failed to rebind API server to port %d: %v", port, err)
+			return fmt.Errorf("failed to listen on port %d: %v", port, err)
+		}
+	}
 	return a.Config.Save()
 }
 
-// UpdateLocalApiKey 更新本地 API Key
+// UpdateLocalApiKey 更新本地 API Key（立即生效）
 func (a *AppService) UpdateLocalApiKey(newApiKey string) error {
-	a.Config.LocalAPIKey = newApiKey
+	a.Config.SetAuthKey(newApiKey)
 	return a.Config.Save()
 }
 
-// FetchRemoteModels 获取远程模型列表
+// ApplyConfig 重新读取 config.json 并应用到运行中的服务
+// 监听地址变化时重新绑定端口，API Key 原子切换，重定向等设置立即生效
+func (a *AppService) ApplyConfig() error {
+	log.Info("Applying configuration from config.json...")
+	oldProxyEnabled := a.Config.ProxyEnabled
+	oldCertPath, oldKeyPath := a.Config.CertPath, a.Config.KeyPath
+
+	if err := a.Config.Reload(); err != nil {
+		log.Errorf("Failed to reload config: %v", err)
+		return fmt.Errorf("failed to reload config: %v", err)
+	}
+
+	if a.APIServer != nil {
+		addrs := a.Config.ListenAddrs()
+		var err error
+		if a.Config.TLSEnabled != a.APIServer.IsTLS() || (a.Config.TLSEnabled && (oldCertPath != a.Config.CertPath || oldKeyPath != a.Config.KeyPath)) {
+			err = a.applyTLS(addrs)
+		} else {
+			err = a.APIServer.Rebind(addrs)
+		}
+		if err != nil {
+			log.Errorf("Failed to rebind API server to %v: %v", addrs, err)
+			return fmt.Errorf("failed to listen on %v: %v", addrs, err)
+		}
+	}
+
+	if a.ProxyService != nil && oldProxyEnabled != a.Config.ProxyEnabled {
+		a.ProxyService.UpdateProxySettings(a.Config.ProxyEnabled)
+	}
+
+	log.Info("Configuration applied successfully")
+	return nil
+}
+
+// PatchConfig 修改 config.json 中的部分字段（字段名与 config.json 相同，只允许 configPatchFields 中的字段）并立即应用
+func (a *AppService) PatchConfig(fields map[string]interface{}) error {
+	// 适配器插件会在本机执行配置的程序，只能在桌面端设置
+	if _, ok := fields["adapter_plugins"]; ok {
+		return fmt.Errorf("adapter_plugins can only be changed in the desktop app")
+	}
+	if err := checkConfigPatchFields(fields); err != nil {
+		return err
+	}
+	patch, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := a.Config.WritePatch(patch, validateConfigPatch(fields)); err != nil {
+		return err
+	}
+	return a.ApplyConfig()
+}
+
+// GetNetworkSettings 获取监听地址和 IP 白名单设置
+func (a *AppService) GetNetworkSettings() map[string]interface{} {
+	listenHosts := a.Config.ListenHosts
+	if listenHosts == nil {
+		listenHosts = []string{}
+	}
+	allowedCIDRs := a.Config.AllowedCIDRs
+	if allowedCIDRs == nil {
+		allowedCIDRs = []string{}
+	}
+	var addrs []string
+	if a.APIServer != nil {
+		addrs = a.APIServer.Addrs()
+	}
+	return map[string]interface{}{
+		"host":         a.Config.Host,
+		"listenHosts":  listenHosts,
+		"allowedCidrs": allowedCIDRs,
+		"activeAddrs":  addrs,
+	}
+}
+
+// SetNetworkSettings 设置额外监听地址和允许访问的客户端网段（立即生效）
+func (a *AppService) SetNetworkSettings(listenHosts, allowedCIDRs []string) error {
+	log.Infof("SetNetworkSettings called: listenHosts=%v, allowedCIDRs=%v", listenHosts, allowedCIDRs)
+	for _, entry := range allowedCIDRs {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return fmt.Errorf("invalid CIDR: %s", entry)
+		}
+	}
+
+	oldListenHosts := a.Config.ListenHosts
+	a.Config.ListenHosts = listenHosts
+	if a.APIServer != nil {
+		if err := a.APIServer.Rebind(a.Config.ListenAddrs()); err != nil {
+			a.Config.ListenHosts = oldListenHosts
+			log.Errorf("Failed to rebind API server: %v", err)
+			return fmt.Errorf("failed to listen: %v", err)
+		}
+	}
+	a.Config.AllowedCIDRs = allowedCIDRs
+
+	if err := a.Config.Save(); err != nil {
+		log.Errorf("Failed to save config: %v", err)
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	return nil
+}
+
+// GetTLSSettings 获取 HTTPS 设置
+func (a *AppService) GetTLSSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"tlsEnabled": a.Config.TLSEnabled,
+		"certPath":   a.Config.CertPath,
+		"keyPath":    a.Config.KeyPath,
+		"active":     a.APIServer != nil && a.APIServer.IsTLS(),
+		"endpoint":   a.Config.BaseURL(),
+	}
+}
+
+// SetTLSSettings 设置 HTTPS（立即重新监听，证书路径为空时自动生成自签名证书）
+func (a *AppService) SetTLSSettings(enabled bool, certPath, keyPath string) error {
+	log.Infof("SetTLSSettings called: enabled=%v, cert=%s, key=%s", enabled, certPath, keyPath)
+	oldEnabled, oldCertPath, oldKeyPath := a.Config.TLSEnabled, a.Config.CertPath, a.Config.KeyPath
+	a.Config.TLSEnabled = enabled
+	a.Config.CertPath = certPath
+	a.Config.KeyPath = keyPath
+
+	if a.APIServer != nil {
+		if err := a.applyTLS(a.Config.ListenAddrs()); err != nil {
+			a.Config.TLSEnabled, a.Config.CertPath, a.Config.KeyPath = oldEnabled, oldCertPath, oldKeyPath
+			log.Errorf("Failed to apply TLS settings: %v", err)
+			return err
+		}
+	}
+
+	if err := a.Config.Save(); err != nil {
+		log.Errorf("Failed to save config: %v", err)
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	return nil
+}
+
+// applyTLS 按当前配置加载证书并重新监听
+func (a *AppService) applyTLS(addrs []string) error {
+	var tlsConfig *tls.Config
+	if a.Config.TLSEnabled {
+		var err error
+		if tlsConfig, err = router.LoadTLSConfig(a.Config.CertPath, a.Config.KeyPath); err != nil {
+			return err
+		}
+	}
+	return a.APIServer.Reconfigure(addrs, tlsConfig)
+}
+
+// ImportExternalConfig 从 LiteLLM config.yaml、one-api 渠道导出或 OpenRouter 模型列表导入路由
+func (a *AppService) ImportExternalConfig(data, apiKey string) (*service.ExternalImportResult, error) {
+	return a.RouteService.ImportExternalConfig([]byte(data), apiKey)
+}
+
+// GetProviderTemplates 获取内置的供应商模板（新增路由时选择供应商自动填写地址和格式）
+func (a *AppService) GetProviderTemplates() []service.ProviderTemplate {
+	return service.GetProviderTemplates()
+}
+
+// FetchRemoteModels 获取远程模型列表（优先使用缓存）
 func (a *AppService) FetchRemoteModels(apiUrl, apiKey string) ([]string, error) {
-	return a.ProxyService.FetchRemoteModels(apiUrl, apiKey)
+	return a.ProxyService.GetRemoteModels(apiUrl, apiKey, false)
+}
+
+// RefreshRemoteModels 跳过缓存重新获取远程模型列表
+func (a *AppService) RefreshRemoteModels(apiUrl, apiKey string) ([]string, error) {
+	return a.ProxyService.GetRemoteModels(apiUrl, apiKey, true)
 }
 
 // ImportRouteFromFormat 从不同格式导入路由
@@ -362,6 +892,374 @@ func (a *AppService) SetEnableFileLog(enabled bool) error {
 	return nil
 }
 
+// GetLogSettings 获取日志设置
+func (a *AppService) GetLogSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"level":           a.Config.LogLevel,
+		"format":          a.Config.LogFormat,
+		"maxSizeMB":       a.Config.LogMaxSizeMB,
+		"maxBackups":      a.Config.LogMaxBackups,
+		"maxMessageBytes": a.Config.LogMaxMessageBytes,
+	}
+}
+
+// SetLogSettings 设置日志级别、格式和截断阈值（立即生效），滚动大小和备份数重启后生效
+func (a *AppService) SetLogSettings(level, format string, maxSizeMB, maxBackups, maxMessageBytes int) error {
+	if _, err := log.ParseLevel(level); err != nil {
+		return fmt.Errorf("invalid log level: %s", level)
+	}
+	if format != "json" {
+		format = "text"
+	}
+	if maxSizeMB < 1 {
+		maxSizeMB = 1
+	}
+	if maxBackups < 0 {
+		maxBackups = 0
+	}
+	if maxMessageBytes < 0 {
+		maxMessageBytes = 0
+	}
+	a.Config.LogLevel = level
+	a.Config.LogFormat = format
+	a.Config.LogMaxSizeMB = maxSizeMB
+	a.Config.LogMaxBackups = maxBackups
+	a.Config.LogMaxMessageBytes = maxMessageBytes
+	logging.Apply(level, format, maxMessageBytes)
+	log.Infof("Log settings updated: level=%s, format=%s", level, format)
+	return a.Config.Save()
+}
+
+// GetBodyLogSettings 获取代理请求头/请求体日志设置
+func (a *AppService) GetBodyLogSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"mode":          a.Config.BodyLogMode,
+		"samplePercent": a.Config.BodyLogSamplePercent,
+	}
+}
+
+// SetBodyLogSettings 设置代理请求头/请求体日志模式（立即生效）
+func (a *AppService) SetBodyLogSettings(mode string, samplePercent int) error {
+	switch mode {
+	case service.BodyLogOff, service.BodyLogHeaders, service.BodyLogSampled, service.BodyLogFull:
+	default:
+		return fmt.Errorf("invalid body log mode: %s", mode)
+	}
+	if samplePercent < 0 {
+		samplePercent = 0
+	}
+	if samplePercent > 100 {
+		samplePercent = 100
+	}
+	a.Config.BodyLogMode = mode
+	a.Config.BodyLogSamplePercent = samplePercent
+	log.Infof("Body log settings updated: mode=%s, sample=%d%%", mode, samplePercent)
+	return a.Config.Save()
+}
+
+// GetModerationSettings 获取内容审查设置
+func (a *AppService) GetModerationSettings() map[string]interface{} {
+	rules := a.Config.ModerationRules
+	if rules == nil {
+		rules = []config.ModerationRule{}
+	}
+	return map[string]interface{}{
+		"enabled": a.Config.ModerationEnabled,
+		"rules":   rules,
+	}
+}
+
+// SetModerationSettings 设置内容审查开关和规则（立即生效）
+func (a *AppService) SetModerationSettings(enabled bool, rules []config.ModerationRule) error {
+	if err := service.ValidateModerationRules(rules); err != nil {
+		return err
+	}
+	a.Config.ModerationEnabled = enabled
+	a.Config.ModerationRules = rules
+	log.Infof("Moderation settings updated: enabled=%v, rules=%d", enabled, len(rules))
+	return a.Config.Save()
+}
+
+// GetModelCompatSettings 获取模型参数兼容设置，defaultRules 为内置规则（用于恢复默认）
+func (a *AppService) GetModelCompatSettings() map[string]interface{} {
+	rules := a.Config.ModelCompatRules
+	if rules == nil {
+		rules = []config.ModelCompatRule{}
+	}
+	return map[string]interface{}{
+		"enabled":      a.Config.ModelCompatEnabled,
+		"rules":        rules,
+		"defaultRules": config.DefaultModelCompatRules(),
+	}
+}
+
+// SetModelCompatSettings 设置模型参数兼容开关和规则（立即生效）
+func (a *AppService) SetModelCompatSettings(enabled bool, rules []config.ModelCompatRule) error {
+	if err := service.ValidateModelCompatRules(rules); err != nil {
+		return err
+	}
+	a.Config.ModelCompatEnabled = enabled
+	a.Config.ModelCompatRules = rules
+	log.Infof("Model compatibility settings updated: enabled=%v, rules=%d", enabled, len(rules))
+	return a.Config.Save()
+}
+
+// GetClaudeMaxTokens 获取发往 Claude 的请求未指定 max_tokens 时填充的默认值（模型名或通配 -> max_tokens）
+func (a *AppService) GetClaudeMaxTokens() map[string]int {
+	if a.Config.ClaudeMaxTokens == nil {
+		return map[string]int{}
+	}
+	return a.Config.ClaudeMaxTokens
+}
+
+// SetClaudeMaxTokens 设置 Claude 请求的默认 max_tokens（立即生效）
+func (a *AppService) SetClaudeMaxTokens(defaults map[string]int) error {
+	if err := service.ValidateClaudeMaxTokens(defaults); err != nil {
+		return err
+	}
+	a.Config.ClaudeMaxTokens = defaults
+	log.Infof("Claude default max_tokens updated: %d rules", len(defaults))
+	return a.Config.Save()
+}
+
+// GetAdapterPlugins 获取适配器插件配置
+func (a *AppService) GetAdapterPlugins() []config.AdapterPlugin {
+	if a.Config.AdapterPlugins == nil {
+		return []config.AdapterPlugin{}
+	}
+	return a.Config.AdapterPlugins
+}
+
+// SetAdapterPlugins 设置适配器插件并重新加载（立即生效）
+func (a *AppService) SetAdapterPlugins(plugins []config.AdapterPlugin) error {
+	if err := service.ValidateAdapterPlugins(plugins); err != nil {
+		return err
+	}
+	a.Config.AdapterPlugins = plugins
+	a.ProxyService.ReloadAdapterPlugins()
+	log.Infof("Adapter plugins updated: %d plugins", len(plugins))
+	return a.Config.Save()
+}
+
+// GetRoutingRuleSettings 获取按请求特征路由的设置
+func (a *AppService) GetRoutingRuleSettings() map[string]interface{} {
+	rules := a.Config.RoutingRules
+	if rules == nil {
+		rules = []config.RoutingRule{}
+	}
+	return map[string]interface{}{
+		"enabled": a.Config.RoutingRulesEnabled,
+		"rules":   rules,
+	}
+}
+
+// SetRoutingRuleSettings 设置按请求特征路由的开关和规则（立即生效）
+func (a *AppService) SetRoutingRuleSettings(enabled bool, rules []config.RoutingRule) error {
+	if err := service.ValidateRoutingRules(rules); err != nil {
+		return err
+	}
+	a.Config.RoutingRulesEnabled = enabled
+	a.Config.RoutingRules = rules
+	log.Infof("Routing rule settings updated: enabled=%v, rules=%d", enabled, len(rules))
+	return a.Config.Save()
+}
+
+// GetContextOverflowSettings 获取上下文溢出处理设置
+func (a *AppService) GetContextOverflowSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled":      a.Config.ContextOverflowEnabled,
+		"strategy":     a.Config.ContextOverflowStrategy,
+		"summaryModel": a.Config.ContextSummaryModel,
+	}
+}
+
+// SetContextOverflowSettings 设置上下文溢出处理（立即生效）
+func (a *AppService) SetContextOverflowSettings(enabled bool, strategy, summaryModel string) error {
+	if err := service.ValidateContextStrategy(strategy); err != nil {
+		return err
+	}
+	a.Config.ContextOverflowEnabled = enabled
+	a.Config.ContextOverflowStrategy = strategy
+	a.Config.ContextSummaryModel = strings.TrimSpace(summaryModel)
+	log.Infof("Context overflow settings updated: enabled=%v, strategy=%s, summary model=%s", enabled, strategy, a.Config.ContextSummaryModel)
+	return a.Config.Save()
+}
+
+// GetQuotaSettings 获取路由每日配额的重置时区
+func (a *AppService) GetQuotaSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"timezone": a.Config.QuotaTimezone,
+	}
+}
+
+// SetQuotaSettings 设置路由每日配额的重置时区（IANA 名称），为空时使用本地时区（立即生效）
+func (a *AppService) SetQuotaSettings(timezone string) error {
+	timezone = strings.TrimSpace(timezone)
+	if err := a.RouteService.SetQuotaTimezone(timezone); err != nil {
+		return err
+	}
+	a.Config.QuotaTimezone = timezone
+	log.Infof("Quota settings updated: timezone=%s", timezone)
+	return a.Config.Save()
+}
+
+// NotificationSettingsInfo 通知设置
+type NotificationSettingsInfo struct {
+	Enabled          bool                   `json:"enabled"`
+	Desktop          bool                   `json:"desktop"`
+	Events           map[string]bool        `json:"events"` // 事件开关
+	Webhooks         []config.NotifyWebhook `json:"webhooks"`
+	CooldownMinutes  int                    `json:"cooldownMinutes"`
+	DailyTokenBudget int64                  `json:"dailyTokenBudget"`
+}
+
+// GetNotificationSettings 获取通知设置，events 包含所有可通知的事件
+func (a *AppService) GetNotificationSettings() NotificationSettingsInfo {
+	events := make(map[string]bool, len(service.NotifyEventTypes))
+	for _, event := range service.NotifyEventTypes {
+		enabled, ok := a.Config.NotifyEvents[event]
+		events[event] = !ok || enabled
+	}
+	webhooks := a.Config.NotifyWebhooks
+	if webhooks == nil {
+		webhooks = []config.NotifyWebhook{}
+	}
+	return NotificationSettingsInfo{
+		Enabled:          a.Config.NotificationsEnabled,
+		Desktop:          a.Config.NotifyDesktop,
+		Events:           events,
+		Webhooks:         webhooks,
+		CooldownMinutes:  a.Config.NotifyCooldownMinutes,
+		DailyTokenBudget: a.Config.DailyTokenBudget,
+	}
+}
+
+// SetNotificationSettings 设置通知（立即生效）
+func (a *AppService) SetNotificationSettings(settings NotificationSettingsInfo) error {
+	if err := service.ValidateNotifyWebhooks(settings.Webhooks); err != nil {
+		return err
+	}
+	if settings.CooldownMinutes < 0 {
+		settings.CooldownMinutes = 0
+	}
+	if settings.DailyTokenBudget < 0 {
+		settings.DailyTokenBudget = 0
+	}
+	a.Config.NotificationsEnabled = settings.Enabled
+	a.Config.NotifyDesktop = settings.Desktop
+	a.Config.NotifyEvents = settings.Events
+	a.Config.NotifyWebhooks = settings.Webhooks
+	a.Config.NotifyCooldownMinutes = settings.CooldownMinutes
+	a.Config.DailyTokenBudget = settings.DailyTokenBudget
+	log.Infof("Notification settings updated: enabled=%v, desktop=%v, webhooks=%d", settings.Enabled, settings.Desktop, len(settings.Webhooks))
+	return a.Config.Save()
+}
+
+// TestNotification 发送测试通知，返回各 Webhook 的发送错误（为空表示全部成功）
+func (a *AppService) TestNotification() map[string]string {
+	if a.Notifier == nil {
+		return map[string]string{}
+	}
+	return a.Notifier.SendTest()
+}
+
+// ReportSettingsInfo 定期用量报告设置
+type ReportSettingsInfo struct {
+	Enabled         bool                         `json:"enabled"`
+	Schedule        string                       `json:"schedule"` // daily, weekly
+	Hour            int                          `json:"hour"`
+	Weekday         int                          `json:"weekday"`
+	Template        string                       `json:"template"` // 为空使用默认模板
+	DefaultTemplate string                       `json:"defaultTemplate"`
+	Webhooks        bool                         `json:"webhooks"`
+	Email           config.ReportEmail           `json:"email"`
+	ModelPrices     map[string]config.ModelPrice `json:"modelPrices"`
+	Status          *service.ReportStatus        `json:"status,omitempty"`
+}
+
+// GetReportSettings 获取定期用量报告设置和最近一次发送结果
+func (a *AppService) GetReportSettings() ReportSettingsInfo {
+	email := a.Config.ReportEmail
+	if email.To == nil {
+		email.To = []string{}
+	}
+	prices := a.Config.ModelPrices
+	if prices == nil {
+		prices = map[string]config.ModelPrice{}
+	}
+	info := ReportSettingsInfo{
+		Enabled:         a.Config.ReportEnabled,
+		Schedule:        a.Config.ReportSchedule,
+		Hour:            a.Config.ReportHour,
+		Weekday:         a.Config.ReportWeekday,
+		Template:        a.Config.ReportTemplate,
+		DefaultTemplate: service.DefaultReportTemplate,
+		Webhooks:        a.Config.ReportWebhooks,
+		Email:           email,
+		ModelPrices:     prices,
+	}
+	if a.Reporter != nil {
+		status := a.Reporter.Status()
+		info.Status = &status
+	}
+	return info
+}
+
+// SetReportSettings 设置定期用量报告（下次检查时生效）
+func (a *AppService) SetReportSettings(settings ReportSettingsInfo) error {
+	if settings.Schedule != "daily" && settings.Schedule != "weekly" {
+		return fmt.Errorf("unsupported report schedule %q", settings.Schedule)
+	}
+	if settings.Hour < 0 || settings.Hour > 23 {
+		return fmt.Errorf("report hour must be between 0 and 23")
+	}
+	if settings.Weekday < 0 || settings.Weekday > 6 {
+		return fmt.Errorf("report weekday must be between 0 and 6")
+	}
+	if err := service.ValidateReportTemplate(settings.Template); err != nil {
+		return fmt.Errorf("invalid report template: %v", err)
+	}
+	if settings.Email.Enabled && (settings.Email.SMTPHost == "" || settings.Email.From == "" || len(settings.Email.To) == 0) {
+		return fmt.Errorf("smtp host, from and to are required for email reports")
+	}
+	for model, price := range settings.ModelPrices {
+		if price.Input < 0 || price.Output < 0 {
+			return fmt.Errorf("price for %s must not be negative", model)
+		}
+	}
+	a.Config.ReportEnabled = settings.Enabled
+	a.Config.ReportSchedule = settings.Schedule
+	a.Config.ReportHour = settings.Hour
+	a.Config.ReportWeekday = settings.Weekday
+	a.Config.ReportTemplate = settings.Template
+	a.Config.ReportWebhooks = settings.Webhooks
+	a.Config.ReportEmail = settings.Email
+	a.Config.ModelPrices = settings.ModelPrices
+	log.Infof("Report settings updated: enabled=%v, schedule=%s, hour=%d", settings.Enabled, settings.Schedule, settings.Hour)
+	return a.Config.Save()
+}
+
+// FetchOpenRouterPrices 从 OpenRouter 模型目录获取 OpenRouter 路由所用模型的价格（每百万 token），不保存
+func (a *AppService) FetchOpenRouterPrices() (map[string]config.ModelPrice, error) {
+	return a.ProxyService.FetchOpenRouterPrices()
+}
+
+// PreviewUsageReport 按当前设置生成最近一个周期的报告（不发送）
+func (a *AppService) PreviewUsageReport() (string, error) {
+	if a.Reporter == nil {
+		return "", fmt.Errorf("report scheduler not initialized")
+	}
+	return a.Reporter.Preview()
+}
+
+// SendUsageReportNow 立即发送最近一个周期的报告
+func (a *AppService) SendUsageReportNow() (service.ReportStatus, error) {
+	if a.Reporter == nil {
+		return service.ReportStatus{}, fmt.Errorf("report scheduler not initialized")
+	}
+	return a.Reporter.SendNow(), nil
+}
+
 // SetFallbackEnabled 设置是否启用故障转移
 func (a *AppService) SetFallbackEnabled(enabled bool) error {
 	log.Infof("Setting fallback enabled: %v", enabled)
@@ -376,6 +1274,92 @@ func (a *AppService) SetFallbackEnabled(enabled bool) error {
 	return nil
 }
 
+// GetRetrySettings 获取同一路由重试策略
+func (a *AppService) GetRetrySettings() map[string]interface{} {
+	return map[string]interface{}{
+		"maxAttempts": a.Config.RetryMaxAttempts,
+		"baseDelayMs": a.Config.RetryBaseDelayMs,
+		"maxDelayMs":  a.Config.RetryMaxDelayMs,
+		"jitter":      a.Config.RetryJitter,
+	}
+}
+
+// SetRetrySettings 设置同一路由重试策略（指数退避 + 抖动）
+func (a *AppService) SetRetrySettings(maxAttempts, baseDelayMs, maxDelayMs int, jitter float64) error {
+	log.Infof("Setting retry policy: attempts=%d, base=%dms, max=%dms, jitter=%.2f", maxAttempts, baseDelayMs, maxDelayMs, jitter)
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	if jitter < 0 || jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	a.Config.RetryMaxAttempts = maxAttempts
+	a.Config.RetryBaseDelayMs = baseDelayMs
+	a.Config.RetryMaxDelayMs = maxDelayMs
+	a.Config.RetryJitter = jitter
+
+	if err := a.Config.Save(); err != nil {
+		log.Errorf("Failed to save config: %v", err)
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	return nil
+}
+
+// GetAdaptiveTimeoutSettings 获取自适应超时设置
+func (a *AppService) GetAdaptiveTimeoutSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled": a.Config.AdaptiveTimeoutEnabled,
+		"factor":  a.Config.AdaptiveTimeoutFactor,
+		"minMs":   a.Config.AdaptiveTimeoutMinMs,
+		"maxMs":   a.Config.AdaptiveTimeoutMaxMs,
+	}
+}
+
+// SetAdaptiveTimeoutSettings 设置自适应超时（仅对非流式请求生效）
+func (a *AppService) SetAdaptiveTimeoutSettings(enabled bool, factor float64, minMs, maxMs int) error {
+	log.Infof("Setting adaptive timeout: enabled=%v, factor=%.1f, min=%dms, max=%dms", enabled, factor, minMs, maxMs)
+	if factor <= 1 {
+		return fmt.Errorf("factor must be greater than 1")
+	}
+	if maxMs > 0 && minMs > maxMs {
+		return fmt.Errorf("min timeout must not exceed max timeout")
+	}
+	a.Config.AdaptiveTimeoutEnabled = enabled
+	a.Config.AdaptiveTimeoutFactor = factor
+	a.Config.AdaptiveTimeoutMinMs = minMs
+	a.Config.AdaptiveTimeoutMaxMs = maxMs
+
+	if err := a.Config.Save(); err != nil {
+		log.Errorf("Failed to save config: %v", err)
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	return nil
+}
+
+// GetRequestLimits 获取请求护栏设置
+func (a *AppService) GetRequestLimits() map[string]interface{} {
+	return map[string]interface{}{
+		"maxBodyMB":   a.Config.MaxRequestBodyMB,
+		"maxMessages": a.Config.MaxRequestMessages,
+	}
+}
+
+// SetRequestLimits 设置请求体大小(MB)和消息数上限，0 表示不限制
+func (a *AppService) SetRequestLimits(maxBodyMB, maxMessages int) error {
+	log.Infof("Setting request limits: body=%dMB, messages=%d", maxBodyMB, maxMessages)
+	if maxBodyMB < 0 || maxMessages < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	a.Config.MaxRequestBodyMB = maxBodyMB
+	a.Config.MaxRequestMessages = maxMessages
+
+	if err := a.Config.Save(); err != nil {
+		log.Errorf("Failed to save config: %v", err)
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	return nil
+}
+
 // GetProxyEnabled 获取是否启用系统代理
 func (a *AppService) GetProxyEnabled() bool {
 	return a.Config.ProxyEnabled
@@ -404,15 +1388,19 @@ func (a *AppService) SetProxyEnabled(enabled bool) error {
 func (a *AppService) RestartApp() error {
 	log.Info("Restarting application...")
 
-	// 获取当前可执行文件路径
+	// 获取当前可执行文件路径（更新后文件已被替换，使用启动时记录的路径）
 	executable, err := os.Executable()
+	if a.Updater != nil && a.Updater.ExecutablePath() != "" {
+		executable, err = a.Updater.ExecutablePath(), nil
+	}
 	if err != nil {
 		log.Errorf("Failed to get executable path: %v", err)
 		return fmt.Errorf("failed to get executable path: %v", err)
 	}
 
-	// 启动新进程
+	// 启动新进程，新进程等待当前进程退出（释放单实例锁和端口）后再启动
 	cmd := exec.Command(executable)
+	cmd.Env = append(os.Environ(), RestartEnv+"=1")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -444,6 +1432,104 @@ func (a *AppService) CompressDatabase() (map[string]interface{}, error) {
 	return result, nil
 }
 
+// GetMaintenanceSettings 获取后台定期维护设置和最近一次执行结果
+func (a *AppService) GetMaintenanceSettings() map[string]interface{} {
+	result := map[string]interface{}{
+		"enabled":             a.Config.MaintenanceEnabled,
+		"intervalHours":       a.Config.MaintenanceIntervalHours,
+		"logRetentionDays":    a.Config.LogRetentionDays,
+		"vacuumIntervalHours": a.Config.VacuumIntervalHours,
+	}
+	if a.Maintenance != nil {
+		result["status"] = a.Maintenance.Status()
+	}
+	return result
+}
+
+// SetMaintenanceSettings 设置后台定期维护（下次检查时生效）
+func (a *AppService) SetMaintenanceSettings(enabled bool, intervalHours, logRetentionDays, vacuumIntervalHours int) error {
+	if intervalHours < 1 {
+		intervalHours = 1
+	}
+	if logRetentionDays < 0 {
+		logRetentionDays = 0
+	}
+	if vacuumIntervalHours < 0 {
+		vacuumIntervalHours = 0
+	}
+	a.Config.MaintenanceEnabled = enabled
+	a.Config.MaintenanceIntervalHours = intervalHours
+	a.Config.LogRetentionDays = logRetentionDays
+	a.Config.VacuumIntervalHours = vacuumIntervalHours
+	return a.Config.Save()
+}
+
+// RunMaintenanceNow 立即执行一次维护（清理 Traces、压缩日志、VACUUM）
+func (a *AppService) RunMaintenanceNow() (service.MaintenanceStatus, error) {
+	if a.Maintenance == nil {
+		return service.MaintenanceStatus{}, fmt.Errorf("maintenance scheduler not initialized")
+	}
+	return a.Maintenance.RunNow(), nil
+}
+
+// GetHealthProbeSettings 获取主动健康探测设置和最近一次探测结果
+func (a *AppService) GetHealthProbeSettings() map[string]interface{} {
+	result := map[string]interface{}{
+		"enabled":         a.Config.HealthProbeEnabled,
+		"intervalSeconds": a.Config.HealthProbeIntervalSeconds,
+		"mode":            a.Config.HealthProbeMode,
+		"healthAware":     a.Config.HealthAwareRouting,
+	}
+	if a.HealthProber != nil {
+		result["status"] = a.HealthProber.Status()
+	}
+	return result
+}
+
+// SetHealthProbeSettings 设置主动健康探测（下次检查时生效）
+func (a *AppService) SetHealthProbeSettings(enabled bool, intervalSeconds int, mode string, healthAware bool) error {
+	if intervalSeconds < 30 {
+		intervalSeconds = 30
+	}
+	if mode != service.HealthProbeCompletion {
+		mode = service.HealthProbeModels
+	}
+	a.Config.HealthProbeEnabled = enabled
+	a.Config.HealthProbeIntervalSeconds = intervalSeconds
+	a.Config.HealthProbeMode = mode
+	a.Config.HealthAwareRouting = healthAware
+	a.RouteService.SetHealthAwareRouting(healthAware)
+	return a.Config.Save()
+}
+
+// RunHealthProbeNow 立即探测所有启用的路由
+func (a *AppService) RunHealthProbeNow() (service.HealthProbeStatus, error) {
+	if a.HealthProber == nil {
+		return service.HealthProbeStatus{}, fmt.Errorf("health prober not initialized")
+	}
+	return a.HealthProber.RunNow(), nil
+}
+
+// GetClientSnippets 获取各客户端（Cursor、Claude Code、openai-python、LangChain、Continue）的配置片段
+func (a *AppService) GetClientSnippets(model string) []service.ClientSnippet {
+	return service.NewConversationService(a.RouteService, a.ProxyService, a.Config).GetClientSnippets(model, "")
+}
+
+// GetLogBufferStatus 获取请求日志缓冲状态（数据库锁定或不可用时日志暂存在内存中）
+func (a *AppService) GetLogBufferStatus() map[string]interface{} {
+	return a.RouteService.GetLogBufferStatus()
+}
+
+// GetSchemaDriftWarnings 获取上游响应结构变化告警（缺少 usage、新的 finish_reason、字段变化等）
+func (a *AppService) GetSchemaDriftWarnings() []service.SchemaDriftWarning {
+	return a.ProxyService.GetSchemaDriftWarnings()
+}
+
+// ClearSchemaDriftWarnings 清除结构变化告警，resetBaseline 为 true 时重新学习响应结构
+func (a *AppService) ClearSchemaDriftWarnings(resetBaseline bool) {
+	a.ProxyService.ClearSchemaDriftWarnings(resetBaseline)
+}
+
 // GetUsageSummary 获取用量汇总（周/年/总用量）
 func (a *AppService) GetUsageSummary() (map[string]interface{}, error) {
 	return a.RouteService.GetUsageSummary()
@@ -459,7 +1545,9 @@ type RequestLogsResult struct {
 
 // GetRequestLogs 获取请求日志（支持分页和筛选）
 // startTime/endTime format: "2006-01-02 15:04:05" or empty string
-func (a *AppService) GetRequestLogs(page, pageSize int, model, style, success, startTime, endTime string) (RequestLogsResult, error) {
+// requestID: 按 X-Request-ID 精确查找，空字符串表示不筛选
+// search: 全文搜索错误信息，多个词用空格分隔（需同时匹配）
+func (a *AppService) GetRequestLogs(page, pageSize int, model, style, success, startTime, endTime, requestID, search string) (RequestLogsResult, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -484,6 +1572,12 @@ func (a *AppService) GetRequestLogs(page, pageSize int, model, style, success, s
 	if endTime != "" {
 		filters["end_time"] = endTime
 	}
+	if requestID != "" {
+		filters["request_id"] = requestID
+	}
+	if search != "" {
+		filters["search"] = search
+	}
 
 	logs, total, err := a.RouteService.GetRequestLogs(page, pageSize, filters)
 	if err != nil {
@@ -504,12 +1598,14 @@ func (a *AppService) GetRequestLogs(page, pageSize int, model, style, success, s
 			"total_tokens":    l.TotalTokens,
 			"success":         l.Success,
 			"error_message":   l.ErrorMessage,
+			"error_type":      l.ErrorType,
 			"style":           l.Style,
 			"user_agent":      l.UserAgent,
 			"remote_ip":       l.RemoteIP,
 			"proxy_time_ms":   l.ProxyTimeMs,
 			"first_chunk_ms":  l.FirstChunkMs,
 			"is_stream":       l.IsStream,
+			"request_id":      l.RequestID,
 			"created_at":      l.CreatedAt.Format("2006-01-02 15:04:05"),
 		}
 	}
@@ -522,6 +1618,55 @@ func (a *AppService) GetRequestLogs(page, pageSize int, model, style, success, s
 	}, nil
 }
 
+// GetRequestLogExportColumns 获取请求日志可导出的列（默认顺序）
+func (a *AppService) GetRequestLogExportColumns() []string {
+	return service.RequestLogExportColumns()
+}
+
+// ExportRequestLogs 将请求日志导出为 CSV/JSON 文件：弹出保存对话框选择路径后逐行写入，取消时返回的 Path 为空
+// dateFrom/dateTo: 2006-01-02 或 2006-01-02 15:04:05，为空表示不限；filters 与 GetRequestLogs 的筛选条件相同；columns 为空时导出全部列
+func (a *AppService) ExportRequestLogs(dateFrom, dateTo string, filters map[string]string, format string, columns []string) (*service.RequestLogExportResult, error) {
+	if a.App == nil {
+		return nil, fmt.Errorf("save dialog is not available, use GET /api/admin/logs/export instead")
+	}
+	opts := service.RequestLogExportOptions{DateFrom: dateFrom, DateTo: dateTo, Filters: filters, Format: format, Columns: columns}
+	if err := opts.Normalize(); err != nil {
+		return nil, err
+	}
+
+	path, err := a.App.Dialog.SaveFile().
+		SetFilename(opts.Filename()).
+		AddFilter(strings.ToUpper(opts.Format), "*."+opts.Format).
+		CanCreateDirectories(true).
+		PromptForSingleSelection()
+	if err != nil {
+		return nil, err
+	}
+	result := &service.RequestLogExportResult{Path: path, Format: opts.Format}
+	if path == "" {
+		return result, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(file)
+	result.Count, err = a.RouteService.ExportRequestLogs(w, opts, a.Config)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	log.Infof("Exported %d request logs to %s", result.Count, path)
+	return result, nil
+}
+
 // RouteHealthInfo represents health information for a single route (frontend binding)
 type RouteHealthInfo struct {
 	ID            int64   `json:"id"`
@@ -530,6 +1675,10 @@ type RouteHealthInfo struct {
 	StatusHistory []bool  `json:"status_history"` // Last N requests, true=success, index 0 is oldest
 	SuccessRate   float64 `json:"success_rate"`
 	TotalRequests int     `json:"total_requests"`
+	ProbeHistory   []bool `json:"probe_history"` // 最近的主动探测结果，index 0 is oldest
+	ProbeLatencyMs int64  `json:"probe_latency_ms"`
+	LastProbeAt    string `json:"last_probe_at"`
+	LastProbeError string `json:"last_probe_error"`
 }
 
 // GroupHealthInfo represents health information for a group of routes (frontend binding)
@@ -561,6 +1710,10 @@ func (a *AppService) GetHealthStatus() ([]GroupHealthInfo, error) {
 				StatusHistory: r.StatusHistory,
 				SuccessRate:   r.SuccessRate,
 				TotalRequests: r.TotalRequests,
+				ProbeHistory:   r.ProbeHistory,
+				ProbeLatencyMs: r.ProbeLatencyMs,
+				LastProbeAt:    r.LastProbeAt,
+				LastProbeError: r.LastProbeError,
 			})
 		}
 		groups = append(groups, GroupHealthInfo{
@@ -574,6 +1727,15 @@ func (a *AppService) GetHealthStatus() ([]GroupHealthInfo, error) {
 	return groups, nil
 }
 
+// GetMetrics 获取运行指标（上游连接池）
+func (a *AppService) GetMetrics() map[string]interface{} {
+	result := map[string]interface{}{}
+	if a.ProxyService != nil {
+		result["connection_pool"] = a.ProxyService.ConnectionStats()
+	}
+	return result
+}
+
 // ================== Traces 相关方法 ==================
 
 // TraceSessionInfo 会话信息结构体（前端）
@@ -583,6 +1745,7 @@ type TraceSessionInfo struct {
 	TraceCount    int    `json:"trace_count"`
 	FirstTraceAt  string `json:"first_trace_at"`
 	LastTraceAt   string `json:"last_trace_at"`
+	Models        string `json:"models"`
 }
 
 // TraceDetailInfo 对话详情结构体（前端）
@@ -603,9 +1766,99 @@ type TraceDetailInfo struct {
 	Style           string `json:"style"`
 	IsStream        bool   `json:"is_stream"`
 	ProxyTimeMs     int64  `json:"proxy_time_ms"`
+	RequestID       string `json:"request_id"`
+	ParentID        int64  `json:"parent_id"`  // 上一轮对话的 Trace ID
+	ClientKey       string `json:"client_key"` // 客户端提供的会话标识
 	CreatedAt       string `json:"created_at"`
 }
 
+// newTraceDetailInfo 转换为前端结构，redactor 不为空时脱敏请求/响应/错误信息中的密钥
+func newTraceDetailInfo(t database.ConversationTrace, redactor *service.SecretRedactor) TraceDetailInfo {
+	if redactor != nil {
+		t.RequestContent = redactor.Redact(t.RequestContent)
+		t.ResponseContent = redactor.Redact(t.ResponseContent)
+		t.ErrorMessage = redactor.Redact(t.ErrorMessage)
+	}
+	return TraceDetailInfo{
+		ID:              t.ID,
+		SessionID:       t.SessionID,
+		RemoteIP:        t.RemoteIP,
+		Model:           t.Model,
+		ProviderModel:   t.ProviderModel,
+		ProviderName:    t.ProviderName,
+		RequestContent:  t.RequestContent,
+		ResponseContent: t.ResponseContent,
+		RequestTokens:   t.RequestTokens,
+		ResponseTokens:  t.ResponseTokens,
+		TotalTokens:     t.TotalTokens,
+		Success:         t.Success,
+		ErrorMessage:    t.ErrorMessage,
+		Style:           t.Style,
+		IsStream:        t.IsStream,
+		ProxyTimeMs:     t.ProxyTimeMs,
+		RequestID:       t.RequestID,
+		ParentID:        t.ParentID,
+		ClientKey:       t.ClientKey,
+		CreatedAt:       t.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// traceRedactor 未关闭脱敏时返回脱敏器，除规则匹配外还会替换本地 Key 和所有路由的上游 Key
+func (a *AppService) traceRedactor() *service.SecretRedactor {
+	if !a.Config.TraceRedactionEnabled {
+		return nil
+	}
+	known := []string{a.Config.AuthKey()}
+	if routes, err := a.RouteService.GetAllRoutes(); err == nil {
+		for _, route := range routes {
+			known = append(known, service.SplitAPIKeys(route.APIKey)...)
+		}
+	}
+	return service.NewSecretRedactor(a.Config.TraceRedactionPatterns, known)
+}
+
+// checkAdminKey 校验管理员口令（即本地 API Key），用于查看原文等敏感操作
+func (a *AppService) checkAdminKey(adminKey string) error {
+	if adminKey == "" || subtle.ConstantTimeCompare([]byte(adminKey), []byte(a.Config.AuthKey())) != 1 {
+		return fmt.Errorf("invalid admin key")
+	}
+	return nil
+}
+
+// GetTraceRedactionEnabled 获取 Traces 自动脱敏是否启用
+func (a *AppService) GetTraceRedactionEnabled() bool {
+	return a.Config.TraceRedactionEnabled
+}
+
+// SetTraceRedactionEnabled 启用/关闭 Traces 自动脱敏，关闭需要管理员口令
+func (a *AppService) SetTraceRedactionEnabled(enabled bool, adminKey string) error {
+	if !enabled {
+		if err := a.checkAdminKey(adminKey); err != nil {
+			return err
+		}
+	}
+	a.Config.TraceRedactionEnabled = enabled
+	return a.Config.Save()
+}
+
+// RevealTrace 查看未脱敏的单条 Trace，需要管理员口令
+func (a *AppService) RevealTrace(id int64, adminKey string) (TraceDetailInfo, error) {
+	if err := a.checkAdminKey(adminKey); err != nil {
+		return TraceDetailInfo{}, err
+	}
+	trace, err := a.RouteService.GetTraceByID(id)
+	if err != nil {
+		return TraceDetailInfo{}, err
+	}
+	log.Infof("Revealed unredacted trace %d", id)
+	return newTraceDetailInfo(*trace, nil), nil
+}
+
+// GetTraceConversion 获取 Trace 适配器转换前后的请求和响应，用于对比差异
+func (a *AppService) GetTraceConversion(id int64) (service.TraceConversion, error) {
+	return a.RouteService.GetTraceConversion(id, a.traceRedactor())
+}
+
 // GetTracesEnabled 获取 Traces 功能是否启用
 func (a *AppService) GetTracesEnabled() bool {
 	return a.Config.TracesEnabled
@@ -631,6 +1884,20 @@ func (a *AppService) SetTracesRetentionDays(days int) error {
 	return a.Config.Save()
 }
 
+// GetTraceStreamMaxKB 获取流式响应写入 Trace 的最大长度（KB）
+func (a *AppService) GetTraceStreamMaxKB() int {
+	return a.Config.TraceStreamMaxKB
+}
+
+// SetTraceStreamMaxKB 设置流式响应写入 Trace 的最大长度（KB），0 表示不记录内容
+func (a *AppService) SetTraceStreamMaxKB(kb int) error {
+	if kb < 0 {
+		kb = 0
+	}
+	a.Config.TraceStreamMaxKB = kb
+	return a.Config.Save()
+}
+
 // GetTracesSessionTimeout 获取 Traces 会话超时（分钟）
 func (a *AppService) GetTracesSessionTimeout() int {
 	return a.Config.TracesSessionTimeout
@@ -675,6 +1942,7 @@ func (a *AppService) GetTraceSessions(page, pageSize int) (TraceSessionsResult,
 			TraceCount:   s.MessageCount,
 			FirstTraceAt: s.FirstTime.Format("2006-01-02 15:04:05"),
 			LastTraceAt:  s.LastTime.Format("2006-01-02 15:04:05"),
+			Models:       s.Models,
 		}
 	}
 	return TraceSessionsResult{
@@ -692,31 +1960,19 @@ func (a *AppService) GetTracesBySession(sessionID string) ([]TraceDetailInfo, er
 		return nil, err
 	}
 
+	redactor := a.traceRedactor()
 	result := make([]TraceDetailInfo, len(traces))
 	for i, t := range traces {
-		result[i] = TraceDetailInfo{
-			ID:              t.ID,
-			SessionID:       t.SessionID,
-			RemoteIP:        t.RemoteIP,
-			Model:           t.Model,
-			ProviderModel:   t.ProviderModel,
-			ProviderName:    t.ProviderName,
-			RequestContent:  t.RequestContent,
-			ResponseContent: t.ResponseContent,
-			RequestTokens:   t.RequestTokens,
-			ResponseTokens:  t.ResponseTokens,
-			TotalTokens:     t.TotalTokens,
-			Success:         t.Success,
-			ErrorMessage:    t.ErrorMessage,
-			Style:           t.Style,
-			IsStream:        t.IsStream,
-			ProxyTimeMs:     t.ProxyTimeMs,
-			CreatedAt:       t.CreatedAt.Format("2006-01-02 15:04:05"),
-		}
+		result[i] = newTraceDetailInfo(t, redactor)
 	}
 	return result, nil
 }
 
+// ExportTraces 按会话或时间范围导出 Trace（OpenAI 微调 JSONL、JSONL、JSON、CSV），开启脱敏时导出内容同样脱敏
+func (a *AppService) ExportTraces(opts service.TraceExportOptions) (*service.TraceExportResult, error) {
+	return a.RouteService.ExportTraces(opts, a.traceRedactor())
+}
+
 // ClearOldTraces 清除过期的 Trace 记录
 func (a *AppService) ClearOldTraces(beforeDays int) (int64, error) {
 	if beforeDays < 0 {
@@ -746,7 +2002,9 @@ type AllTracesResult struct {
 // GetAllTraces 获取所有 trace 记录（按时间倒序，分页，支持筛选）
 // success: "true"/"false"/"" (empty = all)
 // startTime/endTime format: "2006-01-02 15:04:05" or empty string
-func (a *AppService) GetAllTraces(page, pageSize int, success, startTime, endTime string) (AllTracesResult, error) {
+// requestID: 按 X-Request-ID 精确查找
+// search: 全文搜索模型、供应商、客户端 IP、请求/响应内容和错误信息，多个词用空格分隔（需同时匹配）
+func (a *AppService) GetAllTraces(page, pageSize int, success, startTime, endTime, requestID, search string) (AllTracesResult, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -765,33 +2023,22 @@ func (a *AppService) GetAllTraces(page, pageSize int, success, startTime, endTim
 	if endTime != "" {
 		filters["end_time"] = endTime
 	}
+	if requestID != "" {
+		filters["request_id"] = requestID
+	}
+	if search != "" {
+		filters["search"] = search
+	}
 
 	traces, total, err := a.RouteService.GetAllTraces(page, pageSize, filters)
 	if err != nil {
 		return AllTracesResult{}, err
 	}
 
+	redactor := a.traceRedactor()
 	result := make([]TraceDetailInfo, len(traces))
 	for i, t := range traces {
-		result[i] = TraceDetailInfo{
-			ID:              t.ID,
-			SessionID:       t.SessionID,
-			RemoteIP:        t.RemoteIP,
-			Model:           t.Model,
-			ProviderModel:   t.ProviderModel,
-			ProviderName:    t.ProviderName,
-			RequestContent:  t.RequestContent,
-			ResponseContent: t.ResponseContent,
-			RequestTokens:   t.RequestTokens,
-			ResponseTokens:  t.ResponseTokens,
-			TotalTokens:     t.TotalTokens,
-			Success:         t.Success,
-			ErrorMessage:    t.ErrorMessage,
-			Style:           t.Style,
-			IsStream:        t.IsStream,
-			ProxyTimeMs:     t.ProxyTimeMs,
-			CreatedAt:       t.CreatedAt.Format("2006-01-02 15:04:05"),
-		}
+		result[i] = newTraceDetailInfo(t, redactor)
 	}
 	return AllTracesResult{
 		Traces:   result,
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/service"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// configPatchFields PatchConfig（PATCH /api/admin/config）可以修改的字段及其校验，校验与对应的设置方法相同，nil 表示不需要校验。
+// 监听地址、TLS、IP 白名单、API Key、网页管理/MCP 开关、通知 Webhook、适配器插件等字段只能在桌面端或通过专用方法修改
+var configPatchFields = map[string]func(c *config.Config) error{
+	"fallback_enabled":         nil,
+	"proxy_enabled":            nil,
+	"redirect_enabled":         nil,
+	"redirect_keyword":         nil,
+	"redirect_target_model":    nil,
+	"redirect_target_name":     nil,
+	"redirect_target_route_id": nil,
+	"language":                 nil,
+	"log_level": func(c *config.Config) error {
+		if _, err := log.ParseLevel(c.LogLevel); err != nil {
+			return fmt.Errorf("invalid log level: %s", c.LogLevel)
+		}
+		return nil
+	},
+	"log_format": func(c *config.Config) error {
+		if c.LogFormat != "text" && c.LogFormat != "json" {
+			return fmt.Errorf("log_format must be text or json")
+		}
+		return nil
+	},
+	"log_max_size_mb":       atLeast("log_max_size_mb", 1, func(c *config.Config) int { return c.LogMaxSizeMB }),
+	"log_max_backups":       atLeast("log_max_backups", 0, func(c *config.Config) int { return c.LogMaxBackups }),
+	"log_max_message_bytes": atLeast("log_max_message_bytes", 0, func(c *config.Config) int { return c.LogMaxMessageBytes }),
+	"traces_enabled":        nil,
+	"traces_retention_days": atLeast("traces_retention_days", 0, func(c *config.Config) int { return c.TracesRetentionDays }),
+	"traces_session_timeout": atLeast("traces_session_timeout", 0, func(c *config.Config) int {
+		return c.TracesSessionTimeout
+	}),
+	"trace_stream_max_kb": atLeast("trace_stream_max_kb", 0, func(c *config.Config) int { return c.TraceStreamMaxKB }),
+	"retry_max_attempts":  atLeast("retry_max_attempts", 1, func(c *config.Config) int { return c.RetryMaxAttempts }),
+	"retry_base_delay_ms": atLeast("retry_base_delay_ms", 0, func(c *config.Config) int { return c.RetryBaseDelayMs }),
+	"retry_max_delay_ms":  atLeast("retry_max_delay_ms", 0, func(c *config.Config) int { return c.RetryMaxDelayMs }),
+	"retry_jitter": func(c *config.Config) error {
+		if c.RetryJitter < 0 || c.RetryJitter > 1 {
+			return fmt.Errorf("jitter must be between 0 and 1")
+		}
+		return nil
+	},
+	"adaptive_timeout_enabled": nil,
+	"adaptive_timeout_factor": func(c *config.Config) error {
+		if c.AdaptiveTimeoutFactor <= 1 {
+			return fmt.Errorf("factor must be greater than 1")
+		}
+		return nil
+	},
+	"adaptive_timeout_min_ms": validateAdaptiveTimeoutRange,
+	"adaptive_timeout_max_ms": validateAdaptiveTimeoutRange,
+	"models_cache_ttl_minutes": atLeast("models_cache_ttl_minutes", 0, func(c *config.Config) int {
+		return c.ModelsCacheTTLMinutes
+	}),
+	"health_probe_enabled": nil,
+	"health_probe_interval_seconds": atLeast("health_probe_interval_seconds", 30, func(c *config.Config) int {
+		return c.HealthProbeIntervalSeconds
+	}),
+	"health_probe_mode": func(c *config.Config) error {
+		if c.HealthProbeMode != service.HealthProbeModels && c.HealthProbeMode != service.HealthProbeCompletion {
+			return fmt.Errorf("health_probe_mode must be %s or %s", service.HealthProbeModels, service.HealthProbeCompletion)
+		}
+		return nil
+	},
+	"health_aware_routing":      nil,
+	"key_cooldown_seconds":      atLeast("key_cooldown_seconds", 0, func(c *config.Config) int { return c.KeyCooldownSeconds }),
+	"key_auth_cooldown_minutes": atLeast("key_auth_cooldown_minutes", 0, func(c *config.Config) int { return c.KeyAuthCooldownMinutes }),
+	"batch_concurrency":         atLeast("batch_concurrency", 1, func(c *config.Config) int { return c.BatchConcurrency }),
+	"context_overflow_enabled":  nil,
+	"context_overflow_strategy": func(c *config.Config) error { return service.ValidateContextStrategy(c.ContextOverflowStrategy) },
+	"context_summary_model":     nil,
+	"stream_heartbeat_seconds":  atLeast("stream_heartbeat_seconds", 0, func(c *config.Config) int { return c.StreamHeartbeatSeconds }),
+	"max_request_body_mb":       atLeast("max_request_body_mb", 0, func(c *config.Config) int { return c.MaxRequestBodyMB }),
+	"max_request_messages":      atLeast("max_request_messages", 0, func(c *config.Config) int { return c.MaxRequestMessages }),
+	"daily_token_budget": func(c *config.Config) error {
+		if c.DailyTokenBudget < 0 {
+			return fmt.Errorf("daily_token_budget must not be negative")
+		}
+		return nil
+	},
+}
+
+// atLeast 整数字段不小于 min
+func atLeast(name string, min int, get func(c *config.Config) int) func(c *config.Config) error {
+	return func(c *config.Config) error {
+		if get(c) < min {
+			return fmt.Errorf("%s must be at least %d", name, min)
+		}
+		return nil
+	}
+}
+
+// validateAdaptiveTimeoutRange 自适应超时下限不超过上限
+func validateAdaptiveTimeoutRange(c *config.Config) error {
+	if c.AdaptiveTimeoutMinMs < 0 || c.AdaptiveTimeoutMaxMs < 0 {
+		return fmt.Errorf("adaptive timeout must not be negative")
+	}
+	if c.AdaptiveTimeoutMaxMs > 0 && c.AdaptiveTimeoutMinMs > c.AdaptiveTimeoutMaxMs {
+		return fmt.Errorf("min timeout must not exceed max timeout")
+	}
+	return nil
+}
+
+// checkConfigPatchFields 拒绝不在 configPatchFields 中的字段
+func checkConfigPatchFields(fields map[string]interface{}) error {
+	for name := range fields {
+		if _, ok := configPatchFields[name]; !ok {
+			return fmt.Errorf("config field %s cannot be changed through the config API", name)
+		}
+	}
+	return nil
+}
+
+// validateConfigPatch 合并后的配置中，本次修改的字段需要通过校验
+func validateConfigPatch(fields map[string]interface{}) func(next *config.Config) error {
+	return func(next *config.Config) error {
+		for name := range fields {
+			if validate := configPatchFields[name]; validate != nil {
+				if err := validate(next); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ConfigChangedEventName 托盘等桌面端快捷操作修改配置后发给前端的事件，前端收到后重新加载配置
+const ConfigChangedEventName = "anyproxy:config-changed"
+
+// QuickStatus 托盘快捷操作使用的状态
+type QuickStatus struct {
+	Paused                bool   `json:"paused"`
+	FallbackEnabled       bool   `json:"fallbackEnabled"`
+	RedirectEnabled       bool   `json:"redirectEnabled"`
+	RedirectKeyword       string `json:"redirectKeyword"`
+	RedirectTargetRouteID int64  `json:"redirectTargetRouteId"`
+	TodayRequests         int64  `json:"todayRequests"`
+	TodayTokens           int64  `json:"todayTokens"`
+}
+
+// GetQuickStatus 获取暂停、故障转移、重定向目标和今日用量
+func (a *AppService) GetQuickStatus() QuickStatus {
+	status := QuickStatus{
+		Paused:                a.ProxyService.IsPaused(),
+		FallbackEnabled:       a.Config.FallbackEnabled,
+		RedirectEnabled:       a.Config.RedirectEnabled,
+		RedirectKeyword:       a.Config.RedirectKeyword,
+		RedirectTargetRouteID: a.Config.RedirectTargetRouteID,
+	}
+	if stats, err := a.GetStats(); err == nil {
+		status.TodayRequests = stats.TodayRequests
+		status.TodayTokens = stats.TodayTokens
+	}
+	return status
+}
+
+// GetProxyPaused 代理是否已暂停
+func (a *AppService) GetProxyPaused() bool {
+	return a.ProxyService.IsPaused()
+}
+
+// SetProxyPaused 暂停/恢复代理（不保存，重启后恢复运行）
+func (a *AppService) SetProxyPaused(paused bool) error {
+	a.ProxyService.SetPaused(paused)
+	return nil
+}
+
+// SetRedirectTarget 将重定向关键字的目标切换为指定路由
+func (a *AppService) SetRedirectTarget(routeID int64) error {
+	route, err := a.RouteService.GetRouteByID(routeID)
+	if err != nil {
+		return fmt.Errorf("route %d not found: %v", routeID, err)
+	}
+	a.Config.RedirectTargetRouteID = route.ID
+	a.Config.RedirectTargetModel = route.Model
+	a.Config.RedirectTargetName = route.Name
+	if err := a.Config.Save(); err != nil {
+		log.Errorf("Failed to save config: %v", err)
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	log.Infof("Redirect target switched to route %s (%s)", route.Name, route.Model)
+	return nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"os"
+
+	"openai-router-go/internal/system"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GetServiceStatus 获取系统服务（Windows 服务 / systemd 用户单元）的安装和运行状态
+func (a *AppService) GetServiceStatus() system.ServiceStatus {
+	return a.Service.Status()
+}
+
+// InstallService 将无界面 API 服务器注册为系统服务，使用当前的 config.json 和数据目录
+func (a *AppService) InstallService() error {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %v", err)
+	}
+	if err := a.Service.Install(workDir); err != nil {
+		log.Errorf("Failed to install service: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UninstallService 停止并删除系统服务
+func (a *AppService) UninstallService() error {
+	if err := a.Service.Uninstall(); err != nil {
+		log.Errorf("Failed to uninstall service: %v", err)
+		return err
+	}
+	return nil
+}
+
+// StartService 启动系统服务（与桌面端使用相同端口，需要先退出桌面端的 API 服务器）
+func (a *AppService) StartService() error {
+	if err := a.Service.Start(); err != nil {
+		log.Errorf("Failed to start service: %v", err)
+		return err
+	}
+	log.Info("Service started")
+	return nil
+}
+
+// StopService 停止系统服务
+func (a *AppService) StopService() error {
+	if err := a.Service.Stop(); err != nil {
+		log.Errorf("Failed to stop service: %v", err)
+		return err
+	}
+	log.Info("Service stopped")
+	return nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"fmt"
+
+	"openai-router-go/internal/config"
+	"openai-router-go/internal/service"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RestartEnv RestartApp 启动的新进程带有此环境变量，启动时等待旧进程退出
+const RestartEnv = "ANYPROXY_RESTARTING"
+
+// UpdateAvailableEventName 定期检查发现新版本时发给前端的事件
+const UpdateAvailableEventName = "anyproxy:update-available"
+
+// GetUpdateSettings 获取更新设置、当前版本和最近一次检查的结果
+func (a *AppService) GetUpdateSettings() map[string]interface{} {
+	result := map[string]interface{}{
+		"enabled":        a.Config.UpdateCheckEnabled,
+		"channel":        a.Config.UpdateChannel,
+		"currentVersion": config.Version,
+	}
+	if a.Updater != nil {
+		result["status"] = a.Updater.Status()
+	}
+	return result
+}
+
+// SetUpdateSettings 设置是否自动检查更新和更新通道(stable/beta)
+func (a *AppService) SetUpdateSettings(enabled bool, channel string) error {
+	if channel != service.UpdateChannelStable && channel != service.UpdateChannelBeta {
+		return fmt.Errorf("unsupported update channel %q", channel)
+	}
+	a.Config.UpdateCheckEnabled = enabled
+	a.Config.UpdateChannel = channel
+	log.Infof("Update settings updated: enabled=%v, channel=%s", enabled, channel)
+	return a.Config.Save()
+}
+
+// CheckForUpdate 立即检查所选通道的新版本
+func (a *AppService) CheckForUpdate() (service.UpdateStatus, error) {
+	if a.Updater == nil {
+		return service.UpdateStatus{}, fmt.Errorf("updater not initialized")
+	}
+	return a.Updater.Check()
+}
+
+// GetUpdateStatus 获取更新状态（安装时用于显示下载进度）
+func (a *AppService) GetUpdateStatus() service.UpdateStatus {
+	if a.Updater == nil {
+		return service.UpdateStatus{CurrentVersion: config.Version}
+	}
+	return a.Updater.Status()
+}
+
+// InstallUpdate 下载并校验新版本，替换当前程序文件，完成后需要调用 RestartApp 重启
+func (a *AppService) InstallUpdate() (service.UpdateStatus, error) {
+	if a.Updater == nil {
+		return service.UpdateStatus{}, fmt.Errorf("updater not initialized")
+	}
+	return a.Updater.Install()
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// webAdminAllowed 网页管理界面可以调用的方法（前端 wails-shim、/api/admin 和 MCP 用到的方法）
+// AppService 新增的导出方法必须加入此列表或 webAdminDenied，否则测试失败
+var webAdminAllowed = map[string]bool{
+	"AddRoute":                   true,
+	"AggregateConversation":      true,
+	"ApplyModelSyncChange":       true,
+	"CancelActiveRequest":        true,
+	"CancelPlayground":           true,
+	"CheckForUpdate":             true,
+	"ClearAllTraces":             true,
+	"ClearMirrorResults":         true,
+	"ClearOldTraces":             true,
+	"ClearSchemaDriftWarnings":   true,
+	"ClearStats":                 true,
+	"CompareConversation":        true,
+	"CompressDatabase":           true,
+	"DeleteExperiment":           true,
+	"DeleteMirrorRule":           true,
+	"DeleteModelMetadata":        true,
+	"DeleteModelSyncSource":      true,
+	"DeletePromptTemplate":       true,
+	"DeleteRoute":                true,
+	"DismissModelSyncChange":     true,
+	"ExportRoutes":               true,
+	"ExportTraces":               true,
+	"FetchOpenRouterPrices":      true,
+	"FetchRemoteModels":          true,
+	"GetActiveRequests":          true,
+	"GetAdapterPlugins":          true,
+	"GetAllTraces":               true,
+	"GetAppSettings":             true,
+	"GetBodyLogSettings":         true,
+	"GetClaudeMaxTokens":         true,
+	"GetClientSnippets":          true,
+	"GetConfig":                  true,
+	"GetContextOverflowSettings": true,
+	"GetDailyStats":              true,
+	"GetErrorStats":              true,
+	"GetExperimentReport":        true,
+	"GetExperiments":             true,
+	"GetHealthProbeSettings":     true,
+	"GetHealthStatus":            true,
+	"GetHourlyStats":             true,
+	"GetKeyPoolStatus":           true,
+	"GetLatencyStats":            true,
+	"GetLogBufferStatus":         true,
+	"GetLogSettings":             true,
+	"GetMCPEnabled":              true,
+	"GetMaintenanceSettings":     true,
+	"GetMetrics":                 true,
+	"GetMirrorResults":           true,
+	"GetMirrorRules":             true,
+	"GetMirrorSummary":           true,
+	"GetModelCompatSettings":     true,
+	"GetModelMetadata":           true,
+	"GetModelRanking":            true,
+	"GetModelSyncChanges":        true,
+	"GetModelSyncSources":        true,
+	"GetModerationSettings":      true,
+	"GetNetworkSettings":         true,
+	"GetNotificationSettings":    true,
+	"GetPlaygroundSession":       true,
+	"GetPromptTemplates":         true,
+	"GetProviderTemplates":       true,
+	"GetProxyEnabled":            true,
+	"GetProxyPaused":             true,
+	"GetQuickStatus":             true,
+	"GetQuotaSettings":           true,
+	"GetReportSettings":          true,
+	"GetRequestLimits":           true,
+	"GetRequestLogExportColumns": true,
+	"GetRequestLogs":             true,
+	"GetRouteGroups":             true,
+	"GetRouteKeyStats":           true,
+	"GetRoutes":                  true,
+	"GetRoutesWithKeys":          true,
+	"GetRoutingRuleSettings":     true,
+	"GetSchemaDriftWarnings":     true,
+	"GetSecondlyStats":           true,
+	"GetServiceStatus":           true,
+	"GetStats":                   true,
+	"GetStatsBreakdown":          true,
+	"GetTLSSettings":             true,
+	"GetTraceConversion":         true,
+	"GetTraceRedactionEnabled":   true,
+	"GetTraceSessions":           true,
+	"GetTraceStreamMaxKB":        true,
+	"GetTracesBySession":         true,
+	"GetTracesCount":             true,
+	"GetTracesEnabled":           true,
+	"GetTracesRetentionDays":     true,
+	"GetTracesSessionTimeout":    true,
+	"GetUpdateSettings":          true,
+	"GetUpdateStatus":            true,
+	"GetUsageSummary":            true,
+	"GetWebAdminEnabled":         true,
+	"ImportExternalConfig":       true,
+	"ImportRouteFromFormat":      true,
+	"ImportRoutes":               true,
+	"PatchConfig":                true,
+	"PreviewUsageReport":         true,
+	"RefreshRemoteModels":        true,
+	"RevealRouteKey":             true,
+	"RevealTrace":                true,
+	"RunHealthProbeNow":          true,
+	"RunMaintenanceNow":          true,
+	"SaveExperiment":             true,
+	"SaveMirrorRule":             true,
+	"SaveModelMetadata":          true,
+	"SaveModelSyncSource":        true,
+	"SavePromptTemplate":         true,
+	"SendUsageReportNow":         true,
+	"SetBodyLogSettings":         true,
+	"SetClaudeMaxTokens":         true,
+	"SetContextOverflowSettings": true,
+	"SetEnableFileLog":           true,
+	"SetFallbackEnabled":         true,
+	"SetGroupPriority":           true,
+	"SetHealthProbeSettings":     true,
+	"SetLogSettings":             true,
+	"SetMaintenanceSettings":     true,
+	"SetModelCompatSettings":     true,
+	"SetModerationSettings":      true,
+	"SetNotificationSettings":    true,
+	"SetProxyEnabled":            true,
+	"SetProxyPaused":             true,
+	"SetQuotaSettings":           true,
+	"SetRedirectTarget":          true,
+	"SetReportSettings":          true,
+	"SetRequestLimits":           true,
+	"SetRouteExtras":             true,
+	"SetRouteMaxTokens":          true,
+	"SetRoutePriority":           true,
+	"SetRouteProviderPrefs":      true,
+	"SetRouteQuota":              true,
+	"SetRouteUpstreamModel":      true,
+	"SetRoutingRuleSettings":     true,
+	"SetTraceRedactionEnabled":   true,
+	"SetTraceStreamMaxKB":        true,
+	"SetTracesEnabled":           true,
+	"SetTracesRetentionDays":     true,
+	"SetTracesSessionTimeout":    true,
+	"SetUpdateSettings":          true,
+	"StartPlayground":            true,
+	"SyncModelSourceNow":         true,
+	"TestNotification":           true,
+	"TestRoute":                  true,
+	"ToggleRoute":                true,
+	"UpdateConfig":               true,
+	"UpdateLocalApiKey":          true,
+	"UpdateRoute":                true,
+}
+
+// webAdminDenied 网页管理界面不允许调用的方法，Invoke 只放行 webAdminAllowed 中的方法，此列表记录拒绝的原因
+var webAdminDenied = map[string]bool{
+	// 注入依赖
+	"Invoke":          true,
+	"SetApp":          true,
+	"SetAPIServer":    true,
+	"SetHealthProber": true,
+	"SetMaintenance":  true,
+	"SetModelSyncer":  true,
+	"SetNotifier":     true,
+	"SetReporter":     true,
+	"SetUpdater":      true,
+	// 桌面端专用
+	"RestartApp":         true, // 桌面应用操作
+	"SetAutoStart":       true, // 桌面应用操作
+	"SetMinimizeToTray":  true, // 桌面应用操作
+	"InstallUpdate":      true, // 桌面应用操作
+	"StartService":       true, // Windows 服务控制只能在桌面端操作
+	"StopService":        true, // Windows 服务控制只能在桌面端操作
+	"InstallService":     true, // Windows 服务控制只能在桌面端操作
+	"UninstallService":   true, // Windows 服务控制只能在桌面端操作
+	"UpdatePort":         true, // 修改监听端口会重启网页管理界面所在的 API 服务器
+	"SetNetworkSettings": true, // 监听地址变更可能让网页端失去连接或暴露到外网
+	"SetTLSSettings":     true, // 证书路径指向本机文件，改错后网页端无法连接
+	"SetWebAdminEnabled": true, // 网页端不能关闭自身或修改远程访问开关
+	"SetMCPEnabled":      true, // 网页端不能关闭自身或修改远程访问开关
+	"SetAdapterPlugins":  true, // 插件会在本机执行配置的程序，只能在桌面端设置
+	"ExportRequestLogs":  true, // 保存对话框会弹在运行服务的桌面上，网页端使用 /api/admin/logs/export
+	// 网页界面未使用
+	"ApplyConfig":                true,
+	"GetAdaptiveTimeoutSettings": true,
+	"GetLanguage":                true,
+	"GetRetrySettings":           true,
+	"SetAdaptiveTimeoutSettings": true,
+	"SetLanguage":                true,
+	"SetRetrySettings":           true,
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Invoke 网页管理界面按方法名调用 AppService，与 Wails 绑定相同：参数按 JSON 解码，
+// 返回值为 (结果)、(error) 或 (结果, error)
+func (a *AppService) Invoke(method string, args []json.RawMessage) (interface{}, error) {
+	if !webAdminAllowed[method] {
+		return nil, fmt.Errorf("method %s is not available in web admin", method)
+	}
+	m := reflect.ValueOf(a).MethodByName(method)
+	if !m.IsValid() {
+		return nil, fmt.Errorf("unknown method: %s", method)
+	}
+	mt := m.Type()
+	if mt.IsVariadic() || len(args) > mt.NumIn() {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", method, mt.NumIn(), len(args))
+	}
+
+	// 缺少的参数使用零值（与前端省略可选参数一致）
+	in := make([]reflect.Value, mt.NumIn())
+	for i := range in {
+		arg := reflect.New(mt.In(i))
+		if i < len(args) && len(args[i]) > 0 {
+			if err := json.Unmarshal(args[i], arg.Interface()); err != nil {
+				return nil, fmt.Errorf("%s argument %d: %v", method, i+1, err)
+			}
+		}
+		in[i] = arg.Elem()
+	}
+
+	log.Debugf("[Web Admin] Invoke %s", method)
+	out := m.Call(in)
+	var result interface{}
+	for _, v := range out {
+		if v.Type() == errorType {
+			if !v.IsNil() {
+				return nil, v.Interface().(error)
+			}
+			continue
+		}
+		result = v.Interface()
+	}
+	return result, nil
+}
+
+// GetWebAdminEnabled 获取网页管理界面是否启用
+func (a *AppService) GetWebAdminEnabled() bool {
+	return a.Config.WebAdminEnabled
+}
+
+// SetWebAdminEnabled 启用/关闭 API 服务器上的网页管理界面（/admin），立即生效
+func (a *AppService) SetWebAdminEnabled(enabled bool) error {
+	if enabled && a.Config.AuthKey() == "" {
+		return fmt.Errorf("web admin requires a local API key")
+	}
+	a.Config.WebAdminEnabled = enabled
+	if err := a.Config.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	log.Infof("Web admin enabled: %v", enabled)
+	return nil
+}
+
+// GetMCPEnabled 获取 MCP 服务器是否启用
+func (a *AppService) GetMCPEnabled() bool {
+	return a.Config.MCPEnabled
+}
+
+// SetMCPEnabled 启用/关闭 API 服务器上的 MCP 服务器（/mcp），立即生效
+func (a *AppService) SetMCPEnabled(enabled bool) error {
+	if enabled && a.Config.AuthKey() == "" {
+		return fmt.Errorf("MCP server requires a local API key")
+	}
+	a.Config.MCPEnabled = enabled
+	if err := a.Config.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	log.Infof("MCP server enabled: %v", enabled)
+	return nil
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWebAdminMethodLists(t *testing.T) {
+	typ := reflect.TypeOf(&AppService{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		name := typ.Method(i).Name
+		if webAdminAllowed[name] == webAdminDenied[name] {
+			t.Errorf("method %s must be listed in exactly one of webAdminAllowed and webAdminDenied", name)
+		}
+	}
+	for _, list := range []map[string]bool{webAdminAllowed, webAdminDenied} {
+		for name := range list {
+			if _, ok := typ.MethodByName(name); !ok {
+				t.Errorf("%s is listed but AppService has no such method", name)
+			}
+		}
+	}
+}
+
+func TestInvokeDeniedMethod(t *testing.T) {
+	a := &AppService{}
+	for _, method := range []string{"StartService", "SetTLSSettings", "SetNetworkSettings", "UpdatePort", "Invoke"} {
+		if _, err := a.Invoke(method, nil); err == nil {
+			t.Errorf("Invoke(%s) succeeded, want error", method)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"openai-router-go/services"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// trayRefreshInterval 托盘提示（今日用量）和路由子菜单的刷新间隔
+const trayRefreshInterval = 30 * time.Second
+
+// trayTexts 托盘菜单文本
+type trayTexts struct {
+	ShowWindow     string
+	PauseProxy     string
+	Fallback       string
+	RedirectTarget string
+	NoRoutes       string
+	Quit           string
+	Paused         string
+	Today          string
+}
+
+func newTrayTexts(language string) trayTexts {
+	if language == "zh-CN" {
+		return trayTexts{
+			ShowWindow:     "显示主窗口",
+			PauseProxy:     "暂停代理",
+			Fallback:       "故障转移",
+			RedirectTarget: "重定向目标",
+			NoRoutes:       "没有可用路由",
+			Quit:           "退出",
+			Paused:         "已暂停",
+			Today:          "今日 %d 次请求，%s tokens",
+		}
+	}
+	return trayTexts{
+		ShowWindow:     "Show Window",
+		PauseProxy:     "Pause Proxy",
+		Fallback:       "Fallback",
+		RedirectTarget: "Redirect Target",
+		NoRoutes:       "No routes available",
+		Quit:           "Quit",
+		Paused:         "Paused",
+		Today:          "Today: %d requests, %s tokens",
+	}
+}
+
+// trayController 托盘菜单：显示窗口、暂停代理、故障转移开关、选择重定向目标路由，提示中显示今日用量
+type trayController struct {
+	app        *application.App
+	tray       *application.SystemTray
+	appSvc     *services.AppService
+	texts      trayTexts
+	showWindow func()
+}
+
+// start 生成菜单并定时刷新
+func (t *trayController) start() {
+	t.refresh()
+	go func() {
+		ticker := time.NewTicker(trayRefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			application.InvokeAsync(t.refresh)
+		}
+	}()
+}
+
+// refresh 重新生成菜单和提示（路由和设置可能已在主窗口中修改）
+func (t *trayController) refresh() {
+	status := t.appSvc.GetQuickStatus()
+
+	tooltip := "AnyProxyAi"
+	if status.Paused {
+		tooltip += " (" + t.texts.Paused + ")"
+	}
+	tooltip += "\n" + fmt.Sprintf(t.texts.Today, status.TodayRequests, formatTokenCount(status.TodayTokens))
+	t.tray.SetTooltip(tooltip)
+
+	menu := application.NewMenu()
+	menu.Add(t.texts.ShowWindow).OnClick(func(ctx *application.Context) {
+		t.showWindow()
+	})
+	menu.AddSeparator()
+	menu.AddCheckbox(t.texts.PauseProxy, status.Paused).OnClick(func(ctx *application.Context) {
+		t.apply(t.appSvc.SetProxyPaused(!status.Paused))
+	})
+	menu.AddCheckbox(t.texts.Fallback, status.FallbackEnabled).OnClick(func(ctx *application.Context) {
+		t.apply(t.appSvc.SetFallbackEnabled(!status.FallbackEnabled))
+	})
+	t.addRedirectMenu(menu.AddSubmenu(t.texts.RedirectTarget), status.RedirectTargetRouteID)
+	menu.AddSeparator()
+	menu.Add(t.texts.Quit).OnClick(func(ctx *application.Context) {
+		log.Info("Quit from tray menu")
+		t.app.Quit()
+	})
+	t.tray.SetMenu(menu)
+}
+
+// addRedirectMenu 重定向目标子菜单，列出所有已启用路由
+func (t *trayController) addRedirectMenu(submenu *application.Menu, currentID int64) {
+	routes, err := t.appSvc.RouteService.GetAllRoutes()
+	if err != nil {
+		log.Warnf("Failed to load routes for tray menu: %v", err)
+	}
+	count := 0
+	for _, route := range routes {
+		if !route.Enabled {
+			continue
+		}
+		routeID := route.ID
+		submenu.AddRadio(fmt.Sprintf("%s (%s)", route.Name, route.Model), routeID == currentID).OnClick(func(ctx *application.Context) {
+			t.apply(t.appSvc.SetRedirectTarget(routeID))
+		})
+		count++
+	}
+	if count == 0 {
+		submenu.Add(t.texts.NoRoutes).SetEnabled(false)
+	}
+}
+
+// apply 快捷操作完成后通知前端重新加载配置，并刷新菜单
+func (t *trayController) apply(err error) {
+	if err != nil {
+		log.Errorf("Tray action failed: %v", err)
+	}
+	t.app.Event.Emit(services.ConfigChangedEventName)
+	application.InvokeAsync(t.refresh)
+}
+
+// formatTokenCount 以 K/M 显示 token 数
+func formatTokenCount(n int64) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fK", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}